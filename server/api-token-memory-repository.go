@@ -0,0 +1,116 @@
+package main
+
+import "time"
+
+type MemoryAPITokenRepository struct {
+	apiTokenRepositoryBase
+}
+
+func (r *MemoryAPITokenRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryAPITokenRepository) Create(e *APIToken) error {
+	if err := memoryCheckUUID(e.OrganizationID, e.UserID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	if memoryCount(memoryData.apiTokens, func(row *APIToken) bool { return row.TokenHash == e.TokenHash }) > 0 {
+		return memoryUniqueViolation("idx_api_tokens_token_hash")
+	}
+	e.ID = memoryNewID()
+	row := r.copy(e)
+	row.Created = memoryTimestamp(e.Created)
+	row.LastUsed = nil
+	memoryData.apiTokens = append(memoryData.apiTokens, row)
+	return nil
+}
+
+func (r *MemoryAPITokenRepository) GetOne(id string) (*APIToken, error) {
+	return r.getOne(func(e *APIToken) bool {
+		return e.ID == id
+	})
+}
+
+// GetByToken returns the token matching the bearer token, regardless of its expiry.
+func (r *MemoryAPITokenRepository) GetByToken(token string) (*APIToken, error) {
+	tokenHash := r.GetTokenHash(token)
+	return r.getOne(func(e *APIToken) bool {
+		return e.TokenHash == tokenHash
+	})
+}
+
+func (r *MemoryAPITokenRepository) getOne(cond func(e *APIToken) bool) (*APIToken, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e, err := memoryFind(memoryData.apiTokens, cond)
+	if err != nil {
+		return nil, err
+	}
+	return r.copy(e), nil
+}
+
+func (r *MemoryAPITokenRepository) GetAll(userID string) ([]*APIToken, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	var result []*APIToken
+	for _, e := range memoryData.apiTokens {
+		if e.UserID == userID {
+			result = append(result, r.copy(e))
+		}
+	}
+	memorySort(result, func(a, b *APIToken) bool {
+		return a.Created.Before(b.Created)
+	})
+	return result, nil
+}
+
+func (r *MemoryAPITokenRepository) SetLastUsed(e *APIToken, lastUsed time.Time) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	for _, row := range memoryData.apiTokens {
+		if row.ID == e.ID {
+			row.LastUsed = memoryNullTimestamp(&lastUsed)
+		}
+	}
+	e.LastUsed = &lastUsed
+	return nil
+}
+
+func (r *MemoryAPITokenRepository) Delete(e *APIToken) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.apiTokens = memoryDelete(memoryData.apiTokens, func(row *APIToken) bool {
+		return row.ID == e.ID
+	})
+	return nil
+}
+
+func (r *MemoryAPITokenRepository) DeleteOfUser(e *User) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.apiTokens = memoryDelete(memoryData.apiTokens, func(row *APIToken) bool {
+		return row.UserID == e.ID
+	})
+	return nil
+}
+
+func (r *MemoryAPITokenRepository) DeleteAll(organizationID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.apiTokens = memoryDelete(memoryData.apiTokens, func(row *APIToken) bool {
+		return row.OrganizationID == organizationID
+	})
+	return nil
+}
+
+// copy returns a copy of the token which doesn't share its scopes and times.
+func (r *MemoryAPITokenRepository) copy(e *APIToken) *APIToken {
+	c := *e
+	c.Scopes = append([]string{}, e.Scopes...)
+	c.Expiry = memoryNullTimestamp(e.Expiry)
+	c.LastUsed = memoryNullTimestamp(e.LastUsed)
+	return &c
+}
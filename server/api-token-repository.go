@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+type APITokenRepository interface {
+	Repository
+	Create(e *APIToken) error
+	GetOne(id string) (*APIToken, error)
+	GetByToken(token string) (*APIToken, error)
+	GetAll(userID string) ([]*APIToken, error)
+	SetLastUsed(e *APIToken, lastUsed time.Time) error
+	Delete(e *APIToken) error
+	DeleteOfUser(e *User) error
+	DeleteAll(organizationID string) error
+	GetTokenHash(token string) string
+}
+
+type PostgresAPITokenRepository struct {
+	apiTokenRepositoryBase
+}
+
+// apiTokenRepositoryBase holds the token hashing shared by all implementations.
+type apiTokenRepositoryBase struct {
+}
+
+// APIToken authenticates requests of integrations on behalf of its user, which is
+// either a regular user or a service account. Only the SHA-256 hash of the bearer
+// token is stored, so the token itself is shown once when it is generated.
+type APIToken struct {
+	ID             string
+	OrganizationID string
+	UserID         string
+	Name           string
+	TokenHash      string
+	Scopes         []string
+	Created        time.Time
+	Expiry         *time.Time
+	LastUsed       *time.Time
+}
+
+func (r *PostgresAPITokenRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		{
+			Version:     23,
+			Description: "Add API tokens",
+			Up: []string{
+				"CREATE TABLE IF NOT EXISTS api_tokens (" +
+					"id uuid DEFAULT uuid_generate_v4(), " +
+					"organization_id uuid NOT NULL, " +
+					"user_id uuid NOT NULL, " +
+					"name VARCHAR NOT NULL, " +
+					"token_hash VARCHAR NOT NULL, " +
+					"scopes VARCHAR NOT NULL DEFAULT '', " +
+					"created TIMESTAMP NOT NULL, " +
+					"expiry TIMESTAMP NULL, " +
+					"last_used TIMESTAMP NULL, " +
+					"PRIMARY KEY (id))",
+				"CREATE UNIQUE INDEX IF NOT EXISTS idx_api_tokens_token_hash ON api_tokens(token_hash)",
+				"CREATE INDEX IF NOT EXISTS idx_api_tokens_user_id ON api_tokens(user_id)",
+			},
+			Down: []string{"DROP TABLE IF EXISTS api_tokens"},
+		},
+	}
+}
+
+func (r *PostgresAPITokenRepository) Create(e *APIToken) error {
+	var id string
+	err := GetDatabase().DB().QueryRow("INSERT INTO api_tokens "+
+		"(organization_id, user_id, name, token_hash, scopes, created, expiry) "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7) "+
+		"RETURNING id",
+		e.OrganizationID, e.UserID, e.Name, e.TokenHash, strings.Join(e.Scopes, ","), e.Created, e.Expiry).Scan(&id)
+	if err != nil {
+		return err
+	}
+	e.ID = id
+	return nil
+}
+
+func (r *PostgresAPITokenRepository) GetOne(id string) (*APIToken, error) {
+	e := &APIToken{}
+	var scopes string
+	err := GetDatabase().DB().QueryRow("SELECT id, organization_id, user_id, name, token_hash, scopes, created, expiry, last_used "+
+		"FROM api_tokens "+
+		"WHERE id = $1",
+		id).Scan(&e.ID, &e.OrganizationID, &e.UserID, &e.Name, &e.TokenHash, &scopes, &e.Created, &e.Expiry, &e.LastUsed)
+	if err != nil {
+		return nil, err
+	}
+	e.Scopes = r.splitScopes(scopes)
+	return e, nil
+}
+
+// GetByToken returns the token matching the bearer token, regardless of its expiry.
+func (r *PostgresAPITokenRepository) GetByToken(token string) (*APIToken, error) {
+	e := &APIToken{}
+	var scopes string
+	err := GetDatabase().DB().QueryRow("SELECT id, organization_id, user_id, name, token_hash, scopes, created, expiry, last_used "+
+		"FROM api_tokens "+
+		"WHERE token_hash = $1",
+		r.GetTokenHash(token)).Scan(&e.ID, &e.OrganizationID, &e.UserID, &e.Name, &e.TokenHash, &scopes, &e.Created, &e.Expiry, &e.LastUsed)
+	if err != nil {
+		return nil, err
+	}
+	e.Scopes = r.splitScopes(scopes)
+	return e, nil
+}
+
+func (r *PostgresAPITokenRepository) GetAll(userID string) ([]*APIToken, error) {
+	var result []*APIToken
+	rows, err := GetDatabase().DB().Query("SELECT id, organization_id, user_id, name, token_hash, scopes, created, expiry, last_used "+
+		"FROM api_tokens "+
+		"WHERE user_id = $1 "+
+		"ORDER BY created", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &APIToken{}
+		var scopes string
+		err = rows.Scan(&e.ID, &e.OrganizationID, &e.UserID, &e.Name, &e.TokenHash, &scopes, &e.Created, &e.Expiry, &e.LastUsed)
+		if err != nil {
+			return nil, err
+		}
+		e.Scopes = r.splitScopes(scopes)
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (r *PostgresAPITokenRepository) SetLastUsed(e *APIToken, lastUsed time.Time) error {
+	_, err := GetDatabase().DB().Exec("UPDATE api_tokens SET last_used = $1 WHERE id = $2", lastUsed, e.ID)
+	if err != nil {
+		return err
+	}
+	e.LastUsed = &lastUsed
+	return nil
+}
+
+func (r *PostgresAPITokenRepository) Delete(e *APIToken) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM api_tokens WHERE id = $1", e.ID)
+	return err
+}
+
+func (r *PostgresAPITokenRepository) DeleteOfUser(e *User) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM api_tokens WHERE user_id = $1", e.ID)
+	return err
+}
+
+func (r *PostgresAPITokenRepository) DeleteAll(organizationID string) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM api_tokens WHERE organization_id = $1", organizationID)
+	return err
+}
+
+func (r *apiTokenRepositoryBase) GetTokenHash(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func (r *PostgresAPITokenRepository) splitScopes(scopes string) []string {
+	if scopes == "" {
+		return []string{}
+	}
+	return strings.Split(scopes, ",")
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type APITokenRouter struct {
+	repos *Repositories
+}
+
+type CreateAPITokenRequest struct {
+	Name   string     `json:"name" validate:"required"`
+	Scopes []string   `json:"scopes" validate:"required"`
+	Expiry *time.Time `json:"expiry"`
+}
+
+type GetAPITokenResponse struct {
+	ID       string     `json:"id"`
+	UserID   string     `json:"userId"`
+	Created  time.Time  `json:"created"`
+	LastUsed *time.Time `json:"lastUsed"`
+	Token    string     `json:"token,omitempty"`
+	CreateAPITokenRequest
+}
+
+type CreateServiceAccountRequest struct {
+	Name string `json:"name" validate:"required"`
+	Role int    `json:"role"`
+}
+
+type GetServiceAccountResponse struct {
+	ID string `json:"id"`
+	CreateServiceAccountRequest
+}
+
+const (
+	APITokenScopeReadBookings  string = "read-bookings"
+	APITokenScopeWriteBookings string = "write-bookings"
+	APITokenScopeAdmin         string = "admin"
+)
+
+var APITokenScopes = []string{
+	APITokenScopeReadBookings,
+	APITokenScopeWriteBookings,
+	APITokenScopeAdmin,
+}
+
+// APITokenPrefix distinguishes API tokens from JWTs in the Authorization header.
+const APITokenPrefix string = "sst_"
+
+// APITokenLastUsedInterval limits how often a token's last use is written.
+const APITokenLastUsedInterval time.Duration = time.Minute
+
+// ServiceAccountEmailDomain is used for the generated email addresses of service
+// accounts. The .invalid TLD is reserved, so no one can log in with these addresses.
+const ServiceAccountEmailDomain string = "service-account.invalid"
+
+func (router *APITokenRouter) setupRoutes(s *mux.Router) {
+	s.HandleFunc("/service-account/{id}/token/", router.getAllOfServiceAccount).Methods("GET")
+	s.HandleFunc("/service-account/{id}/token/", router.createForServiceAccount).Methods("POST")
+	s.HandleFunc("/service-account/{id}", router.deleteServiceAccount).Methods("DELETE")
+	s.HandleFunc("/service-account/", router.createServiceAccount).Methods("POST")
+	s.HandleFunc("/service-account/", router.getAllServiceAccounts).Methods("GET")
+	s.HandleFunc("/{id}", router.delete).Methods("DELETE")
+	s.HandleFunc("/", router.create).Methods("POST")
+	s.HandleFunc("/", router.getAll).Methods("GET")
+}
+
+func (router *APITokenRouter) getAll(w http.ResponseWriter, r *http.Request) {
+	router.sendTokens(w, GetRequestUser(router.repos, r))
+}
+
+func (router *APITokenRouter) create(w http.ResponseWriter, r *http.Request) {
+	router.createToken(w, r, GetRequestUser(router.repos, r))
+}
+
+// delete revokes the token. Org admins may revoke all tokens of their organization.
+func (router *APITokenRouter) delete(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	e, err := router.repos.APIToken.GetOne(vars["id"])
+	if err != nil {
+		SendNotFound(w)
+		return
+	}
+	user := GetRequestUser(router.repos, r)
+	if e.UserID != user.ID && !CanAdminOrg(user, e.OrganizationID) {
+		SendForbidden(w)
+		return
+	}
+	if err := router.repos.APIToken.Delete(e); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	recordAuditEvent(router.repos, r, e.OrganizationID, AuditActionAPITokenDeleted, AuditTargetAPIToken, e.ID, router.copyToRestModel(e), nil)
+	SendUpdated(w)
+}
+
+func (router *APITokenRouter) getAllServiceAccounts(w http.ResponseWriter, r *http.Request) {
+	user := GetRequestUser(router.repos, r)
+	if !CanAdminOrg(user, user.OrganizationID) {
+		SendForbidden(w)
+		return
+	}
+	list, err := router.repos.User.GetAllServiceAccounts(user.OrganizationID)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	res := []*GetServiceAccountResponse{}
+	for _, e := range list {
+		res = append(res, router.copyServiceAccountToRestModel(e))
+	}
+	SendJSON(w, res)
+}
+
+func (router *APITokenRouter) createServiceAccount(w http.ResponseWriter, r *http.Request) {
+	user := GetRequestUser(router.repos, r)
+	if !CanAdminOrg(user, user.OrganizationID) {
+		SendForbidden(w)
+		return
+	}
+	var m CreateServiceAccountRequest
+	if UnmarshalValidateBody(r, &m) != nil {
+		SendBadRequest(w)
+		return
+	}
+	role := UserRole(m.Role)
+	if (role != UserRoleUser && role != UserRoleSpaceAdmin && role != UserRoleOrgAdmin) || role > user.Role {
+		SendBadRequest(w)
+		return
+	}
+	org, err := router.repos.Organization.GetOne(user.OrganizationID)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	if !router.repos.User.canCreateUser(org) {
+		SendPaymentRequired(w)
+		return
+	}
+	e := &User{
+		OrganizationID: user.OrganizationID,
+		Email:          "service-account-" + uuid.New().String() + "@" + ServiceAccountEmailDomain,
+		Role:           role,
+		DisplayName:    m.Name,
+		ServiceAccount: true,
+	}
+	if err := router.repos.User.Create(e); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	userRouter := &UserRouter{repos: router.repos}
+	recordAuditEvent(router.repos, r, e.OrganizationID, AuditActionUserCreated, AuditTargetUser, e.ID, nil, userRouter.copyToRestModel(e, true))
+	SendCreated(w, e.ID)
+}
+
+func (router *APITokenRouter) deleteServiceAccount(w http.ResponseWriter, r *http.Request) {
+	e, ok := router.getServiceAccount(w, r)
+	if !ok {
+		return
+	}
+	if err := router.repos.User.Delete(e); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	userRouter := &UserRouter{repos: router.repos}
+	recordAuditEvent(router.repos, r, e.OrganizationID, AuditActionUserDeleted, AuditTargetUser, e.ID, userRouter.copyToRestModel(e, true), nil)
+	SendUpdated(w)
+}
+
+func (router *APITokenRouter) getAllOfServiceAccount(w http.ResponseWriter, r *http.Request) {
+	e, ok := router.getServiceAccount(w, r)
+	if !ok {
+		return
+	}
+	router.sendTokens(w, e)
+}
+
+func (router *APITokenRouter) createForServiceAccount(w http.ResponseWriter, r *http.Request) {
+	e, ok := router.getServiceAccount(w, r)
+	if !ok {
+		return
+	}
+	router.createToken(w, r, e)
+}
+
+func (router *APITokenRouter) getServiceAccount(w http.ResponseWriter, r *http.Request) (*User, bool) {
+	vars := mux.Vars(r)
+	e, err := router.repos.User.GetOne(vars["id"])
+	if err != nil || !e.ServiceAccount {
+		SendNotFound(w)
+		return nil, false
+	}
+	if !CanAdminOrg(GetRequestUser(router.repos, r), e.OrganizationID) {
+		SendForbidden(w)
+		return nil, false
+	}
+	return e, true
+}
+
+func (router *APITokenRouter) sendTokens(w http.ResponseWriter, user *User) {
+	list, err := router.repos.APIToken.GetAll(user.ID)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	res := []*GetAPITokenResponse{}
+	for _, e := range list {
+		res = append(res, router.copyToRestModel(e))
+	}
+	SendJSON(w, res)
+}
+
+// createToken generates a new token for the user. The token is only returned in this
+// response.
+func (router *APITokenRouter) createToken(w http.ResponseWriter, r *http.Request, user *User) {
+	var m CreateAPITokenRequest
+	if UnmarshalValidateBody(r, &m) != nil || !router.isValidRequest(&m) {
+		SendBadRequest(w)
+		return
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	tokenString := APITokenPrefix + hex.EncodeToString(b)
+	e := &APIToken{
+		OrganizationID: user.OrganizationID,
+		UserID:         user.ID,
+		Name:           m.Name,
+		TokenHash:      router.repos.APIToken.GetTokenHash(tokenString),
+		Scopes:         m.Scopes,
+		Created:        time.Now().UTC(),
+	}
+	if m.Expiry != nil {
+		expiry := m.Expiry.UTC()
+		e.Expiry = &expiry
+	}
+	if err := router.repos.APIToken.Create(e); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	res := router.copyToRestModel(e)
+	recordAuditEvent(router.repos, r, e.OrganizationID, AuditActionAPITokenCreated, AuditTargetAPIToken, e.ID, nil, res)
+	res.Token = tokenString
+	SendJSON(w, res)
+}
+
+func (router *APITokenRouter) isValidRequest(m *CreateAPITokenRequest) bool {
+	if len(m.Scopes) == 0 {
+		return false
+	}
+	for i, scope := range m.Scopes {
+		if !slices.Contains(APITokenScopes, scope) || slices.Contains(m.Scopes[:i], scope) {
+			return false
+		}
+	}
+	if m.Expiry != nil && !m.Expiry.After(time.Now()) {
+		return false
+	}
+	return true
+}
+
+// isAPITokenScopeAllowed returns true if the token's scopes permit the request. The
+// booking scopes grant access to bookings and to the locations and spaces needed to
+// book them, the admin scope grants access to the whole API. In any case, the token's
+// user's role still applies.
+func isAPITokenScopeAllowed(scopes []string, r *http.Request) bool {
+	if slices.Contains(scopes, APITokenScopeAdmin) {
+		return true
+	}
+	path := r.URL.Path
+	isBookingPath := strings.HasPrefix(path, "/booking/")
+	if isBookingPath && slices.Contains(scopes, APITokenScopeWriteBookings) {
+		return true
+	}
+	isReadPath := isBookingPath || strings.HasPrefix(path, "/location/") || strings.HasPrefix(path, "/space-attribute/") || path == "/user/me"
+	isRead := r.Method == "GET" ||
+		strings.HasPrefix(path, "/booking/filter/") ||
+		strings.HasPrefix(path, "/booking/report/") ||
+		strings.HasPrefix(path, "/booking/precheck/") ||
+		strings.HasSuffix(path, "/space/availability")
+	if isReadPath && isRead {
+		return slices.Contains(scopes, APITokenScopeReadBookings) || slices.Contains(scopes, APITokenScopeWriteBookings)
+	}
+	return false
+}
+
+func (router *APITokenRouter) copyToRestModel(e *APIToken) *GetAPITokenResponse {
+	m := &GetAPITokenResponse{}
+	m.ID = e.ID
+	m.UserID = e.UserID
+	m.Name = e.Name
+	m.Scopes = e.Scopes
+	m.Expiry = e.Expiry
+	m.Created = e.Created
+	m.LastUsed = e.LastUsed
+	return m
+}
+
+func (router *APITokenRouter) copyServiceAccountToRestModel(e *User) *GetServiceAccountResponse {
+	m := &GetServiceAccountResponse{}
+	m.ID = e.ID
+	m.Name = e.DisplayName
+	m.Role = int(e.Role)
+	return m
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func createTestAPIToken(t *testing.T, url, userID, payload string) *GetAPITokenResponse {
+	req := newHTTPRequest("POST", url, userID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody *GetAPITokenResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkStringNotEmpty(t, resBody.Token)
+	return resBody
+}
+
+func TestAPITokenCRUD(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	user := createTestUserInOrg(org)
+	otherUser := createTestUserInOrg(org)
+
+	token := createTestAPIToken(t, "/api-token/", user.ID, `{"name": "Script", "scopes": ["read-bookings"]}`)
+
+	req := newHTTPRequest("GET", "/api-token/", user.ID, nil)
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody []*GetAPITokenResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestInt(t, 1, len(resBody))
+	checkTestString(t, token.ID, resBody[0].ID)
+	checkTestString(t, "Script", resBody[0].Name)
+	checkTestString(t, "", resBody[0].Token)
+	checkTestBool(t, true, resBody[0].LastUsed == nil)
+
+	// Read scope allows reading bookings only
+	req = newHTTPRequestWithAccessToken("GET", "/booking/", token.Token, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+
+	req = newHTTPRequestWithAccessToken("GET", "/user/me", token.Token, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resUser *GetUserResponse
+	json.Unmarshal(res.Body.Bytes(), &resUser)
+	checkTestString(t, user.ID, resUser.ID)
+
+	req = newHTTPRequestWithAccessToken("POST", "/booking/", token.Token, bytes.NewBufferString(`{}`))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+
+	req = newHTTPRequestWithAccessToken("GET", "/api-token/", token.Token, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+
+	req = newHTTPRequest("GET", "/api-token/", user.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestBool(t, true, resBody[0].LastUsed != nil)
+
+	// Other users can't revoke the token
+	req = newHTTPRequest("DELETE", "/api-token/"+token.ID, otherUser.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+
+	req = newHTTPRequest("DELETE", "/api-token/"+token.ID, user.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	req = newHTTPRequestWithAccessToken("GET", "/booking/", token.Token, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusUnauthorized, res.Code)
+}
+
+func TestAPITokenWriteBookings(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	user := createTestUserInOrg(org)
+	_, spaceID := createTestWaitlistSpace(t, org, admin)
+
+	token := createTestAPIToken(t, "/api-token/", user.ID, `{"name": "Script", "scopes": ["write-bookings"]}`)
+
+	payload := `{"spaceId": "` + spaceID + `", "enter": "2030-09-01T08:30:00Z", "leave": "2030-09-01T17:00:00Z"}`
+	req := newHTTPRequestWithAccessToken("POST", "/booking/", token.Token, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	bookingID := res.Header().Get("X-Object-Id")
+
+	booking, err := testRepositories.Booking.GetOne(bookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestString(t, user.ID, booking.UserID)
+
+	req = newHTTPRequestWithAccessToken("POST", "/location/", token.Token, bytes.NewBufferString(`{"name": "Location 2"}`))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+}
+
+func TestAPITokenInvalidRequest(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	user := createTestUserInOrg(org)
+
+	payloads := []string{
+		`{"name": "Script", "scopes": []}`,
+		`{"name": "Script", "scopes": ["delete-everything"]}`,
+		`{"name": "Script", "scopes": ["admin", "admin"]}`,
+		`{"name": "Script", "scopes": ["admin"], "expiry": "2020-01-01T00:00:00Z"}`,
+		`{"scopes": ["admin"]}`,
+	}
+	for _, payload := range payloads {
+		req := newHTTPRequest("POST", "/api-token/", user.ID, bytes.NewBufferString(payload))
+		res := executeTestRequest(req)
+		checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+	}
+}
+
+func TestAPITokenExpired(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	user := createTestUserInOrg(org)
+
+	expiry := time.Now().UTC().Add(time.Hour).Format(time.RFC3339)
+	token := createTestAPIToken(t, "/api-token/", user.ID, `{"name": "Script", "scopes": ["admin"], "expiry": "`+expiry+`"}`)
+
+	req := newHTTPRequestWithAccessToken("GET", "/user/me", token.Token, nil)
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+
+	setTestAPITokenExpiry(token.ID, time.Now().UTC().Add(-time.Minute))
+
+	req = newHTTPRequestWithAccessToken("GET", "/user/me", token.Token, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusUnauthorized, res.Code)
+}
+
+func TestAPITokenServiceAccount(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	user := createTestUserInOrg(org)
+
+	payload := `{"name": "HR Sync", "role": 10}`
+	req := newHTTPRequest("POST", "/api-token/service-account/", user.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+
+	req = newHTTPRequest("POST", "/api-token/service-account/", admin.ID, bytes.NewBufferString(`{"name": "HR Sync", "role": 90}`))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+
+	req = newHTTPRequest("POST", "/api-token/service-account/", admin.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	serviceAccountID := res.Header().Get("X-Object-Id")
+
+	req = newHTTPRequest("GET", "/api-token/service-account/", admin.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody []*GetServiceAccountResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestInt(t, 1, len(resBody))
+	checkTestString(t, serviceAccountID, resBody[0].ID)
+	checkTestString(t, "HR Sync", resBody[0].Name)
+	checkTestInt(t, int(UserRoleSpaceAdmin), resBody[0].Role)
+
+	// Regular users are no service accounts
+	req = newHTTPRequest("GET", "/api-token/service-account/"+user.ID+"/token/", admin.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNotFound, res.Code)
+
+	token := createTestAPIToken(t, "/api-token/service-account/"+serviceAccountID+"/token/", admin.ID, `{"name": "Sync", "scopes": ["admin"]}`)
+	checkTestString(t, serviceAccountID, token.UserID)
+
+	req = newHTTPRequestWithAccessToken("GET", "/user/me", token.Token, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resUser *GetUserResponse
+	json.Unmarshal(res.Body.Bytes(), &resUser)
+	checkTestString(t, serviceAccountID, resUser.ID)
+	checkTestBool(t, true, resUser.ServiceAccount)
+	checkTestBool(t, true, resUser.SpaceAdmin)
+	checkTestBool(t, false, resUser.OrgAdmin)
+
+	// Service accounts can't get a password to log in with
+	req = newHTTPRequestWithAccessToken("PUT", "/user/me/password", token.Token, bytes.NewBufferString(`{"password": "12345678"}`))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+
+	// The user's role still applies
+	req = newHTTPRequestWithAccessToken("POST", "/audit/filter/", token.Token, bytes.NewBufferString(`{}`))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+
+	req = newHTTPRequest("DELETE", "/api-token/service-account/"+serviceAccountID, admin.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	req = newHTTPRequestWithAccessToken("GET", "/user/me", token.Token, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusUnauthorized, res.Code)
+}
+
+func setTestAPITokenExpiry(id string, expiry time.Time) {
+	if !*testPostgres {
+		memoryLock.Lock()
+		defer memoryLock.Unlock()
+		for _, e := range memoryData.apiTokens {
+			if e.ID == id {
+				e.Expiry = memoryNullTimestamp(&expiry)
+			}
+		}
+		return
+	}
+	GetDatabase().DB().Exec("UPDATE api_tokens SET expiry = $1 WHERE id = $2", expiry, id)
+}
@@ -29,34 +29,46 @@ func GetApp() *App {
 type App struct {
 	Router        *mux.Router
 	CleanupTicker *time.Ticker
+	Repositories  *Repositories
 }
 
 func (a *App) InitializeDatabases() {
-	RunDBSchemaUpdates()
-	InitDefaultOrgSettings()
-	InitDefaultUserPreferences()
+	RunDBSchemaUpdates(a.Repositories)
+	InitDefaultOrgSettings(a.Repositories)
+	InitDefaultUserPreferences(a.Repositories)
 }
 
 func (a *App) InitializeRouter() {
 	config := GetConfig()
 	a.Router = mux.NewRouter()
 	routers := make(map[string]Route)
-	routers["/location/{locationId}/space/"] = &SpaceRouter{}
-	routers["/location/"] = &LocationRouter{}
-	routers["/booking/"] = &BookingRouter{}
-	routers["/buddy/"] = &BuddyRouter{}
-	routers["/organization/"] = &OrganizationRouter{}
-	routers["/auth-provider/"] = &AuthProviderRouter{}
-	routers["/auth/"] = &AuthRouter{}
-	routers["/user/"] = &UserRouter{}
-	routers["/preference/"] = &UserPreferencesRouter{}
-	routers["/stats/"] = &StatsRouter{}
-	routers["/search/"] = &SearchRouter{}
-	routers["/setting/"] = &SettingsRouter{}
-	routers["/confluence/"] = &ConfluenceRouter{}
-	routers["/uc/"] = &CheckUpdateRouter{}
+	routers["/location/{locationId}/space/"] = &SpaceRouter{repos: a.Repositories}
+	routers["/location/"] = &LocationRouter{repos: a.Repositories}
+	routers["/space-attribute/"] = &SpaceAttributeRouter{repos: a.Repositories}
+	routers["/user-group/"] = &UserGroupRouter{repos: a.Repositories}
+	routers["/booking/series/"] = &BookingSeriesRouter{repos: a.Repositories}
+	routers["/booking/waitlist/"] = &WaitlistRouter{repos: a.Repositories}
+	routers["/booking/approval/"] = &BookingApprovalRouter{repos: a.Repositories}
+	routers["/booking/ical/"] = &ICalRouter{repos: a.Repositories}
+	routers["/booking/"] = &BookingRouter{repos: a.Repositories}
+	routers["/webhook/"] = &WebhookRouter{repos: a.Repositories}
+	routers["/scim/v2/"] = &SCIMRouter{repos: a.Repositories}
+	routers["/buddy/"] = &BuddyRouter{repos: a.Repositories}
+	routers["/organization/"] = &OrganizationRouter{repos: a.Repositories}
+	routers["/auth-provider/"] = &AuthProviderRouter{repos: a.Repositories}
+	routers["/auth/"] = &AuthRouter{repos: a.Repositories}
+	routers["/user/import/"] = &UserImportRouter{repos: a.Repositories}
+	routers["/user/"] = &UserRouter{repos: a.Repositories}
+	routers["/preference/"] = &UserPreferencesRouter{repos: a.Repositories}
+	routers["/stats/"] = &StatsRouter{repos: a.Repositories}
+	routers["/audit/"] = &AuditLogRouter{repos: a.Repositories}
+	routers["/api-token/"] = &APITokenRouter{repos: a.Repositories}
+	routers["/search/"] = &SearchRouter{repos: a.Repositories}
+	routers["/setting/"] = &SettingsRouter{repos: a.Repositories}
+	routers["/confluence/"] = &ConfluenceRouter{repos: a.Repositories}
+	routers["/uc/"] = &CheckUpdateRouter{repos: a.Repositories}
 	if config.OrgSignupEnabled {
-		routers["/signup/"] = &SignupRouter{}
+		routers["/signup/"] = &SignupRouter{repos: a.Repositories}
 	}
 	for route, router := range routers {
 		subRouter := a.Router.PathPrefix(route).Subrouter()
@@ -69,7 +81,7 @@ func (a *App) InitializeRouter() {
 	a.Router.Path("/").Methods("GET").HandlerFunc(a.RedirectRootPath)
 	a.Router.PathPrefix("/").Methods("OPTIONS").HandlerFunc(CorsHandler)
 	a.Router.Use(CorsMiddleware)
-	a.Router.Use(VerifyAuthMiddleware)
+	a.Router.Use(a.VerifyAuthMiddleware)
 }
 
 func (a *App) RedirectRootPath(w http.ResponseWriter, r *http.Request) {
@@ -78,7 +90,7 @@ func (a *App) RedirectRootPath(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *App) InitializeDefaultOrg() {
-	numOrgs, err := GetOrganizationRepository().GetNumOrgs()
+	numOrgs, err := a.Repositories.Organization.GetNumOrgs()
 	if err == nil && numOrgs == 0 {
 		log.Println("Creating first organization...")
 		config := GetConfig()
@@ -87,46 +99,73 @@ func (a *App) InitializeDefaultOrg() {
 			Language:   strings.ToLower(config.InitOrgLanguage),
 			SignupDate: time.Now().UTC(),
 		}
-		GetOrganizationRepository().Create(org)
-		GetSettingsRepository().Set(org.ID, SettingSubscriptionMaxUsers.Name, "10000")
-		GetOrganizationRepository().AddDomain(org, config.InitOrgDomain, true)
+		a.Repositories.Organization.Create(org)
+		a.Repositories.Settings.Set(org.ID, SettingSubscriptionMaxUsers.Name, "10000")
+		a.Repositories.Organization.AddDomain(org, config.InitOrgDomain, true)
 		user := &User{
 			OrganizationID: org.ID,
 			Email:          config.InitOrgUser + "@" + config.InitOrgDomain,
-			HashedPassword: NullString(GetUserRepository().GetHashedPassword(config.InitOrgPass)),
+			HashedPassword: NullString(a.Repositories.User.GetHashedPassword(config.InitOrgPass)),
 			Role:           UserRoleSuperAdmin,
 		}
-		GetUserRepository().Create(user)
-		GetOrganizationRepository().createSampleData(org)
+		a.Repositories.User.Create(user)
+		a.Repositories.Organization.createSampleData(org)
 	}
 }
 
 func (a *App) InitializeTimers() {
-	GetUpdateChecker().InitializeVersionUpdateTimer()
+	GetUpdateChecker().InitializeVersionUpdateTimer(a.Repositories)
 	a.CleanupTicker = time.NewTicker(time.Minute * 1)
 	go func() {
 		for {
 			<-a.CleanupTicker.C
 			log.Println("Cleaning up expired database entries...")
-			if err := GetAuthStateRepository().DeleteExpired(); err != nil {
+			if err := a.Repositories.AuthState.DeleteExpired(); err != nil {
 				log.Println(err)
 			}
-			if err := GetSignupRepository().DeleteExpired(); err != nil {
+			if err := a.Repositories.Signup.DeleteExpired(); err != nil {
 				log.Println(err)
 			}
-			if err := GetRefreshTokenRepository().DeleteExpired(); err != nil {
+			if err := a.Repositories.RefreshToken.DeleteExpired(); err != nil {
 				log.Println(err)
 			}
-			if err := GetUserRepository().enableUsersWithExpiredBan(); err != nil {
+			if err := a.Repositories.User.enableUsersWithExpiredBan(); err != nil {
 				log.Println(err)
 			}
-			num, err := GetUserRepository().DeleteObsoleteConfluenceAnonymousUsers()
+			num, err := a.Repositories.User.DeleteObsoleteConfluenceAnonymousUsers()
 			if err != nil {
 				log.Println(err)
 			}
 			if num > 0 {
 				log.Printf("Deleted %d anonymous Confluence users", num)
 			}
+			bookingRouter := &BookingRouter{repos: a.Repositories}
+			if err := bookingRouter.releaseNoShows(); err != nil {
+				log.Println(err)
+			}
+			if err := bookingRouter.sendBookingReminders(); err != nil {
+				log.Println(err)
+			}
+			bookingApprovalRouter := &BookingApprovalRouter{repos: a.Repositories}
+			if err := bookingApprovalRouter.expirePendingBookings(); err != nil {
+				log.Println(err)
+			}
+			waitlistRouter := &WaitlistRouter{repos: a.Repositories}
+			if err := waitlistRouter.processExpiredOffers(); err != nil {
+				log.Println(err)
+			}
+			webhookRouter := &WebhookRouter{repos: a.Repositories}
+			if err := webhookRouter.retryDeliveries(); err != nil {
+				log.Println(err)
+			}
+			bookingSeriesRouter := &BookingSeriesRouter{repos: a.Repositories}
+			if err := bookingSeriesRouter.materializeAll(); err != nil {
+				log.Println(err)
+			}
+			auditLogRouter := &AuditLogRouter{repos: a.Repositories}
+			if err := auditLogRouter.purgeExpiredEntries(); err != nil {
+				log.Println(err)
+			}
 		}
 	}()
 }
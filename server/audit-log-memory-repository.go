@@ -0,0 +1,97 @@
+package main
+
+import "time"
+
+type MemoryAuditLogRepository struct {
+}
+
+func (r *MemoryAuditLogRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryAuditLogRepository) Create(e *AuditLogEntry) error {
+	if err := memoryCheckUUID(e.OrganizationID); err != nil {
+		return err
+	}
+	if e.ActorID != "" {
+		if err := memoryCheckUUID(string(e.ActorID)); err != nil {
+			return err
+		}
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e.ID = memoryNewID()
+	row := *e
+	row.Timestamp = memoryTimestamp(e.Timestamp)
+	memoryData.auditLog = append(memoryData.auditLog, &row)
+	return nil
+}
+
+func (r *MemoryAuditLogRepository) GetAll(organizationID string, filter *AuditLogFilter, maxResults, offset int) ([]*AuditLogEntry, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	result := memoryCopy(memoryFilter(memoryData.auditLog, func(e *AuditLogEntry) bool {
+		return r.matchesFilter(e, organizationID, filter)
+	}))
+	memorySort(result, func(a, b *AuditLogEntry) bool {
+		if !a.Timestamp.Equal(b.Timestamp) {
+			return a.Timestamp.After(b.Timestamp)
+		}
+		return a.ID < b.ID
+	})
+	return memoryPage(result, maxResults, offset), nil
+}
+
+func (r *MemoryAuditLogRepository) GetCount(organizationID string, filter *AuditLogFilter) (int, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryCount(memoryData.auditLog, func(e *AuditLogEntry) bool {
+		return r.matchesFilter(e, organizationID, filter)
+	}), nil
+}
+
+// matchesFilter is the equivalent of PostgresAuditLogRepository.getFilterCondition.
+func (r *MemoryAuditLogRepository) matchesFilter(e *AuditLogEntry, organizationID string, filter *AuditLogFilter) bool {
+	if e.OrganizationID != organizationID {
+		return false
+	}
+	if !filter.Start.IsZero() && e.Timestamp.Before(memoryTimestamp(filter.Start.UTC())) {
+		return false
+	}
+	if !filter.End.IsZero() && e.Timestamp.After(memoryTimestamp(filter.End.UTC())) {
+		return false
+	}
+	if filter.ActorID != "" && string(e.ActorID) != filter.ActorID {
+		return false
+	}
+	if filter.Action != "" && e.Action != filter.Action {
+		return false
+	}
+	if filter.TargetType != "" && e.TargetType != filter.TargetType {
+		return false
+	}
+	if filter.TargetID != "" && e.TargetID != filter.TargetID {
+		return false
+	}
+	return true
+}
+
+func (r *MemoryAuditLogRepository) DeleteOlderThan(organizationID string, before time.Time) error {
+	before = memoryTimestamp(before)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.auditLog = memoryDelete(memoryData.auditLog, func(row *AuditLogEntry) bool {
+		return row.OrganizationID == organizationID && row.Timestamp.Before(before)
+	})
+	return nil
+}
+
+func (r *MemoryAuditLogRepository) DeleteAll(organizationID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.auditLog = memoryDelete(memoryData.auditLog, func(row *AuditLogEntry) bool {
+		return row.OrganizationID == organizationID
+	})
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+type AuditLogRepository interface {
+	Repository
+	Create(e *AuditLogEntry) error
+	GetAll(organizationID string, filter *AuditLogFilter, maxResults, offset int) ([]*AuditLogEntry, error)
+	GetCount(organizationID string, filter *AuditLogFilter) (int, error)
+	DeleteOlderThan(organizationID string, before time.Time) error
+	DeleteAll(organizationID string) error
+}
+
+type PostgresAuditLogRepository struct {
+}
+
+// AuditLogEntry records an action of ActorID on a target entity. ActorEmail is kept
+// so entries stay readable after the actor has been deleted. Changes holds a JSON
+// object mapping each changed field to its value before and after the action.
+// Entries are never updated; they are only removed after the organization's
+// retention period.
+type AuditLogEntry struct {
+	ID             string
+	OrganizationID string
+	Timestamp      time.Time
+	ActorID        NullString
+	ActorEmail     string
+	Action         string
+	TargetType     string
+	TargetID       string
+	Changes        string
+	IP             string
+}
+
+// AuditLogFilter restricts the entries returned. Empty fields don't restrict the result.
+type AuditLogFilter struct {
+	Start      time.Time
+	End        time.Time
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+}
+
+func (r *PostgresAuditLogRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		{
+			Version:     34,
+			Description: "Add audit log",
+			Up: []string{
+				"CREATE TABLE IF NOT EXISTS audit_log (" +
+					"id uuid DEFAULT uuid_generate_v4(), " +
+					"organization_id uuid NOT NULL, " +
+					"timestamp TIMESTAMP NOT NULL, " +
+					"actor_id uuid NULL, " +
+					"actor_email VARCHAR NOT NULL, " +
+					"action VARCHAR NOT NULL, " +
+					"target_type VARCHAR NOT NULL, " +
+					"target_id VARCHAR NOT NULL, " +
+					"changes TEXT NOT NULL, " +
+					"ip VARCHAR NOT NULL, " +
+					"PRIMARY KEY (id))",
+				"CREATE INDEX IF NOT EXISTS idx_audit_log_organization_id_timestamp ON audit_log(organization_id, timestamp)",
+			},
+			Down: []string{"DROP TABLE IF EXISTS audit_log"},
+		},
+	}
+}
+
+func (r *PostgresAuditLogRepository) Create(e *AuditLogEntry) error {
+	var id string
+	err := GetDatabase().DB().QueryRow("INSERT INTO audit_log "+
+		"(organization_id, timestamp, actor_id, actor_email, action, target_type, target_id, changes, ip) "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) "+
+		"RETURNING id",
+		e.OrganizationID, e.Timestamp, CheckNullString(e.ActorID), e.ActorEmail, e.Action, e.TargetType, e.TargetID, e.Changes, e.IP).Scan(&id)
+	if err != nil {
+		return err
+	}
+	e.ID = id
+	return nil
+}
+
+// GetAll returns the organization's entries matching the filter, newest first.
+func (r *PostgresAuditLogRepository) GetAll(organizationID string, filter *AuditLogFilter, maxResults, offset int) ([]*AuditLogEntry, error) {
+	var result []*AuditLogEntry
+	condition, args := r.getFilterCondition(organizationID, filter)
+	args = append(args, maxResults, offset)
+	rows, err := GetDatabase().DB().Query("SELECT id, organization_id, timestamp, actor_id, actor_email, action, target_type, target_id, changes, ip "+
+		"FROM audit_log "+
+		"WHERE "+condition+" "+
+		"ORDER BY timestamp DESC, id "+
+		"LIMIT $"+strconv.Itoa(len(args)-1)+" OFFSET $"+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &AuditLogEntry{}
+		err = rows.Scan(&e.ID, &e.OrganizationID, &e.Timestamp, &e.ActorID, &e.ActorEmail, &e.Action, &e.TargetType, &e.TargetID, &e.Changes, &e.IP)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (r *PostgresAuditLogRepository) GetCount(organizationID string, filter *AuditLogFilter) (int, error) {
+	var res int
+	condition, args := r.getFilterCondition(organizationID, filter)
+	err := GetDatabase().DB().QueryRow("SELECT COUNT(id) "+
+		"FROM audit_log "+
+		"WHERE "+condition, args...).Scan(&res)
+	return res, err
+}
+
+func (r *PostgresAuditLogRepository) getFilterCondition(organizationID string, filter *AuditLogFilter) (string, []interface{}) {
+	conditions := []string{"organization_id = $1"}
+	args := []interface{}{organizationID}
+	var add = func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, condition+" $"+strconv.Itoa(len(args)))
+	}
+	if !filter.Start.IsZero() {
+		add("timestamp >=", filter.Start.UTC())
+	}
+	if !filter.End.IsZero() {
+		add("timestamp <=", filter.End.UTC())
+	}
+	if filter.ActorID != "" {
+		add("actor_id::text =", filter.ActorID)
+	}
+	if filter.Action != "" {
+		add("action =", filter.Action)
+	}
+	if filter.TargetType != "" {
+		add("target_type =", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		add("target_id =", filter.TargetID)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// DeleteOlderThan removes the organization's entries which are older than the
+// retention period.
+func (r *PostgresAuditLogRepository) DeleteOlderThan(organizationID string, before time.Time) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM audit_log WHERE organization_id = $1 AND timestamp < $2", organizationID, before)
+	return err
+}
+
+func (r *PostgresAuditLogRepository) DeleteAll(organizationID string) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM audit_log WHERE organization_id = $1", organizationID)
+	return err
+}
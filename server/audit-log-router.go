@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type AuditLogRouter struct {
+	repos *Repositories
+}
+
+type GetAuditLogFilterRequest struct {
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	ActorID    string    `json:"actorId"`
+	Action     string    `json:"action"`
+	TargetType string    `json:"targetType"`
+	TargetID   string    `json:"targetId"`
+	MaxResults int       `json:"maxResults"`
+	Offset     int       `json:"offset"`
+}
+
+type GetAuditLogEntryResponse struct {
+	ID         string                  `json:"id"`
+	Timestamp  time.Time               `json:"timestamp"`
+	ActorID    string                  `json:"actorId"`
+	ActorEmail string                  `json:"actorEmail"`
+	Action     string                  `json:"action"`
+	TargetType string                  `json:"targetType"`
+	TargetID   string                  `json:"targetId"`
+	Changes    map[string]*AuditChange `json:"changes"`
+	IP         string                  `json:"ip"`
+}
+
+type GetAuditLogResponse struct {
+	Total   int                         `json:"total"`
+	Entries []*GetAuditLogEntryResponse `json:"entries"`
+}
+
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+const (
+	AuditActionBookingCreated      string = "booking.created"
+	AuditActionBookingUpdated      string = "booking.updated"
+	AuditActionBookingDeleted      string = "booking.deleted"
+	AuditActionBookingApproved     string = "booking.approved"
+	AuditActionBookingRejected     string = "booking.rejected"
+	AuditActionSettingUpdated      string = "setting.updated"
+	AuditActionUserCreated         string = "user.created"
+	AuditActionUserUpdated         string = "user.updated"
+	AuditActionUserDeleted         string = "user.deleted"
+	AuditActionUserMerged          string = "user.merged"
+	AuditActionAuthProviderCreated string = "auth_provider.created"
+	AuditActionAuthProviderUpdated string = "auth_provider.updated"
+	AuditActionAuthProviderDeleted string = "auth_provider.deleted"
+	AuditActionAPITokenCreated     string = "api_token.created"
+	AuditActionAPITokenDeleted     string = "api_token.deleted"
+)
+
+const (
+	AuditTargetBooking      string = "booking"
+	AuditTargetSetting      string = "setting"
+	AuditTargetUser         string = "user"
+	AuditTargetAuthProvider string = "auth_provider"
+	AuditTargetAPIToken     string = "api_token"
+)
+
+const AuditLogDefaultPageSize int = 100
+const AuditLogMaxPageSize int = 1000
+
+// AuditRedactedValue replaces values of secret fields, so the log shows they changed
+// without revealing them.
+const AuditRedactedValue string = "********"
+
+func (router *AuditLogRouter) setupRoutes(s *mux.Router) {
+	s.HandleFunc("/filter/", router.getFiltered).Methods("POST")
+	s.HandleFunc("/export/", router.export).Methods("POST")
+}
+
+func (router *AuditLogRouter) getFiltered(w http.ResponseWriter, r *http.Request) {
+	user := GetRequestUser(router.repos, r)
+	if !CanAdminOrg(user, user.OrganizationID) {
+		SendForbidden(w)
+		return
+	}
+	var m GetAuditLogFilterRequest
+	if UnmarshalValidateBody(r, &m) != nil || m.MaxResults < 0 || m.MaxResults > AuditLogMaxPageSize || m.Offset < 0 {
+		SendBadRequest(w)
+		return
+	}
+	if m.MaxResults == 0 {
+		m.MaxResults = AuditLogDefaultPageSize
+	}
+	filter := router.copyFromRestModel(&m)
+	total, err := router.repos.AuditLog.GetCount(user.OrganizationID, filter)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	list, err := router.repos.AuditLog.GetAll(user.OrganizationID, filter, m.MaxResults, m.Offset)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	res := &GetAuditLogResponse{
+		Total:   total,
+		Entries: []*GetAuditLogEntryResponse{},
+	}
+	for _, e := range list {
+		res.Entries = append(res.Entries, router.copyToRestModel(e))
+	}
+	SendJSON(w, res)
+}
+
+// export returns all entries matching the filter as CSV, ignoring the pagination
+// parameters. The changes are included as JSON.
+func (router *AuditLogRouter) export(w http.ResponseWriter, r *http.Request) {
+	user := GetRequestUser(router.repos, r)
+	if !CanAdminOrg(user, user.OrganizationID) {
+		SendForbidden(w)
+		return
+	}
+	var m GetAuditLogFilterRequest
+	if UnmarshalValidateBody(r, &m) != nil {
+		SendBadRequest(w)
+		return
+	}
+	filter := router.copyFromRestModel(&m)
+	var list []*AuditLogEntry
+	for offset := 0; ; offset += AuditLogMaxPageSize {
+		page, err := router.repos.AuditLog.GetAll(user.OrganizationID, filter, AuditLogMaxPageSize, offset)
+		if err != nil {
+			log.Println(err)
+			SendInternalServerError(w)
+			return
+		}
+		list = append(list, page...)
+		if len(page) < AuditLogMaxPageSize {
+			break
+		}
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"audit-log.csv\"")
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"id", "timestamp", "actorId", "actorEmail", "action", "targetType", "targetId", "changes", "ip"})
+	for _, e := range list {
+		writer.Write([]string{
+			e.ID,
+			e.Timestamp.UTC().Format(time.RFC3339),
+			string(e.ActorID),
+			e.ActorEmail,
+			e.Action,
+			e.TargetType,
+			e.TargetID,
+			e.Changes,
+			e.IP,
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Println(err)
+	}
+}
+
+// purgeExpiredEntries removes entries older than the retention period of each
+// organization. A retention period of 0 days keeps the entries forever.
+func (router *AuditLogRouter) purgeExpiredEntries() error {
+	list, err := router.repos.Organization.GetAllIDs()
+	if err != nil {
+		return err
+	}
+	for _, organizationID := range list {
+		days, err := router.repos.Settings.GetInt(organizationID, SettingAuditLogRetentionDays.Name)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if days <= 0 {
+			continue
+		}
+		before := time.Now().UTC().AddDate(0, 0, -days)
+		if err := router.repos.AuditLog.DeleteOlderThan(organizationID, before); err != nil {
+			log.Println(err)
+		}
+	}
+	return nil
+}
+
+// recordAuditEvent appends an action of the request's user to the audit log. Before and
+// after are the target's REST models, nil if the target didn't exist before or after
+// the action. Updates which didn't change anything are not recorded. Failures are
+// logged only, as the log must not affect the action itself.
+func recordAuditEvent(repos *Repositories, r *http.Request, organizationID, action, targetType, targetID string, before, after interface{}) {
+	changes, err := getAuditChanges(before, after)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if len(changes) == 0 {
+		return
+	}
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	e := &AuditLogEntry{
+		OrganizationID: organizationID,
+		Timestamp:      time.Now().UTC(),
+		Action:         action,
+		TargetType:     targetType,
+		TargetID:       targetID,
+		Changes:        string(changesJSON),
+		IP:             GetRequestIP(r),
+	}
+	if actor := GetRequestUser(repos, r); actor != nil {
+		e.ActorID = NullString(actor.ID)
+		e.ActorEmail = actor.Email
+	}
+	if err := repos.AuditLog.Create(e); err != nil {
+		log.Println(err)
+	}
+}
+
+// getAuditChanges returns the top-level fields which differ between before and after.
+// Values of secret fields are redacted.
+func getAuditChanges(before, after interface{}) (map[string]*AuditChange, error) {
+	beforeFields, err := getAuditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := getAuditFields(after)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]*AuditChange)
+	for key, value := range afterFields {
+		if valueBefore, ok := beforeFields[key]; !ok || !reflect.DeepEqual(valueBefore, value) {
+			res[key] = &AuditChange{Before: valueBefore, After: value}
+		}
+	}
+	for key, value := range beforeFields {
+		if _, ok := afterFields[key]; !ok {
+			res[key] = &AuditChange{Before: value}
+		}
+	}
+	for key, change := range res {
+		name := strings.ToLower(key)
+		if strings.Contains(name, "secret") || strings.Contains(name, "password") {
+			change.Before = getAuditRedactedValue(change.Before)
+			change.After = getAuditRedactedValue(change.After)
+		}
+	}
+	return res, nil
+}
+
+func getAuditFields(model interface{}) (map[string]interface{}, error) {
+	res := make(map[string]interface{})
+	if model == nil {
+		return res, nil
+	}
+	b, err := json.Marshal(model)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func getAuditRedactedValue(value interface{}) interface{} {
+	if s, ok := value.(string); ok && s != "" {
+		return AuditRedactedValue
+	}
+	return value
+}
+
+func (router *AuditLogRouter) copyFromRestModel(m *GetAuditLogFilterRequest) *AuditLogFilter {
+	return &AuditLogFilter{
+		Start:      m.Start,
+		End:        m.End,
+		ActorID:    m.ActorID,
+		Action:     m.Action,
+		TargetType: m.TargetType,
+		TargetID:   m.TargetID,
+	}
+}
+
+func (router *AuditLogRouter) copyToRestModel(e *AuditLogEntry) *GetAuditLogEntryResponse {
+	m := &GetAuditLogEntryResponse{}
+	m.ID = e.ID
+	m.Timestamp = e.Timestamp
+	m.ActorID = string(e.ActorID)
+	m.ActorEmail = e.ActorEmail
+	m.Action = e.Action
+	m.TargetType = e.TargetType
+	m.TargetID = e.TargetID
+	m.IP = e.IP
+	if err := json.Unmarshal([]byte(e.Changes), &m.Changes); err != nil {
+		log.Println(err)
+	}
+	return m
+}
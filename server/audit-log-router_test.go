@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getTestAuditLog(t *testing.T, userID, payload string) *GetAuditLogResponse {
+	req := newHTTPRequest("POST", "/audit/filter/", userID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody *GetAuditLogResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	return resBody
+}
+
+func TestAuditLogForbidden(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	user := createTestUserInOrg(org)
+	spaceAdmin := createTestUserInOrgWithName(org, "admin@test.com", UserRoleSpaceAdmin)
+
+	for _, userID := range []string{user.ID, spaceAdmin.ID} {
+		req := newHTTPRequest("POST", "/audit/filter/", userID, bytes.NewBufferString(`{}`))
+		res := executeTestRequest(req)
+		checkTestResponseCode(t, http.StatusForbidden, res.Code)
+
+		req = newHTTPRequest("POST", "/audit/export/", userID, bytes.NewBufferString(`{}`))
+		res = executeTestRequest(req)
+		checkTestResponseCode(t, http.StatusForbidden, res.Code)
+	}
+}
+
+func TestAuditLogSettings(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	testRepositories.Settings.Set(org.ID, SettingAllowAnyUser.Name, "0")
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingsPerUser.Name, "10")
+
+	// Only changed settings are recorded
+	payload := `[{"name": "allow_any_user", "value": "1"}, {"name": "max_bookings_per_user", "value": "10"}]`
+	req := newHTTPRequest("PUT", "/setting/", admin.ID, bytes.NewBufferString(payload))
+	req.RemoteAddr = "203.0.113.7:4321"
+	// Ignored, as the request doesn't come from a trusted proxy
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	resBody := getTestAuditLog(t, admin.ID, `{"targetType": "setting"}`)
+	checkTestInt(t, 1, resBody.Total)
+	checkTestInt(t, 1, len(resBody.Entries))
+	e := resBody.Entries[0]
+	checkTestString(t, AuditActionSettingUpdated, e.Action)
+	checkTestString(t, SettingAllowAnyUser.Name, e.TargetID)
+	checkTestString(t, admin.ID, e.ActorID)
+	checkTestString(t, admin.Email, e.ActorEmail)
+	checkTestString(t, "203.0.113.7", e.IP)
+	checkTestInt(t, 1, len(e.Changes))
+	checkTestString(t, "0", e.Changes[SettingAllowAnyUser.Name].Before.(string))
+	checkTestString(t, "1", e.Changes[SettingAllowAnyUser.Name].After.(string))
+}
+
+func TestAuditLogAuthProviderSecretRedacted(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+
+	payload := `{"name": "Test", "providerType": 1, "clientId": "test1", "clientSecret": "test2", "authUrl": "http://test.com/1", "tokenUrl": "http://test.com/2", "authStyle": 0, "scopes": "http://test.com/3", "userInfoUrl": "http://test.com/userinfo", "userInfoEmailField": "email"}`
+	req := newHTTPRequest("POST", "/auth-provider/", admin.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	id := res.Header().Get("X-Object-Id")
+
+	payload = strings.Replace(payload, `"clientSecret": "test2"`, `"clientSecret": "test3"`, 1)
+	req = newHTTPRequest("PUT", "/auth-provider/"+id, admin.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	resBody := getTestAuditLog(t, admin.ID, `{"targetId": "`+id+`"}`)
+	checkTestInt(t, 2, resBody.Total)
+	checkTestString(t, AuditActionAuthProviderUpdated, resBody.Entries[0].Action)
+	checkTestInt(t, 1, len(resBody.Entries[0].Changes))
+	checkTestString(t, AuditRedactedValue, resBody.Entries[0].Changes["clientSecret"].Before.(string))
+	checkTestString(t, AuditRedactedValue, resBody.Entries[0].Changes["clientSecret"].After.(string))
+	checkTestString(t, AuditActionAuthProviderCreated, resBody.Entries[1].Action)
+	checkTestString(t, "test1", resBody.Entries[1].Changes["clientId"].After.(string))
+	checkTestString(t, AuditRedactedValue, resBody.Entries[1].Changes["clientSecret"].After.(string))
+}
+
+func TestAuditLogBookingPagination(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	user := createTestUserInOrg(org)
+	_, spaceID := createTestWaitlistSpace(t, org, admin)
+
+	payload := `{"spaceId": "` + spaceID + `", "enter": "2030-09-01T08:30:00Z", "leave": "2030-09-01T17:00:00Z"}`
+	req := newHTTPRequest("POST", "/booking/", user.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	bookingID := res.Header().Get("X-Object-Id")
+
+	req = newHTTPRequest("DELETE", "/booking/"+bookingID, admin.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	resBody := getTestAuditLog(t, admin.ID, `{"targetType": "booking", "targetId": "`+bookingID+`", "maxResults": 1}`)
+	checkTestInt(t, 2, resBody.Total)
+	checkTestInt(t, 1, len(resBody.Entries))
+	checkTestString(t, AuditActionBookingDeleted, resBody.Entries[0].Action)
+	checkTestString(t, admin.ID, resBody.Entries[0].ActorID)
+	checkTestString(t, spaceID, resBody.Entries[0].Changes["spaceId"].Before.(string))
+	checkTestBool(t, true, resBody.Entries[0].Changes["spaceId"].After == nil)
+
+	resBody = getTestAuditLog(t, admin.ID, `{"targetType": "booking", "targetId": "`+bookingID+`", "maxResults": 1, "offset": 1}`)
+	checkTestInt(t, 2, resBody.Total)
+	checkTestInt(t, 1, len(resBody.Entries))
+	checkTestString(t, AuditActionBookingCreated, resBody.Entries[0].Action)
+	checkTestString(t, user.ID, resBody.Entries[0].ActorID)
+
+	resBody = getTestAuditLog(t, admin.ID, `{"actorId": "`+user.ID+`"}`)
+	checkTestInt(t, 1, resBody.Total)
+
+	req = newHTTPRequest("POST", "/audit/filter/", admin.ID, bytes.NewBufferString(`{"maxResults": 1001}`))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+}
+
+func TestAuditLogExport(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	testRepositories.Settings.Set(org.ID, SettingAllowAnyUser.Name, "0")
+
+	payload := `{"value": "1"}`
+	req := newHTTPRequest("PUT", "/setting/"+SettingAllowAnyUser.Name, admin.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	req = newHTTPRequest("POST", "/audit/export/", admin.ID, bytes.NewBufferString(`{"action": "setting.updated"}`))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	checkTestBool(t, true, strings.HasPrefix(res.Header().Get("Content-Type"), "text/csv"))
+	records, err := csv.NewReader(res.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestInt(t, 2, len(records))
+	checkTestString(t, "action", records[0][4])
+	checkTestString(t, admin.Email, records[1][3])
+	checkTestString(t, AuditActionSettingUpdated, records[1][4])
+	checkTestString(t, SettingAllowAnyUser.Name, records[1][6])
+}
+
+func TestAuditLogRetention(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	testRepositories.Settings.Set(org.ID, SettingAuditLogRetentionDays.Name, "30")
+	for _, days := range []int{40, 10} {
+		e := &AuditLogEntry{
+			OrganizationID: org.ID,
+			Timestamp:      time.Now().UTC().AddDate(0, 0, -days),
+			ActorEmail:     "admin@test.com",
+			Action:         AuditActionSettingUpdated,
+			TargetType:     AuditTargetSetting,
+			TargetID:       SettingAllowAnyUser.Name,
+			Changes:        "{}",
+		}
+		if err := testRepositories.AuditLog.Create(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	router := &AuditLogRouter{repos: testRepositories}
+	if err := router.purgeExpiredEntries(); err != nil {
+		t.Fatal(err)
+	}
+	count, _ := testRepositories.AuditLog.GetCount(org.ID, &AuditLogFilter{})
+	checkTestInt(t, 1, count)
+}
+
+func TestAuditLogRequestIP(t *testing.T) {
+	config := GetConfig()
+	trustedProxies, trustedProxyNets := config.TrustedProxies, config.trustedProxyNets
+	defer func() {
+		config.TrustedProxies, config.trustedProxyNets = trustedProxies, trustedProxyNets
+	}()
+	config.TrustedProxies, config.trustedProxyNets = config.parseTrustedProxies("10.0.0.0/8, 192.168.1.1")
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "203.0.113.5:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	checkTestString(t, "203.0.113.5", GetRequestIP(req))
+
+	req.RemoteAddr = "192.168.1.1:1234"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 198.51.100.1, 10.1.2.3")
+	checkTestString(t, "198.51.100.1", GetRequestIP(req))
+
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Del("X-Forwarded-For")
+	checkTestString(t, "10.0.0.1", GetRequestIP(req))
+}
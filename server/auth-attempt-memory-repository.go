@@ -0,0 +1,37 @@
+package main
+
+import "time"
+
+type MemoryAuthAttemptRepository struct {
+	authAttemptRepositoryBase
+}
+
+func (r *MemoryAuthAttemptRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryAuthAttemptRepository) Create(e *AuthAttempt) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e.ID = memoryNewID()
+	row := *e
+	row.Timestamp = memoryTimestamp(e.Timestamp)
+	memoryData.authAttempts = append(memoryData.authAttempts, &row)
+	return nil
+}
+
+func (r *MemoryAuthAttemptRepository) GetNumFailedLogins(userID string, since time.Time) (int, error) {
+	since = memoryTimestamp(since)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	lastSuccessfulLogin := memoryTimestamp(time.Unix(0, 0))
+	for _, e := range memoryData.authAttempts {
+		if e.UserID == userID && e.Successful && e.Timestamp.After(lastSuccessfulLogin) {
+			lastSuccessfulLogin = e.Timestamp
+		}
+	}
+	return memoryCount(memoryData.authAttempts, func(e *AuthAttempt) bool {
+		return e.UserID == userID && e.Timestamp.After(since) && e.Timestamp.After(lastSuccessfulLogin)
+	}), nil
+}
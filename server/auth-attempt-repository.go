@@ -1,11 +1,16 @@
 package main
 
-import (
-	"sync"
-	"time"
-)
+import "time"
 
-type AuthAttemptRepository struct {
+type AuthAttemptRepository interface {
+	Repository
+	Create(e *AuthAttempt) error
+	RecordLoginAttempt(user *User, success bool) error
+	GetNumFailedLogins(userID string, since time.Time) (int, error)
+}
+
+type PostgresAuthAttemptRepository struct {
+	authAttemptRepositoryBase
 }
 
 type AuthAttempt struct {
@@ -16,39 +21,23 @@ type AuthAttempt struct {
 	Successful bool
 }
 
-var authAttemptRepository *AuthAttemptRepository
-var authAttemptRepositoryOnce sync.Once
-
-func GetAuthAttemptRepository() *AuthAttemptRepository {
-	authAttemptRepositoryOnce.Do(func() {
-		authAttemptRepository = &AuthAttemptRepository{}
-		_, err := GetDatabase().DB().Exec("CREATE TABLE IF NOT EXISTS auth_attempts (" +
-			"id uuid DEFAULT uuid_generate_v4(), " +
-			"user_id uuid NULL, " +
-			"email VARCHAR NOT NULL, " +
-			"timestamp TIMESTAMP NOT NULL, " +
-			"successful BOOLEAN, " +
-			"PRIMARY KEY (id))")
-		if err != nil {
-			panic(err)
-		}
-		_, err = GetDatabase().DB().Exec("CREATE INDEX IF NOT EXISTS idx_auth_attempts_user_id ON auth_attempts(user_id)")
-		if err != nil {
-			panic(err)
-		}
-		_, err = GetDatabase().DB().Exec("CREATE INDEX IF NOT EXISTS idx_auth_attempts_email ON auth_attempts(email)")
-		if err != nil {
-			panic(err)
-		}
-	})
-	return authAttemptRepository
-}
-
-func (r *AuthAttemptRepository) RunSchemaUpgrade(curVersion, targetVersion int) {
-	// No updates yet
+func (r *PostgresAuthAttemptRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		newInitialSchemaMigration(
+			"CREATE TABLE IF NOT EXISTS auth_attempts ("+
+				"id uuid DEFAULT uuid_generate_v4(), "+
+				"user_id uuid NULL, "+
+				"email VARCHAR NOT NULL, "+
+				"timestamp TIMESTAMP NOT NULL, "+
+				"successful BOOLEAN, "+
+				"PRIMARY KEY (id))",
+			"CREATE INDEX IF NOT EXISTS idx_auth_attempts_user_id ON auth_attempts(user_id)",
+			"CREATE INDEX IF NOT EXISTS idx_auth_attempts_email ON auth_attempts(email)",
+		),
+	}
 }
 
-func (r *AuthAttemptRepository) Create(e *AuthAttempt) error {
+func (r *PostgresAuthAttemptRepository) Create(e *AuthAttempt) error {
 	var id string
 	err := GetDatabase().DB().QueryRow("INSERT INTO auth_attempts "+
 		"(user_id, email, timestamp, successful) "+
@@ -62,14 +51,20 @@ func (r *AuthAttemptRepository) Create(e *AuthAttempt) error {
 	return nil
 }
 
-func (r *AuthAttemptRepository) RecordLoginAttempt(user *User, success bool) error {
+// authAttemptRepositoryBase implements the login protection on top of the storage
+// specific methods of AuthAttemptRepository.
+type authAttemptRepositoryBase struct {
+	repos *Repositories
+}
+
+func (r *authAttemptRepositoryBase) RecordLoginAttempt(user *User, success bool) error {
 	e := &AuthAttempt{
 		UserID:     user.ID,
 		Email:      user.Email,
 		Timestamp:  time.Now(),
 		Successful: success,
 	}
-	if err := r.Create(e); err != nil {
+	if err := r.repos.AuthAttempt.Create(e); err != nil {
 		return err
 	}
 	if err := r.checkBanUser(user); err != nil {
@@ -78,26 +73,36 @@ func (r *AuthAttemptRepository) RecordLoginAttempt(user *User, success bool) err
 	return nil
 }
 
-func (r *AuthAttemptRepository) checkBanUser(user *User) error {
-	var lastSuccessfulLogin time.Time
-	if err := GetDatabase().DB().QueryRow("SELECT timestamp FROM auth_attempts WHERE user_id = $1 AND successful = TRUE ORDER BY timestamp DESC LIMIT 1",
-		user.ID).Scan(&lastSuccessfulLogin); err != nil {
-		lastSuccessfulLogin = time.Unix(0, 0)
-	}
-	var numFailedLogins int
+func (r *authAttemptRepositoryBase) checkBanUser(user *User) error {
 	limit := time.Now().Add(time.Second * time.Duration(GetConfig().LoginProtectionSlidingWindowSeconds*-1))
-	if err := GetDatabase().DB().QueryRow("SELECT COUNT(id) FROM auth_attempts "+
-		"WHERE user_id = $1 AND timestamp > $2 AND timestamp > $3",
-		user.ID, limit, lastSuccessfulLogin).Scan(&numFailedLogins); err != nil {
+	numFailedLogins, err := r.repos.AuthAttempt.GetNumFailedLogins(user.ID, limit)
+	if err != nil {
 		return err
 	}
 	if numFailedLogins >= GetConfig().LoginProtectionMaxFails {
 		banExpiry := time.Now().Add(time.Minute * time.Duration(GetConfig().LoginProtectionBanMinutes))
 		user.Disabled = true
 		user.BanExpiry = &banExpiry
-		if err := GetUserRepository().Update(user); err != nil {
+		if err := r.repos.User.Update(user); err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+// GetNumFailedLogins returns the number of login attempts of the user after since which
+// happened after the user's last successful login.
+func (r *PostgresAuthAttemptRepository) GetNumFailedLogins(userID string, since time.Time) (int, error) {
+	var lastSuccessfulLogin time.Time
+	if err := GetDatabase().DB().QueryRow("SELECT timestamp FROM auth_attempts WHERE user_id = $1 AND successful = TRUE ORDER BY timestamp DESC LIMIT 1",
+		userID).Scan(&lastSuccessfulLogin); err != nil {
+		lastSuccessfulLogin = time.Unix(0, 0)
+	}
+	var numFailedLogins int
+	if err := GetDatabase().DB().QueryRow("SELECT COUNT(id) FROM auth_attempts "+
+		"WHERE user_id = $1 AND timestamp > $2 AND timestamp > $3",
+		userID, since, lastSuccessfulLogin).Scan(&numFailedLogins); err != nil {
+		return 0, err
+	}
+	return numFailedLogins, nil
+}
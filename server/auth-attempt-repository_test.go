@@ -10,19 +10,19 @@ func TestAuthAttemptRepositoryBanSimple(t *testing.T) {
 	checkTestBool(t, false, authAttemptRepositoryIsUserDisabled(t, user.ID))
 
 	// Attempt 1
-	if err := GetAuthAttemptRepository().RecordLoginAttempt(user, false); err != nil {
+	if err := testRepositories.AuthAttempt.RecordLoginAttempt(user, false); err != nil {
 		t.Error(err)
 	}
 	checkTestBool(t, false, authAttemptRepositoryIsUserDisabled(t, user.ID))
 
 	// Attempt 2
-	if err := GetAuthAttemptRepository().RecordLoginAttempt(user, false); err != nil {
+	if err := testRepositories.AuthAttempt.RecordLoginAttempt(user, false); err != nil {
 		t.Error(err)
 	}
 	checkTestBool(t, false, authAttemptRepositoryIsUserDisabled(t, user.ID))
 
 	// Attempt 3
-	if err := GetAuthAttemptRepository().RecordLoginAttempt(user, false); err != nil {
+	if err := testRepositories.AuthAttempt.RecordLoginAttempt(user, false); err != nil {
 		t.Error(err)
 	}
 	checkTestBool(t, true, authAttemptRepositoryIsUserDisabled(t, user.ID))
@@ -36,32 +36,32 @@ func TestAuthAttemptRepositoryBanWithSuccess(t *testing.T) {
 	checkTestBool(t, false, authAttemptRepositoryIsUserDisabled(t, user.ID))
 
 	// Attempt 1
-	GetAuthAttemptRepository().RecordLoginAttempt(user, false)
+	testRepositories.AuthAttempt.RecordLoginAttempt(user, false)
 	checkTestBool(t, false, authAttemptRepositoryIsUserDisabled(t, user.ID))
 
 	// Attempt 2
-	GetAuthAttemptRepository().RecordLoginAttempt(user, false)
+	testRepositories.AuthAttempt.RecordLoginAttempt(user, false)
 	checkTestBool(t, false, authAttemptRepositoryIsUserDisabled(t, user.ID))
 
 	// Successful Login
-	GetAuthAttemptRepository().RecordLoginAttempt(user, true)
+	testRepositories.AuthAttempt.RecordLoginAttempt(user, true)
 	checkTestBool(t, false, authAttemptRepositoryIsUserDisabled(t, user.ID))
 
 	// Attempt 1
-	GetAuthAttemptRepository().RecordLoginAttempt(user, false)
+	testRepositories.AuthAttempt.RecordLoginAttempt(user, false)
 	checkTestBool(t, false, authAttemptRepositoryIsUserDisabled(t, user.ID))
 
 	// Attempt 2
-	GetAuthAttemptRepository().RecordLoginAttempt(user, false)
+	testRepositories.AuthAttempt.RecordLoginAttempt(user, false)
 	checkTestBool(t, false, authAttemptRepositoryIsUserDisabled(t, user.ID))
 
 	// Attempt 3
-	GetAuthAttemptRepository().RecordLoginAttempt(user, false)
+	testRepositories.AuthAttempt.RecordLoginAttempt(user, false)
 	checkTestBool(t, true, authAttemptRepositoryIsUserDisabled(t, user.ID))
 }
 
 func authAttemptRepositoryIsUserDisabled(t *testing.T, userID string) bool {
-	user, err := GetUserRepository().GetOne(userID)
+	user, err := testRepositories.User.GetOne(userID)
 	if err != nil {
 		t.Error(err)
 	}
@@ -0,0 +1,82 @@
+package main
+
+type MemoryAuthProviderRepository struct {
+	repos *Repositories
+}
+
+func (r *MemoryAuthProviderRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryAuthProviderRepository) Create(e *AuthProvider) error {
+	if err := memoryCheckUUID(e.OrganizationID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e.ID = memoryNewID()
+	row := *e
+	memoryData.authProviders = append(memoryData.authProviders, &row)
+	return nil
+}
+
+func (r *MemoryAuthProviderRepository) GetOne(id string) (*AuthProvider, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryGet(memoryData.authProviders, func(e *AuthProvider) bool {
+		return e.ID == id
+	})
+}
+
+func (r *MemoryAuthProviderRepository) GetAll(organizationID string) ([]*AuthProvider, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	result := memoryCopy(memoryFilter(memoryData.authProviders, func(e *AuthProvider) bool {
+		return e.OrganizationID == organizationID
+	}))
+	memorySort(result, func(a, b *AuthProvider) bool {
+		return memoryCompare(a.Name, b.Name) < 0
+	})
+	return result, nil
+}
+
+func (r *MemoryAuthProviderRepository) Update(e *AuthProvider) error {
+	if err := memoryCheckUUID(e.OrganizationID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	row, err := memoryFind(memoryData.authProviders, func(row *AuthProvider) bool {
+		return row.ID == e.ID
+	})
+	if err != nil {
+		return nil
+	}
+	*row = *e
+	return nil
+}
+
+func (r *MemoryAuthProviderRepository) Delete(e *AuthProvider) error {
+	if err := r.repos.AuthProviderRoleMapping.DeleteAll(e.ID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.authProviders = memoryDelete(memoryData.authProviders, func(row *AuthProvider) bool {
+		return row.ID == e.ID
+	})
+	return nil
+}
+
+func (r *MemoryAuthProviderRepository) DeleteAll(organizationID string) error {
+	if err := r.repos.AuthProviderRoleMapping.DeleteAllInOrg(organizationID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.authProviders = memoryDelete(memoryData.authProviders, func(row *AuthProvider) bool {
+		return row.OrganizationID == organizationID
+	})
+	return nil
+}
@@ -1,16 +1,25 @@
 package main
 
-import (
-	"sync"
-)
+type AuthProviderRepository interface {
+	Repository
+	Create(e *AuthProvider) error
+	GetOne(id string) (*AuthProvider, error)
+	GetAll(organizationID string) ([]*AuthProvider, error)
+	Update(e *AuthProvider) error
+	Delete(e *AuthProvider) error
+	DeleteAll(organizationID string) error
+}
 
-type AuthProviderRepository struct {
+type PostgresAuthProviderRepository struct {
+	repos *Repositories
 }
 
 type AuthProviderType int
 
 const (
 	OAuth2 AuthProviderType = 1
+	OIDC   AuthProviderType = 2
+	SAML   AuthProviderType = 3
 )
 
 type AuthProvider struct {
@@ -26,50 +35,67 @@ type AuthProvider struct {
 	UserInfoEmailField string
 	ClientID           string
 	ClientSecret       string
+	IssuerURL          string
+	ClaimName          string
+	ClaimGroups        string
+	SAMLIdPMetadata    string
+	SAMLSPKey          string
+	SAMLSPCertificate  string
 }
 
-var authProviderRepository *AuthProviderRepository
-var authProviderRepositoryOnce sync.Once
-
-func GetAuthProviderRepository() *AuthProviderRepository {
-	authProviderRepositoryOnce.Do(func() {
-		authProviderRepository = &AuthProviderRepository{}
-		_, err := GetDatabase().DB().Exec("CREATE TABLE IF NOT EXISTS auth_providers (" +
-			"id uuid DEFAULT uuid_generate_v4(), " +
-			"organization_id uuid NOT NULL, " +
-			"name VARCHAR NOT NULL, " +
-			"provider_type INT NOT NULL, " +
-			"auth_url VARCHAR NOT NULL, " +
-			"token_url VARCHAR NOT NULL, " +
-			"auth_style INT NOT NULL, " +
-			"scopes VARCHAR NOT NULL, " +
-			"userinfo_url VARCHAR NOT NULL, " +
-			"userinfo_email_field VARCHAR NOT NULL, " +
-			"client_id VARCHAR NOT NULL, " +
-			"client_secret VARCHAR NOT NULL, " +
-			"PRIMARY KEY (id))")
-		if err != nil {
-			panic(err)
-		}
-		_, err = GetDatabase().DB().Exec("CREATE INDEX IF NOT EXISTS idx_auth_providers_organization_id ON auth_providers(organization_id)")
-		if err != nil {
-			panic(err)
-		}
-	})
-	return authProviderRepository
-}
-
-func (r *AuthProviderRepository) RunSchemaUpgrade(curVersion, targetVersion int) {
-	// No updates yet
+func (r *PostgresAuthProviderRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		newInitialSchemaMigration(
+			"CREATE TABLE IF NOT EXISTS auth_providers ("+
+				"id uuid DEFAULT uuid_generate_v4(), "+
+				"organization_id uuid NOT NULL, "+
+				"name VARCHAR NOT NULL, "+
+				"provider_type INT NOT NULL, "+
+				"auth_url VARCHAR NOT NULL, "+
+				"token_url VARCHAR NOT NULL, "+
+				"auth_style INT NOT NULL, "+
+				"scopes VARCHAR NOT NULL, "+
+				"userinfo_url VARCHAR NOT NULL, "+
+				"userinfo_email_field VARCHAR NOT NULL, "+
+				"client_id VARCHAR NOT NULL, "+
+				"client_secret VARCHAR NOT NULL, "+
+				"PRIMARY KEY (id))",
+			"CREATE INDEX IF NOT EXISTS idx_auth_providers_organization_id ON auth_providers(organization_id)",
+		),
+		{
+			Version:     19,
+			Description: "Add OpenID Connect settings to auth providers",
+			Up: []string{"ALTER TABLE auth_providers " +
+				"ADD COLUMN issuer_url VARCHAR NOT NULL DEFAULT '', " +
+				"ADD COLUMN claim_name VARCHAR NOT NULL DEFAULT '', " +
+				"ADD COLUMN claim_groups VARCHAR NOT NULL DEFAULT ''"},
+			Down: []string{"ALTER TABLE auth_providers " +
+				"DROP COLUMN issuer_url, " +
+				"DROP COLUMN claim_name, " +
+				"DROP COLUMN claim_groups"},
+		},
+		{
+			Version:     20,
+			Description: "Add SAML settings to auth providers",
+			Up: []string{"ALTER TABLE auth_providers " +
+				"ADD COLUMN saml_idp_metadata VARCHAR NOT NULL DEFAULT '', " +
+				"ADD COLUMN saml_sp_key VARCHAR NOT NULL DEFAULT '', " +
+				"ADD COLUMN saml_sp_cert VARCHAR NOT NULL DEFAULT ''"},
+			Down: []string{"ALTER TABLE auth_providers " +
+				"DROP COLUMN saml_idp_metadata, " +
+				"DROP COLUMN saml_sp_key, " +
+				"DROP COLUMN saml_sp_cert"},
+		},
+	}
 }
 
-func (r *AuthProviderRepository) Create(e *AuthProvider) error {
+func (r *PostgresAuthProviderRepository) Create(e *AuthProvider) error {
 	var id string
 	err := GetDatabase().DB().QueryRow("INSERT INTO auth_providers "+
-		"(organization_id, name, provider_type, auth_url, token_url, auth_style, scopes, userinfo_url, userinfo_email_field, client_id, client_secret) "+
-		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) "+
+		"(organization_id, name, provider_type, auth_url, token_url, auth_style, scopes, userinfo_url, userinfo_email_field, client_id, client_secret, issuer_url, claim_name, claim_groups, saml_idp_metadata, saml_sp_key, saml_sp_cert) "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17) "+
 		"RETURNING id",
-		e.OrganizationID, e.Name, e.ProviderType, e.AuthURL, e.TokenURL, e.AuthStyle, e.Scopes, e.UserInfoURL, e.UserInfoEmailField, e.ClientID, e.ClientSecret).Scan(&id)
+		e.OrganizationID, e.Name, e.ProviderType, e.AuthURL, e.TokenURL, e.AuthStyle, e.Scopes, e.UserInfoURL, e.UserInfoEmailField, e.ClientID, e.ClientSecret, e.IssuerURL, e.ClaimName, e.ClaimGroups, e.SAMLIdPMetadata, e.SAMLSPKey, e.SAMLSPCertificate).Scan(&id)
 	if err != nil {
 		return err
 	}
@@ -77,21 +103,21 @@ func (r *AuthProviderRepository) Create(e *AuthProvider) error {
 	return nil
 }
 
-func (r *AuthProviderRepository) GetOne(id string) (*AuthProvider, error) {
+func (r *PostgresAuthProviderRepository) GetOne(id string) (*AuthProvider, error) {
 	e := &AuthProvider{}
-	err := GetDatabase().DB().QueryRow("SELECT id, organization_id, name, provider_type, auth_url, token_url, auth_style, scopes, userinfo_url, userinfo_email_field, client_id, client_secret "+
+	err := GetDatabase().DB().QueryRow("SELECT id, organization_id, name, provider_type, auth_url, token_url, auth_style, scopes, userinfo_url, userinfo_email_field, client_id, client_secret, issuer_url, claim_name, claim_groups, saml_idp_metadata, saml_sp_key, saml_sp_cert "+
 		"FROM auth_providers "+
 		"WHERE id = $1",
-		id).Scan(&e.ID, &e.OrganizationID, &e.Name, &e.ProviderType, &e.AuthURL, &e.TokenURL, &e.AuthStyle, &e.Scopes, &e.UserInfoURL, &e.UserInfoEmailField, &e.ClientID, &e.ClientSecret)
+		id).Scan(&e.ID, &e.OrganizationID, &e.Name, &e.ProviderType, &e.AuthURL, &e.TokenURL, &e.AuthStyle, &e.Scopes, &e.UserInfoURL, &e.UserInfoEmailField, &e.ClientID, &e.ClientSecret, &e.IssuerURL, &e.ClaimName, &e.ClaimGroups, &e.SAMLIdPMetadata, &e.SAMLSPKey, &e.SAMLSPCertificate)
 	if err != nil {
 		return nil, err
 	}
 	return e, nil
 }
 
-func (r *AuthProviderRepository) GetAll(organizationID string) ([]*AuthProvider, error) {
+func (r *PostgresAuthProviderRepository) GetAll(organizationID string) ([]*AuthProvider, error) {
 	var result []*AuthProvider
-	rows, err := GetDatabase().DB().Query("SELECT id, organization_id, name, provider_type, auth_url, token_url, auth_style, scopes, userinfo_url, userinfo_email_field, client_id, client_secret "+
+	rows, err := GetDatabase().DB().Query("SELECT id, organization_id, name, provider_type, auth_url, token_url, auth_style, scopes, userinfo_url, userinfo_email_field, client_id, client_secret, issuer_url, claim_name, claim_groups, saml_idp_metadata, saml_sp_key, saml_sp_cert "+
 		"FROM auth_providers "+
 		"WHERE organization_id = $1 "+
 		"ORDER BY name", organizationID)
@@ -101,7 +127,7 @@ func (r *AuthProviderRepository) GetAll(organizationID string) ([]*AuthProvider,
 	defer rows.Close()
 	for rows.Next() {
 		e := &AuthProvider{}
-		err = rows.Scan(&e.ID, &e.OrganizationID, &e.Name, &e.ProviderType, &e.AuthURL, &e.TokenURL, &e.AuthStyle, &e.Scopes, &e.UserInfoURL, &e.UserInfoEmailField, &e.ClientID, &e.ClientSecret)
+		err = rows.Scan(&e.ID, &e.OrganizationID, &e.Name, &e.ProviderType, &e.AuthURL, &e.TokenURL, &e.AuthStyle, &e.Scopes, &e.UserInfoURL, &e.UserInfoEmailField, &e.ClientID, &e.ClientSecret, &e.IssuerURL, &e.ClaimName, &e.ClaimGroups, &e.SAMLIdPMetadata, &e.SAMLSPKey, &e.SAMLSPCertificate)
 		if err != nil {
 			return nil, err
 		}
@@ -110,7 +136,7 @@ func (r *AuthProviderRepository) GetAll(organizationID string) ([]*AuthProvider,
 	return result, nil
 }
 
-func (r *AuthProviderRepository) Update(e *AuthProvider) error {
+func (r *PostgresAuthProviderRepository) Update(e *AuthProvider) error {
 	_, err := GetDatabase().DB().Exec("UPDATE auth_providers SET "+
 		"organization_id = $1, "+
 		"name = $2, "+
@@ -122,18 +148,30 @@ func (r *AuthProviderRepository) Update(e *AuthProvider) error {
 		"userinfo_url = $8, "+
 		"userinfo_email_field = $9, "+
 		"client_id = $10, "+
-		"client_secret = $11 "+
-		"WHERE id = $12",
-		e.OrganizationID, e.Name, e.ProviderType, e.AuthURL, e.TokenURL, e.AuthStyle, e.Scopes, e.UserInfoURL, e.UserInfoEmailField, e.ClientID, e.ClientSecret, e.ID)
+		"client_secret = $11, "+
+		"issuer_url = $12, "+
+		"claim_name = $13, "+
+		"claim_groups = $14, "+
+		"saml_idp_metadata = $15, "+
+		"saml_sp_key = $16, "+
+		"saml_sp_cert = $17 "+
+		"WHERE id = $18",
+		e.OrganizationID, e.Name, e.ProviderType, e.AuthURL, e.TokenURL, e.AuthStyle, e.Scopes, e.UserInfoURL, e.UserInfoEmailField, e.ClientID, e.ClientSecret, e.IssuerURL, e.ClaimName, e.ClaimGroups, e.SAMLIdPMetadata, e.SAMLSPKey, e.SAMLSPCertificate, e.ID)
 	return err
 }
 
-func (r *AuthProviderRepository) Delete(e *AuthProvider) error {
+func (r *PostgresAuthProviderRepository) Delete(e *AuthProvider) error {
+	if err := r.repos.AuthProviderRoleMapping.DeleteAll(e.ID); err != nil {
+		return err
+	}
 	_, err := GetDatabase().DB().Exec("DELETE FROM auth_providers WHERE id = $1", e.ID)
 	return err
 }
 
-func (r *AuthProviderRepository) DeleteAll(organizationID string) error {
+func (r *PostgresAuthProviderRepository) DeleteAll(organizationID string) error {
+	if err := r.repos.AuthProviderRoleMapping.DeleteAllInOrg(organizationID); err != nil {
+		return err
+	}
 	_, err := GetDatabase().DB().Exec("DELETE FROM auth_providers WHERE organization_id = $1", organizationID)
 	return err
 }
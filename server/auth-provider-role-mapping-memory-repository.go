@@ -0,0 +1,57 @@
+package main
+
+type MemoryAuthProviderRoleMappingRepository struct {
+	authProviderRoleMappingRepositoryBase
+}
+
+func (r *MemoryAuthProviderRoleMappingRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryAuthProviderRoleMappingRepository) Create(e *AuthProviderRoleMapping) error {
+	if err := memoryCheckUUID(e.AuthProviderID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e.ID = memoryNewID()
+	row := *e
+	memoryData.authProviderRoleMappings = append(memoryData.authProviderRoleMappings, &row)
+	return nil
+}
+
+func (r *MemoryAuthProviderRoleMappingRepository) GetAll(authProviderID string) ([]*AuthProviderRoleMapping, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	result := memoryCopy(memoryFilter(memoryData.authProviderRoleMappings, func(e *AuthProviderRoleMapping) bool {
+		return e.AuthProviderID == authProviderID
+	}))
+	memorySort(result, func(a, b *AuthProviderRoleMapping) bool {
+		if a.Role != b.Role {
+			return a.Role > b.Role
+		}
+		return memoryCompare(a.ClaimValue, b.ClaimValue) < 0
+	})
+	return result, nil
+}
+
+func (r *MemoryAuthProviderRoleMappingRepository) DeleteAll(authProviderID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.authProviderRoleMappings = memoryDelete(memoryData.authProviderRoleMappings, func(row *AuthProviderRoleMapping) bool {
+		return row.AuthProviderID == authProviderID
+	})
+	return nil
+}
+
+func (r *MemoryAuthProviderRoleMappingRepository) DeleteAllInOrg(organizationID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.authProviderRoleMappings = memoryDelete(memoryData.authProviderRoleMappings, func(row *AuthProviderRoleMapping) bool {
+		return memoryCount(memoryData.authProviders, func(e *AuthProvider) bool {
+			return e.ID == row.AuthProviderID && e.OrganizationID == organizationID
+		}) > 0
+	})
+	return nil
+}
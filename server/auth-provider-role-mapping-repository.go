@@ -0,0 +1,112 @@
+package main
+
+type AuthProviderRoleMappingRepository interface {
+	Repository
+	Create(e *AuthProviderRoleMapping) error
+	GetAll(authProviderID string) ([]*AuthProviderRoleMapping, error)
+	DeleteAll(authProviderID string) error
+	DeleteAllInOrg(organizationID string) error
+	GetMappedRole(authProviderID string, groups []string) (role UserRole, ok bool, err error)
+}
+
+type PostgresAuthProviderRoleMappingRepository struct {
+	authProviderRoleMappingRepositoryBase
+}
+
+// authProviderRoleMappingRepositoryBase evaluates the mappings of any implementation.
+type authProviderRoleMappingRepositoryBase struct {
+	repos *Repositories
+}
+
+// AuthProviderRoleMapping grants a role to users whose groups claim received from
+// the auth provider contains ClaimValue.
+type AuthProviderRoleMapping struct {
+	ID             string
+	AuthProviderID string
+	ClaimValue     string
+	Role           UserRole
+}
+
+func (r *PostgresAuthProviderRoleMappingRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		{
+			Version:     29,
+			Description: "Add role mappings to auth providers",
+			Up: []string{
+				"CREATE TABLE IF NOT EXISTS auth_provider_role_mappings (" +
+					"id uuid DEFAULT uuid_generate_v4(), " +
+					"auth_provider_id uuid NOT NULL, " +
+					"claim_value VARCHAR NOT NULL, " +
+					"role INT NOT NULL, " +
+					"PRIMARY KEY (id))",
+				"CREATE INDEX IF NOT EXISTS idx_auth_provider_role_mappings_auth_provider_id ON auth_provider_role_mappings(auth_provider_id)",
+			},
+			Down: []string{"DROP TABLE IF EXISTS auth_provider_role_mappings"},
+		},
+	}
+}
+
+func (r *PostgresAuthProviderRoleMappingRepository) Create(e *AuthProviderRoleMapping) error {
+	var id string
+	err := GetDatabase().DB().QueryRow("INSERT INTO auth_provider_role_mappings "+
+		"(auth_provider_id, claim_value, role) "+
+		"VALUES ($1, $2, $3) "+
+		"RETURNING id",
+		e.AuthProviderID, e.ClaimValue, e.Role).Scan(&id)
+	if err != nil {
+		return err
+	}
+	e.ID = id
+	return nil
+}
+
+func (r *PostgresAuthProviderRoleMappingRepository) GetAll(authProviderID string) ([]*AuthProviderRoleMapping, error) {
+	var result []*AuthProviderRoleMapping
+	rows, err := GetDatabase().DB().Query("SELECT id, auth_provider_id, claim_value, role "+
+		"FROM auth_provider_role_mappings "+
+		"WHERE auth_provider_id = $1 "+
+		"ORDER BY role DESC, claim_value", authProviderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &AuthProviderRoleMapping{}
+		err = rows.Scan(&e.ID, &e.AuthProviderID, &e.ClaimValue, &e.Role)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (r *PostgresAuthProviderRoleMappingRepository) DeleteAll(authProviderID string) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM auth_provider_role_mappings WHERE auth_provider_id = $1", authProviderID)
+	return err
+}
+
+func (r *PostgresAuthProviderRoleMappingRepository) DeleteAllInOrg(organizationID string) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM auth_provider_role_mappings WHERE auth_provider_id IN "+
+		"(SELECT id FROM auth_providers WHERE organization_id = $1)", organizationID)
+	return err
+}
+
+// GetMappedRole returns the highest role mapped to any of the groups. Users matching
+// no rule are mapped to UserRoleUser. ok is false if the auth provider has no rules,
+// in which case roles are maintained by hand.
+func (r *authProviderRoleMappingRepositoryBase) GetMappedRole(authProviderID string, groups []string) (role UserRole, ok bool, err error) {
+	list, err := r.repos.AuthProviderRoleMapping.GetAll(authProviderID)
+	if err != nil || len(list) == 0 {
+		return UserRoleUser, false, err
+	}
+	role = UserRoleUser
+	for _, e := range list {
+		for _, group := range groups {
+			if group == e.ClaimValue && e.Role > role {
+				role = e.Role
+			}
+		}
+	}
+	return role, true, nil
+}
@@ -3,7 +3,12 @@ package main
 import (
 	"log"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/crewjam/saml"
 	"github.com/gorilla/mux"
 
 	_ "image/gif"
@@ -12,34 +17,63 @@ import (
 )
 
 type AuthProviderRouter struct {
+	repos *Repositories
 }
 
 type CreateAuthProviderRequest struct {
 	Name               string `json:"name" validate:"required"`
 	ProviderType       int    `json:"providerType" validate:"required"`
-	AuthURL            string `json:"authUrl" validate:"required"`
-	TokenURL           string `json:"tokenUrl" validate:"required"`
+	AuthURL            string `json:"authUrl"`
+	TokenURL           string `json:"tokenUrl"`
 	AuthStyle          int    `json:"authStyle"`
-	Scopes             string `json:"scopes" validate:"required"`
-	UserInfoURL        string `json:"userInfoUrl" validate:"required"`
-	UserInfoEmailField string `json:"userInfoEmailField" validate:"required"`
-	ClientID           string `json:"clientId" validate:"required"`
-	ClientSecret       string `json:"clientSecret" validate:"required"`
+	Scopes             string `json:"scopes"`
+	UserInfoURL        string `json:"userInfoUrl"`
+	UserInfoEmailField string `json:"userInfoEmailField"`
+	ClientID           string `json:"clientId"`
+	ClientSecret       string `json:"clientSecret"`
+	IssuerURL          string `json:"issuerUrl"`
+	ClaimName          string `json:"claimName"`
+	ClaimGroups        string `json:"claimGroups"`
+	SAMLIdPMetadata    string `json:"samlIdpMetadata"`
 }
 
 type GetAuthProviderResponse struct {
-	ID             string `json:"id"`
-	OrganizationID string `json:"organizationId"`
+	ID                string `json:"id"`
+	OrganizationID    string `json:"organizationId"`
+	SAMLSPCertificate string `json:"samlSpCertificate,omitempty"`
+	SAMLSPMetadataURL string `json:"samlSpMetadataUrl,omitempty"`
 	CreateAuthProviderRequest
 }
 
+type AuthProviderRoleMappingRequest struct {
+	ClaimValue string `json:"claimValue" validate:"required"`
+	Role       int    `json:"role"`
+}
+
+type SetAuthProviderRoleMappingsRequest struct {
+	Mappings []*AuthProviderRoleMappingRequest `json:"mappings" validate:"dive"`
+}
+
 type GetAuthProviderPublicResponse struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
 }
 
+// AuthProviderRequestTimeout limits requests to the discovery, JWKS and metadata URLs of
+// identity providers.
+const AuthProviderRequestTimeout time.Duration = time.Second * 10
+
+// getAuthProviderHTTPClient returns the client for requests to identity providers.
+// Their URLs are set by organization admins, so addresses of internal networks are
+// refused unless AUTH_PROVIDER_ALLOW_PRIVATE_NETWORKS is set.
+func getAuthProviderHTTPClient() *http.Client {
+	return newRestrictedHTTPClient(AuthProviderRequestTimeout, GetConfig().AuthProviderAllowPrivateNetworks)
+}
+
 func (router *AuthProviderRouter) setupRoutes(s *mux.Router) {
 	s.HandleFunc("/org/{id}", router.listPublicForOrg).Methods("GET")
+	s.HandleFunc("/{id}/rolemapping", router.getRoleMappings).Methods("GET")
+	s.HandleFunc("/{id}/rolemapping", router.setRoleMappings).Methods("PUT")
 	s.HandleFunc("/{id}", router.getOne).Methods("GET")
 	s.HandleFunc("/{id}", router.update).Methods("PUT")
 	s.HandleFunc("/{id}", router.delete).Methods("DELETE")
@@ -49,13 +83,13 @@ func (router *AuthProviderRouter) setupRoutes(s *mux.Router) {
 
 func (router *AuthProviderRouter) listPublicForOrg(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	org, err := GetOrganizationRepository().GetOne(vars["id"])
+	org, err := router.repos.Organization.GetOne(vars["id"])
 	if err != nil {
 		log.Println(err)
 		SendNotFound(w)
 		return
 	}
-	list, err := GetAuthProviderRepository().GetAll(org.ID)
+	list, err := router.repos.AuthProvider.GetAll(org.ID)
 	if err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
@@ -73,13 +107,13 @@ func (router *AuthProviderRouter) listPublicForOrg(w http.ResponseWriter, r *htt
 
 func (router *AuthProviderRouter) getOne(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	e, err := GetAuthProviderRepository().GetOne(vars["id"])
+	e, err := router.repos.AuthProvider.GetOne(vars["id"])
 	if err != nil {
 		log.Println(err)
 		SendNotFound(w)
 		return
 	}
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanAdminOrg(user, e.OrganizationID) {
 		SendForbidden(w)
 		return
@@ -89,12 +123,12 @@ func (router *AuthProviderRouter) getOne(w http.ResponseWriter, r *http.Request)
 }
 
 func (router *AuthProviderRouter) getAll(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanAdminOrg(user, user.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
-	list, err := GetAuthProviderRepository().GetAll(user.OrganizationID)
+	list, err := router.repos.AuthProvider.GetAll(user.OrganizationID)
 	if err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
@@ -114,12 +148,12 @@ func (router *AuthProviderRouter) update(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	vars := mux.Vars(r)
-	e, err := GetAuthProviderRepository().GetOne(vars["id"])
+	e, err := router.repos.AuthProvider.GetOne(vars["id"])
 	if err != nil {
 		SendBadRequest(w)
 		return
 	}
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanAdminOrg(user, e.OrganizationID) {
 		SendForbidden(w)
 		return
@@ -127,32 +161,40 @@ func (router *AuthProviderRouter) update(w http.ResponseWriter, r *http.Request)
 	eNew := router.copyFromRestModel(&m)
 	eNew.ID = e.ID
 	eNew.OrganizationID = e.OrganizationID
-	if err := GetAuthProviderRepository().Update(eNew); err != nil {
+	eNew.SAMLSPKey = e.SAMLSPKey
+	eNew.SAMLSPCertificate = e.SAMLSPCertificate
+	if !router.applyProviderType(eNew) {
+		SendBadRequest(w)
+		return
+	}
+	if err := router.repos.AuthProvider.Update(eNew); err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
 	}
+	recordAuditEvent(router.repos, r, e.OrganizationID, AuditActionAuthProviderUpdated, AuditTargetAuthProvider, e.ID, router.copyToRestModel(e), router.copyToRestModel(eNew))
 	SendUpdated(w)
 }
 
 func (router *AuthProviderRouter) delete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	e, err := GetAuthProviderRepository().GetOne(vars["id"])
+	e, err := router.repos.AuthProvider.GetOne(vars["id"])
 	if err != nil {
 		log.Println(err)
 		SendNotFound(w)
 		return
 	}
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanAdminOrg(user, e.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
-	if err := GetAuthProviderRepository().Delete(e); err != nil {
+	if err := router.repos.AuthProvider.Delete(e); err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
 	}
+	recordAuditEvent(router.repos, r, e.OrganizationID, AuditActionAuthProviderDeleted, AuditTargetAuthProvider, e.ID, router.copyToRestModel(e), nil)
 	SendUpdated(w)
 }
 
@@ -163,21 +205,194 @@ func (router *AuthProviderRouter) create(w http.ResponseWriter, r *http.Request)
 		SendBadRequest(w)
 		return
 	}
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	e := router.copyFromRestModel(&m)
 	e.OrganizationID = user.OrganizationID
 	if !CanAdminOrg(user, e.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
-	if err := GetAuthProviderRepository().Create(e); err != nil {
+	if !router.applyProviderType(e) {
+		SendBadRequest(w)
+		return
+	}
+	if err := router.repos.AuthProvider.Create(e); err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
 	}
+	recordAuditEvent(router.repos, r, e.OrganizationID, AuditActionAuthProviderCreated, AuditTargetAuthProvider, e.ID, nil, router.copyToRestModel(e))
 	SendCreated(w, e.ID)
 }
 
+func (router *AuthProviderRouter) getRoleMappings(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	e, err := router.repos.AuthProvider.GetOne(vars["id"])
+	if err != nil {
+		log.Println(err)
+		SendNotFound(w)
+		return
+	}
+	user := GetRequestUser(router.repos, r)
+	if !CanAdminOrg(user, e.OrganizationID) {
+		SendForbidden(w)
+		return
+	}
+	list, err := router.repos.AuthProviderRoleMapping.GetAll(e.ID)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	res := []*AuthProviderRoleMappingRequest{}
+	for _, mapping := range list {
+		res = append(res, &AuthProviderRoleMappingRequest{
+			ClaimValue: mapping.ClaimValue,
+			Role:       int(mapping.Role),
+		})
+	}
+	SendJSON(w, res)
+}
+
+// setRoleMappings replaces the provider's role mapping rules. The rules are applied
+// to the values of the provider's groups claim, so a groups claim must be configured.
+func (router *AuthProviderRouter) setRoleMappings(w http.ResponseWriter, r *http.Request) {
+	var m SetAuthProviderRoleMappingsRequest
+	if err := UnmarshalValidateBody(r, &m); err != nil {
+		log.Println(err)
+		SendBadRequest(w)
+		return
+	}
+	vars := mux.Vars(r)
+	e, err := router.repos.AuthProvider.GetOne(vars["id"])
+	if err != nil {
+		log.Println(err)
+		SendNotFound(w)
+		return
+	}
+	user := GetRequestUser(router.repos, r)
+	if !CanAdminOrg(user, e.OrganizationID) {
+		SendForbidden(w)
+		return
+	}
+	if len(m.Mappings) > 0 && e.ClaimGroups == "" {
+		SendBadRequest(w)
+		return
+	}
+	for _, mapping := range m.Mappings {
+		role := UserRole(mapping.Role)
+		if role != UserRoleUser && role != UserRoleSpaceAdmin && role != UserRoleOrgAdmin {
+			SendBadRequest(w)
+			return
+		}
+	}
+	listOld, err := router.repos.AuthProviderRoleMapping.GetAll(e.ID)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	mappingsOld := []*AuthProviderRoleMappingRequest{}
+	for _, mapping := range listOld {
+		mappingsOld = append(mappingsOld, &AuthProviderRoleMappingRequest{
+			ClaimValue: mapping.ClaimValue,
+			Role:       int(mapping.Role),
+		})
+	}
+	if err := router.repos.AuthProviderRoleMapping.DeleteAll(e.ID); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	for _, mapping := range m.Mappings {
+		rule := &AuthProviderRoleMapping{
+			AuthProviderID: e.ID,
+			ClaimValue:     mapping.ClaimValue,
+			Role:           UserRole(mapping.Role),
+		}
+		if err := router.repos.AuthProviderRoleMapping.Create(rule); err != nil {
+			log.Println(err)
+			SendInternalServerError(w)
+			return
+		}
+	}
+	recordAuditEvent(router.repos, r, e.OrganizationID, AuditActionAuthProviderUpdated, AuditTargetAuthProvider, e.ID, map[string]interface{}{"roleMappings": mappingsOld},
+		map[string]interface{}{"roleMappings": append([]*AuthProviderRoleMappingRequest{}, m.Mappings...)})
+	SendUpdated(w)
+}
+
+// applyProviderType validates the provider's settings for its type. OpenID Connect
+// providers only need an issuer URL, the endpoints are read from its discovery document.
+// SAML providers need the identity provider's metadata, either inline or from the
+// URL in IssuerURL, and get a service provider key pair on first save.
+func (router *AuthProviderRouter) applyProviderType(e *AuthProvider) bool {
+	switch AuthProviderType(e.ProviderType) {
+	case OAuth2:
+		return e.ClientID != "" && e.ClientSecret != "" && e.AuthURL != "" && e.TokenURL != "" && e.Scopes != "" && e.UserInfoURL != "" && e.UserInfoEmailField != ""
+	case OIDC:
+		if e.ClientID == "" || e.ClientSecret == "" || e.IssuerURL == "" {
+			return false
+		}
+		provider, err := getOIDCProvider(e.IssuerURL)
+		if err != nil {
+			log.Println(err)
+			return false
+		}
+		endpoint := provider.Endpoint()
+		e.AuthURL = endpoint.AuthURL
+		e.TokenURL = endpoint.TokenURL
+		e.UserInfoURL = provider.UserInfoEndpoint()
+		if e.Scopes == "" {
+			e.Scopes = OIDCDefaultScopes
+		}
+		if !slices.Contains(strings.Split(e.Scopes, ","), oidc.ScopeOpenID) {
+			e.Scopes = oidc.ScopeOpenID + "," + e.Scopes
+		}
+		if e.UserInfoEmailField == "" {
+			e.UserInfoEmailField = "email"
+		}
+		if e.ClaimName == "" {
+			e.ClaimName = "name"
+		}
+		return true
+	case SAML:
+		if e.IssuerURL != "" {
+			metadata, err := fetchSAMLIdPMetadata(e.IssuerURL)
+			if err != nil {
+				log.Println(err)
+				return false
+			}
+			e.SAMLIdPMetadata = string(metadata)
+		}
+		idpMetadata, err := parseSAMLIdPMetadata([]byte(e.SAMLIdPMetadata))
+		if err != nil {
+			log.Println(err)
+			return false
+		}
+		e.AuthURL = ""
+		for _, sso := range idpMetadata.IDPSSODescriptors[0].SingleSignOnServices {
+			if sso.Binding == saml.HTTPRedirectBinding {
+				e.AuthURL = sso.Location
+			}
+		}
+		if e.AuthURL == "" {
+			return false
+		}
+		e.TokenURL = ""
+		e.UserInfoURL = ""
+		e.Scopes = ""
+		if e.SAMLSPKey == "" || e.SAMLSPCertificate == "" {
+			e.SAMLSPKey, e.SAMLSPCertificate, err = createSAMLKeyPair(GetConfig().PublicURL)
+			if err != nil {
+				log.Println(err)
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
 func (router *AuthProviderRouter) copyFromRestModel(m *CreateAuthProviderRequest) *AuthProvider {
 	e := &AuthProvider{}
 	e.Name = m.Name
@@ -190,6 +405,10 @@ func (router *AuthProviderRouter) copyFromRestModel(m *CreateAuthProviderRequest
 	e.UserInfoURL = m.UserInfoURL
 	e.UserInfoEmailField = m.UserInfoEmailField
 	e.ProviderType = m.ProviderType
+	e.IssuerURL = m.IssuerURL
+	e.ClaimName = m.ClaimName
+	e.ClaimGroups = m.ClaimGroups
+	e.SAMLIdPMetadata = m.SAMLIdPMetadata
 	return e
 }
 
@@ -207,5 +426,13 @@ func (router *AuthProviderRouter) copyToRestModel(e *AuthProvider) *GetAuthProvi
 	m.UserInfoURL = e.UserInfoURL
 	m.UserInfoEmailField = e.UserInfoEmailField
 	m.ProviderType = e.ProviderType
+	m.IssuerURL = e.IssuerURL
+	m.ClaimName = e.ClaimName
+	m.ClaimGroups = e.ClaimGroups
+	m.SAMLIdPMetadata = e.SAMLIdPMetadata
+	if AuthProviderType(e.ProviderType) == SAML {
+		m.SAMLSPCertificate = e.SAMLSPCertificate
+		m.SAMLSPMetadataURL = GetConfig().PublicURL + "auth/" + e.ID + "/metadata"
+	}
 	return m
 }
@@ -157,3 +157,68 @@ func TestAuthProvidersGetPublicForOrg(t *testing.T) {
 	checkTestString(t, id2, resBody[1].ID)
 	checkTestString(t, "Test2", resBody[1].Name)
 }
+
+func TestAuthProvidersRoleMappings(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	userAdmin := createTestUserOrgAdmin(org)
+	user := createTestUserInOrg(org)
+
+	payload := `{"name": "Test", "providerType": 1, "clientId": "test1", "clientSecret": "test2", "authUrl": "http://test.com/1", "tokenUrl": "http://test.com/2", "authStyle": 0, "scopes": "http://test.com/3", "userInfoUrl": "http://test.com/userinfo", "userInfoEmailField": "email"}`
+	req := newHTTPRequest("POST", "/auth-provider/", userAdmin.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	id := res.Header().Get("X-Object-Id")
+
+	// Rules require a groups claim
+	payload = `{"mappings": [{"claimValue": "admins", "role": 20}]}`
+	req = newHTTPRequest("PUT", "/auth-provider/"+id+"/rolemapping", userAdmin.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+
+	payload = `{"name": "Test", "providerType": 1, "clientId": "test1", "clientSecret": "test2", "authUrl": "http://test.com/1", "tokenUrl": "http://test.com/2", "authStyle": 0, "scopes": "http://test.com/3", "userInfoUrl": "http://test.com/userinfo", "userInfoEmailField": "email", "claimGroups": "groups"}`
+	req = newHTTPRequest("PUT", "/auth-provider/"+id, userAdmin.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	// Super admin can't be mapped
+	payload = `{"mappings": [{"claimValue": "admins", "role": 90}]}`
+	req = newHTTPRequest("PUT", "/auth-provider/"+id+"/rolemapping", userAdmin.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+
+	payload = `{"mappings": [{"claimValue": "facility", "role": 10}, {"claimValue": "admins", "role": 20}]}`
+	req = newHTTPRequest("PUT", "/auth-provider/"+id+"/rolemapping", user.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+
+	req = newHTTPRequest("PUT", "/auth-provider/"+id+"/rolemapping", userAdmin.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	req = newHTTPRequest("GET", "/auth-provider/"+id+"/rolemapping", userAdmin.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody []*AuthProviderRoleMappingRequest
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestInt(t, 2, len(resBody))
+	checkTestString(t, "admins", resBody[0].ClaimValue)
+	checkTestInt(t, int(UserRoleOrgAdmin), resBody[0].Role)
+	checkTestString(t, "facility", resBody[1].ClaimValue)
+	checkTestInt(t, int(UserRoleSpaceAdmin), resBody[1].Role)
+
+	role, ok, _ := testRepositories.AuthProviderRoleMapping.GetMappedRole(id, []string{"facility", "admins"})
+	checkTestBool(t, true, ok)
+	checkTestInt(t, int(UserRoleOrgAdmin), int(role))
+	role, ok, _ = testRepositories.AuthProviderRoleMapping.GetMappedRole(id, nil)
+	checkTestBool(t, true, ok)
+	checkTestInt(t, int(UserRoleUser), int(role))
+
+	// Replace with empty rule set
+	payload = `{"mappings": []}`
+	req = newHTTPRequest("PUT", "/auth-provider/"+id+"/rolemapping", userAdmin.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+	_, ok, _ = testRepositories.AuthProviderRoleMapping.GetMappedRole(id, []string{"admins"})
+	checkTestBool(t, false, ok)
+}
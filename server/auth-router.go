@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io"
 	"log"
@@ -11,7 +12,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/crewjam/saml"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"golang.org/x/oauth2"
 )
@@ -56,26 +60,62 @@ type AuthPasswordRequest struct {
 	LongLived bool   `json:"longLived"`
 }
 
+// AuthTOTPChallengeResponse is returned by password logins that need a second
+// factor. Users required to use TOTP but not yet enrolled get a new secret to enroll with.
+type AuthTOTPChallengeResponse struct {
+	RequireOTP        bool   `json:"otpRequired"`
+	RequireEnrollment bool   `json:"otpEnrollmentRequired"`
+	ChallengeID       string `json:"challengeId"`
+	EnrollmentSecret  string `json:"otpSecret,omitempty"`
+	EnrollmentURL     string `json:"otpUrl,omitempty"`
+}
+
+type AuthTOTPRequest struct {
+	ChallengeID string `json:"challengeId" validate:"required"`
+	Code        string `json:"code" validate:"required"`
+}
+
+type AuthTOTPResponse struct {
+	JWTResponse
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
+}
+
 type RefreshRequest struct {
 	RefreshToken string `json:"refreshToken" validate:"required"`
 }
 
 type AuthStateLoginPayload struct {
-	UserID    string `json:"userId"`
-	LoginType string `json:"type"`
-	LongLived bool   `json:"longLived"`
-	Redirect  string `json:"redirect,omitempty"`
+	UserID       string    `json:"userId"`
+	LoginType    string    `json:"type"`
+	LongLived    bool      `json:"longLived"`
+	Redirect     string    `json:"redirect,omitempty"`
+	CodeVerifier string    `json:"codeVerifier,omitempty"`
+	Nonce        string    `json:"nonce,omitempty"`
+	Name         string    `json:"name,omitempty"`
+	Groups       []string  `json:"groups,omitempty"`
+	Role         *UserRole `json:"role,omitempty"`
+}
+
+// AuthUserInfo holds the user attributes read from an identity provider's claims.
+// Groups is nil if the provider doesn't supply group claims.
+type AuthUserInfo struct {
+	Email  string
+	Name   string
+	Groups []string
 }
 
 type AuthRouter struct {
+	repos *Repositories
 }
 
 func (router *AuthRouter) setupRoutes(s *mux.Router) {
 	s.HandleFunc("/verify/{id}", router.verify).Methods("GET")
 	s.HandleFunc("/{id}/login/{type}/{longLived}", router.login).Methods("GET")
 	s.HandleFunc("/{id}/login/{type}", router.login).Methods("GET")
-	s.HandleFunc("/{id}/callback", router.callback).Methods("GET")
+	s.HandleFunc("/{id}/callback", router.callback).Methods("GET", "POST")
+	s.HandleFunc("/{id}/metadata", router.samlMetadata).Methods("GET")
 	s.HandleFunc("/preflight", router.preflight).Methods("POST")
+	s.HandleFunc("/login/otp", router.loginTOTP).Methods("POST")
 	s.HandleFunc("/login", router.loginPassword).Methods("POST")
 	s.HandleFunc("/initpwreset", router.initPasswordReset).Methods("POST")
 	s.HandleFunc("/pwreset/{id}", router.completePasswordReset).Methods("POST")
@@ -84,7 +124,7 @@ func (router *AuthRouter) setupRoutes(s *mux.Router) {
 }
 
 func (router *AuthRouter) singleOrg(w http.ResponseWriter, r *http.Request) {
-	numOrgs, err := GetOrganizationRepository().GetNumOrgs()
+	numOrgs, err := router.repos.Organization.GetNumOrgs()
 	if err != nil {
 		SendInternalServerError(w)
 		return
@@ -93,7 +133,7 @@ func (router *AuthRouter) singleOrg(w http.ResponseWriter, r *http.Request) {
 		SendNotFound(w)
 		return
 	}
-	list, err := GetOrganizationRepository().GetAll()
+	list, err := router.repos.Organization.GetAll()
 	if err != nil {
 		SendInternalServerError(w)
 		return
@@ -108,7 +148,7 @@ func (router *AuthRouter) singleOrg(w http.ResponseWriter, r *http.Request) {
 		SendInternalServerError(w)
 		return
 	}
-	requirePassword, err := GetUserRepository().HasAnyUserInOrgPasswordSet(org.ID)
+	requirePassword, err := router.repos.User.HasAnyUserInOrgPasswordSet(org.ID)
 	if err != nil {
 		SendInternalServerError(w)
 		return
@@ -123,7 +163,7 @@ func (router *AuthRouter) refreshAccessToken(w http.ResponseWriter, r *http.Requ
 		SendBadRequest(w)
 		return
 	}
-	refreshToken, err := GetRefreshTokenRepository().GetOne(m.RefreshToken)
+	refreshToken, err := router.repos.RefreshToken.GetOne(m.RefreshToken)
 	if err != nil || refreshToken == nil {
 		SendNotFound(w)
 		return
@@ -132,7 +172,7 @@ func (router *AuthRouter) refreshAccessToken(w http.ResponseWriter, r *http.Requ
 		SendBadRequest(w)
 		return
 	}
-	user, err := GetUserRepository().GetOne(refreshToken.UserID)
+	user, err := router.repos.User.GetOne(refreshToken.UserID)
 	if err != nil {
 		SendNotFound(w)
 		return
@@ -149,7 +189,7 @@ func (router *AuthRouter) refreshAccessToken(w http.ResponseWriter, r *http.Requ
 		AccessToken:  accessToken,
 		RefreshToken: newRefreshToken,
 	}
-	GetRefreshTokenRepository().Delete(refreshToken)
+	router.repos.RefreshToken.Delete(refreshToken)
 	SendJSON(w, res)
 }
 
@@ -159,7 +199,7 @@ func (router *AuthRouter) initPasswordReset(w http.ResponseWriter, r *http.Reque
 		SendBadRequest(w)
 		return
 	}
-	user, err := GetUserRepository().GetByEmail(m.Email)
+	user, err := router.repos.User.GetByEmail(m.Email)
 	if user == nil || err != nil {
 		SendNotFound(w)
 		return
@@ -172,18 +212,18 @@ func (router *AuthRouter) initPasswordReset(w http.ResponseWriter, r *http.Reque
 		SendNotFound(w)
 		return
 	}
-	org, err := GetOrganizationRepository().GetOne(user.OrganizationID)
+	org, err := router.repos.Organization.GetOne(user.OrganizationID)
 	if org == nil || err != nil {
 		SendNotFound(w)
 		return
 	}
 	authState := &AuthState{
-		AuthProviderID: GetSettingsRepository().getNullUUID(),
+		AuthProviderID: router.repos.Settings.getNullUUID(),
 		Expiry:         time.Now().Add(time.Hour * 1),
 		AuthStateType:  AuthResetPasswordRequest,
 		Payload:        user.ID,
 	}
-	GetAuthStateRepository().Create(authState)
+	router.repos.AuthState.Create(authState)
 	router.SendPasswordResetEmail(user, authState.ID, org)
 	SendUpdated(w)
 }
@@ -195,7 +235,7 @@ func (router *AuthRouter) completePasswordReset(w http.ResponseWriter, r *http.R
 		return
 	}
 	vars := mux.Vars(r)
-	authState, err := GetAuthStateRepository().GetOne(vars["id"])
+	authState, err := router.repos.AuthState.GetOne(vars["id"])
 	if err != nil {
 		SendNotFound(w)
 		return
@@ -204,7 +244,7 @@ func (router *AuthRouter) completePasswordReset(w http.ResponseWriter, r *http.R
 		SendNotFound(w)
 		return
 	}
-	user, err := GetUserRepository().GetOne(authState.Payload)
+	user, err := router.repos.User.GetOne(authState.Payload)
 	if user == nil || err != nil {
 		SendNotFound(w)
 		return
@@ -217,9 +257,9 @@ func (router *AuthRouter) completePasswordReset(w http.ResponseWriter, r *http.R
 		SendNotFound(w)
 		return
 	}
-	user.HashedPassword = NullString(GetUserRepository().GetHashedPassword(m.Password))
-	GetUserRepository().Update(user)
-	GetAuthStateRepository().Delete(authState)
+	user.HashedPassword = NullString(router.repos.User.GetHashedPassword(m.Password))
+	router.repos.User.Update(user)
+	router.repos.AuthState.Delete(authState)
 	SendUpdated(w)
 }
 
@@ -234,7 +274,7 @@ func (router *AuthRouter) preflight(w http.ResponseWriter, r *http.Request) {
 		SendNotFound(w)
 		return
 	}
-	user, err := GetUserRepository().GetByEmail(m.Email)
+	user, err := router.repos.User.GetByEmail(m.Email)
 	if err != nil {
 		log.Println(err)
 		SendJSON(w, res)
@@ -250,7 +290,7 @@ func (router *AuthRouter) loginPassword(w http.ResponseWriter, r *http.Request)
 		SendBadRequest(w)
 		return
 	}
-	user, err := GetUserRepository().GetByEmail(m.Email)
+	user, err := router.repos.User.GetByEmail(m.Email)
 	if err != nil {
 		SendNotFound(w)
 		return
@@ -263,12 +303,16 @@ func (router *AuthRouter) loginPassword(w http.ResponseWriter, r *http.Request)
 		SendNotFound(w)
 		return
 	}
-	if !GetUserRepository().CheckPassword(string(user.HashedPassword), m.Password) {
-		GetAuthAttemptRepository().RecordLoginAttempt(user, false)
+	if !router.repos.User.CheckPassword(string(user.HashedPassword), m.Password) {
+		router.repos.AuthAttempt.RecordLoginAttempt(user, false)
 		SendNotFound(w)
 		return
 	}
-	GetAuthAttemptRepository().RecordLoginAttempt(user, true)
+	if router.repos.UserTOTP.IsEnabled(user.ID) || router.isTOTPRequired(user) {
+		router.sendTOTPChallenge(w, user, m.LongLived)
+		return
+	}
+	router.repos.AuthAttempt.RecordLoginAttempt(user, true)
 	claims := router.createClaims(user)
 	accessToken := router.createAccessToken(claims)
 	refreshToken := router.createRefreshToken(claims, m.LongLived)
@@ -279,9 +323,141 @@ func (router *AuthRouter) loginPassword(w http.ResponseWriter, r *http.Request)
 	SendJSON(w, res)
 }
 
+// isTOTPRequired returns true if the user's organization enforces TOTP for the user's role.
+func (router *AuthRouter) isTOTPRequired(user *User) bool {
+	if !router.repos.User.isSpaceAdmin(user) {
+		return false
+	}
+	enforce, _ := router.repos.Settings.GetBool(user.OrganizationID, SettingEnforceTOTPAdmins.Name)
+	return enforce
+}
+
+// sendTOTPChallenge starts the second login step. The password check is only recorded
+// as a successful login attempt once the code was verified, so failed codes count towards a ban.
+func (router *AuthRouter) sendTOTPChallenge(w http.ResponseWriter, user *User, longLived bool) {
+	res := &AuthTOTPChallengeResponse{
+		RequireOTP: true,
+	}
+	if !router.repos.UserTOTP.IsEnabled(user.ID) {
+		key, err := router.repos.UserTOTP.CreateSecret(user)
+		if err != nil {
+			log.Println(err)
+			SendInternalServerError(w)
+			return
+		}
+		res.RequireEnrollment = true
+		res.EnrollmentSecret = key.Secret()
+		res.EnrollmentURL = key.URL()
+	}
+	payload := &AuthStateLoginPayload{
+		UserID:    user.ID,
+		LongLived: longLived,
+	}
+	authState := &AuthState{
+		AuthProviderID: router.repos.Settings.getNullUUID(),
+		Expiry:         time.Now().Add(TOTPChallengeLifetime),
+		AuthStateType:  AuthTOTPChallenge,
+		Payload:        marshalAuthStateLoginPayload(payload),
+	}
+	if err := router.repos.AuthState.Create(authState); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	res.ChallengeID = authState.ID
+	SendJSON(w, res)
+}
+
+// loginTOTP completes a password login with a TOTP or recovery code. Users enrolling
+// during login confirm their new secret with the code and receive their recovery codes.
+func (router *AuthRouter) loginTOTP(w http.ResponseWriter, r *http.Request) {
+	var m AuthTOTPRequest
+	if UnmarshalValidateBody(r, &m) != nil {
+		SendBadRequest(w)
+		return
+	}
+	authState, err := router.repos.AuthState.GetOne(m.ChallengeID)
+	if err != nil || authState.AuthStateType != AuthTOTPChallenge || authState.Expiry.Before(time.Now()) {
+		SendNotFound(w)
+		return
+	}
+	payload := unmarshalAuthStateLoginPayload(authState.Payload)
+	user, err := router.repos.User.GetOne(payload.UserID)
+	if err != nil || user.Disabled {
+		SendNotFound(w)
+		return
+	}
+	if router.isTOTPChallengeExhausted(authState, user) {
+		router.repos.AuthState.Delete(authState)
+		SendNotFound(w)
+		return
+	}
+	userTOTP, err := router.repos.UserTOTP.GetOne(user.ID)
+	if err != nil {
+		SendNotFound(w)
+		return
+	}
+	valid, err := router.repos.UserTOTP.CheckCode(userTOTP, m.Code)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	if !valid && userTOTP.Enabled {
+		valid, err = router.repos.UserTOTP.UseRecoveryCode(user.ID, m.Code)
+		if err != nil {
+			log.Println(err)
+			SendInternalServerError(w)
+			return
+		}
+	}
+	if !valid {
+		router.repos.AuthAttempt.RecordLoginAttempt(user, false)
+		if router.isTOTPChallengeExhausted(authState, user) {
+			router.repos.AuthState.Delete(authState)
+		}
+		SendNotFound(w)
+		return
+	}
+	res := &AuthTOTPResponse{}
+	if !userTOTP.Enabled {
+		userTOTP.Enabled = true
+		if err := router.repos.UserTOTP.Set(userTOTP); err != nil {
+			log.Println(err)
+			SendInternalServerError(w)
+			return
+		}
+		res.RecoveryCodes, err = router.repos.UserTOTP.CreateRecoveryCodes(user.ID)
+		if err != nil {
+			log.Println(err)
+			SendInternalServerError(w)
+			return
+		}
+	}
+	router.repos.AuthState.Delete(authState)
+	router.repos.AuthAttempt.RecordLoginAttempt(user, true)
+	claims := router.createClaims(user)
+	res.AccessToken = router.createAccessToken(claims)
+	res.RefreshToken = router.createRefreshToken(claims, payload.LongLived)
+	res.LongLived = payload.LongLived
+	SendJSON(w, res)
+}
+
+// isTOTPChallengeExhausted returns true if the user entered TOTPMaxFailedAttempts wrong
+// codes since the challenge was created.
+func (router *AuthRouter) isTOTPChallengeExhausted(authState *AuthState, user *User) bool {
+	created := authState.Expiry.Add(-TOTPChallengeLifetime)
+	numFailedLogins, err := router.repos.AuthAttempt.GetNumFailedLogins(user.ID, created)
+	if err != nil {
+		log.Println(err)
+		return true
+	}
+	return numFailedLogins >= TOTPMaxFailedAttempts
+}
+
 func (router *AuthRouter) handleAtlassianVerify(authState *AuthState, w http.ResponseWriter) {
 	payload := unmarshalAuthStateLoginPayload(authState.Payload)
-	user, err := GetUserRepository().GetByAtlassianID(payload.UserID)
+	user, err := router.repos.User.GetByAtlassianID(payload.UserID)
 	if err != nil {
 		SendNotFound(w)
 		return
@@ -290,8 +466,8 @@ func (router *AuthRouter) handleAtlassianVerify(authState *AuthState, w http.Res
 		SendNotFound(w)
 		return
 	}
-	GetAuthStateRepository().Delete(authState)
-	GetAuthAttemptRepository().RecordLoginAttempt(user, true)
+	router.repos.AuthState.Delete(authState)
+	router.repos.AuthAttempt.RecordLoginAttempt(user, true)
 	claims := router.createClaims(user)
 	accessToken := router.createAccessToken(claims)
 	refreshToken := router.createRefreshToken(claims, payload.LongLived)
@@ -304,7 +480,7 @@ func (router *AuthRouter) handleAtlassianVerify(authState *AuthState, w http.Res
 
 func (router *AuthRouter) verify(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	authState, err := GetAuthStateRepository().GetOne(vars["id"])
+	authState, err := router.repos.AuthState.GetOne(vars["id"])
 	if err != nil {
 		SendNotFound(w)
 		return
@@ -317,21 +493,21 @@ func (router *AuthRouter) verify(w http.ResponseWriter, r *http.Request) {
 		SendNotFound(w)
 		return
 	}
-	provider, err := GetAuthProviderRepository().GetOne(authState.AuthProviderID)
+	provider, err := router.repos.AuthProvider.GetOne(authState.AuthProviderID)
 	if err != nil {
 		SendNotFound(w)
 		return
 	}
 	payload := unmarshalAuthStateLoginPayload(authState.Payload)
-	user, err := GetUserRepository().GetByEmail(payload.UserID)
+	user, err := router.repos.User.GetByEmail(payload.UserID)
 	// TODO Change email to auth server ID???
 	if err != nil {
-		org, err := GetOrganizationRepository().GetOne(provider.OrganizationID)
+		org, err := router.repos.Organization.GetOne(provider.OrganizationID)
 		if err != nil {
 			SendInternalServerError(w)
 			return
 		}
-		if !GetUserRepository().canCreateUser(org) {
+		if !router.repos.User.canCreateUser(org) {
 			SendPaymentRequired(w)
 			return
 		}
@@ -339,8 +515,12 @@ func (router *AuthRouter) verify(w http.ResponseWriter, r *http.Request) {
 			Email:          payload.UserID,
 			OrganizationID: org.ID,
 			Role:           UserRoleUser,
+			DisplayName:    payload.Name,
+		}
+		if payload.Role != nil {
+			user.Role = *payload.Role
 		}
-		GetUserRepository().Create(user)
+		router.repos.User.Create(user)
 	}
 	if user.OrganizationID != provider.OrganizationID {
 		SendBadRequest(w)
@@ -350,8 +530,11 @@ func (router *AuthRouter) verify(w http.ResponseWriter, r *http.Request) {
 		SendNotFound(w)
 		return
 	}
-	GetAuthStateRepository().Delete(authState)
-	GetAuthAttemptRepository().RecordLoginAttempt(user, true)
+	if err := router.applyUserInfo(user, payload); err != nil {
+		log.Println(err)
+	}
+	router.repos.AuthState.Delete(authState)
+	router.repos.AuthAttempt.RecordLoginAttempt(user, true)
 	claims := router.createClaims(user)
 	accessToken := router.createAccessToken(claims)
 	refreshToken := router.createRefreshToken(claims, payload.LongLived)
@@ -370,7 +553,7 @@ func (router *AuthRouter) login(w http.ResponseWriter, r *http.Request) {
 		SendBadRequest(w)
 		return
 	}
-	provider, err := GetAuthProviderRepository().GetOne(vars["id"])
+	provider, err := router.repos.AuthProvider.GetOne(vars["id"])
 	if err != nil {
 		SendTemporaryRedirect(w, router.getRedirectFailedUrl(loginType))
 		return
@@ -380,56 +563,91 @@ func (router *AuthRouter) login(w http.ResponseWriter, r *http.Request) {
 		longLived = true
 	}
 	redir := r.URL.Query().Get("redir")
-	config := router.getConfig(provider)
 	payload := &AuthStateLoginPayload{
 		LoginType: loginType,
 		UserID:    "",
 		LongLived: longLived, // TODO
 		Redirect:  redir,
 	}
+	if AuthProviderType(provider.ProviderType) == SAML {
+		router.loginSAML(w, r, provider, payload)
+		return
+	}
+	config := router.getConfig(provider)
+	var opts []oauth2.AuthCodeOption
+	if AuthProviderType(provider.ProviderType) == OIDC {
+		payload.CodeVerifier = oauth2.GenerateVerifier()
+		payload.Nonce = uuid.New().String()
+		opts = append(opts, oauth2.S256ChallengeOption(payload.CodeVerifier), oidc.Nonce(payload.Nonce))
+	}
 	authState := &AuthState{
 		AuthProviderID: provider.ID,
 		Expiry:         time.Now().Add(time.Minute * 5),
 		AuthStateType:  AuthRequestState,
 		Payload:        marshalAuthStateLoginPayload(payload),
 	}
-	if err := GetAuthStateRepository().Create(authState); err != nil {
+	if err := router.repos.AuthState.Create(authState); err != nil {
 		SendTemporaryRedirect(w, router.getRedirectFailedUrl(loginType))
 		return
 	}
-	url := config.AuthCodeURL(authState.ID)
+	url := config.AuthCodeURL(authState.ID, opts...)
 	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 }
 
 func (router *AuthRouter) callback(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	provider, err := GetAuthProviderRepository().GetOne(vars["id"])
+	provider, err := router.repos.AuthProvider.GetOne(vars["id"])
 	if err != nil {
 		SendTemporaryRedirect(w, router.getRedirectFailedUrl("ui"))
 		return
 	}
-	claims, payload, err := router.getUserInfo(provider, r.FormValue("state"), r.FormValue("code"))
+	var userInfo *AuthUserInfo
+	var payload *AuthStateLoginPayload
+	if AuthProviderType(provider.ProviderType) == SAML {
+		userInfo, payload, err = router.getSAMLUserInfo(provider, r)
+	} else {
+		userInfo, payload, err = router.getUserInfo(provider, r.FormValue("state"), r.FormValue("code"))
+	}
 	if err != nil {
 		log.Println(err)
-		SendTemporaryRedirect(w, router.getRedirectFailedUrl(payload.LoginType))
+		loginType := "ui"
+		if payload != nil {
+			loginType = payload.LoginType
+		}
+		SendTemporaryRedirect(w, router.getRedirectFailedUrl(loginType))
 		return
 	}
-	if !router.isValidEmailForOrg(provider, claims.Email) {
+	if !router.isValidEmailForOrg(provider, userInfo.Email) {
 		SendTemporaryRedirect(w, router.getRedirectFailedUrl(payload.LoginType))
 		return
 	}
-	allowAnyUser, _ := GetSettingsRepository().GetBool(provider.OrganizationID, SettingAllowAnyUser.Name)
+	allowAnyUser, _ := router.repos.Settings.GetBool(provider.OrganizationID, SettingAllowAnyUser.Name)
 	if !allowAnyUser {
-		_, err := GetUserRepository().GetByEmail(claims.Email)
+		_, err := router.repos.User.GetByEmail(userInfo.Email)
 		if err != nil {
 			SendTemporaryRedirect(w, router.getRedirectFailedUrl(payload.LoginType))
 			return
 		}
 	}
 	payloadNew := &AuthStateLoginPayload{
-		UserID:    claims.Email,
+		UserID:    userInfo.Email,
 		LoginType: payload.LoginType,
 		LongLived: payload.LongLived,
+		Name:      userInfo.Name,
+		Groups:    userInfo.Groups,
+	}
+	// Without a groups claim the role is kept, as a misconfigured provider would demote
+	// everyone otherwise. An empty list demotes users removed from all mapped groups.
+	if userInfo.Groups != nil {
+		role, ok, err := router.repos.AuthProviderRoleMapping.GetMappedRole(provider.ID, userInfo.Groups)
+		if err != nil {
+			log.Println(err)
+			SendTemporaryRedirect(w, router.getRedirectFailedUrl(payload.LoginType))
+			return
+		}
+		if ok {
+			payloadNew.Role = &role
+		}
 	}
 	authState := &AuthState{
 		AuthProviderID: provider.ID,
@@ -437,7 +655,7 @@ func (router *AuthRouter) callback(w http.ResponseWriter, r *http.Request) {
 		AuthStateType:  AuthResponseCache,
 		Payload:        marshalAuthStateLoginPayload(payloadNew),
 	}
-	if err := GetAuthStateRepository().Create(authState); err != nil {
+	if err := router.repos.AuthState.Create(authState); err != nil {
 		log.Println(err)
 		SendTemporaryRedirect(w, router.getRedirectFailedUrl(payload.LoginType))
 		return
@@ -449,6 +667,65 @@ func (router *AuthRouter) callback(w http.ResponseWriter, r *http.Request) {
 	SendTemporaryRedirect(w, redirectUrl)
 }
 
+// loginSAML redirects to the identity provider with a signed AuthnRequest. The
+// request's ID is kept in the auth state so that only a response to it is accepted.
+func (router *AuthRouter) loginSAML(w http.ResponseWriter, r *http.Request, provider *AuthProvider, payload *AuthStateLoginPayload) {
+	sp, err := getSAMLServiceProvider(provider)
+	if err != nil {
+		log.Println(err)
+		SendTemporaryRedirect(w, router.getRedirectFailedUrl(payload.LoginType))
+		return
+	}
+	req, err := sp.MakeAuthenticationRequest(provider.AuthURL, saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		log.Println(err)
+		SendTemporaryRedirect(w, router.getRedirectFailedUrl(payload.LoginType))
+		return
+	}
+	payload.Nonce = req.ID
+	authState := &AuthState{
+		AuthProviderID: provider.ID,
+		Expiry:         time.Now().Add(time.Minute * 5),
+		AuthStateType:  AuthRequestState,
+		Payload:        marshalAuthStateLoginPayload(payload),
+	}
+	if err := router.repos.AuthState.Create(authState); err != nil {
+		SendTemporaryRedirect(w, router.getRedirectFailedUrl(payload.LoginType))
+		return
+	}
+	redirectURL, err := req.Redirect(authState.ID, sp)
+	if err != nil {
+		log.Println(err)
+		SendTemporaryRedirect(w, router.getRedirectFailedUrl(payload.LoginType))
+		return
+	}
+	http.Redirect(w, r, redirectURL.String(), http.StatusTemporaryRedirect)
+}
+
+func (router *AuthRouter) samlMetadata(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	provider, err := router.repos.AuthProvider.GetOne(vars["id"])
+	if err != nil || AuthProviderType(provider.ProviderType) != SAML {
+		SendNotFound(w)
+		return
+	}
+	sp, err := getSAMLServiceProvider(provider)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	metadata, err := xml.MarshalIndent(sp.Metadata(), "", "  ")
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(metadata)
+}
+
 func (router *AuthRouter) getRedirectSuccessUrl(loginType string, authState *AuthState) string {
 	if loginType == "ui" {
 		return GetConfig().FrontendURL + "ui/login/success/" + authState.ID
@@ -466,56 +743,240 @@ func (router *AuthRouter) getRedirectFailedUrl(loginType string) string {
 }
 
 func (router *AuthRouter) isValidEmailForOrg(provider *AuthProvider, email string) bool {
-	org, err := GetOrganizationRepository().GetOne(provider.OrganizationID)
+	org, err := router.repos.Organization.GetOne(provider.OrganizationID)
 	if err != nil {
 		return false
 	}
-	return GetOrganizationRepository().isValidEmailForOrg(email, org)
+	return router.repos.Organization.isValidEmailForOrg(email, org)
 }
 
-func (router *AuthRouter) getUserInfo(provider *AuthProvider, state string, code string) (*Claims, *AuthStateLoginPayload, error) {
+func (router *AuthRouter) getUserInfo(provider *AuthProvider, state string, code string) (*AuthUserInfo, *AuthStateLoginPayload, error) {
 	// Verify state string
-	authState, err := GetAuthStateRepository().GetOne(state)
+	authState, err := router.repos.AuthState.GetOne(state)
 	if err != nil {
 		return nil, nil, fmt.Errorf("state not found for id %s", strings.Replace(strings.Replace(state, "\r", "", -1), "\n", "", -1))
 	}
 	if authState.AuthProviderID != provider.ID {
 		return nil, nil, fmt.Errorf("auth providers don't match")
 	}
-	defer GetAuthStateRepository().Delete(authState)
+	defer router.repos.AuthState.Delete(authState)
+	payload := unmarshalAuthStateLoginPayload(authState.Payload)
 	// Exchange authorization code for an access token
 	config := router.getConfig(provider)
-	token, err := config.Exchange(context.Background(), code)
+	var opts []oauth2.AuthCodeOption
+	if payload.CodeVerifier != "" {
+		opts = append(opts, oauth2.VerifierOption(payload.CodeVerifier))
+	}
+	token, err := config.Exchange(context.Background(), code, opts...)
+	if err != nil {
+		return nil, payload, fmt.Errorf("code exchange failed: %s", err.Error())
+	}
+	var claims map[string]interface{}
+	if AuthProviderType(provider.ProviderType) == OIDC {
+		claims, err = router.getIDTokenClaims(provider, token, payload.Nonce)
+	} else {
+		claims, err = router.getUserInfoClaims(provider, token)
+	}
+	if err != nil {
+		return nil, payload, err
+	}
+	userInfo, err := router.getUserInfoFromClaims(provider, claims)
 	if err != nil {
-		return nil, nil, fmt.Errorf("code exchange failed: %s", err.Error())
+		return nil, payload, err
 	}
+	return userInfo, payload, nil
+}
+
+// getSAMLUserInfo validates the SAML response posted by the identity provider. The
+// assertion must be signed by the identity provider, be addressed to this service
+// provider and answer the AuthnRequest stored in the auth state named by RelayState.
+func (router *AuthRouter) getSAMLUserInfo(provider *AuthProvider, r *http.Request) (*AuthUserInfo, *AuthStateLoginPayload, error) {
+	state := r.FormValue("RelayState")
+	authState, err := router.repos.AuthState.GetOne(state)
+	if err != nil {
+		return nil, nil, fmt.Errorf("state not found for id %s", strings.Replace(strings.Replace(state, "\r", "", -1), "\n", "", -1))
+	}
+	if authState.AuthProviderID != provider.ID {
+		return nil, nil, fmt.Errorf("auth providers don't match")
+	}
+	defer router.repos.AuthState.Delete(authState)
+	payload := unmarshalAuthStateLoginPayload(authState.Payload)
+	sp, err := getSAMLServiceProvider(provider)
+	if err != nil {
+		return nil, payload, err
+	}
+	assertion, err := sp.ParseResponse(r, []string{payload.Nonce})
+	if err != nil {
+		if invalidErr, ok := err.(*saml.InvalidResponseError); ok {
+			err = invalidErr.PrivateErr
+		}
+		return nil, payload, fmt.Errorf("saml response validation failed: %s", err.Error())
+	}
+	// Attributes are mapped like OAuth claims, an empty email field takes the subject's NameID
+	claims := map[string]interface{}{}
+	if assertion.Subject != nil && assertion.Subject.NameID != nil {
+		claims[""] = assertion.Subject.NameID.Value
+	}
+	for _, statement := range assertion.AttributeStatements {
+		for _, attr := range statement.Attributes {
+			values := []interface{}{}
+			for _, value := range attr.Values {
+				values = append(values, value.Value)
+			}
+			for _, name := range []string{attr.Name, attr.FriendlyName} {
+				if name == "" {
+					continue
+				}
+				if len(values) == 1 && name != provider.ClaimGroups {
+					claims[name] = values[0]
+				} else {
+					claims[name] = values
+				}
+			}
+		}
+	}
+	userInfo, err := router.getUserInfoFromClaims(provider, claims)
+	if err != nil {
+		return nil, payload, err
+	}
+	return userInfo, payload, nil
+}
+
+// getIDTokenClaims validates the ID token returned with the access token against
+// the issuer's signing keys, the client ID and the nonce sent with the login request.
+// Claims are only taken from the validated ID token.
+func (router *AuthRouter) getIDTokenClaims(provider *AuthProvider, token *oauth2.Token, nonce string) (map[string]interface{}, error) {
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("token response contains no id_token")
+	}
+	oidcProvider, err := getOIDCProvider(provider.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading openid configuration: %s", err.Error())
+	}
+	verifier := oidcProvider.Verifier(&oidc.Config{ClientID: provider.ClientID})
+	idToken, err := verifier.Verify(context.Background(), rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("id token verification failed: %s", err.Error())
+	}
+	if nonce == "" || idToken.Nonce != nonce {
+		return nil, fmt.Errorf("id token nonce does not match")
+	}
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed reading id token claims: %s", err.Error())
+	}
+	if verified, ok := claims["email_verified"].(bool); ok && !verified {
+		return nil, fmt.Errorf("email address is not verified")
+	}
+	return claims, nil
+}
+
+func (router *AuthRouter) getUserInfoClaims(provider *AuthProvider, token *oauth2.Token) (map[string]interface{}, error) {
 	// Get user info from resource server
 	client := &http.Client{}
 	req, err := http.NewRequest("GET", provider.UserInfoURL, nil)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed creating http request: %s", err.Error())
+		return nil, fmt.Errorf("failed creating http request: %s", err.Error())
 	}
 	req.Header.Add("Authorization", "Bearer "+token.AccessToken)
 	response, err := client.Do(req)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed getting user info: %s", err.Error())
+		return nil, fmt.Errorf("failed getting user info: %s", err.Error())
 	}
 	defer response.Body.Close()
 	contents, err := io.ReadAll(response.Body)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed reading response body: %s", err.Error())
+		return nil, fmt.Errorf("failed reading response body: %s", err.Error())
 	}
-	// Extract email address from JSON response
 	var result map[string]interface{}
 	json.Unmarshal([]byte(contents), &result)
-	if (result[provider.UserInfoEmailField] == nil) || (strings.TrimSpace(result[provider.UserInfoEmailField].(string)) == "") {
-		return nil, nil, fmt.Errorf("could not read email address from field: %s", provider.UserInfoEmailField)
+	return result, nil
+}
+
+func (router *AuthRouter) getUserInfoFromClaims(provider *AuthProvider, claims map[string]interface{}) (*AuthUserInfo, error) {
+	// Extract email address from JSON response
+	email, _ := claims[provider.UserInfoEmailField].(string)
+	if strings.TrimSpace(email) == "" {
+		return nil, fmt.Errorf("could not read email address from field: %s", provider.UserInfoEmailField)
+	}
+	userInfo := &AuthUserInfo{
+		Email: email,
+	}
+	if provider.ClaimName != "" {
+		userInfo.Name, _ = claims[provider.ClaimName].(string)
+	}
+	if provider.ClaimGroups != "" {
+		switch groups := claims[provider.ClaimGroups].(type) {
+		case []interface{}:
+			userInfo.Groups = []string{}
+			for _, group := range groups {
+				if s, ok := group.(string); ok {
+					userInfo.Groups = append(userInfo.Groups, s)
+				}
+			}
+		case string:
+			userInfo.Groups = []string{groups}
+		}
 	}
-	claims := &Claims{
-		Email: result[provider.UserInfoEmailField].(string),
+	return userInfo, nil
+}
+
+// applyUserInfo updates the user with the attributes received from the identity provider.
+// Roles mapped from the provider's claims promote and demote the user, except for super admins.
+func (router *AuthRouter) applyUserInfo(user *User, payload *AuthStateLoginPayload) error {
+	changed := false
+	if payload.Name != "" && payload.Name != user.DisplayName {
+		user.DisplayName = payload.Name
+		changed = true
+	}
+	if payload.Role != nil && *payload.Role != user.Role && user.Role < UserRoleSuperAdmin {
+		user.Role = *payload.Role
+		changed = true
+	}
+	if changed {
+		if err := router.repos.User.Update(user); err != nil {
+			return err
+		}
 	}
-	payload := unmarshalAuthStateLoginPayload(authState.Payload)
-	return claims, payload, nil
+	if payload.Groups != nil {
+		return router.syncUserGroups(user, payload.Groups)
+	}
+	return nil
+}
+
+// syncUserGroups makes the user a member of exactly those groups of the organization
+// whose external ID or name is contained in the identity provider's groups claim.
+func (router *AuthRouter) syncUserGroups(user *User, groups []string) error {
+	wanted := make(map[string]*UserGroup)
+	for _, name := range groups {
+		group, err := router.repos.UserGroup.GetByExternalID(user.OrganizationID, name)
+		if err != nil {
+			group, err = router.repos.UserGroup.GetByName(user.OrganizationID, name)
+		}
+		if err == nil {
+			wanted[group.ID] = group
+		}
+	}
+	current, err := router.repos.UserGroup.GetAllByUser(user.ID)
+	if err != nil {
+		return err
+	}
+	for _, group := range current {
+		if _, ok := wanted[group.ID]; ok {
+			delete(wanted, group.ID)
+			continue
+		}
+		if err := router.repos.UserGroup.RemoveMembers(group, []string{user.ID}); err != nil {
+			return err
+		}
+	}
+	for _, group := range wanted {
+		if err := router.repos.UserGroup.AddMembers(group, []string{user.ID}); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (router *AuthRouter) SendPasswordResetEmail(user *User, ID string, org *Organization) error {
@@ -551,8 +1012,8 @@ func (router *AuthRouter) createClaims(user *User) *Claims {
 	claims := &Claims{
 		UserID:     user.ID,
 		Email:      user.Email,
-		SpaceAdmin: GetUserRepository().isSpaceAdmin(user),
-		OrgAdmin:   GetUserRepository().isOrgAdmin(user),
+		SpaceAdmin: router.repos.User.isSpaceAdmin(user),
+		OrgAdmin:   router.repos.User.isOrgAdmin(user),
 		Role:       int(user.Role),
 	}
 	return claims
@@ -582,7 +1043,7 @@ func (router *AuthRouter) createRefreshToken(claims *Claims, longLived bool) str
 		Expiry:  expiry,
 		Created: time.Now(),
 	}
-	GetRefreshTokenRepository().Create(refreshToken)
+	router.repos.RefreshToken.Create(refreshToken)
 	return refreshToken.ID
 }
 
@@ -592,7 +1053,7 @@ func (router *AuthRouter) getOrgForEmail(email string) *Organization {
 		return nil
 	}
 	domain := strings.ToLower(mailParts[1])
-	org, err := GetOrganizationRepository().GetOneByDomain(domain)
+	org, err := router.repos.Organization.GetOneByDomain(domain)
 	if err != nil {
 		log.Println(err)
 		return nil
@@ -601,7 +1062,7 @@ func (router *AuthRouter) getOrgForEmail(email string) *Organization {
 }
 
 func (router *AuthRouter) getPreflightResponseForOrg(org *Organization) *AuthPreflightResponse {
-	list, err := GetAuthProviderRepository().GetAll(org.ID)
+	list, err := router.repos.AuthProvider.GetAll(org.ID)
 	if err != nil {
 		return nil
 	}
@@ -2,14 +2,27 @@ package main
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/xml"
+	"math/big"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/crewjam/saml"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
+	"github.com/pquerna/otp/totp"
 )
 
 func TestAuthPasswordLogin(t *testing.T) {
@@ -17,8 +30,8 @@ func TestAuthPasswordLogin(t *testing.T) {
 
 	org := createTestOrg("test.com")
 	user := createTestUserInOrg(org)
-	user.HashedPassword = NullString(GetUserRepository().GetHashedPassword("12345678"))
-	GetUserRepository().Update(user)
+	user.HashedPassword = NullString(testRepositories.User.GetHashedPassword("12345678"))
+	testRepositories.User.Update(user)
 
 	// Log in
 	payload := "{ \"email\": \"" + user.Email + "\", \"password\": \"12345678\" }"
@@ -44,8 +57,8 @@ func TestAuthPasswordLoginBan(t *testing.T) {
 
 	org := createTestOrg("test.com")
 	user := createTestUserInOrg(org)
-	user.HashedPassword = NullString(GetUserRepository().GetHashedPassword("12345678"))
-	GetUserRepository().Update(user)
+	user.HashedPassword = NullString(testRepositories.User.GetHashedPassword("12345678"))
+	testRepositories.User.Update(user)
 
 	// Attempt 1
 	payload := "{ \"email\": \"" + user.Email + "\", \"password\": \"12345670\" }"
@@ -76,13 +89,230 @@ func TestAuthPasswordLoginBan(t *testing.T) {
 	checkTestBool(t, true, authAttemptRepositoryIsUserDisabled(t, user.ID))
 }
 
+func TestAuthPasswordLoginTOTP(t *testing.T) {
+	clearTestDB()
+
+	org := createTestOrg("test.com")
+	user := createTestUserInOrg(org)
+	user.HashedPassword = NullString(testRepositories.User.GetHashedPassword("12345678"))
+	testRepositories.User.Update(user)
+
+	// Enroll
+	req := newHTTPRequest("POST", "/user/me/totp", user.ID, nil)
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var enrollment *CreateTOTPResponse
+	json.Unmarshal(res.Body.Bytes(), &enrollment)
+	checkStringNotEmpty(t, enrollment.Secret)
+	checkTestBool(t, true, strings.HasPrefix(enrollment.URL, "otpauth://totp/"))
+
+	// Not enabled before confirmation
+	payload := "{ \"email\": \"" + user.Email + "\", \"password\": \"12345678\" }"
+	req = newHTTPRequest("POST", "/auth/login", "", bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var loginRes *AuthTOTPChallengeResponse
+	json.Unmarshal(res.Body.Bytes(), &loginRes)
+	checkTestBool(t, false, loginRes.RequireOTP)
+
+	req = newHTTPRequest("POST", "/user/me/totp/verify", user.ID, bytes.NewBufferString(`{"code": "000000"}`))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+
+	code, _ := totp.GenerateCode(enrollment.Secret, time.Now())
+	req = newHTTPRequest("POST", "/user/me/totp/verify", user.ID, bytes.NewBufferString(`{"code": "`+code+`"}`))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var recovery *TOTPRecoveryCodesResponse
+	json.Unmarshal(res.Body.Bytes(), &recovery)
+	checkTestInt(t, TOTPNumRecoveryCodes, len(recovery.RecoveryCodes))
+
+	req = newHTTPRequest("GET", "/user/me/totp", user.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var status *GetTOTPResponse
+	json.Unmarshal(res.Body.Bytes(), &status)
+	checkTestBool(t, true, status.Enabled)
+	checkTestBool(t, false, status.Required)
+	checkTestInt(t, TOTPNumRecoveryCodes, status.RecoveryCodesLeft)
+
+	// Password step returns a challenge instead of tokens
+	req = newHTTPRequest("POST", "/auth/login", "", bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	json.Unmarshal(res.Body.Bytes(), &loginRes)
+	checkTestBool(t, true, loginRes.RequireOTP)
+	checkTestBool(t, false, loginRes.RequireEnrollment)
+	checkStringNotEmpty(t, loginRes.ChallengeID)
+	checkTestBool(t, false, strings.Contains(res.Body.String(), "accessToken"))
+
+	// Code used for enrollment can't be replayed
+	req = newHTTPRequest("POST", "/auth/login/otp", "", bytes.NewBufferString(`{"challengeId": "`+loginRes.ChallengeID+`", "code": "`+code+`"}`))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNotFound, res.Code)
+
+	code, _ = totp.GenerateCode(enrollment.Secret, time.Now().Add(time.Duration(TOTPPeriod)*time.Second))
+	req = newHTTPRequest("POST", "/auth/login/otp", "", bytes.NewBufferString(`{"challengeId": "`+loginRes.ChallengeID+`", "code": "`+code+`"}`))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody *AuthTOTPResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestBool(t, true, len(resBody.AccessToken) > 32)
+	checkTestBool(t, true, len(resBody.RefreshToken) == 36)
+
+	// Challenge is consumed
+	req = newHTTPRequest("POST", "/auth/login/otp", "", bytes.NewBufferString(`{"challengeId": "`+loginRes.ChallengeID+`", "code": "`+code+`"}`))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNotFound, res.Code)
+
+	// Recovery codes work once
+	for i, expected := range []int{http.StatusOK, http.StatusNotFound} {
+		req = newHTTPRequest("POST", "/auth/login", "", bytes.NewBufferString(payload))
+		res = executeTestRequest(req)
+		checkTestResponseCode(t, http.StatusOK, res.Code)
+		json.Unmarshal(res.Body.Bytes(), &loginRes)
+		req = newHTTPRequest("POST", "/auth/login/otp", "", bytes.NewBufferString(`{"challengeId": "`+loginRes.ChallengeID+`", "code": "`+recovery.RecoveryCodes[0]+`"}`))
+		res = executeTestRequest(req)
+		checkTestResponseCode(t, expected, res.Code)
+		if i == 0 {
+			checkTestBool(t, false, authAttemptRepositoryIsUserDisabled(t, user.ID))
+		}
+	}
+
+	// Disable
+	req = newHTTPRequest("DELETE", "/user/me/totp", user.ID, bytes.NewBufferString(`{"code": "`+recovery.RecoveryCodes[1]+`"}`))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+	checkTestBool(t, false, testRepositories.UserTOTP.IsEnabled(user.ID))
+}
+
+func TestAuthPasswordLoginTOTPFailedAttempts(t *testing.T) {
+	clearTestDB()
+	// Make sure the user isn't banned before the challenge is invalidated
+	maxFails := GetConfig().LoginProtectionMaxFails
+	GetConfig().LoginProtectionMaxFails = TOTPMaxFailedAttempts + 2
+	defer func() { GetConfig().LoginProtectionMaxFails = maxFails }()
+
+	org := createTestOrg("test.com")
+	user := createTestUserInOrg(org)
+	user.HashedPassword = NullString(testRepositories.User.GetHashedPassword("12345678"))
+	testRepositories.User.Update(user)
+
+	req := newHTTPRequest("POST", "/user/me/totp", user.ID, nil)
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var enrollment *CreateTOTPResponse
+	json.Unmarshal(res.Body.Bytes(), &enrollment)
+	code, _ := totp.GenerateCode(enrollment.Secret, time.Now())
+	req = newHTTPRequest("POST", "/user/me/totp/verify", user.ID, bytes.NewBufferString(`{"code": "`+code+`"}`))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+
+	payload := "{ \"email\": \"" + user.Email + "\", \"password\": \"12345678\" }"
+	req = newHTTPRequest("POST", "/auth/login", "", bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var loginRes *AuthTOTPChallengeResponse
+	json.Unmarshal(res.Body.Bytes(), &loginRes)
+
+	for i := 0; i < TOTPMaxFailedAttempts; i++ {
+		req = newHTTPRequest("POST", "/auth/login/otp", "", bytes.NewBufferString(`{"challengeId": "`+loginRes.ChallengeID+`", "code": "000000"}`))
+		res = executeTestRequest(req)
+		checkTestResponseCode(t, http.StatusNotFound, res.Code)
+	}
+
+	// Challenge is invalidated, even for a correct code
+	code, _ = totp.GenerateCode(enrollment.Secret, time.Now().Add(time.Duration(TOTPPeriod)*time.Second))
+	req = newHTTPRequest("POST", "/auth/login/otp", "", bytes.NewBufferString(`{"challengeId": "`+loginRes.ChallengeID+`", "code": "`+code+`"}`))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNotFound, res.Code)
+	_, err := testRepositories.AuthState.GetOne(loginRes.ChallengeID)
+	checkTestBool(t, true, err != nil)
+
+	// A new challenge accepts the code
+	req = newHTTPRequest("POST", "/auth/login", "", bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	json.Unmarshal(res.Body.Bytes(), &loginRes)
+	req = newHTTPRequest("POST", "/auth/login/otp", "", bytes.NewBufferString(`{"challengeId": "`+loginRes.ChallengeID+`", "code": "`+code+`"}`))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+}
+
+func TestAuthPasswordLoginTOTPEnforced(t *testing.T) {
+	clearTestDB()
+
+	org := createTestOrg("test.com")
+	testRepositories.Settings.Set(org.ID, SettingEnforceTOTPAdmins.Name, "1")
+	admin := createTestUserOrgAdmin(org)
+	admin.HashedPassword = NullString(testRepositories.User.GetHashedPassword("12345678"))
+	testRepositories.User.Update(admin)
+	user := createTestUserInOrg(org)
+	user.HashedPassword = NullString(testRepositories.User.GetHashedPassword("12345678"))
+	testRepositories.User.Update(user)
+
+	// Regular users are not affected
+	payload := "{ \"email\": \"" + user.Email + "\", \"password\": \"12345678\" }"
+	req := newHTTPRequest("POST", "/auth/login", "", bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var userRes *JWTResponse
+	json.Unmarshal(res.Body.Bytes(), &userRes)
+	checkTestBool(t, true, len(userRes.AccessToken) > 32)
+
+	// Admins must enroll during login
+	payload = "{ \"email\": \"" + admin.Email + "\", \"password\": \"12345678\" }"
+	req = newHTTPRequest("POST", "/auth/login", "", bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var loginRes *AuthTOTPChallengeResponse
+	json.Unmarshal(res.Body.Bytes(), &loginRes)
+	checkTestBool(t, true, loginRes.RequireOTP)
+	checkTestBool(t, true, loginRes.RequireEnrollment)
+	checkStringNotEmpty(t, loginRes.EnrollmentSecret)
+
+	// Wrong codes count towards a ban
+	req = newHTTPRequest("POST", "/auth/login/otp", "", bytes.NewBufferString(`{"challengeId": "`+loginRes.ChallengeID+`", "code": "000000"}`))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNotFound, res.Code)
+	numFailed, _ := testRepositories.AuthAttempt.GetNumFailedLogins(admin.ID, time.Now().Add(-time.Hour))
+	checkTestInt(t, 1, numFailed)
+
+	code, _ := totp.GenerateCode(loginRes.EnrollmentSecret, time.Now())
+	req = newHTTPRequest("POST", "/auth/login/otp", "", bytes.NewBufferString(`{"challengeId": "`+loginRes.ChallengeID+`", "code": "`+code+`"}`))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody *AuthTOTPResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestBool(t, true, len(resBody.AccessToken) > 32)
+	checkTestInt(t, TOTPNumRecoveryCodes, len(resBody.RecoveryCodes))
+	checkTestBool(t, true, testRepositories.UserTOTP.IsEnabled(admin.ID))
+
+	// Enforced TOTP can't be disabled by the user
+	req = newHTTPRequest("DELETE", "/user/me/totp", admin.ID, bytes.NewBufferString(`{"code": "`+resBody.RecoveryCodes[0]+`"}`))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+	checkTestString(t, strconv.Itoa(ResponseCodeTOTPEnforced), res.Header().Get("X-Error-Code"))
+
+	// Admins can reset users' enrollment, but users can't reset admins'
+	req = newHTTPRequest("DELETE", "/user/"+admin.ID+"/totp", user.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+	testRepositories.UserTOTP.CreateSecret(user)
+	req = newHTTPRequest("DELETE", "/user/"+user.ID+"/totp", admin.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+	_, err := testRepositories.UserTOTP.GetOne(user.ID)
+	checkTestBool(t, true, err != nil)
+}
+
 func TestAuthRefresh(t *testing.T) {
 	clearTestDB()
 
 	org := createTestOrg("test.com")
 	user := createTestUserInOrg(org)
-	user.HashedPassword = NullString(GetUserRepository().GetHashedPassword("12345678"))
-	GetUserRepository().Update(user)
+	user.HashedPassword = NullString(testRepositories.User.GetHashedPassword("12345678"))
+	testRepositories.User.Update(user)
 
 	// Log in
 	payload := "{ \"email\": \"" + user.Email + "\", \"password\": \"12345678\" }"
@@ -123,8 +353,8 @@ func TestAuthRefreshNonExistent(t *testing.T) {
 
 	org := createTestOrg("test.com")
 	user := createTestUserInOrg(org)
-	user.HashedPassword = NullString(GetUserRepository().GetHashedPassword("12345678"))
-	GetUserRepository().Update(user)
+	user.HashedPassword = NullString(testRepositories.User.GetHashedPassword("12345678"))
+	testRepositories.User.Update(user)
 
 	// Refresh access token
 	payload := "{ \"refreshToken\": \"" + uuid.New().String() + "\" }"
@@ -138,8 +368,8 @@ func TestAuthPasswordReset(t *testing.T) {
 
 	org := createTestOrg("test.com")
 	user := createTestUserInOrg(org)
-	user.HashedPassword = NullString(GetUserRepository().GetHashedPassword("12345678"))
-	GetUserRepository().Update(user)
+	user.HashedPassword = NullString(testRepositories.User.GetHashedPassword("12345678"))
+	testRepositories.User.Update(user)
 
 	// Init password reset
 	payload := "{ \"email\": \"" + user.Email + "\" }"
@@ -199,3 +429,500 @@ func TestAuthSingleOrgWithMultipleOrgs(t *testing.T) {
 	res := executeTestRequest(req)
 	checkTestResponseCode(t, http.StatusNotFound, res.Code)
 }
+
+// testOIDCServer is a minimal OpenID Connect provider issuing signed ID tokens.
+type testOIDCServer struct {
+	Server        *httptest.Server
+	Key           *rsa.PrivateKey
+	ClientID      string
+	Nonce         string
+	CodeChallenge string
+	Claims        jwt.MapClaims
+}
+
+func newTestOIDCServer(clientID string) *testOIDCServer {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	s := &testOIDCServer{
+		Key:      key,
+		ClientID: clientID,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                s.Server.URL,
+			"authorization_endpoint":                s.Server.URL + "/authorize",
+			"token_endpoint":                        s.Server.URL + "/token",
+			"userinfo_endpoint":                     s.Server.URL + "/userinfo",
+			"jwks_uri":                              s.Server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		hash := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(hash[:]) != s.CodeChallenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		claims := jwt.MapClaims{
+			"iss":   s.Server.URL,
+			"aud":   s.ClientID,
+			"sub":   "123",
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"nonce": s.Nonce,
+		}
+		for k, v := range s.Claims {
+			claims[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "test"
+		idToken, _ := token.SignedString(key)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+	})
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+func TestAuthOIDCLogin(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	user := createTestUserInOrgWithName(org, "oidc.user@test.com", UserRoleUser)
+	group1 := &UserGroup{OrganizationID: org.ID, Name: "Team A"}
+	testRepositories.UserGroup.Create(group1)
+	group2 := &UserGroup{OrganizationID: org.ID, Name: "Team B"}
+	testRepositories.UserGroup.Create(group2)
+	testRepositories.UserGroup.AddMembers(group2, []string{user.ID})
+
+	// The test server listens on the loopback interface
+	GetConfig().AuthProviderAllowPrivateNetworks = true
+	defer func() { GetConfig().AuthProviderAllowPrivateNetworks = false }()
+	idp := newTestOIDCServer("seatsurfing")
+	defer idp.Server.Close()
+
+	payload := `{"name": "OIDC", "providerType": 2, "issuerUrl": "` + idp.Server.URL + `", "clientId": "seatsurfing", "clientSecret": "secret", "claimGroups": "groups"}`
+	req := newHTTPRequest("POST", "/auth-provider/", admin.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	id := res.Header().Get("X-Object-Id")
+
+	req = newHTTPRequest("GET", "/auth-provider/"+id, admin.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody *GetAuthProviderResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestString(t, idp.Server.URL+"/authorize", resBody.AuthURL)
+	checkTestString(t, idp.Server.URL+"/token", resBody.TokenURL)
+	checkTestString(t, OIDCDefaultScopes, resBody.Scopes)
+	checkTestString(t, "email", resBody.UserInfoEmailField)
+
+	// Start login
+	req = newHTTPRequest("GET", "/auth/"+id+"/login/web", "", nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusTemporaryRedirect, res.Code)
+	redirect, _ := url.Parse(res.Header().Get("Location"))
+	checkTestString(t, "S256", redirect.Query().Get("code_challenge_method"))
+	checkStringNotEmpty(t, redirect.Query().Get("nonce"))
+	idp.Nonce = redirect.Query().Get("nonce")
+	idp.CodeChallenge = redirect.Query().Get("code_challenge")
+	idp.Claims = jwt.MapClaims{
+		"email":          "oidc.user@test.com",
+		"email_verified": true,
+		"name":           "OIDC User",
+		"groups":         []string{"Team A", "Unknown"},
+	}
+
+	// Callback from IdP
+	req = newHTTPRequest("GET", "/auth/"+id+"/callback?state="+redirect.Query().Get("state")+"&code=abc", "", nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusTemporaryRedirect, res.Code)
+	location := res.Header().Get("Location")
+	checkTestBool(t, true, strings.Contains(location, "/admin/login/success/"))
+	authStateID := location[strings.LastIndex(location, "/")+1:]
+
+	req = newHTTPRequest("GET", "/auth/verify/"+authStateID, "", nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody2 *JWTResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody2)
+	checkTestBool(t, true, len(resBody2.AccessToken) > 32)
+
+	// Claims are mapped to user attributes
+	user, _ = testRepositories.User.GetOne(user.ID)
+	checkTestString(t, "OIDC User", user.DisplayName)
+	groups, _ := testRepositories.UserGroup.GetAllByUser(user.ID)
+	checkTestInt(t, 1, len(groups))
+	checkTestString(t, group1.ID, groups[0].ID)
+}
+
+func TestAuthOIDCLoginRoleMapping(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	user := createTestUserInOrgWithName(org, "oidc.user@test.com", UserRoleUser)
+
+	// The test server listens on the loopback interface
+	GetConfig().AuthProviderAllowPrivateNetworks = true
+	defer func() { GetConfig().AuthProviderAllowPrivateNetworks = false }()
+	idp := newTestOIDCServer("seatsurfing")
+	defer idp.Server.Close()
+
+	payload := `{"name": "OIDC", "providerType": 2, "issuerUrl": "` + idp.Server.URL + `", "clientId": "seatsurfing", "clientSecret": "secret", "claimGroups": "groups"}`
+	req := newHTTPRequest("POST", "/auth-provider/", admin.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	id := res.Header().Get("X-Object-Id")
+
+	payload = `{"mappings": [{"claimValue": "facility", "role": 10}, {"claimValue": "admins", "role": 20}]}`
+	req = newHTTPRequest("PUT", "/auth-provider/"+id+"/rolemapping", admin.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	login := func(groups []string) *JWTResponse {
+		req := newHTTPRequest("GET", "/auth/"+id+"/login/web", "", nil)
+		res := executeTestRequest(req)
+		checkTestResponseCode(t, http.StatusTemporaryRedirect, res.Code)
+		redirect, _ := url.Parse(res.Header().Get("Location"))
+		idp.Nonce = redirect.Query().Get("nonce")
+		idp.CodeChallenge = redirect.Query().Get("code_challenge")
+		idp.Claims = jwt.MapClaims{
+			"email": "oidc.user@test.com",
+		}
+		if groups != nil {
+			idp.Claims["groups"] = groups
+		}
+		req = newHTTPRequest("GET", "/auth/"+id+"/callback?state="+redirect.Query().Get("state")+"&code=abc", "", nil)
+		res = executeTestRequest(req)
+		checkTestResponseCode(t, http.StatusTemporaryRedirect, res.Code)
+		location := res.Header().Get("Location")
+		req = newHTTPRequest("GET", "/auth/verify/"+location[strings.LastIndex(location, "/")+1:], "", nil)
+		res = executeTestRequest(req)
+		checkTestResponseCode(t, http.StatusOK, res.Code)
+		var resBody *JWTResponse
+		json.Unmarshal(res.Body.Bytes(), &resBody)
+		return resBody
+	}
+
+	// Promotion
+	login([]string{"facility", "admins"})
+	user, _ = testRepositories.User.GetOne(user.ID)
+	checkTestInt(t, int(UserRoleOrgAdmin), int(user.Role))
+
+	// Demotion
+	login([]string{"facility"})
+	user, _ = testRepositories.User.GetOne(user.ID)
+	checkTestInt(t, int(UserRoleSpaceAdmin), int(user.Role))
+
+	// Issued token reflects the mapped role
+	tokens := login([]string{})
+	user, _ = testRepositories.User.GetOne(user.ID)
+	checkTestInt(t, int(UserRoleUser), int(user.Role))
+	claims := &Claims{}
+	jwt.ParseWithClaims(tokens.AccessToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(GetConfig().JwtSigningKey), nil
+	})
+	checkTestInt(t, int(UserRoleUser), claims.Role)
+
+	// Missing groups claim keeps the role
+	login([]string{"admins"})
+	tokens = login(nil)
+	user, _ = testRepositories.User.GetOne(user.ID)
+	checkTestInt(t, int(UserRoleOrgAdmin), int(user.Role))
+	claims = &Claims{}
+	jwt.ParseWithClaims(tokens.AccessToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(GetConfig().JwtSigningKey), nil
+	})
+	checkTestInt(t, int(UserRoleOrgAdmin), claims.Role)
+
+	// New users get the mapped role
+	user2Email := "oidc.user2@test.com"
+	testRepositories.Settings.Set(org.ID, SettingAllowAnyUser.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingSubscriptionMaxUsers.Name, "50")
+	req = newHTTPRequest("GET", "/auth/"+id+"/login/web", "", nil)
+	res = executeTestRequest(req)
+	redirect, _ := url.Parse(res.Header().Get("Location"))
+	idp.Nonce = redirect.Query().Get("nonce")
+	idp.CodeChallenge = redirect.Query().Get("code_challenge")
+	idp.Claims = jwt.MapClaims{
+		"email":  user2Email,
+		"groups": []string{"admins"},
+	}
+	req = newHTTPRequest("GET", "/auth/"+id+"/callback?state="+redirect.Query().Get("state")+"&code=abc", "", nil)
+	res = executeTestRequest(req)
+	location := res.Header().Get("Location")
+	req = newHTTPRequest("GET", "/auth/verify/"+location[strings.LastIndex(location, "/")+1:], "", nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	user2, _ := testRepositories.User.GetByEmail(user2Email)
+	checkTestInt(t, int(UserRoleOrgAdmin), int(user2.Role))
+}
+
+func TestAuthOIDCLoginInvalidNonce(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	createTestUserInOrgWithName(org, "oidc.user@test.com", UserRoleUser)
+
+	// The test server listens on the loopback interface
+	GetConfig().AuthProviderAllowPrivateNetworks = true
+	defer func() { GetConfig().AuthProviderAllowPrivateNetworks = false }()
+	idp := newTestOIDCServer("seatsurfing")
+	defer idp.Server.Close()
+
+	payload := `{"name": "OIDC", "providerType": 2, "issuerUrl": "` + idp.Server.URL + `", "clientId": "seatsurfing", "clientSecret": "secret"}`
+	req := newHTTPRequest("POST", "/auth-provider/", admin.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	id := res.Header().Get("X-Object-Id")
+
+	req = newHTTPRequest("GET", "/auth/"+id+"/login/web", "", nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusTemporaryRedirect, res.Code)
+	redirect, _ := url.Parse(res.Header().Get("Location"))
+	idp.Nonce = "invalid"
+	idp.CodeChallenge = redirect.Query().Get("code_challenge")
+	idp.Claims = jwt.MapClaims{
+		"email": "oidc.user@test.com",
+	}
+
+	req = newHTTPRequest("GET", "/auth/"+id+"/callback?state="+redirect.Query().Get("state")+"&code=abc", "", nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusTemporaryRedirect, res.Code)
+	checkTestBool(t, true, strings.HasSuffix(res.Header().Get("Location"), "/admin/login/failed"))
+}
+
+func TestAuthOIDCProviderInvalidIssuer(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+
+	payload := `{"name": "OIDC", "providerType": 2, "clientId": "seatsurfing", "clientSecret": "secret"}`
+	req := newHTTPRequest("POST", "/auth-provider/", admin.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+}
+
+func TestAuthOIDCProviderPrivateIssuer(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+
+	idp := newTestOIDCServer("seatsurfing")
+	defer idp.Server.Close()
+
+	payload := `{"name": "OIDC", "providerType": 2, "issuerUrl": "` + idp.Server.URL + `", "clientId": "seatsurfing", "clientSecret": "secret"}`
+	req := newHTTPRequest("POST", "/auth-provider/", admin.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+}
+
+type testSAMLServiceProviders struct {
+	Metadata *saml.EntityDescriptor
+}
+
+func (p *testSAMLServiceProviders) GetServiceProvider(r *http.Request, serviceProviderID string) (*saml.EntityDescriptor, error) {
+	if p.Metadata == nil || p.Metadata.EntityID != serviceProviderID {
+		return nil, os.ErrNotExist
+	}
+	return p.Metadata, nil
+}
+
+func newTestSAMLIdentityProvider() (*saml.IdentityProvider, *testSAMLServiceProviders) {
+	keyPEM, certPEM, _ := createSAMLKeyPair("idp.test.com")
+	key, cert, _ := parseSAMLKeyPair(keyPEM, certPEM)
+	metadataURL, _ := url.Parse("https://idp.test.com/metadata")
+	ssoURL, _ := url.Parse("https://idp.test.com/sso")
+	serviceProviders := &testSAMLServiceProviders{}
+	idp := &saml.IdentityProvider{
+		Key:                     key,
+		Certificate:             cert,
+		MetadataURL:             *metadataURL,
+		SSOURL:                  *ssoURL,
+		ServiceProviderProvider: serviceProviders,
+	}
+	return idp, serviceProviders
+}
+
+func createTestSAMLProvider(t *testing.T, admin *User, idp *saml.IdentityProvider) string {
+	idpMetadata, _ := xml.Marshal(idp.Metadata())
+	m := &CreateAuthProviderRequest{
+		Name:               "SAML",
+		ProviderType:       int(SAML),
+		UserInfoEmailField: "eduPersonPrincipalName",
+		ClaimName:          "cn",
+		ClaimGroups:        "eduPersonAffiliation",
+		SAMLIdPMetadata:    string(idpMetadata),
+	}
+	payload, _ := json.Marshal(m)
+	req := newHTTPRequest("POST", "/auth-provider/", admin.ID, bytes.NewBuffer(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	return res.Header().Get("X-Object-Id")
+}
+
+func TestAuthSAMLLogin(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	user := createTestUserInOrgWithName(org, "saml.user@test.com", UserRoleUser)
+	group := &UserGroup{OrganizationID: org.ID, Name: "Team A"}
+	testRepositories.UserGroup.Create(group)
+
+	idp, serviceProviders := newTestSAMLIdentityProvider()
+	id := createTestSAMLProvider(t, admin, idp)
+
+	req := newHTTPRequest("GET", "/auth-provider/"+id, admin.ID, nil)
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody *GetAuthProviderResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestString(t, "https://idp.test.com/sso", resBody.AuthURL)
+	checkTestBool(t, true, strings.HasPrefix(resBody.SAMLSPCertificate, "-----BEGIN CERTIFICATE-----"))
+	checkTestBool(t, false, strings.Contains(res.Body.String(), "PRIVATE KEY"))
+
+	// Service provider metadata
+	req = newHTTPRequest("GET", "/auth/"+id+"/metadata", "", nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	spMetadata := &saml.EntityDescriptor{}
+	if err := xml.Unmarshal(res.Body.Bytes(), spMetadata); err != nil {
+		t.Fatal(err)
+	}
+	checkTestString(t, GetConfig().PublicURL+"auth/"+id+"/metadata", spMetadata.EntityID)
+	checkTestString(t, GetConfig().PublicURL+"auth/"+id+"/callback", spMetadata.SPSSODescriptors[0].AssertionConsumerServices[0].Location)
+	serviceProviders.Metadata = spMetadata
+
+	// Start login, the AuthnRequest is signed
+	req = newHTTPRequest("GET", "/auth/"+id+"/login/web", "", nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusTemporaryRedirect, res.Code)
+	redirect, _ := url.Parse(res.Header().Get("Location"))
+	checkTestString(t, "idp.test.com", redirect.Host)
+	checkStringNotEmpty(t, redirect.Query().Get("SAMLRequest"))
+	checkStringNotEmpty(t, redirect.Query().Get("Signature"))
+	checkStringNotEmpty(t, redirect.Query().Get("RelayState"))
+
+	// IdP authenticates the user and posts the response back
+	idpReq, err := saml.NewIdpAuthnRequest(idp, &http.Request{Method: "GET", URL: redirect})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := idpReq.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	session := &saml.Session{
+		ID:             "session-1",
+		NameID:         "saml.user",
+		UserEmail:      "saml.user@test.com",
+		UserCommonName: "SAML User",
+		Groups:         []string{"Team A"},
+	}
+	if err := (saml.DefaultAssertionMaker{}).MakeAssertion(idpReq, session); err != nil {
+		t.Fatal(err)
+	}
+	form, err := idpReq.PostBinding()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := url.Values{}
+	values.Set("SAMLResponse", form.SAMLResponse)
+	values.Set("RelayState", form.RelayState)
+	req = newHTTPRequest("POST", "/auth/"+id+"/callback", "", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusTemporaryRedirect, res.Code)
+	location := res.Header().Get("Location")
+	checkTestBool(t, true, strings.Contains(location, "/admin/login/success/"))
+	authStateID := location[strings.LastIndex(location, "/")+1:]
+
+	req = newHTTPRequest("GET", "/auth/verify/"+authStateID, "", nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody2 *JWTResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody2)
+	checkTestBool(t, true, len(resBody2.AccessToken) > 32)
+
+	// Attributes are mapped to user attributes
+	user, _ = testRepositories.User.GetOne(user.ID)
+	checkTestString(t, "SAML User", user.DisplayName)
+	groups, _ := testRepositories.UserGroup.GetAllByUser(user.ID)
+	checkTestInt(t, 1, len(groups))
+	checkTestString(t, group.ID, groups[0].ID)
+
+	// Responses can't be replayed
+	req = newHTTPRequest("POST", "/auth/"+id+"/callback", "", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusTemporaryRedirect, res.Code)
+	checkTestBool(t, true, strings.HasSuffix(res.Header().Get("Location"), "/ui/login/failed"))
+}
+
+func TestAuthSAMLLoginUnsignedResponse(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	createTestUserInOrgWithName(org, "saml.user@test.com", UserRoleUser)
+
+	idp, _ := newTestSAMLIdentityProvider()
+	id := createTestSAMLProvider(t, admin, idp)
+
+	req := newHTTPRequest("GET", "/auth/"+id+"/login/web", "", nil)
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusTemporaryRedirect, res.Code)
+	redirect, _ := url.Parse(res.Header().Get("Location"))
+
+	response := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="id-1" Version="2.0" IssueInstant="` + time.Now().UTC().Format(time.RFC3339) + `" Destination="` + GetConfig().PublicURL + `auth/` + id + `/callback">` +
+		`<saml:Issuer>https://idp.test.com/metadata</saml:Issuer>` +
+		`<samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>` +
+		`<saml:Assertion ID="id-2" Version="2.0" IssueInstant="` + time.Now().UTC().Format(time.RFC3339) + `"><saml:Issuer>https://idp.test.com/metadata</saml:Issuer>` +
+		`<saml:Subject><saml:NameID>saml.user@test.com</saml:NameID></saml:Subject></saml:Assertion></samlp:Response>`
+	values := url.Values{}
+	values.Set("SAMLResponse", base64.StdEncoding.EncodeToString([]byte(response)))
+	values.Set("RelayState", redirect.Query().Get("RelayState"))
+	req = newHTTPRequest("POST", "/auth/"+id+"/callback", "", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusTemporaryRedirect, res.Code)
+	checkTestBool(t, true, strings.HasSuffix(res.Header().Get("Location"), "/admin/login/failed"))
+}
+
+func TestAuthSAMLProviderInvalidMetadata(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+
+	payload := `{"name": "SAML", "providerType": 3, "samlIdpMetadata": "<invalid/>"}`
+	req := newHTTPRequest("POST", "/auth-provider/", admin.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+}
+
+func TestAuthSAMLProviderPrivateMetadataURL(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+
+	payload := `{"name": "SAML", "providerType": 3, "issuerUrl": "http://169.254.169.254/latest/meta-data/"}`
+	req := newHTTPRequest("POST", "/auth-provider/", admin.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+}
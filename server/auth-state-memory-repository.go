@@ -0,0 +1,59 @@
+package main
+
+import "time"
+
+type MemoryAuthStateRepository struct {
+}
+
+func (r *MemoryAuthStateRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryAuthStateRepository) Create(e *AuthState) error {
+	if err := memoryCheckUUID(e.AuthProviderID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e.ID = memoryNewID()
+	row := *e
+	row.Expiry = memoryTimestamp(e.Expiry)
+	memoryData.authStates = append(memoryData.authStates, &row)
+	return nil
+}
+
+func (r *MemoryAuthStateRepository) GetOne(id string) (*AuthState, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryGet(memoryData.authStates, func(e *AuthState) bool {
+		return e.ID == id
+	})
+}
+
+func (r *MemoryAuthStateRepository) Delete(e *AuthState) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.authStates = memoryDelete(memoryData.authStates, func(row *AuthState) bool {
+		return row.ID == e.ID
+	})
+	return nil
+}
+
+func (r *MemoryAuthStateRepository) DeleteExpired() error {
+	now := memoryTimestamp(time.Now())
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.authStates = memoryDelete(memoryData.authStates, func(row *AuthState) bool {
+		return row.Expiry.Before(now)
+	})
+	return nil
+}
+
+func (r *MemoryAuthStateRepository) GetByAuthProviderID(authProviderID string) ([]*AuthState, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryCopy(memoryFilter(memoryData.authStates, func(e *AuthState) bool {
+		return e.AuthProviderID == authProviderID
+	})), nil
+}
@@ -1,11 +1,17 @@
 package main
 
-import (
-	"sync"
-	"time"
-)
+import "time"
+
+type AuthStateRepository interface {
+	Repository
+	Create(e *AuthState) error
+	GetOne(id string) (*AuthState, error)
+	Delete(e *AuthState) error
+	DeleteExpired() error
+	GetByAuthProviderID(authProviderID string) ([]*AuthState, error)
+}
 
-type AuthStateRepository struct {
+type PostgresAuthStateRepository struct {
 }
 
 type AuthStateType int
@@ -16,6 +22,7 @@ const (
 	AuthAtlassian            AuthStateType = 3
 	AuthMergeRequest         AuthStateType = 4
 	AuthResetPasswordRequest AuthStateType = 5
+	AuthTOTPChallenge        AuthStateType = 6
 )
 
 type AuthState struct {
@@ -26,31 +33,21 @@ type AuthState struct {
 	Payload        string
 }
 
-var authStateRepository *AuthStateRepository
-var authStateRepositoryOnce sync.Once
-
-func GetAuthStateRepository() *AuthStateRepository {
-	authStateRepositoryOnce.Do(func() {
-		authStateRepository = &AuthStateRepository{}
-		_, err := GetDatabase().DB().Exec("CREATE TABLE IF NOT EXISTS auth_states (" +
-			"id uuid DEFAULT uuid_generate_v4(), " +
-			"auth_provider_id uuid NOT NULL, " +
-			"expiry TIMESTAMP NOT NULL, " +
-			"auth_state_type INT NOT NULL, " +
-			"payload VARCHAR NULL, " +
-			"PRIMARY KEY (id))")
-		if err != nil {
-			panic(err)
-		}
-	})
-	return authStateRepository
-}
-
-func (r *AuthStateRepository) RunSchemaUpgrade(curVersion, targetVersion int) {
-	// No updates yet
+func (r *PostgresAuthStateRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		newInitialSchemaMigration(
+			"CREATE TABLE IF NOT EXISTS auth_states (" +
+				"id uuid DEFAULT uuid_generate_v4(), " +
+				"auth_provider_id uuid NOT NULL, " +
+				"expiry TIMESTAMP NOT NULL, " +
+				"auth_state_type INT NOT NULL, " +
+				"payload VARCHAR NULL, " +
+				"PRIMARY KEY (id))",
+		),
+	}
 }
 
-func (r *AuthStateRepository) Create(e *AuthState) error {
+func (r *PostgresAuthStateRepository) Create(e *AuthState) error {
 	var id string
 	err := GetDatabase().DB().QueryRow("INSERT INTO auth_states "+
 		"(auth_provider_id, expiry, auth_state_type, payload) "+
@@ -64,7 +61,7 @@ func (r *AuthStateRepository) Create(e *AuthState) error {
 	return nil
 }
 
-func (r *AuthStateRepository) GetOne(id string) (*AuthState, error) {
+func (r *PostgresAuthStateRepository) GetOne(id string) (*AuthState, error) {
 	e := &AuthState{}
 	err := GetDatabase().DB().QueryRow("SELECT id, auth_provider_id, expiry, auth_state_type, payload "+
 		"FROM auth_states "+
@@ -76,18 +73,18 @@ func (r *AuthStateRepository) GetOne(id string) (*AuthState, error) {
 	return e, nil
 }
 
-func (r *AuthStateRepository) Delete(e *AuthState) error {
+func (r *PostgresAuthStateRepository) Delete(e *AuthState) error {
 	_, err := GetDatabase().DB().Exec("DELETE FROM auth_states WHERE id = $1", e.ID)
 	return err
 }
 
-func (r *AuthStateRepository) DeleteExpired() error {
+func (r *PostgresAuthStateRepository) DeleteExpired() error {
 	now := time.Now()
 	_, err := GetDatabase().DB().Exec("DELETE FROM auth_states WHERE expiry < $1", now)
 	return err
 }
 
-func (r *AuthStateRepository) GetByAuthProviderID(authProviderID string) ([]*AuthState, error) {
+func (r *PostgresAuthStateRepository) GetByAuthProviderID(authProviderID string) ([]*AuthState, error) {
 	var result []*AuthState
 	rows, err := GetDatabase().DB().Query("SELECT id, auth_provider_id, expiry, auth_state_type, payload "+
 		"FROM auth_states "+
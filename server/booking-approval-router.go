@@ -0,0 +1,196 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type BookingApprovalRouter struct {
+	repos *Repositories
+}
+
+type RejectBookingRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+func (router *BookingApprovalRouter) setupRoutes(s *mux.Router) {
+	s.HandleFunc("/{id}/approve", router.approve).Methods("POST")
+	s.HandleFunc("/{id}/reject", router.reject).Methods("POST")
+	s.HandleFunc("/", router.getAll).Methods("GET")
+}
+
+// getAll returns the pending bookings the requesting user may approve.
+func (router *BookingApprovalRouter) getAll(w http.ResponseWriter, r *http.Request) {
+	user := GetRequestUser(router.repos, r)
+	if !CanSpaceAdminOrg(user, user.OrganizationID) {
+		SendForbidden(w)
+		return
+	}
+	list, err := router.repos.Booking.GetAllPending(user.OrganizationID)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	bookingRouter := &BookingRouter{repos: router.repos}
+	canApprove := make(map[string]bool)
+	res := []*GetBookingResponse{}
+	for _, e := range list {
+		allowed, ok := canApprove[e.Space.LocationID]
+		if !ok {
+			if allowed, err = router.repos.LocationApprover.CanApprove(user, &e.Space.Location); err != nil {
+				log.Println(err)
+				SendInternalServerError(w)
+				return
+			}
+			canApprove[e.Space.LocationID] = allowed
+		}
+		if !allowed {
+			continue
+		}
+		if e.Attendees, err = router.repos.BookingAttendee.GetAll(e.ID); err != nil {
+			log.Println(err)
+			SendInternalServerError(w)
+			return
+		}
+		res = append(res, bookingRouter.copyToRestModel(e))
+	}
+	SendJSON(w, res)
+}
+
+func (router *BookingApprovalRouter) approve(w http.ResponseWriter, r *http.Request) {
+	e, ok := router.getPendingBooking(w, r)
+	if !ok {
+		return
+	}
+	bookingRouter := &BookingRouter{repos: router.repos}
+	before := bookingRouter.copyToRestModel(e)
+	if err := router.repos.Booking.Approve(&e.Booking); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	if err := bookingRouter.autoCheckIn(&e.Booking, &e.Space.Location); err != nil {
+		log.Println(err)
+	}
+	bookingRouter.recordAuditEvent(r, AuditActionBookingApproved, e.ID, before)
+	bookingRouter.onBookingChanged(e.ID, EmailTemplateBookingCreated, WebhookEventBookingUpdated)
+	SendUpdated(w)
+}
+
+func (router *BookingApprovalRouter) reject(w http.ResponseWriter, r *http.Request) {
+	e, ok := router.getPendingBooking(w, r)
+	if !ok {
+		return
+	}
+	var m RejectBookingRequest
+	if UnmarshalValidateBody(r, &m) != nil {
+		SendBadRequest(w)
+		return
+	}
+	if err := router.deletePending(e, EmailTemplateBookingRejected, m.Reason); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	bookingRouter := &BookingRouter{repos: router.repos}
+	recordAuditEvent(router.repos, r, e.Space.Location.OrganizationID, AuditActionBookingRejected, AuditTargetBooking, e.ID, bookingRouter.copyToRestModel(e), map[string]string{"rejectionReason": m.Reason})
+	SendUpdated(w)
+}
+
+// expirePendingBookings deletes pending bookings which have not been approved in time
+// and notifies their users.
+func (router *BookingApprovalRouter) expirePendingBookings() error {
+	list, err := router.repos.Booking.GetAllApprovalExpired(time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	for _, e := range list {
+		if err := router.deletePending(e, EmailTemplateBookingApprovalExpired, ""); err != nil {
+			log.Println(err)
+		}
+	}
+	return nil
+}
+
+// onBookingPending notifies the booking's user that the booking awaits approval and
+// fires the webhook event. Attendees are notified once the booking is approved.
+func (router *BookingApprovalRouter) onBookingPending(bookingID, event string) {
+	e, err := router.repos.Booking.GetOne(bookingID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	router.sendApprovalMail(e, EmailTemplateBookingPending, "")
+	bookingRouter := &BookingRouter{repos: router.repos}
+	fireWebhookEvent(router.repos, e.Space.Location.OrganizationID, event, bookingRouter.copyToRestModel(e))
+}
+
+func (router *BookingApprovalRouter) deletePending(e *BookingDetails, templateFile, reason string) error {
+	if err := router.repos.Booking.Delete(e); err != nil {
+		return err
+	}
+	router.sendApprovalMail(e, templateFile, reason)
+	bookingRouter := &BookingRouter{repos: router.repos}
+	fireWebhookEvent(router.repos, e.Space.Location.OrganizationID, WebhookEventBookingDeleted, bookingRouter.copyToRestModel(e))
+	waitlistRouter := &WaitlistRouter{repos: router.repos}
+	waitlistRouter.onSpaceFreed(e.SpaceID)
+	return nil
+}
+
+func (router *BookingApprovalRouter) getPendingBooking(w http.ResponseWriter, r *http.Request) (*BookingDetails, bool) {
+	vars := mux.Vars(r)
+	e, err := router.repos.Booking.GetOne(vars["id"])
+	if err != nil {
+		SendNotFound(w)
+		return nil, false
+	}
+	canApprove, err := router.repos.LocationApprover.CanApprove(GetRequestUser(router.repos, r), &e.Space.Location)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return nil, false
+	}
+	if !canApprove {
+		SendForbidden(w)
+		return nil, false
+	}
+	if e.Status != BookingStatusPending {
+		SendBadRequest(w)
+		return nil, false
+	}
+	return e, true
+}
+
+// sendApprovalMail notifies the booking's user of the approval state. Failures are
+// logged only, as notifications must not affect the booking itself.
+func (router *BookingApprovalRouter) sendApprovalMail(e *BookingDetails, templateFile, reason string) {
+	if value, err := router.repos.UserPreferences.Get(e.UserID, PreferenceMailBookingConfirmation.Name); err == nil && value == "0" {
+		return
+	}
+	org, err := router.repos.Organization.GetOne(e.Space.Location.OrganizationID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	vars := map[string]string{
+		"recipientName":  e.UserEmail,
+		"recipientEmail": e.UserEmail,
+		"spaceName":      e.Space.Name,
+		"locationName":   e.Space.Location.Name,
+		"enter":          e.Enter.Format("2006-01-02 15:04"),
+		"leave":          e.Leave.Format("2006-01-02 15:04"),
+		"timezone":       router.repos.Location.GetTimezone(&e.Space.Location),
+		"reason":         reason,
+	}
+	if e.ApprovalExpiry != nil {
+		expiry, _ := getLocalWallTime(router.repos, *e.ApprovalExpiry, &e.Space.Location)
+		vars["approvalExpiry"] = expiry.Format("2006-01-02 15:04")
+	}
+	if err := sendEmail(e.UserEmail, GetConfig().SMTPSenderAddress, templateFile, org.Language, vars); err != nil {
+		log.Println(err)
+	}
+}
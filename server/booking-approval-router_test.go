@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func createTestApprovalSpace(t *testing.T, org *Organization, admin *User, approverIDs []string) (locationID, spaceID string) {
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	approvers, _ := json.Marshal(approverIDs)
+	payload := `{"name": "Location 1", "approverIds": ` + string(approvers) + `}`
+	req := newHTTPRequest("POST", "/location/", admin.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	locationID = res.Header().Get("X-Object-Id")
+	payload = `{"name": "H234", "requiresApproval": true}`
+	req = newHTTPRequest("POST", "/location/"+locationID+"/space/", admin.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	spaceID = res.Header().Get("X-Object-Id")
+	return locationID, spaceID
+}
+
+func TestBookingApprovalApprove(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	approver := createTestUserInOrgWithName(org, "approver@test.com", UserRoleSpaceAdmin)
+	spaceAdmin := createTestUserInOrgWithName(org, "spaceadmin@test.com", UserRoleSpaceAdmin)
+	user := createTestUserInOrg(org)
+	user2 := createTestUserInOrg(org)
+	locationID, spaceID := createTestApprovalSpace(t, org, admin, []string{approver.ID})
+
+	req := newHTTPRequest("GET", "/location/"+locationID, admin.ID, nil)
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resLocation *GetLocationResponse
+	json.Unmarshal(res.Body.Bytes(), &resLocation)
+	checkTestInt(t, 1, len(resLocation.ApproverIDs))
+	checkTestString(t, approver.ID, resLocation.ApproverIDs[0])
+
+	SendMailMockContent = ""
+	payload := `{"spaceId": "` + spaceID + `", "enter": "2030-09-01T08:30:00Z", "leave": "2030-09-01T17:00:00Z"}`
+	req = newHTTPRequest("POST", "/booking/", user.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	bookingID := res.Header().Get("X-Object-Id")
+	checkTestBool(t, true, strings.Contains(SendMailMockContent, "wartet auf Freigabe"))
+
+	req = newHTTPRequest("GET", "/booking/"+bookingID, user.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody *GetBookingResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestInt(t, int(BookingStatusPending), int(resBody.Status))
+	if resBody.ApprovalExpiry == nil {
+		t.Fatal("expected approval expiry to be set")
+	}
+
+	// Pending bookings block the space
+	req = newHTTPRequest("POST", "/booking/", user2.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusConflict, res.Code)
+
+	req = newHTTPRequest("POST", "/booking/"+bookingID+"/checkin", user.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+
+	// Filter by status
+	for status, expected := range map[BookingStatus]int{0: 1, BookingStatusPending: 1, BookingStatusConfirmed: 0} {
+		payload = `{"start": "2030-09-01T00:00:00Z", "end": "2030-09-02T00:00:00Z", "status": ` + strconv.Itoa(int(status)) + `}`
+		req = newHTTPRequest("POST", "/booking/filter/", admin.ID, bytes.NewBufferString(payload))
+		res = executeTestRequest(req)
+		checkTestResponseCode(t, http.StatusOK, res.Code)
+		var resFilter []*GetBookingResponse
+		json.Unmarshal(res.Body.Bytes(), &resFilter)
+		checkTestInt(t, expected, len(resFilter))
+	}
+
+	// Only approvers of the location see the queue
+	req = newHTTPRequest("GET", "/booking/approval/", user.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+	req = newHTTPRequest("GET", "/booking/approval/", spaceAdmin.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resQueue []*GetBookingResponse
+	json.Unmarshal(res.Body.Bytes(), &resQueue)
+	checkTestInt(t, 0, len(resQueue))
+	req = newHTTPRequest("POST", "/booking/approval/"+bookingID+"/approve", spaceAdmin.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+
+	req = newHTTPRequest("GET", "/booking/approval/", approver.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	json.Unmarshal(res.Body.Bytes(), &resQueue)
+	checkTestInt(t, 1, len(resQueue))
+	checkTestString(t, bookingID, resQueue[0].ID)
+
+	req = newHTTPRequest("POST", "/booking/approval/"+bookingID+"/approve", approver.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+	req = newHTTPRequest("POST", "/booking/approval/"+bookingID+"/approve", approver.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+
+	booking, _ := testRepositories.Booking.GetOne(bookingID)
+	checkTestInt(t, int(BookingStatusConfirmed), int(booking.Status))
+	if booking.ApprovalExpiry != nil {
+		t.Fatal("expected approval expiry to be cleared")
+	}
+
+	// Approvers' own bookings are confirmed right away
+	payload = `{"spaceId": "` + spaceID + `", "enter": "2030-09-02T08:30:00Z", "leave": "2030-09-02T17:00:00Z"}`
+	req = newHTTPRequest("POST", "/booking/", approver.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	booking, _ = testRepositories.Booking.GetOne(res.Header().Get("X-Object-Id"))
+	checkTestInt(t, int(BookingStatusConfirmed), int(booking.Status))
+}
+
+func TestBookingApprovalReject(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	user := createTestUserInOrg(org)
+	_, spaceID := createTestApprovalSpace(t, org, admin, nil)
+
+	payload := `{"spaceId": "` + spaceID + `", "enter": "2030-09-01T08:30:00Z", "leave": "2030-09-01T17:00:00Z"}`
+	req := newHTTPRequest("POST", "/booking/", user.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	bookingID := res.Header().Get("X-Object-Id")
+
+	// Without approvers, space admins approve
+	req = newHTTPRequest("POST", "/booking/approval/"+bookingID+"/reject", admin.ID, bytes.NewBufferString(`{}`))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+	SendMailMockContent = ""
+	req = newHTTPRequest("POST", "/booking/approval/"+bookingID+"/reject", admin.ID, bytes.NewBufferString(`{"reason": "Reserved for visitors"}`))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+	checkTestBool(t, true, strings.Contains(SendMailMockContent, "Reserved for visitors"))
+
+	req = newHTTPRequest("GET", "/booking/"+bookingID, user.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNotFound, res.Code)
+}
+
+func TestBookingApprovalExpired(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	user := createTestUserInOrg(org)
+	_, spaceID := createTestApprovalSpace(t, org, admin, nil)
+
+	payload := `{"spaceId": "` + spaceID + `", "enter": "2030-09-01T08:30:00Z", "leave": "2030-09-01T17:00:00Z"}`
+	req := newHTTPRequest("POST", "/booking/", user.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	bookingID := res.Header().Get("X-Object-Id")
+
+	router := &BookingApprovalRouter{repos: testRepositories}
+	if err := router.expirePendingBookings(); err != nil {
+		t.Fatal(err)
+	}
+	booking, err := testRepositories.Booking.GetOne(bookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiry := time.Now().UTC().Add(-time.Minute)
+	booking.ApprovalExpiry = &expiry
+	testRepositories.Booking.Update(&booking.Booking)
+
+	SendMailMockContent = ""
+	if err := router.expirePendingBookings(); err != nil {
+		t.Fatal(err)
+	}
+	checkTestBool(t, true, strings.Contains(SendMailMockContent, "nicht rechtzeitig freigegeben"))
+	if _, err := testRepositories.Booking.GetOne(bookingID); err == nil {
+		t.Fatal("expected expired booking to be deleted")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+type MemoryBookingAttendeeRepository struct {
+}
+
+func (r *MemoryBookingAttendeeRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+// SetAll replaces the attendees of the booking.
+func (r *MemoryBookingAttendeeRepository) SetAll(bookingID string, userIDs []string) error {
+	if err := memoryCheckUUID(bookingID); err != nil {
+		return err
+	}
+	if err := memoryCheckUUID(userIDs...); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.bookingAttendees = memoryDelete(memoryData.bookingAttendees, func(row *BookingAttendee) bool {
+		return row.BookingID == bookingID
+	})
+	for i, userID := range userIDs {
+		if memoryContainsID(userIDs[:i], userID) {
+			return memoryUniqueViolation("bookings_attendees_pkey")
+		}
+		memoryData.bookingAttendees = append(memoryData.bookingAttendees, &BookingAttendee{
+			BookingID: bookingID,
+			UserID:    userID,
+		})
+	}
+	return nil
+}
+
+func (r *MemoryBookingAttendeeRepository) GetAll(bookingID string) ([]*BookingAttendee, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryBookingAttendees(bookingID), nil
+}
+
+// memoryBookingAttendees returns the attendees of the booking ordered by email. The
+// caller must hold the lock.
+func memoryBookingAttendees(bookingID string) []*BookingAttendee {
+	var result []*BookingAttendee
+	for _, e := range memoryData.bookingAttendees {
+		if e.BookingID != bookingID {
+			continue
+		}
+		user := memoryUserByID(e.UserID)
+		if user == nil {
+			continue
+		}
+		result = append(result, &BookingAttendee{
+			BookingID: e.BookingID,
+			UserID:    e.UserID,
+			UserEmail: user.Email,
+		})
+	}
+	memorySort(result, func(a, b *BookingAttendee) bool {
+		return memoryCompare(a.UserEmail, b.UserEmail) < 0
+	})
+	return result
+}
+
+func (r *MemoryBookingAttendeeRepository) DeleteAll(bookingID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.bookingAttendees = memoryDelete(memoryData.bookingAttendees, func(row *BookingAttendee) bool {
+		return row.BookingID == bookingID
+	})
+	return nil
+}
+
+// DeleteOfUser removes the user from all bookings they attend and removes the
+// attendees of all bookings they organize.
+func (r *MemoryBookingAttendeeRepository) DeleteOfUser(e *User) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.bookingAttendees = memoryDelete(memoryData.bookingAttendees, func(row *BookingAttendee) bool {
+		if row.UserID == e.ID {
+			return true
+		}
+		booking, err := memoryFind(memoryData.bookings, func(b *memoryBooking) bool { return b.ID == row.BookingID })
+		return err == nil && booking.UserID == e.ID
+	})
+	return nil
+}
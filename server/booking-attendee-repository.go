@@ -0,0 +1,88 @@
+package main
+
+type BookingAttendeeRepository interface {
+	Repository
+	SetAll(bookingID string, userIDs []string) error
+	GetAll(bookingID string) ([]*BookingAttendee, error)
+	DeleteAll(bookingID string) error
+	DeleteOfUser(e *User) error
+}
+
+type PostgresBookingAttendeeRepository struct {
+}
+
+// BookingAttendee is a user invited to a meeting room booking by its organizer,
+// the booking's user.
+type BookingAttendee struct {
+	BookingID string
+	UserID    string
+	UserEmail string
+}
+
+func (r *PostgresBookingAttendeeRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		{
+			Version:     21,
+			Description: "Add attendees to bookings",
+			Up: []string{
+				"CREATE TABLE IF NOT EXISTS bookings_attendees (" +
+					"booking_id uuid NOT NULL, " +
+					"user_id uuid NOT NULL, " +
+					"PRIMARY KEY (booking_id, user_id))",
+				"CREATE INDEX IF NOT EXISTS idx_bookings_attendees_user_id ON bookings_attendees(user_id)",
+			},
+			Down: []string{"DROP TABLE IF EXISTS bookings_attendees"},
+		},
+	}
+}
+
+// SetAll replaces the attendees of the booking.
+func (r *PostgresBookingAttendeeRepository) SetAll(bookingID string, userIDs []string) error {
+	if err := r.DeleteAll(bookingID); err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		if _, err := GetDatabase().DB().Exec("INSERT INTO bookings_attendees "+
+			"(booking_id, user_id) "+
+			"VALUES ($1, $2)",
+			bookingID, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PostgresBookingAttendeeRepository) GetAll(bookingID string) ([]*BookingAttendee, error) {
+	var result []*BookingAttendee
+	rows, err := GetDatabase().DB().Query("SELECT bookings_attendees.booking_id, bookings_attendees.user_id, users.email "+
+		"FROM bookings_attendees "+
+		"INNER JOIN users ON users.id = bookings_attendees.user_id "+
+		"WHERE bookings_attendees.booking_id = $1 "+
+		"ORDER BY users.email", bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &BookingAttendee{}
+		err = rows.Scan(&e.BookingID, &e.UserID, &e.UserEmail)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (r *PostgresBookingAttendeeRepository) DeleteAll(bookingID string) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM bookings_attendees WHERE booking_id = $1", bookingID)
+	return err
+}
+
+// DeleteOfUser removes the user from all bookings they attend and removes the
+// attendees of all bookings they organize.
+func (r *PostgresBookingAttendeeRepository) DeleteOfUser(e *User) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM bookings_attendees WHERE user_id = $1 OR "+
+		"booking_id IN (SELECT id FROM bookings WHERE user_id = $1)", e.ID)
+	return err
+}
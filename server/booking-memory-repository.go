@@ -0,0 +1,523 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"time"
+)
+
+type MemoryBookingRepository struct {
+	bookingRepositoryBase
+}
+
+// memoryBooking holds the columns of a booking not part of Booking.
+type memoryBooking struct {
+	Booking
+	ReminderSent bool
+	Created      time.Time
+}
+
+func (r *MemoryBookingRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryBookingRepository) Create(e *Booking) error {
+	if err := r.checkUUIDs(e); err != nil {
+		return err
+	}
+	if e.Status == 0 {
+		e.Status = BookingStatusConfirmed
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	row := &memoryBooking{
+		Booking: *r.copy(e),
+		Created: memoryTimestamp(time.Now().UTC()),
+	}
+	row.ID = memoryNewID()
+	if r.isConflict(&row.Booking) {
+		return ErrBookingConflict
+	}
+	e.ID = row.ID
+	memoryData.bookings = append(memoryData.bookings, row)
+	return nil
+}
+
+func (r *MemoryBookingRepository) GetOne(id string) (*BookingDetails, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	result := r.getAll(func(e *memoryBooking) bool {
+		return e.ID == id
+	})
+	if len(result) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	e := result[0]
+	e.Attendees = memoryBookingAttendees(e.ID)
+	return e, nil
+}
+
+// Get first upcoming booking by user
+func (r *MemoryBookingRepository) GetFirstUpcomingBookingByUserID(userID string) (*BookingDetails, error) {
+	now := memoryTimestamp(time.Now())
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	result := r.getAll(func(e *memoryBooking) bool {
+		return e.UserID == userID && e.Enter.After(now)
+	})
+	if len(result) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return result[0], nil
+}
+
+// GetAllByOrg returns the organization's bookings within the range. If status is 0, bookings
+// of all statuses are returned.
+func (r *MemoryBookingRepository) GetAllByOrg(organizationID string, startTime, endTime time.Time, status BookingStatus) ([]*BookingDetails, error) {
+	startTime, endTime = memoryTimestamp(startTime), memoryTimestamp(endTime)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return r.getAllByOrg(organizationID, func(e *memoryBooking) bool {
+		return !e.Leave.Before(startTime) && !e.Enter.After(endTime) && (status == 0 || e.Status == status)
+	}), nil
+}
+
+func (r *MemoryBookingRepository) GetAllByUser(userID string, startTime time.Time) ([]*BookingDetails, error) {
+	startTime = memoryTimestamp(startTime)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return r.getAll(func(e *memoryBooking) bool {
+		return e.UserID == userID && !e.Leave.Before(startTime)
+	}), nil
+}
+
+// GetAllByUserOrAttendee returns the bookings the user made or attends.
+func (r *MemoryBookingRepository) GetAllByUserOrAttendee(userID string, startTime time.Time) ([]*BookingDetails, error) {
+	startTime = memoryTimestamp(startTime)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return r.getAll(func(e *memoryBooking) bool {
+		if e.Leave.Before(startTime) {
+			return false
+		}
+		return e.UserID == userID || memoryCount(memoryData.bookingAttendees, func(a *BookingAttendee) bool {
+			return a.BookingID == e.ID && a.UserID == userID
+		}) > 0
+	}), nil
+}
+
+func (r *MemoryBookingRepository) GetAllBySeries(seriesID string, startTime time.Time) ([]*BookingDetails, error) {
+	startTime = memoryTimestamp(startTime)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return r.getAll(func(e *memoryBooking) bool {
+		return string(e.SeriesID) == seriesID && !e.Leave.Before(startTime)
+	}), nil
+}
+
+func (r *MemoryBookingRepository) DetachFromSeries(seriesID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	for _, row := range memoryData.bookings {
+		if string(row.SeriesID) == seriesID {
+			row.SeriesID = ""
+		}
+	}
+	return nil
+}
+
+func (r *MemoryBookingRepository) Update(e *Booking) error {
+	if err := r.checkUUIDs(e); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	row := r.find(e.ID)
+	if row == nil {
+		return nil
+	}
+	c := r.copy(e)
+	c.CheckInTime = row.CheckInTime
+	if r.isConflict(c) {
+		return ErrBookingConflict
+	}
+	row.ReminderSent = row.ReminderSent && row.Enter.Equal(c.Enter)
+	row.Booking = *c
+	return nil
+}
+
+// Lock serializes the validation and saving of bookings of the user and of the location,
+// so concurrent requests can't both pass the checks. Unlike the PostgreSQL implementation,
+// the locks only work within this process. The returned function releases the locks.
+func (r *MemoryBookingRepository) Lock(ctx context.Context, userID, locationID string) (func(), error) {
+	ctx, cancel := context.WithTimeout(ctx, BookingLockTimeout)
+	defer cancel()
+	return r.lockLocal(ctx, r.getLockKeys(userID, locationID))
+}
+
+// CheckIn marks the booking as checked in at the specified local wall clock time of the location.
+func (r *MemoryBookingRepository) CheckIn(e *Booking, checkInTime time.Time) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	if row := r.find(e.ID); row != nil {
+		row.CheckInTime = memoryNullTimestamp(&checkInTime)
+	}
+	e.CheckInTime = &checkInTime
+	return nil
+}
+
+// GetAllNotCheckedIn returns all bookings of the organization which have not been checked in
+// and started within the specified range.
+func (r *MemoryBookingRepository) GetAllNotCheckedIn(organizationID string, enterFrom, enterUntil time.Time) ([]*BookingDetails, error) {
+	enterFrom, enterUntil = memoryTimestamp(enterFrom), memoryTimestamp(enterUntil)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return r.getAllByOrg(organizationID, func(e *memoryBooking) bool {
+		return e.CheckInTime == nil && !e.Enter.Before(enterFrom) && !e.Enter.After(enterUntil)
+	}), nil
+}
+
+// GetAllWithoutReminder returns all confirmed bookings of the organization which have not been
+// reminded of and start within the specified range.
+func (r *MemoryBookingRepository) GetAllWithoutReminder(organizationID string, enterFrom, enterUntil time.Time) ([]*BookingDetails, error) {
+	enterFrom, enterUntil = memoryTimestamp(enterFrom), memoryTimestamp(enterUntil)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return r.getAllByOrg(organizationID, func(e *memoryBooking) bool {
+		return !e.ReminderSent && e.Status == BookingStatusConfirmed && !e.Enter.Before(enterFrom) && !e.Enter.After(enterUntil)
+	}), nil
+}
+
+// GetAllPending returns the organization's bookings waiting for approval.
+func (r *MemoryBookingRepository) GetAllPending(organizationID string) ([]*BookingDetails, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return r.getAllByOrg(organizationID, func(e *memoryBooking) bool {
+		return e.Status == BookingStatusPending
+	}), nil
+}
+
+// GetAllApprovalExpired returns the pending bookings of all organizations which have not been
+// approved in time.
+func (r *MemoryBookingRepository) GetAllApprovalExpired(now time.Time) ([]*BookingDetails, error) {
+	now = memoryTimestamp(now)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	result := r.getAll(func(e *memoryBooking) bool {
+		return e.Status == BookingStatusPending && e.ApprovalExpiry != nil && e.ApprovalExpiry.Before(now)
+	})
+	memorySort(result, func(a, b *BookingDetails) bool {
+		return a.ApprovalExpiry.Before(*b.ApprovalExpiry)
+	})
+	return result, nil
+}
+
+func (r *MemoryBookingRepository) Approve(e *Booking) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	if row := r.find(e.ID); row != nil {
+		row.Status = BookingStatusConfirmed
+		row.ApprovalExpiry = nil
+	}
+	e.Status = BookingStatusConfirmed
+	e.ApprovalExpiry = nil
+	return nil
+}
+
+func (r *MemoryBookingRepository) SetReminderSent(e *Booking) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	if row := r.find(e.ID); row != nil {
+		row.ReminderSent = true
+	}
+	return nil
+}
+
+func (r *MemoryBookingRepository) Delete(e *BookingDetails) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.bookingAttendees = memoryDelete(memoryData.bookingAttendees, func(row *BookingAttendee) bool {
+		return row.BookingID == e.ID
+	})
+	memoryData.bookings = memoryDelete(memoryData.bookings, func(row *memoryBooking) bool {
+		return row.ID == e.ID
+	})
+	return nil
+}
+
+func (r *MemoryBookingRepository) GetCount(organizationID string) (int, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return len(r.getAllOfOrg(organizationID, func(e *memoryBooking) bool {
+		return true
+	})), nil
+}
+
+func (r *MemoryBookingRepository) GetCountDateRange(organizationID string, enter, leave time.Time) (int, error) {
+	enter, leave = memoryTimestamp(enter), memoryTimestamp(leave)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return len(r.getAllOfOrg(organizationID, func(e *memoryBooking) bool {
+		return r.isInRange(e, enter, leave)
+	})), nil
+}
+
+func (r *MemoryBookingRepository) GetTotalBookedMinutes(organizationID string, enter, leave time.Time) (int, error) {
+	enter, leave = memoryTimestamp(enter), memoryTimestamp(leave)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	bookings := r.getAllOfOrg(organizationID, func(e *memoryBooking) bool {
+		return r.isInRange(e, enter, leave)
+	})
+	if len(bookings) == 0 {
+		// The sum of no bookings is NULL in PostgreSQL, which can't be scanned
+		return 0, sql.ErrNoRows
+	}
+	var totalBookedMinutes float64
+	for _, e := range bookings {
+		start, end := e.Enter, e.Leave
+		if start.Before(enter) {
+			start = enter
+		}
+		if end.After(leave) {
+			end = leave
+		}
+		totalBookedMinutes += end.Sub(start).Minutes()
+	}
+	return int(math.RoundToEven(totalBookedMinutes)), nil
+}
+
+// GetAllForAnalytics returns all confirmed bookings of the organization (optionally
+// limited to one location) which overlap with the specified range.
+func (r *MemoryBookingRepository) GetAllForAnalytics(organizationID, locationID string, start, end time.Time) ([]*BookingAnalyticsItem, error) {
+	start, end = memoryTimestamp(start), memoryTimestamp(end)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	var result []*BookingAnalyticsItem
+	for _, e := range r.getAllOfOrg(organizationID, func(e *memoryBooking) bool {
+		return e.Status == BookingStatusConfirmed && e.Enter.Before(end) && e.Leave.After(start)
+	}) {
+		space := memorySpaceDetails(e.SpaceID)
+		if locationID != "" && space.Location.ID != locationID {
+			continue
+		}
+		item := &BookingAnalyticsItem{
+			UserID:  e.UserID,
+			SpaceID: e.SpaceID,
+			Enter:   e.Enter,
+			Leave:   e.Leave,
+		}
+		if !e.Created.IsZero() {
+			item.Created = memoryNullTimestamp(&e.Created)
+		}
+		item.Location.ID = space.Location.ID
+		item.Location.OrganizationID = space.Location.OrganizationID
+		item.Location.Timezone = space.Location.Timezone
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// GetTimeRangeByUser returns all bookings by a specific user which overlap with the provided
+// time range. Bookings may end at the same time another one starts.
+func (r *MemoryBookingRepository) GetTimeRangeByUser(userID string, enter time.Time, leave time.Time, excludeBookingID string) ([]*Booking, error) {
+	enter, leave = memoryTimestamp(enter), memoryTimestamp(leave)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return r.getBookings(func(e *memoryBooking) bool {
+		return e.ID != excludeBookingID && e.UserID == userID &&
+			((!enter.After(e.Enter) && leave.After(e.Enter)) ||
+				(enter.Before(e.Leave) && !leave.Before(e.Leave)) ||
+				(!enter.Before(e.Enter) && !leave.After(e.Leave)))
+	}), nil
+}
+
+// GetConflicts returns bookings for a specific space which overlap
+// with the specified enter and leave times.
+func (r *MemoryBookingRepository) GetConflicts(spaceID string, enter time.Time, leave time.Time, excludeBookingID string) ([]*Booking, error) {
+	enter, leave = memoryTimestamp(enter), memoryTimestamp(leave)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return r.getBookings(func(e *memoryBooking) bool {
+		return e.ID != excludeBookingID && e.SpaceID == spaceID && memoryOverlaps(enter, leave, e.Enter, e.Leave)
+	}), nil
+}
+
+// GetConcurrent returns the maximum number of bookings active at the same time in the
+// specified location between enter and leave.
+func (r *MemoryBookingRepository) GetConcurrent(location *Location, enter time.Time, leave time.Time, excludeBookingID string) (int, error) {
+	tz := r.repos.Location.GetTimezone(location)
+	targetTz, err := time.LoadLocation(tz)
+	if err != nil {
+		return 0, err
+	}
+	enter, leave = memoryTimestamp(enter), memoryTimestamp(leave)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	var result []*timeInterval
+	// Bookings only touching the range are never active within it
+	for _, e := range memoryData.bookings {
+		if e.ID == excludeBookingID || !e.Enter.Before(leave) || !e.Leave.After(enter) {
+			continue
+		}
+		space, err := memoryFind(memoryData.spaces, func(space *Space) bool {
+			return space.ID == e.SpaceID
+		})
+		if err != nil || space.LocationID != location.ID {
+			continue
+		}
+		interval := &timeInterval{}
+		interval.Enter, _ = time.ParseInLocation(JsDateTimeFormat, e.Enter.Format(JsDateTimeFormat), targetTz)
+		interval.Leave, _ = time.ParseInLocation(JsDateTimeFormat, e.Leave.Format(JsDateTimeFormat), targetTz)
+		result = append(result, interval)
+	}
+	return getPeakConcurrency(result), nil
+}
+
+// GetPresenceReport returns the number of bookings per user and day. The days are the
+// dates between start and end in the timezone of the location or, if no location is
+// specified, in the timezone of start. Bookings count for the day they start on.
+func (r *MemoryBookingRepository) GetPresenceReport(organizationID string, location *Location, start time.Time, end time.Time, maxResults, offset int) ([]*BookingPresenceItem, error) {
+	res, days, err := r.newPresenceReport(organizationID, location, start, end, maxResults, offset)
+	if err != nil {
+		return nil, err
+	}
+	if len(days) == 0 || len(res) == 0 {
+		return res, nil
+	}
+	items := make(map[string]*BookingPresenceItem, len(res))
+	for _, item := range res {
+		items[item.User.ID] = item
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	const DateFormat string = "2006-01-02"
+	for _, e := range memoryData.bookings {
+		item, ok := items[e.UserID]
+		if !ok {
+			continue
+		}
+		if location != nil {
+			space := memorySpaceDetails(e.SpaceID)
+			if space == nil || space.LocationID != location.ID {
+				continue
+			}
+		}
+		day := e.Enter.Format(DateFormat)
+		if _, ok := item.Presence[day]; ok {
+			item.Presence[day]++
+		}
+	}
+	return res, nil
+}
+
+// isConflict returns true if the booking overlaps with another booking of its space,
+// like the exclusion constraint of the PostgreSQL implementation. The caller must hold
+// the lock.
+func (r *MemoryBookingRepository) isConflict(e *Booking) bool {
+	return memoryCount(memoryData.bookings, func(row *memoryBooking) bool {
+		return row.ID != e.ID && row.SpaceID == e.SpaceID && memoryOverlaps(e.Enter, e.Leave, row.Enter, row.Leave)
+	}) > 0
+}
+
+// isInRange returns true if the booking overlaps with the closed range.
+func (r *MemoryBookingRepository) isInRange(e *memoryBooking, enter, leave time.Time) bool {
+	return memoryOverlaps(enter, leave, e.Enter, e.Leave)
+}
+
+// find returns the stored booking or nil. The caller must hold the lock.
+func (r *MemoryBookingRepository) find(id string) *memoryBooking {
+	e, _ := memoryFind(memoryData.bookings, func(e *memoryBooking) bool {
+		return e.ID == id
+	})
+	return e
+}
+
+// getAll returns the bookings matching the condition joined with their space, location
+// and user, ordered by enter time. The caller must hold the lock.
+func (r *MemoryBookingRepository) getAll(cond func(*memoryBooking) bool) []*BookingDetails {
+	var result []*BookingDetails
+	for _, e := range memoryData.bookings {
+		if !cond(e) {
+			continue
+		}
+		space := memorySpaceDetails(e.SpaceID)
+		user := memoryUserByID(e.UserID)
+		if space == nil || user == nil {
+			continue
+		}
+		result = append(result, &BookingDetails{
+			Space:     *space,
+			UserEmail: user.Email,
+			Booking:   *r.copy(&e.Booking),
+		})
+	}
+	memorySort(result, func(a, b *BookingDetails) bool {
+		return a.Enter.Before(b.Enter)
+	})
+	return result
+}
+
+// getAllByOrg is getAll limited to the bookings of the organization. The caller must hold
+// the lock.
+func (r *MemoryBookingRepository) getAllByOrg(organizationID string, cond func(*memoryBooking) bool) []*BookingDetails {
+	return r.getAll(func(e *memoryBooking) bool {
+		return cond(e) && r.isOfOrg(e, organizationID)
+	})
+}
+
+// getAllOfOrg returns the stored bookings of the organization matching the condition,
+// ordered by enter time, without requiring their user to exist. The caller must hold
+// the lock.
+func (r *MemoryBookingRepository) getAllOfOrg(organizationID string, cond func(*memoryBooking) bool) []*memoryBooking {
+	result := memoryFilter(memoryData.bookings, func(e *memoryBooking) bool {
+		return cond(e) && r.isOfOrg(e, organizationID)
+	})
+	memorySort(result, func(a, b *memoryBooking) bool {
+		return a.Enter.Before(b.Enter)
+	})
+	return result
+}
+
+// getBookings returns copies of the stored bookings matching the condition, ordered by
+// enter time. The caller must hold the lock.
+func (r *MemoryBookingRepository) getBookings(cond func(*memoryBooking) bool) []*Booking {
+	var result []*Booking
+	for _, e := range memoryData.bookings {
+		if cond(e) {
+			result = append(result, r.copy(&e.Booking))
+		}
+	}
+	memorySort(result, func(a, b *Booking) bool {
+		return a.Enter.Before(b.Enter)
+	})
+	return result
+}
+
+// isOfOrg returns true if the booking's space belongs to a location of the organization.
+// The caller must hold the lock.
+func (r *MemoryBookingRepository) isOfOrg(e *memoryBooking, organizationID string) bool {
+	space := memorySpaceDetails(e.SpaceID)
+	return space != nil && space.Location.OrganizationID == organizationID
+}
+
+func (r *MemoryBookingRepository) checkUUIDs(e *Booking) error {
+	if err := memoryCheckUUID(e.UserID, e.SpaceID); err != nil {
+		return err
+	}
+	if e.SeriesID != "" {
+		return memoryCheckUUID(string(e.SeriesID))
+	}
+	return nil
+}
+
+func (r *MemoryBookingRepository) copy(e *Booking) *Booking {
+	c := *e
+	c.Enter = memoryTimestamp(e.Enter)
+	c.Leave = memoryTimestamp(e.Leave)
+	c.CheckInTime = memoryNullTimestamp(e.CheckInTime)
+	c.ApprovalExpiry = memoryNullTimestamp(e.ApprovalExpiry)
+	return &c
+}
@@ -1,82 +1,226 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"errors"
+	"hash/fnv"
+	"log"
 	"math"
-	"strings"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/lib/pq"
 )
 
-type BookingRepository struct {
+type BookingRepository interface {
+	Repository
+	Create(e *Booking) error
+	GetOne(id string) (*BookingDetails, error)
+	GetFirstUpcomingBookingByUserID(userID string) (*BookingDetails, error)
+	GetAllByOrg(organizationID string, startTime, endTime time.Time, status BookingStatus) ([]*BookingDetails, error)
+	GetAllByUser(userID string, startTime time.Time) ([]*BookingDetails, error)
+	GetAllByUserOrAttendee(userID string, startTime time.Time) ([]*BookingDetails, error)
+	GetAllBySeries(seriesID string, startTime time.Time) ([]*BookingDetails, error)
+	DetachFromSeries(seriesID string) error
+	Update(e *Booking) error
+	Lock(ctx context.Context, userID, locationID string) (func(), error)
+	CheckIn(e *Booking, checkInTime time.Time) error
+	GetAllNotCheckedIn(organizationID string, enterFrom, enterUntil time.Time) ([]*BookingDetails, error)
+	GetAllWithoutReminder(organizationID string, enterFrom, enterUntil time.Time) ([]*BookingDetails, error)
+	GetAllPending(organizationID string) ([]*BookingDetails, error)
+	GetAllApprovalExpired(now time.Time) ([]*BookingDetails, error)
+	Approve(e *Booking) error
+	SetReminderSent(e *Booking) error
+	Delete(e *BookingDetails) error
+	GetCount(organizationID string) (int, error)
+	GetCountDateRange(organizationID string, enter, leave time.Time) (int, error)
+	GetTotalBookedMinutes(organizationID string, enter, leave time.Time) (int, error)
+	GetLoad(organizationID string, enter, leave time.Time) (int, error)
+	GetAllForAnalytics(organizationID, locationID string, start, end time.Time) ([]*BookingAnalyticsItem, error)
+	GetTimeRangeByUser(userID string, enter time.Time, leave time.Time, excludeBookingID string) ([]*Booking, error)
+	GetConflicts(spaceID string, enter time.Time, leave time.Time, excludeBookingID string) ([]*Booking, error)
+	GetConcurrent(location *Location, enter time.Time, leave time.Time, excludeBookingID string) (int, error)
+	GetPresenceReport(organizationID string, location *Location, start time.Time, end time.Time, maxResults, offset int) ([]*BookingPresenceItem, error)
 }
 
+type PostgresBookingRepository struct {
+	bookingRepositoryBase
+}
+
+// bookingRepositoryBase implements the booking logic not depending on the storage.
+type bookingRepositoryBase struct {
+	repos *Repositories
+}
+
+type BookingStatus int
+
+const (
+	BookingStatusConfirmed BookingStatus = 1
+	BookingStatusPending   BookingStatus = 2
+)
+
+// Booking reserves a space for its user. For meeting rooms, the user is the
+// organizer of a meeting with a Subject and attendees. A pending booking blocks
+// the space like a confirmed one until it is approved, rejected or its
+// ApprovalExpiry, which is in UTC, has passed.
 type Booking struct {
-	ID      string
-	UserID  string
-	SpaceID string
-	Enter   time.Time
-	Leave   time.Time
+	ID             string
+	UserID         string
+	SpaceID        string
+	Enter          time.Time
+	Leave          time.Time
+	SeriesID       NullString
+	CheckInTime    *time.Time
+	Subject        string
+	Status         BookingStatus
+	ApprovalExpiry *time.Time
 }
 
+// BookingDetails holds the booking's attendees only if loaded by GetOne.
 type BookingDetails struct {
 	Space     SpaceDetails
 	UserEmail string
+	Attendees []*BookingAttendee
 	Booking
 }
 
+// BookingAnalyticsItem is a confirmed booking as needed for utilization analytics.
+// Created is in UTC and nil for bookings created before it was recorded.
+type BookingAnalyticsItem struct {
+	UserID   string
+	SpaceID  string
+	Location Location
+	Enter    time.Time
+	Leave    time.Time
+	Created  *time.Time
+}
+
 type BookingPresenceItem struct {
 	User     *User
 	Presence map[string]int
 }
 
-var bookingRepository *BookingRepository
-var bookingRepositoryOnce sync.Once
+// BookingLockTimeout is the maximum time to wait for the lock acquired by Lock.
+const BookingLockTimeout time.Duration = time.Second * 10
 
-func GetBookingRepository() *BookingRepository {
-	bookingRepositoryOnce.Do(func() {
-		bookingRepository = &BookingRepository{}
-		_, err := GetDatabase().DB().Exec("CREATE TABLE IF NOT EXISTS bookings (" +
-			"id uuid DEFAULT uuid_generate_v4(), " +
-			"user_id uuid NOT NULL, " +
-			"space_id uuid NOT NULL, " +
-			"enter_time TIMESTAMP NOT NULL, " +
-			"leave_time TIMESTAMP NOT NULL, " +
-			"PRIMARY KEY (id))")
-		if err != nil {
-			panic(err)
-		}
-		_, err = GetDatabase().DB().Exec("CREATE INDEX IF NOT EXISTS idx_bookings_user_id ON bookings(user_id)")
-		if err != nil {
-			panic(err)
-		}
-	})
-	return bookingRepository
-}
+// bookingLocks holds a channel per key locked by bookingRepositoryBase.lockLocal.
+var bookingLocks sync.Map
 
-func (r *BookingRepository) RunSchemaUpgrade(curVersion, targetVersion int) {
-	// No updates yet
+// ErrBookingConflict is returned if a booking overlaps with another booking of its space.
+var ErrBookingConflict = errors.New("booking overlaps with another booking of the space")
+
+// BookingExclusionConstraint prevents overlapping bookings of a space. As bookings are
+// stored as local wall clock times, the period is a tsrange. Its bounds are inclusive
+// like the ones of GetConflicts.
+const BookingExclusionConstraint string = "bookings_space_id_period_excl"
+
+func (r *PostgresBookingRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		newInitialSchemaMigration(
+			"CREATE TABLE IF NOT EXISTS bookings ("+
+				"id uuid DEFAULT uuid_generate_v4(), "+
+				"user_id uuid NOT NULL, "+
+				"space_id uuid NOT NULL, "+
+				"enter_time TIMESTAMP NOT NULL, "+
+				"leave_time TIMESTAMP NOT NULL, "+
+				"PRIMARY KEY (id))",
+			"CREATE INDEX IF NOT EXISTS idx_bookings_user_id ON bookings(user_id)",
+		),
+		{
+			Version:     16,
+			Description: "Add series to bookings",
+			Up: []string{
+				"ALTER TABLE bookings " +
+					"ADD COLUMN series_id uuid NULL",
+				"CREATE INDEX IF NOT EXISTS idx_bookings_series_id ON bookings(series_id)",
+			},
+			Down: []string{
+				"DROP INDEX IF EXISTS idx_bookings_series_id",
+				"ALTER TABLE bookings " +
+					"DROP COLUMN series_id",
+			},
+		},
+		{
+			Version:     17,
+			Description: "Add check-in time to bookings",
+			Up: []string{"ALTER TABLE bookings " +
+				"ADD COLUMN checkin_time TIMESTAMP NULL"},
+			Down: []string{"ALTER TABLE bookings " +
+				"DROP COLUMN checkin_time"},
+		},
+		{
+			Version:     18,
+			Description: "Add reminder status to bookings",
+			Up: []string{"ALTER TABLE bookings " +
+				"ADD COLUMN reminder_sent boolean NOT NULL DEFAULT FALSE"},
+			Down: []string{"ALTER TABLE bookings " +
+				"DROP COLUMN reminder_sent"},
+		},
+		{
+			Version:     21,
+			Description: "Add subject to bookings",
+			Up: []string{"ALTER TABLE bookings " +
+				"ADD COLUMN subject VARCHAR NOT NULL DEFAULT ''"},
+			Down: []string{"ALTER TABLE bookings " +
+				"DROP COLUMN subject"},
+		},
+		{
+			Version:     22,
+			Description: "Add approval status to bookings",
+			Up: []string{"ALTER TABLE bookings " +
+				"ADD COLUMN status INTEGER NOT NULL DEFAULT 1, " +
+				"ADD COLUMN approval_expiry TIMESTAMP NULL"},
+			Down: []string{"ALTER TABLE bookings " +
+				"DROP COLUMN status, " +
+				"DROP COLUMN approval_expiry"},
+		},
+		{
+			Version:     24,
+			Description: "Add creation time to bookings",
+			Up: []string{"ALTER TABLE bookings " +
+				"ADD COLUMN created TIMESTAMP NULL"},
+			Down: []string{"ALTER TABLE bookings " +
+				"DROP COLUMN created"},
+		},
+		{
+			// Fails if the database contains overlapping bookings, which have to be
+			// resolved manually then, see README.md. Older versions of the server created
+			// the constraint outside of the migrations, so it's replaced.
+			Version:     25,
+			Description: "Prevent overlapping bookings of a space",
+			Up: []string{
+				"CREATE EXTENSION IF NOT EXISTS btree_gist",
+				"ALTER TABLE bookings DROP CONSTRAINT IF EXISTS " + BookingExclusionConstraint,
+				"ALTER TABLE bookings ADD CONSTRAINT " + BookingExclusionConstraint + " " +
+					"EXCLUDE USING gist (space_id WITH =, tsrange(enter_time, leave_time, '[]') WITH &&)",
+			},
+			Down: []string{"ALTER TABLE bookings DROP CONSTRAINT IF EXISTS " + BookingExclusionConstraint},
+		},
+	}
 }
 
-func (r *BookingRepository) Create(e *Booking) error {
+func (r *PostgresBookingRepository) Create(e *Booking) error {
 	var id string
+	if e.Status == 0 {
+		e.Status = BookingStatusConfirmed
+	}
 	err := GetDatabase().DB().QueryRow("INSERT INTO bookings "+
-		"(user_id, space_id, enter_time, leave_time) "+
-		"VALUES ($1, $2, $3, $4) "+
+		"(user_id, space_id, enter_time, leave_time, series_id, subject, status, approval_expiry, created) "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) "+
 		"RETURNING id",
-		e.UserID, e.SpaceID, e.Enter, e.Leave).Scan(&id)
+		e.UserID, e.SpaceID, e.Enter, e.Leave, CheckNullString(e.SeriesID), e.Subject, e.Status, e.ApprovalExpiry, time.Now().UTC()).Scan(&id)
 	if err != nil {
-		return err
+		return r.getConflictError(err)
 	}
 	e.ID = id
 	return nil
 }
 
-func (r *BookingRepository) GetOne(id string) (*BookingDetails, error) {
+func (r *PostgresBookingRepository) GetOne(id string) (*BookingDetails, error) {
 	e := &BookingDetails{}
-	err := GetDatabase().DB().QueryRow("SELECT bookings.id, bookings.user_id, bookings.space_id, bookings.enter_time, bookings.leave_time, "+
+	err := GetDatabase().DB().QueryRow("SELECT bookings.id, bookings.user_id, bookings.space_id, bookings.enter_time, bookings.leave_time, bookings.series_id, bookings.checkin_time, bookings.subject, bookings.status, bookings.approval_expiry, "+
 		"spaces.id, spaces.location_id, spaces.name, "+
 		"locations.id, locations.organization_id, locations.name, locations.description, locations.tz, "+
 		"users.email "+
@@ -85,7 +229,11 @@ func (r *BookingRepository) GetOne(id string) (*BookingDetails, error) {
 		"INNER JOIN locations ON spaces.location_id = locations.id "+
 		"INNER JOIN users ON bookings.user_id = users.id "+
 		"WHERE bookings.id = $1",
-		id).Scan(&e.ID, &e.UserID, &e.SpaceID, &e.Enter, &e.Leave, &e.Space.ID, &e.Space.LocationID, &e.Space.Name, &e.Space.Location.ID, &e.Space.Location.OrganizationID, &e.Space.Location.Name, &e.Space.Location.Description, &e.Space.Location.Timezone, &e.UserEmail)
+		id).Scan(&e.ID, &e.UserID, &e.SpaceID, &e.Enter, &e.Leave, &e.SeriesID, &e.CheckInTime, &e.Subject, &e.Status, &e.ApprovalExpiry, &e.Space.ID, &e.Space.LocationID, &e.Space.Name, &e.Space.Location.ID, &e.Space.Location.OrganizationID, &e.Space.Location.Name, &e.Space.Location.Description, &e.Space.Location.Timezone, &e.UserEmail)
+	if err != nil {
+		return nil, err
+	}
+	e.Attendees, err = r.repos.BookingAttendee.GetAll(e.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -93,9 +241,9 @@ func (r *BookingRepository) GetOne(id string) (*BookingDetails, error) {
 }
 
 // Get first upcoming booking by user
-func (r *BookingRepository) GetFirstUpcomingBookingByUserID(userID string) (*BookingDetails, error) {
+func (r *PostgresBookingRepository) GetFirstUpcomingBookingByUserID(userID string) (*BookingDetails, error) {
 	e := &BookingDetails{}
-	err := GetDatabase().DB().QueryRow("SELECT bookings.id, bookings.user_id, bookings.space_id, bookings.enter_time, bookings.leave_time, "+
+	err := GetDatabase().DB().QueryRow("SELECT bookings.id, bookings.user_id, bookings.space_id, bookings.enter_time, bookings.leave_time, bookings.series_id, bookings.checkin_time, bookings.subject, bookings.status, bookings.approval_expiry, "+
 		"spaces.id, spaces.location_id, spaces.name, "+
 		"locations.id, locations.organization_id, locations.name, locations.description, locations.tz, "+
 		"users.email "+
@@ -105,16 +253,18 @@ func (r *BookingRepository) GetFirstUpcomingBookingByUserID(userID string) (*Boo
 		"INNER JOIN users ON bookings.user_id = users.id "+
 		"WHERE bookings.user_id = $1 AND bookings.enter_time > $2 "+
 		"ORDER BY bookings.enter_time ASC LIMIT 1",
-		userID, time.Now()).Scan(&e.ID, &e.UserID, &e.SpaceID, &e.Enter, &e.Leave, &e.Space.ID, &e.Space.LocationID, &e.Space.Name, &e.Space.Location.ID, &e.Space.Location.OrganizationID, &e.Space.Location.Name, &e.Space.Location.Description, &e.Space.Location.Timezone, &e.UserEmail)
+		userID, time.Now()).Scan(&e.ID, &e.UserID, &e.SpaceID, &e.Enter, &e.Leave, &e.SeriesID, &e.CheckInTime, &e.Subject, &e.Status, &e.ApprovalExpiry, &e.Space.ID, &e.Space.LocationID, &e.Space.Name, &e.Space.Location.ID, &e.Space.Location.OrganizationID, &e.Space.Location.Name, &e.Space.Location.Description, &e.Space.Location.Timezone, &e.UserEmail)
 	if err != nil {
 		return nil, err
 	}
 	return e, nil
 }
 
-func (r *BookingRepository) GetAllByOrg(organizationID string, startTime, endTime time.Time) ([]*BookingDetails, error) {
+// GetAllByOrg returns the organization's bookings within the range. If status is 0, bookings
+// of all statuses are returned.
+func (r *PostgresBookingRepository) GetAllByOrg(organizationID string, startTime, endTime time.Time, status BookingStatus) ([]*BookingDetails, error) {
 	var result []*BookingDetails
-	rows, err := GetDatabase().DB().Query("SELECT bookings.id, bookings.user_id, bookings.space_id, bookings.enter_time, bookings.leave_time, "+
+	rows, err := GetDatabase().DB().Query("SELECT bookings.id, bookings.user_id, bookings.space_id, bookings.enter_time, bookings.leave_time, bookings.series_id, bookings.checkin_time, bookings.subject, bookings.status, bookings.approval_expiry, "+
 		"spaces.id, spaces.location_id, spaces.name, "+
 		"locations.id, locations.organization_id, locations.name, locations.description, locations.tz, "+
 		"users.email "+
@@ -122,15 +272,15 @@ func (r *BookingRepository) GetAllByOrg(organizationID string, startTime, endTim
 		"INNER JOIN spaces ON bookings.space_id = spaces.id "+
 		"INNER JOIN locations ON spaces.location_id = locations.id "+
 		"INNER JOIN users ON bookings.user_id = users.id "+
-		"WHERE locations.organization_id = $1 AND leave_time >= $2 AND enter_time <= $3 "+
-		"ORDER BY enter_time", organizationID, startTime, endTime)
+		"WHERE locations.organization_id = $1 AND leave_time >= $2 AND enter_time <= $3 AND ($4 = 0 OR bookings.status = $4) "+
+		"ORDER BY enter_time", organizationID, startTime, endTime, status)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	for rows.Next() {
 		e := &BookingDetails{}
-		err = rows.Scan(&e.ID, &e.UserID, &e.SpaceID, &e.Enter, &e.Leave, &e.Space.ID, &e.Space.LocationID, &e.Space.Name, &e.Space.Location.ID, &e.Space.Location.OrganizationID, &e.Space.Location.Name, &e.Space.Location.Description, &e.Space.Location.Timezone, &e.UserEmail)
+		err = rows.Scan(&e.ID, &e.UserID, &e.SpaceID, &e.Enter, &e.Leave, &e.SeriesID, &e.CheckInTime, &e.Subject, &e.Status, &e.ApprovalExpiry, &e.Space.ID, &e.Space.LocationID, &e.Space.Name, &e.Space.Location.ID, &e.Space.Location.OrganizationID, &e.Space.Location.Name, &e.Space.Location.Description, &e.Space.Location.Timezone, &e.UserEmail)
 		if err != nil {
 			return nil, err
 		}
@@ -139,9 +289,9 @@ func (r *BookingRepository) GetAllByOrg(organizationID string, startTime, endTim
 	return result, nil
 }
 
-func (r *BookingRepository) GetAllByUser(userID string, startTime time.Time) ([]*BookingDetails, error) {
+func (r *PostgresBookingRepository) GetAllByUser(userID string, startTime time.Time) ([]*BookingDetails, error) {
 	var result []*BookingDetails
-	rows, err := GetDatabase().DB().Query("SELECT bookings.id, bookings.user_id, bookings.space_id, bookings.enter_time, bookings.leave_time, "+
+	rows, err := GetDatabase().DB().Query("SELECT bookings.id, bookings.user_id, bookings.space_id, bookings.enter_time, bookings.leave_time, bookings.series_id, bookings.checkin_time, bookings.subject, bookings.status, bookings.approval_expiry, "+
 		"spaces.id, spaces.location_id, spaces.name, "+
 		"locations.id, locations.organization_id, locations.name, locations.description, locations.tz, "+
 		"users.email "+
@@ -157,7 +307,35 @@ func (r *BookingRepository) GetAllByUser(userID string, startTime time.Time) ([]
 	defer rows.Close()
 	for rows.Next() {
 		e := &BookingDetails{}
-		err = rows.Scan(&e.ID, &e.UserID, &e.SpaceID, &e.Enter, &e.Leave, &e.Space.ID, &e.Space.LocationID, &e.Space.Name, &e.Space.Location.ID, &e.Space.Location.OrganizationID, &e.Space.Location.Name, &e.Space.Location.Description, &e.Space.Location.Timezone, &e.UserEmail)
+		err = rows.Scan(&e.ID, &e.UserID, &e.SpaceID, &e.Enter, &e.Leave, &e.SeriesID, &e.CheckInTime, &e.Subject, &e.Status, &e.ApprovalExpiry, &e.Space.ID, &e.Space.LocationID, &e.Space.Name, &e.Space.Location.ID, &e.Space.Location.OrganizationID, &e.Space.Location.Name, &e.Space.Location.Description, &e.Space.Location.Timezone, &e.UserEmail)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+// GetAllByUserOrAttendee returns the bookings the user made or attends.
+func (r *PostgresBookingRepository) GetAllByUserOrAttendee(userID string, startTime time.Time) ([]*BookingDetails, error) {
+	var result []*BookingDetails
+	rows, err := GetDatabase().DB().Query("SELECT bookings.id, bookings.user_id, bookings.space_id, bookings.enter_time, bookings.leave_time, bookings.series_id, bookings.checkin_time, bookings.subject, bookings.status, bookings.approval_expiry, "+
+		"spaces.id, spaces.location_id, spaces.name, "+
+		"locations.id, locations.organization_id, locations.name, locations.description, locations.tz, "+
+		"users.email "+
+		"FROM bookings "+
+		"INNER JOIN spaces ON bookings.space_id = spaces.id "+
+		"INNER JOIN locations ON spaces.location_id = locations.id "+
+		"INNER JOIN users ON bookings.user_id = users.id "+
+		"WHERE (bookings.user_id = $1 OR bookings.id IN (SELECT booking_id FROM bookings_attendees WHERE bookings_attendees.user_id = $1)) AND leave_time >= $2 "+
+		"ORDER BY enter_time", userID, startTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &BookingDetails{}
+		err = rows.Scan(&e.ID, &e.UserID, &e.SpaceID, &e.Enter, &e.Leave, &e.SeriesID, &e.CheckInTime, &e.Subject, &e.Status, &e.ApprovalExpiry, &e.Space.ID, &e.Space.LocationID, &e.Space.Name, &e.Space.Location.ID, &e.Space.Location.OrganizationID, &e.Space.Location.Name, &e.Space.Location.Description, &e.Space.Location.Timezone, &e.UserEmail)
 		if err != nil {
 			return nil, err
 		}
@@ -165,23 +343,260 @@ func (r *BookingRepository) GetAllByUser(userID string, startTime time.Time) ([]
 	}
 	return result, nil
 }
-func (r *BookingRepository) Update(e *Booking) error {
+func (r *PostgresBookingRepository) GetAllBySeries(seriesID string, startTime time.Time) ([]*BookingDetails, error) {
+	var result []*BookingDetails
+	rows, err := GetDatabase().DB().Query("SELECT bookings.id, bookings.user_id, bookings.space_id, bookings.enter_time, bookings.leave_time, bookings.series_id, bookings.checkin_time, bookings.subject, bookings.status, bookings.approval_expiry, "+
+		"spaces.id, spaces.location_id, spaces.name, "+
+		"locations.id, locations.organization_id, locations.name, locations.description, locations.tz, "+
+		"users.email "+
+		"FROM bookings "+
+		"INNER JOIN spaces ON bookings.space_id = spaces.id "+
+		"INNER JOIN locations ON spaces.location_id = locations.id "+
+		"INNER JOIN users ON bookings.user_id = users.id "+
+		"WHERE series_id = $1 AND leave_time >= $2 "+
+		"ORDER BY enter_time", seriesID, startTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &BookingDetails{}
+		err = rows.Scan(&e.ID, &e.UserID, &e.SpaceID, &e.Enter, &e.Leave, &e.SeriesID, &e.CheckInTime, &e.Subject, &e.Status, &e.ApprovalExpiry, &e.Space.ID, &e.Space.LocationID, &e.Space.Name, &e.Space.Location.ID, &e.Space.Location.OrganizationID, &e.Space.Location.Name, &e.Space.Location.Description, &e.Space.Location.Timezone, &e.UserEmail)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (r *PostgresBookingRepository) DetachFromSeries(seriesID string) error {
+	_, err := GetDatabase().DB().Exec("UPDATE bookings SET series_id = NULL WHERE series_id = $1", seriesID)
+	return err
+}
+
+func (r *PostgresBookingRepository) Update(e *Booking) error {
 	_, err := GetDatabase().DB().Exec("UPDATE bookings SET "+
 		"user_id = $1, "+
 		"space_id = $2, "+
 		"enter_time = $3, "+
-		"leave_time = $4 "+
-		"WHERE id = $5",
-		e.UserID, e.SpaceID, e.Enter, e.Leave, e.ID)
+		"leave_time = $4, "+
+		"series_id = $5, "+
+		"subject = $6, "+
+		"status = $7, "+
+		"approval_expiry = $8, "+
+		"reminder_sent = (reminder_sent AND enter_time = $3) "+
+		"WHERE id = $9",
+		e.UserID, e.SpaceID, e.Enter, e.Leave, CheckNullString(e.SeriesID), e.Subject, e.Status, e.ApprovalExpiry, e.ID)
+	return r.getConflictError(err)
+}
+
+// getConflictError returns ErrBookingConflict if the error is a violation of the
+// exclusion constraint, otherwise the error itself.
+func (r *PostgresBookingRepository) getConflictError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23P01" && pqErr.Constraint == BookingExclusionConstraint {
+		return ErrBookingConflict
+	}
 	return err
 }
 
-func (r *BookingRepository) Delete(e *BookingDetails) error {
+// Lock serializes the validation and saving of bookings of the user and of the location,
+// so concurrent requests can't both pass the checks. Requests of this process wait for
+// each other without holding a database connection, so a burst of requests can't exhaust
+// the connection pool. Only the one holding the process' lock takes the transaction scoped
+// advisory lock shared with other instances. Waiting is aborted if ctx is done or after
+// BookingLockTimeout. The returned function releases the locks.
+func (r *PostgresBookingRepository) Lock(ctx context.Context, userID, locationID string) (func(), error) {
+	ctx, cancel := context.WithTimeout(ctx, BookingLockTimeout)
+	defer cancel()
+	keys := r.getLockKeys(userID, locationID)
+	unlockLocal, err := r.lockLocal(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	// Not bound to ctx, as the transaction must last until the booking is saved
+	tx, err := GetDatabase().DB().Begin()
+	if err != nil {
+		unlockLocal()
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, "SELECT set_config('lock_timeout', $1, true)", strconv.FormatInt(BookingLockTimeout.Milliseconds(), 10)+"ms")
+	for i := 0; err == nil && i < len(keys); i++ {
+		_, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", r.getLockKey(keys[i]))
+	}
+	if err != nil {
+		tx.Rollback()
+		unlockLocal()
+		return nil, err
+	}
+	unlock := func() {
+		// Ending the transaction releases the advisory locks
+		if err := tx.Commit(); err != nil {
+			log.Println(err)
+		}
+		unlockLocal()
+	}
+	return unlock, nil
+}
+
+func (r *PostgresBookingRepository) getLockKey(key string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	return int64(hash.Sum64())
+}
+
+// CheckIn marks the booking as checked in at the specified local wall clock time of the location.
+func (r *PostgresBookingRepository) CheckIn(e *Booking, checkInTime time.Time) error {
+	_, err := GetDatabase().DB().Exec("UPDATE bookings SET checkin_time = $1 WHERE id = $2", checkInTime, e.ID)
+	if err != nil {
+		return err
+	}
+	e.CheckInTime = &checkInTime
+	return nil
+}
+
+// GetAllNotCheckedIn returns all bookings of the organization which have not been checked in
+// and started within the specified range.
+func (r *PostgresBookingRepository) GetAllNotCheckedIn(organizationID string, enterFrom, enterUntil time.Time) ([]*BookingDetails, error) {
+	var result []*BookingDetails
+	rows, err := GetDatabase().DB().Query("SELECT bookings.id, bookings.user_id, bookings.space_id, bookings.enter_time, bookings.leave_time, bookings.series_id, bookings.checkin_time, bookings.subject, bookings.status, bookings.approval_expiry, "+
+		"spaces.id, spaces.location_id, spaces.name, "+
+		"locations.id, locations.organization_id, locations.name, locations.description, locations.tz, "+
+		"users.email "+
+		"FROM bookings "+
+		"INNER JOIN spaces ON bookings.space_id = spaces.id "+
+		"INNER JOIN locations ON spaces.location_id = locations.id "+
+		"INNER JOIN users ON bookings.user_id = users.id "+
+		"WHERE locations.organization_id = $1 AND bookings.checkin_time IS NULL AND "+
+		"bookings.enter_time >= $2 AND bookings.enter_time <= $3 "+
+		"ORDER BY bookings.enter_time", organizationID, enterFrom, enterUntil)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &BookingDetails{}
+		err = rows.Scan(&e.ID, &e.UserID, &e.SpaceID, &e.Enter, &e.Leave, &e.SeriesID, &e.CheckInTime, &e.Subject, &e.Status, &e.ApprovalExpiry, &e.Space.ID, &e.Space.LocationID, &e.Space.Name, &e.Space.Location.ID, &e.Space.Location.OrganizationID, &e.Space.Location.Name, &e.Space.Location.Description, &e.Space.Location.Timezone, &e.UserEmail)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+// GetAllWithoutReminder returns all confirmed bookings of the organization which have not been
+// reminded of and start within the specified range.
+func (r *PostgresBookingRepository) GetAllWithoutReminder(organizationID string, enterFrom, enterUntil time.Time) ([]*BookingDetails, error) {
+	var result []*BookingDetails
+	rows, err := GetDatabase().DB().Query("SELECT bookings.id, bookings.user_id, bookings.space_id, bookings.enter_time, bookings.leave_time, bookings.series_id, bookings.checkin_time, bookings.subject, bookings.status, bookings.approval_expiry, "+
+		"spaces.id, spaces.location_id, spaces.name, "+
+		"locations.id, locations.organization_id, locations.name, locations.description, locations.tz, "+
+		"users.email "+
+		"FROM bookings "+
+		"INNER JOIN spaces ON bookings.space_id = spaces.id "+
+		"INNER JOIN locations ON spaces.location_id = locations.id "+
+		"INNER JOIN users ON bookings.user_id = users.id "+
+		"WHERE locations.organization_id = $1 AND bookings.reminder_sent = FALSE AND bookings.status = $4 AND "+
+		"bookings.enter_time >= $2 AND bookings.enter_time <= $3 "+
+		"ORDER BY bookings.enter_time", organizationID, enterFrom, enterUntil, BookingStatusConfirmed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &BookingDetails{}
+		err = rows.Scan(&e.ID, &e.UserID, &e.SpaceID, &e.Enter, &e.Leave, &e.SeriesID, &e.CheckInTime, &e.Subject, &e.Status, &e.ApprovalExpiry, &e.Space.ID, &e.Space.LocationID, &e.Space.Name, &e.Space.Location.ID, &e.Space.Location.OrganizationID, &e.Space.Location.Name, &e.Space.Location.Description, &e.Space.Location.Timezone, &e.UserEmail)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+// GetAllPending returns the organization's bookings waiting for approval.
+func (r *PostgresBookingRepository) GetAllPending(organizationID string) ([]*BookingDetails, error) {
+	var result []*BookingDetails
+	rows, err := GetDatabase().DB().Query("SELECT bookings.id, bookings.user_id, bookings.space_id, bookings.enter_time, bookings.leave_time, bookings.series_id, bookings.checkin_time, bookings.subject, bookings.status, bookings.approval_expiry, "+
+		"spaces.id, spaces.location_id, spaces.name, "+
+		"locations.id, locations.organization_id, locations.name, locations.description, locations.tz, "+
+		"users.email "+
+		"FROM bookings "+
+		"INNER JOIN spaces ON bookings.space_id = spaces.id "+
+		"INNER JOIN locations ON spaces.location_id = locations.id "+
+		"INNER JOIN users ON bookings.user_id = users.id "+
+		"WHERE locations.organization_id = $1 AND bookings.status = $2 "+
+		"ORDER BY bookings.enter_time", organizationID, BookingStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &BookingDetails{}
+		err = rows.Scan(&e.ID, &e.UserID, &e.SpaceID, &e.Enter, &e.Leave, &e.SeriesID, &e.CheckInTime, &e.Subject, &e.Status, &e.ApprovalExpiry, &e.Space.ID, &e.Space.LocationID, &e.Space.Name, &e.Space.Location.ID, &e.Space.Location.OrganizationID, &e.Space.Location.Name, &e.Space.Location.Description, &e.Space.Location.Timezone, &e.UserEmail)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+// GetAllApprovalExpired returns the pending bookings of all organizations which have not been
+// approved in time.
+func (r *PostgresBookingRepository) GetAllApprovalExpired(now time.Time) ([]*BookingDetails, error) {
+	var result []*BookingDetails
+	rows, err := GetDatabase().DB().Query("SELECT bookings.id, bookings.user_id, bookings.space_id, bookings.enter_time, bookings.leave_time, bookings.series_id, bookings.checkin_time, bookings.subject, bookings.status, bookings.approval_expiry, "+
+		"spaces.id, spaces.location_id, spaces.name, "+
+		"locations.id, locations.organization_id, locations.name, locations.description, locations.tz, "+
+		"users.email "+
+		"FROM bookings "+
+		"INNER JOIN spaces ON bookings.space_id = spaces.id "+
+		"INNER JOIN locations ON spaces.location_id = locations.id "+
+		"INNER JOIN users ON bookings.user_id = users.id "+
+		"WHERE bookings.status = $1 AND bookings.approval_expiry < $2 "+
+		"ORDER BY bookings.approval_expiry", BookingStatusPending, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &BookingDetails{}
+		err = rows.Scan(&e.ID, &e.UserID, &e.SpaceID, &e.Enter, &e.Leave, &e.SeriesID, &e.CheckInTime, &e.Subject, &e.Status, &e.ApprovalExpiry, &e.Space.ID, &e.Space.LocationID, &e.Space.Name, &e.Space.Location.ID, &e.Space.Location.OrganizationID, &e.Space.Location.Name, &e.Space.Location.Description, &e.Space.Location.Timezone, &e.UserEmail)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (r *PostgresBookingRepository) Approve(e *Booking) error {
+	_, err := GetDatabase().DB().Exec("UPDATE bookings SET status = $1, approval_expiry = NULL WHERE id = $2", BookingStatusConfirmed, e.ID)
+	if err != nil {
+		return err
+	}
+	e.Status = BookingStatusConfirmed
+	e.ApprovalExpiry = nil
+	return nil
+}
+
+func (r *PostgresBookingRepository) SetReminderSent(e *Booking) error {
+	_, err := GetDatabase().DB().Exec("UPDATE bookings SET reminder_sent = TRUE WHERE id = $1", e.ID)
+	return err
+}
+
+func (r *PostgresBookingRepository) Delete(e *BookingDetails) error {
+	if err := r.repos.BookingAttendee.DeleteAll(e.ID); err != nil {
+		return err
+	}
 	_, err := GetDatabase().DB().Exec("DELETE FROM bookings WHERE id = $1", e.ID)
 	return err
 }
 
-func (r *BookingRepository) GetCount(organizationID string) (int, error) {
+func (r *PostgresBookingRepository) GetCount(organizationID string) (int, error) {
 	var res int
 	err := GetDatabase().DB().QueryRow("SELECT COUNT(bookings.id) "+
 		"FROM bookings "+
@@ -192,7 +607,7 @@ func (r *BookingRepository) GetCount(organizationID string) (int, error) {
 	return res, err
 }
 
-func (r *BookingRepository) GetCountDateRange(organizationID string, enter, leave time.Time) (int, error) {
+func (r *PostgresBookingRepository) GetCountDateRange(organizationID string, enter, leave time.Time) (int, error) {
 	var res int
 	err := GetDatabase().DB().QueryRow("SELECT COUNT(bookings.id) "+
 		"FROM bookings "+
@@ -208,7 +623,7 @@ func (r *BookingRepository) GetCountDateRange(organizationID string, enter, leav
 	return res, err
 }
 
-func (r *BookingRepository) GetTotalBookedMinutes(organizationID string, enter, leave time.Time) (int, error) {
+func (r *PostgresBookingRepository) GetTotalBookedMinutes(organizationID string, enter, leave time.Time) (int, error) {
 	var totalBookedMinutes float64
 	err := GetDatabase().DB().QueryRow("SELECT SUM(EXTRACT(EPOCH FROM (LEAST(leave_time, $3) - GREATEST(enter_time, $2)))/60) "+
 		"FROM bookings "+
@@ -224,12 +639,12 @@ func (r *BookingRepository) GetTotalBookedMinutes(organizationID string, enter,
 	return int(math.RoundToEven(totalBookedMinutes)), err
 }
 
-func (r *BookingRepository) GetLoad(organizationID string, enter, leave time.Time) (int, error) {
-	totalBookedMinutes, err := r.GetTotalBookedMinutes(organizationID, enter, leave)
+func (r *bookingRepositoryBase) GetLoad(organizationID string, enter, leave time.Time) (int, error) {
+	totalBookedMinutes, err := r.repos.Booking.GetTotalBookedMinutes(organizationID, enter, leave)
 	if err != nil {
 		return 0, err
 	}
-	numSpaces, err := GetSpaceRepository().GetCount(organizationID)
+	numSpaces, err := r.repos.Space.GetCount(organizationID)
 	if err != nil {
 		return 0, err
 	}
@@ -241,6 +656,62 @@ func (r *BookingRepository) GetLoad(organizationID string, enter, leave time.Tim
 	return int(math.RoundToEven(res)), nil
 }
 
+// getLockKeys returns the keys locked by Lock. They are always acquired in this order to
+// prevent deadlocks.
+func (r *bookingRepositoryBase) getLockKeys(userID, locationID string) []string {
+	return []string{"user:" + userID, "location:" + locationID}
+}
+
+// lockLocal acquires the in-process locks of the keys in order, unless ctx is done before.
+// The returned function releases them.
+func (r *bookingRepositoryBase) lockLocal(ctx context.Context, keys []string) (func(), error) {
+	var acquired []chan struct{}
+	unlock := func() {
+		for i := len(acquired) - 1; i >= 0; i-- {
+			<-acquired[i]
+		}
+	}
+	for _, key := range keys {
+		l, _ := bookingLocks.LoadOrStore(key, make(chan struct{}, 1))
+		lock := l.(chan struct{})
+		select {
+		case lock <- struct{}{}:
+			acquired = append(acquired, lock)
+		case <-ctx.Done():
+			unlock()
+			return nil, ctx.Err()
+		}
+	}
+	return unlock, nil
+}
+
+// GetAllForAnalytics returns all confirmed bookings of the organization (optionally
+// limited to one location) which overlap with the specified range.
+func (r *PostgresBookingRepository) GetAllForAnalytics(organizationID, locationID string, start, end time.Time) ([]*BookingAnalyticsItem, error) {
+	var result []*BookingAnalyticsItem
+	rows, err := GetDatabase().DB().Query("SELECT bookings.user_id, bookings.space_id, bookings.enter_time, bookings.leave_time, bookings.created, "+
+		"locations.id, locations.organization_id, locations.tz "+
+		"FROM bookings "+
+		"INNER JOIN spaces ON spaces.id = bookings.space_id "+
+		"INNER JOIN locations ON locations.id = spaces.location_id "+
+		"WHERE locations.organization_id = $1 AND ($2 = '' OR locations.id::text = $2) AND "+
+		"bookings.status = $3 AND bookings.enter_time < $5 AND bookings.leave_time > $4 "+
+		"ORDER BY bookings.enter_time", organizationID, locationID, BookingStatusConfirmed, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &BookingAnalyticsItem{}
+		err = rows.Scan(&e.UserID, &e.SpaceID, &e.Enter, &e.Leave, &e.Created, &e.Location.ID, &e.Location.OrganizationID, &e.Location.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
 // various scenarios
 //
 //	   |-----------|    (base)
@@ -252,9 +723,9 @@ func (r *BookingRepository) GetLoad(organizationID string, enter, leave time.Tim
 // bigger than should be covered by overlap start / end checks
 //
 // get all bookings by a specific user which overlap with the provided time range
-func (r *BookingRepository) GetTimeRangeByUser(userID string, enter time.Time, leave time.Time, excludeBookingID string) ([]*Booking, error) {
+func (r *PostgresBookingRepository) GetTimeRangeByUser(userID string, enter time.Time, leave time.Time, excludeBookingID string) ([]*Booking, error) {
 	var result []*Booking
-	rows, err := GetDatabase().DB().Query("SELECT id, user_id, space_id, enter_time, leave_time "+
+	rows, err := GetDatabase().DB().Query("SELECT id, user_id, space_id, enter_time, leave_time, series_id, checkin_time, subject, status, approval_expiry "+
 		"FROM bookings "+
 		"WHERE id::text != $4 AND user_id = $1 AND ("+
 		"($2 <= enter_time AND $3 > enter_time) OR "+ // (overlap start, can end at same time as next start)
@@ -268,7 +739,7 @@ func (r *BookingRepository) GetTimeRangeByUser(userID string, enter time.Time, l
 	defer rows.Close()
 	for rows.Next() {
 		e := &Booking{}
-		err = rows.Scan(&e.ID, &e.UserID, &e.SpaceID, &e.Enter, &e.Leave)
+		err = rows.Scan(&e.ID, &e.UserID, &e.SpaceID, &e.Enter, &e.Leave, &e.SeriesID, &e.CheckInTime, &e.Subject, &e.Status, &e.ApprovalExpiry)
 		if err != nil {
 			return nil, err
 		}
@@ -279,9 +750,9 @@ func (r *BookingRepository) GetTimeRangeByUser(userID string, enter time.Time, l
 
 // GetConflicts returns bookings for a specific space which overlap
 // with the specified enter and leave times.
-func (r *BookingRepository) GetConflicts(spaceID string, enter time.Time, leave time.Time, excludeBookingID string) ([]*Booking, error) {
+func (r *PostgresBookingRepository) GetConflicts(spaceID string, enter time.Time, leave time.Time, excludeBookingID string) ([]*Booking, error) {
 	var result []*Booking
-	rows, err := GetDatabase().DB().Query("SELECT id, user_id, space_id, enter_time, leave_time "+
+	rows, err := GetDatabase().DB().Query("SELECT id, user_id, space_id, enter_time, leave_time, series_id, checkin_time, subject, status, approval_expiry "+
 		"FROM bookings "+
 		"WHERE id::text != $1 AND space_id = $2 AND ("+
 		"($3 >= enter_time AND $3 <= leave_time) OR "+
@@ -296,7 +767,7 @@ func (r *BookingRepository) GetConflicts(spaceID string, enter time.Time, leave
 	defer rows.Close()
 	for rows.Next() {
 		e := &Booking{}
-		err = rows.Scan(&e.ID, &e.UserID, &e.SpaceID, &e.Enter, &e.Leave)
+		err = rows.Scan(&e.ID, &e.UserID, &e.SpaceID, &e.Enter, &e.Leave, &e.SeriesID, &e.CheckInTime, &e.Subject, &e.Status, &e.ApprovalExpiry)
 		if err != nil {
 			return nil, err
 		}
@@ -305,148 +776,158 @@ func (r *BookingRepository) GetConflicts(spaceID string, enter time.Time, leave
 	return result, nil
 }
 
-// GetConcurrent returns concurrent bookings for a specific location
-// within the specified enter and leave times.
-func (r *BookingRepository) GetConcurrent(location *Location, enter time.Time, leave time.Time, excludeBookingID string) (int, error) {
-	var getNumActive = func(bookings []*Booking, timestamp time.Time) int {
-		res := 0
-		for _, b := range bookings {
-			if b.Enter.Before(timestamp) && b.Leave.After(timestamp) && !b.Enter.Equal(timestamp) && !b.Leave.Equal(timestamp) {
-				res++
-			}
-		}
-		return res
-	}
-
-	var result []*Booking
-	tz := GetLocationRepository().GetTimezone(location)
+// GetConcurrent returns the maximum number of bookings active at the same time in the
+// specified location between enter and leave.
+func (r *PostgresBookingRepository) GetConcurrent(location *Location, enter time.Time, leave time.Time, excludeBookingID string) (int, error) {
+	var result []*timeInterval
+	tz := r.repos.Location.GetTimezone(location)
 	targetTz, err := time.LoadLocation(tz)
 	if err != nil {
 		return 0, err
 	}
-	rows, err := GetDatabase().DB().Query("SELECT id, user_id, space_id, enter_time, leave_time "+
+	// Bookings only touching the range are never active within it
+	rows, err := GetDatabase().DB().Query("SELECT enter_time, leave_time "+
 		"FROM bookings "+
-		"WHERE id::text != $1 AND space_id IN (SELECT id FROM spaces WHERE location_id = $2) AND ("+
-		"($3 >= enter_time AND $3 <= leave_time) OR "+
-		"($4 >= enter_time AND $4 <= leave_time) OR "+
-		"(enter_time >= $3 AND enter_time <= $4) OR "+
-		"(leave_time >= $3 AND leave_time <= $4)"+
-		") "+
-		"ORDER BY enter_time", excludeBookingID, location.ID, enter, leave)
-	if err == sql.ErrNoRows {
-		return 0, nil
-	}
+		"WHERE id::text != $1 AND space_id IN (SELECT id FROM spaces WHERE location_id = $2) AND "+
+		"enter_time < $4 AND leave_time > $3", excludeBookingID, location.ID, enter, leave)
 	if err != nil {
 		return 0, err
 	}
 	defer rows.Close()
 	for rows.Next() {
-		e := &Booking{}
-		err = rows.Scan(&e.ID, &e.UserID, &e.SpaceID, &e.Enter, &e.Leave)
-		e.Enter, _ = time.ParseInLocation(JsDateTimeFormat, e.Enter.Format(JsDateTimeFormat), targetTz)
-		e.Leave, _ = time.ParseInLocation(JsDateTimeFormat, e.Leave.Format(JsDateTimeFormat), targetTz)
-		if err != nil {
+		e := &timeInterval{}
+		if err := rows.Scan(&e.Enter, &e.Leave); err != nil {
 			return 0, err
 		}
+		e.Enter, _ = time.ParseInLocation(JsDateTimeFormat, e.Enter.Format(JsDateTimeFormat), targetTz)
+		e.Leave, _ = time.ParseInLocation(JsDateTimeFormat, e.Leave.Format(JsDateTimeFormat), targetTz)
 		result = append(result, e)
 	}
+	// All intervals overlap with the range, so the intervals active at the same time
+	// overlap within the range, too
+	return getPeakConcurrency(result), nil
+}
+
+// timeInterval is the time between Enter and Leave, excluding both.
+type timeInterval struct {
+	Enter time.Time
+	Leave time.Time
+}
 
-	max := 0
-	timestamp := enter
-	for timestamp.Before(leave) || timestamp.Equal(leave) {
-		numActive := getNumActive(result, timestamp)
-		if numActive > max {
-			max = numActive
+// getPeakConcurrency returns the maximum number of overlapping intervals. Intervals
+// ending at the same time another one starts don't overlap.
+func getPeakConcurrency(intervals []*timeInterval) int {
+	type event struct {
+		t     time.Time
+		delta int
+	}
+	events := make([]event, 0, len(intervals)*2)
+	for _, interval := range intervals {
+		if !interval.Enter.Before(interval.Leave) {
+			continue
 		}
-		timestamp = timestamp.Add(time.Minute * 1)
+		events = append(events, event{t: interval.Enter, delta: 1}, event{t: interval.Leave, delta: -1})
 	}
-
-	return max, nil
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].t.Equal(events[j].t) {
+			return events[i].delta < events[j].delta
+		}
+		return events[i].t.Before(events[j].t)
+	})
+	cur, res := 0, 0
+	for _, e := range events {
+		cur += e.delta
+		if cur > res {
+			res = cur
+		}
+	}
+	return res
 }
 
-func (r *BookingRepository) GetPresenceReport(organizationID string, location *Location, start time.Time, end time.Time, maxResults, offset int) ([]*BookingPresenceItem, error) {
-	// Build list of users to include in report
-	users, err := GetUserRepository().GetAll(organizationID, maxResults, offset)
+// GetPresenceReport returns the number of bookings per user and day. The days are the
+// dates between start and end in the timezone of the location or, if no location is
+// specified, in the timezone of start. Bookings count for the day they start on.
+func (r *PostgresBookingRepository) GetPresenceReport(organizationID string, location *Location, start time.Time, end time.Time, maxResults, offset int) ([]*BookingPresenceItem, error) {
+	res, days, err := r.newPresenceReport(organizationID, location, start, end, maxResults, offset)
 	if err != nil {
 		return nil, err
 	}
-	userIds := make([]string, len(users))
-	for i, user := range users {
-		userIds[i] = user.ID
+	if len(days) == 0 || len(res) == 0 {
+		return res, nil
 	}
-
-	// Prepare array of days to report
-	var times []time.Time
-	curTime := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
-	var cols strings.Builder
-	const DateFormat string = "2006-01-02"
-	for curTime.Before(end) {
-		times = append(times, curTime)
-		cols.WriteString(", ")
-		cols.WriteString("(SELECT COUNT(*) FROM bookings b2 WHERE b2.user_id = b.user_id AND DATE(b2.enter_time) = '" + curTime.Format(DateFormat) + "'::DATE)")
-		curTime = curTime.AddDate(0, 0, 1)
+	userIds := make([]string, len(res))
+	items := make(map[string]*BookingPresenceItem, len(res))
+	for i, item := range res {
+		userIds[i] = item.User.ID
+		items[item.User.ID] = item
 	}
 
-	// Prepare result
-	res := make([]*BookingPresenceItem, len(users))
-	for i, user := range users {
-		presence := make(map[string]int)
-		for _, time := range times {
-			presence[time.Format(DateFormat)] = 0
-		}
-		item := &BookingPresenceItem{
-			User:     user,
-			Presence: presence,
-		}
-		res[i] = item
-	}
-
-	// Build query
+	// Count bookings per user and day
 	conditions := ""
+	params := []interface{}{pq.Array(userIds), days[0], days[len(days)-1]}
 	if location != nil {
-		conditions = "AND b.space_id IN (SELECT id FROM spaces WHERE location_id = $2) "
-	}
-	stm := "SELECT b.user_id" + cols.String() + " " +
-		"FROM bookings b " +
-		"WHERE b.user_id = ANY($1) " + conditions +
-		"GROUP BY b.user_id"
-	var rows *sql.Rows
-	if location != nil {
-		rows, err = GetDatabase().DB().Query(stm, pq.Array(userIds), location.ID)
-	} else {
-		rows, err = GetDatabase().DB().Query(stm, pq.Array(userIds))
-	}
-	if err == sql.ErrNoRows {
-		return res, nil
+		conditions = "AND b.space_id IN (SELECT id FROM spaces WHERE location_id = $4) "
+		params = append(params, location.ID)
 	}
+	rows, err := GetDatabase().DB().Query("SELECT b.user_id, TO_CHAR(d.day, 'YYYY-MM-DD'), COUNT(*) "+
+		"FROM generate_series($2::TIMESTAMP, $3::TIMESTAMP, INTERVAL '1 day') AS d(day) "+
+		"INNER JOIN bookings b ON b.enter_time >= d.day AND b.enter_time < d.day + INTERVAL '1 day' "+
+		"WHERE b.user_id = ANY($1) "+conditions+
+		"GROUP BY b.user_id, d.day", params...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	for rows.Next() {
-		// Scan row
-		dest := make([]interface{}, len(times)+1)
-		dest[0] = new(string)
-		for i := 1; i < len(times)+1; i++ {
-			dest[i] = new(int)
+		var userID, day string
+		var count int
+		if err := rows.Scan(&userID, &day, &count); err != nil {
+			return nil, err
 		}
-		if err := rows.Scan(dest...); err != nil {
-			continue
+		if item, ok := items[userID]; ok {
+			item.Presence[day] = count
 		}
+	}
+	return res, nil
+}
+
+// newPresenceReport returns the report items of the users to include with a count of
+// zero for each day and the days to report.
+func (r *bookingRepositoryBase) newPresenceReport(organizationID string, location *Location, start time.Time, end time.Time, maxResults, offset int) ([]*BookingPresenceItem, []string, error) {
+	// Build list of users to include in report
+	users, err := r.repos.User.GetAll(organizationID, maxResults, offset)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		// Get
-		var item *BookingPresenceItem = nil
-		for _, i := range res {
-			if i.User.ID == *(dest[0].(*string)) {
-				item = i
-			}
+	// Prepare array of days to report
+	if location != nil {
+		if start, err = getLocalWallTime(r.repos, start, location); err != nil {
+			return nil, nil, err
 		}
-		if item == nil {
-			continue
+		if end, err = getLocalWallTime(r.repos, end, location); err != nil {
+			return nil, nil, err
+		}
+	}
+	var days []string
+	curTime := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	const DateFormat string = "2006-01-02"
+	for curTime.Before(end) {
+		days = append(days, curTime.Format(DateFormat))
+		curTime = curTime.AddDate(0, 0, 1)
+	}
+
+	// Prepare result
+	res := make([]*BookingPresenceItem, len(users))
+	for i, user := range users {
+		presence := make(map[string]int, len(days))
+		for _, day := range days {
+			presence[day] = 0
 		}
-		for i, time := range times {
-			item.Presence[time.Format(DateFormat)] = *(dest[i+1].(*int))
+		res[i] = &BookingPresenceItem{
+			User:     user,
+			Presence: presence,
 		}
 	}
-	return res, nil
+	return res, days, nil
 }
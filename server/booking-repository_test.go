@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"math/rand"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -17,9 +20,9 @@ func TestBookingRepositoryPresenceReport(t *testing.T) {
 		Name:           "Test",
 		OrganizationID: org.ID,
 	}
-	GetLocationRepository().Create(l)
+	testRepositories.Location.Create(l)
 	s1 := &Space{Name: "Test 1", LocationID: l.ID}
-	GetSpaceRepository().Create(s1)
+	testRepositories.Space.Create(s1)
 
 	tomorrow := time.Now().Add(24 * time.Hour)
 	tomorrow = time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 8, 0, 0, 0, tomorrow.Location())
@@ -31,24 +34,24 @@ func TestBookingRepositoryPresenceReport(t *testing.T) {
 		Enter:   tomorrow.Add(0 * time.Hour),
 		Leave:   tomorrow.Add(8 * time.Hour),
 	}
-	GetBookingRepository().Create(b1_1)
+	testRepositories.Booking.Create(b1_1)
 	b1_2 := &Booking{
 		UserID:  user1.ID,
 		SpaceID: s1.ID,
 		Enter:   tomorrow.Add((24 + 0) * time.Hour),
 		Leave:   tomorrow.Add((24 + 8) * time.Hour),
 	}
-	GetBookingRepository().Create(b1_2)
+	testRepositories.Booking.Create(b1_2)
 	b2_1 := &Booking{
 		UserID:  user2.ID,
 		SpaceID: s1.ID,
 		Enter:   tomorrow.Add((24*2 + 0) * time.Hour),
 		Leave:   tomorrow.Add((24*2 + 8) * time.Hour),
 	}
-	GetBookingRepository().Create(b2_1)
+	testRepositories.Booking.Create(b2_1)
 
 	end := tomorrow.Add(24 * 7 * time.Hour)
-	res, err := GetBookingRepository().GetPresenceReport(org.ID, nil, tomorrow, end, 99999, 0)
+	res, err := testRepositories.Booking.GetPresenceReport(org.ID, nil, tomorrow, end, 99999, 0)
 
 	checkTestBool(t, true, err == nil)
 	checkTestInt(t, 3, len(res))
@@ -84,3 +87,198 @@ func TestBookingRepositoryPresenceReport(t *testing.T) {
 	checkTestInt(t, 0, res[2].Presence[tomorrow.Add(24*6*time.Hour).Format(DateFormat)])
 	checkTestInt(t, 0, res[2].Presence[tomorrow.Add(24*7*time.Hour).Format(DateFormat)])
 }
+
+func TestBookingRepositoryPresenceReportLocation(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	user := createTestUserInOrg(org)
+	l1 := &Location{Name: "Test 1", OrganizationID: org.ID, Timezone: "America/New_York"}
+	testRepositories.Location.Create(l1)
+	l2 := &Location{Name: "Test 2", OrganizationID: org.ID, Timezone: "America/New_York"}
+	testRepositories.Location.Create(l2)
+	s1 := &Space{Name: "Test 1", LocationID: l1.ID}
+	testRepositories.Space.Create(s1)
+	s2 := &Space{Name: "Test 2", LocationID: l2.ID}
+	testRepositories.Space.Create(s2)
+	testRepositories.Booking.Create(&Booking{UserID: user.ID, SpaceID: s1.ID, Enter: time.Date(2030, 9, 1, 21, 0, 0, 0, time.UTC), Leave: time.Date(2030, 9, 1, 23, 0, 0, 0, time.UTC)})
+	testRepositories.Booking.Create(&Booking{UserID: user.ID, SpaceID: s2.ID, Enter: time.Date(2030, 9, 2, 8, 0, 0, 0, time.UTC), Leave: time.Date(2030, 9, 2, 17, 0, 0, 0, time.UTC)})
+
+	// The range starts at 8 pm on September 1st in New York
+	res, err := testRepositories.Booking.GetPresenceReport(org.ID, l1, time.Date(2030, 9, 2, 0, 0, 0, 0, time.UTC), time.Date(2030, 9, 4, 0, 0, 0, 0, time.UTC), 99999, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var item *BookingPresenceItem
+	for _, e := range res {
+		if e.User.ID == user.ID {
+			item = e
+		}
+	}
+	checkTestInt(t, 3, len(item.Presence))
+	checkTestInt(t, 1, item.Presence["2030-09-01"])
+	checkTestInt(t, 0, item.Presence["2030-09-02"])
+	checkTestInt(t, 0, item.Presence["2030-09-03"])
+}
+
+func TestBookingRepositoryExclusionConstraint(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	user := createTestUserInOrg(org)
+	l := &Location{Name: "Test", OrganizationID: org.ID}
+	testRepositories.Location.Create(l)
+	s1 := &Space{Name: "Test 1", LocationID: l.ID}
+	testRepositories.Space.Create(s1)
+	s2 := &Space{Name: "Test 2", LocationID: l.ID}
+	testRepositories.Space.Create(s2)
+
+	enter := time.Date(2030, 9, 2, 8, 0, 0, 0, time.UTC)
+	b1 := &Booking{UserID: user.ID, SpaceID: s1.ID, Enter: enter, Leave: enter.Add(4 * time.Hour)}
+	if err := testRepositories.Booking.Create(b1); err != nil {
+		t.Fatal(err)
+	}
+	b2 := &Booking{UserID: user.ID, SpaceID: s1.ID, Enter: enter.Add(2 * time.Hour), Leave: enter.Add(6 * time.Hour)}
+	if err := testRepositories.Booking.Create(b2); err != ErrBookingConflict {
+		t.Fatalf("Expected ErrBookingConflict, got %v", err)
+	}
+	b2.SpaceID = s2.ID
+	if err := testRepositories.Booking.Create(b2); err != nil {
+		t.Fatal(err)
+	}
+	b2.SpaceID = s1.ID
+	if err := testRepositories.Booking.Update(b2); err != ErrBookingConflict {
+		t.Fatalf("Expected ErrBookingConflict, got %v", err)
+	}
+}
+
+func TestBookingRepositoryLock(t *testing.T) {
+	clearTestDB()
+	unlock, err := testRepositories.Booking.Lock(context.Background(), "user1", "location1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Waiting for the lock of the location is aborted with the request
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	if _, err := testRepositories.Booking.Lock(ctx, "user2", "location1"); err == nil {
+		t.Fatal("Expected the lock to be held")
+	}
+
+	// The lock of the user was released again
+	unlock2, err := testRepositories.Booking.Lock(context.Background(), "user2", "location2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock2()
+
+	unlock()
+	unlock, err = testRepositories.Booking.Lock(context.Background(), "user2", "location1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+}
+
+func TestBookingRepositoryConcurrent(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	user := createTestUserInOrg(org)
+	l := &Location{Name: "Test", OrganizationID: org.ID, Timezone: "Europe/Berlin"}
+	testRepositories.Location.Create(l)
+	tz, _ := time.LoadLocation(l.Timezone)
+	day := time.Date(2030, 9, 2, 0, 0, 0, 0, tz)
+	bookings := [][2]int{{8, 12}, {10, 14}, {12, 16}, {14, 18}, {6, 8}}
+	for i, hours := range bookings {
+		s := &Space{Name: "Test " + strconv.Itoa(i), LocationID: l.ID}
+		testRepositories.Space.Create(s)
+		e := &Booking{UserID: user.ID, SpaceID: s.ID, Enter: day.Add(time.Duration(hours[0]) * time.Hour), Leave: day.Add(time.Duration(hours[1]) * time.Hour)}
+		if err := testRepositories.Booking.Create(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tests = []struct {
+		enter    int
+		leave    int
+		expected int
+	}{
+		{0, 24, 2},
+		{12, 12, 1},
+		{8, 9, 1},
+		{11, 13, 2},
+		{18, 20, 0},
+		{5, 8, 1},
+	}
+	for _, test := range tests {
+		res, err := testRepositories.Booking.GetConcurrent(l, day.Add(time.Duration(test.enter)*time.Hour), day.Add(time.Duration(test.leave)*time.Hour), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkTestInt(t, test.expected, res)
+	}
+}
+
+func TestBookingRepositoryPeakConcurrency(t *testing.T) {
+	enter := time.Date(2030, 9, 2, 0, 0, 0, 0, time.UTC)
+	leave := enter.Add(48 * time.Hour)
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		intervals := getTestIntervals(random, enter, 100)
+		checkTestInt(t, getPeakConcurrencyByMinute(intervals, enter, leave), getPeakConcurrency(intervals))
+	}
+	checkTestInt(t, 0, getPeakConcurrency(nil))
+	intervals := []*timeInterval{
+		{Enter: enter, Leave: enter.Add(time.Hour)},
+		{Enter: enter.Add(time.Hour), Leave: enter.Add(2 * time.Hour)},
+	}
+	checkTestInt(t, 1, getPeakConcurrency(intervals))
+}
+
+// getTestIntervals returns random intervals of quarter hours within two days after start.
+// As overlaps last at least a quarter hour, counting by minute finds all of them.
+func getTestIntervals(random *rand.Rand, start time.Time, n int) []*timeInterval {
+	var res []*timeInterval
+	for i := 0; i < n; i++ {
+		enter := start.Add(time.Duration(random.Intn(36*4)) * 15 * time.Minute)
+		leave := enter.Add(time.Duration(1+random.Intn(12*4)) * 15 * time.Minute)
+		res = append(res, &timeInterval{Enter: enter, Leave: leave})
+	}
+	return res
+}
+
+// getPeakConcurrencyByMinute is the previous implementation of GetConcurrent, which counts
+// the active intervals for each minute in the range.
+func getPeakConcurrencyByMinute(intervals []*timeInterval, enter, leave time.Time) int {
+	max := 0
+	for timestamp := enter; !timestamp.After(leave); timestamp = timestamp.Add(time.Minute) {
+		numActive := 0
+		for _, e := range intervals {
+			if e.Enter.Before(timestamp) && e.Leave.After(timestamp) {
+				numActive++
+			}
+		}
+		if numActive > max {
+			max = numActive
+		}
+	}
+	return max
+}
+
+func BenchmarkPeakConcurrency(b *testing.B) {
+	enter := time.Date(2030, 9, 2, 0, 0, 0, 0, time.UTC)
+	intervals := getTestIntervals(rand.New(rand.NewSource(1)), enter, 200)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		getPeakConcurrency(intervals)
+	}
+}
+
+func BenchmarkPeakConcurrencyByMinute(b *testing.B) {
+	enter := time.Date(2030, 9, 2, 0, 0, 0, 0, time.UTC)
+	leave := enter.Add(48 * time.Hour)
+	intervals := getTestIntervals(rand.New(rand.NewSource(1)), enter, 200)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		getPeakConcurrencyByMinute(intervals, enter, leave)
+	}
+}
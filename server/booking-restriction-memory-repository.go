@@ -0,0 +1,89 @@
+package main
+
+type MemoryBookingRestrictionRepository struct {
+	bookingRestrictionRepositoryBase
+}
+
+func (r *MemoryBookingRestrictionRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryBookingRestrictionRepository) SetAll(targetID string, allowGroupIDs, denyGroupIDs []string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.bookingRestrictions = memoryDelete(memoryData.bookingRestrictions, func(row *BookingRestriction) bool {
+		return row.TargetID == targetID
+	})
+	for _, groupID := range allowGroupIDs {
+		if err := r.create(targetID, groupID, true); err != nil {
+			return err
+		}
+	}
+	for _, groupID := range denyGroupIDs {
+		if err := r.create(targetID, groupID, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// create adds a restriction. The caller must hold the lock.
+func (r *MemoryBookingRestrictionRepository) create(targetID, groupID string, allow bool) error {
+	if err := memoryCheckUUID(targetID, groupID); err != nil {
+		return err
+	}
+	if memoryCount(memoryData.bookingRestrictions, func(row *BookingRestriction) bool {
+		return row.TargetID == targetID && row.GroupID == groupID
+	}) > 0 {
+		return memoryUniqueViolation("booking_restrictions_pkey")
+	}
+	memoryData.bookingRestrictions = append(memoryData.bookingRestrictions, &BookingRestriction{
+		TargetID: targetID,
+		GroupID:  groupID,
+		Allow:    allow,
+	})
+	return nil
+}
+
+func (r *MemoryBookingRestrictionRepository) GetAll(targetID string) ([]*BookingRestriction, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryCopy(memoryFilter(memoryData.bookingRestrictions, func(e *BookingRestriction) bool {
+		return e.TargetID == targetID
+	})), nil
+}
+
+func (r *MemoryBookingRestrictionRepository) GetAllInLocation(locationID string) (map[string][]*BookingRestriction, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	result := make(map[string][]*BookingRestriction)
+	for _, e := range memoryData.bookingRestrictions {
+		if e.TargetID != locationID && memoryCount(memoryData.spaces, func(space *Space) bool {
+			return space.ID == e.TargetID && space.LocationID == locationID
+		}) == 0 {
+			continue
+		}
+		c := *e
+		result[e.TargetID] = append(result[e.TargetID], &c)
+	}
+	return result, nil
+}
+
+func (r *MemoryBookingRestrictionRepository) DeleteAll(targetID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.bookingRestrictions = memoryDelete(memoryData.bookingRestrictions, func(row *BookingRestriction) bool {
+		return row.TargetID == targetID
+	})
+	return nil
+}
+
+func (r *MemoryBookingRestrictionRepository) DeleteOfGroup(groupID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.bookingRestrictions = memoryDelete(memoryData.bookingRestrictions, func(row *BookingRestriction) bool {
+		return row.GroupID == groupID
+	})
+	return nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import "slices"
+
+type BookingRestrictionRepository interface {
+	Repository
+	SetAll(targetID string, allowGroupIDs, denyGroupIDs []string) error
+	GetAll(targetID string) ([]*BookingRestriction, error)
+	GetAllInLocation(locationID string) (map[string][]*BookingRestriction, error)
+	DeleteAll(targetID string) error
+	DeleteOfGroup(groupID string) error
+	IsAllowed(userID, locationID, spaceID string) (bool, error)
+	IsAllowedForGroups(groupIDs []string, locationRestrictions, spaceRestrictions []*BookingRestriction) bool
+	GetGroupIDs(userID string) ([]string, error)
+	IsValidGroupList(organizationID string, allowGroupIDs, denyGroupIDs []string) bool
+	Split(list []*BookingRestriction) (allowGroupIDs, denyGroupIDs []string)
+}
+
+type PostgresBookingRestrictionRepository struct {
+	bookingRestrictionRepositoryBase
+}
+
+// bookingRestrictionRepositoryBase evaluates restrictions, whichever implementation stores them.
+type bookingRestrictionRepositoryBase struct {
+	repos *Repositories
+}
+
+// BookingRestriction allows or denies members of a user group to book a location
+// or a space, identified by TargetID.
+type BookingRestriction struct {
+	TargetID string
+	GroupID  string
+	Allow    bool
+}
+
+func (r *PostgresBookingRestrictionRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		{
+			Version:     32,
+			Description: "Add booking restrictions",
+			Up: []string{
+				"CREATE TABLE IF NOT EXISTS booking_restrictions (" +
+					"target_id uuid NOT NULL, " +
+					"group_id uuid NOT NULL, " +
+					"allow boolean NOT NULL, " +
+					"PRIMARY KEY (target_id, group_id))",
+				"CREATE INDEX IF NOT EXISTS idx_booking_restrictions_group_id ON booking_restrictions(group_id)",
+			},
+			Down: []string{"DROP TABLE IF EXISTS booking_restrictions"},
+		},
+	}
+}
+
+// SetAll replaces the allow and deny lists of the location or space.
+func (r *PostgresBookingRestrictionRepository) SetAll(targetID string, allowGroupIDs, denyGroupIDs []string) error {
+	if err := r.DeleteAll(targetID); err != nil {
+		return err
+	}
+	for _, groupID := range allowGroupIDs {
+		if err := r.create(targetID, groupID, true); err != nil {
+			return err
+		}
+	}
+	for _, groupID := range denyGroupIDs {
+		if err := r.create(targetID, groupID, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PostgresBookingRestrictionRepository) create(targetID, groupID string, allow bool) error {
+	_, err := GetDatabase().DB().Exec("INSERT INTO booking_restrictions "+
+		"(target_id, group_id, allow) "+
+		"VALUES ($1, $2, $3)",
+		targetID, groupID, allow)
+	return err
+}
+
+func (r *PostgresBookingRestrictionRepository) GetAll(targetID string) ([]*BookingRestriction, error) {
+	var result []*BookingRestriction
+	rows, err := GetDatabase().DB().Query("SELECT target_id, group_id, allow "+
+		"FROM booking_restrictions "+
+		"WHERE target_id = $1", targetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &BookingRestriction{}
+		err = rows.Scan(&e.TargetID, &e.GroupID, &e.Allow)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+// GetAllInLocation returns the restrictions of the location and all of its spaces, keyed by target ID.
+func (r *PostgresBookingRestrictionRepository) GetAllInLocation(locationID string) (map[string][]*BookingRestriction, error) {
+	result := make(map[string][]*BookingRestriction)
+	rows, err := GetDatabase().DB().Query("SELECT target_id, group_id, allow "+
+		"FROM booking_restrictions "+
+		"WHERE target_id = $1 OR target_id IN (SELECT id FROM spaces WHERE location_id = $1)", locationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &BookingRestriction{}
+		err = rows.Scan(&e.TargetID, &e.GroupID, &e.Allow)
+		if err != nil {
+			return nil, err
+		}
+		result[e.TargetID] = append(result[e.TargetID], e)
+	}
+	return result, nil
+}
+
+func (r *PostgresBookingRestrictionRepository) DeleteAll(targetID string) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM booking_restrictions WHERE target_id = $1", targetID)
+	return err
+}
+
+func (r *PostgresBookingRestrictionRepository) DeleteOfGroup(groupID string) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM booking_restrictions WHERE group_id = $1", groupID)
+	return err
+}
+
+// IsAllowed returns true if the user may book the space in the location. If spaceID is
+// empty, only the location's restrictions are checked.
+func (r *bookingRestrictionRepositoryBase) IsAllowed(userID, locationID, spaceID string) (bool, error) {
+	groupIDs, err := r.GetGroupIDs(userID)
+	if err != nil {
+		return false, err
+	}
+	locationRestrictions, err := r.repos.BookingRestriction.GetAll(locationID)
+	if err != nil {
+		return false, err
+	}
+	var spaceRestrictions []*BookingRestriction
+	if spaceID != "" {
+		if spaceRestrictions, err = r.repos.BookingRestriction.GetAll(spaceID); err != nil {
+			return false, err
+		}
+	}
+	return r.IsAllowedForGroups(groupIDs, locationRestrictions, spaceRestrictions), nil
+}
+
+// IsAllowedForGroups checks the restrictions for a user who is a member of the groups.
+// Members of a denied group of the location or the space are never allowed. If the
+// space has an allow list, the user must be a member of one of its groups, otherwise
+// the location's allow list applies the same way. Without allow lists, everyone not
+// denied is allowed.
+func (r *bookingRestrictionRepositoryBase) IsAllowedForGroups(groupIDs []string, locationRestrictions, spaceRestrictions []*BookingRestriction) bool {
+	for _, list := range [][]*BookingRestriction{locationRestrictions, spaceRestrictions} {
+		for _, e := range list {
+			if !e.Allow && slices.Contains(groupIDs, e.GroupID) {
+				return false
+			}
+		}
+	}
+	for _, list := range [][]*BookingRestriction{spaceRestrictions, locationRestrictions} {
+		hasAllowList := false
+		for _, e := range list {
+			if e.Allow {
+				hasAllowList = true
+				if slices.Contains(groupIDs, e.GroupID) {
+					return true
+				}
+			}
+		}
+		if hasAllowList {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *bookingRestrictionRepositoryBase) GetGroupIDs(userID string) ([]string, error) {
+	groups, err := r.repos.UserGroup.GetAllByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, group := range groups {
+		result = append(result, group.ID)
+	}
+	return result, nil
+}
+
+// IsValidGroupList returns true if all groups belong to the organization and none
+// is both allowed and denied.
+func (r *bookingRestrictionRepositoryBase) IsValidGroupList(organizationID string, allowGroupIDs, denyGroupIDs []string) bool {
+	seen := make(map[string]bool)
+	for _, groupID := range slices.Concat(allowGroupIDs, denyGroupIDs) {
+		if seen[groupID] {
+			return false
+		}
+		seen[groupID] = true
+		group, err := r.repos.UserGroup.GetOne(groupID)
+		if err != nil || group.OrganizationID != organizationID {
+			return false
+		}
+	}
+	return true
+}
+
+// Split returns the group IDs of the allow and deny lists.
+func (r *bookingRestrictionRepositoryBase) Split(list []*BookingRestriction) (allowGroupIDs, denyGroupIDs []string) {
+	allowGroupIDs = []string{}
+	denyGroupIDs = []string{}
+	for _, e := range list {
+		if e.Allow {
+			allowGroupIDs = append(allowGroupIDs, e.GroupID)
+		} else {
+			denyGroupIDs = append(denyGroupIDs, e.GroupID)
+		}
+	}
+	return allowGroupIDs, denyGroupIDs
+}
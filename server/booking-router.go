@@ -1,17 +1,22 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"log"
 	"math"
 	"net/http"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 )
 
 type BookingRouter struct {
+	repos *Repositories
 }
 
 type BookingRequest struct {
@@ -20,8 +25,11 @@ type BookingRequest struct {
 	UserEmail string    `json:"userEmail"`
 }
 
+// CreateBookingRequest keeps the attendees on update if AttendeeEmails is not set.
 type CreateBookingRequest struct {
-	SpaceID string `json:"spaceId" validate:"required"`
+	SpaceID        string   `json:"spaceId" validate:"required"`
+	Subject        string   `json:"subject"`
+	AttendeeEmails []string `json:"attendeeEmails"`
 	BookingRequest
 }
 
@@ -30,20 +38,43 @@ type PreCreateBookingRequest struct {
 	BookingRequest
 }
 
+// GetBookingResponse's user is the organizer if the booking has attendees. A pending
+// booking is rejected automatically if not approved until ApprovalExpiry.
 type GetBookingResponse struct {
+	ID             string           `json:"id"`
+	UserID         string           `json:"userId"`
+	UserEmail      string           `json:"userEmail"`
+	SeriesID       string           `json:"seriesId"`
+	CheckedIn      bool             `json:"checkedIn"`
+	CheckInTime    *time.Time       `json:"checkInTime"`
+	Status         BookingStatus    `json:"status"`
+	ApprovalExpiry *time.Time       `json:"approvalExpiry"`
+	Space          GetSpaceResponse `json:"space"`
+	CreateBookingRequest
+}
+
+type GetNoShowResponse struct {
 	ID        string           `json:"id"`
+	BookingID string           `json:"bookingId"`
 	UserID    string           `json:"userId"`
 	UserEmail string           `json:"userEmail"`
 	Space     GetSpaceResponse `json:"space"`
-	CreateBookingRequest
+	Enter     time.Time        `json:"enter"`
+	Leave     time.Time        `json:"leave"`
+	Released  time.Time        `json:"released"`
 }
 
+// GetBookingFilterRequest returns bookings of all statuses if Status is not set.
 type GetBookingFilterRequest struct {
-	Start      time.Time `json:"start" validate:"required"`
-	End        time.Time `json:"end" validate:"required"`
-	LocationID string    `json:"locationId"`
+	Start      time.Time     `json:"start" validate:"required"`
+	End        time.Time     `json:"end" validate:"required"`
+	LocationID string        `json:"locationId"`
+	Status     BookingStatus `json:"status"`
 }
 
+// PresenceReportMaxRangeDays limits the number of days of a presence report.
+const PresenceReportMaxRangeDays int = 366
+
 type GetPresenceReportResult struct {
 	Users     []GetUserInfoSmall `json:"users"`
 	Dates     []string           `json:"dates"`
@@ -66,8 +97,10 @@ type DebugTimeIssuesResponse struct {
 func (router *BookingRouter) setupRoutes(s *mux.Router) {
 	s.HandleFunc("/debugtimeissues/", router.debugTimeIssues).Methods("POST")
 	s.HandleFunc("/report/presence/", router.getPresenceReport).Methods("POST")
+	s.HandleFunc("/report/noshows/", router.getNoShowReport).Methods("POST")
 	s.HandleFunc("/filter/", router.getFiltered).Methods("POST")
 	s.HandleFunc("/precheck/", router.preBookingCreateCheck).Methods("POST")
+	s.HandleFunc("/{id}/checkin", router.checkIn).Methods("POST")
 	s.HandleFunc("/{id}", router.getOne).Methods("GET")
 	s.HandleFunc("/{id}", router.update).Methods("PUT")
 	s.HandleFunc("/{id}", router.delete).Methods("DELETE")
@@ -103,13 +136,13 @@ func (router *BookingRouter) debugTimeIssues(w http.ResponseWriter, r *http.Requ
 	e := &DebugTimeIssueItem{
 		Created: timeNew,
 	}
-	if err := GetDebugTimeIssuesRepository().Create(e); err != nil {
+	if err := router.repos.DebugTimeIssues.Create(e); err != nil {
 		res.Error = "Could not create database record: " + err.Error()
 		SendJSON(w, res)
 		return
 	}
-	defer GetDebugTimeIssuesRepository().Delete(e)
-	e2, err := GetDebugTimeIssuesRepository().GetOne(e.ID)
+	defer router.repos.DebugTimeIssues.Delete(e)
+	e2, err := router.repos.DebugTimeIssues.GetOne(e.ID)
 	if err != nil {
 		res.Error = "Could not load database record: " + err.Error()
 		SendJSON(w, res)
@@ -127,22 +160,27 @@ func (router *BookingRouter) debugTimeIssues(w http.ResponseWriter, r *http.Requ
 }
 
 func (router *BookingRouter) getFiltered(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanSpaceAdminOrg(user, user.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
 	var m GetBookingFilterRequest
-	if UnmarshalValidateBody(r, &m) != nil {
+	format, err := GetRequestExportFormat(r)
+	if err != nil || UnmarshalValidateBody(r, &m) != nil || !router.isValidStatus(m.Status) {
 		SendBadRequest(w)
 		return
 	}
-	list, err := GetBookingRepository().GetAllByOrg(user.OrganizationID, m.Start, m.End)
+	list, err := router.repos.Booking.GetAllByOrg(user.OrganizationID, m.Start, m.End, m.Status)
 	if err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
 	}
+	if format != ExportFormatJSON {
+		router.exportBookings(w, format, list)
+		return
+	}
 	res := []*GetBookingResponse{}
 	for _, e := range list {
 		m := router.copyToRestModel(e)
@@ -153,18 +191,18 @@ func (router *BookingRouter) getFiltered(w http.ResponseWriter, r *http.Request)
 
 func (router *BookingRouter) getOne(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	e, err := GetBookingRepository().GetOne(vars["id"])
+	e, err := router.repos.Booking.GetOne(vars["id"])
 	if err != nil {
 		log.Println(err)
 		SendNotFound(w)
 		return
 	}
-	requestUser := GetRequestUser(r)
+	requestUser := GetRequestUser(router.repos, r)
 	if !CanAccessOrg(requestUser, requestUser.OrganizationID) && e.UserID != GetRequestUserID(r) {
 		SendForbidden(w)
 		return
 	}
-	if e.UserID != GetRequestUserID(r) && !CanSpaceAdminOrg(requestUser, requestUser.OrganizationID) {
+	if e.UserID != GetRequestUserID(r) && !router.isAttendee(e, requestUser.ID) && !CanSpaceAdminOrg(requestUser, requestUser.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
@@ -173,7 +211,7 @@ func (router *BookingRouter) getOne(w http.ResponseWriter, r *http.Request) {
 }
 
 func (router *BookingRouter) getAll(w http.ResponseWriter, r *http.Request) {
-	list, err := GetBookingRepository().GetAllByUser(GetRequestUserID(r), time.Now().UTC())
+	list, err := router.repos.Booking.GetAllByUserOrAttendee(GetRequestUserID(r), time.Now().UTC())
 	if err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
@@ -181,6 +219,11 @@ func (router *BookingRouter) getAll(w http.ResponseWriter, r *http.Request) {
 	}
 	res := []*GetBookingResponse{}
 	for _, e := range list {
+		if e.Attendees, err = router.repos.BookingAttendee.GetAll(e.ID); err != nil {
+			log.Println(err)
+			SendInternalServerError(w)
+			return
+		}
 		m := router.copyToRestModel(e)
 		res = append(res, m)
 	}
@@ -190,7 +233,7 @@ func (router *BookingRouter) getAll(w http.ResponseWriter, r *http.Request) {
 func (router *BookingRouter) update(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
-	e, err := GetBookingRepository().GetOne(vars["id"])
+	e, err := router.repos.Booking.GetOne(vars["id"])
 	if err != nil {
 		SendNotFound(w)
 		return
@@ -200,17 +243,17 @@ func (router *BookingRouter) update(w http.ResponseWriter, r *http.Request) {
 		SendBadRequest(w)
 		return
 	}
-	space, err := GetSpaceRepository().GetOne(m.SpaceID)
+	space, err := router.repos.Space.GetOne(m.SpaceID)
 	if err != nil {
 		SendBadRequest(w)
 		return
 	}
-	location, err := GetLocationRepository().GetOne(space.LocationID)
+	location, err := router.repos.Location.GetOne(space.LocationID)
 	if err != nil {
 		SendBadRequest(w)
 		return
 	}
-	requestUser := GetRequestUser(r)
+	requestUser := GetRequestUser(router.repos, r)
 	if e.UserID != requestUser.ID && !CanSpaceAdminOrg(requestUser, location.OrganizationID) {
 		SendForbidden(w)
 		return
@@ -222,6 +265,9 @@ func (router *BookingRouter) update(w http.ResponseWriter, r *http.Request) {
 	}
 	eNew.ID = e.ID
 	eNew.UserID = e.UserID
+	eNew.SeriesID = e.SeriesID
+	eNew.Status = e.Status
+	eNew.ApprovalExpiry = e.ApprovalExpiry
 	if m.UserEmail != "" && m.UserEmail != requestUser.Email {
 		if !CanSpaceAdminOrg(requestUser, location.OrganizationID) {
 			SendForbidden(w)
@@ -233,78 +279,120 @@ func (router *BookingRouter) update(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	bookingReq := &BookingRequest{
-		Enter: eNew.Enter,
-		Leave: eNew.Leave,
+	attendeeEmails := m.AttendeeEmails
+	if attendeeEmails == nil {
+		for _, attendee := range e.Attendees {
+			attendeeEmails = append(attendeeEmails, attendee.UserEmail)
+		}
 	}
-
-	if valid, code := router.checkBookingCreateUpdate(bookingReq, location, requestUser, eNew.ID); !valid {
+	attendees, code := router.getAttendees(attendeeEmails, space, eNew.UserID, location.OrganizationID)
+	if code != 0 {
 		SendBadRequestCode(w, code)
 		return
 	}
-	conflicts, err := GetBookingRepository().GetConflicts(eNew.SpaceID, eNew.Enter, eNew.Leave, eNew.ID)
-	if err != nil {
-		log.Println(err)
-		SendInternalServerError(w)
-		return
-	}
-	if len(conflicts) > 0 {
-		SendAleadyExists(w)
+	status, code := router.save(r.Context(), eNew, location, requestUser, func() error {
+		// Approved bookings must be approved again if moved to another space or time
+		enterOld, _ := attachTimezoneInformation(router.repos, e.Enter, &e.Space.Location)
+		leaveOld, _ := attachTimezoneInformation(router.repos, e.Leave, &e.Space.Location)
+		if eNew.SpaceID != e.SpaceID || !eNew.Enter.Equal(enterOld) || !eNew.Leave.Equal(leaveOld) {
+			if err := router.setApprovalStatus(eNew, space, location, requestUser); err != nil {
+				return err
+			}
+		}
+		return router.repos.Booking.Update(eNew)
+	})
+	if status != 0 {
+		router.sendSaveError(w, status, code)
 		return
 	}
-	if err := GetBookingRepository().Update(eNew); err != nil {
+	if err := router.repos.BookingAttendee.SetAll(eNew.ID, router.getUserIDs(attendees)); err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
 	}
+	for _, attendee := range e.Attendees {
+		if attendee.UserID != eNew.UserID && !slices.ContainsFunc(attendees, func(user *User) bool { return user.ID == attendee.UserID }) {
+			router.sendBookingMailTo(e, attendee.UserID, attendee.UserEmail, EmailTemplateBookingDeleted)
+		}
+	}
+	if eNew.UserID != e.UserID {
+		router.sendBookingMailTo(e, e.UserID, e.UserEmail, EmailTemplateBookingDeleted)
+	}
+	router.recordAuditEvent(r, AuditActionBookingUpdated, eNew.ID, router.copyToRestModel(e))
+	if eNew.Status == BookingStatusPending {
+		bookingApprovalRouter := &BookingApprovalRouter{repos: router.repos}
+		bookingApprovalRouter.onBookingPending(eNew.ID, WebhookEventBookingUpdated)
+	} else if eNew.UserID != e.UserID {
+		router.onBookingChanged(eNew.ID, EmailTemplateBookingCreated, WebhookEventBookingUpdated)
+	} else {
+		router.onBookingChanged(eNew.ID, EmailTemplateBookingUpdated, WebhookEventBookingUpdated)
+	}
+	// Moving or shortening the booking may free the previously booked slot
+	waitlistRouter := &WaitlistRouter{repos: router.repos}
+	waitlistRouter.onSpaceFreed(e.SpaceID)
 	SendUpdated(w)
 }
 
 func (router *BookingRouter) delete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	e, err := GetBookingRepository().GetOne(vars["id"])
+	e, err := router.repos.Booking.GetOne(vars["id"])
 	if err != nil {
 		SendNotFound(w)
 		return
 	}
-	space, err := GetSpaceRepository().GetOne(e.SpaceID)
+	space, err := router.repos.Space.GetOne(e.SpaceID)
 	if err != nil {
 		SendBadRequest(w)
 		return
 	}
-	location, err := GetLocationRepository().GetOne(space.LocationID)
+	location, err := router.repos.Location.GetOne(space.LocationID)
 	if err != nil {
 		SendBadRequest(w)
 		return
 	}
-	if !CanAccessOrg(GetRequestUser(r), location.OrganizationID) {
+	if !CanAccessOrg(GetRequestUser(router.repos, r), location.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
-	if (e.UserID != GetRequestUserID(r)) && !CanSpaceAdminOrg(GetRequestUser(r), location.OrganizationID) {
+	if (e.UserID != GetRequestUserID(r)) && !CanSpaceAdminOrg(GetRequestUser(router.repos, r), location.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
-	requestUser := GetRequestUser(r)
+	requestUser := GetRequestUser(router.repos, r)
 	// Check for the date, If the BookingRequest is to close with SettingsMaxHoursBeforeDelete, the Delete can not be performed.
 	if router.isValidBookingHoursBeforeDelete(e, requestUser, location.OrganizationID) {
-		if err := GetBookingRepository().Delete(e); err != nil {
+		if err := router.repos.Booking.Delete(e); err != nil {
 			SendInternalServerError(w)
 			return
 		}
+		router.sendBookingMail(e, EmailTemplateBookingDeleted)
+		recordAuditEvent(router.repos, r, location.OrganizationID, AuditActionBookingDeleted, AuditTargetBooking, e.ID, router.copyToRestModel(e), nil)
+		fireWebhookEvent(router.repos, location.OrganizationID, WebhookEventBookingDeleted, router.copyToRestModel(e))
+		waitlistRouter := &WaitlistRouter{repos: router.repos}
+		waitlistRouter.onSpaceFreed(e.SpaceID)
 		SendUpdated(w)
 		return
 	}
 	SendForbiddenCode(w, ResponseCodeBookingMaxHoursBeforeDelete)
 }
 
-func (router *BookingRouter) checkBookingCreateUpdate(m *BookingRequest, location *Location, requestUser *User, bookingID string) (bool, int) {
+// checkBookingCreateUpdate validates a booking of the space for the booking's user. If
+// spaceID is empty, only the location is checked.
+func (router *BookingRouter) checkBookingCreateUpdate(m *BookingRequest, location *Location, spaceID string, requestUser *User, bookingUserID, bookingID string) (bool, int) {
 	if valid, code := router.isValidBookingRequest(m, requestUser, location.OrganizationID, bookingID); !valid {
 		return false, code
 	}
 	if !router.isValidConcurrent(m, location, bookingID) {
 		return false, ResponseCodeBookingLocationMaxConcurrent
 	}
+	allowed, err := router.repos.BookingRestriction.IsAllowed(bookingUserID, location.ID, spaceID)
+	if err != nil {
+		log.Println(err)
+		return false, ResponseCodeBookingSpaceRestricted
+	}
+	if !allowed {
+		return false, ResponseCodeBookingSpaceRestricted
+	}
 	return true, 0
 }
 
@@ -314,22 +402,22 @@ func (router *BookingRouter) preBookingCreateCheck(w http.ResponseWriter, r *htt
 		SendBadRequest(w)
 		return
 	}
-	location, err := GetLocationRepository().GetOne(m.LocationID)
+	location, err := router.repos.Location.GetOne(m.LocationID)
 	if err != nil {
 		SendBadRequest(w)
 		return
 	}
-	requestUser := GetRequestUser(r)
+	requestUser := GetRequestUser(router.repos, r)
 	if !CanAccessOrg(requestUser, location.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
-	enterNew, err := attachTimezoneInformation(m.Enter, location)
+	enterNew, err := attachTimezoneInformation(router.repos, m.Enter, location)
 	if err != nil {
 		SendInternalServerError(w)
 		return
 	}
-	leaveNew, err := attachTimezoneInformation(m.Leave, location)
+	leaveNew, err := attachTimezoneInformation(router.repos, m.Leave, location)
 	if err != nil {
 		SendInternalServerError(w)
 		return
@@ -338,7 +426,7 @@ func (router *BookingRouter) preBookingCreateCheck(w http.ResponseWriter, r *htt
 		Enter: enterNew,
 		Leave: leaveNew,
 	}
-	if valid, code := router.checkBookingCreateUpdate(bookingReq, location, requestUser, ""); !valid {
+	if valid, code := router.checkBookingCreateUpdate(bookingReq, location, "", requestUser, requestUser.ID, ""); !valid {
 		SendBadRequestCode(w, code)
 		return
 	}
@@ -351,17 +439,17 @@ func (router *BookingRouter) create(w http.ResponseWriter, r *http.Request) {
 		SendBadRequest(w)
 		return
 	}
-	space, err := GetSpaceRepository().GetOne(m.SpaceID)
+	space, err := router.repos.Space.GetOne(m.SpaceID)
 	if err != nil {
 		SendBadRequest(w)
 		return
 	}
-	location, err := GetLocationRepository().GetOne(space.LocationID)
+	location, err := router.repos.Location.GetOne(space.LocationID)
 	if err != nil {
 		SendBadRequest(w)
 		return
 	}
-	requestUser := GetRequestUser(r)
+	requestUser := GetRequestUser(router.repos, r)
 	if !CanAccessOrg(requestUser, location.OrganizationID) {
 		SendForbidden(w)
 		return
@@ -383,32 +471,430 @@ func (router *BookingRouter) create(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	attendees, code := router.getAttendees(m.AttendeeEmails, space, e.UserID, location.OrganizationID)
+	if code != 0 {
+		SendBadRequestCode(w, code)
+		return
+	}
+	status, code := router.save(r.Context(), e, location, requestUser, func() error {
+		if err := router.setApprovalStatus(e, space, location, requestUser); err != nil {
+			return err
+		}
+		return router.repos.Booking.Create(e)
+	})
+	if status != 0 {
+		router.sendSaveError(w, status, code)
+		return
+	}
+	if err := router.repos.BookingAttendee.SetAll(e.ID, router.getUserIDs(attendees)); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	if err := router.autoCheckIn(e, location); err != nil {
+		log.Println(err)
+	}
+	router.recordAuditEvent(r, AuditActionBookingCreated, e.ID, nil)
+	if e.Status == BookingStatusPending {
+		bookingApprovalRouter := &BookingApprovalRouter{repos: router.repos}
+		bookingApprovalRouter.onBookingPending(e.ID, WebhookEventBookingCreated)
+	} else {
+		router.onBookingChanged(e.ID, EmailTemplateBookingCreated, WebhookEventBookingCreated)
+	}
+	SendCreated(w, e.ID)
+}
+
+// save validates the booking and calls the save function while holding the booking lock
+// of the user and the location. This prevents concurrent requests from both passing the
+// checks, e.g. for conflicts or the maximum number of bookings. If the booking is invalid
+// or can't be saved, the HTTP status and error code to send are returned.
+func (router *BookingRouter) save(ctx context.Context, e *Booking, location *Location, requestUser *User, save func() error) (int, int) {
+	unlock, err := router.repos.Booking.Lock(ctx, e.UserID, location.ID)
+	if err != nil {
+		log.Println(err)
+		return http.StatusInternalServerError, 0
+	}
+	defer unlock()
 	bookingReq := &BookingRequest{
 		Enter: e.Enter,
 		Leave: e.Leave,
 	}
-
-	if valid, code := router.checkBookingCreateUpdate(bookingReq, location, requestUser, ""); !valid {
+	if valid, code := router.checkBookingCreateUpdate(bookingReq, location, e.SpaceID, requestUser, e.UserID, e.ID); !valid {
+		return http.StatusBadRequest, code
+	}
+	conflicts, err := router.repos.Booking.GetConflicts(e.SpaceID, e.Enter, e.Leave, e.ID)
+	if err != nil {
+		log.Println(err)
+		return http.StatusInternalServerError, 0
+	}
+	if len(conflicts) > 0 {
+		return http.StatusConflict, ResponseCodeBookingSlotConflict
+	}
+	held, err := router.repos.Waitlist.HasPendingOffer(e.SpaceID, e.Enter, e.Leave, e.UserID)
+	if err != nil {
+		log.Println(err)
+		return http.StatusInternalServerError, 0
+	}
+	if held {
+		return http.StatusConflict, 0
+	}
+	if err := save(); err != nil {
+		// The exclusion constraint catches conflicts with bookings saved bypassing the lock
+		if errors.Is(err, ErrBookingConflict) {
+			return http.StatusConflict, ResponseCodeBookingSlotConflict
+		}
 		log.Println(err)
+		return http.StatusInternalServerError, 0
+	}
+	return 0, 0
+}
+
+func (router *BookingRouter) sendSaveError(w http.ResponseWriter, status, code int) {
+	switch {
+	case status == http.StatusBadRequest:
 		SendBadRequestCode(w, code)
+	case status == http.StatusConflict && code != 0:
+		SendAlreadyExistsCode(w, code)
+	case status == http.StatusConflict:
+		SendAleadyExists(w)
+	default:
+		SendInternalServerError(w)
+	}
+}
+
+func (router *BookingRouter) checkIn(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	e, err := router.repos.Booking.GetOne(vars["id"])
+	if err != nil {
+		log.Println(err)
+		SendNotFound(w)
+		return
+	}
+	if e.UserID != GetRequestUserID(r) {
+		SendForbidden(w)
 		return
 	}
-	conflicts, err := GetBookingRepository().GetConflicts(e.SpaceID, e.Enter, e.Leave, "")
+	orgID := e.Space.Location.OrganizationID
+	enabled, _ := router.repos.Settings.GetBool(orgID, SettingEnableCheckIn.Name)
+	if !enabled {
+		SendBadRequest(w)
+		return
+	}
+	if e.CheckInTime != nil {
+		SendUpdated(w)
+		return
+	}
+	if e.Status == BookingStatusPending {
+		SendBadRequestCode(w, ResponseCodeBookingPendingApproval)
+		return
+	}
+	now, err := getLocalWallTime(router.repos, time.Now(), &e.Space.Location)
 	if err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
 	}
-	if len(conflicts) > 0 {
-		SendAleadyExists(w)
+	window, _ := router.repos.Settings.GetInt(orgID, SettingCheckInWindowMinutes.Name)
+	if now.Before(e.Enter.Add(time.Minute*time.Duration(-window))) || !now.Before(e.Leave) {
+		SendBadRequestCode(w, ResponseCodeBookingCheckInNotPossible)
 		return
 	}
-	if err := GetBookingRepository().Create(e); err != nil {
+	if err := router.repos.Booking.CheckIn(&e.Booking, now); err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
 	}
-	SendCreated(w, e.ID)
+	SendUpdated(w)
+}
+
+// autoCheckIn checks in bookings which are created or approved when the check-in window has
+// already opened. Otherwise, bookings made on short notice would be released right away.
+func (router *BookingRouter) autoCheckIn(e *Booking, location *Location) error {
+	enabled, _ := router.repos.Settings.GetBool(location.OrganizationID, SettingEnableCheckIn.Name)
+	if !enabled || e.Status == BookingStatusPending {
+		return nil
+	}
+	window, _ := router.repos.Settings.GetInt(location.OrganizationID, SettingCheckInWindowMinutes.Name)
+	if time.Now().Before(e.Enter.Add(time.Minute * time.Duration(-window))) {
+		return nil
+	}
+	now, err := getLocalWallTime(router.repos, time.Now(), location)
+	if err != nil {
+		return err
+	}
+	return router.repos.Booking.CheckIn(e, now)
+}
+
+// releaseNoShows deletes bookings which have not been checked in within the check-in window
+// plus the grace period, so that the space becomes available again, and records them as no-shows.
+func (router *BookingRouter) releaseNoShows() error {
+	orgIDs, err := router.repos.Settings.GetOrganizationIDsByValue(SettingEnableAutoRelease.Name, "1")
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for _, orgID := range orgIDs {
+		enabled, _ := router.repos.Settings.GetBool(orgID, SettingEnableCheckIn.Name)
+		if !enabled {
+			continue
+		}
+		window, _ := router.repos.Settings.GetInt(orgID, SettingCheckInWindowMinutes.Name)
+		grace, _ := router.repos.Settings.GetInt(orgID, SettingAutoReleaseGraceMinutes.Name)
+		timeout := time.Minute * time.Duration(window+grace)
+		// Bookings which have started up to two days ago are released as well if they
+		// have been missed, e.g. because the server was down
+		from, until := getWallClockSearchRange(now.Add(-timeout).Add(-48*time.Hour), now)
+		list, err := router.repos.Booking.GetAllNotCheckedIn(orgID, from, until)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		for _, e := range list {
+			localNow, err := getLocalWallTime(router.repos, now, &e.Space.Location)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			if localNow.Before(e.Enter.Add(timeout)) || !localNow.Before(e.Leave) {
+				continue
+			}
+			noShow := &NoShow{
+				UserID:    e.UserID,
+				SpaceID:   e.SpaceID,
+				BookingID: e.ID,
+				Enter:     e.Enter,
+				Leave:     e.Leave,
+				Released:  localNow,
+			}
+			if err := router.repos.NoShow.Create(noShow); err != nil {
+				log.Println(err)
+				continue
+			}
+			if err := router.repos.Booking.Delete(e); err != nil {
+				log.Println(err)
+				continue
+			}
+			waitlistRouter := &WaitlistRouter{repos: router.repos}
+			waitlistRouter.onSpaceFreed(e.SpaceID)
+		}
+	}
+	return nil
+}
+
+// cancelFutureBookings deletes all bookings of the user which have not ended yet.
+// Users and webhooks are notified as if the bookings were cancelled manually.
+func (router *BookingRouter) cancelFutureBookings(user *User) error {
+	now := time.Now().UTC()
+	from, _ := getWallClockSearchRange(now, now)
+	list, err := router.repos.Booking.GetAllByUser(user.ID, from)
+	if err != nil {
+		return err
+	}
+	for _, e := range list {
+		localNow, err := getLocalWallTime(router.repos, now, &e.Space.Location)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if !e.Leave.After(localNow) {
+			continue
+		}
+		// Attendees are removed with the booking, but must be notified as well
+		if e.Attendees, err = router.repos.BookingAttendee.GetAll(e.ID); err != nil {
+			return err
+		}
+		if err := router.repos.Booking.Delete(e); err != nil {
+			return err
+		}
+		router.sendBookingMail(e, EmailTemplateBookingDeleted)
+		fireWebhookEvent(router.repos, e.Space.Location.OrganizationID, WebhookEventBookingDeleted, router.copyToRestModel(e))
+		waitlistRouter := &WaitlistRouter{repos: router.repos}
+		waitlistRouter.onSpaceFreed(e.SpaceID)
+	}
+	return nil
+}
+
+// sendBookingReminders notifies users of bookings starting within the organization's configured number of hours.
+func (router *BookingRouter) sendBookingReminders() error {
+	orgIDs, err := router.repos.Organization.GetAllIDs()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for _, orgID := range orgIDs {
+		hours, _ := router.repos.Settings.GetInt(orgID, SettingBookingReminderHours.Name)
+		if hours <= 0 {
+			continue
+		}
+		reminderTime := time.Hour * time.Duration(hours)
+		from, until := getWallClockSearchRange(now, now.Add(reminderTime))
+		list, err := router.repos.Booking.GetAllWithoutReminder(orgID, from, until)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		for _, e := range list {
+			localNow, err := getLocalWallTime(router.repos, now, &e.Space.Location)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			if localNow.Before(e.Enter.Add(-reminderTime)) || !localNow.Before(e.Enter) {
+				continue
+			}
+			if err := router.repos.Booking.SetReminderSent(&e.Booking); err != nil {
+				log.Println(err)
+				continue
+			}
+			router.sendBookingMail(e, EmailTemplateBookingReminder)
+		}
+	}
+	return nil
+}
+
+// setApprovalStatus makes the booking pending if the space requires approval, unless
+// the requesting user is an approver of the location. Pending bookings expire after
+// the organization's configured number of hours, but not later than they start.
+func (router *BookingRouter) setApprovalStatus(e *Booking, space *Space, location *Location, requestUser *User) error {
+	e.Status = BookingStatusConfirmed
+	e.ApprovalExpiry = nil
+	if !space.RequiresApproval {
+		return nil
+	}
+	canApprove, err := router.repos.LocationApprover.CanApprove(requestUser, location)
+	if err != nil {
+		return err
+	}
+	if canApprove {
+		return nil
+	}
+	expiry := e.Enter.UTC()
+	hours, _ := router.repos.Settings.GetInt(location.OrganizationID, SettingApprovalExpiryHours.Name)
+	if hours > 0 && time.Now().UTC().Add(time.Hour*time.Duration(hours)).Before(expiry) {
+		expiry = time.Now().UTC().Add(time.Hour * time.Duration(hours))
+	}
+	e.Status = BookingStatusPending
+	e.ApprovalExpiry = &expiry
+	return nil
+}
+
+// recordAuditEvent records an action of the request's user on the booking. The
+// booking's state after the action is read from the database.
+func (router *BookingRouter) recordAuditEvent(r *http.Request, action, bookingID string, before interface{}) {
+	e, err := router.repos.Booking.GetOne(bookingID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	recordAuditEvent(router.repos, r, e.Space.Location.OrganizationID, action, AuditTargetBooking, e.ID, before, router.copyToRestModel(e))
+}
+
+// onBookingChanged sends the notification email and fires the webhook event for a created or updated booking.
+func (router *BookingRouter) onBookingChanged(bookingID, templateFile, event string) {
+	e, err := router.repos.Booking.GetOne(bookingID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	router.sendBookingMail(e, templateFile)
+	fireWebhookEvent(router.repos, e.Space.Location.OrganizationID, event, router.copyToRestModel(e))
+}
+
+// sendBookingMail notifies the booking's user and attendees. Attendees are loaded
+// if the booking was not retrieved with them.
+func (router *BookingRouter) sendBookingMail(e *BookingDetails, templateFile string) {
+	router.sendBookingMailTo(e, e.UserID, e.UserEmail, templateFile)
+	if e.Attendees == nil {
+		attendees, err := router.repos.BookingAttendee.GetAll(e.ID)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		e.Attendees = attendees
+	}
+	for _, attendee := range e.Attendees {
+		router.sendBookingMailTo(e, attendee.UserID, attendee.UserEmail, templateFile)
+	}
+}
+
+// sendBookingMailTo notifies a user of the booking unless they opted out of the notification type.
+// An iCalendar invite or cancellation is attached to keep the user's calendar in sync.
+// Failures are logged only, as notifications must not affect the booking itself.
+func (router *BookingRouter) sendBookingMailTo(e *BookingDetails, userID, email, templateFile string) {
+	preference := PreferenceMailBookingConfirmation
+	if templateFile == EmailTemplateBookingReminder {
+		preference = PreferenceMailBookingReminder
+	}
+	if value, err := router.repos.UserPreferences.Get(userID, preference.Name); err == nil && value == "0" {
+		return
+	}
+	org, err := router.repos.Organization.GetOne(e.Space.Location.OrganizationID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	method := "REQUEST"
+	if templateFile == EmailTemplateBookingDeleted {
+		method = "CANCEL"
+	}
+	icalRouter := &ICalRouter{repos: router.repos}
+	attachment := &EmailAttachment{
+		Filename:    strings.ToLower(method) + ".ics",
+		ContentType: "text/calendar; charset=UTF-8; method=" + method,
+		Data:        []byte(icalRouter.renderInvite(e, method)),
+	}
+	vars := map[string]string{
+		"recipientName":  email,
+		"recipientEmail": email,
+		"spaceName":      e.Space.Name,
+		"locationName":   e.Space.Location.Name,
+		"enter":          e.Enter.Format("2006-01-02 15:04"),
+		"leave":          e.Leave.Format("2006-01-02 15:04"),
+		"timezone":       router.repos.Location.GetTimezone(&e.Space.Location),
+	}
+	if err := sendEmailWithAttachment(email, GetConfig().SMTPSenderAddress, templateFile, org.Language, vars, attachment); err != nil {
+		log.Println(err)
+	}
+}
+
+// getAttendees resolves the attendees' email addresses to users of the organization,
+// skipping the organizer. Only meeting rooms can have attendees, and together with
+// the organizer they must not exceed the room's capacity. A response code is returned
+// if the attendees are not valid.
+func (router *BookingRouter) getAttendees(emails []string, space *Space, organizerID, organizationID string) ([]*User, int) {
+	res := []*User{}
+	for _, email := range emails {
+		user, err := router.repos.User.GetByEmail(email)
+		if user == nil || err != nil || user.OrganizationID != organizationID {
+			return nil, ResponseCodeBookingInvalidAttendees
+		}
+		if user.ID == organizerID || slices.ContainsFunc(res, func(item *User) bool { return item.ID == user.ID }) {
+			continue
+		}
+		res = append(res, user)
+	}
+	if len(res) == 0 {
+		return res, 0
+	}
+	if space.Type != SpaceTypeMeetingRoom {
+		return nil, ResponseCodeBookingInvalidAttendees
+	}
+	if uint(len(res)+1) > space.Capacity {
+		return nil, ResponseCodeBookingCapacityExceeded
+	}
+	return res, 0
+}
+
+func (router *BookingRouter) getUserIDs(users []*User) []string {
+	res := []string{}
+	for _, user := range users {
+		res = append(res, user.ID)
+	}
+	return res
+}
+
+func (router *BookingRouter) isAttendee(e *BookingDetails, userID string) bool {
+	return slices.ContainsFunc(e.Attendees, func(attendee *BookingAttendee) bool { return attendee.UserID == userID })
 }
 
 func (router *BookingRouter) bookForUser(requestUser *User, userEmail string, w http.ResponseWriter) (string, error) {
@@ -416,22 +902,22 @@ func (router *BookingRouter) bookForUser(requestUser *User, userEmail string, w
 		SendForbidden(w)
 		return "", errors.New("Forbidden")
 	}
-	bookForUser, err := GetUserRepository().GetByEmail(userEmail)
+	bookForUser, err := router.repos.User.GetByEmail(userEmail)
 	if bookForUser == nil || err != nil {
-		org, err := GetOrganizationRepository().GetOne(requestUser.OrganizationID)
+		org, err := router.repos.Organization.GetOne(requestUser.OrganizationID)
 		if err != nil || org == nil {
 			SendInternalServerError(w)
 			return "", errors.New("InternalServerError")
 		}
-		if allowed, _ := GetSettingsRepository().GetBool(org.ID, SettingAllowBookingsNonExistingUsers.Name); !allowed {
+		if allowed, _ := router.repos.Settings.GetBool(org.ID, SettingAllowBookingsNonExistingUsers.Name); !allowed {
 			SendForbidden(w)
 			return "", errors.New("Forbidden")
 		}
-		if !GetUserRepository().canCreateUser(org) {
+		if !router.repos.User.canCreateUser(org) {
 			SendInternalServerError(w)
 			return "", errors.New("InternalServerError")
 		}
-		if !GetOrganizationRepository().isValidEmailForOrg(userEmail, org) {
+		if !router.repos.Organization.isValidEmailForOrg(userEmail, org) {
 			SendBadRequest(w)
 			return "", errors.New("BadRequest")
 		}
@@ -441,12 +927,12 @@ func (router *BookingRouter) bookForUser(requestUser *User, userEmail string, w
 			OrganizationID: org.ID,
 			Role:           UserRoleUser,
 		}
-		err = GetUserRepository().Create(user)
+		err = router.repos.User.Create(user)
 		if err != nil {
 			SendInternalServerError(w)
 			return "", errors.New("InternalServerError")
 		}
-		bookForUser, err = GetUserRepository().GetByEmail(userEmail)
+		bookForUser, err = router.repos.User.GetByEmail(userEmail)
 		if err != nil {
 			SendInternalServerError(w)
 			return "", errors.New("InternalServerError")
@@ -461,29 +947,34 @@ func (router *BookingRouter) bookForUser(requestUser *User, userEmail string, w
 }
 
 func (router *BookingRouter) getPresenceReport(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanSpaceAdminOrg(user, user.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
 	var m GetBookingFilterRequest
-	if UnmarshalValidateBody(r, &m) != nil {
+	format, err := GetRequestExportFormat(r)
+	if err != nil || UnmarshalValidateBody(r, &m) != nil {
 		SendBadRequest(w)
 		return
 	}
 	var location *Location = nil
 	if m.LocationID != "" {
-		location, _ = GetLocationRepository().GetOne(m.LocationID)
+		location, _ = router.repos.Location.GetOne(m.LocationID)
 		if location == nil {
 			SendNotFound(w)
 			return
 		}
-		if !GetUserRepository().isSuperAdmin(user) && location.OrganizationID != user.OrganizationID {
+		if !router.repos.User.isSuperAdmin(user) && location.OrganizationID != user.OrganizationID {
 			SendForbidden(w)
 			return
 		}
 	}
-	items, err := GetBookingRepository().GetPresenceReport(user.OrganizationID, location, m.Start, m.End, 1000, 0)
+	if m.End.Sub(m.Start) > time.Duration(PresenceReportMaxRangeDays)*24*time.Hour {
+		SendBadRequest(w)
+		return
+	}
+	items, err := router.repos.Booking.GetPresenceReport(user.OrganizationID, location, m.Start, m.End, 1000, 0)
 	if err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
@@ -499,12 +990,14 @@ func (router *BookingRouter) getPresenceReport(w http.ResponseWriter, r *http.Re
 		Dates:     make([]string, numDates),
 		Presences: make([][]int, numUsers),
 	}
-	i := 0
-	for date := range items[0].Presence {
-		res.Dates[i] = date
-		i++
+	if numUsers > 0 {
+		i := 0
+		for date := range items[0].Presence {
+			res.Dates[i] = date
+			i++
+		}
+		sort.Strings(res.Dates)
 	}
-	sort.Strings(res.Dates)
 	for i, item := range items {
 		res.Users[i] = GetUserInfoSmall{
 			UserID: item.User.ID,
@@ -515,16 +1008,124 @@ func (router *BookingRouter) getPresenceReport(w http.ResponseWriter, r *http.Re
 			res.Presences[i][j] = item.Presence[date]
 		}
 	}
+	if format != ExportFormatJSON {
+		router.exportPresenceReport(w, format, res)
+		return
+	}
+	SendJSON(w, res)
+}
+
+// exportBookings writes the bookings as spreadsheet. Enter and leave are the local wall
+// clock times of the bookings' locations.
+func (router *BookingRouter) exportBookings(w http.ResponseWriter, format string, list []*BookingDetails) {
+	writer, err := NewSpreadsheetWriter(w, format, "bookings")
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	const DateTimeFormat string = "2006-01-02 15:04"
+	writer.WriteRow([]string{"id", "userEmail", "location", "space", "enter", "leave", "timezone", "subject"})
+	timezones := make(map[string]string)
+	for _, e := range list {
+		tz, ok := timezones[e.Space.Location.ID]
+		if !ok {
+			tz = router.repos.Location.GetTimezone(&e.Space.Location)
+			timezones[e.Space.Location.ID] = tz
+		}
+		writer.WriteRow([]string{
+			e.ID,
+			e.UserEmail,
+			e.Space.Location.Name,
+			e.Space.Name,
+			e.Enter.Format(DateTimeFormat),
+			e.Leave.Format(DateTimeFormat),
+			tz,
+			e.Subject,
+		})
+	}
+	if err := writer.Close(); err != nil {
+		log.Println(err)
+	}
+}
+
+// exportPresenceReport writes the report as spreadsheet with one column per day.
+func (router *BookingRouter) exportPresenceReport(w http.ResponseWriter, format string, res *GetPresenceReportResult) {
+	writer, err := NewSpreadsheetWriter(w, format, "presence-report")
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	writer.WriteRow(append([]string{"userEmail"}, res.Dates...))
+	for i, user := range res.Users {
+		row := []string{user.Email}
+		for _, presence := range res.Presences[i] {
+			row = append(row, strconv.Itoa(presence))
+		}
+		writer.WriteRow(row)
+	}
+	if err := writer.Close(); err != nil {
+		log.Println(err)
+	}
+}
+
+func (router *BookingRouter) getNoShowReport(w http.ResponseWriter, r *http.Request) {
+	user := GetRequestUser(router.repos, r)
+	if !CanSpaceAdminOrg(user, user.OrganizationID) {
+		SendForbidden(w)
+		return
+	}
+	var m GetBookingFilterRequest
+	if UnmarshalValidateBody(r, &m) != nil {
+		SendBadRequest(w)
+		return
+	}
+	var location *Location = nil
+	if m.LocationID != "" {
+		location, _ = router.repos.Location.GetOne(m.LocationID)
+		if location == nil {
+			SendNotFound(w)
+			return
+		}
+		if !router.repos.User.isSuperAdmin(user) && location.OrganizationID != user.OrganizationID {
+			SendForbidden(w)
+			return
+		}
+	}
+	list, err := router.repos.NoShow.GetAllByOrg(user.OrganizationID, location, m.Start, m.End)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	res := []*GetNoShowResponse{}
+	for _, e := range list {
+		m := &GetNoShowResponse{}
+		m.ID = e.ID
+		m.BookingID = e.BookingID
+		m.UserID = e.UserID
+		m.UserEmail = e.UserEmail
+		m.Enter, _ = attachTimezoneInformation(router.repos, e.Enter, &e.Space.Location)
+		m.Leave, _ = attachTimezoneInformation(router.repos, e.Leave, &e.Space.Location)
+		m.Released, _ = attachTimezoneInformation(router.repos, e.Released, &e.Space.Location)
+		m.Space.ID = e.Space.ID
+		m.Space.LocationID = e.Space.LocationID
+		m.Space.Name = e.Space.Name
+		m.Space.Location.ID = e.Space.Location.ID
+		m.Space.Location.Name = e.Space.Location.Name
+		res = append(res, m)
+	}
 	SendJSON(w, res)
 }
 
 func (router *BookingRouter) isValidBookingDuration(m *BookingRequest, orgID string, user *User) bool {
-	noAdminRestrictions, _ := GetSettingsRepository().GetBool(orgID, SettingNoAdminRestrictions.Name)
+	noAdminRestrictions, _ := router.repos.Settings.GetBool(orgID, SettingNoAdminRestrictions.Name)
 	if noAdminRestrictions && CanSpaceAdminOrg(user, orgID) {
 		return true
 	}
-	dailyBasisBooking, _ := GetSettingsRepository().GetBool(orgID, SettingDailyBasisBooking.Name)
-	maxDurationHours, _ := GetSettingsRepository().GetInt(orgID, SettingMaxBookingDurationHours.Name)
+	dailyBasisBooking, _ := router.repos.Settings.GetBool(orgID, SettingDailyBasisBooking.Name)
+	maxDurationHours, _ := router.repos.Settings.GetInt(orgID, SettingMaxBookingDurationHours.Name)
 	if dailyBasisBooking && (maxDurationHours%24 != 0) {
 		maxDurationHours += (24 - (maxDurationHours % 24))
 	}
@@ -552,9 +1153,9 @@ func (router *BookingRouter) getHoursOnDate(t *time.Time) int {
 }
 
 func (router *BookingRouter) isValidBookingAdvance(m *BookingRequest, orgID string, user *User) bool {
-	noAdminRestrictions, _ := GetSettingsRepository().GetBool(orgID, SettingNoAdminRestrictions.Name)
-	maxAdvanceDays, _ := GetSettingsRepository().GetInt(orgID, SettingMaxDaysInAdvance.Name)
-	dailyBasisBooking, _ := GetSettingsRepository().GetBool(orgID, SettingDailyBasisBooking.Name)
+	noAdminRestrictions, _ := router.repos.Settings.GetBool(orgID, SettingNoAdminRestrictions.Name)
+	maxAdvanceDays, _ := router.repos.Settings.GetInt(orgID, SettingMaxDaysInAdvance.Name)
+	dailyBasisBooking, _ := router.repos.Settings.GetBool(orgID, SettingDailyBasisBooking.Name)
 	// allow Enter-Date in past if at least this morning
 	now := time.Now().UTC()
 	now = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
@@ -575,26 +1176,26 @@ func (router *BookingRouter) isValidBookingAdvance(m *BookingRequest, orgID stri
 }
 
 func (router *BookingRouter) isValidMaxUpcomingBookings(orgID string, user *User) bool {
-	noAdminRestrictions, _ := GetSettingsRepository().GetBool(orgID, SettingNoAdminRestrictions.Name)
+	noAdminRestrictions, _ := router.repos.Settings.GetBool(orgID, SettingNoAdminRestrictions.Name)
 	if noAdminRestrictions && CanSpaceAdminOrg(user, orgID) {
 		return true
 	}
-	maxUpcoming, _ := GetSettingsRepository().GetInt(orgID, SettingMaxBookingsPerUser.Name)
-	curUpcoming, _ := GetBookingRepository().GetAllByUser(user.ID, time.Now().UTC())
+	maxUpcoming, _ := router.repos.Settings.GetInt(orgID, SettingMaxBookingsPerUser.Name)
+	curUpcoming, _ := router.repos.Booking.GetAllByUser(user.ID, time.Now().UTC())
 	return len(curUpcoming) < maxUpcoming
 }
 
 func (router *BookingRouter) isValidMaxConcurrentBookingsForUser(orgID string, user *User, m *BookingRequest, bookingID string) bool {
-	noAdminRestrictions, _ := GetSettingsRepository().GetBool(orgID, SettingNoAdminRestrictions.Name)
+	noAdminRestrictions, _ := router.repos.Settings.GetBool(orgID, SettingNoAdminRestrictions.Name)
 	if noAdminRestrictions && CanSpaceAdminOrg(user, orgID) {
 		return true
 	}
-	maxConcurrent, _ := GetSettingsRepository().GetInt(orgID, SettingMaxConcurrentBookingsPerUser.Name)
+	maxConcurrent, _ := router.repos.Settings.GetInt(orgID, SettingMaxConcurrentBookingsPerUser.Name)
 	// 0 = no limit
 	if maxConcurrent == 0 {
 		return true
 	}
-	curAtTime, _ := GetBookingRepository().GetTimeRangeByUser(user.ID, m.Enter, m.Leave, bookingID)
+	curAtTime, _ := router.repos.Booking.GetTimeRangeByUser(user.ID, m.Enter, m.Leave, bookingID)
 	return len(curAtTime) < maxConcurrent
 }
 
@@ -624,7 +1225,7 @@ func (router *BookingRouter) isValidConcurrent(m *BookingRequest, location *Loca
 	if location.MaxConcurrentBookings == 0 {
 		return true
 	}
-	bookings, err := GetBookingRepository().GetConcurrent(location, m.Enter, m.Leave, bookingID)
+	bookings, err := router.repos.Booking.GetConcurrent(location, m.Enter, m.Leave, bookingID)
 	if err != nil {
 		log.Println(err)
 		return false
@@ -636,11 +1237,11 @@ func (router *BookingRouter) isValidConcurrent(m *BookingRequest, location *Loca
 }
 
 func (router *BookingRouter) isValidBookingHoursBeforeDelete(e *BookingDetails, user *User, organizationID string) bool {
-	noAdminRestrictions, _ := GetSettingsRepository().GetBool(organizationID, SettingNoAdminRestrictions.Name)
+	noAdminRestrictions, _ := router.repos.Settings.GetBool(organizationID, SettingNoAdminRestrictions.Name)
 	if noAdminRestrictions && CanSpaceAdminOrg(user, organizationID) {
 		return true
 	}
-	enable_check, err := GetSettingsRepository().GetBool(organizationID, SettingEnableMaxHourBeforeDelete.Name)
+	enable_check, err := router.repos.Settings.GetBool(organizationID, SettingEnableMaxHourBeforeDelete.Name)
 	if err != nil {
 		log.Println(err)
 		return false
@@ -648,7 +1249,7 @@ func (router *BookingRouter) isValidBookingHoursBeforeDelete(e *BookingDetails,
 	if !enable_check {
 		return true
 	}
-	max_hours, err := GetSettingsRepository().GetInt(organizationID, SettingMaxHoursBeforeDelete.Name)
+	max_hours, err := router.repos.Settings.GetInt(organizationID, SettingMaxHoursBeforeDelete.Name)
 	if err != nil {
 		log.Println(err)
 		return false
@@ -660,11 +1261,11 @@ func (router *BookingRouter) isValidBookingHoursBeforeDelete(e *BookingDetails,
 }
 
 func (router *BookingRouter) isValidMinHoursBooking(e *BookingRequest, organizationID string, user *User) bool {
-	noAdminRestrictions, _ := GetSettingsRepository().GetBool(organizationID, SettingNoAdminRestrictions.Name)
+	noAdminRestrictions, _ := router.repos.Settings.GetBool(organizationID, SettingNoAdminRestrictions.Name)
 	if noAdminRestrictions && CanSpaceAdminOrg(user, organizationID) {
 		return true
 	}
-	min_hours, err := GetSettingsRepository().GetInt(organizationID, SettingMinBookingDurationHours.Name)
+	min_hours, err := router.repos.Settings.GetInt(organizationID, SettingMinBookingDurationHours.Name)
 	if err != nil {
 		log.Println(err)
 		return false
@@ -675,17 +1276,23 @@ func (router *BookingRouter) isValidMinHoursBooking(e *BookingRequest, organizat
 	return difference_in_hours >= int64(min_hours)
 }
 
+// isValidStatus returns true if the status is known or not set.
+func (router *BookingRouter) isValidStatus(status BookingStatus) bool {
+	return status == 0 || status == BookingStatusConfirmed || status == BookingStatusPending
+}
+
 func (router *BookingRouter) copyFromRestModel(m *CreateBookingRequest, location *Location) (*Booking, error) {
 	e := &Booking{}
 	e.SpaceID = m.SpaceID
+	e.Subject = m.Subject
 	e.Enter = m.Enter
 	e.Leave = m.Leave
-	enterNew, err := attachTimezoneInformation(e.Enter, location)
+	enterNew, err := attachTimezoneInformation(router.repos, e.Enter, location)
 	if err != nil {
 		return nil, err
 	}
 	e.Enter = enterNew
-	leaveNew, err := attachTimezoneInformation(e.Leave, location)
+	leaveNew, err := attachTimezoneInformation(router.repos, e.Leave, location)
 	if err != nil {
 		return nil, err
 	}
@@ -698,9 +1305,22 @@ func (router *BookingRouter) copyToRestModel(e *BookingDetails) *GetBookingRespo
 	m.ID = e.ID
 	m.UserID = e.UserID
 	m.UserEmail = e.UserEmail
+	m.SeriesID = string(e.SeriesID)
+	m.Status = e.Status
+	m.ApprovalExpiry = e.ApprovalExpiry
+	if e.CheckInTime != nil {
+		checkInTime, _ := attachTimezoneInformation(router.repos, *e.CheckInTime, &e.Space.Location)
+		m.CheckedIn = true
+		m.CheckInTime = &checkInTime
+	}
 	m.SpaceID = e.SpaceID
-	m.Enter, _ = attachTimezoneInformation(e.Enter, &e.Space.Location)
-	m.Leave, _ = attachTimezoneInformation(e.Leave, &e.Space.Location)
+	m.Subject = e.Subject
+	m.AttendeeEmails = []string{}
+	for _, attendee := range e.Attendees {
+		m.AttendeeEmails = append(m.AttendeeEmails, attendee.UserEmail)
+	}
+	m.Enter, _ = attachTimezoneInformation(router.repos, e.Enter, &e.Space.Location)
+	m.Leave, _ = attachTimezoneInformation(router.repos, e.Leave, &e.Space.Location)
 	m.Space.ID = e.Space.ID
 	m.Space.LocationID = e.Space.LocationID
 	m.Space.Name = e.Space.Name
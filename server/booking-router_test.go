@@ -1,10 +1,15 @@
 package main
 
 import (
+	"archive/zip"
 	"bytes"
+	"encoding/csv"
 	"encoding/json"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -30,7 +35,7 @@ func TestBookingsCRUD(t *testing.T) {
 	org := createTestOrg("test.com")
 	adminUser := createTestUserOrgAdmin(org)
 	loginResponse2 := loginTestUser(adminUser.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 
 	// Create location
 	payload := `{"name": "Location 1"}`
@@ -111,8 +116,8 @@ func TestBookingsCreateNonExistingUser(t *testing.T) {
 	org := createTestOrg("test.com")
 	user2 := createTestUserOrgAdmin(org)
 	loginResponse2 := loginTestUser(user2.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
-	GetSettingsRepository().Set(org.ID, SettingAllowBookingsNonExistingUsers.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingAllowBookingsNonExistingUsers.Name, "1")
 
 	// Create location
 	payload := `{"name": "Location 1"}`
@@ -136,11 +141,11 @@ func TestBookingsCreateNonExistingUser(t *testing.T) {
 	id := res.Header().Get("X-Object-Id")
 
 	// Check user
-	newUser, _ := GetUserRepository().GetByEmail("new-user@test.com")
+	newUser, _ := testRepositories.User.GetByEmail("new-user@test.com")
 	checkTestBool(t, true, newUser != nil)
 
 	// Check booking
-	booking, _ := GetBookingRepository().GetOne(id)
+	booking, _ := testRepositories.Booking.GetOne(id)
 	checkTestBool(t, true, booking != nil)
 }
 
@@ -149,8 +154,8 @@ func TestBookingsCreateNonExistingUserNoAdmin(t *testing.T) {
 	org := createTestOrg("test.com")
 	user2 := createTestUserOrgAdmin(org)
 	loginResponse2 := loginTestUser(user2.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
-	GetSettingsRepository().Set(org.ID, SettingAllowBookingsNonExistingUsers.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingAllowBookingsNonExistingUsers.Name, "1")
 
 	// Create location
 	payload := `{"name": "Location 1"}`
@@ -182,7 +187,7 @@ func TestBookingsCreateNonExistingUserNotEnabled(t *testing.T) {
 	org := createTestOrg("test.com")
 	user2 := createTestUserOrgAdmin(org)
 	loginResponse2 := loginTestUser(user2.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 
 	// Create location
 	payload := `{"name": "Location 1"}`
@@ -210,8 +215,8 @@ func TestBookingsCreateNonExistingUserForeignDomain(t *testing.T) {
 	org := createTestOrg("test.com")
 	user2 := createTestUserOrgAdmin(org)
 	loginResponse2 := loginTestUser(user2.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
-	GetSettingsRepository().Set(org.ID, SettingAllowBookingsNonExistingUsers.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingAllowBookingsNonExistingUsers.Name, "1")
 
 	// Create location
 	payload := `{"name": "Location 1"}`
@@ -239,7 +244,7 @@ func TestBookingsList(t *testing.T) {
 	org := createTestOrg("test.com")
 	user2 := createTestUserOrgAdmin(org)
 	loginResponse2 := loginTestUser(user2.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 
 	// Create location
 	payload := `{"name": "Location 1"}`
@@ -274,7 +279,7 @@ func TestBookingsList(t *testing.T) {
 		Enter:   enter,
 		Leave:   leave,
 	}
-	GetBookingRepository().Create(b2)
+	testRepositories.Booking.Create(b2)
 
 	// Create #3
 	payload = "{\"spaceId\": \"" + spaceID + "\", \"enter\": \"2030-06-01T08:30:00Z\", \"leave\": \"2030-06-01T17:00:00Z\"}"
@@ -299,7 +304,7 @@ func TestBookingsGetForeign(t *testing.T) {
 	org := createTestOrg("test.com")
 	user2 := createTestUserOrgAdmin(org)
 	loginResponse2 := loginTestUser(user2.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 
 	// Create location
 	payload := `{"name": "Location 1"}`
@@ -342,7 +347,7 @@ func TestBookingsUpdateForeign(t *testing.T) {
 	org := createTestOrg("test.com")
 	adminUser := createTestUserOrgAdmin(org)
 	loginResponse2 := loginTestUser(adminUser.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 
 	// Create location
 	payload := `{"name": "Location 1"}`
@@ -406,7 +411,7 @@ func TestBookingsCreateForeign(t *testing.T) {
 	org := createTestOrg("test2.com")
 	user2 := createTestUserOrgAdminDomain(org, "test2.com")
 	loginResponse2 := loginTestUser(user2.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 
 	// Create location
 	payload := `{"name": "Location 1"}`
@@ -437,11 +442,11 @@ func TestBookingsConflictDeleteTooClose(t *testing.T) {
 	org := createTestOrg("test.com")
 	user2 := createTestUserOrgAdmin(org)
 	loginResponse2 := loginTestUser(user2.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 	// Turning on the check
-	GetSettingsRepository().Set(org.ID, SettingEnableMaxHourBeforeDelete.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingEnableMaxHourBeforeDelete.Name, "1")
 	// A booking can be deleted only before 24 hours
-	GetSettingsRepository().Set(org.ID, SettingMaxHoursBeforeDelete.Name, "24")
+	testRepositories.Settings.Set(org.ID, SettingMaxHoursBeforeDelete.Name, "24")
 
 	// Create location
 	payload := `{"name": "Location 1"}`
@@ -490,7 +495,7 @@ func TestBookingsConflictDeleteTooClose(t *testing.T) {
 	checkTestResponseCode(t, http.StatusNoContent, res.Code)
 
 	// Change the Hours limit, add the possibility to delete a Booking at any moment.
-	GetSettingsRepository().Set(org.ID, SettingMaxHoursBeforeDelete.Name, "0")
+	testRepositories.Settings.Set(org.ID, SettingMaxHoursBeforeDelete.Name, "0")
 	// Create booking for now
 	now_en := time.Now().UTC().Format("2006-01-02T15:04:05-07:00")
 	now_ex := time.Now().UTC().Format("2006-01-02T15:04:05-07:00")
@@ -511,7 +516,7 @@ func TestBookingsConflictDeleteTooClose(t *testing.T) {
 	checkTestResponseCode(t, http.StatusNoContent, res.Code)
 
 	// Change the Hours limit, the delete can be done before one hour from the beginning of the booking.
-	GetSettingsRepository().Set(org.ID, SettingMaxHoursBeforeDelete.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxHoursBeforeDelete.Name, "1")
 
 	// Create booking for today plus 1 hour, this SHOULD NOT BE deleted
 	today_en := time.Now().UTC().Add((2 * time.Hour)).Format("2006-01-02T15:04:05-07:00")
@@ -542,7 +547,7 @@ func TestBookingsConflictDeleteTooClose(t *testing.T) {
 	checkTestResponseCode(t, http.StatusNoContent, res.Code)
 
 	// Turning the check off but the max hours before delete still remain at 24 hours.
-	GetSettingsRepository().Set(org.ID, SettingEnableMaxHourBeforeDelete.Name, "0")
+	testRepositories.Settings.Set(org.ID, SettingEnableMaxHourBeforeDelete.Name, "0")
 
 	// Create booking for tomorrow
 	tomorrow_enter = time.Now().UTC().Add(24 * time.Hour).Format("2006-01-02T15:04:05-07:00")
@@ -563,11 +568,11 @@ func TestBookingsDeleteToCloseBeeingAdmin(t *testing.T) {
 	org := createTestOrg("test.com")
 	user2 := createTestUserOrgAdmin(org)
 	loginResponse2 := loginTestUser(user2.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 	// Turning on the check and set a booking can be deleted only before 24 hours
-	GetSettingsRepository().Set(org.ID, SettingEnableMaxHourBeforeDelete.Name, "1")
-	GetSettingsRepository().Set(org.ID, SettingMaxHoursBeforeDelete.Name, "48")
-	GetSettingsRepository().Set(org.ID, SettingNoAdminRestrictions.Name, "0")
+	testRepositories.Settings.Set(org.ID, SettingEnableMaxHourBeforeDelete.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxHoursBeforeDelete.Name, "48")
+	testRepositories.Settings.Set(org.ID, SettingNoAdminRestrictions.Name, "0")
 
 	// Create location
 	payload := `{"name": "Location 1"}`
@@ -606,7 +611,7 @@ func TestBookingsDeleteToCloseBeeingAdmin(t *testing.T) {
 	res = executeTestRequest(req)
 	checkTestResponseCode(t, http.StatusForbidden, res.Code)
 
-	GetSettingsRepository().Set(org.ID, SettingNoAdminRestrictions.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingNoAdminRestrictions.Name, "1")
 	// Delete without Error for tomorrow booking
 	req = newHTTPRequest("DELETE", "/booking/"+id, loginResponse2.UserID, nil)
 	res = executeTestRequest(req)
@@ -623,8 +628,8 @@ func TestBookingConflictDurationTooShort(t *testing.T) {
 	org := createTestOrg("test.com")
 	user2 := createTestUserOrgAdmin(org)
 	loginResponse2 := loginTestUser(user2.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
-	GetSettingsRepository().Set(org.ID, SettingMinBookingDurationHours.Name, "2")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMinBookingDurationHours.Name, "2")
 
 	// Create location
 	payload := `{"name": "Location 1"}`
@@ -657,7 +662,7 @@ func TestBookingConflictDurationTooShort(t *testing.T) {
 	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
 
 	// Set Min duration equals to 0
-	GetSettingsRepository().Set(org.ID, SettingMinBookingDurationHours.Name, "0")
+	testRepositories.Settings.Set(org.ID, SettingMinBookingDurationHours.Name, "0")
 	// Booking with duration == 1 hour, this SHOULD BE accepted
 	payload = "{\"spaceId\": \"" + spaceID + "\", \"enter\": \"2030-09-03T08:30:00+02:00\", \"leave\": \"2030-09-03T08:30:00+02:00\"}"
 	req = newHTTPRequest("POST", "/booking/", loginResponse.UserID, bytes.NewBufferString(payload))
@@ -665,8 +670,8 @@ func TestBookingConflictDurationTooShort(t *testing.T) {
 	checkTestResponseCode(t, http.StatusCreated, res.Code)
 
 	// Set min hours duration to 10 and set No Admin Restrictions to True
-	GetSettingsRepository().Set(org.ID, SettingNoAdminRestrictions.Name, "1")
-	GetSettingsRepository().Set(org.ID, SettingMinBookingDurationHours.Name, "10")
+	testRepositories.Settings.Set(org.ID, SettingNoAdminRestrictions.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMinBookingDurationHours.Name, "10")
 	loginResponse2 = loginTestUser(user2.ID)
 	payload = "{\"spaceId\": \"" + spaceID + "\", \"enter\": \"2030-09-02T08:30:00+02:00\", \"leave\": \"2030-09-02T09:30:00+02:00\"}"
 	req = newHTTPRequest("POST", "/booking/", loginResponse2.UserID, bytes.NewBufferString(payload))
@@ -679,7 +684,7 @@ func TestBookingUpdateConflictDurationTooShort(t *testing.T) {
 	org := createTestOrg("test.com")
 	user2 := createTestUserOrgAdmin(org)
 	loginResponse2 := loginTestUser(user2.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 
 	// Create location
 	payload := `{"name": "Location 1"}`
@@ -707,14 +712,14 @@ func TestBookingUpdateConflictDurationTooShort(t *testing.T) {
 	checkTestResponseCode(t, http.StatusCreated, res.Code)
 
 	// Booking with duration == 1 hour, this SHOULD NOT BE accepted
-	GetSettingsRepository().Set(org.ID, SettingMinBookingDurationHours.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMinBookingDurationHours.Name, "1")
 	payload = "{\"spaceId\": \"" + spaceID + "\", \"enter\": \"2030-09-01T08:30:00+02:00\", \"leave\": \"2030-09-01T09:00:00+02:00\"}"
 	req = newHTTPRequest("PUT", "/booking/"+id, loginResponse.UserID, bytes.NewBufferString(payload))
 	res = executeTestRequest(req)
 	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
 
 	// Set Min duration equals to 0
-	GetSettingsRepository().Set(org.ID, SettingMinBookingDurationHours.Name, "0")
+	testRepositories.Settings.Set(org.ID, SettingMinBookingDurationHours.Name, "0")
 	// Booking with duration == 1 hour, this SHOULD BE accepted
 	payload = "{\"spaceId\": \"" + spaceID + "\", \"enter\": \"2030-09-03T08:30:00+02:00\", \"leave\": \"2030-09-03T08:30:00+02:00\"}"
 	req = newHTTPRequest("POST", "/booking/", loginResponse.UserID, bytes.NewBufferString(payload))
@@ -727,7 +732,7 @@ func TestBookingsDeleteForeign(t *testing.T) {
 	org := createTestOrg("test.com")
 	user2 := createTestUserOrgAdmin(org)
 	loginResponse2 := loginTestUser(user2.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 
 	// Create location
 	payload := `{"name": "Location 1"}`
@@ -769,7 +774,7 @@ func TestBookingsDeleteSpaceAdmin(t *testing.T) {
 	org := createTestOrg("test.com")
 	user2 := createTestUserOrgAdmin(org)
 	loginResponse2 := loginTestUser(user2.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 
 	// Create location
 	payload := `{"name": "Location 1"}`
@@ -811,7 +816,7 @@ func TestBookingsConflictEnd(t *testing.T) {
 	org := createTestOrg("test.com")
 	user2 := createTestUserOrgAdmin(org)
 	loginResponse2 := loginTestUser(user2.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 
 	// Create location
 	payload := `{"name": "Location 1"}`
@@ -845,7 +850,7 @@ func TestBookingsConflictStart(t *testing.T) {
 	org := createTestOrg("test.com")
 	user2 := createTestUserOrgAdmin(org)
 	loginResponse2 := loginTestUser(user2.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 
 	// Create location
 	payload := `{"name": "Location 1"}`
@@ -879,7 +884,7 @@ func TestBookingsConflictInner(t *testing.T) {
 	org := createTestOrg("test.com")
 	user2 := createTestUserOrgAdmin(org)
 	loginResponse2 := loginTestUser(user2.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 
 	// Create location
 	payload := `{"name": "Location 1"}`
@@ -913,7 +918,7 @@ func TestBookingsConflictOuter(t *testing.T) {
 	org := createTestOrg("test.com")
 	user2 := createTestUserOrgAdmin(org)
 	loginResponse2 := loginTestUser(user2.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 
 	// Create location
 	payload := `{"name": "Location 1"}`
@@ -947,7 +952,7 @@ func TestBookingsConflictUpdateSelf(t *testing.T) {
 	org := createTestOrg("test.com")
 	user2 := createTestUserOrgAdmin(org)
 	loginResponse2 := loginTestUser(user2.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 
 	// Create location
 	payload := `{"name": "Location 1"}`
@@ -982,7 +987,7 @@ func TestBookingsConflictUpdateOther(t *testing.T) {
 	org := createTestOrg("test.com")
 	user2 := createTestUserOrgAdmin(org)
 	loginResponse2 := loginTestUser(user2.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 
 	// Create location
 	payload := `{"name": "Location 1"}`
@@ -1028,7 +1033,7 @@ func TestBookingsNegativeBookingDuration(t *testing.T) {
 		Leave: time.Now().Add(time.Hour * -2).UTC(),
 	}
 
-	router := &BookingRouter{}
+	router := &BookingRouter{repos: testRepositories}
 	res := router.isValidBookingDuration(m, org.ID, user)
 	checkTestBool(t, false, res)
 }
@@ -1043,7 +1048,7 @@ func TestBookingsValidBookingDuration(t *testing.T) {
 		Leave: time.Now().Add(time.Hour * 8).UTC(),
 	}
 
-	router := &BookingRouter{}
+	router := &BookingRouter{repos: testRepositories}
 	res := router.isValidBookingDuration(m, org.ID, user)
 	checkTestBool(t, true, res)
 }
@@ -1051,8 +1056,8 @@ func TestBookingsValidBookingDuration(t *testing.T) {
 func TestBookingsInvalidBookingDuration(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingNoAdminRestrictions.Name, "1")
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingDurationHours.Name, "12")
+	testRepositories.Settings.Set(org.ID, SettingNoAdminRestrictions.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingDurationHours.Name, "12")
 	user := createTestUserInOrg(org)
 	adminUser := createTestUserOrgAdmin(org)
 
@@ -1061,14 +1066,14 @@ func TestBookingsInvalidBookingDuration(t *testing.T) {
 		Leave: time.Now().Add(time.Hour * 14).UTC(),
 	}
 
-	router := &BookingRouter{}
+	router := &BookingRouter{repos: testRepositories}
 	res := router.isValidBookingDuration(m, org.ID, user)
 	checkTestBool(t, false, res)
 
 	res = router.isValidBookingDuration(m, org.ID, adminUser)
 	checkTestBool(t, true, res)
 
-	GetSettingsRepository().Set(org.ID, SettingNoAdminRestrictions.Name, "0")
+	testRepositories.Settings.Set(org.ID, SettingNoAdminRestrictions.Name, "0")
 	res = router.isValidBookingDuration(m, org.ID, adminUser)
 	checkTestBool(t, false, res)
 
@@ -1077,8 +1082,8 @@ func TestBookingsInvalidBookingDuration(t *testing.T) {
 func TestBookingsDailyBasisBookingValid(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingDurationHours.Name, "24")
-	GetSettingsRepository().Set(org.ID, SettingDailyBasisBooking.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingDurationHours.Name, "24")
+	testRepositories.Settings.Set(org.ID, SettingDailyBasisBooking.Name, "1")
 	user := createTestUserInOrg(org)
 	tm := time.Now().Add(time.Hour * 24).UTC()
 
@@ -1087,7 +1092,7 @@ func TestBookingsDailyBasisBookingValid(t *testing.T) {
 		Leave: time.Date(tm.Year(), tm.Month(), tm.Day(), 23, 59, 59, 0, tm.Location()),
 	}
 
-	router := &BookingRouter{}
+	router := &BookingRouter{repos: testRepositories}
 	res := router.isValidBookingDuration(m, org.ID, user)
 	checkTestBool(t, true, res)
 }
@@ -1095,8 +1100,8 @@ func TestBookingsDailyBasisBookingValid(t *testing.T) {
 func TestBookingsDailyBasisBookingSameDayValid(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingDurationHours.Name, "24")
-	GetSettingsRepository().Set(org.ID, SettingDailyBasisBooking.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingDurationHours.Name, "24")
+	testRepositories.Settings.Set(org.ID, SettingDailyBasisBooking.Name, "1")
 	user := createTestUserInOrg(org)
 	tm := time.Now().UTC()
 
@@ -1105,7 +1110,7 @@ func TestBookingsDailyBasisBookingSameDayValid(t *testing.T) {
 		Leave: time.Date(tm.Year(), tm.Month(), tm.Day(), 23, 59, 59, 0, tm.Location()),
 	}
 
-	router := &BookingRouter{}
+	router := &BookingRouter{repos: testRepositories}
 	res := router.isValidBookingAdvance(m, org.ID, user)
 	checkTestBool(t, true, res)
 }
@@ -1113,8 +1118,8 @@ func TestBookingsDailyBasisBookingSameDayValid(t *testing.T) {
 func TestBookingsDailyBasisBookingInvalidEnter(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingDurationHours.Name, "24")
-	GetSettingsRepository().Set(org.ID, SettingDailyBasisBooking.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingDurationHours.Name, "24")
+	testRepositories.Settings.Set(org.ID, SettingDailyBasisBooking.Name, "1")
 	user := createTestUserInOrg(org)
 	tm := time.Now().Add(time.Hour * 24).UTC()
 
@@ -1123,7 +1128,7 @@ func TestBookingsDailyBasisBookingInvalidEnter(t *testing.T) {
 		Leave: time.Date(tm.Year(), tm.Month(), tm.Day(), 23, 59, 59, 0, tm.Location()),
 	}
 
-	router := &BookingRouter{}
+	router := &BookingRouter{repos: testRepositories}
 	res := router.isValidBookingDuration(m, org.ID, user)
 	checkTestBool(t, false, res)
 }
@@ -1131,8 +1136,8 @@ func TestBookingsDailyBasisBookingInvalidEnter(t *testing.T) {
 func TestBookingsDailyBasisBookingInvalidLeave(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingDurationHours.Name, "24")
-	GetSettingsRepository().Set(org.ID, SettingDailyBasisBooking.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingDurationHours.Name, "24")
+	testRepositories.Settings.Set(org.ID, SettingDailyBasisBooking.Name, "1")
 	user := createTestUserInOrg(org)
 	tm := time.Now().Add(time.Hour * 24).UTC()
 
@@ -1141,7 +1146,7 @@ func TestBookingsDailyBasisBookingInvalidLeave(t *testing.T) {
 		Leave: time.Date(tm.Year(), tm.Month(), tm.Day(), 23, 50, 59, 0, tm.Location()),
 	}
 
-	router := &BookingRouter{}
+	router := &BookingRouter{repos: testRepositories}
 	res := router.isValidBookingDuration(m, org.ID, user)
 	checkTestBool(t, false, res)
 }
@@ -1149,8 +1154,8 @@ func TestBookingsDailyBasisBookingInvalidLeave(t *testing.T) {
 func TestBookingsDailyBasisBookingRoundBookingDurationUp(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingDurationHours.Name, "12")
-	GetSettingsRepository().Set(org.ID, SettingDailyBasisBooking.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingDurationHours.Name, "12")
+	testRepositories.Settings.Set(org.ID, SettingDailyBasisBooking.Name, "1")
 	user := createTestUserInOrg(org)
 	tm := time.Now().Add(time.Hour * 24).UTC()
 
@@ -1159,7 +1164,7 @@ func TestBookingsDailyBasisBookingRoundBookingDurationUp(t *testing.T) {
 		Leave: time.Date(tm.Year(), tm.Month(), tm.Day(), 23, 59, 59, 0, tm.Location()),
 	}
 
-	router := &BookingRouter{}
+	router := &BookingRouter{repos: testRepositories}
 	res := router.isValidBookingDuration(m, org.ID, user)
 	checkTestBool(t, true, res)
 }
@@ -1167,7 +1172,7 @@ func TestBookingsDailyBasisBookingRoundBookingDurationUp(t *testing.T) {
 func TestBookingsValidBorderBookingDuration(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingDurationHours.Name, "3")
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingDurationHours.Name, "3")
 	user := createTestUserInOrg(org)
 
 	m := &BookingRequest{
@@ -1175,7 +1180,7 @@ func TestBookingsValidBorderBookingDuration(t *testing.T) {
 		Leave: time.Now().Add(time.Hour * 4).UTC(),
 	}
 
-	router := &BookingRouter{}
+	router := &BookingRouter{repos: testRepositories}
 	res := router.isValidBookingDuration(m, org.ID, user)
 	checkTestBool(t, true, res)
 }
@@ -1183,8 +1188,8 @@ func TestBookingsValidBorderBookingDuration(t *testing.T) {
 func TestBookingsInvalidBorderBookingDuration(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingNoAdminRestrictions.Name, "1")
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingDurationHours.Name, "3")
+	testRepositories.Settings.Set(org.ID, SettingNoAdminRestrictions.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingDurationHours.Name, "3")
 	user := createTestUserInOrg(org)
 	adminUser := createTestUserOrgAdmin(org)
 
@@ -1193,14 +1198,14 @@ func TestBookingsInvalidBorderBookingDuration(t *testing.T) {
 		Leave: time.Now().Add(time.Hour * 4).Add(time.Minute * 1).UTC(),
 	}
 
-	router := &BookingRouter{}
+	router := &BookingRouter{repos: testRepositories}
 	res := router.isValidBookingDuration(m, org.ID, user)
 	checkTestBool(t, false, res)
 
 	res = router.isValidBookingDuration(m, org.ID, adminUser)
 	checkTestBool(t, true, res)
 
-	GetSettingsRepository().Set(org.ID, SettingNoAdminRestrictions.Name, "0")
+	testRepositories.Settings.Set(org.ID, SettingNoAdminRestrictions.Name, "0")
 	res = router.isValidBookingDuration(m, org.ID, adminUser)
 	checkTestBool(t, false, res)
 }
@@ -1208,8 +1213,8 @@ func TestBookingsInvalidBorderBookingDuration(t *testing.T) {
 func TestBookingsPastEnterDate(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingNoAdminRestrictions.Name, "1")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5")
+	testRepositories.Settings.Set(org.ID, SettingNoAdminRestrictions.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5")
 	user := createTestUserInOrg(org)
 	adminUser := createTestUserOrgAdmin(org)
 
@@ -1218,7 +1223,7 @@ func TestBookingsPastEnterDate(t *testing.T) {
 		Leave: time.Now().Add(time.Hour * 1).UTC(),
 	}
 
-	router := &BookingRouter{}
+	router := &BookingRouter{repos: testRepositories}
 	res := router.isValidBookingAdvance(m, org.ID, user)
 	checkTestBool(t, false, res)
 
@@ -1230,8 +1235,8 @@ func TestBookingsPastEnterDate(t *testing.T) {
 func TestBookingsEarlyMorningEnterDate(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingNoAdminRestrictions.Name, "1")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5")
+	testRepositories.Settings.Set(org.ID, SettingNoAdminRestrictions.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5")
 	user := createTestUserInOrg(org)
 
 	now := time.Now().UTC()
@@ -1240,7 +1245,7 @@ func TestBookingsEarlyMorningEnterDate(t *testing.T) {
 		Leave: time.Date(now.Year(), now.Month(), now.Day(), 23, 0, 0, 0, now.Location()),
 	}
 
-	router := &BookingRouter{}
+	router := &BookingRouter{repos: testRepositories}
 	res := router.isValidBookingAdvance(m, org.ID, user)
 	checkTestBool(t, true, res)
 }
@@ -1248,7 +1253,7 @@ func TestBookingsEarlyMorningEnterDate(t *testing.T) {
 func TestBookingsValidFutureAdvanceDate(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5")
 	user := createTestUserInOrg(org)
 
 	m := &BookingRequest{
@@ -1256,7 +1261,7 @@ func TestBookingsValidFutureAdvanceDate(t *testing.T) {
 		Leave: time.Now().Add(time.Hour * 2 * 24).Add(time.Hour * 5).UTC(),
 	}
 
-	router := &BookingRouter{}
+	router := &BookingRouter{repos: testRepositories}
 	res := router.isValidBookingAdvance(m, org.ID, user)
 	checkTestBool(t, true, res)
 }
@@ -1264,7 +1269,7 @@ func TestBookingsValidFutureAdvanceDate(t *testing.T) {
 func TestBookingsValidBorderAdvanceDate(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5")
 	user := createTestUserInOrg(org)
 
 	m := &BookingRequest{
@@ -1272,7 +1277,7 @@ func TestBookingsValidBorderAdvanceDate(t *testing.T) {
 		Leave: time.Now().Add(time.Hour * 5 * 24).Add(time.Hour * 5).UTC(),
 	}
 
-	router := &BookingRouter{}
+	router := &BookingRouter{repos: testRepositories}
 	res := router.isValidBookingAdvance(m, org.ID, user)
 	checkTestBool(t, true, res)
 }
@@ -1280,8 +1285,8 @@ func TestBookingsValidBorderAdvanceDate(t *testing.T) {
 func TestBookingsInvalidBorderAdvanceDate(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingNoAdminRestrictions.Name, "1")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5")
+	testRepositories.Settings.Set(org.ID, SettingNoAdminRestrictions.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5")
 	user := createTestUserInOrg(org)
 	adminUser := createTestUserOrgAdmin(org)
 
@@ -1290,14 +1295,14 @@ func TestBookingsInvalidBorderAdvanceDate(t *testing.T) {
 		Leave: time.Now().Add(time.Hour * 6 * 24).Add(time.Hour * 5).UTC(),
 	}
 
-	router := &BookingRouter{}
+	router := &BookingRouter{repos: testRepositories}
 	res := router.isValidBookingAdvance(m, org.ID, user)
 	checkTestBool(t, false, res)
 
 	res = router.isValidBookingAdvance(m, org.ID, adminUser)
 	checkTestBool(t, true, res)
 
-	GetSettingsRepository().Set(org.ID, SettingNoAdminRestrictions.Name, "0")
+	testRepositories.Settings.Set(org.ID, SettingNoAdminRestrictions.Name, "0")
 	res = router.isValidBookingAdvance(m, org.ID, adminUser)
 	checkTestBool(t, false, res)
 
@@ -1307,8 +1312,8 @@ func TestBookingsInvalidFutureAdvanceDate(t *testing.T) {
 	clearTestDB()
 	// TBD
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingNoAdminRestrictions.Name, "1")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5")
+	testRepositories.Settings.Set(org.ID, SettingNoAdminRestrictions.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5")
 	user := createTestUserInOrg(org)
 	adminUser := createTestUserOrgAdmin(org)
 
@@ -1317,14 +1322,14 @@ func TestBookingsInvalidFutureAdvanceDate(t *testing.T) {
 		Leave: time.Now().Add(time.Hour * 7 * 24).Add(time.Hour * 5).UTC(),
 	}
 
-	router := &BookingRouter{}
+	router := &BookingRouter{repos: testRepositories}
 	res := router.isValidBookingAdvance(m, org.ID, user)
 	checkTestBool(t, false, res)
 
 	res = router.isValidBookingAdvance(m, org.ID, adminUser)
 	checkTestBool(t, true, res)
 
-	GetSettingsRepository().Set(org.ID, SettingNoAdminRestrictions.Name, "0")
+	testRepositories.Settings.Set(org.ID, SettingNoAdminRestrictions.Name, "0")
 	res = router.isValidBookingAdvance(m, org.ID, adminUser)
 	checkTestBool(t, false, res)
 }
@@ -1332,10 +1337,10 @@ func TestBookingsInvalidFutureAdvanceDate(t *testing.T) {
 func TestBookingsValidMaxUpcomingBookings(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingsPerUser.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingsPerUser.Name, "1")
 	user := createTestUserInOrg(org)
 
-	router := &BookingRouter{}
+	router := &BookingRouter{repos: testRepositories}
 	res := router.isValidMaxUpcomingBookings(org.ID, user)
 	checkTestBool(t, true, res)
 }
@@ -1343,28 +1348,28 @@ func TestBookingsValidMaxUpcomingBookings(t *testing.T) {
 func TestBookingsInvalidMaxUpcomingBookings(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingsPerUser.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingsPerUser.Name, "1")
 	user := createTestUserInOrg(org)
 
 	l := &Location{
 		Name:           "Test",
 		OrganizationID: org.ID,
 	}
-	GetLocationRepository().Create(l)
+	testRepositories.Location.Create(l)
 	s := &Space{
 		Name:       "Test",
 		LocationID: l.ID,
 	}
-	GetSpaceRepository().Create(s)
+	testRepositories.Space.Create(s)
 	b := &Booking{
 		Enter:   time.Now().Add(time.Hour * 6 * 24).UTC(),
 		Leave:   time.Now().Add(time.Hour * 6 * 24).Add(time.Hour * 5).UTC(),
 		SpaceID: s.ID,
 		UserID:  user.ID,
 	}
-	GetBookingRepository().Create(b)
+	testRepositories.Booking.Create(b)
 
-	router := &BookingRouter{}
+	router := &BookingRouter{repos: testRepositories}
 	res := router.isValidMaxUpcomingBookings(org.ID, user)
 	checkTestBool(t, false, res)
 }
@@ -1372,8 +1377,8 @@ func TestBookingsInvalidMaxUpcomingBookings(t *testing.T) {
 func TestBookingsMaxConcurrentOK(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingsPerUser.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingsPerUser.Name, "1")
 	user1 := createTestUserInOrg(org)
 	user2 := createTestUserInOrg(org)
 	user3 := createTestUserInOrg(org)
@@ -1383,13 +1388,13 @@ func TestBookingsMaxConcurrentOK(t *testing.T) {
 		MaxConcurrentBookings: 2,
 		OrganizationID:        org.ID,
 	}
-	GetLocationRepository().Create(l)
+	testRepositories.Location.Create(l)
 	s1 := &Space{Name: "Test 1", LocationID: l.ID}
-	GetSpaceRepository().Create(s1)
+	testRepositories.Space.Create(s1)
 	s2 := &Space{Name: "Test 2", LocationID: l.ID}
-	GetSpaceRepository().Create(s2)
+	testRepositories.Space.Create(s2)
 	s3 := &Space{Name: "Test 3", LocationID: l.ID}
-	GetSpaceRepository().Create(s3)
+	testRepositories.Space.Create(s3)
 
 	// Create booking 1
 	payload := "{\"spaceId\": \"" + s1.ID + "\", \"enter\": \"2030-09-01T08:30:00+02:00\", \"leave\": \"2030-09-01T17:00:00+02:00\"}"
@@ -1413,9 +1418,9 @@ func TestBookingsMaxConcurrentOK(t *testing.T) {
 func TestBookingsSwitchToWinterTime(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingDailyBasisBooking.Name, "1")
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingDurationHours.Name, "24")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "1000")
+	testRepositories.Settings.Set(org.ID, SettingDailyBasisBooking.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingDurationHours.Name, "24")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "1000")
 	user1 := createTestUserInOrg(org)
 
 	l := &Location{
@@ -1423,9 +1428,9 @@ func TestBookingsSwitchToWinterTime(t *testing.T) {
 		MaxConcurrentBookings: 2,
 		OrganizationID:        org.ID,
 	}
-	GetLocationRepository().Create(l)
+	testRepositories.Location.Create(l)
 	s1 := &Space{Name: "Test 1", LocationID: l.ID}
-	GetSpaceRepository().Create(s1)
+	testRepositories.Space.Create(s1)
 
 	now := time.Now().UTC()
 	enter := time.Date(2025, 10, 26, 0, 0, 0, 0, now.Location())
@@ -1445,9 +1450,9 @@ func TestBookingsSwitchToWinterTime(t *testing.T) {
 func TestBookingsSwitchToSummerTime(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingDailyBasisBooking.Name, "1")
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingDurationHours.Name, "24")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "1000")
+	testRepositories.Settings.Set(org.ID, SettingDailyBasisBooking.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingDurationHours.Name, "24")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "1000")
 	user1 := createTestUserInOrg(org)
 
 	l := &Location{
@@ -1455,9 +1460,9 @@ func TestBookingsSwitchToSummerTime(t *testing.T) {
 		MaxConcurrentBookings: 2,
 		OrganizationID:        org.ID,
 	}
-	GetLocationRepository().Create(l)
+	testRepositories.Location.Create(l)
 	s1 := &Space{Name: "Test 1", LocationID: l.ID}
-	GetSpaceRepository().Create(s1)
+	testRepositories.Space.Create(s1)
 
 	now := time.Now().UTC()
 	enter := time.Date(2026, 3, 29, 0, 0, 0, 0, now.Location())
@@ -1477,8 +1482,8 @@ func TestBookingsSwitchToSummerTime(t *testing.T) {
 func TestBookingsSameDay(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingDailyBasisBooking.Name, "1")
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingDurationHours.Name, "24")
+	testRepositories.Settings.Set(org.ID, SettingDailyBasisBooking.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingDurationHours.Name, "24")
 	user1 := createTestUserInOrg(org)
 
 	l := &Location{
@@ -1486,9 +1491,9 @@ func TestBookingsSameDay(t *testing.T) {
 		MaxConcurrentBookings: 2,
 		OrganizationID:        org.ID,
 	}
-	GetLocationRepository().Create(l)
+	testRepositories.Location.Create(l)
 	s1 := &Space{Name: "Test 1", LocationID: l.ID}
-	GetSpaceRepository().Create(s1)
+	testRepositories.Space.Create(s1)
 
 	now := time.Now().UTC()
 	enter := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
@@ -1503,9 +1508,9 @@ func TestBookingsSameDay(t *testing.T) {
 func TestBookingsMaxConcurrentLimitExceeded(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingNoAdminRestrictions.Name, "1")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingsPerUser.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingNoAdminRestrictions.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingsPerUser.Name, "1")
 	user1 := createTestUserInOrg(org)
 	user2 := createTestUserInOrg(org)
 	user3 := createTestUserInOrg(org)
@@ -1516,13 +1521,13 @@ func TestBookingsMaxConcurrentLimitExceeded(t *testing.T) {
 		MaxConcurrentBookings: 2,
 		OrganizationID:        org.ID,
 	}
-	GetLocationRepository().Create(l)
+	testRepositories.Location.Create(l)
 	s1 := &Space{Name: "Test 1", LocationID: l.ID}
-	GetSpaceRepository().Create(s1)
+	testRepositories.Space.Create(s1)
 	s2 := &Space{Name: "Test 2", LocationID: l.ID}
-	GetSpaceRepository().Create(s2)
+	testRepositories.Space.Create(s2)
 	s3 := &Space{Name: "Test 3", LocationID: l.ID}
-	GetSpaceRepository().Create(s3)
+	testRepositories.Space.Create(s3)
 
 	// Create booking 1
 	payload := "{\"spaceId\": \"" + s1.ID + "\", \"enter\": \"2030-09-01T08:30:00+02:00\", \"leave\": \"2030-09-01T17:00:00+02:00\"}"
@@ -1554,8 +1559,8 @@ func TestBookingsMaxConcurrentLimitExceeded(t *testing.T) {
 func TestBookingsMaxConcurrentLimitOKOnUpdate(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingsPerUser.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingsPerUser.Name, "1")
 	user1 := createTestUserInOrg(org)
 	user2 := createTestUserInOrg(org)
 
@@ -1564,11 +1569,11 @@ func TestBookingsMaxConcurrentLimitOKOnUpdate(t *testing.T) {
 		MaxConcurrentBookings: 2,
 		OrganizationID:        org.ID,
 	}
-	GetLocationRepository().Create(l)
+	testRepositories.Location.Create(l)
 	s1 := &Space{Name: "Test 1", LocationID: l.ID}
-	GetSpaceRepository().Create(s1)
+	testRepositories.Space.Create(s1)
 	s2 := &Space{Name: "Test 2", LocationID: l.ID}
-	GetSpaceRepository().Create(s2)
+	testRepositories.Space.Create(s2)
 
 	// Create booking 1
 	payload := "{\"spaceId\": \"" + s1.ID + "\", \"enter\": \"2030-09-01T08:30:00+02:00\", \"leave\": \"2030-09-01T17:00:00+02:00\"}"
@@ -1593,9 +1598,9 @@ func TestBookingsMaxConcurrentLimitOKOnUpdate(t *testing.T) {
 func TestBookingsMaxConcurrentLimitExceededOnUpdate(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingNoAdminRestrictions.Name, "1")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingsPerUser.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingNoAdminRestrictions.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingsPerUser.Name, "1")
 	user1 := createTestUserInOrg(org)
 	user2 := createTestUserInOrg(org)
 	user3 := createTestUserInOrg(org)
@@ -1606,13 +1611,13 @@ func TestBookingsMaxConcurrentLimitExceededOnUpdate(t *testing.T) {
 		MaxConcurrentBookings: 2,
 		OrganizationID:        org.ID,
 	}
-	GetLocationRepository().Create(l)
+	testRepositories.Location.Create(l)
 	s1 := &Space{Name: "Test 1", LocationID: l.ID}
-	GetSpaceRepository().Create(s1)
+	testRepositories.Space.Create(s1)
 	s2 := &Space{Name: "Test 2", LocationID: l.ID}
-	GetSpaceRepository().Create(s2)
+	testRepositories.Space.Create(s2)
 	s3 := &Space{Name: "Test 3", LocationID: l.ID}
-	GetSpaceRepository().Create(s3)
+	testRepositories.Space.Create(s3)
 
 	// Create booking 1
 	payload := "{\"spaceId\": \"" + s1.ID + "\", \"enter\": \"2030-09-01T08:30:00+02:00\", \"leave\": \"2030-09-01T17:00:00+02:00\"}"
@@ -1651,8 +1656,8 @@ func TestBookingsMaxConcurrentLimitExceededOnUpdate(t *testing.T) {
 func TestBookingsMaxConcurrentLimitExceededHeadRequest(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingsPerUser.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingsPerUser.Name, "1")
 	user1 := createTestUserInOrg(org)
 	user2 := createTestUserInOrg(org)
 	user3 := createTestUserInOrg(org)
@@ -1662,11 +1667,11 @@ func TestBookingsMaxConcurrentLimitExceededHeadRequest(t *testing.T) {
 		MaxConcurrentBookings: 2,
 		OrganizationID:        org.ID,
 	}
-	GetLocationRepository().Create(l)
+	testRepositories.Location.Create(l)
 	s1 := &Space{Name: "Test 1", LocationID: l.ID}
-	GetSpaceRepository().Create(s1)
+	testRepositories.Space.Create(s1)
 	s2 := &Space{Name: "Test 2", LocationID: l.ID}
-	GetSpaceRepository().Create(s2)
+	testRepositories.Space.Create(s2)
 
 	//	 |------------------------| #1 - OK
 	//	|------------|              #2 - OK
@@ -1695,8 +1700,8 @@ func TestBookingsMaxConcurrentLimitExceededHeadRequest(t *testing.T) {
 func TestBookingsMaxConcurrentLimitComplex(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingsPerUser.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingsPerUser.Name, "1")
 	user1 := createTestUserInOrg(org)
 	user2 := createTestUserInOrg(org)
 	user3 := createTestUserInOrg(org)
@@ -1708,17 +1713,17 @@ func TestBookingsMaxConcurrentLimitComplex(t *testing.T) {
 		MaxConcurrentBookings: 2,
 		OrganizationID:        org.ID,
 	}
-	GetLocationRepository().Create(l)
+	testRepositories.Location.Create(l)
 	s1 := &Space{Name: "Test 1", LocationID: l.ID}
-	GetSpaceRepository().Create(s1)
+	testRepositories.Space.Create(s1)
 	s2 := &Space{Name: "Test 2", LocationID: l.ID}
-	GetSpaceRepository().Create(s2)
+	testRepositories.Space.Create(s2)
 	s3 := &Space{Name: "Test 3", LocationID: l.ID}
-	GetSpaceRepository().Create(s3)
+	testRepositories.Space.Create(s3)
 	s4 := &Space{Name: "Test 4", LocationID: l.ID}
-	GetSpaceRepository().Create(s4)
+	testRepositories.Space.Create(s4)
 	s5 := &Space{Name: "Test 5", LocationID: l.ID}
-	GetSpaceRepository().Create(s5)
+	testRepositories.Space.Create(s5)
 
 	//	|------------|                   #1 - OK  (07:30 - 12:00)
 	//	                      |-----|    #2 - OK  (16:00 - 19:00)
@@ -1760,8 +1765,8 @@ func TestBookingsMaxConcurrentLimitComplex(t *testing.T) {
 func TestBookingsMaxConcurrentLimitOKComplex(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingsPerUser.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingsPerUser.Name, "1")
 	user1 := createTestUserInOrg(org)
 	user2 := createTestUserInOrg(org)
 	user3 := createTestUserInOrg(org)
@@ -1771,13 +1776,13 @@ func TestBookingsMaxConcurrentLimitOKComplex(t *testing.T) {
 		MaxConcurrentBookings: 1,
 		OrganizationID:        org.ID,
 	}
-	GetLocationRepository().Create(l)
+	testRepositories.Location.Create(l)
 	s1 := &Space{Name: "Test 1", LocationID: l.ID}
-	GetSpaceRepository().Create(s1)
+	testRepositories.Space.Create(s1)
 	s2 := &Space{Name: "Test 2", LocationID: l.ID}
-	GetSpaceRepository().Create(s2)
+	testRepositories.Space.Create(s2)
 	s3 := &Space{Name: "Test 3", LocationID: l.ID}
-	GetSpaceRepository().Create(s3)
+	testRepositories.Space.Create(s3)
 
 	//	|------------|                   #1 - OK  (07:30 - 12:00)
 	//	                      |-----|    #2 - OK  (16:00 - 19:00)
@@ -1805,17 +1810,17 @@ func TestBookingsMaxConcurrentLimitOKComplex(t *testing.T) {
 func TestBookingsConvertTimestampDefaultSetting(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingDefaultTimezone.Name, "US/Central")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
+	testRepositories.Settings.Set(org.ID, SettingDefaultTimezone.Name, "US/Central")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
 	user1 := createTestUserInOrg(org)
 
 	l := &Location{
 		Name:           "Test",
 		OrganizationID: org.ID,
 	}
-	GetLocationRepository().Create(l)
+	testRepositories.Location.Create(l)
 	s1 := &Space{Name: "Test 1", LocationID: l.ID}
-	GetSpaceRepository().Create(s1)
+	testRepositories.Space.Create(s1)
 
 	// Create booking
 	payload := "{\"spaceId\": \"" + s1.ID + "\", \"enter\": \"2030-09-01T09:30:00Z\", \"leave\": \"2030-09-01T12:00:00Z\"}"
@@ -1837,7 +1842,7 @@ func TestBookingsConvertTimestampDefaultSetting(t *testing.T) {
 func TestBookingsConvertTimestamp(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
 	user1 := createTestUserInOrg(org)
 
 	l := &Location{
@@ -1845,9 +1850,9 @@ func TestBookingsConvertTimestamp(t *testing.T) {
 		OrganizationID: org.ID,
 		Timezone:       "US/Central",
 	}
-	GetLocationRepository().Create(l)
+	testRepositories.Location.Create(l)
 	s1 := &Space{Name: "Test 1", LocationID: l.ID}
-	GetSpaceRepository().Create(s1)
+	testRepositories.Space.Create(s1)
 
 	// Create booking
 	payload := "{\"spaceId\": \"" + s1.ID + "\", \"enter\": \"2030-09-01T09:30:00Z\", \"leave\": \"2030-09-01T12:00:00Z\"}"
@@ -1893,9 +1898,9 @@ func TestBookingsPresenceReport(t *testing.T) {
 		Name:           "Test",
 		OrganizationID: org.ID,
 	}
-	GetLocationRepository().Create(l)
+	testRepositories.Location.Create(l)
 	s1 := &Space{Name: "Test 1", LocationID: l.ID}
-	GetSpaceRepository().Create(s1)
+	testRepositories.Space.Create(s1)
 
 	tomorrow := time.Now().Add(24 * time.Hour)
 	tomorrow = time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 8, 0, 0, 0, tomorrow.Location())
@@ -1907,21 +1912,21 @@ func TestBookingsPresenceReport(t *testing.T) {
 		Enter:   tomorrow.Add(0 * time.Hour),
 		Leave:   tomorrow.Add(8 * time.Hour),
 	}
-	GetBookingRepository().Create(b1_1)
+	testRepositories.Booking.Create(b1_1)
 	b1_2 := &Booking{
 		UserID:  user1.ID,
 		SpaceID: s1.ID,
 		Enter:   tomorrow.Add((24 + 0) * time.Hour),
 		Leave:   tomorrow.Add((24 + 8) * time.Hour),
 	}
-	GetBookingRepository().Create(b1_2)
+	testRepositories.Booking.Create(b1_2)
 	b2_1 := &Booking{
 		UserID:  user2.ID,
 		SpaceID: s1.ID,
 		Enter:   tomorrow.Add((24*2 + 0) * time.Hour),
 		Leave:   tomorrow.Add((24*2 + 8) * time.Hour),
 	}
-	GetBookingRepository().Create(b2_1)
+	testRepositories.Booking.Create(b2_1)
 
 	end := tomorrow.Add(24 * 7 * time.Hour)
 	end = time.Date(end.Year(), end.Month(), end.Day(), 8, 0, 0, 0, end.Location())
@@ -1983,9 +1988,9 @@ func TestBookingsPresenceReport(t *testing.T) {
 func TestBookingsUserConcurrentOk(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingsPerUser.Name, "50")
-	GetSettingsRepository().Set(org.ID, SettingMaxConcurrentBookingsPerUser.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingsPerUser.Name, "50")
+	testRepositories.Settings.Set(org.ID, SettingMaxConcurrentBookingsPerUser.Name, "1")
 	user1 := createTestUserInOrg(org)
 	user2 := createTestUserInOrg(org)
 
@@ -1994,17 +1999,17 @@ func TestBookingsUserConcurrentOk(t *testing.T) {
 		MaxConcurrentBookings: 10,
 		OrganizationID:        org.ID,
 	}
-	GetLocationRepository().Create(l)
+	testRepositories.Location.Create(l)
 	s1 := &Space{Name: "Test 1", LocationID: l.ID}
-	GetSpaceRepository().Create(s1)
+	testRepositories.Space.Create(s1)
 	s2 := &Space{Name: "Test 2", LocationID: l.ID}
-	GetSpaceRepository().Create(s2)
+	testRepositories.Space.Create(s2)
 	s3 := &Space{Name: "Test 3", LocationID: l.ID}
-	GetSpaceRepository().Create(s3)
+	testRepositories.Space.Create(s3)
 	s4 := &Space{Name: "Test 4", LocationID: l.ID}
-	GetSpaceRepository().Create(s4)
+	testRepositories.Space.Create(s4)
 	s5 := &Space{Name: "Test 5", LocationID: l.ID}
-	GetSpaceRepository().Create(s5)
+	testRepositories.Space.Create(s5)
 
 	// all with overlap
 
@@ -2042,9 +2047,9 @@ func TestBookingsUserConcurrentOk(t *testing.T) {
 func TestBookingsUserConcurrentExceedLimit(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingsPerUser.Name, "50")
-	GetSettingsRepository().Set(org.ID, SettingMaxConcurrentBookingsPerUser.Name, "2")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingsPerUser.Name, "50")
+	testRepositories.Settings.Set(org.ID, SettingMaxConcurrentBookingsPerUser.Name, "2")
 	user1 := createTestUserInOrg(org)
 	user2 := createTestUserInOrg(org)
 
@@ -2053,17 +2058,17 @@ func TestBookingsUserConcurrentExceedLimit(t *testing.T) {
 		MaxConcurrentBookings: 10,
 		OrganizationID:        org.ID,
 	}
-	GetLocationRepository().Create(l)
+	testRepositories.Location.Create(l)
 	s1 := &Space{Name: "Test 1", LocationID: l.ID}
-	GetSpaceRepository().Create(s1)
+	testRepositories.Space.Create(s1)
 	s2 := &Space{Name: "Test 2", LocationID: l.ID}
-	GetSpaceRepository().Create(s2)
+	testRepositories.Space.Create(s2)
 	s3 := &Space{Name: "Test 3", LocationID: l.ID}
-	GetSpaceRepository().Create(s3)
+	testRepositories.Space.Create(s3)
 	s4 := &Space{Name: "Test 4", LocationID: l.ID}
-	GetSpaceRepository().Create(s4)
+	testRepositories.Space.Create(s4)
 	s5 := &Space{Name: "Test 5", LocationID: l.ID}
-	GetSpaceRepository().Create(s5)
+	testRepositories.Space.Create(s5)
 
 	// all with overlap
 
@@ -2113,9 +2118,9 @@ func TestBookingsUserConcurrentExceedLimit(t *testing.T) {
 func TestBookingsUserConcurrentNoLimit(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingsPerUser.Name, "50")
-	GetSettingsRepository().Set(org.ID, SettingMaxConcurrentBookingsPerUser.Name, "0")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingsPerUser.Name, "50")
+	testRepositories.Settings.Set(org.ID, SettingMaxConcurrentBookingsPerUser.Name, "0")
 	user1 := createTestUserInOrg(org)
 	user2 := createTestUserInOrg(org)
 
@@ -2124,17 +2129,17 @@ func TestBookingsUserConcurrentNoLimit(t *testing.T) {
 		MaxConcurrentBookings: 10,
 		OrganizationID:        org.ID,
 	}
-	GetLocationRepository().Create(l)
+	testRepositories.Location.Create(l)
 	s1 := &Space{Name: "Test 1", LocationID: l.ID}
-	GetSpaceRepository().Create(s1)
+	testRepositories.Space.Create(s1)
 	s2 := &Space{Name: "Test 2", LocationID: l.ID}
-	GetSpaceRepository().Create(s2)
+	testRepositories.Space.Create(s2)
 	s3 := &Space{Name: "Test 3", LocationID: l.ID}
-	GetSpaceRepository().Create(s3)
+	testRepositories.Space.Create(s3)
 	s4 := &Space{Name: "Test 4", LocationID: l.ID}
-	GetSpaceRepository().Create(s4)
+	testRepositories.Space.Create(s4)
 	s5 := &Space{Name: "Test 5", LocationID: l.ID}
-	GetSpaceRepository().Create(s5)
+	testRepositories.Space.Create(s5)
 
 	// all with overlap
 
@@ -2168,9 +2173,9 @@ func TestBookingsUserConcurrentNoLimit(t *testing.T) {
 func TestBookingsUserConcurrentLimitOkOnUpdate(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingsPerUser.Name, "50")
-	GetSettingsRepository().Set(org.ID, SettingMaxConcurrentBookingsPerUser.Name, "2")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingsPerUser.Name, "50")
+	testRepositories.Settings.Set(org.ID, SettingMaxConcurrentBookingsPerUser.Name, "2")
 	user1 := createTestUserInOrg(org)
 	user2 := createTestUserInOrg(org)
 
@@ -2179,17 +2184,17 @@ func TestBookingsUserConcurrentLimitOkOnUpdate(t *testing.T) {
 		MaxConcurrentBookings: 10,
 		OrganizationID:        org.ID,
 	}
-	GetLocationRepository().Create(l)
+	testRepositories.Location.Create(l)
 	s1 := &Space{Name: "Test 1", LocationID: l.ID}
-	GetSpaceRepository().Create(s1)
+	testRepositories.Space.Create(s1)
 	s2 := &Space{Name: "Test 2", LocationID: l.ID}
-	GetSpaceRepository().Create(s2)
+	testRepositories.Space.Create(s2)
 	s3 := &Space{Name: "Test 3", LocationID: l.ID}
-	GetSpaceRepository().Create(s3)
+	testRepositories.Space.Create(s3)
 	s4 := &Space{Name: "Test 4", LocationID: l.ID}
-	GetSpaceRepository().Create(s4)
+	testRepositories.Space.Create(s4)
 	s5 := &Space{Name: "Test 5", LocationID: l.ID}
-	GetSpaceRepository().Create(s5)
+	testRepositories.Space.Create(s5)
 
 	// all with overlap
 
@@ -2227,10 +2232,10 @@ func TestBookingsUserConcurrentLimitOkOnUpdate(t *testing.T) {
 func TestBookingsUserConcurrentLimitExceededOnUpdate(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingNoAdminRestrictions.Name, "1")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
-	GetSettingsRepository().Set(org.ID, SettingMaxBookingsPerUser.Name, "50")
-	GetSettingsRepository().Set(org.ID, SettingMaxConcurrentBookingsPerUser.Name, "2")
+	testRepositories.Settings.Set(org.ID, SettingNoAdminRestrictions.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
+	testRepositories.Settings.Set(org.ID, SettingMaxBookingsPerUser.Name, "50")
+	testRepositories.Settings.Set(org.ID, SettingMaxConcurrentBookingsPerUser.Name, "2")
 	user1 := createTestUserInOrg(org)
 	user2 := createTestUserInOrg(org)
 	adminUser := createTestUserOrgAdmin(org)
@@ -2240,17 +2245,17 @@ func TestBookingsUserConcurrentLimitExceededOnUpdate(t *testing.T) {
 		MaxConcurrentBookings: 10,
 		OrganizationID:        org.ID,
 	}
-	GetLocationRepository().Create(l)
+	testRepositories.Location.Create(l)
 	s1 := &Space{Name: "Test 1", LocationID: l.ID}
-	GetSpaceRepository().Create(s1)
+	testRepositories.Space.Create(s1)
 	s2 := &Space{Name: "Test 2", LocationID: l.ID}
-	GetSpaceRepository().Create(s2)
+	testRepositories.Space.Create(s2)
 	s3 := &Space{Name: "Test 3", LocationID: l.ID}
-	GetSpaceRepository().Create(s3)
+	testRepositories.Space.Create(s3)
 	s4 := &Space{Name: "Test 4", LocationID: l.ID}
-	GetSpaceRepository().Create(s4)
+	testRepositories.Space.Create(s4)
 	s5 := &Space{Name: "Test 5", LocationID: l.ID}
-	GetSpaceRepository().Create(s5)
+	testRepositories.Space.Create(s5)
 
 	// all with overlap
 
@@ -2296,9 +2301,9 @@ func TestBookingsUserConcurrentLimitExceededOnUpdate(t *testing.T) {
 func TestBookingsNonExistingUsers(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingAllowBookingsNonExistingUsers.Name, "1")
-	GetSettingsRepository().Set(org.ID, SettingNoAdminRestrictions.Name, "1")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
+	testRepositories.Settings.Set(org.ID, SettingAllowBookingsNonExistingUsers.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingNoAdminRestrictions.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, strconv.Itoa(365*10))
 	user := createTestUserInOrg(org)
 	adminUser := createTestUserOrgAdmin(org)
 
@@ -2307,9 +2312,9 @@ func TestBookingsNonExistingUsers(t *testing.T) {
 		MaxConcurrentBookings: 10,
 		OrganizationID:        org.ID,
 	}
-	GetLocationRepository().Create(l)
+	testRepositories.Location.Create(l)
 	s1 := &Space{Name: "Test 1", LocationID: l.ID}
-	GetSpaceRepository().Create(s1)
+	testRepositories.Space.Create(s1)
 
 	// admin books
 	payload := "{\"spaceId\": \"" + s1.ID + "\", \"userEmail\": \"noobie@test.com\", \"enter\": \"2030-09-01T07:30:00+02:00\", \"leave\": \"2030-09-01T12:00:00+02:00\"}"
@@ -2356,7 +2361,7 @@ func TestBookingsNonExistingUsers(t *testing.T) {
 	checkTestResponseCode(t, http.StatusForbidden, res.Code)
 
 	// disallow feature
-	GetSettingsRepository().Set(org.ID, SettingAllowBookingsNonExistingUsers.Name, "0")
+	testRepositories.Settings.Set(org.ID, SettingAllowBookingsNonExistingUsers.Name, "0")
 
 	payload = "{\"spaceId\": \"" + s1.ID + "\", \"userEmail\": \"noobie5@test.com\", \"enter\": \"2030-09-05T07:30:00+02:00\", \"leave\": \"2030-09-05T12:00:00+02:00\"}"
 	req = newHTTPRequest("POST", "/booking/", adminUser.ID, bytes.NewBufferString(payload))
@@ -2364,3 +2369,616 @@ func TestBookingsNonExistingUsers(t *testing.T) {
 	checkTestResponseCode(t, http.StatusForbidden, res.Code)
 
 }
+
+func TestBookingsCheckIn(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	user := createTestUserInOrg(org)
+	testRepositories.Settings.Set(org.ID, SettingEnableCheckIn.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingCheckInWindowMinutes.Name, "15")
+
+	l := &Location{
+		Name:           "Test",
+		OrganizationID: org.ID,
+		Timezone:       "Europe/Berlin",
+	}
+	testRepositories.Location.Create(l)
+	s1 := &Space{Name: "Test 1", LocationID: l.ID}
+	testRepositories.Space.Create(s1)
+
+	now, _ := getLocalWallTime(testRepositories, time.Now(), l)
+	b1 := &Booking{
+		UserID:  user.ID,
+		SpaceID: s1.ID,
+		Enter:   now.Add(time.Hour * 2),
+		Leave:   now.Add(time.Hour * 4),
+	}
+	testRepositories.Booking.Create(b1)
+	b2 := &Booking{
+		UserID:  user.ID,
+		SpaceID: s1.ID,
+		Enter:   now.Add(time.Minute * 10),
+		Leave:   now.Add(time.Minute * 70),
+	}
+	testRepositories.Booking.Create(b2)
+
+	// Check-in window not open yet
+	req := newHTTPRequest("POST", "/booking/"+b1.ID+"/checkin", user.ID, nil)
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+	checkTestString(t, strconv.Itoa(ResponseCodeBookingCheckInNotPossible), res.Header().Get("X-Error-Code"))
+
+	// Foreign user
+	user2 := createTestUserInOrg(org)
+	req = newHTTPRequest("POST", "/booking/"+b2.ID+"/checkin", user2.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+
+	// Within check-in window
+	req = newHTTPRequest("POST", "/booking/"+b2.ID+"/checkin", user.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	req = newHTTPRequest("GET", "/booking/"+b2.ID, user.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody *GetBookingResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestBool(t, true, resBody.CheckedIn)
+
+	// Check-in disabled
+	testRepositories.Settings.Set(org.ID, SettingEnableCheckIn.Name, "0")
+	req = newHTTPRequest("POST", "/booking/"+b1.ID+"/checkin", user.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+}
+
+func TestBookingsReleaseNoShows(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	user := createTestUserInOrg(org)
+	adminUser := createTestUserOrgAdmin(org)
+	testRepositories.Settings.Set(org.ID, SettingEnableCheckIn.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingCheckInWindowMinutes.Name, "15")
+	testRepositories.Settings.Set(org.ID, SettingEnableAutoRelease.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingAutoReleaseGraceMinutes.Name, "15")
+
+	l := &Location{
+		Name:           "Test",
+		OrganizationID: org.ID,
+		Timezone:       "America/New_York",
+	}
+	testRepositories.Location.Create(l)
+	// The bookings overlap, so each one needs its own space. The repository rejects
+	// overlapping bookings of the same space.
+	s1 := &Space{Name: "Test 1", LocationID: l.ID}
+	testRepositories.Space.Create(s1)
+	s2 := &Space{Name: "Test 2", LocationID: l.ID}
+	testRepositories.Space.Create(s2)
+	s3 := &Space{Name: "Test 3", LocationID: l.ID}
+	testRepositories.Space.Create(s3)
+
+	now, _ := getLocalWallTime(testRepositories, time.Now(), l)
+	// No-show
+	b1 := &Booking{
+		UserID:  user.ID,
+		SpaceID: s1.ID,
+		Enter:   now.Add(time.Minute * -45),
+		Leave:   now.Add(time.Hour * 2),
+	}
+	// Within grace period
+	b2 := &Booking{
+		UserID:  user.ID,
+		SpaceID: s2.ID,
+		Enter:   now.Add(time.Minute * -20),
+		Leave:   now.Add(time.Hour * 2),
+	}
+	// Checked in
+	b3 := &Booking{
+		UserID:  adminUser.ID,
+		SpaceID: s3.ID,
+		Enter:   now.Add(time.Minute * -45),
+		Leave:   now.Add(time.Hour * 2),
+	}
+	for _, e := range []*Booking{b1, b2, b3} {
+		if err := testRepositories.Booking.Create(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	testRepositories.Booking.CheckIn(b3, now.Add(time.Minute*-40))
+
+	router := &BookingRouter{repos: testRepositories}
+	if err := router.releaseNoShows(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := testRepositories.Booking.GetOne(b1.ID); err == nil {
+		t.Fatalf("Expected booking to be released")
+	}
+	if _, err := testRepositories.Booking.GetOne(b2.ID); err != nil {
+		t.Fatalf("Expected booking in grace period to be kept")
+	}
+	if _, err := testRepositories.Booking.GetOne(b3.ID); err != nil {
+		t.Fatalf("Expected checked in booking to be kept")
+	}
+
+	payload := "{\"start\": \"" + now.Add(time.Hour*-24).Format(JsDateTimeFormat) + "Z\", \"end\": \"" + now.Add(time.Hour*24).Format(JsDateTimeFormat) + "Z\"}"
+	req := newHTTPRequest("POST", "/booking/report/noshows/", adminUser.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody []*GetNoShowResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestInt(t, 1, len(resBody))
+	checkTestString(t, b1.ID, resBody[0].BookingID)
+	checkTestString(t, user.ID, resBody[0].UserID)
+
+	req = newHTTPRequest("POST", "/booking/report/noshows/", user.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+}
+
+func TestBookingsSendMail(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	org.Language = "en"
+	testRepositories.Organization.Update(org)
+	user := createTestUserInOrg(org)
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+
+	l := &Location{
+		Name:           "Test",
+		OrganizationID: org.ID,
+	}
+	testRepositories.Location.Create(l)
+	s1 := &Space{Name: "Test 1", LocationID: l.ID}
+	testRepositories.Space.Create(s1)
+
+	SendMailMockContent = ""
+	payload := "{\"spaceId\": \"" + s1.ID + "\", \"enter\": \"2030-09-01T08:30:00Z\", \"leave\": \"2030-09-01T17:00:00Z\"}"
+	req := newHTTPRequest("POST", "/booking/", user.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	id := res.Header().Get("X-Object-Id")
+	checkTestBool(t, true, strings.Contains(SendMailMockContent, "Subject: Your booking has been confirmed"))
+	checkTestBool(t, true, strings.Contains(SendMailMockContent, "From:     2030-09-01 08:30"))
+	checkTestBool(t, true, strings.Contains(SendMailMockContent, "method=REQUEST"))
+
+	SendMailMockContent = ""
+	req = newHTTPRequest("DELETE", "/booking/"+id, user.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+	checkTestBool(t, true, strings.Contains(SendMailMockContent, "Subject: Your booking has been cancelled"))
+	checkTestBool(t, true, strings.Contains(SendMailMockContent, "method=CANCEL"))
+
+	// Opt out
+	testRepositories.UserPreferences.Set(user.ID, PreferenceMailBookingConfirmation.Name, "0")
+	SendMailMockContent = ""
+	req = newHTTPRequest("POST", "/booking/", user.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	checkTestString(t, "", SendMailMockContent)
+}
+
+func TestBookingsSendReminder(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	org.Language = "en"
+	testRepositories.Organization.Update(org)
+	user := createTestUserInOrg(org)
+	testRepositories.Settings.Set(org.ID, SettingBookingReminderHours.Name, "2")
+
+	l := &Location{
+		Name:           "Test",
+		OrganizationID: org.ID,
+	}
+	testRepositories.Location.Create(l)
+	s1 := &Space{Name: "Test 1", LocationID: l.ID}
+	testRepositories.Space.Create(s1)
+
+	now, _ := getLocalWallTime(testRepositories, time.Now(), l)
+	b1 := &Booking{
+		UserID:  user.ID,
+		SpaceID: s1.ID,
+		Enter:   now.Add(time.Hour * 3),
+		Leave:   now.Add(time.Hour * 4),
+	}
+	testRepositories.Booking.Create(b1)
+
+	router := &BookingRouter{repos: testRepositories}
+	SendMailMockContent = ""
+	router.sendBookingReminders()
+	checkTestString(t, "", SendMailMockContent)
+
+	b1.Enter = now.Add(time.Hour * 1)
+	testRepositories.Booking.Update(b1)
+	router.sendBookingReminders()
+	checkTestBool(t, true, strings.Contains(SendMailMockContent, "Subject: Reminder: Your upcoming booking"))
+
+	// Reminder is sent only once
+	SendMailMockContent = ""
+	router.sendBookingReminders()
+	checkTestString(t, "", SendMailMockContent)
+}
+
+func TestBookingsMeetingRoomAttendees(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	adminUser := createTestUserOrgAdmin(org)
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+
+	// Create location
+	payload := `{"name": "Location 1"}`
+	req := newHTTPRequest("POST", "/location/", adminUser.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	locationID := res.Header().Get("X-Object-Id")
+
+	// Create meeting room and desk
+	payload = `{"name": "Room 1", "type": 2, "capacity": 3}`
+	req = newHTTPRequest("POST", "/location/"+locationID+"/space/", adminUser.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	roomID := res.Header().Get("X-Object-Id")
+	payload = `{"name": "H234"}`
+	req = newHTTPRequest("POST", "/location/"+locationID+"/space/", adminUser.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	deskID := res.Header().Get("X-Object-Id")
+
+	organizer := createTestUserInOrg(org)
+	attendee1 := createTestUserInOrg(org)
+	attendee2 := createTestUserInOrg(org)
+	attendee3 := createTestUserInOrg(org)
+	foreignUser := createTestUser("foo.com")
+
+	// Desks can't have attendees
+	payload = `{"spaceId": "` + deskID + `", "enter": "2030-09-01T08:30:00Z", "leave": "2030-09-01T09:30:00Z", "attendeeEmails": ["` + attendee1.Email + `"]}`
+	req = newHTTPRequest("POST", "/booking/", organizer.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+	checkTestString(t, strconv.Itoa(ResponseCodeBookingInvalidAttendees), res.Header().Get("X-Error-Code"))
+
+	// Attendees must be in the organization
+	payload = `{"spaceId": "` + roomID + `", "enter": "2030-09-01T08:30:00Z", "leave": "2030-09-01T09:30:00Z", "attendeeEmails": ["` + foreignUser.Email + `"]}`
+	req = newHTTPRequest("POST", "/booking/", organizer.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+	checkTestString(t, strconv.Itoa(ResponseCodeBookingInvalidAttendees), res.Header().Get("X-Error-Code"))
+
+	// Capacity includes the organizer
+	payload = `{"spaceId": "` + roomID + `", "enter": "2030-09-01T08:30:00Z", "leave": "2030-09-01T09:30:00Z", "attendeeEmails": ["` + attendee1.Email + `", "` + attendee2.Email + `", "` + attendee3.Email + `"]}`
+	req = newHTTPRequest("POST", "/booking/", organizer.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+	checkTestString(t, strconv.Itoa(ResponseCodeBookingCapacityExceeded), res.Header().Get("X-Error-Code"))
+
+	SendMailMockContent = ""
+	payload = `{"spaceId": "` + roomID + `", "enter": "2030-09-01T08:30:00Z", "leave": "2030-09-01T09:30:00Z", "subject": "Planning", "attendeeEmails": ["` + attendee1.Email + `", "` + attendee2.Email + `"]}`
+	req = newHTTPRequest("POST", "/booking/", organizer.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	id := res.Header().Get("X-Object-Id")
+	checkStringNotEmpty(t, SendMailMockContent)
+	booking, _ := testRepositories.Booking.GetOne(id)
+	invite := strings.ReplaceAll((&ICalRouter{repos: testRepositories}).renderInvite(booking, "REQUEST"), "\r\n ", "")
+	checkTestBool(t, true, strings.Contains(invite, "SUMMARY:Planning: Room 1 (Location 1)"))
+	checkTestBool(t, true, strings.Contains(invite, "mailto:"+attendee1.Email))
+	checkTestBool(t, true, strings.Contains(invite, "mailto:"+attendee2.Email))
+
+	// Attendees see the booking
+	req = newHTTPRequest("GET", "/booking/", attendee1.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody []*GetBookingResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestInt(t, 1, len(resBody))
+	checkTestString(t, id, resBody[0].ID)
+	checkTestString(t, organizer.ID, resBody[0].UserID)
+	checkTestString(t, "Planning", resBody[0].Subject)
+	checkTestInt(t, 2, len(resBody[0].AttendeeEmails))
+
+	req = newHTTPRequest("GET", "/booking/"+id, attendee2.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+
+	// Attendees can't change the booking
+	payload = `{"spaceId": "` + roomID + `", "enter": "2030-09-01T08:30:00Z", "leave": "2030-09-01T10:30:00Z"}`
+	req = newHTTPRequest("PUT", "/booking/"+id, attendee1.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+
+	// Conflicts are per room, not per attendee
+	payload = `{"spaceId": "` + roomID + `", "enter": "2030-09-01T09:00:00Z", "leave": "2030-09-01T10:00:00Z"}`
+	req = newHTTPRequest("POST", "/booking/", attendee3.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusConflict, res.Code)
+	payload = `{"spaceId": "` + deskID + `", "enter": "2030-09-01T08:30:00Z", "leave": "2030-09-01T09:30:00Z"}`
+	req = newHTTPRequest("POST", "/booking/", attendee1.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+
+	// Update without attendees keeps them, removed attendees are notified
+	payload = `{"spaceId": "` + roomID + `", "enter": "2030-09-01T08:30:00Z", "leave": "2030-09-01T10:30:00Z", "subject": "Planning"}`
+	req = newHTTPRequest("PUT", "/booking/"+id, organizer.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+	attendees, _ := testRepositories.BookingAttendee.GetAll(id)
+	checkTestInt(t, 2, len(attendees))
+
+	SendMailMockContent = ""
+	payload = `{"spaceId": "` + roomID + `", "enter": "2030-09-01T08:30:00Z", "leave": "2030-09-01T10:30:00Z", "subject": "Planning", "attendeeEmails": ["` + attendee1.Email + `"]}`
+	req = newHTTPRequest("PUT", "/booking/"+id, organizer.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+	attendees, _ = testRepositories.BookingAttendee.GetAll(id)
+	checkTestInt(t, 1, len(attendees))
+	checkTestString(t, attendee1.ID, attendees[0].UserID)
+
+	// Deleting the booking removes the attendees
+	req = newHTTPRequest("DELETE", "/booking/"+id, organizer.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+	attendees, _ = testRepositories.BookingAttendee.GetAll(id)
+	checkTestInt(t, 0, len(attendees))
+}
+
+func TestBookingsRestrictedSpace(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	adminUser := createTestUserOrgAdmin(org)
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+
+	firstAiders := &UserGroup{OrganizationID: org.ID, Name: "First Aid"}
+	testRepositories.UserGroup.Create(firstAiders)
+	interns := &UserGroup{OrganizationID: org.ID, Name: "Interns"}
+	testRepositories.UserGroup.Create(interns)
+	firstAider := createTestUserInOrg(org)
+	intern := createTestUserInOrg(org)
+	user := createTestUserInOrg(org)
+	testRepositories.UserGroup.AddMembers(firstAiders, []string{firstAider.ID})
+	testRepositories.UserGroup.AddMembers(interns, []string{intern.ID})
+
+	// Interns may not book in the location, the first aid desk is reserved
+	payload := `{"name": "Location 1", "deniedGroupIds": ["` + interns.ID + `"]}`
+	req := newHTTPRequest("POST", "/location/", adminUser.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	locationID := res.Header().Get("X-Object-Id")
+	payload = `{"name": "First Aid Desk", "allowedGroupIds": ["` + firstAiders.ID + `"]}`
+	req = newHTTPRequest("POST", "/location/"+locationID+"/space/", adminUser.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	restrictedID := res.Header().Get("X-Object-Id")
+	payload = `{"name": "H234"}`
+	req = newHTTPRequest("POST", "/location/"+locationID+"/space/", adminUser.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	deskID := res.Header().Get("X-Object-Id")
+
+	req = newHTTPRequest("GET", "/location/"+locationID+"/space/"+restrictedID, adminUser.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resSpace *GetSpaceResponse
+	json.Unmarshal(res.Body.Bytes(), &resSpace)
+	checkTestInt(t, 1, len(resSpace.AllowedGroupIDs))
+	checkTestString(t, firstAiders.ID, resSpace.AllowedGroupIDs[0])
+	checkTestInt(t, 0, len(resSpace.DeniedGroupIDs))
+
+	// Reserved desk
+	payload = `{"spaceId": "` + restrictedID + `", "enter": "2030-09-01T08:30:00Z", "leave": "2030-09-01T17:00:00Z"}`
+	req = newHTTPRequest("POST", "/booking/", user.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+	checkTestString(t, strconv.Itoa(ResponseCodeBookingSpaceRestricted), res.Header().Get("X-Error-Code"))
+	req = newHTTPRequest("POST", "/booking/", firstAider.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+
+	// Denied in location
+	payload = `{"spaceId": "` + deskID + `", "enter": "2030-09-01T08:30:00Z", "leave": "2030-09-01T17:00:00Z"}`
+	req = newHTTPRequest("POST", "/booking/", intern.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+	checkTestString(t, strconv.Itoa(ResponseCodeBookingSpaceRestricted), res.Header().Get("X-Error-Code"))
+	payload = `{"locationId": "` + locationID + `", "enter": "2030-09-01T08:30:00Z", "leave": "2030-09-01T17:00:00Z"}`
+	req = newHTTPRequest("POST", "/booking/precheck/", intern.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+	checkTestString(t, strconv.Itoa(ResponseCodeBookingSpaceRestricted), res.Header().Get("X-Error-Code"))
+	payload = `{"spaceId": "` + deskID + `", "enter": "2030-09-01T08:30:00Z", "leave": "2030-09-01T17:00:00Z"}`
+	req = newHTTPRequest("POST", "/booking/", user.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+
+	// Admins can't book restricted spaces for other users either
+	payload = `{"spaceId": "` + restrictedID + `", "enter": "2030-09-02T08:30:00Z", "leave": "2030-09-02T17:00:00Z", "userEmail": "` + user.Email + `"}`
+	req = newHTTPRequest("POST", "/booking/", adminUser.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+	checkTestString(t, strconv.Itoa(ResponseCodeBookingSpaceRestricted), res.Header().Get("X-Error-Code"))
+
+	// Lifting the restriction
+	payload = `{"name": "First Aid Desk", "allowedGroupIds": []}`
+	req = newHTTPRequest("PUT", "/location/"+locationID+"/space/"+restrictedID, adminUser.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+	payload = `{"spaceId": "` + restrictedID + `", "enter": "2030-09-02T08:30:00Z", "leave": "2030-09-02T17:00:00Z"}`
+	req = newHTTPRequest("POST", "/booking/", user.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+}
+
+func TestBookingsFilterExportCSV(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	user := createTestUserInOrgWithName(org, "u1@test.com", UserRoleUser)
+	_, spaceID := createTestWaitlistSpace(t, org, admin)
+	e := &Booking{
+		UserID:  user.ID,
+		SpaceID: spaceID,
+		Enter:   time.Date(2030, 9, 2, 8, 30, 0, 0, time.UTC),
+		Leave:   time.Date(2030, 9, 2, 17, 0, 0, 0, time.UTC),
+		Subject: "Planning, Q4",
+	}
+	testRepositories.Booking.Create(e)
+
+	payload := `{"start": "2030-09-02T00:00:00Z", "end": "2030-09-03T00:00:00Z"}`
+	req := newHTTPRequest("POST", "/booking/filter/?format=pdf", admin.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+
+	req = newHTTPRequest("POST", "/booking/filter/?format=csv", admin.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	checkTestBool(t, true, strings.HasPrefix(res.Header().Get("Content-Type"), ContentTypeCSV))
+	records, err := csv.NewReader(res.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tz, _ := testRepositories.Settings.Get(org.ID, SettingDefaultTimezone.Name)
+	checkTestInt(t, 2, len(records))
+	checkTestString(t, "userEmail", records[0][1])
+	checkTestString(t, e.ID, records[1][0])
+	checkTestString(t, "u1@test.com", records[1][1])
+	checkTestString(t, "Location 1", records[1][2])
+	checkTestString(t, "H234", records[1][3])
+	checkTestString(t, "2030-09-02 08:30", records[1][4])
+	checkTestString(t, "2030-09-02 17:00", records[1][5])
+	checkTestString(t, tz, records[1][6])
+	checkTestString(t, "Planning, Q4", records[1][7])
+}
+
+func TestBookingsFilterExportCSVInjection(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	_, spaceID := createTestWaitlistSpace(t, org, admin)
+	subjects := [][]string{
+		{"=HYPERLINK(\"http://evil.com\")", "'=HYPERLINK(\"http://evil.com\")"},
+		{"+1+1", "'+1+1"},
+		{"-1+1", "'-1+1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tTab", "'\tTab"},
+		{"\rReturn", "'\rReturn"},
+		{"-42", "-42"},
+		{"Planning", "Planning"},
+	}
+	for i, subject := range subjects {
+		e := &Booking{
+			UserID:  admin.ID,
+			SpaceID: spaceID,
+			Enter:   time.Date(2030, 9, 2, i, 0, 0, 0, time.UTC),
+			Leave:   time.Date(2030, 9, 2, i, 30, 0, 0, time.UTC),
+			Subject: subject[0],
+		}
+		testRepositories.Booking.Create(e)
+	}
+
+	payload := `{"start": "2030-09-02T00:00:00Z", "end": "2030-09-03T00:00:00Z"}`
+	req := newHTTPRequest("POST", "/booking/filter/?format=csv", admin.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	records, err := csv.NewReader(res.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestInt(t, len(subjects)+1, len(records))
+	for i, subject := range subjects {
+		checkTestString(t, subject[1], records[i+1][7])
+	}
+}
+
+func TestBookingsPresenceReportMaxRange(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+
+	payload := `{"start": "2030-01-01T00:00:00Z", "end": "2031-01-01T00:00:00Z"}`
+	req := newHTTPRequest("POST", "/booking/report/presence/", admin.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody *GetPresenceReportResult
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestInt(t, 365, len(resBody.Dates))
+
+	payload = `{"start": "2030-01-01T00:00:00Z", "end": "2031-01-03T00:00:00Z"}`
+	req = newHTTPRequest("POST", "/booking/report/presence/", admin.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+}
+
+func TestBookingsPresenceReportExportXLSX(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserInOrgWithName(org, "admin@test.com", UserRoleSpaceAdmin)
+	user := createTestUserInOrgWithName(org, "u1&2@test.com", UserRoleUser)
+	l := &Location{Name: "Test", OrganizationID: org.ID}
+	testRepositories.Location.Create(l)
+	s1 := &Space{Name: "Test 1", LocationID: l.ID}
+	testRepositories.Space.Create(s1)
+	testRepositories.Booking.Create(&Booking{
+		UserID:  user.ID,
+		SpaceID: s1.ID,
+		Enter:   time.Date(2030, 9, 3, 8, 0, 0, 0, time.UTC),
+		Leave:   time.Date(2030, 9, 3, 17, 0, 0, 0, time.UTC),
+	})
+
+	payload := `{"start": "2030-09-02T00:00:00Z", "end": "2030-09-04T00:00:00Z"}`
+	req := newHTTPRequest("POST", "/booking/report/presence/", admin.ID, bytes.NewBufferString(payload))
+	req.Header.Set("Accept", ContentTypeXLSX)
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	checkTestString(t, ContentTypeXLSX, res.Header().Get("Content-Type"))
+	body := res.Body.Bytes()
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sheet string
+	for _, f := range archive.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			r, _ := f.Open()
+			b, _ := io.ReadAll(r)
+			sheet = string(b)
+		}
+	}
+	checkTestBool(t, true, strings.Contains(sheet, `<c r="B1" t="inlineStr"><is><t xml:space="preserve">2030-09-02</t></is></c>`))
+	checkTestBool(t, true, strings.Contains(sheet, `<c r="A3" t="inlineStr"><is><t xml:space="preserve">u1&amp;2@test.com</t></is></c><c r="B3"><v>0</v></c><c r="C3"><v>1</v></c>`))
+}
+
+func TestBookingsCreateConcurrent(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	_, spaceID := createTestWaitlistSpace(t, org, admin)
+	var users []*User
+	for i := 0; i < 20; i++ {
+		users = append(users, createTestUserInOrgWithName(org, "u"+strconv.Itoa(i)+"@test.com", UserRoleUser))
+	}
+
+	payload := "{\"spaceId\": \"" + spaceID + "\", \"enter\": \"2030-09-02T08:00:00Z\", \"leave\": \"2030-09-02T17:00:00Z\"}"
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	codes := make(map[int]int)
+	for _, user := range users {
+		wg.Add(1)
+		go func(user *User) {
+			defer wg.Done()
+			req := newHTTPRequest("POST", "/booking/", user.ID, bytes.NewBufferString(payload))
+			res := executeTestRequest(req)
+			mu.Lock()
+			defer mu.Unlock()
+			codes[res.Code]++
+			if res.Code == http.StatusConflict {
+				checkTestString(t, strconv.Itoa(ResponseCodeBookingSlotConflict), res.Header().Get("X-Error-Code"))
+			}
+		}(user)
+	}
+	wg.Wait()
+	checkTestInt(t, 1, codes[http.StatusCreated])
+	checkTestInt(t, len(users)-1, codes[http.StatusConflict])
+
+	bookings, _ := testRepositories.Booking.GetConflicts(spaceID, time.Date(2030, 9, 2, 0, 0, 0, 0, time.UTC), time.Date(2030, 9, 3, 0, 0, 0, 0, time.UTC), "")
+	checkTestInt(t, 1, len(bookings))
+}
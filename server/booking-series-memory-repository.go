@@ -0,0 +1,80 @@
+package main
+
+import "time"
+
+type MemoryBookingSeriesRepository struct {
+	bookingSeriesRepositoryBase
+}
+
+func (r *MemoryBookingSeriesRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryBookingSeriesRepository) Create(e *BookingSeries) error {
+	if err := memoryCheckUUID(e.UserID, e.SpaceID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e.ID = memoryNewID()
+	memoryData.bookingSeries = append(memoryData.bookingSeries, r.copy(e))
+	return nil
+}
+
+func (r *MemoryBookingSeriesRepository) GetOne(id string) (*BookingSeries, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryGet(memoryData.bookingSeries, func(e *BookingSeries) bool {
+		return e.ID == id
+	})
+}
+
+func (r *MemoryBookingSeriesRepository) GetAllToMaterialize(until time.Time) ([]*BookingSeries, error) {
+	until = memoryTimestamp(until)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	result := memoryCopy(memoryFilter(memoryData.bookingSeries, func(e *BookingSeries) bool {
+		return e.MaterializedUntil.Before(until)
+	}))
+	memorySort(result, func(a, b *BookingSeries) bool {
+		return a.Enter.Before(b.Enter)
+	})
+	return result, nil
+}
+
+func (r *MemoryBookingSeriesRepository) Update(e *BookingSeries) error {
+	if err := memoryCheckUUID(e.UserID, e.SpaceID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	row, err := memoryFind(memoryData.bookingSeries, func(row *BookingSeries) bool {
+		return row.ID == e.ID
+	})
+	if err != nil {
+		return nil
+	}
+	*row = *r.copy(e)
+	return nil
+}
+
+func (r *MemoryBookingSeriesRepository) Delete(e *BookingSeries) error {
+	if err := r.repos.Booking.DetachFromSeries(e.ID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.bookingSeries = memoryDelete(memoryData.bookingSeries, func(row *BookingSeries) bool {
+		return row.ID == e.ID
+	})
+	return nil
+}
+
+func (r *MemoryBookingSeriesRepository) copy(e *BookingSeries) *BookingSeries {
+	c := *e
+	c.Enter = memoryTimestamp(e.Enter)
+	c.Leave = memoryTimestamp(e.Leave)
+	c.MaterializedUntil = memoryTimestamp(e.MaterializedUntil)
+	return &c
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+type BookingSeriesRepository interface {
+	Repository
+	Create(e *BookingSeries) error
+	GetOne(id string) (*BookingSeries, error)
+	GetAllToMaterialize(until time.Time) ([]*BookingSeries, error)
+	Update(e *BookingSeries) error
+	Delete(e *BookingSeries) error
+	GetOccurrences(e *BookingSeries, from, until time.Time) ([]*BookingSeriesOccurrence, error)
+	TruncateRRule(e *BookingSeries, before time.Time) (string, error)
+	isValidRRule(s string) bool
+}
+
+type PostgresBookingSeriesRepository struct {
+	bookingSeriesRepositoryBase
+}
+
+// bookingSeriesRepositoryBase expands recurrence rules; it is the same for every storage.
+type bookingSeriesRepositoryBase struct {
+	repos *Repositories
+}
+
+// BookingSeries describes a recurring booking. Enter and Leave hold the
+// first occurrence as local wall clock time of the location, RRule holds
+// the RFC 5545 recurrence rule (without DTSTART).
+// Occurrences are materialized as regular bookings up to MaterializedUntil.
+type BookingSeries struct {
+	ID                string
+	UserID            string
+	SpaceID           string
+	Enter             time.Time
+	Leave             time.Time
+	RRule             string
+	MaterializedUntil time.Time
+}
+
+type BookingSeriesOccurrence struct {
+	Enter time.Time
+	Leave time.Time
+}
+
+const BookingSeriesMaxOccurrences int = 366
+
+func (r *PostgresBookingSeriesRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		{
+			Version:     16,
+			Description: "Add booking series",
+			Up: []string{
+				"CREATE TABLE IF NOT EXISTS booking_series (" +
+					"id uuid DEFAULT uuid_generate_v4(), " +
+					"user_id uuid NOT NULL, " +
+					"space_id uuid NOT NULL, " +
+					"enter_time TIMESTAMP NOT NULL, " +
+					"leave_time TIMESTAMP NOT NULL, " +
+					"rrule VARCHAR NOT NULL, " +
+					"materialized_until TIMESTAMP NOT NULL, " +
+					"PRIMARY KEY (id))",
+				"CREATE INDEX IF NOT EXISTS idx_booking_series_user_id ON booking_series(user_id)",
+			},
+			Down: []string{"DROP TABLE IF EXISTS booking_series"},
+		},
+	}
+}
+
+func (r *PostgresBookingSeriesRepository) Create(e *BookingSeries) error {
+	var id string
+	err := GetDatabase().DB().QueryRow("INSERT INTO booking_series "+
+		"(user_id, space_id, enter_time, leave_time, rrule, materialized_until) "+
+		"VALUES ($1, $2, $3, $4, $5, $6) "+
+		"RETURNING id",
+		e.UserID, e.SpaceID, e.Enter, e.Leave, e.RRule, e.MaterializedUntil).Scan(&id)
+	if err != nil {
+		return err
+	}
+	e.ID = id
+	return nil
+}
+
+func (r *PostgresBookingSeriesRepository) GetOne(id string) (*BookingSeries, error) {
+	e := &BookingSeries{}
+	err := GetDatabase().DB().QueryRow("SELECT id, user_id, space_id, enter_time, leave_time, rrule, materialized_until "+
+		"FROM booking_series "+
+		"WHERE id = $1",
+		id).Scan(&e.ID, &e.UserID, &e.SpaceID, &e.Enter, &e.Leave, &e.RRule, &e.MaterializedUntil)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// GetAllToMaterialize returns all series which have not been expanded up to the specified time yet.
+func (r *PostgresBookingSeriesRepository) GetAllToMaterialize(until time.Time) ([]*BookingSeries, error) {
+	var result []*BookingSeries
+	rows, err := GetDatabase().DB().Query("SELECT id, user_id, space_id, enter_time, leave_time, rrule, materialized_until "+
+		"FROM booking_series "+
+		"WHERE materialized_until < $1 "+
+		"ORDER BY enter_time", until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &BookingSeries{}
+		err = rows.Scan(&e.ID, &e.UserID, &e.SpaceID, &e.Enter, &e.Leave, &e.RRule, &e.MaterializedUntil)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (r *PostgresBookingSeriesRepository) Update(e *BookingSeries) error {
+	_, err := GetDatabase().DB().Exec("UPDATE booking_series SET "+
+		"user_id = $1, "+
+		"space_id = $2, "+
+		"enter_time = $3, "+
+		"leave_time = $4, "+
+		"rrule = $5, "+
+		"materialized_until = $6 "+
+		"WHERE id = $7",
+		e.UserID, e.SpaceID, e.Enter, e.Leave, e.RRule, e.MaterializedUntil, e.ID)
+	return err
+}
+
+func (r *PostgresBookingSeriesRepository) Delete(e *BookingSeries) error {
+	if err := r.repos.Booking.DetachFromSeries(e.ID); err != nil {
+		return err
+	}
+	_, err := GetDatabase().DB().Exec("DELETE FROM booking_series WHERE id = $1", e.ID)
+	return err
+}
+
+// GetOccurrences expands the series' recurrence rule and returns all occurrences
+// starting within [from, until]. All times are local wall clock times of the location.
+func (r *bookingSeriesRepositoryBase) GetOccurrences(e *BookingSeries, from, until time.Time) ([]*BookingSeriesOccurrence, error) {
+	rule, err := r.parseRRule(e.RRule, e.Enter)
+	if err != nil {
+		return nil, err
+	}
+	duration := e.Leave.Sub(e.Enter)
+	var result []*BookingSeriesOccurrence
+	for _, enter := range rule.Between(from, until, true) {
+		if len(result) >= BookingSeriesMaxOccurrences {
+			break
+		}
+		result = append(result, &BookingSeriesOccurrence{
+			Enter: enter,
+			Leave: enter.Add(duration),
+		})
+	}
+	return result, nil
+}
+
+// TruncateRRule returns the series' recurrence rule limited to occurrences starting before the specified time.
+func (r *bookingSeriesRepositoryBase) TruncateRRule(e *BookingSeries, before time.Time) (string, error) {
+	rule, err := r.parseRRule(e.RRule, e.Enter)
+	if err != nil {
+		return "", err
+	}
+	option := rule.OrigOptions
+	option.Dtstart = time.Time{}
+	option.Count = 0
+	option.Until = before.Add(-1 * time.Second).UTC()
+	return option.RRuleString(), nil
+}
+
+func (r *bookingSeriesRepositoryBase) isValidRRule(s string) bool {
+	_, err := r.parseRRule(s, time.Now().UTC())
+	return err == nil
+}
+
+func (r *bookingSeriesRepositoryBase) parseRRule(s string, dtstart time.Time) (*rrule.RRule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" || strings.Contains(s, "\n") {
+		return nil, errors.New("invalid recurrence rule")
+	}
+	option, err := rrule.StrToROption(s)
+	if err != nil {
+		return nil, err
+	}
+	if option.Freq != rrule.DAILY && option.Freq != rrule.WEEKLY && option.Freq != rrule.MONTHLY {
+		return nil, errors.New("unsupported recurrence frequency")
+	}
+	option.Dtstart = dtstart
+	return rrule.NewRRule(*option)
+}
@@ -0,0 +1,666 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type BookingSeriesRouter struct {
+	repos *Repositories
+}
+
+// CreateBookingSeriesRequest doesn't support attendees, series are for personal bookings.
+type CreateBookingSeriesRequest struct {
+	RRule string `json:"rrule" validate:"required"`
+	CreateBookingRequest
+}
+
+type GetBookingSeriesResponse struct {
+	ID       string                `json:"id"`
+	UserID   string                `json:"userId"`
+	Bookings []*GetBookingResponse `json:"bookings"`
+	CreateBookingSeriesRequest
+}
+
+var ErrBookingSeriesNoOccurrences = errors.New("recurrence rule has no upcoming occurrences")
+
+func (router *BookingSeriesRouter) setupRoutes(s *mux.Router) {
+	s.HandleFunc("/{id}/following/{bookingId}", router.updateFollowing).Methods("PUT")
+	s.HandleFunc("/{id}/following/{bookingId}", router.deleteFollowing).Methods("DELETE")
+	s.HandleFunc("/{id}", router.getOne).Methods("GET")
+	s.HandleFunc("/{id}", router.update).Methods("PUT")
+	s.HandleFunc("/{id}", router.delete).Methods("DELETE")
+	s.HandleFunc("/", router.create).Methods("POST")
+}
+
+func (router *BookingSeriesRouter) getOne(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	e, err := router.repos.BookingSeries.GetOne(vars["id"])
+	if err != nil {
+		log.Println(err)
+		SendNotFound(w)
+		return
+	}
+	location, err := router.getLocation(e.SpaceID)
+	if err != nil {
+		SendNotFound(w)
+		return
+	}
+	requestUser := GetRequestUser(router.repos, r)
+	if !router.canModify(e, location, requestUser) {
+		SendForbidden(w)
+		return
+	}
+	bookings, err := router.repos.Booking.GetAllBySeries(e.ID, time.Now().UTC())
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	res := router.copyToRestModel(e, bookings)
+	SendJSON(w, res)
+}
+
+func (router *BookingSeriesRouter) create(w http.ResponseWriter, r *http.Request) {
+	var m CreateBookingSeriesRequest
+	if UnmarshalValidateBody(r, &m) != nil {
+		SendBadRequest(w)
+		return
+	}
+	if !m.Leave.After(m.Enter) || !router.repos.BookingSeries.isValidRRule(m.RRule) || len(m.AttendeeEmails) > 0 {
+		SendBadRequest(w)
+		return
+	}
+	location, err := router.getLocation(m.SpaceID)
+	if err != nil {
+		SendBadRequest(w)
+		return
+	}
+	requestUser := GetRequestUser(router.repos, r)
+	if !CanAccessOrg(requestUser, location.OrganizationID) {
+		SendForbidden(w)
+		return
+	}
+	e := router.copyFromRestModel(&m)
+	e.UserID = requestUser.ID
+	if m.UserEmail != "" && m.UserEmail != requestUser.Email {
+		if !CanSpaceAdminOrg(requestUser, location.OrganizationID) {
+			SendForbidden(w)
+			return
+		}
+		bookingRouter := &BookingRouter{repos: router.repos}
+		e.UserID, err = bookingRouter.bookForUser(requestUser, m.UserEmail, w)
+		if err != nil {
+			SendInternalServerError(w)
+			return
+		}
+	}
+	e.MaterializedUntil = router.getHorizon(location)
+	if err := router.repos.BookingSeries.Create(e); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	if _, code, err := router.createOccurrences(r.Context(), e, location, requestUser, e.Enter); err != nil {
+		router.repos.BookingSeries.Delete(e)
+		router.sendOccurrenceError(w, code, err)
+		return
+	}
+	SendCreated(w, e.ID)
+}
+
+// update replaces all upcoming occurrences of the series ("whole series").
+func (router *BookingSeriesRouter) update(w http.ResponseWriter, r *http.Request) {
+	var m CreateBookingSeriesRequest
+	if UnmarshalValidateBody(r, &m) != nil {
+		SendBadRequest(w)
+		return
+	}
+	if !m.Leave.After(m.Enter) || !router.repos.BookingSeries.isValidRRule(m.RRule) || len(m.AttendeeEmails) > 0 {
+		SendBadRequest(w)
+		return
+	}
+	vars := mux.Vars(r)
+	e, err := router.repos.BookingSeries.GetOne(vars["id"])
+	if err != nil {
+		SendNotFound(w)
+		return
+	}
+	location, err := router.getLocation(m.SpaceID)
+	if err != nil {
+		SendBadRequest(w)
+		return
+	}
+	requestUser := GetRequestUser(router.repos, r)
+	oldLocation, err := router.getLocation(e.SpaceID)
+	if err != nil || !router.canModify(e, oldLocation, requestUser) || !router.canModify(e, location, requestUser) {
+		SendForbidden(w)
+		return
+	}
+	old, err := router.repos.Booking.GetAllBySeries(e.ID, time.Now().UTC())
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	if !router.canDeleteOccurrences(old, requestUser, oldLocation) {
+		SendForbiddenCode(w, ResponseCodeBookingMaxHoursBeforeDelete)
+		return
+	}
+	if err := router.deleteOccurrences(old); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	eOld := *e
+	eNew := router.copyFromRestModel(&m)
+	e.SpaceID = eNew.SpaceID
+	e.Enter = eNew.Enter
+	e.Leave = eNew.Leave
+	e.RRule = eNew.RRule
+	e.MaterializedUntil = router.getHorizon(location)
+	if _, code, err := router.createOccurrences(r.Context(), e, location, requestUser, e.Enter); err != nil {
+		router.restoreOccurrences(old)
+		router.sendOccurrenceError(w, code, err)
+		return
+	}
+	if err := router.repos.BookingSeries.Update(e); err != nil {
+		log.Println(err)
+		router.restoreSeries(&eOld, e, old)
+		SendInternalServerError(w)
+		return
+	}
+	SendUpdated(w)
+}
+
+// delete cancels all upcoming occurrences and removes the series ("whole series").
+// Past occurrences are kept as regular bookings.
+func (router *BookingSeriesRouter) delete(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	e, err := router.repos.BookingSeries.GetOne(vars["id"])
+	if err != nil {
+		SendNotFound(w)
+		return
+	}
+	location, err := router.getLocation(e.SpaceID)
+	if err != nil {
+		SendBadRequest(w)
+		return
+	}
+	requestUser := GetRequestUser(router.repos, r)
+	if !router.canModify(e, location, requestUser) {
+		SendForbidden(w)
+		return
+	}
+	list, err := router.repos.Booking.GetAllBySeries(e.ID, time.Now().UTC())
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	if !router.canDeleteOccurrences(list, requestUser, location) {
+		SendForbiddenCode(w, ResponseCodeBookingMaxHoursBeforeDelete)
+		return
+	}
+	if err := router.deleteOccurrences(list); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	if err := router.repos.BookingSeries.Delete(e); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	waitlistRouter := &WaitlistRouter{repos: router.repos}
+	waitlistRouter.onSpaceFreed(e.SpaceID)
+	SendUpdated(w)
+}
+
+// updateFollowing splits the series at the specified occurrence ("this and following"):
+// the existing series ends before the occurrence, a new series replaces the
+// occurrence and all following ones.
+func (router *BookingSeriesRouter) updateFollowing(w http.ResponseWriter, r *http.Request) {
+	var m CreateBookingSeriesRequest
+	if UnmarshalValidateBody(r, &m) != nil {
+		SendBadRequest(w)
+		return
+	}
+	if !m.Leave.After(m.Enter) || !router.repos.BookingSeries.isValidRRule(m.RRule) || len(m.AttendeeEmails) > 0 {
+		SendBadRequest(w)
+		return
+	}
+	e, booking, ok := router.getSeriesAndOccurrence(w, r)
+	if !ok {
+		return
+	}
+	location, err := router.getLocation(m.SpaceID)
+	if err != nil {
+		SendBadRequest(w)
+		return
+	}
+	requestUser := GetRequestUser(router.repos, r)
+	if !router.canModify(e, &booking.Space.Location, requestUser) || !router.canModify(e, location, requestUser) {
+		SendForbidden(w)
+		return
+	}
+	old, err := router.getFollowingOccurrences(e, booking)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	if !router.canDeleteOccurrences(old, requestUser, &booking.Space.Location) {
+		SendForbiddenCode(w, ResponseCodeBookingMaxHoursBeforeDelete)
+		return
+	}
+	eOld := *e
+	if e.RRule, err = router.repos.BookingSeries.TruncateRRule(e, booking.Enter); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	if err := router.deleteOccurrences(old); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	eNew := router.copyFromRestModel(&m)
+	eNew.UserID = e.UserID
+	eNew.MaterializedUntil = router.getHorizon(location)
+	if err := router.repos.BookingSeries.Create(eNew); err != nil {
+		log.Println(err)
+		router.restoreOccurrences(old)
+		SendInternalServerError(w)
+		return
+	}
+	if _, code, err := router.createOccurrences(r.Context(), eNew, location, requestUser, eNew.Enter); err != nil {
+		router.repos.BookingSeries.Delete(eNew)
+		router.restoreOccurrences(old)
+		router.sendOccurrenceError(w, code, err)
+		return
+	}
+	if err := router.repos.BookingSeries.Update(e); err != nil {
+		log.Println(err)
+		router.restoreSeries(&eOld, eNew, old)
+		router.repos.BookingSeries.Delete(eNew)
+		SendInternalServerError(w)
+		return
+	}
+	SendCreated(w, eNew.ID)
+}
+
+// deleteFollowing cancels the specified occurrence and all following ones ("this and following").
+func (router *BookingSeriesRouter) deleteFollowing(w http.ResponseWriter, r *http.Request) {
+	e, booking, ok := router.getSeriesAndOccurrence(w, r)
+	if !ok {
+		return
+	}
+	requestUser := GetRequestUser(router.repos, r)
+	if !router.canModify(e, &booking.Space.Location, requestUser) {
+		SendForbidden(w)
+		return
+	}
+	list, err := router.getFollowingOccurrences(e, booking)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	if !router.canDeleteOccurrences(list, requestUser, &booking.Space.Location) {
+		SendForbiddenCode(w, ResponseCodeBookingMaxHoursBeforeDelete)
+		return
+	}
+	if e.RRule, err = router.repos.BookingSeries.TruncateRRule(e, booking.Enter); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	if err := router.repos.BookingSeries.Update(e); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	if err := router.deleteOccurrences(list); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	waitlistRouter := &WaitlistRouter{repos: router.repos}
+	waitlistRouter.onSpaceFreed(e.SpaceID)
+	SendUpdated(w)
+}
+
+// materializeAll creates the occurrences which moved into the booking horizon since the last run.
+// Occurrences failing the booking checks are skipped.
+func (router *BookingSeriesRouter) materializeAll() error {
+	now := time.Now().UTC()
+	list, err := router.repos.BookingSeries.GetAllToMaterialize(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		return err
+	}
+	for _, e := range list {
+		location, err := router.getLocation(e.SpaceID)
+		if err != nil {
+			log.Printf("Could not load location of booking series %s: %s", e.ID, err)
+			continue
+		}
+		user, err := router.repos.User.GetOne(e.UserID)
+		if err != nil {
+			log.Printf("Could not load user of booking series %s: %s", e.ID, err)
+			continue
+		}
+		horizon := router.getHorizon(location)
+		if !horizon.After(e.MaterializedUntil) {
+			continue
+		}
+		if user.Disabled {
+			// Occurrences falling into the time a user is disabled are skipped for good
+			e.MaterializedUntil = horizon
+			if err := router.repos.BookingSeries.Update(e); err != nil {
+				log.Println(err)
+			}
+			continue
+		}
+		from := e.MaterializedUntil.Add(time.Second)
+		occurrences, err := router.repos.BookingSeries.GetOccurrences(e, from, horizon)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		space, err := router.repos.Space.GetOne(e.SpaceID)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		unlock, err := router.repos.Booking.Lock(context.Background(), e.UserID, location.ID)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		bookingRouter := &BookingRouter{repos: router.repos}
+		for _, occurrence := range occurrences {
+			booking, err := router.getOccurrenceBooking(e, occurrence, location)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			if code := router.checkOccurrence(bookingRouter, booking, location, user); code != 0 {
+				log.Printf("Skipping occurrence %s of booking series %s with code %d", occurrence.Enter.Format(JsDateTimeFormat), e.ID, code)
+				continue
+			}
+			if err := bookingRouter.setApprovalStatus(booking, space, location, user); err != nil {
+				log.Println(err)
+				continue
+			}
+			if err := router.repos.Booking.Create(booking); err != nil {
+				log.Println(err)
+			}
+		}
+		unlock()
+		e.MaterializedUntil = horizon
+		if err := router.repos.BookingSeries.Update(e); err != nil {
+			log.Println(err)
+		}
+	}
+	return nil
+}
+
+// createOccurrences validates and creates all occurrences of the series from the specified
+// local wall clock time up to the series' materialization horizon. If one occurrence
+// fails, all occurrences created so far are removed again.
+func (router *BookingSeriesRouter) createOccurrences(ctx context.Context, e *BookingSeries, location *Location, requestUser *User, from time.Time) ([]*Booking, int, error) {
+	now, err := getLocalWallTime(router.repos, time.Now(), location)
+	if err != nil {
+		return nil, 0, err
+	}
+	if from.Before(now) {
+		from = now
+	}
+	occurrences, err := router.repos.BookingSeries.GetOccurrences(e, from, e.MaterializedUntil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(occurrences) == 0 {
+		return nil, 0, ErrBookingSeriesNoOccurrences
+	}
+	user := requestUser
+	if e.UserID != requestUser.ID {
+		if user, err = router.repos.User.GetOne(e.UserID); err != nil {
+			return nil, 0, err
+		}
+	}
+	space, err := router.repos.Space.GetOne(e.SpaceID)
+	if err != nil {
+		return nil, 0, err
+	}
+	unlock, err := router.repos.Booking.Lock(ctx, e.UserID, location.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer unlock()
+	bookingRouter := &BookingRouter{repos: router.repos}
+	var created []*Booking
+	var rollback = func() {
+		for _, booking := range created {
+			router.repos.Booking.Delete(&BookingDetails{Booking: *booking})
+		}
+	}
+	for _, occurrence := range occurrences {
+		booking, err := router.getOccurrenceBooking(e, occurrence, location)
+		if err != nil {
+			rollback()
+			return nil, 0, err
+		}
+		if code := router.checkOccurrence(bookingRouter, booking, location, requestUser); code != 0 {
+			rollback()
+			return nil, code, errors.New("occurrence " + occurrence.Enter.Format(JsDateTimeFormat) + " of user " + user.ID + " is invalid")
+		}
+		if err := bookingRouter.setApprovalStatus(booking, space, location, requestUser); err != nil {
+			rollback()
+			return nil, 0, err
+		}
+		if err := router.repos.Booking.Create(booking); err != nil {
+			rollback()
+			if errors.Is(err, ErrBookingConflict) {
+				return nil, ResponseCodeBookingSlotConflict, err
+			}
+			return nil, 0, err
+		}
+		created = append(created, booking)
+	}
+	return created, 0, nil
+}
+
+// checkOccurrence runs the same checks as a single booking request and returns
+// 0 if the occurrence is valid, otherwise the response code.
+func (router *BookingSeriesRouter) checkOccurrence(bookingRouter *BookingRouter, booking *Booking, location *Location, requestUser *User) int {
+	bookingReq := &BookingRequest{
+		Enter: booking.Enter,
+		Leave: booking.Leave,
+	}
+	if valid, code := bookingRouter.checkBookingCreateUpdate(bookingReq, location, booking.SpaceID, requestUser, booking.UserID, ""); !valid {
+		return code
+	}
+	conflicts, err := router.repos.Booking.GetConflicts(booking.SpaceID, booking.Enter, booking.Leave, "")
+	if err != nil {
+		log.Println(err)
+		return ResponseCodeBookingSlotConflict
+	}
+	if len(conflicts) > 0 {
+		return ResponseCodeBookingSlotConflict
+	}
+	held, err := router.repos.Waitlist.HasPendingOffer(booking.SpaceID, booking.Enter, booking.Leave, booking.UserID)
+	if err != nil {
+		log.Println(err)
+		return ResponseCodeBookingSlotConflict
+	}
+	if held {
+		return ResponseCodeBookingSlotConflict
+	}
+	return 0
+}
+
+func (router *BookingSeriesRouter) getOccurrenceBooking(e *BookingSeries, occurrence *BookingSeriesOccurrence, location *Location) (*Booking, error) {
+	enter, err := attachTimezoneInformation(router.repos, occurrence.Enter, location)
+	if err != nil {
+		return nil, err
+	}
+	leave, err := attachTimezoneInformation(router.repos, occurrence.Leave, location)
+	if err != nil {
+		return nil, err
+	}
+	booking := &Booking{
+		UserID:   e.UserID,
+		SpaceID:  e.SpaceID,
+		Enter:    enter,
+		Leave:    leave,
+		SeriesID: NullString(e.ID),
+	}
+	return booking, nil
+}
+
+func (router *BookingSeriesRouter) getSeriesAndOccurrence(w http.ResponseWriter, r *http.Request) (*BookingSeries, *BookingDetails, bool) {
+	vars := mux.Vars(r)
+	e, err := router.repos.BookingSeries.GetOne(vars["id"])
+	if err != nil {
+		SendNotFound(w)
+		return nil, nil, false
+	}
+	booking, err := router.repos.Booking.GetOne(vars["bookingId"])
+	if err != nil {
+		SendNotFound(w)
+		return nil, nil, false
+	}
+	if string(booking.SeriesID) != e.ID {
+		SendBadRequest(w)
+		return nil, nil, false
+	}
+	return e, booking, true
+}
+
+func (router *BookingSeriesRouter) getFollowingOccurrences(e *BookingSeries, booking *BookingDetails) ([]*BookingDetails, error) {
+	list, err := router.repos.Booking.GetAllBySeries(e.ID, booking.Enter)
+	if err != nil {
+		return nil, err
+	}
+	var res []*BookingDetails
+	for _, item := range list {
+		if !item.Enter.Before(booking.Enter) {
+			res = append(res, item)
+		}
+	}
+	return res, nil
+}
+
+func (router *BookingSeriesRouter) canDeleteOccurrences(list []*BookingDetails, requestUser *User, location *Location) bool {
+	bookingRouter := &BookingRouter{repos: router.repos}
+	for _, booking := range list {
+		if !bookingRouter.isValidBookingHoursBeforeDelete(booking, requestUser, location.OrganizationID) {
+			return false
+		}
+	}
+	return true
+}
+
+func (router *BookingSeriesRouter) deleteOccurrences(list []*BookingDetails) error {
+	for _, booking := range list {
+		if err := router.repos.Booking.Delete(booking); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (router *BookingSeriesRouter) restoreOccurrences(list []*BookingDetails) {
+	for _, booking := range list {
+		if err := router.repos.Booking.Create(&booking.Booking); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func (router *BookingSeriesRouter) restoreSeries(eOld, eNew *BookingSeries, old []*BookingDetails) {
+	if created, err := router.repos.Booking.GetAllBySeries(eNew.ID, time.Now().UTC()); err == nil {
+		router.deleteOccurrences(created)
+	}
+	if err := router.repos.BookingSeries.Update(eOld); err != nil {
+		log.Println(err)
+	}
+	router.restoreOccurrences(old)
+}
+
+func (router *BookingSeriesRouter) sendOccurrenceError(w http.ResponseWriter, code int, err error) {
+	log.Println(err)
+	if code == ResponseCodeBookingSlotConflict {
+		SendAlreadyExistsCode(w, code)
+		return
+	}
+	if code != 0 {
+		SendBadRequestCode(w, code)
+		return
+	}
+	if err == ErrBookingSeriesNoOccurrences {
+		SendBadRequest(w)
+		return
+	}
+	SendInternalServerError(w)
+}
+
+func (router *BookingSeriesRouter) canModify(e *BookingSeries, location *Location, requestUser *User) bool {
+	if !CanAccessOrg(requestUser, location.OrganizationID) {
+		return false
+	}
+	if e.UserID != requestUser.ID && !CanSpaceAdminOrg(requestUser, location.OrganizationID) {
+		return false
+	}
+	return true
+}
+
+func (router *BookingSeriesRouter) getLocation(spaceID string) (*Location, error) {
+	space, err := router.repos.Space.GetOne(spaceID)
+	if err != nil {
+		return nil, err
+	}
+	return router.repos.Location.GetOne(space.LocationID)
+}
+
+// getHorizon returns the local wall clock time up to which occurrences are created.
+// It is kept one day below the max days in advance setting so that timezone
+// offsets never make a materialized occurrence fail the advance check.
+func (router *BookingSeriesRouter) getHorizon(location *Location) time.Time {
+	maxAdvanceDays, _ := router.repos.Settings.GetInt(location.OrganizationID, SettingMaxDaysInAdvance.Name)
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return today.AddDate(0, 0, maxAdvanceDays-1)
+}
+
+func (router *BookingSeriesRouter) copyFromRestModel(m *CreateBookingSeriesRequest) *BookingSeries {
+	e := &BookingSeries{}
+	e.SpaceID = m.SpaceID
+	e.Enter = m.Enter.UTC()
+	e.Leave = m.Leave.UTC()
+	e.RRule = m.RRule
+	return e
+}
+
+func (router *BookingSeriesRouter) copyToRestModel(e *BookingSeries, bookings []*BookingDetails) *GetBookingSeriesResponse {
+	m := &GetBookingSeriesResponse{}
+	m.ID = e.ID
+	m.UserID = e.UserID
+	m.SpaceID = e.SpaceID
+	m.Enter = e.Enter
+	m.Leave = e.Leave
+	m.RRule = e.RRule
+	m.Bookings = []*GetBookingResponse{}
+	bookingRouter := &BookingRouter{repos: router.repos}
+	for _, booking := range bookings {
+		m.Bookings = append(m.Bookings, bookingRouter.copyToRestModel(booking))
+	}
+	return m
+}
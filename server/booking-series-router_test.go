@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestBookingSeriesCRUD(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	adminUser := createTestUserOrgAdmin(org)
+	loginResponse2 := loginTestUser(adminUser.ID)
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+
+	// Create location
+	payload := `{"name": "Location 1"}`
+	req := newHTTPRequest("POST", "/location/", loginResponse2.UserID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	locationID := res.Header().Get("X-Object-Id")
+
+	// Create space
+	payload = `{"name": "H234", "x": 50, "y": 100, "width": 200, "height": 300, "rotation": 90}`
+	req = newHTTPRequest("POST", "/location/"+locationID+"/space/", loginResponse2.UserID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	spaceID := res.Header().Get("X-Object-Id")
+
+	// Switch to non-admin user
+	user := createTestUserInOrg(org)
+	loginResponse := loginTestUser(user.ID)
+
+	// 1. Create
+	payload = "{\"spaceId\": \"" + spaceID + "\", \"enter\": \"2030-09-02T08:30:00Z\", \"leave\": \"2030-09-02T17:00:00Z\", \"rrule\": \"FREQ=WEEKLY;BYDAY=MO,WE;COUNT=4\"}"
+	req = newHTTPRequest("POST", "/booking/series/", loginResponse.UserID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	id := res.Header().Get("X-Object-Id")
+
+	// 2. Read
+	req = newHTTPRequest("GET", "/booking/series/"+id, loginResponse.UserID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody *GetBookingSeriesResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestString(t, spaceID, resBody.SpaceID)
+	checkTestInt(t, 4, len(resBody.Bookings))
+	checkTestString(t, "2030-09-02T08:30:00+02:00", resBody.Bookings[0].Enter.Format(JsDateTimeFormatWithTimezone))
+	checkTestString(t, "2030-09-04T08:30:00+02:00", resBody.Bookings[1].Enter.Format(JsDateTimeFormatWithTimezone))
+	checkTestString(t, "2030-09-11T17:00:00+02:00", resBody.Bookings[3].Leave.Format(JsDateTimeFormatWithTimezone))
+	checkTestString(t, id, resBody.Bookings[0].SeriesID)
+
+	// 3. Delete this and following
+	req = newHTTPRequest("DELETE", "/booking/series/"+id+"/following/"+resBody.Bookings[2].ID, loginResponse.UserID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	req = newHTTPRequest("GET", "/booking/series/"+id, loginResponse.UserID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody2 *GetBookingSeriesResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody2)
+	checkTestInt(t, 2, len(resBody2.Bookings))
+
+	// 4. Update whole series
+	payload = "{\"spaceId\": \"" + spaceID + "\", \"enter\": \"2030-09-02T09:00:00Z\", \"leave\": \"2030-09-02T12:00:00Z\", \"rrule\": \"FREQ=DAILY;COUNT=3\"}"
+	req = newHTTPRequest("PUT", "/booking/series/"+id, loginResponse.UserID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	req = newHTTPRequest("GET", "/booking/series/"+id, loginResponse.UserID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody3 *GetBookingSeriesResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody3)
+	checkTestInt(t, 3, len(resBody3.Bookings))
+	checkTestString(t, "2030-09-04T09:00:00+02:00", resBody3.Bookings[2].Enter.Format(JsDateTimeFormatWithTimezone))
+
+	// 5. Delete whole series
+	req = newHTTPRequest("DELETE", "/booking/series/"+id, loginResponse.UserID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	req = newHTTPRequest("GET", "/booking/series/"+id, loginResponse.UserID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNotFound, res.Code)
+
+	req = newHTTPRequest("GET", "/booking/", loginResponse.UserID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody4 []*GetBookingResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody4)
+	checkTestInt(t, 0, len(resBody4))
+}
+
+func TestBookingSeriesConflict(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	user := createTestUserInOrg(org)
+	loginResponse := loginTestUser(user.ID)
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+
+	l := &Location{
+		Name:           "Test",
+		OrganizationID: org.ID,
+	}
+	testRepositories.Location.Create(l)
+	s1 := &Space{Name: "Test 1", LocationID: l.ID}
+	testRepositories.Space.Create(s1)
+
+	// Create single booking blocking the second occurrence
+	payload := "{\"spaceId\": \"" + s1.ID + "\", \"enter\": \"2030-09-03T08:30:00Z\", \"leave\": \"2030-09-03T17:00:00Z\"}"
+	req := newHTTPRequest("POST", "/booking/", loginResponse.UserID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+
+	payload = "{\"spaceId\": \"" + s1.ID + "\", \"enter\": \"2030-09-02T08:30:00Z\", \"leave\": \"2030-09-02T17:00:00Z\", \"rrule\": \"FREQ=DAILY;COUNT=3\"}"
+	req = newHTTPRequest("POST", "/booking/series/", loginResponse.UserID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusConflict, res.Code)
+
+	// No occurrence must have been created
+	req = newHTTPRequest("GET", "/booking/", loginResponse.UserID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody []*GetBookingResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestInt(t, 1, len(resBody))
+}
+
+func TestBookingSeriesInvalidRRule(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	user := createTestUserInOrg(org)
+	loginResponse := loginTestUser(user.ID)
+
+	l := &Location{
+		Name:           "Test",
+		OrganizationID: org.ID,
+	}
+	testRepositories.Location.Create(l)
+	s1 := &Space{Name: "Test 1", LocationID: l.ID}
+	testRepositories.Space.Create(s1)
+
+	payload := "{\"spaceId\": \"" + s1.ID + "\", \"enter\": \"2030-09-02T08:30:00Z\", \"leave\": \"2030-09-02T17:00:00Z\", \"rrule\": \"FREQ=HOURLY;COUNT=3\"}"
+	req := newHTTPRequest("POST", "/booking/series/", loginResponse.UserID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+}
+
+func TestBookingSeriesUpdateOccurrence(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	user := createTestUserInOrg(org)
+	loginResponse := loginTestUser(user.ID)
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+
+	l := &Location{
+		Name:           "Test",
+		OrganizationID: org.ID,
+	}
+	testRepositories.Location.Create(l)
+	s1 := &Space{Name: "Test 1", LocationID: l.ID}
+	testRepositories.Space.Create(s1)
+
+	payload := "{\"spaceId\": \"" + s1.ID + "\", \"enter\": \"2030-09-02T08:30:00Z\", \"leave\": \"2030-09-02T17:00:00Z\", \"rrule\": \"FREQ=DAILY;COUNT=3\"}"
+	req := newHTTPRequest("POST", "/booking/series/", loginResponse.UserID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	id := res.Header().Get("X-Object-Id")
+
+	req = newHTTPRequest("GET", "/booking/series/"+id, loginResponse.UserID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody *GetBookingSeriesResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestInt(t, 3, len(resBody.Bookings))
+	bookingID := resBody.Bookings[1].ID
+
+	// Update a single occurrence
+	payload = "{\"spaceId\": \"" + s1.ID + "\", \"enter\": \"2030-09-03T10:00:00Z\", \"leave\": \"2030-09-03T15:00:00Z\"}"
+	req = newHTTPRequest("PUT", "/booking/"+bookingID, loginResponse.UserID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	req = newHTTPRequest("GET", "/booking/"+bookingID, loginResponse.UserID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody2 *GetBookingResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody2)
+	checkTestString(t, id, resBody2.SeriesID)
+	checkTestString(t, "2030-09-03T10:00:00+02:00", resBody2.Enter.Format(JsDateTimeFormatWithTimezone))
+
+	req = newHTTPRequest("GET", "/booking/series/"+id, loginResponse.UserID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody3 *GetBookingSeriesResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody3)
+	checkTestInt(t, 3, len(resBody3.Bookings))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+)
+
+type MemoryBuddyRepository struct {
+}
+
+func (r *MemoryBuddyRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryBuddyRepository) Create(e *Buddy) error {
+	if err := memoryCheckUUID(e.OwnerID, e.BuddyID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e.ID = memoryNewID()
+	row := *e
+	memoryData.buddies = append(memoryData.buddies, &row)
+	return nil
+}
+
+func (r *MemoryBuddyRepository) GetOne(id string) (*BuddyDetails, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	result := r.getAll(func(e *Buddy) bool {
+		return e.ID == id
+	})
+	if len(result) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return result[0], nil
+}
+
+func (r *MemoryBuddyRepository) GetAllByOwner(ownerID string) ([]*BuddyDetails, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	result := r.getAll(func(e *Buddy) bool {
+		return e.OwnerID == ownerID
+	})
+	memorySort(result, func(a, b *BuddyDetails) bool {
+		return strings.Compare(a.ID, b.ID) > 0
+	})
+	return result, nil
+}
+
+// getAll returns the matching buddies whose user exists.
+func (r *MemoryBuddyRepository) getAll(cond func(e *Buddy) bool) []*BuddyDetails {
+	var result []*BuddyDetails
+	for _, e := range memoryData.buddies {
+		if !cond(e) {
+			continue
+		}
+		user := memoryUserByID(e.BuddyID)
+		if user == nil {
+			continue
+		}
+		result = append(result, &BuddyDetails{
+			BuddyEmail: user.Email,
+			Buddy:      *e,
+		})
+	}
+	return result
+}
+
+func (r *MemoryBuddyRepository) Delete(e *BuddyDetails) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.buddies = memoryDelete(memoryData.buddies, func(row *Buddy) bool {
+		return row.ID == e.ID
+	})
+	return nil
+}
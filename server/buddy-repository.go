@@ -1,10 +1,14 @@
 package main
 
-import (
-	"sync"
-)
+type BuddyRepository interface {
+	Repository
+	Create(e *Buddy) error
+	GetOne(id string) (*BuddyDetails, error)
+	GetAllByOwner(ownerID string) ([]*BuddyDetails, error)
+	Delete(e *BuddyDetails) error
+}
 
-type BuddyRepository struct {
+type PostgresBuddyRepository struct {
 }
 
 type Buddy struct {
@@ -18,33 +22,20 @@ type BuddyDetails struct {
 	Buddy
 }
 
-var buddyRepository *BuddyRepository
-var buddyRepositoryOnce sync.Once
-
-func GetBuddyRepository() *BuddyRepository {
-	buddyRepositoryOnce.Do(func() {
-		buddyRepository = &BuddyRepository{}
-		_, err := GetDatabase().DB().Exec("CREATE TABLE IF NOT EXISTS buddies (" +
-			"id uuid DEFAULT uuid_generate_v4(), " +
-			"owner_id uuid NOT NULL, " +
-			"buddy_id uuid NOT NULL, " +
-			"PRIMARY KEY (id))")
-		if err != nil {
-			panic(err)
-		}
-		_, err = GetDatabase().DB().Exec("CREATE INDEX IF NOT EXISTS idx_buddies_owner_id ON buddies(owner_id)")
-		if err != nil {
-			panic(err)
-		}
-	})
-	return buddyRepository
-}
-
-func (r *BuddyRepository) RunSchemaUpgrade(curVersion, targetVersion int) {
-	// No updates yet
+func (r *PostgresBuddyRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		newInitialSchemaMigration(
+			"CREATE TABLE IF NOT EXISTS buddies ("+
+				"id uuid DEFAULT uuid_generate_v4(), "+
+				"owner_id uuid NOT NULL, "+
+				"buddy_id uuid NOT NULL, "+
+				"PRIMARY KEY (id))",
+			"CREATE INDEX IF NOT EXISTS idx_buddies_owner_id ON buddies(owner_id)",
+		),
+	}
 }
 
-func (r *BuddyRepository) Create(e *Buddy) error {
+func (r *PostgresBuddyRepository) Create(e *Buddy) error {
 	var id string
 	err := GetDatabase().DB().QueryRow("INSERT INTO buddies "+
 		"(owner_id, buddy_id) "+
@@ -58,7 +49,7 @@ func (r *BuddyRepository) Create(e *Buddy) error {
 	return nil
 }
 
-func (r *BuddyRepository) GetOne(id string) (*BuddyDetails, error) {
+func (r *PostgresBuddyRepository) GetOne(id string) (*BuddyDetails, error) {
 	e := &BuddyDetails{}
 	err := GetDatabase().DB().QueryRow("SELECT buddies.id, buddies.owner_id, buddies.buddy_id, "+
 		"users.email "+
@@ -72,7 +63,7 @@ func (r *BuddyRepository) GetOne(id string) (*BuddyDetails, error) {
 	return e, nil
 }
 
-func (r *BuddyRepository) GetAllByOwner(ownerID string) ([]*BuddyDetails, error) {
+func (r *PostgresBuddyRepository) GetAllByOwner(ownerID string) ([]*BuddyDetails, error) {
 	var result []*BuddyDetails
 	rows, err := GetDatabase().DB().Query("SELECT buddies.id, buddies.owner_id, buddies.buddy_id, "+
 		"users.email "+
@@ -95,7 +86,7 @@ func (r *BuddyRepository) GetAllByOwner(ownerID string) ([]*BuddyDetails, error)
 	return result, nil
 }
 
-func (r *BuddyRepository) Delete(e *BuddyDetails) error {
+func (r *PostgresBuddyRepository) Delete(e *BuddyDetails) error {
 	_, err := GetDatabase().DB().Exec("DELETE FROM buddies WHERE id = $1", e.ID)
 	return err
 }
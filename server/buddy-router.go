@@ -9,6 +9,7 @@ import (
 )
 
 type BuddyRouter struct {
+	repos *Repositories
 }
 
 type BuddyBooking struct {
@@ -40,7 +41,7 @@ func (router *BuddyRouter) setupRoutes(s *mux.Router) {
 }
 
 func (router *BuddyRouter) getAll(w http.ResponseWriter, r *http.Request) {
-	list, err := GetBuddyRepository().GetAllByOwner(GetRequestUserID(r))
+	list, err := router.repos.Buddy.GetAllByOwner(GetRequestUserID(r))
 	if err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
@@ -56,7 +57,7 @@ func (router *BuddyRouter) getAll(w http.ResponseWriter, r *http.Request) {
 
 func (router *BuddyRouter) delete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	e, err := GetBuddyRepository().GetOne(vars["id"])
+	e, err := router.repos.Buddy.GetOne(vars["id"])
 	if err != nil {
 		SendNotFound(w)
 		return
@@ -64,7 +65,7 @@ func (router *BuddyRouter) delete(w http.ResponseWriter, r *http.Request) {
 	if e.OwnerID != GetRequestUserID(r) {
 		SendForbidden(w)
 	}
-	if err := GetBuddyRepository().Delete(e); err != nil {
+	if err := router.repos.Buddy.Delete(e); err != nil {
 		SendInternalServerError(w)
 		return
 	}
@@ -78,7 +79,7 @@ func (router *BuddyRouter) create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	buddyUser, err := GetUserRepository().GetOne(m.BuddyID)
+	buddyUser, err := router.repos.User.GetOne(m.BuddyID)
 	if err != nil {
 		SendBadRequest(w)
 		return
@@ -87,7 +88,7 @@ func (router *BuddyRouter) create(w http.ResponseWriter, r *http.Request) {
 	e := &Buddy{}
 	e.BuddyID = buddyUser.ID
 	e.OwnerID = GetRequestUserID(r)
-	if err := GetBuddyRepository().Create(e); err != nil {
+	if err := router.repos.Buddy.Create(e); err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
@@ -101,7 +102,7 @@ func (router *BuddyRouter) copyToRestModel(e *BuddyDetails) *GetBuddyResponse {
 	m.BuddyID = e.BuddyID
 	m.BuddyEmail = e.BuddyEmail
 	// Assuming GetOne returns a pointer to BookingDetails
-	bookingDetails, _ := GetBookingRepository().GetFirstUpcomingBookingByUserID(e.BuddyID)
+	bookingDetails, _ := router.repos.Booking.GetFirstUpcomingBookingByUserID(e.BuddyID)
 	if bookingDetails == nil {
 		m.BuddyFirstBooking = nil
 		return m
@@ -26,7 +26,7 @@ func TestBuddiesEmptyResult(t *testing.T) {
 func TestBuddiesCRUD(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 
 	// Create buddy users
 	buddyUser1 := createTestUserInOrg(org)
@@ -73,7 +73,7 @@ func TestBuddiesCRUD(t *testing.T) {
 func TestDeleteBuddyOfAnotherUser(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 
 	// Create buddy users
 	buddyUser1 := createTestUserInOrg(org)
@@ -101,8 +101,8 @@ func TestBuddiesCreateWithMissingUser(t *testing.T) {
 	org := createTestOrg("test.com")
 	user2 := createTestUserOrgAdmin(org)
 	loginResponse2 := loginTestUser(user2.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
-	GetSettingsRepository().Set(org.ID, SettingAllowBookingsNonExistingUsers.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingAllowBookingsNonExistingUsers.Name, "1")
 
 	// Create
 	payload := "{\"buddyId\": \"" + uuid.New().String() + "\"}"
@@ -114,7 +114,7 @@ func TestBuddiesCreateWithMissingUser(t *testing.T) {
 func TestBuddiesList(t *testing.T) {
 	clearTestDB()
 	org := createTestOrg("test.com")
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 
 	// Create buddy users
 	buddyUser1 := createTestUserInOrg(org)
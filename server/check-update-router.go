@@ -7,6 +7,7 @@ import (
 )
 
 type CheckUpdateRouter struct {
+	repos *Repositories
 }
 
 func (router *CheckUpdateRouter) setupRoutes(s *mux.Router) {
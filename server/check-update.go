@@ -24,6 +24,7 @@ type CheckVersionResponse struct {
 
 type UpdateChecker struct {
 	Latest *CheckVersionResponse
+	repos  *Repositories
 }
 
 var updateChecker *UpdateChecker
@@ -38,7 +39,7 @@ func GetUpdateChecker() *UpdateChecker {
 
 func (uc *UpdateChecker) pollLatestRelease() (*CheckVersionResponse, error) {
 	const url = "https://uc.seatsurfing.app/"
-	installID, _ := GetSettingsRepository().GetGlobalString(SettingInstallID.Name)
+	installID, _ := uc.repos.Settings.GetGlobalString(SettingInstallID.Name)
 	payload := CheckVersionRequest{
 		InstallID:      installID,
 		CurrentVersion: GetProductVersion(),
@@ -86,7 +87,8 @@ func (uc *UpdateChecker) onVersionUpdateTimerTick() error {
 	return nil
 }
 
-func (uc *UpdateChecker) InitializeVersionUpdateTimer() {
+func (uc *UpdateChecker) InitializeVersionUpdateTimer(repos *Repositories) {
+	uc.repos = repos
 	if err := uc.onVersionUpdateTimerTick(); err != nil {
 		return
 	}
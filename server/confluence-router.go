@@ -19,6 +19,7 @@ type ConfluenceServerClaims struct {
 }
 
 type ConfluenceRouter struct {
+	repos *Repositories
 }
 
 func (router *ConfluenceRouter) setupRoutes(s *mux.Router) {
@@ -27,12 +28,12 @@ func (router *ConfluenceRouter) setupRoutes(s *mux.Router) {
 
 func (router *ConfluenceRouter) serverLogin(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	org, err := GetOrganizationRepository().GetOne(vars["orgID"])
+	org, err := router.repos.Organization.GetOne(vars["orgID"])
 	if err != nil || org == nil {
 		SendTextNotFound(w, "text/plain", router.getOrgNotFoundBody())
 		return
 	}
-	sharedSecret, err := GetSettingsRepository().Get(org.ID, SettingConfluenceServerSharedSecret.Name)
+	sharedSecret, err := router.repos.Settings.Get(org.ID, SettingConfluenceServerSharedSecret.Name)
 	if err != nil || sharedSecret == "" {
 		SendBadRequest(w)
 		return
@@ -54,25 +55,25 @@ func (router *ConfluenceRouter) serverLogin(w http.ResponseWriter, r *http.Reque
 		SendTemporaryRedirect(w, GetConfig().FrontendURL+"ui/login/failed")
 		return
 	}
-	allowAnonymous, _ := GetSettingsRepository().GetBool(org.ID, SettingConfluenceAnonymous.Name)
+	allowAnonymous, _ := router.repos.Settings.GetBool(org.ID, SettingConfluenceAnonymous.Name)
 	userID := router.getUserEmailServer(org, claims, allowAnonymous)
 	if userID == "" {
 		SendTemporaryRedirect(w, GetConfig().FrontendURL+"ui/login/confluence/anonymous")
 		return
 	}
-	_, err = GetUserRepository().GetByAtlassianID(userID)
+	_, err = router.repos.User.GetByAtlassianID(userID)
 	if err != nil {
 		// user not found using atlassianID, try by mail
-		u, err := GetUserRepository().GetByEmail(userID)
+		u, err := router.repos.User.GetByEmail(userID)
 		if err == nil {
 			// got it, update it now
-			GetUserRepository().UpdateAtlassianClientIDForUser(u.OrganizationID, u.ID, userID)
+			router.repos.User.UpdateAtlassianClientIDForUser(u.OrganizationID, u.ID, userID)
 		}
 		// and load again
-		GetUserRepository().GetByAtlassianID(userID)
+		router.repos.User.GetByAtlassianID(userID)
 	}
 	if err != nil {
-		if !GetUserRepository().canCreateUser(org) {
+		if !router.repos.User.canCreateUser(org) {
 			SendTemporaryRedirect(w, GetConfig().FrontendURL+"ui/login/failed")
 			return
 		}
@@ -82,7 +83,7 @@ func (router *ConfluenceRouter) serverLogin(w http.ResponseWriter, r *http.Reque
 			OrganizationID: org.ID,
 			Role:           UserRoleUser,
 		}
-		GetUserRepository().Create(user)
+		router.repos.User.Create(user)
 	}
 	payload := &AuthStateLoginPayload{
 		LoginType: "",
@@ -90,12 +91,12 @@ func (router *ConfluenceRouter) serverLogin(w http.ResponseWriter, r *http.Reque
 		LongLived: false,
 	}
 	authState := &AuthState{
-		AuthProviderID: GetSettingsRepository().getNullUUID(),
+		AuthProviderID: router.repos.Settings.getNullUUID(),
 		Expiry:         time.Now().Add(time.Minute * 5),
 		AuthStateType:  AuthAtlassian,
 		Payload:        marshalAuthStateLoginPayload(payload),
 	}
-	if err := GetAuthStateRepository().Create(authState); err != nil {
+	if err := router.repos.AuthState.Create(authState); err != nil {
 		SendInternalServerError(w)
 		return
 	}
@@ -137,7 +138,7 @@ func (router *ConfluenceRouter) getUserEmailServer(org *Organization, claims *Co
 			userAccountID = "confluence-anonymous-" + uuid.New().String()
 		}
 	}
-	domains, err := GetOrganizationRepository().GetDomains(org)
+	domains, err := router.repos.Organization.GetDomains(org)
 	if err != nil {
 		return ""
 	}
@@ -61,11 +61,15 @@ func (s *NullString) Scan(value interface{}) error {
 		*s = ""
 		return nil
 	}
-	strVal, ok := value.(string)
-	if !ok {
+	switch v := value.(type) {
+	case string:
+		*s = NullString(v)
+	case []byte:
+		// uuid columns are returned as raw bytes by the driver
+		*s = NullString(string(v))
+	default:
 		return errors.New("column is not a string")
 	}
-	*s = NullString(strVal)
 	return nil
 }
 
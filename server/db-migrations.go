@@ -0,0 +1,325 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Migration changes the database schema to Version. The Up statements are executed in
+// the order listed, the Down statements revert them. A migration without Down
+// statements can't be reverted.
+type Migration struct {
+	Version     int
+	Description string
+	Up          []string
+	Down        []string
+}
+
+// InitialSchemaVersion is the version of the tables created by the first releases of
+// the server, which did not record the changes of the schema yet.
+const InitialSchemaVersion int = 0
+
+// newInitialSchemaMigration returns a migration creating tables of the initial schema.
+// It can't be reverted, since all later migrations depend on it.
+func newInitialSchemaMigration(up ...string) *Migration {
+	return &Migration{
+		Version:     InitialSchemaVersion,
+		Description: "Create initial schema",
+		Up:          up,
+	}
+}
+
+// MigrationStatus is a migration with the time it has been applied at, which is nil
+// if it is pending.
+type MigrationStatus struct {
+	*Migration
+	Applied *time.Time
+}
+
+// SchemaMigrationsTable records the versions of the applied migrations.
+const SchemaMigrationsTable string = "schema_migrations"
+
+// schemaMigrationsLockKey identifies the advisory lock which prevents multiple
+// instances of the server from migrating the database at the same time.
+const schemaMigrationsLockKey int64 = 0x5ea75c4e3a
+
+// Migrator applies the migrations of all repositories. The migrations of the
+// repositories with the same version are merged and run in a single transaction.
+type Migrator struct {
+	db         *sql.DB
+	migrations []*Migration
+	settings   SettingsRepository
+}
+
+// GetMigrator returns a migrator for the database with the migrations of the
+// repositories.
+func GetMigrator(repos *Repositories) (*Migrator, error) {
+	migrations, err := mergeMigrations(repos.all())
+	if err != nil {
+		return nil, err
+	}
+	return newMigrator(GetDatabase().DB(), migrations, repos.Settings)
+}
+
+// newMigrator returns a migrator applying the migrations to the database. The settings
+// store the version of the schema for older versions of the server.
+func newMigrator(db *sql.DB, migrations []*Migration, settings SettingsRepository) (*Migrator, error) {
+	m := &Migrator{
+		db:         db,
+		migrations: migrations,
+		settings:   settings,
+	}
+	if err := m.init(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// mergeMigrations returns the migrations of the repositories ordered by version. The
+// migrations of the same version are merged in the order of the repositories. The
+// merged migration can only be reverted if all of them can be reverted.
+func mergeMigrations(repositories []Repository) ([]*Migration, error) {
+	var result []*Migration
+	versions := make(map[int]*Migration)
+	irreversible := make(map[int]bool)
+	for _, repository := range repositories {
+		for _, e := range repository.GetMigrations() {
+			if e.Version < InitialSchemaVersion || len(e.Up) == 0 {
+				return nil, errors.New("invalid migration " + strconv.Itoa(e.Version) + ": " + e.Description)
+			}
+			m, ok := versions[e.Version]
+			if !ok {
+				m = &Migration{Version: e.Version, Description: e.Description}
+				versions[e.Version] = m
+				result = append(result, m)
+			} else if m.Description != e.Description {
+				m.Description += "; " + e.Description
+			}
+			m.Up = append(m.Up, e.Up...)
+			// Later changes of the version are reverted first
+			m.Down = append(append([]string{}, e.Down...), m.Down...)
+			if len(e.Down) == 0 {
+				irreversible[e.Version] = true
+			}
+		}
+	}
+	for _, m := range result {
+		if irreversible[m.Version] {
+			m.Down = nil
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+	return result, nil
+}
+
+// init creates SchemaMigrationsTable. Databases created before the migrations have
+// been recorded only store their version in SettingDatabaseVersion, so all migrations
+// up to that version, including the initial schema, are recorded as applied.
+func (m *Migrator) init() error {
+	if _, err := m.db.Exec("CREATE TABLE IF NOT EXISTS " + SchemaMigrationsTable + " (" +
+		"version INTEGER NOT NULL, " +
+		"description VARCHAR NOT NULL, " +
+		"applied TIMESTAMP NOT NULL, " +
+		"PRIMARY KEY (version))"); err != nil {
+		return err
+	}
+	return m.inTransaction(func(tx *sql.Tx) error {
+		var numApplied int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM " + SchemaMigrationsTable).Scan(&numApplied); err != nil {
+			return err
+		}
+		if numApplied > 0 {
+			return nil
+		}
+		legacyVersion, err := m.settings.GetGlobalInt(SettingDatabaseVersion.Name)
+		if err != nil {
+			return nil
+		}
+		for _, e := range m.migrations {
+			if e.Version > legacyVersion {
+				break
+			}
+			if _, err := tx.Exec("INSERT INTO "+SchemaMigrationsTable+" (version, description, applied) VALUES ($1, $2, $3)",
+				e.Version, e.Description, time.Now().UTC()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetLatestVersion returns the version of the last migration.
+func (m *Migrator) GetLatestVersion() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// GetStatus returns all migrations ordered by version.
+func (m *Migrator) GetStatus() ([]*MigrationStatus, error) {
+	applied, err := m.getApplied()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*MigrationStatus, len(m.migrations))
+	for i, e := range m.migrations {
+		result[i] = &MigrationStatus{Migration: e}
+		if t, ok := applied[e.Version]; ok {
+			result[i].Applied = &t
+		}
+	}
+	return result, nil
+}
+
+// GetPending returns the migrations up to the version which have not been applied yet.
+func (m *Migrator) GetPending(version int) ([]*Migration, error) {
+	applied, err := m.getApplied()
+	if err != nil {
+		return nil, err
+	}
+	var result []*Migration
+	for _, e := range m.migrations {
+		if _, ok := applied[e.Version]; !ok && e.Version <= version {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+// Up applies the pending migrations up to the version and returns them. If a migration
+// fails, its transaction is rolled back and the following ones are not applied.
+func (m *Migrator) Up(version int) ([]*Migration, error) {
+	pending, err := m.GetPending(version)
+	if err != nil {
+		return nil, err
+	}
+	var result []*Migration
+	for _, e := range pending {
+		log.Printf("Applying migration %d: %s\n", e.Version, e.Description)
+		err := m.inTransaction(func(tx *sql.Tx) error {
+			// Another instance might have applied the migration in the meantime
+			if applied, err := m.isApplied(tx, e.Version); err != nil || applied {
+				return err
+			}
+			if err := m.exec(tx, e.Up); err != nil {
+				return err
+			}
+			_, err := tx.Exec("INSERT INTO "+SchemaMigrationsTable+" (version, description, applied) VALUES ($1, $2, $3)",
+				e.Version, e.Description, time.Now().UTC())
+			return err
+		})
+		if err != nil {
+			return result, fmt.Errorf("migration %d failed: %w", e.Version, err)
+		}
+		result = append(result, e)
+	}
+	return result, m.updateDatabaseVersion()
+}
+
+// Down reverts the applied migrations above the version, starting with the latest
+// one, and returns them. Nothing is reverted if one of them can't be reverted.
+func (m *Migrator) Down(version int) ([]*Migration, error) {
+	applied, err := m.getApplied()
+	if err != nil {
+		return nil, err
+	}
+	var revert []*Migration
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		e := m.migrations[i]
+		if _, ok := applied[e.Version]; !ok || e.Version <= version {
+			continue
+		}
+		if len(e.Down) == 0 {
+			return nil, errors.New("migration " + strconv.Itoa(e.Version) + " can't be reverted: " + e.Description)
+		}
+		revert = append(revert, e)
+	}
+	var result []*Migration
+	for _, e := range revert {
+		log.Printf("Reverting migration %d: %s\n", e.Version, e.Description)
+		err := m.inTransaction(func(tx *sql.Tx) error {
+			if applied, err := m.isApplied(tx, e.Version); err != nil || !applied {
+				return err
+			}
+			if err := m.exec(tx, e.Down); err != nil {
+				return err
+			}
+			_, err := tx.Exec("DELETE FROM "+SchemaMigrationsTable+" WHERE version = $1", e.Version)
+			return err
+		})
+		if err != nil {
+			return result, fmt.Errorf("reverting migration %d failed: %w", e.Version, err)
+		}
+		result = append(result, e)
+	}
+	return result, m.updateDatabaseVersion()
+}
+
+func (m *Migrator) getApplied() (map[int]time.Time, error) {
+	rows, err := m.db.Query("SELECT version, applied FROM " + SchemaMigrationsTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var applied time.Time
+		if err := rows.Scan(&version, &applied); err != nil {
+			return nil, err
+		}
+		result[version] = applied
+	}
+	return result, rows.Err()
+}
+
+func (m *Migrator) isApplied(tx *sql.Tx, version int) (bool, error) {
+	var res bool
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM "+SchemaMigrationsTable+" WHERE version = $1)", version).Scan(&res)
+	return res, err
+}
+
+func (m *Migrator) exec(tx *sql.Tx, statements []string) error {
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("%w: %s", err, stmt)
+		}
+	}
+	return nil
+}
+
+// inTransaction runs f in a transaction holding the migration lock. The transaction is
+// rolled back if f returns an error.
+func (m *Migrator) inTransaction(f func(tx *sql.Tx) error) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", schemaMigrationsLockKey); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// updateDatabaseVersion sets SettingDatabaseVersion to the latest applied migration for
+// older versions of the server.
+func (m *Migrator) updateDatabaseVersion() error {
+	var version int
+	if err := m.db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM " + SchemaMigrationsTable).Scan(&version); err != nil {
+		return err
+	}
+	return m.settings.SetGlobal(SettingDatabaseVersion.Name, strconv.Itoa(version))
+}
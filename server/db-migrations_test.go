@@ -0,0 +1,436 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testMigrationRepository []*Migration
+
+func (r testMigrationRepository) GetMigrations() []*Migration {
+	return r
+}
+
+func TestMergeMigrations(t *testing.T) {
+	repositories := []Repository{
+		testMigrationRepository{
+			{Version: 2, Description: "A2", Up: []string{"UP A2"}, Down: []string{"DOWN A2"}},
+			{Version: 5, Description: "A5", Up: []string{"UP A5"}, Down: []string{"DOWN A5"}},
+		},
+		testMigrationRepository{
+			{Version: 1, Description: "B1", Up: []string{"UP B1"}, Down: []string{"DOWN B1"}},
+			{Version: 2, Description: "B2", Up: []string{"UP B2 1", "UP B2 2"}, Down: []string{"DOWN B2 2", "DOWN B2 1"}},
+		},
+	}
+	res, err := mergeMigrations(repositories)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestInt(t, 3, len(res))
+	checkTestInt(t, 1, res[0].Version)
+	checkTestInt(t, 2, res[1].Version)
+	checkTestInt(t, 5, res[2].Version)
+	checkTestString(t, "A2; B2", res[1].Description)
+	checkTestString(t, "UP A2|UP B2 1|UP B2 2", strings.Join(res[1].Up, "|"))
+	checkTestString(t, "DOWN B2 2|DOWN B2 1|DOWN A2", strings.Join(res[1].Down, "|"))
+}
+
+func TestMergeMigrationsIrreversible(t *testing.T) {
+	repositories := []Repository{
+		testMigrationRepository{
+			{Version: 3, Description: "A3", Up: []string{"UP A3"}, Down: []string{"DOWN A3"}},
+		},
+		testMigrationRepository{
+			{Version: 3, Description: "B3", Up: []string{"UP B3"}},
+			{Version: 4, Description: "B4", Up: []string{"UP B4"}, Down: []string{"DOWN B4"}},
+		},
+	}
+	res, err := mergeMigrations(repositories)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestInt(t, 2, len(res))
+	checkTestInt(t, 0, len(res[0].Down))
+	checkTestInt(t, 1, len(res[1].Down))
+}
+
+func TestMergeMigrationsInvalid(t *testing.T) {
+	invalid := []*Migration{
+		{Version: -1, Description: "Negative version", Up: []string{"UP"}},
+		{Version: 1, Description: "No statements"},
+	}
+	for _, e := range invalid {
+		if _, err := mergeMigrations([]Repository{testMigrationRepository{e}}); err == nil {
+			t.Fatal("Expected error for migration: " + e.Description)
+		}
+	}
+}
+
+func TestMergeMigrationsInitialSchema(t *testing.T) {
+	repositories := []Repository{
+		testMigrationRepository{newInitialSchemaMigration("UP A0")},
+		testMigrationRepository{newInitialSchemaMigration("UP B0")},
+	}
+	res, err := mergeMigrations(repositories)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestInt(t, 1, len(res))
+	checkTestInt(t, InitialSchemaVersion, res[0].Version)
+	checkTestString(t, "Create initial schema", res[0].Description)
+	checkTestString(t, "UP A0|UP B0", strings.Join(res[0].Up, "|"))
+	checkTestInt(t, 0, len(res[0].Down))
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	restoreTestDatabaseVersion(t)
+	db := newTestMigrationDatabase()
+	m := newTestMigrator(t, db, []*Migration{
+		newInitialSchemaMigration("CREATE TABLE a"),
+		{Version: 1, Description: "B", Up: []string{"CREATE TABLE b"}, Down: []string{"DROP TABLE b"}},
+		{Version: 2, Description: "C", Up: []string{"CREATE TABLE c"}, Down: []string{"DROP TABLE c"}},
+	})
+	checkTestInt(t, 2, m.GetLatestVersion())
+
+	res, err := m.Up(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestInt(t, 2, len(res))
+	checkTestString(t, "a|b", db.getTables())
+	checkTestString(t, "0|1", db.getApplied())
+	checkTestInt(t, 1, getTestDatabaseVersion(t))
+
+	res, err = m.Up(m.GetLatestVersion())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestInt(t, 1, len(res))
+	checkTestInt(t, 2, res[0].Version)
+	checkTestString(t, "a|b|c", db.getTables())
+	checkTestString(t, "0|1|2", db.getApplied())
+	checkTestInt(t, 2, getTestDatabaseVersion(t))
+
+	res, err = m.Down(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestInt(t, 2, len(res))
+	checkTestInt(t, 2, res[0].Version)
+	checkTestInt(t, 1, res[1].Version)
+	checkTestString(t, "a", db.getTables())
+	checkTestString(t, "0", db.getApplied())
+	checkTestInt(t, 0, getTestDatabaseVersion(t))
+
+	// The initial schema can't be reverted
+	if _, err := m.Down(-1); err == nil {
+		t.Fatal("Expected error when reverting the initial schema")
+	}
+	checkTestString(t, "a", db.getTables())
+	checkTestString(t, "0", db.getApplied())
+}
+
+func TestMigratorLegacyVersion(t *testing.T) {
+	restoreTestDatabaseVersion(t)
+	if err := testRepositories.Settings.SetGlobal(SettingDatabaseVersion.Name, "1"); err != nil {
+		t.Fatal(err)
+	}
+	db := newTestMigrationDatabase()
+	m := newTestMigrator(t, db, []*Migration{
+		newInitialSchemaMigration("CREATE TABLE a"),
+		{Version: 1, Description: "B", Up: []string{"CREATE TABLE b"}, Down: []string{"DROP TABLE b"}},
+		{Version: 2, Description: "C", Up: []string{"CREATE TABLE c"}, Down: []string{"DROP TABLE c"}},
+	})
+	checkTestString(t, "0|1", db.getApplied())
+	pending, err := m.GetPending(m.GetLatestVersion())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestInt(t, 1, len(pending))
+	checkTestInt(t, 2, pending[0].Version)
+
+	if _, err := m.Up(m.GetLatestVersion()); err != nil {
+		t.Fatal(err)
+	}
+	// The statements of the adopted migrations are not executed again
+	checkTestString(t, "c", db.getTables())
+	checkTestString(t, "0|1|2", db.getApplied())
+	checkTestInt(t, 2, getTestDatabaseVersion(t))
+
+	// The legacy version is only adopted once
+	m = newTestMigrator(t, db, m.migrations)
+	checkTestString(t, "0|1|2", db.getApplied())
+}
+
+func TestMigratorUpRollback(t *testing.T) {
+	restoreTestDatabaseVersion(t)
+	db := newTestMigrationDatabase()
+	m := newTestMigrator(t, db, []*Migration{
+		{Version: 1, Description: "A", Up: []string{"CREATE TABLE a"}, Down: []string{"DROP TABLE a"}},
+		{Version: 2, Description: "B", Up: []string{"CREATE TABLE b", "FAIL"}, Down: []string{"DROP TABLE b"}},
+		{Version: 3, Description: "C", Up: []string{"CREATE TABLE c"}, Down: []string{"DROP TABLE c"}},
+	})
+	res, err := m.Up(m.GetLatestVersion())
+	if err == nil {
+		t.Fatal("Expected error for failing migration")
+	}
+	checkTestInt(t, 1, len(res))
+	checkTestInt(t, 1, res[0].Version)
+	checkTestString(t, "a", db.getTables())
+	checkTestString(t, "1", db.getApplied())
+	pending, err := m.GetPending(m.GetLatestVersion())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestInt(t, 2, len(pending))
+}
+
+func TestMigratorDownRollback(t *testing.T) {
+	restoreTestDatabaseVersion(t)
+	db := newTestMigrationDatabase()
+	m := newTestMigrator(t, db, []*Migration{
+		{Version: 1, Description: "A", Up: []string{"CREATE TABLE a"}, Down: []string{"DROP TABLE a"}},
+		{Version: 2, Description: "B", Up: []string{"CREATE TABLE b"}, Down: []string{"DROP TABLE b", "FAIL"}},
+	})
+	if _, err := m.Up(m.GetLatestVersion()); err != nil {
+		t.Fatal(err)
+	}
+	res, err := m.Down(0)
+	if err == nil {
+		t.Fatal("Expected error for failing migration")
+	}
+	checkTestInt(t, 0, len(res))
+	checkTestString(t, "a|b", db.getTables())
+	checkTestString(t, "1|2", db.getApplied())
+}
+
+func newTestMigrator(t *testing.T, db *testMigrationDatabase, migrations []*Migration) *Migrator {
+	m, err := newMigrator(sql.OpenDB(db), migrations, testRepositories.Settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// restoreTestDatabaseVersion clears SettingDatabaseVersion for the test and restores it
+// afterwards, as the migrator tests share the settings with the other tests.
+func restoreTestDatabaseVersion(t *testing.T) {
+	prev, _ := testRepositories.Settings.GetGlobalString(SettingDatabaseVersion.Name)
+	testRepositories.Settings.SetGlobal(SettingDatabaseVersion.Name, "")
+	t.Cleanup(func() {
+		testRepositories.Settings.SetGlobal(SettingDatabaseVersion.Name, prev)
+	})
+}
+
+func getTestDatabaseVersion(t *testing.T) int {
+	res, err := testRepositories.Settings.GetGlobalInt(SettingDatabaseVersion.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+// testMigrationDatabase is a database/sql driver understanding the statements of the
+// migrator. The statements of the test migrations are "CREATE TABLE <name>",
+// "DROP TABLE <name>" and "FAIL", which returns an error. Transactions work on a copy
+// of the state, which replaces it on commit.
+type testMigrationDatabase struct {
+	mu    sync.Mutex
+	state *testMigrationState
+}
+
+type testMigrationState struct {
+	applied map[int]time.Time
+	tables  map[string]bool
+}
+
+type testMigrationConn struct {
+	db *testMigrationDatabase
+	tx *testMigrationState
+}
+
+type testMigrationRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func newTestMigrationDatabase() *testMigrationDatabase {
+	return &testMigrationDatabase{
+		state: &testMigrationState{
+			applied: make(map[int]time.Time),
+			tables:  make(map[string]bool),
+		},
+	}
+}
+
+func (d *testMigrationDatabase) getTables() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var res []string
+	for name := range d.state.tables {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return strings.Join(res, "|")
+}
+
+func (d *testMigrationDatabase) getApplied() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var versions []int
+	for version := range d.state.applied {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	res := make([]string, len(versions))
+	for i, version := range versions {
+		res[i] = strconv.Itoa(version)
+	}
+	return strings.Join(res, "|")
+}
+
+func (d *testMigrationDatabase) Connect(ctx context.Context) (driver.Conn, error) {
+	return &testMigrationConn{db: d}, nil
+}
+
+func (d *testMigrationDatabase) Driver() driver.Driver {
+	return d
+}
+
+func (d *testMigrationDatabase) Open(name string) (driver.Conn, error) {
+	return d.Connect(context.Background())
+}
+
+func (s *testMigrationState) copy() *testMigrationState {
+	res := &testMigrationState{
+		applied: make(map[int]time.Time),
+		tables:  make(map[string]bool),
+	}
+	for k, v := range s.applied {
+		res.applied[k] = v
+	}
+	for k, v := range s.tables {
+		res.tables[k] = v
+	}
+	return res
+}
+
+func (c *testMigrationConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *testMigrationConn) Close() error {
+	return nil
+}
+
+func (c *testMigrationConn) Begin() (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.tx = c.db.state.copy()
+	return c, nil
+}
+
+func (c *testMigrationConn) Commit() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.state = c.tx
+	c.tx = nil
+	return nil
+}
+
+func (c *testMigrationConn) Rollback() error {
+	c.tx = nil
+	return nil
+}
+
+// getState returns the state of the transaction or, outside of it, the committed one.
+// The caller must hold the lock.
+func (c *testMigrationConn) getState() *testMigrationState {
+	if c.tx != nil {
+		return c.tx
+	}
+	return c.db.state
+}
+
+func (c *testMigrationConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	state := c.getState()
+	switch {
+	case strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS "+SchemaMigrationsTable+" "),
+		strings.HasPrefix(query, "SELECT pg_advisory_xact_lock("):
+	case strings.HasPrefix(query, "INSERT INTO "+SchemaMigrationsTable+" "):
+		state.applied[int(args[0].Value.(int64))] = args[2].Value.(time.Time)
+	case strings.HasPrefix(query, "DELETE FROM "+SchemaMigrationsTable+" "):
+		delete(state.applied, int(args[0].Value.(int64)))
+	case strings.HasPrefix(query, "CREATE TABLE "):
+		name := strings.TrimPrefix(query, "CREATE TABLE ")
+		if state.tables[name] {
+			return nil, errors.New("table already exists: " + name)
+		}
+		state.tables[name] = true
+	case strings.HasPrefix(query, "DROP TABLE "):
+		name := strings.TrimPrefix(query, "DROP TABLE ")
+		if !state.tables[name] {
+			return nil, errors.New("table does not exist: " + name)
+		}
+		delete(state.tables, name)
+	case query == "FAIL":
+		return nil, errors.New("failed")
+	default:
+		return nil, errors.New("unsupported statement: " + query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *testMigrationConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	state := c.getState()
+	switch {
+	case strings.HasPrefix(query, "SELECT COUNT(*) FROM "+SchemaMigrationsTable):
+		return &testMigrationRows{columns: []string{"count"}, values: [][]driver.Value{{int64(len(state.applied))}}}, nil
+	case strings.HasPrefix(query, "SELECT COALESCE(MAX(version), 0) FROM "+SchemaMigrationsTable):
+		var max int64
+		for version := range state.applied {
+			max = int64(MaxOf(int(max), version))
+		}
+		return &testMigrationRows{columns: []string{"max"}, values: [][]driver.Value{{max}}}, nil
+	case strings.HasPrefix(query, "SELECT EXISTS (SELECT 1 FROM "+SchemaMigrationsTable+" "):
+		_, ok := state.applied[int(args[0].Value.(int64))]
+		return &testMigrationRows{columns: []string{"exists"}, values: [][]driver.Value{{ok}}}, nil
+	case strings.HasPrefix(query, "SELECT version, applied FROM "+SchemaMigrationsTable):
+		res := &testMigrationRows{columns: []string{"version", "applied"}}
+		for version, applied := range state.applied {
+			res.values = append(res.values, []driver.Value{int64(version), applied})
+		}
+		return res, nil
+	}
+	return nil, errors.New("unsupported query: " + query)
+}
+
+func (r *testMigrationRows) Columns() []string {
+	return r.columns
+}
+
+func (r *testMigrationRows) Close() error {
+	return nil
+}
+
+func (r *testMigrationRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
@@ -2,66 +2,57 @@ package main
 
 import (
 	"log"
-	"strconv"
 
 	"github.com/google/uuid"
 )
 
-func RunDBSchemaUpdates() {
-	targetVersion := 15
-	log.Printf("Initializing database with schema version %d...\n", targetVersion)
-	curVersion, err := GetSettingsRepository().GetGlobalInt(SettingDatabaseVersion.Name)
+// RunDBSchemaUpdates applies the pending migrations. If the automatic migration is
+// disabled, the migrations have to be applied using the migrate command before.
+func RunDBSchemaUpdates(repos *Repositories) {
+	migrator, err := GetMigrator(repos)
 	if err != nil {
-		curVersion = 0
+		panic(err)
 	}
-	repositories := []Repository{
-		GetAuthProviderRepository(),
-		GetAuthStateRepository(),
-		GetAuthAttemptRepository(),
-		GetBookingRepository(),
-		GetLocationRepository(),
-		GetOrganizationRepository(),
-		GetSpaceRepository(),
-		GetUserRepository(),
-		GetUserPreferencesRepository(),
-		GetSettingsRepository(),
-		GetSignupRepository(),
-		GetSubscriptionRepository(),
-		GetRefreshTokenRepository(),
-		GetDebugTimeIssuesRepository(),
+	targetVersion := migrator.GetLatestVersion()
+	pending, err := migrator.GetPending(targetVersion)
+	if err != nil {
+		panic(err)
 	}
-	for _, repository := range repositories {
-		repository.RunSchemaUpgrade(curVersion, targetVersion)
+	if len(pending) > 0 && GetConfig().DisableAutoMigrate {
+		log.Fatalf("Database schema is outdated, %d migrations are pending. Run 'migrate up' to apply them.\n", len(pending))
+	}
+	log.Printf("Initializing database with schema version %d...\n", targetVersion)
+	if _, err := migrator.Up(targetVersion); err != nil {
+		panic(err)
 	}
-	GetSettingsRepository().SetGlobal(SettingDatabaseVersion.Name, strconv.Itoa(targetVersion))
-	SetGlobalInstallID()
+	SetGlobalInstallID(repos)
 }
 
-func SetGlobalInstallID() {
-	ID, err := GetSettingsRepository().GetGlobalString(SettingInstallID.Name)
+func SetGlobalInstallID(repos *Repositories) {
+	ID, err := repos.Settings.GetGlobalString(SettingInstallID.Name)
 	if (err != nil) || (ID == "") {
-		GetSettingsRepository().SetGlobal(SettingInstallID.Name, uuid.New().String())
+		repos.Settings.SetGlobal(SettingInstallID.Name, uuid.New().String())
 	}
 }
 
-func InitDefaultOrgSettings() {
+func InitDefaultOrgSettings(repos *Repositories) {
 	log.Println("Configuring default settings for orgs...")
-	list, err := GetOrganizationRepository().GetAllIDs()
+	list, err := repos.Organization.GetAllIDs()
 	if err != nil {
 		panic(err)
 	}
-	if err := GetSettingsRepository().InitDefaultSettings(list); err != nil {
+	if err := repos.Settings.InitDefaultSettings(list); err != nil {
 		panic(err)
 	}
 }
 
-func InitDefaultUserPreferences() {
+func InitDefaultUserPreferences(repos *Repositories) {
 	log.Println("Configuring default preferences for users...")
-	list, err := GetUserRepository().GetAllIDs()
+	list, err := repos.User.GetAllIDs()
 	if err != nil {
 		panic(err)
 	}
-	if err := GetUserPreferencesRepository().InitDefaultSettings(list); err != nil {
+	if err := repos.UserPreferences.InitDefaultSettings(list); err != nil {
 		panic(err)
 	}
 }
@@ -0,0 +1,36 @@
+package main
+
+type MemoryDebugTimeIssuesRepository struct {
+}
+
+func (r *MemoryDebugTimeIssuesRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryDebugTimeIssuesRepository) Create(e *DebugTimeIssueItem) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e.ID = memoryNewID()
+	row := *e
+	row.Created = memoryTimestamp(e.Created)
+	memoryData.debugTimeIssues = append(memoryData.debugTimeIssues, &row)
+	return nil
+}
+
+func (r *MemoryDebugTimeIssuesRepository) GetOne(id string) (*DebugTimeIssueItem, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryGet(memoryData.debugTimeIssues, func(e *DebugTimeIssueItem) bool {
+		return e.ID == id
+	})
+}
+
+func (r *MemoryDebugTimeIssuesRepository) Delete(e *DebugTimeIssueItem) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.debugTimeIssues = memoryDelete(memoryData.debugTimeIssues, func(row *DebugTimeIssueItem) bool {
+		return row.ID == e.ID
+	})
+	return nil
+}
@@ -1,11 +1,15 @@
 package main
 
-import (
-	"sync"
-	"time"
-)
+import "time"
 
-type DebugTimeIssuesRepository struct {
+type DebugTimeIssuesRepository interface {
+	Repository
+	Create(e *DebugTimeIssueItem) error
+	GetOne(id string) (*DebugTimeIssueItem, error)
+	Delete(e *DebugTimeIssueItem) error
+}
+
+type PostgresDebugTimeIssuesRepository struct {
 }
 
 type DebugTimeIssueItem struct {
@@ -13,28 +17,18 @@ type DebugTimeIssueItem struct {
 	Created time.Time
 }
 
-var debugTimeIssuesRepository *DebugTimeIssuesRepository
-var debugTimeIssuesRepositoryOnce sync.Once
-
-func GetDebugTimeIssuesRepository() *DebugTimeIssuesRepository {
-	debugTimeIssuesRepositoryOnce.Do(func() {
-		debugTimeIssuesRepository = &DebugTimeIssuesRepository{}
-		_, err := GetDatabase().DB().Exec("CREATE TABLE IF NOT EXISTS debug_time_issues (" +
-			"id uuid DEFAULT uuid_generate_v4(), " +
-			"created TIMESTAMP NOT NULL, " +
-			"PRIMARY KEY (id))")
-		if err != nil {
-			panic(err)
-		}
-	})
-	return debugTimeIssuesRepository
-}
-
-func (r *DebugTimeIssuesRepository) RunSchemaUpgrade(curVersion, targetVersion int) {
-	// No updates yet
+func (r *PostgresDebugTimeIssuesRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		newInitialSchemaMigration(
+			"CREATE TABLE IF NOT EXISTS debug_time_issues (" +
+				"id uuid DEFAULT uuid_generate_v4(), " +
+				"created TIMESTAMP NOT NULL, " +
+				"PRIMARY KEY (id))",
+		),
+	}
 }
 
-func (r *DebugTimeIssuesRepository) Create(e *DebugTimeIssueItem) error {
+func (r *PostgresDebugTimeIssuesRepository) Create(e *DebugTimeIssueItem) error {
 	var id string
 	err := GetDatabase().DB().QueryRow("INSERT INTO debug_time_issues "+
 		"(created) "+
@@ -48,7 +42,7 @@ func (r *DebugTimeIssuesRepository) Create(e *DebugTimeIssueItem) error {
 	return nil
 }
 
-func (r *DebugTimeIssuesRepository) GetOne(id string) (*DebugTimeIssueItem, error) {
+func (r *PostgresDebugTimeIssuesRepository) GetOne(id string) (*DebugTimeIssueItem, error) {
 	e := &DebugTimeIssueItem{}
 	err := GetDatabase().DB().QueryRow("SELECT id, created "+
 		"FROM debug_time_issues "+
@@ -60,7 +54,7 @@ func (r *DebugTimeIssuesRepository) GetOne(id string) (*DebugTimeIssueItem, erro
 	return e, nil
 }
 
-func (r *DebugTimeIssuesRepository) Delete(e *DebugTimeIssueItem) error {
+func (r *PostgresDebugTimeIssuesRepository) Delete(e *DebugTimeIssueItem) error {
 	_, err := GetDatabase().DB().Exec("DELETE FROM debug_time_issues WHERE id = $1", e.ID)
 	return err
 }
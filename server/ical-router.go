@@ -0,0 +1,148 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type ICalRouter struct {
+	repos *Repositories
+}
+
+type GetICalTokenResponse struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+const ICalDateTimeFormat string = "20060102T150405"
+
+// ICalSequenceEpoch is subtracted from the current unix time to derive invite sequence numbers.
+const ICalSequenceEpoch int64 = 1700000000
+
+// ICalPastDays is the number of days past bookings are kept in the feed.
+const ICalPastDays int = 30
+
+func (router *ICalRouter) setupRoutes(s *mux.Router) {
+	s.HandleFunc("/{token}.ics", router.getFeed).Methods("GET")
+}
+
+func (router *ICalRouter) getFeed(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	token, err := router.repos.ICalToken.GetOne(vars["token"])
+	if err != nil {
+		SendNotFound(w)
+		return
+	}
+	user, err := router.repos.User.GetOne(token.UserID)
+	if err != nil || user.Disabled {
+		SendNotFound(w)
+		return
+	}
+	list, err := router.repos.Booking.GetAllByUserOrAttendee(user.ID, time.Now().UTC().AddDate(0, 0, -ICalPastDays))
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", "inline; filename=\"seatsurfing.ics\"")
+	w.Write([]byte(router.render(list)))
+}
+
+func (router *ICalRouter) render(list []*BookingDetails) string {
+	var sb strings.Builder
+	router.writeHeader(&sb, "PUBLISH")
+	router.writeLine(&sb, "X-WR-CALNAME:Seatsurfing")
+	now := time.Now().UTC()
+	for _, e := range list {
+		router.writeLine(&sb, "BEGIN:VEVENT")
+		router.writeEvent(&sb, e, now)
+		router.writeLine(&sb, "END:VEVENT")
+	}
+	router.writeLine(&sb, "END:VCALENDAR")
+	return sb.String()
+}
+
+// renderInvite returns an iTIP message for the booking. Method is either "REQUEST" or "CANCEL".
+func (router *ICalRouter) renderInvite(e *BookingDetails, method string) string {
+	var sb strings.Builder
+	router.writeHeader(&sb, method)
+	now := time.Now().UTC()
+	router.writeLine(&sb, "BEGIN:VEVENT")
+	router.writeEvent(&sb, e, now)
+	// Each message supersedes the previous ones
+	router.writeLine(&sb, "SEQUENCE:"+strconv.FormatInt(now.Unix()-ICalSequenceEpoch, 10))
+	router.writeLine(&sb, "ORGANIZER;CN=Seatsurfing:mailto:"+GetConfig().SMTPSenderAddress)
+	router.writeLine(&sb, "ATTENDEE;ROLE=REQ-PARTICIPANT;PARTSTAT=ACCEPTED:mailto:"+e.UserEmail)
+	for _, attendee := range e.Attendees {
+		router.writeLine(&sb, "ATTENDEE;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION:mailto:"+attendee.UserEmail)
+	}
+	if method == "CANCEL" {
+		router.writeLine(&sb, "STATUS:CANCELLED")
+	} else {
+		router.writeLine(&sb, "STATUS:CONFIRMED")
+	}
+	router.writeLine(&sb, "END:VEVENT")
+	router.writeLine(&sb, "END:VCALENDAR")
+	return sb.String()
+}
+
+func (router *ICalRouter) writeHeader(sb *strings.Builder, method string) {
+	router.writeLine(sb, "BEGIN:VCALENDAR")
+	router.writeLine(sb, "VERSION:2.0")
+	router.writeLine(sb, "PRODID:-//Seatsurfing//Seatsurfing "+GetProductVersion()+"//EN")
+	router.writeLine(sb, "CALSCALE:GREGORIAN")
+	router.writeLine(sb, "METHOD:"+method)
+}
+
+func (router *ICalRouter) writeEvent(sb *strings.Builder, e *BookingDetails, now time.Time) {
+	// Bookings are stored as local wall clock time of the location. They are converted
+	// to UTC, as TZID references would require a VTIMEZONE component for each timezone.
+	enter, _ := attachTimezoneInformation(router.repos, e.Enter, &e.Space.Location)
+	leave, _ := attachTimezoneInformation(router.repos, e.Leave, &e.Space.Location)
+	router.writeLine(sb, "UID:"+e.ID+"@seatsurfing")
+	router.writeLine(sb, "DTSTAMP:"+now.Format(ICalDateTimeFormat)+"Z")
+	router.writeLine(sb, "DTSTART:"+enter.UTC().Format(ICalDateTimeFormat)+"Z")
+	router.writeLine(sb, "DTEND:"+leave.UTC().Format(ICalDateTimeFormat)+"Z")
+	summary := e.Space.Name + " (" + e.Space.Location.Name + ")"
+	if e.Subject != "" {
+		summary = e.Subject + ": " + summary
+	}
+	router.writeLine(sb, "SUMMARY:"+router.escape(summary))
+	router.writeLine(sb, "LOCATION:"+router.escape(e.Space.Location.Name))
+	router.writeLine(sb, "TRANSP:TRANSPARENT")
+}
+
+// writeLine writes a content line folded to 75 octets as required by RFC 5545.
+func (router *ICalRouter) writeLine(sb *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		// Don't split multi-byte UTF-8 sequences
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		sb.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+	sb.WriteString(line + "\r\n")
+}
+
+func (router *ICalRouter) escape(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, ";", "\\;")
+	s = strings.ReplaceAll(s, ",", "\\,")
+	s = strings.ReplaceAll(s, "\r\n", "\\n")
+	s = strings.ReplaceAll(s, "\n", "\\n")
+	return s
+}
+
+func (router *ICalRouter) getFeedURL(token *ICalToken) string {
+	return GetConfig().PublicURL + "booking/ical/" + token.ID + ".ics"
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestICalFeed(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	user := createTestUserInOrg(org)
+	loginResponse := loginTestUser(user.ID)
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+
+	l := &Location{
+		Name:           "Main, Building",
+		OrganizationID: org.ID,
+		Timezone:       "Europe/Berlin",
+	}
+	testRepositories.Location.Create(l)
+	s1 := &Space{Name: "Desk 1", LocationID: l.ID}
+	testRepositories.Space.Create(s1)
+
+	payload := "{\"spaceId\": \"" + s1.ID + "\", \"enter\": \"2030-09-01T08:30:00Z\", \"leave\": \"2030-09-01T17:00:00Z\"}"
+	req := newHTTPRequest("POST", "/booking/", loginResponse.UserID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	bookingID := res.Header().Get("X-Object-Id")
+
+	// No token yet
+	req = newHTTPRequest("GET", "/user/me/ical", loginResponse.UserID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNotFound, res.Code)
+
+	// Create token
+	req = newHTTPRequest("POST", "/user/me/ical", loginResponse.UserID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	token := res.Header().Get("X-Object-Id")
+
+	req = newHTTPRequest("GET", "/user/me/ical", loginResponse.UserID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody *GetICalTokenResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestString(t, token, resBody.Token)
+	checkTestBool(t, true, strings.HasSuffix(resBody.URL, "/booking/ical/"+token+".ics"))
+
+	// Get feed without JWT
+	req = newHTTPRequest("GET", "/booking/ical/"+token+".ics", "", nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	checkTestBool(t, true, strings.HasPrefix(res.Header().Get("Content-Type"), "text/calendar"))
+	body := res.Body.String()
+	checkTestBool(t, true, strings.Contains(body, "UID:"+bookingID+"@seatsurfing\r\n"))
+	checkTestBool(t, true, strings.Contains(body, "DTSTART:20300901T063000Z\r\n"))
+	checkTestBool(t, true, strings.Contains(body, "DTEND:20300901T150000Z\r\n"))
+	checkTestBool(t, true, strings.Contains(body, "SUMMARY:Desk 1 (Main\\, Building)\r\n"))
+
+	// Regenerate token revokes the old one
+	req = newHTTPRequest("POST", "/user/me/ical", loginResponse.UserID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	token2 := res.Header().Get("X-Object-Id")
+
+	req = newHTTPRequest("GET", "/booking/ical/"+token+".ics", "", nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNotFound, res.Code)
+
+	// Revoke token
+	req = newHTTPRequest("DELETE", "/user/me/ical", loginResponse.UserID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	req = newHTTPRequest("GET", "/booking/ical/"+token2+".ics", "", nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNotFound, res.Code)
+}
+
+func TestICalFeedInvalidToken(t *testing.T) {
+	clearTestDB()
+	req := newHTTPRequest("GET", "/booking/ical/invalid.ics", "", nil)
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNotFound, res.Code)
+}
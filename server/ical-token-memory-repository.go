@@ -0,0 +1,52 @@
+package main
+
+type MemoryICalTokenRepository struct {
+}
+
+func (r *MemoryICalTokenRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryICalTokenRepository) Create(e *ICalToken) error {
+	if err := memoryCheckUUID(e.UserID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	if memoryCount(memoryData.iCalTokens, func(row *ICalToken) bool { return row.UserID == e.UserID }) > 0 {
+		return memoryUniqueViolation("idx_ical_tokens_user_id")
+	}
+	e.ID = memoryNewID()
+	row := *e
+	row.Created = memoryTimestamp(e.Created)
+	memoryData.iCalTokens = append(memoryData.iCalTokens, &row)
+	return nil
+}
+
+func (r *MemoryICalTokenRepository) GetOne(id string) (*ICalToken, error) {
+	return r.getOne(func(e *ICalToken) bool {
+		return e.ID == id
+	})
+}
+
+func (r *MemoryICalTokenRepository) GetByUser(userID string) (*ICalToken, error) {
+	return r.getOne(func(e *ICalToken) bool {
+		return e.UserID == userID
+	})
+}
+
+func (r *MemoryICalTokenRepository) getOne(cond func(e *ICalToken) bool) (*ICalToken, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryGet(memoryData.iCalTokens, cond)
+}
+
+func (r *MemoryICalTokenRepository) DeleteOfUser(u *User) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.iCalTokens = memoryDelete(memoryData.iCalTokens, func(row *ICalToken) bool {
+		return row.UserID == u.ID
+	})
+	return nil
+}
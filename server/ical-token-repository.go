@@ -0,0 +1,83 @@
+package main
+
+import "time"
+
+type ICalTokenRepository interface {
+	Repository
+	Create(e *ICalToken) error
+	GetOne(id string) (*ICalToken, error)
+	GetByUser(userID string) (*ICalToken, error)
+	DeleteOfUser(u *User) error
+}
+
+type PostgresICalTokenRepository struct {
+}
+
+// ICalToken grants read access to a user's iCalendar feed. It does not expire
+// and stays valid until it is revoked by the user.
+type ICalToken struct {
+	ID      string
+	UserID  string
+	Created time.Time
+}
+
+func (r *PostgresICalTokenRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		{
+			Version:     26,
+			Description: "Add iCalendar tokens",
+			Up: []string{
+				"CREATE TABLE IF NOT EXISTS ical_tokens (" +
+					"id uuid DEFAULT uuid_generate_v4(), " +
+					"user_id uuid NOT NULL, " +
+					"created TIMESTAMP NOT NULL, " +
+					"PRIMARY KEY (id))",
+				"CREATE UNIQUE INDEX IF NOT EXISTS idx_ical_tokens_user_id ON ical_tokens(user_id)",
+			},
+			Down: []string{"DROP TABLE IF EXISTS ical_tokens"},
+		},
+	}
+}
+
+func (r *PostgresICalTokenRepository) Create(e *ICalToken) error {
+	var id string
+	err := GetDatabase().DB().QueryRow("INSERT INTO ical_tokens "+
+		"(user_id, created) "+
+		"VALUES ($1, $2) "+
+		"RETURNING id",
+		e.UserID, e.Created).Scan(&id)
+	if err != nil {
+		return err
+	}
+	e.ID = id
+	return nil
+}
+
+func (r *PostgresICalTokenRepository) GetOne(id string) (*ICalToken, error) {
+	e := &ICalToken{}
+	err := GetDatabase().DB().QueryRow("SELECT id, user_id, created "+
+		"FROM ical_tokens "+
+		"WHERE id = $1",
+		id).Scan(&e.ID, &e.UserID, &e.Created)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (r *PostgresICalTokenRepository) GetByUser(userID string) (*ICalToken, error) {
+	e := &ICalToken{}
+	err := GetDatabase().DB().QueryRow("SELECT id, user_id, created "+
+		"FROM ical_tokens "+
+		"WHERE user_id = $1",
+		userID).Scan(&e.ID, &e.UserID, &e.Created)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (r *PostgresICalTokenRepository) DeleteOfUser(u *User) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM ical_tokens WHERE user_id = $1", u.ID)
+	return err
+}
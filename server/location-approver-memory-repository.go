@@ -0,0 +1,68 @@
+package main
+
+type MemoryLocationApproverRepository struct {
+	locationApproverRepositoryBase
+}
+
+func (r *MemoryLocationApproverRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryLocationApproverRepository) SetAll(locationID string, userIDs []string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.locationApprovers = memoryDelete(memoryData.locationApprovers, func(row *memoryLocationApprover) bool {
+		return row.LocationID == locationID
+	})
+	for _, userID := range userIDs {
+		if err := memoryCheckUUID(locationID, userID); err != nil {
+			return err
+		}
+		if memoryCount(memoryData.locationApprovers, func(row *memoryLocationApprover) bool {
+			return row.LocationID == locationID && row.UserID == userID
+		}) > 0 {
+			return memoryUniqueViolation("location_approvers_pkey")
+		}
+		memoryData.locationApprovers = append(memoryData.locationApprovers, &memoryLocationApprover{
+			LocationID: locationID,
+			UserID:     userID,
+		})
+	}
+	return nil
+}
+
+func (r *MemoryLocationApproverRepository) GetAll(locationID string) ([]string, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	var result []string
+	for _, e := range memoryData.locationApprovers {
+		if e.LocationID == locationID {
+			result = append(result, e.UserID)
+		}
+	}
+	return result, nil
+}
+
+func (r *MemoryLocationApproverRepository) DeleteAll(locationID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.locationApprovers = memoryDelete(memoryData.locationApprovers, func(row *memoryLocationApprover) bool {
+		return row.LocationID == locationID
+	})
+	return nil
+}
+
+func (r *MemoryLocationApproverRepository) DeleteOfUser(e *User) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.locationApprovers = memoryDelete(memoryData.locationApprovers, func(row *memoryLocationApprover) bool {
+		return row.UserID == e.ID
+	})
+	return nil
+}
+
+type memoryLocationApprover struct {
+	LocationID string
+	UserID     string
+}
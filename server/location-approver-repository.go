@@ -0,0 +1,114 @@
+package main
+
+import "slices"
+
+type LocationApproverRepository interface {
+	Repository
+	SetAll(locationID string, userIDs []string) error
+	GetAll(locationID string) ([]string, error)
+	CanApprove(user *User, location *Location) (bool, error)
+	IsValidApproverList(organizationID string, userIDs []string) bool
+	DeleteAll(locationID string) error
+	DeleteOfUser(e *User) error
+}
+
+type PostgresLocationApproverRepository struct {
+	locationApproverRepositoryBase
+}
+
+// locationApproverRepositoryBase contains the permission checks, which only depend on GetAll.
+type locationApproverRepositoryBase struct {
+	repos *Repositories
+}
+
+func (r *PostgresLocationApproverRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		{
+			Version:     22,
+			Description: "Add approvers to locations",
+			Up: []string{
+				"CREATE TABLE IF NOT EXISTS location_approvers (" +
+					"location_id uuid NOT NULL, " +
+					"user_id uuid NOT NULL, " +
+					"PRIMARY KEY (location_id, user_id))",
+				"CREATE INDEX IF NOT EXISTS idx_location_approvers_user_id ON location_approvers(user_id)",
+			},
+			Down: []string{"DROP TABLE IF EXISTS location_approvers"},
+		},
+	}
+}
+
+// SetAll replaces the approvers of the location.
+func (r *PostgresLocationApproverRepository) SetAll(locationID string, userIDs []string) error {
+	if err := r.DeleteAll(locationID); err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		_, err := GetDatabase().DB().Exec("INSERT INTO location_approvers "+
+			"(location_id, user_id) "+
+			"VALUES ($1, $2)",
+			locationID, userID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PostgresLocationApproverRepository) GetAll(locationID string) ([]string, error) {
+	var result []string
+	rows, err := GetDatabase().DB().Query("SELECT user_id "+
+		"FROM location_approvers "+
+		"WHERE location_id = $1", locationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID string
+		if err = rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		result = append(result, userID)
+	}
+	return result, nil
+}
+
+// CanApprove returns true if the user may approve bookings in the location. If the
+// location has no approvers, all space admins of the organization are approvers.
+func (r *locationApproverRepositoryBase) CanApprove(user *User, location *Location) (bool, error) {
+	if !CanSpaceAdminOrg(user, location.OrganizationID) {
+		return false, nil
+	}
+	approvers, err := r.repos.LocationApprover.GetAll(location.ID)
+	if err != nil {
+		return false, err
+	}
+	return len(approvers) == 0 || slices.Contains(approvers, user.ID), nil
+}
+
+// IsValidApproverList returns true if all users are space admins of the organization.
+func (r *locationApproverRepositoryBase) IsValidApproverList(organizationID string, userIDs []string) bool {
+	seen := make(map[string]bool)
+	for _, userID := range userIDs {
+		if seen[userID] {
+			return false
+		}
+		seen[userID] = true
+		user, err := r.repos.User.GetOne(userID)
+		if err != nil || user.OrganizationID != organizationID || !r.repos.User.isSpaceAdmin(user) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *PostgresLocationApproverRepository) DeleteAll(locationID string) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM location_approvers WHERE location_id = $1", locationID)
+	return err
+}
+
+func (r *PostgresLocationApproverRepository) DeleteOfUser(e *User) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM location_approvers WHERE user_id = $1", e.ID)
+	return err
+}
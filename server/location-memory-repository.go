@@ -0,0 +1,206 @@
+package main
+
+type MemoryLocationRepository struct {
+	locationRepositoryBase
+}
+
+// memoryLocation is a row of the locations table, including the map's image.
+type memoryLocation struct {
+	Location
+	MapData []byte
+}
+
+func (r *MemoryLocationRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryLocationRepository) Create(e *Location) error {
+	if err := memoryCheckUUID(e.OrganizationID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e.ID = memoryNewID()
+	row := &memoryLocation{Location: *e}
+	row.MapMimeType = ""
+	row.MapWidth = 0
+	row.MapHeight = 0
+	memoryData.locations = append(memoryData.locations, row)
+	return nil
+}
+
+func (r *MemoryLocationRepository) GetOne(id string) (*Location, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e, err := memoryFind(memoryData.locations, func(e *memoryLocation) bool {
+		return e.ID == id
+	})
+	if err != nil {
+		return nil, err
+	}
+	c := e.Location
+	return &c, nil
+}
+
+func (r *MemoryLocationRepository) GetByKeyword(organizationID string, keyword string) ([]*Location, error) {
+	return r.getAll(func(e *memoryLocation) bool {
+		return e.OrganizationID == organizationID && memoryContains(e.Name, keyword)
+	}), nil
+}
+
+func (r *MemoryLocationRepository) GetAll(organizationID string) ([]*Location, error) {
+	return r.getAll(func(e *memoryLocation) bool {
+		return e.OrganizationID == organizationID
+	}), nil
+}
+
+func (r *MemoryLocationRepository) getAll(cond func(*memoryLocation) bool) []*Location {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	var result []*Location
+	for _, e := range memoryData.locations {
+		if cond(e) {
+			c := e.Location
+			result = append(result, &c)
+		}
+	}
+	memorySort(result, func(a, b *Location) bool {
+		return memoryCompare(a.Name, b.Name) < 0
+	})
+	return result
+}
+
+func (r *MemoryLocationRepository) Update(e *Location) error {
+	if err := memoryCheckUUID(e.OrganizationID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	row, err := memoryFind(memoryData.locations, func(row *memoryLocation) bool {
+		return row.ID == e.ID
+	})
+	if err != nil {
+		return nil
+	}
+	row.OrganizationID = e.OrganizationID
+	row.Name = e.Name
+	row.Description = e.Description
+	row.MaxConcurrentBookings = e.MaxConcurrentBookings
+	row.Timezone = e.Timezone
+	return nil
+}
+
+func (r *MemoryLocationRepository) Delete(e *Location) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	r.deleteLocations(func(row *memoryLocation) bool {
+		return row.ID == e.ID
+	})
+	return nil
+}
+
+func (r *MemoryLocationRepository) DeleteAll(organizationID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	r.deleteLocations(func(row *memoryLocation) bool {
+		return row.OrganizationID == organizationID
+	})
+	return nil
+}
+
+// deleteLocations removes the matching locations including their spaces, bookings,
+// restrictions, waitlist entries and approvers. The caller must hold the lock.
+func (r *MemoryLocationRepository) deleteLocations(cond func(*memoryLocation) bool) {
+	locationIDs := make(map[string]bool)
+	for _, e := range memoryData.locations {
+		if cond(e) {
+			locationIDs[e.ID] = true
+		}
+	}
+	spaceIDs := make(map[string]bool)
+	for _, e := range memoryData.spaces {
+		if locationIDs[e.LocationID] {
+			spaceIDs[e.ID] = true
+		}
+	}
+	bookingIDs := make(map[string]bool)
+	for _, e := range memoryData.bookings {
+		if spaceIDs[e.SpaceID] {
+			bookingIDs[e.ID] = true
+		}
+	}
+	memoryData.bookingAttendees = memoryDelete(memoryData.bookingAttendees, func(row *BookingAttendee) bool {
+		return bookingIDs[row.BookingID]
+	})
+	memoryData.bookings = memoryDelete(memoryData.bookings, func(row *memoryBooking) bool {
+		return bookingIDs[row.ID]
+	})
+	memoryData.spaceAttributeValues = memoryDelete(memoryData.spaceAttributeValues, func(row *SpaceAttributeValue) bool {
+		return spaceIDs[row.SpaceID]
+	})
+	memoryData.bookingRestrictions = memoryDelete(memoryData.bookingRestrictions, func(row *BookingRestriction) bool {
+		return locationIDs[row.TargetID] || spaceIDs[row.TargetID]
+	})
+	memoryData.waitlistEntries = memoryDelete(memoryData.waitlistEntries, func(row *WaitlistEntry) bool {
+		return locationIDs[row.LocationID]
+	})
+	memoryData.locationApprovers = memoryDelete(memoryData.locationApprovers, func(row *memoryLocationApprover) bool {
+		return locationIDs[row.LocationID]
+	})
+	memoryData.spaces = memoryDelete(memoryData.spaces, func(row *Space) bool {
+		return spaceIDs[row.ID]
+	})
+	memoryData.locations = memoryDelete(memoryData.locations, func(row *memoryLocation) bool {
+		return locationIDs[row.ID]
+	})
+}
+
+func (r *MemoryLocationRepository) GetCount(organizationID string) (int, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryCount(memoryData.locations, func(e *memoryLocation) bool {
+		return e.OrganizationID == organizationID
+	}), nil
+}
+
+func (r *MemoryLocationRepository) SetMap(e *Location, locationMap *LocationMap) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	row, err := memoryFind(memoryData.locations, func(row *memoryLocation) bool {
+		return row.ID == e.ID
+	})
+	if err != nil {
+		return nil
+	}
+	row.MapMimeType = locationMap.MimeType
+	row.MapData = append([]byte(nil), locationMap.Data...)
+	row.MapWidth = locationMap.Width
+	row.MapHeight = locationMap.Height
+	return nil
+}
+
+func (r *MemoryLocationRepository) GetMap(location *Location) (*LocationMap, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	row, err := memoryFind(memoryData.locations, func(row *memoryLocation) bool {
+		return row.ID == location.ID
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &LocationMap{
+		MimeType: row.MapMimeType,
+		Data:     append([]byte(nil), row.MapData...),
+		Width:    row.MapWidth,
+		Height:   row.MapHeight,
+	}, nil
+}
+
+// memoryLocationByID returns the stored location or nil. The caller must hold the lock.
+func memoryLocationByID(id string) *memoryLocation {
+	e, _ := memoryFind(memoryData.locations, func(e *memoryLocation) bool {
+		return e.ID == id
+	})
+	return e
+}
@@ -1,11 +1,29 @@
 package main
 
-import (
-	"strings"
-	"sync"
-)
+import "strings"
 
-type LocationRepository struct {
+type LocationRepository interface {
+	Repository
+	Create(e *Location) error
+	GetOne(id string) (*Location, error)
+	GetByKeyword(organizationID string, keyword string) ([]*Location, error)
+	GetAll(organizationID string) ([]*Location, error)
+	Update(e *Location) error
+	Delete(e *Location) error
+	DeleteAll(organizationID string) error
+	GetCount(organizationID string) (int, error)
+	SetMap(e *Location, locationMap *LocationMap) error
+	GetMap(location *Location) (*LocationMap, error)
+	GetTimezone(location *Location) string
+}
+
+type PostgresLocationRepository struct {
+	locationRepositoryBase
+}
+
+// locationRepositoryBase resolves the timezone of locations regardless of the storage.
+type locationRepositoryBase struct {
+	repos *Repositories
 }
 
 type Location struct {
@@ -27,50 +45,47 @@ type LocationMap struct {
 	Data     []byte
 }
 
-var locationRepository *LocationRepository
-var locationRepositoryOnce sync.Once
-
-func GetLocationRepository() *LocationRepository {
-	locationRepositoryOnce.Do(func() {
-		locationRepository = &LocationRepository{}
-		_, err := GetDatabase().DB().Exec("CREATE TABLE IF NOT EXISTS locations (" +
-			"id uuid DEFAULT uuid_generate_v4(), " +
-			"organization_id uuid NOT NULL, " +
-			"name VARCHAR NOT NULL, " +
-			"map_mimetype VARCHAR DEFAULT ''," +
-			"map_data BYTEA," +
-			"map_width INTEGER DEFAULT 0," +
-			"map_height INTEGER DEFAULT 0," +
-			"PRIMARY KEY (id))")
-		if err != nil {
-			panic(err)
-		}
-	})
-	return locationRepository
-}
-
-func (r *LocationRepository) RunSchemaUpgrade(curVersion, targetVersion int) {
-	if curVersion < 9 {
-		if _, err := GetDatabase().DB().Exec("ALTER TABLE locations " +
-			"ADD COLUMN description VARCHAR DEFAULT ''"); err != nil {
-			panic(err)
-		}
-	}
-	if curVersion < 10 {
-		if _, err := GetDatabase().DB().Exec("ALTER TABLE locations " +
-			"ADD COLUMN max_concurrent_bookings INTEGER DEFAULT 0"); err != nil {
-			panic(err)
-		}
-	}
-	if curVersion < 11 {
-		if _, err := GetDatabase().DB().Exec("ALTER TABLE locations " +
-			"ADD COLUMN tz VARCHAR DEFAULT ''"); err != nil {
-			panic(err)
-		}
+func (r *PostgresLocationRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		newInitialSchemaMigration(
+			"CREATE TABLE IF NOT EXISTS locations (" +
+				"id uuid DEFAULT uuid_generate_v4(), " +
+				"organization_id uuid NOT NULL, " +
+				"name VARCHAR NOT NULL, " +
+				"map_mimetype VARCHAR DEFAULT ''," +
+				"map_data BYTEA," +
+				"map_width INTEGER DEFAULT 0," +
+				"map_height INTEGER DEFAULT 0," +
+				"PRIMARY KEY (id))",
+		),
+		{
+			Version:     9,
+			Description: "Add description to locations",
+			Up: []string{"ALTER TABLE locations " +
+				"ADD COLUMN description VARCHAR DEFAULT ''"},
+			Down: []string{"ALTER TABLE locations " +
+				"DROP COLUMN description"},
+		},
+		{
+			Version:     10,
+			Description: "Add maximum concurrent bookings to locations",
+			Up: []string{"ALTER TABLE locations " +
+				"ADD COLUMN max_concurrent_bookings INTEGER DEFAULT 0"},
+			Down: []string{"ALTER TABLE locations " +
+				"DROP COLUMN max_concurrent_bookings"},
+		},
+		{
+			Version:     11,
+			Description: "Add timezone to locations",
+			Up: []string{"ALTER TABLE locations " +
+				"ADD COLUMN tz VARCHAR DEFAULT ''"},
+			Down: []string{"ALTER TABLE locations " +
+				"DROP COLUMN tz"},
+		},
 	}
 }
 
-func (r *LocationRepository) Create(e *Location) error {
+func (r *PostgresLocationRepository) Create(e *Location) error {
 	var id string
 	err := GetDatabase().DB().QueryRow("INSERT INTO locations "+
 		"(organization_id, name, description, max_concurrent_bookings, tz) "+
@@ -84,7 +99,7 @@ func (r *LocationRepository) Create(e *Location) error {
 	return nil
 }
 
-func (r *LocationRepository) GetOne(id string) (*Location, error) {
+func (r *PostgresLocationRepository) GetOne(id string) (*Location, error) {
 	e := &Location{}
 	err := GetDatabase().DB().QueryRow("SELECT id, organization_id, name, map_mimetype, map_width, map_height, description, max_concurrent_bookings, tz "+
 		"FROM locations "+
@@ -96,7 +111,7 @@ func (r *LocationRepository) GetOne(id string) (*Location, error) {
 	return e, nil
 }
 
-func (r *LocationRepository) GetByKeyword(organizationID string, keyword string) ([]*Location, error) {
+func (r *PostgresLocationRepository) GetByKeyword(organizationID string, keyword string) ([]*Location, error) {
 	var result []*Location
 	rows, err := GetDatabase().DB().Query("SELECT id, organization_id, name, map_mimetype, map_width, map_height, description, max_concurrent_bookings, tz "+
 		"FROM locations "+
@@ -117,7 +132,7 @@ func (r *LocationRepository) GetByKeyword(organizationID string, keyword string)
 	return result, nil
 }
 
-func (r *LocationRepository) GetAll(organizationID string) ([]*Location, error) {
+func (r *PostgresLocationRepository) GetAll(organizationID string) ([]*Location, error) {
 	var result []*Location
 	rows, err := GetDatabase().DB().Query("SELECT id, organization_id, name, map_mimetype, map_width, map_height, description, max_concurrent_bookings, tz "+
 		"FROM locations "+
@@ -138,7 +153,7 @@ func (r *LocationRepository) GetAll(organizationID string) ([]*Location, error)
 	return result, nil
 }
 
-func (r *LocationRepository) Update(e *Location) error {
+func (r *PostgresLocationRepository) Update(e *Location) error {
 	_, err := GetDatabase().DB().Exec("UPDATE locations SET "+
 		"organization_id = $1, "+
 		"name = $2, "+
@@ -150,10 +165,27 @@ func (r *LocationRepository) Update(e *Location) error {
 	return err
 }
 
-func (r *LocationRepository) Delete(e *Location) error {
+func (r *PostgresLocationRepository) Delete(e *Location) error {
+	if _, err := GetDatabase().DB().Exec("DELETE FROM bookings_attendees WHERE bookings_attendees.booking_id IN (SELECT bookings.id FROM bookings WHERE "+
+		"bookings.space_id IN (SELECT spaces.id FROM spaces WHERE spaces.location_id = $1))", e.ID); err != nil {
+		return err
+	}
 	if _, err := GetDatabase().DB().Exec("DELETE FROM bookings WHERE bookings.space_id IN (SELECT spaces.id FROM spaces WHERE spaces.location_id = $1)", e.ID); err != nil {
 		return err
 	}
+	if _, err := GetDatabase().DB().Exec("DELETE FROM space_attribute_values WHERE space_attribute_values.space_id IN (SELECT spaces.id FROM spaces WHERE spaces.location_id = $1)", e.ID); err != nil {
+		return err
+	}
+	if _, err := GetDatabase().DB().Exec("DELETE FROM booking_restrictions WHERE booking_restrictions.target_id = $1 OR "+
+		"booking_restrictions.target_id IN (SELECT spaces.id FROM spaces WHERE spaces.location_id = $1)", e.ID); err != nil {
+		return err
+	}
+	if _, err := GetDatabase().DB().Exec("DELETE FROM waitlist_entries WHERE location_id = $1", e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.LocationApprover.DeleteAll(e.ID); err != nil {
+		return err
+	}
 	if _, err := GetDatabase().DB().Exec("DELETE FROM spaces WHERE location_id = $1", e.ID); err != nil {
 		return err
 	}
@@ -161,13 +193,41 @@ func (r *LocationRepository) Delete(e *Location) error {
 	return err
 }
 
-func (r *LocationRepository) DeleteAll(organizationID string) error {
+func (r *PostgresLocationRepository) DeleteAll(organizationID string) error {
+	if _, err := GetDatabase().DB().Exec("DELETE FROM bookings_attendees WHERE "+
+		"bookings_attendees.booking_id IN (SELECT bookings.id FROM bookings WHERE "+
+		"bookings.space_id IN (SELECT spaces.id FROM spaces WHERE "+
+		"spaces.location_id IN (SELECT locations.id FROM locations WHERE locations.organization_id = $1)"+
+		"))", organizationID); err != nil {
+		return err
+	}
 	if _, err := GetDatabase().DB().Exec("DELETE FROM bookings WHERE "+
 		"bookings.space_id IN (SELECT spaces.id FROM spaces WHERE "+
 		"spaces.location_id IN (SELECT locations.id FROM locations WHERE locations.organization_id = $1)"+
 		")", organizationID); err != nil {
 		return err
 	}
+	if _, err := GetDatabase().DB().Exec("DELETE FROM space_attribute_values WHERE "+
+		"space_attribute_values.space_id IN (SELECT spaces.id FROM spaces WHERE "+
+		"spaces.location_id IN (SELECT locations.id FROM locations WHERE locations.organization_id = $1)"+
+		")", organizationID); err != nil {
+		return err
+	}
+	if _, err := GetDatabase().DB().Exec("DELETE FROM booking_restrictions WHERE "+
+		"booking_restrictions.target_id IN (SELECT locations.id FROM locations WHERE locations.organization_id = $1) OR "+
+		"booking_restrictions.target_id IN (SELECT spaces.id FROM spaces WHERE "+
+		"spaces.location_id IN (SELECT locations.id FROM locations WHERE locations.organization_id = $1)"+
+		")", organizationID); err != nil {
+		return err
+	}
+	if _, err := GetDatabase().DB().Exec("DELETE FROM waitlist_entries WHERE "+
+		"waitlist_entries.location_id IN (SELECT locations.id FROM locations WHERE locations.organization_id = $1)", organizationID); err != nil {
+		return err
+	}
+	if _, err := GetDatabase().DB().Exec("DELETE FROM location_approvers WHERE "+
+		"location_approvers.location_id IN (SELECT locations.id FROM locations WHERE locations.organization_id = $1)", organizationID); err != nil {
+		return err
+	}
 	if _, err := GetDatabase().DB().Exec("DELETE FROM spaces WHERE spaces.location_id IN (SELECT locations.id FROM locations WHERE locations.organization_id = $1)", organizationID); err != nil {
 		return err
 	}
@@ -175,7 +235,7 @@ func (r *LocationRepository) DeleteAll(organizationID string) error {
 	return err
 }
 
-func (r *LocationRepository) GetCount(organizationID string) (int, error) {
+func (r *PostgresLocationRepository) GetCount(organizationID string) (int, error) {
 	var res int
 	err := GetDatabase().DB().QueryRow("SELECT COUNT(id) "+
 		"FROM locations "+
@@ -184,7 +244,7 @@ func (r *LocationRepository) GetCount(organizationID string) (int, error) {
 	return res, err
 }
 
-func (r *LocationRepository) SetMap(e *Location, locationMap *LocationMap) error {
+func (r *PostgresLocationRepository) SetMap(e *Location, locationMap *LocationMap) error {
 	_, err := GetDatabase().DB().Exec("UPDATE locations SET "+
 		"map_mimetype = $1, "+
 		"map_data = $2, "+
@@ -195,7 +255,7 @@ func (r *LocationRepository) SetMap(e *Location, locationMap *LocationMap) error
 	return err
 }
 
-func (r *LocationRepository) GetMap(location *Location) (*LocationMap, error) {
+func (r *PostgresLocationRepository) GetMap(location *Location) (*LocationMap, error) {
 	e := &LocationMap{}
 	err := GetDatabase().DB().QueryRow("SELECT map_mimetype, map_data, map_width, map_height "+
 		"FROM locations "+
@@ -207,10 +267,10 @@ func (r *LocationRepository) GetMap(location *Location) (*LocationMap, error) {
 	return e, nil
 }
 
-func (r *LocationRepository) GetTimezone(location *Location) string {
+func (r *locationRepositoryBase) GetTimezone(location *Location) string {
 	tz := location.Timezone
 	if tz == "" {
-		defaultTz, _ := GetSettingsRepository().Get(location.OrganizationID, SettingDefaultTimezone.Name)
+		defaultTz, _ := r.repos.Settings.Get(location.OrganizationID, SettingDefaultTimezone.Name)
 		tz = defaultTz
 	}
 	return tz
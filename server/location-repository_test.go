@@ -10,9 +10,9 @@ func TestLocationsCount(t *testing.T) {
 		OrganizationID: org.ID,
 		Name:           "L1",
 	}
-	GetLocationRepository().Create(l1)
+	testRepositories.Location.Create(l1)
 
-	res, err := GetLocationRepository().GetCount(org.ID)
+	res, err := testRepositories.Location.GetCount(org.ID)
 	if err != nil {
 		t.Fatal(err)
 	}
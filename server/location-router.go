@@ -16,13 +16,26 @@ import (
 )
 
 type LocationRouter struct {
+	repos *Repositories
 }
 
+// BookingRestrictionRequest holds the user groups allowed or denied to book a location
+// or a space. The lists are replaced only if at least one of them is set, so clients
+// not aware of restrictions don't remove them on update.
+type BookingRestrictionRequest struct {
+	AllowedGroupIDs []string `json:"allowedGroupIds"`
+	DeniedGroupIDs  []string `json:"deniedGroupIds"`
+}
+
+// CreateLocationRequest replaces the approvers of bookings which require approval
+// only if ApproverIDs is set. Approvers must be space admins.
 type CreateLocationRequest struct {
-	Name                  string `json:"name" validate:"required"`
-	Description           string `json:"description"`
-	MaxConcurrentBookings uint   `json:"maxConcurrentBookings"`
-	Timezone              string `json:"timezone"`
+	Name                  string   `json:"name" validate:"required"`
+	Description           string   `json:"description"`
+	MaxConcurrentBookings uint     `json:"maxConcurrentBookings"`
+	Timezone              string   `json:"timezone"`
+	ApproverIDs           []string `json:"approverIds"`
+	BookingRestrictionRequest
 }
 
 type GetLocationResponse struct {
@@ -54,24 +67,29 @@ func (router *LocationRouter) setupRoutes(s *mux.Router) {
 
 func (router *LocationRouter) getOne(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	e, err := GetLocationRepository().GetOne(vars["id"])
+	e, err := router.repos.Location.GetOne(vars["id"])
 	if err != nil {
 		log.Println(err)
 		SendNotFound(w)
 		return
 	}
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanAccessOrg(user, e.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
 	res := router.copyToRestModel(e)
+	if err := router.addDetails(res); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
 	SendJSON(w, res)
 }
 
 func (router *LocationRouter) getAll(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
-	list, err := GetLocationRepository().GetAll(user.OrganizationID)
+	user := GetRequestUser(router.repos, r)
+	list, err := router.repos.Location.GetAll(user.OrganizationID)
 	if err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
@@ -80,6 +98,11 @@ func (router *LocationRouter) getAll(w http.ResponseWriter, r *http.Request) {
 	res := []*GetLocationResponse{}
 	for _, e := range list {
 		m := router.copyToRestModel(e)
+		if err := router.addDetails(m); err != nil {
+			log.Println(err)
+			SendInternalServerError(w)
+			return
+		}
 		res = append(res, m)
 	}
 	SendJSON(w, res)
@@ -92,12 +115,12 @@ func (router *LocationRouter) update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	vars := mux.Vars(r)
-	e, err := GetLocationRepository().GetOne(vars["id"])
+	e, err := router.repos.Location.GetOne(vars["id"])
 	if err != nil {
 		SendBadRequest(w)
 		return
 	}
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanSpaceAdminOrg(user, e.OrganizationID) {
 		SendForbidden(w)
 		return
@@ -108,30 +131,46 @@ func (router *LocationRouter) update(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	if !router.repos.BookingRestriction.IsValidGroupList(e.OrganizationID, m.AllowedGroupIDs, m.DeniedGroupIDs) ||
+		!router.repos.LocationApprover.IsValidApproverList(e.OrganizationID, m.ApproverIDs) {
+		SendBadRequest(w)
+		return
+	}
 	eNew := router.copyFromRestModel(&m)
 	eNew.ID = e.ID
 	eNew.OrganizationID = e.OrganizationID
-	if err := GetLocationRepository().Update(eNew); err != nil {
+	if err := router.repos.Location.Update(eNew); err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
 	}
+	if err := setBookingRestrictions(router.repos, eNew.ID, &m.BookingRestrictionRequest); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	if err := router.setApprovers(eNew.ID, m.ApproverIDs); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	fireWebhookEvent(router.repos, eNew.OrganizationID, WebhookEventLocationUpdated, router.copyToRestModel(eNew))
 	SendUpdated(w)
 }
 
 func (router *LocationRouter) delete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	e, err := GetLocationRepository().GetOne(vars["id"])
+	e, err := router.repos.Location.GetOne(vars["id"])
 	if err != nil {
 		SendNotFound(w)
 		return
 	}
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanSpaceAdminOrg(user, e.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
-	if err := GetLocationRepository().Delete(e); err != nil {
+	if err := router.repos.Location.Delete(e); err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
@@ -145,7 +184,7 @@ func (router *LocationRouter) create(w http.ResponseWriter, r *http.Request) {
 		SendBadRequest(w)
 		return
 	}
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	e := router.copyFromRestModel(&m)
 	e.OrganizationID = user.OrganizationID
 	if !CanSpaceAdminOrg(user, e.OrganizationID) {
@@ -158,7 +197,22 @@ func (router *LocationRouter) create(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	if err := GetLocationRepository().Create(e); err != nil {
+	if !router.repos.BookingRestriction.IsValidGroupList(e.OrganizationID, m.AllowedGroupIDs, m.DeniedGroupIDs) ||
+		!router.repos.LocationApprover.IsValidApproverList(e.OrganizationID, m.ApproverIDs) {
+		SendBadRequest(w)
+		return
+	}
+	if err := router.repos.Location.Create(e); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	if err := setBookingRestrictions(router.repos, e.ID, &m.BookingRestrictionRequest); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	if err := router.setApprovers(e.ID, m.ApproverIDs); err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
@@ -168,18 +222,18 @@ func (router *LocationRouter) create(w http.ResponseWriter, r *http.Request) {
 
 func (router *LocationRouter) getMap(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	e, err := GetLocationRepository().GetOne(vars["id"])
+	e, err := router.repos.Location.GetOne(vars["id"])
 	if err != nil {
 		log.Println(err)
 		SendNotFound(w)
 		return
 	}
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanAccessOrg(user, e.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
-	locationMap, err := GetLocationRepository().GetMap(e)
+	locationMap, err := router.repos.Location.GetMap(e)
 	if err != nil {
 		log.Println(err)
 		SendNotFound(w)
@@ -196,13 +250,13 @@ func (router *LocationRouter) getMap(w http.ResponseWriter, r *http.Request) {
 
 func (router *LocationRouter) setMap(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	e, err := GetLocationRepository().GetOne(vars["id"])
+	e, err := router.repos.Location.GetOne(vars["id"])
 	if err != nil {
 		log.Println(err)
 		SendNotFound(w)
 		return
 	}
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanSpaceAdminOrg(user, e.OrganizationID) {
 		SendForbidden(w)
 		return
@@ -225,7 +279,7 @@ func (router *LocationRouter) setMap(w http.ResponseWriter, r *http.Request) {
 		MimeType: format,
 		Data:     data,
 	}
-	if err := GetLocationRepository().SetMap(e, locationMap); err != nil {
+	if err := router.repos.Location.SetMap(e, locationMap); err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
@@ -234,17 +288,48 @@ func (router *LocationRouter) setMap(w http.ResponseWriter, r *http.Request) {
 }
 
 func (router *LocationRouter) loadSampleData(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanAdminOrg(user, user.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
-	org, err := GetOrganizationRepository().GetOne(user.OrganizationID)
+	org, err := router.repos.Organization.GetOne(user.OrganizationID)
 	if err != nil {
 		SendInternalServerError(w)
 		return
 	}
-	GetOrganizationRepository().createSampleData(org)
+	router.repos.Organization.createSampleData(org)
+}
+
+func (router *LocationRouter) addDetails(m *GetLocationResponse) error {
+	list, err := router.repos.BookingRestriction.GetAll(m.ID)
+	if err != nil {
+		return err
+	}
+	m.AllowedGroupIDs, m.DeniedGroupIDs = router.repos.BookingRestriction.Split(list)
+	if m.ApproverIDs, err = router.repos.LocationApprover.GetAll(m.ID); err != nil {
+		return err
+	}
+	if m.ApproverIDs == nil {
+		m.ApproverIDs = []string{}
+	}
+	return nil
+}
+
+func (router *LocationRouter) setApprovers(locationID string, userIDs []string) error {
+	if userIDs == nil {
+		return nil
+	}
+	return router.repos.LocationApprover.SetAll(locationID, userIDs)
+}
+
+// setBookingRestrictions replaces the restrictions of the location or space if the
+// request sets any of the lists.
+func setBookingRestrictions(repos *Repositories, targetID string, m *BookingRestrictionRequest) error {
+	if m.AllowedGroupIDs == nil && m.DeniedGroupIDs == nil {
+		return nil
+	}
+	return repos.BookingRestriction.SetAll(targetID, m.AllowedGroupIDs, m.DeniedGroupIDs)
 }
 
 func (router *LocationRouter) copyFromRestModel(m *CreateLocationRequest) *Location {
@@ -267,5 +352,7 @@ func (router *LocationRouter) copyToRestModel(e *Location) *GetLocationResponse
 	m.Description = e.Description
 	m.MaxConcurrentBookings = e.MaxConcurrentBookings
 	m.Timezone = e.Timezone
+	m.AllowedGroupIDs = []string{}
+	m.DeniedGroupIDs = []string{}
 	return m
 }
@@ -26,10 +26,14 @@ func GetProductVersion() string {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrateCommand(os.Args[2:]))
+	}
 	log.Println("Starting...")
 	log.Println("Seatsurfing Backend Version " + GetProductVersion())
 	db := GetDatabase()
 	a := GetApp()
+	a.Repositories = NewPostgresRepositories()
 	a.InitializeDatabases()
 	a.InitializeDefaultOrg()
 	a.InitializeRouter()
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memoryTables holds the rows of the in-memory repositories. Rows are kept in the order
+// of their creation and are never handed out directly, but copied.
+type memoryTables struct {
+	apiTokens                []*APIToken
+	auditLog                 []*AuditLogEntry
+	authAttempts             []*AuthAttempt
+	authProviders            []*AuthProvider
+	authProviderRoleMappings []*AuthProviderRoleMapping
+	authStates               []*AuthState
+	bookings                 []*memoryBooking
+	bookingAttendees         []*BookingAttendee
+	bookingRestrictions      []*BookingRestriction
+	bookingSeries            []*BookingSeries
+	buddies                  []*Buddy
+	debugTimeIssues          []*DebugTimeIssueItem
+	iCalTokens               []*ICalToken
+	locations                []*memoryLocation
+	locationApprovers        []*memoryLocationApprover
+	noShows                  []*NoShow
+	organizations            []*Organization
+	organizationDomains      []*Domain
+	refreshTokens            []*RefreshToken
+	scimTokens               []*SCIMToken
+	settings                 []*OrgSetting
+	signups                  []*Signup
+	spaces                   []*Space
+	spaceAttributes          []*SpaceAttribute
+	spaceAttributeValues     []*SpaceAttributeValue
+	subscriptionEvents       []*SubscriptionEvent
+	users                    []*User
+	userGroups               []*UserGroup
+	userGroupMembers         []*memoryUserGroupMember
+	userPreferences          []*UserPreference
+	userTOTP                 []*UserTOTP
+	userTOTPRecoveryCodes    []*memoryRecoveryCode
+	waitlistEntries          []*WaitlistEntry
+	webhooks                 []*Webhook
+	webhookDeliveries        []*WebhookDelivery
+}
+
+// All in-memory repositories share a single lock, so operations spanning multiple
+// tables, i.e. cascading deletes, are atomic. Methods acquiring the lock must not call
+// other repositories while holding it.
+var memoryLock sync.Mutex
+var memoryData memoryTables
+
+// ResetMemoryRepositories removes all data of the in-memory repositories.
+func ResetMemoryRepositories() {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData = memoryTables{}
+}
+
+func memoryNewID() string {
+	return uuid.New().String()
+}
+
+// memoryCheckUUID returns an error if one of the values is not a valid UUID, like
+// PostgreSQL does when storing it in a uuid column.
+func memoryCheckUUID(values ...string) error {
+	for _, value := range values {
+		if _, err := uuid.Parse(value); err != nil {
+			return errors.New("invalid input syntax for type uuid: \"" + value + "\"")
+		}
+	}
+	return nil
+}
+
+func memoryUniqueViolation(constraint string) error {
+	return errors.New("duplicate key value violates unique constraint \"" + constraint + "\"")
+}
+
+// memoryTimestamp returns the time as read back from a TIMESTAMP column: the wall
+// clock time in UTC with a precision of microseconds.
+func memoryTimestamp(t time.Time) time.Time {
+	t = t.Round(time.Microsecond)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+func memoryNullTimestamp(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	res := memoryTimestamp(*t)
+	return &res
+}
+
+// memoryFind returns the first row matching the condition or sql.ErrNoRows.
+func memoryFind[T any](rows []*T, cond func(*T) bool) (*T, error) {
+	for _, e := range rows {
+		if cond(e) {
+			return e, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// memoryGet returns a copy of the first row matching the condition or sql.ErrNoRows.
+func memoryGet[T any](rows []*T, cond func(*T) bool) (*T, error) {
+	e, err := memoryFind(rows, cond)
+	if err != nil {
+		return nil, err
+	}
+	c := *e
+	return &c, nil
+}
+
+func memoryFilter[T any](rows []*T, cond func(*T) bool) []*T {
+	var res []*T
+	for _, e := range rows {
+		if cond(e) {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+func memoryCount[T any](rows []*T, cond func(*T) bool) int {
+	res := 0
+	for _, e := range rows {
+		if cond(e) {
+			res++
+		}
+	}
+	return res
+}
+
+// memoryDelete returns the rows not matching the condition.
+func memoryDelete[T any](rows []*T, cond func(*T) bool) []*T {
+	res := make([]*T, 0, len(rows))
+	for _, e := range rows {
+		if !cond(e) {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// memoryCopy returns copies of the rows, which can be modified by the caller.
+func memoryCopy[T any](rows []*T) []*T {
+	var res []*T
+	for _, e := range rows {
+		c := *e
+		res = append(res, &c)
+	}
+	return res
+}
+
+func memorySort[T any](rows []*T, less func(a, b *T) bool) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		return less(rows[i], rows[j])
+	})
+}
+
+// memoryPage returns the rows as limited by LIMIT and OFFSET.
+func memoryPage[T any](rows []*T, maxResults, offset int) []*T {
+	if offset >= len(rows) {
+		return nil
+	}
+	rows = rows[offset:]
+	if maxResults < len(rows) {
+		rows = rows[:maxResults]
+	}
+	return rows
+}
+
+// memoryCompare compares strings case-insensitively, similar to the collation of the database.
+func memoryCompare(a, b string) int {
+	if res := strings.Compare(strings.ToLower(a), strings.ToLower(b)); res != 0 {
+		return res
+	}
+	return strings.Compare(a, b)
+}
+
+// memoryContains returns true if s contains the keyword, like LOWER(s) LIKE '%' || keyword || '%'.
+func memoryContains(s, keyword string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(keyword))
+}
+
+func memoryContainsID(ids []string, id string) bool {
+	for _, e := range ids {
+		if e == id {
+			return true
+		}
+	}
+	return false
+}
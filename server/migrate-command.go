@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+const migrateCommandUsage string = `Usage: server migrate <command> [version]
+
+Commands:
+  status             List all migrations and whether they have been applied
+  up [version]       Apply the pending migrations up to the version, by default all
+  down [version]     Revert the applied migrations above the version, by default the latest one
+  dry-run [version]  Print the statements up would execute without executing them
+`
+
+// runMigrateCommand runs the migrate command of the server binary and returns its exit code.
+// It allows operators to migrate the database separately from starting the server.
+func runMigrateCommand(args []string) int {
+	if len(args) < 1 || len(args) > 2 {
+		fmt.Fprint(os.Stderr, migrateCommandUsage)
+		return 2
+	}
+	command := args[0]
+	if command != "status" && command != "up" && command != "down" && command != "dry-run" {
+		fmt.Fprint(os.Stderr, migrateCommandUsage)
+		return 2
+	}
+	version := -1
+	if len(args) == 2 {
+		i, err := strconv.Atoi(args[1])
+		if err != nil || i < 0 || command == "status" {
+			fmt.Fprint(os.Stderr, migrateCommandUsage)
+			return 2
+		}
+		version = i
+	}
+	db := GetDatabase()
+	defer db.Close()
+	migrator, err := GetMigrator(NewPostgresRepositories())
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	switch command {
+	case "status":
+		err = printMigrationStatus(os.Stdout, migrator)
+	case "up":
+		if version == -1 {
+			version = migrator.GetLatestVersion()
+		}
+		var list []*Migration
+		list, err = migrator.Up(version)
+		fmt.Fprintf(os.Stdout, "Applied %d migrations.\n", len(list))
+	case "down":
+		if version == -1 {
+			version, err = getPreviousMigrationVersion(migrator)
+			if err != nil {
+				break
+			}
+		}
+		var list []*Migration
+		list, err = migrator.Down(version)
+		fmt.Fprintf(os.Stdout, "Reverted %d migrations.\n", len(list))
+	case "dry-run":
+		if version == -1 {
+			version = migrator.GetLatestVersion()
+		}
+		var list []*Migration
+		if list, err = migrator.GetPending(version); err == nil {
+			printMigrationScript(os.Stdout, list)
+		}
+	}
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	return 0
+}
+
+func printMigrationStatus(w io.Writer, migrator *Migrator) error {
+	list, err := migrator.GetStatus()
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tAPPLIED\tREVERSIBLE\tDESCRIPTION")
+	for _, e := range list {
+		applied := "pending"
+		if e.Applied != nil {
+			applied = e.Applied.Format(JsDateTimeFormat)
+		}
+		reversible := "no"
+		if len(e.Down) > 0 {
+			reversible = "yes"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", e.Version, applied, reversible, e.Description)
+	}
+	return tw.Flush()
+}
+
+// printMigrationScript prints the statements of the migrations as an SQL script.
+func printMigrationScript(w io.Writer, list []*Migration) {
+	if len(list) == 0 {
+		fmt.Fprintln(w, "-- No pending migrations")
+	}
+	for _, e := range list {
+		fmt.Fprintf(w, "-- Migration %d: %s\n", e.Version, e.Description)
+		fmt.Fprintln(w, "BEGIN;")
+		for _, stmt := range e.Up {
+			fmt.Fprintln(w, stmt+";")
+		}
+		fmt.Fprintln(w, "COMMIT;")
+	}
+}
+
+// getPreviousMigrationVersion returns the version of the applied migration before the
+// latest applied one, or 0 if there is none.
+func getPreviousMigrationVersion(migrator *Migrator) (int, error) {
+	list, err := migrator.GetStatus()
+	if err != nil {
+		return 0, err
+	}
+	var applied []int
+	for _, e := range list {
+		if e.Applied != nil {
+			applied = append(applied, e.Version)
+		}
+	}
+	if len(applied) < 2 {
+		return 0, nil
+	}
+	return applied[len(applied)-2], nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import "time"
+
+type MemoryNoShowRepository struct {
+}
+
+func (r *MemoryNoShowRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryNoShowRepository) Create(e *NoShow) error {
+	if err := memoryCheckUUID(e.UserID, e.SpaceID, e.BookingID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e.ID = memoryNewID()
+	row := *e
+	row.Enter = memoryTimestamp(e.Enter)
+	row.Leave = memoryTimestamp(e.Leave)
+	row.Released = memoryTimestamp(e.Released)
+	memoryData.noShows = append(memoryData.noShows, &row)
+	return nil
+}
+
+func (r *MemoryNoShowRepository) GetAllByOrg(organizationID string, location *Location, start, end time.Time) ([]*NoShowDetails, error) {
+	start, end = memoryTimestamp(start), memoryTimestamp(end)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	var result []*NoShowDetails
+	for _, e := range memoryData.noShows {
+		if e.Enter.Before(start) || e.Enter.After(end) {
+			continue
+		}
+		space := memorySpaceDetails(e.SpaceID)
+		user := memoryUserByID(e.UserID)
+		if space == nil || user == nil || space.Location.OrganizationID != organizationID {
+			continue
+		}
+		if location != nil && space.Location.ID != location.ID {
+			continue
+		}
+		result = append(result, &NoShowDetails{
+			Space:     *space,
+			UserEmail: user.Email,
+			NoShow:    *e,
+		})
+	}
+	memorySort(result, func(a, b *NoShowDetails) bool {
+		return a.Enter.Before(b.Enter)
+	})
+	return result, nil
+}
+
+func (r *MemoryNoShowRepository) DeleteOfUser(u *User) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.noShows = memoryDelete(memoryData.noShows, func(row *NoShow) bool {
+		return row.UserID == u.ID
+	})
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import "time"
+
+type NoShowRepository interface {
+	Repository
+	Create(e *NoShow) error
+	GetAllByOrg(organizationID string, location *Location, start, end time.Time) ([]*NoShowDetails, error)
+	DeleteOfUser(u *User) error
+}
+
+type PostgresNoShowRepository struct {
+}
+
+// NoShow records a booking which has been released because the user did not check in.
+// Enter, Leave and Released are local wall clock times of the location.
+type NoShow struct {
+	ID        string
+	UserID    string
+	SpaceID   string
+	BookingID string
+	Enter     time.Time
+	Leave     time.Time
+	Released  time.Time
+}
+
+type NoShowDetails struct {
+	Space     SpaceDetails
+	UserEmail string
+	NoShow
+}
+
+func (r *PostgresNoShowRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		{
+			Version:     17,
+			Description: "Add no-shows",
+			Up: []string{
+				"CREATE TABLE IF NOT EXISTS no_shows (" +
+					"id uuid DEFAULT uuid_generate_v4(), " +
+					"user_id uuid NOT NULL, " +
+					"space_id uuid NOT NULL, " +
+					"booking_id uuid NOT NULL, " +
+					"enter_time TIMESTAMP NOT NULL, " +
+					"leave_time TIMESTAMP NOT NULL, " +
+					"released TIMESTAMP NOT NULL, " +
+					"PRIMARY KEY (id))",
+				"CREATE INDEX IF NOT EXISTS idx_no_shows_user_id ON no_shows(user_id)",
+			},
+			Down: []string{"DROP TABLE IF EXISTS no_shows"},
+		},
+	}
+}
+
+func (r *PostgresNoShowRepository) Create(e *NoShow) error {
+	var id string
+	err := GetDatabase().DB().QueryRow("INSERT INTO no_shows "+
+		"(user_id, space_id, booking_id, enter_time, leave_time, released) "+
+		"VALUES ($1, $2, $3, $4, $5, $6) "+
+		"RETURNING id",
+		e.UserID, e.SpaceID, e.BookingID, e.Enter, e.Leave, e.Released).Scan(&id)
+	if err != nil {
+		return err
+	}
+	e.ID = id
+	return nil
+}
+
+// GetAllByOrg returns all no-shows of the organization (optionally limited to one location)
+// which started within the specified range.
+func (r *PostgresNoShowRepository) GetAllByOrg(organizationID string, location *Location, start, end time.Time) ([]*NoShowDetails, error) {
+	var result []*NoShowDetails
+	locationID := ""
+	if location != nil {
+		locationID = location.ID
+	}
+	rows, err := GetDatabase().DB().Query("SELECT no_shows.id, no_shows.user_id, no_shows.space_id, no_shows.booking_id, no_shows.enter_time, no_shows.leave_time, no_shows.released, "+
+		"spaces.id, spaces.location_id, spaces.name, "+
+		"locations.id, locations.organization_id, locations.name, locations.description, locations.tz, "+
+		"users.email "+
+		"FROM no_shows "+
+		"INNER JOIN spaces ON no_shows.space_id = spaces.id "+
+		"INNER JOIN locations ON spaces.location_id = locations.id "+
+		"INNER JOIN users ON no_shows.user_id = users.id "+
+		"WHERE locations.organization_id = $1 AND ($2 = '' OR locations.id::text = $2) AND "+
+		"no_shows.enter_time >= $3 AND no_shows.enter_time <= $4 "+
+		"ORDER BY no_shows.enter_time", organizationID, locationID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &NoShowDetails{}
+		err = rows.Scan(&e.ID, &e.UserID, &e.SpaceID, &e.BookingID, &e.Enter, &e.Leave, &e.Released, &e.Space.ID, &e.Space.LocationID, &e.Space.Name, &e.Space.Location.ID, &e.Space.Location.OrganizationID, &e.Space.Location.Name, &e.Space.Location.Description, &e.Space.Location.Timezone, &e.UserEmail)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (r *PostgresNoShowRepository) DeleteOfUser(u *User) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM no_shows WHERE user_id = $1", u.ID)
+	return err
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// OIDCProviderCacheDuration is how long an issuer's discovery document is cached.
+const OIDCProviderCacheDuration time.Duration = time.Hour
+
+// OIDCDefaultScopes are used for OpenID Connect providers configured without scopes.
+const OIDCDefaultScopes string = "openid,email,profile"
+
+type oidcProviderCacheItem struct {
+	provider *oidc.Provider
+	created  time.Time
+}
+
+var oidcProviderCache = make(map[string]*oidcProviderCacheItem)
+var oidcProviderCacheLock sync.Mutex
+
+// getOIDCProvider returns the OpenID Connect provider of the issuer, reading its
+// .well-known/openid-configuration on first use. The provider fetches and caches
+// the issuer's JWKS signing keys itself when ID tokens are verified, using the
+// same restricted client as the discovery.
+func getOIDCProvider(issuerURL string) (*oidc.Provider, error) {
+	oidcProviderCacheLock.Lock()
+	defer oidcProviderCacheLock.Unlock()
+	if item, ok := oidcProviderCache[issuerURL]; ok && time.Since(item.created) < OIDCProviderCacheDuration {
+		return item.provider, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), AuthProviderRequestTimeout)
+	defer cancel()
+	ctx = oidc.ClientContext(ctx, getAuthProviderHTTPClient())
+	provider, err := oidc.NewProvider(ctx, issuerURL)
+	if err != nil {
+		return nil, err
+	}
+	oidcProviderCache[issuerURL] = &oidcProviderCacheItem{
+		provider: provider,
+		created:  time.Now(),
+	}
+	return provider, nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type MemoryOrganizationRepository struct {
+	organizationRepositoryBase
+}
+
+func (r *MemoryOrganizationRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryOrganizationRepository) Create(e *Organization) error {
+	memoryLock.Lock()
+	e.ID = memoryNewID()
+	row := *e
+	row.SignupDate = memoryTimestamp(e.SignupDate)
+	memoryData.organizations = append(memoryData.organizations, &row)
+	memoryLock.Unlock()
+	r.repos.Settings.InitDefaultSettingsForOrg(e.ID)
+	return nil
+}
+
+func (r *MemoryOrganizationRepository) GetOneByDomain(domain string) (*Organization, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	for _, d := range memoryData.organizationDomains {
+		if !d.Active || strings.ToLower(d.DomainName) != strings.ToLower(domain) {
+			continue
+		}
+		e, err := memoryGet(memoryData.organizations, func(e *Organization) bool {
+			return e.ID == d.OrganizationID
+		})
+		if err == nil {
+			return e, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *MemoryOrganizationRepository) GetOne(id string) (*Organization, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryGet(memoryData.organizations, func(e *Organization) bool {
+		return e.ID == id
+	})
+}
+
+func (r *MemoryOrganizationRepository) GetByEmail(email string) (*Organization, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryGet(memoryData.organizations, func(e *Organization) bool {
+		return strings.ToLower(e.ContactEmail) == strings.ToLower(email)
+	})
+}
+
+func (r *MemoryOrganizationRepository) GetAll() ([]*Organization, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	result := memoryCopy(memoryData.organizations)
+	memorySort(result, func(a, b *Organization) bool {
+		return memoryCompare(a.Name, b.Name) < 0
+	})
+	return result, nil
+}
+
+func (r *MemoryOrganizationRepository) GetNumOrgs() (int, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return len(memoryData.organizations), nil
+}
+
+func (r *MemoryOrganizationRepository) GetAllIDs() ([]string, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	var result []string
+	for _, e := range memoryData.organizations {
+		result = append(result, e.ID)
+	}
+	return result, nil
+}
+
+func (r *MemoryOrganizationRepository) Update(e *Organization) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	row, err := memoryFind(memoryData.organizations, func(row *Organization) bool {
+		return row.ID == e.ID
+	})
+	if err != nil {
+		return nil
+	}
+	*row = *e
+	row.SignupDate = memoryTimestamp(e.SignupDate)
+	return nil
+}
+
+func (r *MemoryOrganizationRepository) Delete(e *Organization) error {
+	if err := r.repos.AuthProvider.DeleteAll(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.SpaceAttribute.DeleteAll(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.Location.DeleteAll(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.Settings.DeleteAll(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.Webhook.DeleteAll(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.AuditLog.DeleteAll(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.SCIMToken.DeleteAll(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.APIToken.DeleteAll(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.UserGroup.DeleteAll(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.UserTOTP.DeleteAll(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.User.DeleteAll(e.ID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.organizationDomains = memoryDelete(memoryData.organizationDomains, func(row *Domain) bool {
+		return row.OrganizationID == e.ID
+	})
+	memoryData.organizations = memoryDelete(memoryData.organizations, func(row *Organization) bool {
+		return row.ID == e.ID
+	})
+	return nil
+}
+
+func (r *MemoryOrganizationRepository) GetDomain(org *Organization, domain string) (*Domain, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryGet(memoryData.organizationDomains, func(e *Domain) bool {
+		return e.DomainName == strings.ToLower(domain) && e.OrganizationID == org.ID
+	})
+}
+
+func (r *MemoryOrganizationRepository) AddDomain(e *Organization, domain string, active bool) error {
+	if err := memoryCheckUUID(e.ID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.organizationDomains = append(memoryData.organizationDomains, &Domain{
+		DomainName:     strings.ToLower(domain),
+		OrganizationID: e.ID,
+		Active:         active,
+		VerifyToken:    uuid.New().String(),
+	})
+	return nil
+}
+
+func (r *MemoryOrganizationRepository) RemoveDomain(e *Organization, domain string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.organizationDomains = memoryDelete(memoryData.organizationDomains, func(row *Domain) bool {
+		return row.DomainName == strings.ToLower(domain) && row.OrganizationID == e.ID
+	})
+	return nil
+}
+
+func (r *MemoryOrganizationRepository) ActivateDomain(e *Organization, domain string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	for _, row := range memoryData.organizationDomains {
+		if row.DomainName == strings.ToLower(domain) && row.OrganizationID == e.ID {
+			row.Active = true
+		}
+	}
+	return nil
+}
+
+func (r *MemoryOrganizationRepository) GetDomains(e *Organization) ([]*Domain, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	result := memoryCopy(memoryFilter(memoryData.organizationDomains, func(row *Domain) bool {
+		return row.OrganizationID == e.ID
+	}))
+	memorySort(result, func(a, b *Domain) bool {
+		return memoryCompare(a.DomainName, b.DomainName) < 0
+	})
+	return result, nil
+}
@@ -4,13 +4,38 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-type OrganizationRepository struct {
+type OrganizationRepository interface {
+	Repository
+	Create(e *Organization) error
+	GetOneByDomain(domain string) (*Organization, error)
+	GetOne(id string) (*Organization, error)
+	GetByEmail(email string) (*Organization, error)
+	GetAll() ([]*Organization, error)
+	GetNumOrgs() (int, error)
+	GetAllIDs() ([]string, error)
+	Update(e *Organization) error
+	Delete(e *Organization) error
+	GetDomain(org *Organization, domain string) (*Domain, error)
+	AddDomain(e *Organization, domain string, active bool) error
+	RemoveDomain(e *Organization, domain string) error
+	ActivateDomain(e *Organization, domain string) error
+	GetDomains(e *Organization) ([]*Domain, error)
+	isValidEmailForOrg(email string, org *Organization) bool
+	createSampleData(org *Organization) error
+}
+
+type PostgresOrganizationRepository struct {
+	organizationRepositoryBase
+}
+
+// organizationRepositoryBase is built on top of the other repositories only, so both implementations share it.
+type organizationRepositoryBase struct {
+	repos *Repositories
 }
 
 type Organization struct {
@@ -30,88 +55,87 @@ type Domain struct {
 	VerifyToken    string
 }
 
-var organizationRepository *OrganizationRepository
-var organizationRepositoryOnce sync.Once
-
-func GetOrganizationRepository() *OrganizationRepository {
-	organizationRepositoryOnce.Do(func() {
-		organizationRepository = &OrganizationRepository{}
-		_, err := GetDatabase().DB().Exec("CREATE TABLE IF NOT EXISTS organizations (" +
-			"id uuid DEFAULT uuid_generate_v4(), " +
-			"name VARCHAR NOT NULL, " +
-			"PRIMARY KEY (id))")
-		if err != nil {
-			panic(err)
-		}
-		_, err = GetDatabase().DB().Exec("CREATE TABLE IF NOT EXISTS organizations_domains (" +
-			"domain VARCHAR NOT NULL, " +
-			"organization_id uuid NOT NULL, " +
-			"PRIMARY KEY (domain))")
-		if err != nil {
-			panic(err)
-		}
-		_, err = GetDatabase().DB().Exec("CREATE INDEX IF NOT EXISTS idx_organizations_domains_organization_id ON organizations_domains(organization_id)")
-		if err != nil {
-			panic(err)
-		}
-	})
-	return organizationRepository
-}
-
-func (r *OrganizationRepository) RunSchemaUpgrade(curVersion, targetVersion int) {
-	if curVersion < 3 {
-		if _, err := GetDatabase().DB().Exec("ALTER TABLE organizations " +
-			"ADD COLUMN contact_firstname VARCHAR, " +
-			"ADD COLUMN contact_lastname VARCHAR, " +
-			"ADD COLUMN contact_email VARCHAR"); err != nil {
-			panic(err)
-		}
-	}
-	if curVersion < 4 {
-		if _, err := GetDatabase().DB().Exec("ALTER TABLE organizations_domains " +
-			"ADD COLUMN active boolean NOT NULL DEFAULT FALSE, " +
-			"ADD COLUMN verify_token uuid"); err != nil {
-			panic(err)
-		}
-	}
-	if curVersion < 5 {
-		if _, err := GetDatabase().DB().Exec("ALTER TABLE organizations_domains " +
-			"DROP CONSTRAINT organizations_domains_pkey"); err != nil {
-			panic(err)
-		}
-		if _, err := GetDatabase().DB().Exec("CREATE INDEX IF NOT EXISTS idx_organizations_domains_domain ON organizations_domains(domain)"); err != nil {
-			panic(err)
-		}
-	}
-	if curVersion < 6 {
-		if _, err := GetDatabase().DB().Exec("ALTER TABLE organizations " +
-			"ADD COLUMN country VARCHAR, " +
-			"ADD COLUMN language VARCHAR"); err != nil {
-			panic(err)
-		}
-		if _, err := GetDatabase().DB().Exec("UPDATE organizations SET country = 'DE', language = 'de'"); err != nil {
-			panic(err)
-		}
-	}
-	if curVersion < 8 {
-		if _, err := GetDatabase().DB().Exec("ALTER TABLE organizations " +
-			"ADD COLUMN signup_date TIMESTAMP NOT NULL DEFAULT '2021-03-28 16:00:00'"); err != nil {
-			panic(err)
-		}
-		if _, err := GetDatabase().DB().Exec("ALTER TABLE organizations " +
-			"ALTER COLUMN signup_date DROP DEFAULT"); err != nil {
-			panic(err)
-		}
-	}
-	if curVersion < 15 {
-		if _, err := GetDatabase().DB().Exec("ALTER TABLE organizations " +
-			"DROP COLUMN country"); err != nil {
-			panic(err)
-		}
+func (r *PostgresOrganizationRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		newInitialSchemaMigration(
+			"CREATE TABLE IF NOT EXISTS organizations ("+
+				"id uuid DEFAULT uuid_generate_v4(), "+
+				"name VARCHAR NOT NULL, "+
+				"PRIMARY KEY (id))",
+			"CREATE TABLE IF NOT EXISTS organizations_domains ("+
+				"domain VARCHAR NOT NULL, "+
+				"organization_id uuid NOT NULL, "+
+				"PRIMARY KEY (domain))",
+			"CREATE INDEX IF NOT EXISTS idx_organizations_domains_organization_id ON organizations_domains(organization_id)",
+		),
+		{
+			Version:     3,
+			Description: "Add contact to organizations",
+			Up: []string{"ALTER TABLE organizations " +
+				"ADD COLUMN contact_firstname VARCHAR, " +
+				"ADD COLUMN contact_lastname VARCHAR, " +
+				"ADD COLUMN contact_email VARCHAR"},
+			Down: []string{"ALTER TABLE organizations " +
+				"DROP COLUMN contact_firstname, " +
+				"DROP COLUMN contact_lastname, " +
+				"DROP COLUMN contact_email"},
+		},
+		{
+			Version:     4,
+			Description: "Add verification to organization domains",
+			Up: []string{"ALTER TABLE organizations_domains " +
+				"ADD COLUMN active boolean NOT NULL DEFAULT FALSE, " +
+				"ADD COLUMN verify_token uuid"},
+			Down: []string{"ALTER TABLE organizations_domains " +
+				"DROP COLUMN active, " +
+				"DROP COLUMN verify_token"},
+		},
+		{
+			// Domains added to multiple organizations since then would violate the primary key
+			Version:     5,
+			Description: "Allow adding a domain to multiple organizations",
+			Up: []string{
+				"ALTER TABLE organizations_domains " +
+					"DROP CONSTRAINT organizations_domains_pkey",
+				"CREATE INDEX IF NOT EXISTS idx_organizations_domains_domain ON organizations_domains(domain)",
+			},
+		},
+		{
+			Version:     6,
+			Description: "Add country and language to organizations",
+			Up: []string{
+				"ALTER TABLE organizations " +
+					"ADD COLUMN country VARCHAR, " +
+					"ADD COLUMN language VARCHAR",
+				"UPDATE organizations SET country = 'DE', language = 'de'",
+			},
+			Down: []string{"ALTER TABLE organizations " +
+				"DROP COLUMN country, " +
+				"DROP COLUMN language"},
+		},
+		{
+			Version:     8,
+			Description: "Add signup date to organizations",
+			Up: []string{
+				"ALTER TABLE organizations " +
+					"ADD COLUMN signup_date TIMESTAMP NOT NULL DEFAULT '2021-03-28 16:00:00'",
+				"ALTER TABLE organizations " +
+					"ALTER COLUMN signup_date DROP DEFAULT",
+			},
+			Down: []string{"ALTER TABLE organizations " +
+				"DROP COLUMN signup_date"},
+		},
+		{
+			// The dropped countries can't be restored
+			Version:     15,
+			Description: "Remove country from organizations",
+			Up: []string{"ALTER TABLE organizations " +
+				"DROP COLUMN country"},
+		},
 	}
 }
 
-func (r *OrganizationRepository) Create(e *Organization) error {
+func (r *PostgresOrganizationRepository) Create(e *Organization) error {
 	var id string
 	err := GetDatabase().DB().QueryRow("INSERT INTO organizations "+
 		"(name, contact_firstname, contact_lastname, contact_email, language, signup_date) "+
@@ -122,11 +146,11 @@ func (r *OrganizationRepository) Create(e *Organization) error {
 		return err
 	}
 	e.ID = id
-	GetSettingsRepository().InitDefaultSettingsForOrg(e.ID)
+	r.repos.Settings.InitDefaultSettingsForOrg(e.ID)
 	return nil
 }
 
-func (r *OrganizationRepository) GetOneByDomain(domain string) (*Organization, error) {
+func (r *PostgresOrganizationRepository) GetOneByDomain(domain string) (*Organization, error) {
 	e := &Organization{}
 	err := GetDatabase().DB().QueryRow("SELECT organizations.id, organizations.name, organizations.contact_firstname, organizations.contact_lastname, organizations.contact_email, organizations.language, organizations.signup_date "+
 		"FROM organizations_domains "+
@@ -139,7 +163,7 @@ func (r *OrganizationRepository) GetOneByDomain(domain string) (*Organization, e
 	return e, nil
 }
 
-func (r *OrganizationRepository) GetOne(id string) (*Organization, error) {
+func (r *PostgresOrganizationRepository) GetOne(id string) (*Organization, error) {
 	e := &Organization{}
 	err := GetDatabase().DB().QueryRow("SELECT id, name, contact_firstname, contact_lastname, contact_email, language, signup_date "+
 		"FROM organizations "+
@@ -151,7 +175,7 @@ func (r *OrganizationRepository) GetOne(id string) (*Organization, error) {
 	return e, nil
 }
 
-func (r *OrganizationRepository) GetByEmail(email string) (*Organization, error) {
+func (r *PostgresOrganizationRepository) GetByEmail(email string) (*Organization, error) {
 	e := &Organization{}
 	err := GetDatabase().DB().QueryRow("SELECT id, name, contact_firstname, contact_lastname, contact_email, language, signup_date "+
 		"FROM organizations "+
@@ -163,7 +187,7 @@ func (r *OrganizationRepository) GetByEmail(email string) (*Organization, error)
 	return e, nil
 }
 
-func (r *OrganizationRepository) GetAll() ([]*Organization, error) {
+func (r *PostgresOrganizationRepository) GetAll() ([]*Organization, error) {
 	var result []*Organization
 	rows, err := GetDatabase().DB().Query("SELECT id, name, contact_firstname, contact_lastname, contact_email, language, signup_date " +
 		"FROM organizations ORDER BY name")
@@ -182,7 +206,7 @@ func (r *OrganizationRepository) GetAll() ([]*Organization, error) {
 	return result, nil
 }
 
-func (r *OrganizationRepository) GetNumOrgs() (int, error) {
+func (r *PostgresOrganizationRepository) GetNumOrgs() (int, error) {
 	var result int
 	err := GetDatabase().DB().QueryRow("SELECT COUNT(*) FROM organizations").Scan(&result)
 	if err != nil {
@@ -191,7 +215,7 @@ func (r *OrganizationRepository) GetNumOrgs() (int, error) {
 	return result, nil
 }
 
-func (r *OrganizationRepository) GetAllIDs() ([]string, error) {
+func (r *PostgresOrganizationRepository) GetAllIDs() ([]string, error) {
 	var result []string
 	rows, err := GetDatabase().DB().Query("SELECT id " +
 		"FROM organizations")
@@ -210,7 +234,7 @@ func (r *OrganizationRepository) GetAllIDs() ([]string, error) {
 	return result, nil
 }
 
-func (r *OrganizationRepository) Update(e *Organization) error {
+func (r *PostgresOrganizationRepository) Update(e *Organization) error {
 	_, err := GetDatabase().DB().Exec("UPDATE organizations SET "+
 		"name = $1, contact_firstname = $2, contact_lastname = $3, contact_email = $4, language = $5, signup_date = $6 "+
 		"WHERE id = $7",
@@ -218,17 +242,38 @@ func (r *OrganizationRepository) Update(e *Organization) error {
 	return err
 }
 
-func (r *OrganizationRepository) Delete(e *Organization) error {
-	if err := GetAuthProviderRepository().DeleteAll(e.ID); err != nil {
+func (r *PostgresOrganizationRepository) Delete(e *Organization) error {
+	if err := r.repos.AuthProvider.DeleteAll(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.SpaceAttribute.DeleteAll(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.Location.DeleteAll(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.Settings.DeleteAll(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.Webhook.DeleteAll(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.AuditLog.DeleteAll(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.SCIMToken.DeleteAll(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.APIToken.DeleteAll(e.ID); err != nil {
 		return err
 	}
-	if err := GetLocationRepository().DeleteAll(e.ID); err != nil {
+	if err := r.repos.UserGroup.DeleteAll(e.ID); err != nil {
 		return err
 	}
-	if err := GetSettingsRepository().DeleteAll(e.ID); err != nil {
+	if err := r.repos.UserTOTP.DeleteAll(e.ID); err != nil {
 		return err
 	}
-	if err := GetUserRepository().DeleteAll(e.ID); err != nil {
+	if err := r.repos.User.DeleteAll(e.ID); err != nil {
 		return err
 	}
 	_, err := GetDatabase().DB().Exec("DELETE FROM organizations_domains WHERE organization_id = $1", e.ID)
@@ -239,7 +284,7 @@ func (r *OrganizationRepository) Delete(e *Organization) error {
 	return err
 }
 
-func (r *OrganizationRepository) GetDomain(org *Organization, domain string) (*Domain, error) {
+func (r *PostgresOrganizationRepository) GetDomain(org *Organization, domain string) (*Domain, error) {
 	e := &Domain{}
 	err := GetDatabase().DB().QueryRow("SELECT domain, organization_id, active, verify_token "+
 		"FROM organizations_domains "+
@@ -251,7 +296,7 @@ func (r *OrganizationRepository) GetDomain(org *Organization, domain string) (*D
 	return e, nil
 }
 
-func (r *OrganizationRepository) AddDomain(e *Organization, domain string, active bool) error {
+func (r *PostgresOrganizationRepository) AddDomain(e *Organization, domain string, active bool) error {
 	verifyToken := uuid.New().String()
 	_, err := GetDatabase().DB().Exec("INSERT INTO organizations_domains "+
 		"(domain, organization_id, active, verify_token) "+
@@ -260,14 +305,14 @@ func (r *OrganizationRepository) AddDomain(e *Organization, domain string, activ
 	return err
 }
 
-func (r *OrganizationRepository) RemoveDomain(e *Organization, domain string) error {
+func (r *PostgresOrganizationRepository) RemoveDomain(e *Organization, domain string) error {
 	_, err := GetDatabase().DB().Exec("DELETE FROM organizations_domains "+
 		"WHERE domain = LOWER($1) AND organization_id = $2",
 		strings.ToLower(domain), e.ID)
 	return err
 }
 
-func (r *OrganizationRepository) ActivateDomain(e *Organization, domain string) error {
+func (r *PostgresOrganizationRepository) ActivateDomain(e *Organization, domain string) error {
 	_, err := GetDatabase().DB().Exec("UPDATE organizations_domains "+
 		"SET active = TRUE "+
 		"WHERE domain = LOWER($1) AND organization_id = $2",
@@ -275,7 +320,7 @@ func (r *OrganizationRepository) ActivateDomain(e *Organization, domain string)
 	return err
 }
 
-func (r *OrganizationRepository) GetDomains(e *Organization) ([]*Domain, error) {
+func (r *PostgresOrganizationRepository) GetDomains(e *Organization) ([]*Domain, error) {
 	var result []*Domain
 	rows, err := GetDatabase().DB().Query("SELECT domain, organization_id, active, verify_token "+
 		"FROM organizations_domains "+
@@ -297,13 +342,13 @@ func (r *OrganizationRepository) GetDomains(e *Organization) ([]*Domain, error)
 	return result, nil
 }
 
-func (r *OrganizationRepository) isValidEmailForOrg(email string, org *Organization) bool {
+func (r *organizationRepositoryBase) isValidEmailForOrg(email string, org *Organization) bool {
 	mailParts := strings.Split(email, "@")
 	if len(mailParts) != 2 {
 		return false
 	}
 	domain := strings.ToLower(mailParts[1])
-	domains, err := GetOrganizationRepository().GetDomains(org)
+	domains, err := r.repos.Organization.GetDomains(org)
 	if err != nil {
 		return false
 	}
@@ -318,13 +363,13 @@ func (r *OrganizationRepository) isValidEmailForOrg(email string, org *Organizat
 
 }
 
-func (r *OrganizationRepository) createSampleData(org *Organization) error {
+func (r *organizationRepositoryBase) createSampleData(org *Organization) error {
 	location := &Location{
 		OrganizationID: org.ID,
 		Name:           "Sample Floor",
 		Description:    "Sample Map provided by Marco Garbelini under the Creative Commons Attribution 2.0 Generic (CC BY 2.0) License: https://www.flickr.com/photos/garbelini/300134781",
 	}
-	if err := GetLocationRepository().Create(location); err != nil {
+	if err := r.repos.Location.Create(location); err != nil {
 		return err
 	}
 	mapFile, _ := filepath.Abs("./res/floorplan.jpg")
@@ -338,7 +383,7 @@ func (r *OrganizationRepository) createSampleData(org *Organization) error {
 		Height:   802,
 		Data:     mapData,
 	}
-	if err := GetLocationRepository().SetMap(location, locationMap); err != nil {
+	if err := r.repos.Location.SetMap(location, locationMap); err != nil {
 		return err
 	}
 	spaces := []*Space{
@@ -361,7 +406,7 @@ func (r *OrganizationRepository) createSampleData(org *Organization) error {
 		{LocationID: location.ID, Name: "Desk 16", X: 1933, Y: 626, Width: 104, Height: 52, Rotation: 0},
 	}
 	for _, space := range spaces {
-		if err := GetSpaceRepository().Create(space); err != nil {
+		if err := r.repos.Space.Create(space); err != nil {
 			return err
 		}
 	}
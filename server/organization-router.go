@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"log"
 	"net"
 	"net/http"
@@ -11,6 +13,7 @@ import (
 )
 
 type OrganizationRouter struct {
+	repos *Repositories
 }
 
 type CreateOrganizationRequest struct {
@@ -32,6 +35,13 @@ type GetDomainResponse struct {
 	VerifyToken string `json:"verifyToken"`
 }
 
+type GetSCIMTokenResponse struct {
+	Enabled bool       `json:"enabled"`
+	Created *time.Time `json:"created,omitempty"`
+	Token   string     `json:"token,omitempty"`
+	URL     string     `json:"url"`
+}
+
 type GetManageSubscriptionURLResponse struct {
 	URL string `json:"url"`
 }
@@ -42,6 +52,9 @@ func (router *OrganizationRouter) setupRoutes(s *mux.Router) {
 	s.HandleFunc("/{id}/domain/{domain}/verify", router.verifyDomain).Methods("POST")
 	s.HandleFunc("/{id}/domain/{domain}", router.removeDomain).Methods("DELETE")
 	s.HandleFunc("/{id}/domain/{domain}", router.addDomain).Methods("POST")
+	s.HandleFunc("/{id}/scim", router.getSCIMToken).Methods("GET")
+	s.HandleFunc("/{id}/scim", router.createSCIMToken).Methods("POST")
+	s.HandleFunc("/{id}/scim", router.deleteSCIMToken).Methods("DELETE")
 	s.HandleFunc("/{id}", router.getOne).Methods("GET")
 	s.HandleFunc("/{id}", router.update).Methods("PUT")
 	s.HandleFunc("/{id}", router.delete).Methods("DELETE")
@@ -51,7 +64,7 @@ func (router *OrganizationRouter) setupRoutes(s *mux.Router) {
 
 func (router *OrganizationRouter) getOrgForDomain(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	e, err := GetOrganizationRepository().GetOneByDomain(vars["domain"])
+	e, err := router.repos.Organization.GetOneByDomain(vars["domain"])
 	if err != nil {
 		log.Println(err)
 		SendNotFound(w)
@@ -65,14 +78,14 @@ func (router *OrganizationRouter) getOrgForDomain(w http.ResponseWriter, r *http
 
 func (router *OrganizationRouter) getOne(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	e, err := GetOrganizationRepository().GetOne(vars["id"])
+	e, err := router.repos.Organization.GetOne(vars["id"])
 	if err != nil {
 		log.Println(err)
 		SendNotFound(w)
 		return
 	}
-	user := GetRequestUser(r)
-	if !(GetUserRepository().isSuperAdmin(user) || CanAdminOrg(user, e.ID)) {
+	user := GetRequestUser(router.repos, r)
+	if !(router.repos.User.isSuperAdmin(user) || CanAdminOrg(user, e.ID)) {
 		SendForbidden(w)
 		return
 	}
@@ -81,12 +94,12 @@ func (router *OrganizationRouter) getOne(w http.ResponseWriter, r *http.Request)
 }
 
 func (router *OrganizationRouter) getAll(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
-	if !GetUserRepository().isSuperAdmin(user) {
+	user := GetRequestUser(router.repos, r)
+	if !router.repos.User.isSuperAdmin(user) {
 		SendForbidden(w)
 		return
 	}
-	list, err := GetOrganizationRepository().GetAll()
+	list, err := router.repos.Organization.GetAll()
 	if err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
@@ -102,18 +115,18 @@ func (router *OrganizationRouter) getAll(w http.ResponseWriter, r *http.Request)
 
 func (router *OrganizationRouter) getDomains(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	e, err := GetOrganizationRepository().GetOne(vars["id"])
+	e, err := router.repos.Organization.GetOne(vars["id"])
 	if err != nil {
 		log.Println(err)
 		SendNotFound(w)
 		return
 	}
-	user := GetRequestUser(r)
-	if !(GetUserRepository().isSuperAdmin(user) || CanAdminOrg(user, e.ID)) {
+	user := GetRequestUser(router.repos, r)
+	if !(router.repos.User.isSuperAdmin(user) || CanAdminOrg(user, e.ID)) {
 		SendForbidden(w)
 		return
 	}
-	list, err := GetOrganizationRepository().GetDomains(e)
+	list, err := router.repos.Organization.GetDomains(e)
 	if err != nil {
 		SendInternalServerError(w)
 		return
@@ -132,31 +145,31 @@ func (router *OrganizationRouter) getDomains(w http.ResponseWriter, r *http.Requ
 
 func (router *OrganizationRouter) addDomain(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	e, err := GetOrganizationRepository().GetOne(vars["id"])
+	e, err := router.repos.Organization.GetOne(vars["id"])
 	if err != nil {
 		log.Println(err)
 		SendNotFound(w)
 		return
 	}
-	user := GetRequestUser(r)
-	if !(GetUserRepository().isSuperAdmin(user) || CanAdminOrg(user, e.ID)) {
+	user := GetRequestUser(router.repos, r)
+	if !(router.repos.User.isSuperAdmin(user) || CanAdminOrg(user, e.ID)) {
 		SendForbidden(w)
 		return
 	}
 	// Check if domain exists in this org already
-	domain, _ := GetOrganizationRepository().GetDomain(e, vars["domain"])
+	domain, _ := router.repos.Organization.GetDomain(e, vars["domain"])
 	if domain != nil {
 		SendAleadyExists(w)
 		return
 	}
 	// Check if domain exists in activated state in ANY org already
-	someOrg, _ := GetOrganizationRepository().GetOneByDomain(vars["domain"])
+	someOrg, _ := router.repos.Organization.GetOneByDomain(vars["domain"])
 	if someOrg != nil {
 		SendAleadyExists(w)
 		return
 	}
 	// Add domain
-	err = GetOrganizationRepository().AddDomain(e, vars["domain"], GetUserRepository().isSuperAdmin(user))
+	err = router.repos.Organization.AddDomain(e, vars["domain"], router.repos.User.isSuperAdmin(user))
 	if err != nil {
 		log.Println(err)
 		SendAleadyExists(w)
@@ -167,18 +180,18 @@ func (router *OrganizationRouter) addDomain(w http.ResponseWriter, r *http.Reque
 
 func (router *OrganizationRouter) verifyDomain(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	e, err := GetOrganizationRepository().GetOne(vars["id"])
+	e, err := router.repos.Organization.GetOne(vars["id"])
 	if err != nil {
 		log.Println(err)
 		SendNotFound(w)
 		return
 	}
-	user := GetRequestUser(r)
-	if !(GetUserRepository().isSuperAdmin(user) || CanAdminOrg(user, e.ID)) {
+	user := GetRequestUser(router.repos, r)
+	if !(router.repos.User.isSuperAdmin(user) || CanAdminOrg(user, e.ID)) {
 		SendForbidden(w)
 		return
 	}
-	domain, err := GetOrganizationRepository().GetDomain(e, vars["domain"])
+	domain, err := router.repos.Organization.GetDomain(e, vars["domain"])
 	if err != nil {
 		log.Println(err)
 		SendNotFound(w)
@@ -188,7 +201,7 @@ func (router *OrganizationRouter) verifyDomain(w http.ResponseWriter, r *http.Re
 		return
 	}
 	// Check if domain exists in activated state in ANY org already
-	someOrg, _ := GetOrganizationRepository().GetOneByDomain(vars["domain"])
+	someOrg, _ := router.repos.Organization.GetOneByDomain(vars["domain"])
 	if someOrg != nil {
 		SendAleadyExists(w)
 		return
@@ -197,7 +210,7 @@ func (router *OrganizationRouter) verifyDomain(w http.ResponseWriter, r *http.Re
 		SendBadRequest(w)
 		return
 	}
-	err = GetOrganizationRepository().ActivateDomain(e, domain.DomainName)
+	err = router.repos.Organization.ActivateDomain(e, domain.DomainName)
 	if err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
@@ -208,14 +221,14 @@ func (router *OrganizationRouter) verifyDomain(w http.ResponseWriter, r *http.Re
 
 func (router *OrganizationRouter) removeDomain(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	e, err := GetOrganizationRepository().GetOne(vars["id"])
+	e, err := router.repos.Organization.GetOne(vars["id"])
 	if err != nil {
 		log.Println(err)
 		SendNotFound(w)
 		return
 	}
-	user := GetRequestUser(r)
-	if !(GetUserRepository().isSuperAdmin(user) || CanAdminOrg(user, e.ID)) {
+	user := GetRequestUser(router.repos, r)
+	if !(router.repos.User.isSuperAdmin(user) || CanAdminOrg(user, e.ID)) {
 		SendForbidden(w)
 		return
 	}
@@ -229,7 +242,7 @@ func (router *OrganizationRouter) removeDomain(w http.ResponseWriter, r *http.Re
 		SendBadRequest(w)
 		return
 	}
-	err = GetOrganizationRepository().RemoveDomain(e, vars["domain"])
+	err = router.repos.Organization.RemoveDomain(e, vars["domain"])
 	if err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
@@ -238,9 +251,86 @@ func (router *OrganizationRouter) removeDomain(w http.ResponseWriter, r *http.Re
 	SendUpdated(w)
 }
 
+func (router *OrganizationRouter) getSCIMToken(w http.ResponseWriter, r *http.Request) {
+	e, ok := router.getAdminOrg(w, r)
+	if !ok {
+		return
+	}
+	res := &GetSCIMTokenResponse{
+		URL: GetConfig().PublicURL + "scim/v2/",
+	}
+	if token, err := router.repos.SCIMToken.GetByOrg(e.ID); err == nil {
+		res.Enabled = true
+		res.Created = &token.Created
+	}
+	SendJSON(w, res)
+}
+
+// createSCIMToken generates a new SCIM bearer token, replacing the previous one.
+// The token is only returned in this response.
+func (router *OrganizationRouter) createSCIMToken(w http.ResponseWriter, r *http.Request) {
+	e, ok := router.getAdminOrg(w, r)
+	if !ok {
+		return
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	tokenString := hex.EncodeToString(b)
+	token := &SCIMToken{
+		OrganizationID: e.ID,
+		TokenHash:      router.repos.SCIMToken.GetTokenHash(tokenString),
+		Created:        time.Now().UTC(),
+	}
+	if err := router.repos.SCIMToken.Set(token); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	res := &GetSCIMTokenResponse{
+		Enabled: true,
+		Created: &token.Created,
+		Token:   tokenString,
+		URL:     GetConfig().PublicURL + "scim/v2/",
+	}
+	SendJSON(w, res)
+}
+
+func (router *OrganizationRouter) deleteSCIMToken(w http.ResponseWriter, r *http.Request) {
+	e, ok := router.getAdminOrg(w, r)
+	if !ok {
+		return
+	}
+	if err := router.repos.SCIMToken.DeleteAll(e.ID); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	SendUpdated(w)
+}
+
+func (router *OrganizationRouter) getAdminOrg(w http.ResponseWriter, r *http.Request) (*Organization, bool) {
+	vars := mux.Vars(r)
+	e, err := router.repos.Organization.GetOne(vars["id"])
+	if err != nil {
+		log.Println(err)
+		SendNotFound(w)
+		return nil, false
+	}
+	user := GetRequestUser(router.repos, r)
+	if !(router.repos.User.isSuperAdmin(user) || CanAdminOrg(user, e.ID)) {
+		SendForbidden(w)
+		return nil, false
+	}
+	return e, true
+}
+
 func (router *OrganizationRouter) update(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
-	if !GetUserRepository().isSuperAdmin(user) {
+	user := GetRequestUser(router.repos, r)
+	if !router.repos.User.isSuperAdmin(user) {
 		SendForbidden(w)
 		return
 	}
@@ -252,7 +342,7 @@ func (router *OrganizationRouter) update(w http.ResponseWriter, r *http.Request)
 	vars := mux.Vars(r)
 	e := router.copyFromRestModel(&m)
 	e.ID = vars["id"]
-	if err := GetOrganizationRepository().Update(e); err != nil {
+	if err := router.repos.Organization.Update(e); err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
@@ -261,23 +351,23 @@ func (router *OrganizationRouter) update(w http.ResponseWriter, r *http.Request)
 }
 
 func (router *OrganizationRouter) delete(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
-	if !(GetUserRepository().isSuperAdmin(user) || CanAdminOrg(user, user.OrganizationID)) {
+	user := GetRequestUser(router.repos, r)
+	if !(router.repos.User.isSuperAdmin(user) || CanAdminOrg(user, user.OrganizationID)) {
 		SendForbidden(w)
 		return
 	}
-	if !GetUserRepository().isSuperAdmin(user) && CanAdminOrg(user, user.OrganizationID) {
+	if !router.repos.User.isSuperAdmin(user) && CanAdminOrg(user, user.OrganizationID) {
 		if !GetConfig().OrgSignupDelete {
 			SendForbidden(w)
 		}
 	}
 	vars := mux.Vars(r)
-	e, err := GetOrganizationRepository().GetOne(vars["id"])
+	e, err := router.repos.Organization.GetOne(vars["id"])
 	if err != nil {
 		SendNotFound(w)
 		return
 	}
-	if err := GetOrganizationRepository().Delete(e); err != nil {
+	if err := router.repos.Organization.Delete(e); err != nil {
 		SendInternalServerError(w)
 		return
 	}
@@ -285,8 +375,8 @@ func (router *OrganizationRouter) delete(w http.ResponseWriter, r *http.Request)
 }
 
 func (router *OrganizationRouter) create(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
-	if !GetUserRepository().isSuperAdmin(user) {
+	user := GetRequestUser(router.repos, r)
+	if !router.repos.User.isSuperAdmin(user) {
 		SendForbidden(w)
 		return
 	}
@@ -297,7 +387,7 @@ func (router *OrganizationRouter) create(w http.ResponseWriter, r *http.Request)
 	}
 	e := router.copyFromRestModel(&m)
 	e.SignupDate = time.Now()
-	if err := GetOrganizationRepository().Create(e); err != nil {
+	if err := router.repos.Organization.Create(e); err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
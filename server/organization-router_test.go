@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
+	"strings"
 	"testing"
 )
 
@@ -147,9 +148,9 @@ func TestOrganizationsGetByDomain(t *testing.T) {
 	checkTestResponseCode(t, http.StatusOK, res.Code)
 
 	// Verify both domains
-	org, _ := GetOrganizationRepository().GetOne(id)
-	GetOrganizationRepository().ActivateDomain(org, "test1.com")
-	GetOrganizationRepository().ActivateDomain(org, "test2.com")
+	org, _ := testRepositories.Organization.GetOne(id)
+	testRepositories.Organization.ActivateDomain(org, "test1.com")
+	testRepositories.Organization.ActivateDomain(org, "test2.com")
 
 	// Get by domain 1
 	req = newHTTPRequest("GET", "/organization/domain/test1.com", loginResponse.UserID, nil)
@@ -271,7 +272,7 @@ func TestOrganizationsVerifyDNS(t *testing.T) {
 	checkTestResponseCode(t, http.StatusCreated, res.Code)
 	id := res.Header().Get("X-Object-Id")
 
-	org, _ := GetOrganizationRepository().GetOne(id)
+	org, _ := testRepositories.Organization.GetOne(id)
 	adminUser := createTestUserOrgAdmin(org)
 	adminLoginResponse := loginTestUser(adminUser.ID)
 
@@ -281,10 +282,7 @@ func TestOrganizationsVerifyDNS(t *testing.T) {
 	checkTestResponseCode(t, http.StatusCreated, res.Code)
 
 	// Fake verify token
-	GetDatabase().DB().Exec("UPDATE organizations_domains "+
-		"SET verify_token = '65e51a4b-339f-4b24-b376-f9d866057b38' "+
-		"WHERE domain = LOWER($1) AND organization_id = $2",
-		"testcase.seatsurfing.app", id)
+	setTestDomainVerifyToken(t, "testcase.seatsurfing.app", id)
 
 	// Verify domain
 	req = newHTTPRequest("POST", "/organization/"+id+"/domain/testcase.seatsurfing.app/verify", adminLoginResponse.UserID, nil)
@@ -339,8 +337,8 @@ func TestOrganizationsAddDomainConflict(t *testing.T) {
 	req = newHTTPRequest("POST", "/organization/"+id1+"/domain/test1.com", loginResponse.UserID, nil)
 	res = executeTestRequest(req)
 	checkTestResponseCode(t, http.StatusCreated, res.Code)
-	org1, _ := GetOrganizationRepository().GetOne(id1)
-	GetOrganizationRepository().ActivateDomain(org1, "test1.com")
+	org1, _ := testRepositories.Organization.GetOne(id1)
+	testRepositories.Organization.ActivateDomain(org1, "test1.com")
 
 	// Try to add same domain to org 2
 	req = newHTTPRequest("POST", "/organization/"+id2+"/domain/test1.com", loginResponse.UserID, nil)
@@ -379,11 +377,11 @@ func TestOrganizationsAddDomainNoConflictBecauseInactive(t *testing.T) {
 	checkTestResponseCode(t, http.StatusCreated, res.Code)
 	id2 := res.Header().Get("X-Object-Id")
 
-	org1, _ := GetOrganizationRepository().GetOne(id1)
+	org1, _ := testRepositories.Organization.GetOne(id1)
 	adminUser1 := createTestUserOrgAdmin(org1)
 	adminLoginResponse1 := loginTestUser(adminUser1.ID)
 
-	org2, _ := GetOrganizationRepository().GetOne(id2)
+	org2, _ := testRepositories.Organization.GetOne(id2)
 	adminUser2 := createTestUserOrgAdmin(org2)
 	adminLoginResponse2 := loginTestUser(adminUser2.ID)
 
@@ -429,11 +427,11 @@ func TestOrganizationsAddDomainActivateConflicting(t *testing.T) {
 	checkTestResponseCode(t, http.StatusCreated, res.Code)
 	id2 := res.Header().Get("X-Object-Id")
 
-	org1, _ := GetOrganizationRepository().GetOne(id1)
+	org1, _ := testRepositories.Organization.GetOne(id1)
 	adminUser1 := createTestUserOrgAdmin(org1)
 	adminLoginResponse1 := loginTestUser(adminUser1.ID)
 
-	org2, _ := GetOrganizationRepository().GetOne(id2)
+	org2, _ := testRepositories.Organization.GetOne(id2)
 	adminUser2 := createTestUserOrgAdmin(org2)
 	adminLoginResponse2 := loginTestUser(adminUser2.ID)
 
@@ -448,13 +446,8 @@ func TestOrganizationsAddDomainActivateConflicting(t *testing.T) {
 	checkTestResponseCode(t, http.StatusCreated, res.Code)
 
 	// Fake verify tokens
-	_, err := GetDatabase().DB().Exec("UPDATE organizations_domains "+
-		"SET verify_token = '65e51a4b-339f-4b24-b376-f9d866057b38' "+
-		"WHERE domain = LOWER($1) AND organization_id IN ($2, $3)",
-		"testcase.seatsurfing.app", id1, id2)
-	if err != nil {
-		t.Fatal(err)
-	}
+	setTestDomainVerifyToken(t, "testcase.seatsurfing.app", id1)
+	setTestDomainVerifyToken(t, "testcase.seatsurfing.app", id2)
 
 	// Activate domain in org 1
 	req = newHTTPRequest("POST", "/organization/"+id1+"/domain/testcase.seatsurfing.app/verify", adminLoginResponse1.UserID, nil)
@@ -478,7 +471,29 @@ func TestOrganizationsDelete(t *testing.T) {
 	checkTestResponseCode(t, http.StatusNoContent, res.Code)
 
 	// Verify
-	users, _ := GetUserRepository().GetAll(org.ID, 100, 0)
+	users, _ := testRepositories.User.GetAll(org.ID, 100, 0)
 	checkTestInt(t, 0, len(users))
 
 }
+
+// setTestDomainVerifyToken sets the verify token published in the TXT record of the domain.
+func setTestDomainVerifyToken(t *testing.T, domain, organizationID string) {
+	const verifyToken string = "65e51a4b-339f-4b24-b376-f9d866057b38"
+	if !*testPostgres {
+		memoryLock.Lock()
+		defer memoryLock.Unlock()
+		for _, e := range memoryData.organizationDomains {
+			if e.DomainName == strings.ToLower(domain) && e.OrganizationID == organizationID {
+				e.VerifyToken = verifyToken
+			}
+		}
+		return
+	}
+	_, err := GetDatabase().DB().Exec("UPDATE organizations_domains "+
+		"SET verify_token = $1 "+
+		"WHERE domain = LOWER($2) AND organization_id = $3",
+		verifyToken, domain, organizationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// newRestrictedHTTPClient returns a client for requests to URLs configured by organization
+// admins. Unless allowPrivateNetworks is set, it refuses to connect to addresses of internal
+// networks. The addresses are checked after resolving the host name, so host names resolving
+// to internal addresses and redirects to them are refused as well. Proxies are not used, as
+// the target address could not be checked otherwise.
+func newRestrictedHTTPClient(timeout time.Duration, allowPrivateNetworks bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !allowPrivateNetworks && !isPublicAddress(net.ParseIP(host)) {
+				return errors.New("target address not allowed: " + host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+		},
+	}
+}
+
+// isPublicAddress returns false for loopback, private, link-local and other addresses
+// which are not reachable publicly, so admins can't make the server access internal services.
+func isPublicAddress(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// isPublicHost checks the host of a URL when it is configured. Host names are only
+// rejected if they resolve to an address which is not public, as the addresses can
+// change anyway and are checked again when connecting.
+func isPublicHost(host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return isPublicAddress(ip)
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return true
+	}
+	for _, ip := range ips {
+		if !isPublicAddress(ip) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package main
+
+import "time"
+
+type MemoryRefreshTokenRepository struct {
+}
+
+func (r *MemoryRefreshTokenRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryRefreshTokenRepository) Create(e *RefreshToken) error {
+	if err := memoryCheckUUID(e.UserID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e.ID = memoryNewID()
+	row := *e
+	row.Created = memoryTimestamp(e.Created)
+	row.Expiry = memoryTimestamp(e.Expiry)
+	memoryData.refreshTokens = append(memoryData.refreshTokens, &row)
+	return nil
+}
+
+func (r *MemoryRefreshTokenRepository) GetOne(id string) (*RefreshToken, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryGet(memoryData.refreshTokens, func(e *RefreshToken) bool {
+		return e.ID == id
+	})
+}
+
+func (r *MemoryRefreshTokenRepository) Delete(e *RefreshToken) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.refreshTokens = memoryDelete(memoryData.refreshTokens, func(row *RefreshToken) bool {
+		return row.ID == e.ID
+	})
+	return nil
+}
+
+func (r *MemoryRefreshTokenRepository) DeleteExpired() error {
+	now := memoryTimestamp(time.Now())
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.refreshTokens = memoryDelete(memoryData.refreshTokens, func(row *RefreshToken) bool {
+		return row.Expiry.Before(now)
+	})
+	return nil
+}
+
+func (r *MemoryRefreshTokenRepository) DeleteOfUser(u *User) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.refreshTokens = memoryDelete(memoryData.refreshTokens, func(row *RefreshToken) bool {
+		return row.UserID == u.ID
+	})
+	return nil
+}
@@ -1,11 +1,17 @@
 package main
 
-import (
-	"sync"
-	"time"
-)
+import "time"
 
-type RefreshTokenRepository struct {
+type RefreshTokenRepository interface {
+	Repository
+	Create(e *RefreshToken) error
+	GetOne(id string) (*RefreshToken, error)
+	Delete(e *RefreshToken) error
+	DeleteExpired() error
+	DeleteOfUser(u *User) error
+}
+
+type PostgresRefreshTokenRepository struct {
 }
 
 type RefreshToken struct {
@@ -15,30 +21,20 @@ type RefreshToken struct {
 	Expiry  time.Time
 }
 
-var refreshTokenRepository *RefreshTokenRepository
-var refreshTokenRepositoryOnce sync.Once
-
-func GetRefreshTokenRepository() *RefreshTokenRepository {
-	refreshTokenRepositoryOnce.Do(func() {
-		refreshTokenRepository = &RefreshTokenRepository{}
-		_, err := GetDatabase().DB().Exec("CREATE TABLE IF NOT EXISTS refresh_tokens (" +
-			"id uuid DEFAULT uuid_generate_v4(), " +
-			"user_id uuid NOT NULL, " +
-			"created TIMESTAMP NOT NULL, " +
-			"expiry TIMESTAMP NOT NULL, " +
-			"PRIMARY KEY (id))")
-		if err != nil {
-			panic(err)
-		}
-	})
-	return refreshTokenRepository
-}
-
-func (r *RefreshTokenRepository) RunSchemaUpgrade(curVersion, targetVersion int) {
-	// No updates yet
+func (r *PostgresRefreshTokenRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		newInitialSchemaMigration(
+			"CREATE TABLE IF NOT EXISTS refresh_tokens (" +
+				"id uuid DEFAULT uuid_generate_v4(), " +
+				"user_id uuid NOT NULL, " +
+				"created TIMESTAMP NOT NULL, " +
+				"expiry TIMESTAMP NOT NULL, " +
+				"PRIMARY KEY (id))",
+		),
+	}
 }
 
-func (r *RefreshTokenRepository) Create(e *RefreshToken) error {
+func (r *PostgresRefreshTokenRepository) Create(e *RefreshToken) error {
 	var id string
 	err := GetDatabase().DB().QueryRow("INSERT INTO refresh_tokens "+
 		"(user_id, created, expiry) "+
@@ -52,7 +48,7 @@ func (r *RefreshTokenRepository) Create(e *RefreshToken) error {
 	return nil
 }
 
-func (r *RefreshTokenRepository) GetOne(id string) (*RefreshToken, error) {
+func (r *PostgresRefreshTokenRepository) GetOne(id string) (*RefreshToken, error) {
 	e := &RefreshToken{}
 	err := GetDatabase().DB().QueryRow("SELECT id, user_id, created, expiry "+
 		"FROM refresh_tokens "+
@@ -64,18 +60,18 @@ func (r *RefreshTokenRepository) GetOne(id string) (*RefreshToken, error) {
 	return e, nil
 }
 
-func (r *RefreshTokenRepository) Delete(e *RefreshToken) error {
+func (r *PostgresRefreshTokenRepository) Delete(e *RefreshToken) error {
 	_, err := GetDatabase().DB().Exec("DELETE FROM refresh_tokens WHERE id = $1", e.ID)
 	return err
 }
 
-func (r *RefreshTokenRepository) DeleteExpired() error {
+func (r *PostgresRefreshTokenRepository) DeleteExpired() error {
 	now := time.Now()
 	_, err := GetDatabase().DB().Exec("DELETE FROM refresh_tokens WHERE expiry < $1", now)
 	return err
 }
 
-func (r *RefreshTokenRepository) DeleteOfUser(u *User) error {
+func (r *PostgresRefreshTokenRepository) DeleteOfUser(u *User) error {
 	_, err := GetDatabase().DB().Exec("DELETE FROM refresh_tokens WHERE user_id = $1", u.ID)
 	return err
 }
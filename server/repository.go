@@ -2,8 +2,158 @@ package main
 
 import "database/sql"
 
+// Repository is implemented by all repositories. GetMigrations returns the migrations
+// creating the repository's tables and changing them since.
 type Repository interface {
-	RunSchemaUpgrade(curVersion, targetVersion int)
+	GetMigrations() []*Migration
+}
+
+// Repositories holds one repository of each kind. Routers and repositories get the
+// repositories they work with passed in instead of choosing a backend themselves.
+type Repositories struct {
+	AuthProvider            AuthProviderRepository
+	AuthProviderRoleMapping AuthProviderRoleMappingRepository
+	AuthState               AuthStateRepository
+	AuthAttempt             AuthAttemptRepository
+	Booking                 BookingRepository
+	BookingAttendee         BookingAttendeeRepository
+	BookingRestriction      BookingRestrictionRepository
+	Waitlist                WaitlistRepository
+	BookingSeries           BookingSeriesRepository
+	Buddy                   BuddyRepository
+	NoShow                  NoShowRepository
+	Webhook                 WebhookRepository
+	WebhookDelivery         WebhookDeliveryRepository
+	Location                LocationRepository
+	LocationApprover        LocationApproverRepository
+	AuditLog                AuditLogRepository
+	Organization            OrganizationRepository
+	Space                   SpaceRepository
+	SpaceAttribute          SpaceAttributeRepository
+	User                    UserRepository
+	UserPreferences         UserPreferencesRepository
+	UserGroup               UserGroupRepository
+	UserTOTP                UserTOTPRepository
+	Settings                SettingsRepository
+	Signup                  SignupRepository
+	Subscription            SubscriptionRepository
+	RefreshToken            RefreshTokenRepository
+	ICalToken               ICalTokenRepository
+	SCIMToken               SCIMTokenRepository
+	APIToken                APITokenRepository
+	DebugTimeIssues         DebugTimeIssuesRepository
+}
+
+// NewPostgresRepositories returns the repositories storing their data in PostgreSQL.
+func NewPostgresRepositories() *Repositories {
+	r := &Repositories{}
+	r.AuthProvider = &PostgresAuthProviderRepository{repos: r}
+	r.AuthProviderRoleMapping = &PostgresAuthProviderRoleMappingRepository{authProviderRoleMappingRepositoryBase: authProviderRoleMappingRepositoryBase{repos: r}}
+	r.AuthState = &PostgresAuthStateRepository{}
+	r.AuthAttempt = &PostgresAuthAttemptRepository{authAttemptRepositoryBase: authAttemptRepositoryBase{repos: r}}
+	r.Booking = &PostgresBookingRepository{bookingRepositoryBase: bookingRepositoryBase{repos: r}}
+	r.BookingAttendee = &PostgresBookingAttendeeRepository{}
+	r.BookingRestriction = &PostgresBookingRestrictionRepository{bookingRestrictionRepositoryBase: bookingRestrictionRepositoryBase{repos: r}}
+	r.Waitlist = &PostgresWaitlistRepository{}
+	r.BookingSeries = &PostgresBookingSeriesRepository{bookingSeriesRepositoryBase: bookingSeriesRepositoryBase{repos: r}}
+	r.Buddy = &PostgresBuddyRepository{}
+	r.NoShow = &PostgresNoShowRepository{}
+	r.Webhook = &PostgresWebhookRepository{webhookRepositoryBase: webhookRepositoryBase{repos: r}}
+	r.WebhookDelivery = &PostgresWebhookDeliveryRepository{}
+	r.Location = &PostgresLocationRepository{locationRepositoryBase: locationRepositoryBase{repos: r}}
+	r.LocationApprover = &PostgresLocationApproverRepository{locationApproverRepositoryBase: locationApproverRepositoryBase{repos: r}}
+	r.AuditLog = &PostgresAuditLogRepository{}
+	r.Organization = &PostgresOrganizationRepository{organizationRepositoryBase: organizationRepositoryBase{repos: r}}
+	r.Space = &PostgresSpaceRepository{repos: r}
+	r.SpaceAttribute = &PostgresSpaceAttributeRepository{}
+	r.User = &PostgresUserRepository{userRepositoryBase: userRepositoryBase{repos: r}}
+	r.UserPreferences = &PostgresUserPreferencesRepository{userPreferencesRepositoryBase: userPreferencesRepositoryBase{repos: r}}
+	r.UserGroup = &PostgresUserGroupRepository{repos: r}
+	r.UserTOTP = &PostgresUserTOTPRepository{userTOTPRepositoryBase: userTOTPRepositoryBase{repos: r}}
+	r.Settings = &PostgresSettingsRepository{settingsRepositoryBase: settingsRepositoryBase{repos: r}}
+	r.Signup = &PostgresSignupRepository{}
+	r.Subscription = &PostgresSubscriptionRepository{}
+	r.RefreshToken = &PostgresRefreshTokenRepository{}
+	r.ICalToken = &PostgresICalTokenRepository{}
+	r.SCIMToken = &PostgresSCIMTokenRepository{}
+	r.APIToken = &PostgresAPITokenRepository{}
+	r.DebugTimeIssues = &PostgresDebugTimeIssuesRepository{}
+	return r
+}
+
+// NewMemoryRepositories returns repositories keeping their data in the process, i.e. for
+// running tests without a database. The data is shared by all of them.
+func NewMemoryRepositories() *Repositories {
+	r := &Repositories{}
+	r.AuthProvider = &MemoryAuthProviderRepository{repos: r}
+	r.AuthProviderRoleMapping = &MemoryAuthProviderRoleMappingRepository{authProviderRoleMappingRepositoryBase: authProviderRoleMappingRepositoryBase{repos: r}}
+	r.AuthState = &MemoryAuthStateRepository{}
+	r.AuthAttempt = &MemoryAuthAttemptRepository{authAttemptRepositoryBase: authAttemptRepositoryBase{repos: r}}
+	r.Booking = &MemoryBookingRepository{bookingRepositoryBase: bookingRepositoryBase{repos: r}}
+	r.BookingAttendee = &MemoryBookingAttendeeRepository{}
+	r.BookingRestriction = &MemoryBookingRestrictionRepository{bookingRestrictionRepositoryBase: bookingRestrictionRepositoryBase{repos: r}}
+	r.Waitlist = &MemoryWaitlistRepository{}
+	r.BookingSeries = &MemoryBookingSeriesRepository{bookingSeriesRepositoryBase: bookingSeriesRepositoryBase{repos: r}}
+	r.Buddy = &MemoryBuddyRepository{}
+	r.NoShow = &MemoryNoShowRepository{}
+	r.Webhook = &MemoryWebhookRepository{webhookRepositoryBase: webhookRepositoryBase{repos: r}}
+	r.WebhookDelivery = &MemoryWebhookDeliveryRepository{}
+	r.Location = &MemoryLocationRepository{locationRepositoryBase: locationRepositoryBase{repos: r}}
+	r.LocationApprover = &MemoryLocationApproverRepository{locationApproverRepositoryBase: locationApproverRepositoryBase{repos: r}}
+	r.AuditLog = &MemoryAuditLogRepository{}
+	r.Organization = &MemoryOrganizationRepository{organizationRepositoryBase: organizationRepositoryBase{repos: r}}
+	r.Space = &MemorySpaceRepository{repos: r}
+	r.SpaceAttribute = &MemorySpaceAttributeRepository{}
+	r.User = &MemoryUserRepository{userRepositoryBase: userRepositoryBase{repos: r}}
+	r.UserPreferences = &MemoryUserPreferencesRepository{userPreferencesRepositoryBase: userPreferencesRepositoryBase{repos: r}}
+	r.UserGroup = &MemoryUserGroupRepository{repos: r}
+	r.UserTOTP = &MemoryUserTOTPRepository{userTOTPRepositoryBase: userTOTPRepositoryBase{repos: r}}
+	r.Settings = &MemorySettingsRepository{settingsRepositoryBase: settingsRepositoryBase{repos: r}}
+	r.Signup = &MemorySignupRepository{}
+	r.Subscription = &MemorySubscriptionRepository{}
+	r.RefreshToken = &MemoryRefreshTokenRepository{}
+	r.ICalToken = &MemoryICalTokenRepository{}
+	r.SCIMToken = &MemorySCIMTokenRepository{}
+	r.APIToken = &MemoryAPITokenRepository{}
+	r.DebugTimeIssues = &MemoryDebugTimeIssuesRepository{}
+	return r
+}
+
+// all returns all repositories in the order their migrations are applied.
+func (r *Repositories) all() []Repository {
+	return []Repository{
+		r.AuthProvider,
+		r.AuthProviderRoleMapping,
+		r.AuthState,
+		r.AuthAttempt,
+		r.Booking,
+		r.BookingAttendee,
+		r.BookingRestriction,
+		r.Waitlist,
+		r.BookingSeries,
+		r.Buddy,
+		r.NoShow,
+		r.Webhook,
+		r.WebhookDelivery,
+		r.Location,
+		r.LocationApprover,
+		r.AuditLog,
+		r.Organization,
+		r.Space,
+		r.SpaceAttribute,
+		r.User,
+		r.UserPreferences,
+		r.UserGroup,
+		r.UserTOTP,
+		r.Settings,
+		r.Signup,
+		r.Subscription,
+		r.RefreshToken,
+		r.ICalToken,
+		r.SCIMToken,
+		r.APIToken,
+		r.DebugTimeIssues,
+	}
 }
 
 func CheckNullString(s NullString) sql.NullString {
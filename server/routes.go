@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
@@ -40,6 +41,14 @@ var (
 	ResponseCodeBookingMaxConcurrentForUser      = 1006
 	ResponseCodeBookingInvalidMinBookingDuration = 1007
 	ResponseCodeBookingMaxHoursBeforeDelete      = 1008
+	ResponseCodeBookingCheckInNotPossible        = 1009
+	ResponseCodeTOTPEnforced                     = 1010
+	ResponseCodeBookingInvalidAttendees          = 1011
+	ResponseCodeBookingCapacityExceeded          = 1012
+	ResponseCodeBookingSpaceRestricted           = 1013
+	ResponseCodeWaitlistSpaceAvailable           = 1014
+	ResponseCodeWaitlistNoOffer                  = 1015
+	ResponseCodeBookingPendingApproval           = 1016
 )
 
 type Route interface {
@@ -85,11 +94,31 @@ func SendAleadyExists(w http.ResponseWriter) {
 	w.WriteHeader(http.StatusConflict)
 }
 
+func SendAlreadyExistsCode(w http.ResponseWriter, code int) {
+	w.Header().Set("X-Error-Code", strconv.Itoa(code))
+	w.WriteHeader(http.StatusConflict)
+}
+
 func SendCreated(w http.ResponseWriter, id string) {
 	w.Header().Set("X-Object-ID", id)
 	w.WriteHeader(http.StatusCreated)
 }
 
+// SendCreatedJSON is like SendCreated, but additionally returns v, e.g. for values which
+// can only be retrieved once.
+func SendCreatedJSON(w http.ResponseWriter, id string, v interface{}) {
+	json, err := json.Marshal(v)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	w.Header().Set("X-Object-ID", id)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(json)
+}
+
 func SendUpdated(w http.ResponseWriter) {
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -173,7 +202,32 @@ func ExtractClaimsFromRequest(r *http.Request) (*Claims, string, error) {
 	return claims, authHeader, nil
 }
 
-func VerifyAuthMiddleware(next http.Handler) http.Handler {
+// ExtractAPITokenFromRequest returns the API token of the request's bearer token. The
+// token must not be expired and its user must not be disabled. The token's last use
+// is updated at most once per APITokenLastUsedInterval.
+func ExtractAPITokenFromRequest(repos *Repositories, r *http.Request) (*APIToken, string, error) {
+	authHeader := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	token, err := repos.APIToken.GetByToken(authHeader)
+	if err != nil {
+		return nil, "", errors.New("API token verification failed: unknown token")
+	}
+	now := time.Now().UTC()
+	if token.Expiry != nil && !token.Expiry.After(now) {
+		return nil, "", errors.New("API token verification failed: token expired")
+	}
+	user, err := repos.User.GetOne(token.UserID)
+	if err != nil || user.Disabled {
+		return nil, "", errors.New("API token verification failed: user not found or disabled")
+	}
+	if token.LastUsed == nil || token.LastUsed.Before(now.Add(-APITokenLastUsedInterval)) {
+		if err := repos.APIToken.SetLastUsed(token, now); err != nil {
+			log.Println(err)
+		}
+	}
+	return token, authHeader, nil
+}
+
+func (a *App) VerifyAuthMiddleware(next http.Handler) http.Handler {
 	var isWhitelistMatch = func(url string, whitelistedURL string) bool {
 		whitelistedURL = strings.TrimSpace(whitelistedURL)
 		whitelistedURL = strings.TrimSuffix(whitelistedURL, "/")
@@ -206,6 +260,22 @@ func VerifyAuthMiddleware(next http.Handler) http.Handler {
 			next.ServeHTTP(w, r)
 			return
 		}
+		if strings.HasPrefix(r.Header.Get("Authorization"), "Bearer "+APITokenPrefix) {
+			token, authHeader, err := ExtractAPITokenFromRequest(a.Repositories, r)
+			if err != nil {
+				log.Println(err)
+				SendUnauthorized(w)
+				return
+			}
+			if !isAPITokenScopeAllowed(token.Scopes, r) {
+				SendForbidden(w)
+				return
+			}
+			ctx := context.WithValue(r.Context(), contextKeyUserID, token.UserID)
+			ctx = context.WithValue(ctx, contextKeyAuthHeader, authHeader)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
 		claims, authHeader, err := ExtractClaimsFromRequest(r)
 		if err != nil {
 			log.Println(err)
@@ -246,9 +316,9 @@ func GetAuthHeaderFromContext(r *http.Request) string {
 	return authHeader.(string)
 }
 
-func GetRequestUser(r *http.Request) *User {
+func GetRequestUser(repos *Repositories, r *http.Request) *User {
 	ID := GetRequestUserID(r)
-	user, err := GetUserRepository().GetOne(ID)
+	user, err := repos.User.GetOne(ID)
 	if err != nil {
 		log.Println(err)
 		return nil
@@ -256,31 +326,56 @@ func GetRequestUser(r *http.Request) *User {
 	return user
 }
 
+// GetRequestIP returns the client's IP address. The X-Forwarded-For header is only
+// honored if the request comes from one of the configured trusted proxies. In this
+// case, the last address in the header which is not a trusted proxy is the client.
+func GetRequestIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !GetConfig().isTrustedProxy(ip) {
+		return ip
+	}
+	forwardedFor := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwardedFor) - 1; i >= 0; i-- {
+		forwardedIP := strings.TrimSpace(forwardedFor[i])
+		if forwardedIP == "" {
+			continue
+		}
+		ip = forwardedIP
+		if !GetConfig().isTrustedProxy(ip) {
+			break
+		}
+	}
+	return ip
+}
+
 func CanAccessOrg(user *User, organizationID string) bool {
 	if user.OrganizationID == organizationID {
 		return true
 	}
-	if GetUserRepository().isSuperAdmin(user) {
+	if user.Role >= UserRoleSuperAdmin {
 		return true
 	}
 	return false
 }
 
 func CanSpaceAdminOrg(user *User, organizationID string) bool {
-	if (user.OrganizationID == organizationID) && (GetUserRepository().isSpaceAdmin(user)) {
+	if (user.OrganizationID == organizationID) && (user.Role >= UserRoleSpaceAdmin) {
 		return true
 	}
-	if GetUserRepository().isSuperAdmin(user) {
+	if user.Role >= UserRoleSuperAdmin {
 		return true
 	}
 	return false
 }
 
 func CanAdminOrg(user *User, organizationID string) bool {
-	if (user.OrganizationID == organizationID) && (GetUserRepository().isOrgAdmin(user)) {
+	if (user.OrganizationID == organizationID) && (user.Role >= UserRoleOrgAdmin) {
 		return true
 	}
-	if GetUserRepository().isSuperAdmin(user) {
+	if user.Role >= UserRoleSuperAdmin {
 		return true
 	}
 	return false
@@ -313,4 +408,6 @@ var unauthorizedRoutes = [...]string{
 	"/fastspring/webhook",
 	"/confluence",
 	"/booking/debugtimeissues/",
+	"/booking/ical/",
+	"/scim/",
 }
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/crewjam/saml"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+// SAMLSPCertificateValidity is how long a generated service provider certificate is valid.
+const SAMLSPCertificateValidity time.Duration = 10 * 365 * 24 * time.Hour
+
+// SAMLMaxMetadataSize limits the size of identity provider metadata fetched from a URL.
+const SAMLMaxMetadataSize int64 = 1024 * 1024
+
+// getSAMLServiceProvider returns the SAML service provider of the auth provider,
+// signing its AuthnRequests with the provider's key. The service provider's
+// entity ID is the URL its metadata is served at.
+func getSAMLServiceProvider(provider *AuthProvider) (*saml.ServiceProvider, error) {
+	idpMetadata, err := parseSAMLIdPMetadata([]byte(provider.SAMLIdPMetadata))
+	if err != nil {
+		return nil, err
+	}
+	key, cert, err := parseSAMLKeyPair(provider.SAMLSPKey, provider.SAMLSPCertificate)
+	if err != nil {
+		return nil, err
+	}
+	metadataURL, err := url.Parse(GetConfig().PublicURL + "auth/" + provider.ID + "/metadata")
+	if err != nil {
+		return nil, err
+	}
+	acsURL, err := url.Parse(GetConfig().PublicURL + "auth/" + provider.ID + "/callback")
+	if err != nil {
+		return nil, err
+	}
+	sp := &saml.ServiceProvider{
+		EntityID:          metadataURL.String(),
+		Key:               key,
+		Certificate:       cert,
+		MetadataURL:       *metadataURL,
+		AcsURL:            *acsURL,
+		IDPMetadata:       idpMetadata,
+		SignatureMethod:   dsig.RSASHA256SignatureMethod,
+		AllowIDPInitiated: false,
+	}
+	return sp, nil
+}
+
+// parseSAMLIdPMetadata reads an identity provider's metadata, which is either a
+// single EntityDescriptor or an EntitiesDescriptor containing one with an IDPSSODescriptor.
+func parseSAMLIdPMetadata(data []byte) (*saml.EntityDescriptor, error) {
+	entity := &saml.EntityDescriptor{}
+	if err := xml.Unmarshal(data, entity); err == nil {
+		if len(entity.IDPSSODescriptors) == 0 {
+			return nil, errors.New("metadata contains no IDPSSODescriptor")
+		}
+		return entity, nil
+	}
+	entities := &saml.EntitiesDescriptor{}
+	if err := xml.Unmarshal(data, entities); err != nil {
+		return nil, err
+	}
+	for i := range entities.EntityDescriptors {
+		if len(entities.EntityDescriptors[i].IDPSSODescriptors) > 0 {
+			return &entities.EntityDescriptors[i], nil
+		}
+	}
+	return nil, errors.New("metadata contains no IDPSSODescriptor")
+}
+
+// fetchSAMLIdPMetadata downloads an identity provider's metadata from its URL. The URL
+// is set by organization admins, so addresses of internal networks are refused.
+func fetchSAMLIdPMetadata(metadataURL string) ([]byte, error) {
+	req, err := http.NewRequest("GET", metadataURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := getAuthProviderHTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New("failed fetching metadata: " + res.Status)
+	}
+	return io.ReadAll(io.LimitReader(res.Body, SAMLMaxMetadataSize))
+}
+
+// createSAMLKeyPair generates the PEM encoded RSA key and self-signed certificate
+// the service provider signs its requests with.
+func createSAMLKeyPair(commonName string) (string, string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", "", err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", "", err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(SAMLSPCertificateValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return "", "", err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return string(keyPEM), string(certPEM), nil
+}
+
+func parseSAMLKeyPair(keyPEM, certPEM string) (*rsa.PrivateKey, *x509.Certificate, error) {
+	keyBlock, _ := pem.Decode([]byte(keyPEM))
+	if keyBlock == nil {
+		return nil, nil, errors.New("invalid service provider key")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	certBlock, _ := pem.Decode([]byte(certPEM))
+	if certBlock == nil {
+		return nil, nil, errors.New("invalid service provider certificate")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, cert, nil
+}
@@ -0,0 +1,924 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// SCIMRouter implements a SCIM 2.0 (RFC 7643, RFC 7644) service provider for
+// provisioning users and groups from an identity provider. Requests are
+// authenticated with the organization's SCIM bearer token instead of a JWT.
+type SCIMRouter struct {
+	repos *Repositories
+}
+
+const (
+	SCIMSchemaUser                  string = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCIMSchemaGroup                 string = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SCIMSchemaServiceProviderConfig string = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SCIMSchemaResourceType          string = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SCIMSchemaListResponse          string = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SCIMSchemaPatchOp               string = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SCIMSchemaError                 string = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+const SCIMContentType string = "application/scim+json"
+const SCIMDefaultPageSize int = 100
+const SCIMMaxPageSize int = 1000
+
+type SCIMMeta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location"`
+}
+
+type SCIMMultiValuedAttribute struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type SCIMUser struct {
+	Schemas  []string                   `json:"schemas"`
+	ID       string                     `json:"id,omitempty"`
+	UserName string                     `json:"userName"`
+	Active   *bool                      `json:"active,omitempty"`
+	Emails   []SCIMMultiValuedAttribute `json:"emails,omitempty"`
+	Groups   []SCIMMultiValuedAttribute `json:"groups,omitempty"`
+	Meta     *SCIMMeta                  `json:"meta,omitempty"`
+}
+
+type SCIMGroup struct {
+	Schemas     []string                   `json:"schemas"`
+	ID          string                     `json:"id,omitempty"`
+	ExternalID  string                     `json:"externalId,omitempty"`
+	DisplayName string                     `json:"displayName"`
+	Members     []SCIMMultiValuedAttribute `json:"members"`
+	Meta        *SCIMMeta                  `json:"meta,omitempty"`
+}
+
+type SCIMListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int           `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+type SCIMPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []SCIMPatchOperation `json:"Operations"`
+}
+
+type SCIMPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+type SCIMError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	SCIMType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+type SCIMSupported struct {
+	Supported bool `json:"supported"`
+}
+
+type SCIMFilterSupported struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+type SCIMBulkSupported struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"`
+}
+
+type SCIMAuthenticationScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type SCIMServiceProviderConfig struct {
+	Schemas               []string                   `json:"schemas"`
+	Patch                 SCIMSupported              `json:"patch"`
+	Bulk                  SCIMBulkSupported          `json:"bulk"`
+	Filter                SCIMFilterSupported        `json:"filter"`
+	ChangePassword        SCIMSupported              `json:"changePassword"`
+	Sort                  SCIMSupported              `json:"sort"`
+	ETag                  SCIMSupported              `json:"etag"`
+	AuthenticationSchemes []SCIMAuthenticationScheme `json:"authenticationSchemes"`
+}
+
+type SCIMResourceType struct {
+	Schemas  []string `json:"schemas"`
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Endpoint string   `json:"endpoint"`
+	Schema   string   `json:"schema"`
+}
+
+// scimFilterRegex matches the only filter expression supported: attribute eq "value"
+var scimFilterRegex = regexp.MustCompile(`(?i)^\s*([a-z][a-z0-9.]*)\s+eq\s+"((?:[^"\\]|\\.)*)"\s*$`)
+
+// scimMemberPathRegex matches value filtered member paths such as members[value eq "id"]
+var scimMemberPathRegex = regexp.MustCompile(`(?i)^\s*members\s*\[\s*value\s+eq\s+"([^"]*)"\s*\]\s*$`)
+
+func (router *SCIMRouter) setupRoutes(s *mux.Router) {
+	s.HandleFunc("/ServiceProviderConfig", router.getServiceProviderConfig).Methods("GET")
+	s.HandleFunc("/ResourceTypes", router.getResourceTypes).Methods("GET")
+	s.HandleFunc("/Users/{id}", router.getUser).Methods("GET")
+	s.HandleFunc("/Users/{id}", router.replaceUser).Methods("PUT")
+	s.HandleFunc("/Users/{id}", router.patchUser).Methods("PATCH")
+	s.HandleFunc("/Users/{id}", router.deleteUser).Methods("DELETE")
+	s.HandleFunc("/Users", router.createUser).Methods("POST")
+	s.HandleFunc("/Users", router.getUsers).Methods("GET")
+	s.HandleFunc("/Groups/{id}", router.getGroup).Methods("GET")
+	s.HandleFunc("/Groups/{id}", router.replaceGroup).Methods("PUT")
+	s.HandleFunc("/Groups/{id}", router.patchGroup).Methods("PATCH")
+	s.HandleFunc("/Groups/{id}", router.deleteGroup).Methods("DELETE")
+	s.HandleFunc("/Groups", router.createGroup).Methods("POST")
+	s.HandleFunc("/Groups", router.getGroups).Methods("GET")
+}
+
+func (router *SCIMRouter) getServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	if _, ok := router.getOrganization(w, r); !ok {
+		return
+	}
+	res := &SCIMServiceProviderConfig{
+		Schemas: []string{SCIMSchemaServiceProviderConfig},
+		Patch:   SCIMSupported{Supported: true},
+		Filter:  SCIMFilterSupported{Supported: true, MaxResults: SCIMMaxPageSize},
+		AuthenticationSchemes: []SCIMAuthenticationScheme{{
+			Type:        "oauthbearertoken",
+			Name:        "OAuth Bearer Token",
+			Description: "Authentication using the organization's SCIM token",
+		}},
+	}
+	router.sendJSON(w, http.StatusOK, res)
+}
+
+func (router *SCIMRouter) getResourceTypes(w http.ResponseWriter, r *http.Request) {
+	if _, ok := router.getOrganization(w, r); !ok {
+		return
+	}
+	list := []interface{}{
+		&SCIMResourceType{
+			Schemas:  []string{SCIMSchemaResourceType},
+			ID:       "User",
+			Name:     "User",
+			Endpoint: "/Users",
+			Schema:   SCIMSchemaUser,
+		},
+		&SCIMResourceType{
+			Schemas:  []string{SCIMSchemaResourceType},
+			ID:       "Group",
+			Name:     "Group",
+			Endpoint: "/Groups",
+			Schema:   SCIMSchemaGroup,
+		},
+	}
+	router.sendList(w, list, len(list), 1)
+}
+
+func (router *SCIMRouter) getUsers(w http.ResponseWriter, r *http.Request) {
+	org, ok := router.getOrganization(w, r)
+	if !ok {
+		return
+	}
+	startIndex, count, ok := router.getPagination(w, r)
+	if !ok {
+		return
+	}
+	var list []*User
+	total := 0
+	if filter := r.URL.Query().Get("filter"); filter != "" {
+		attr, value, ok := router.parseFilter(filter)
+		if !ok {
+			router.sendError(w, http.StatusBadRequest, "invalidFilter", "Only 'attribute eq \"value\"' filters are supported")
+			return
+		}
+		var e *User
+		var err error
+		switch attr {
+		case "username", "emails", "emails.value":
+			e, err = router.repos.User.GetByEmail(value)
+		case "id":
+			e, err = router.repos.User.GetOne(value)
+		default:
+			router.sendError(w, http.StatusBadRequest, "invalidFilter", "Unsupported filter attribute: "+attr)
+			return
+		}
+		if err == nil && e.OrganizationID == org.ID {
+			total = 1
+			if startIndex == 1 && count > 0 {
+				list = append(list, e)
+			}
+		}
+	} else {
+		var err error
+		if total, err = router.repos.User.GetCount(org.ID); err != nil {
+			log.Println(err)
+			router.sendError(w, http.StatusInternalServerError, "", "")
+			return
+		}
+		if list, err = router.repos.User.GetAll(org.ID, count, startIndex-1); err != nil {
+			log.Println(err)
+			router.sendError(w, http.StatusInternalServerError, "", "")
+			return
+		}
+	}
+	res := []interface{}{}
+	for _, e := range list {
+		res = append(res, router.copyUserToSCIMModel(e))
+	}
+	router.sendList(w, res, total, startIndex)
+}
+
+func (router *SCIMRouter) getUser(w http.ResponseWriter, r *http.Request) {
+	org, ok := router.getOrganization(w, r)
+	if !ok {
+		return
+	}
+	e, ok := router.getUserOfOrg(w, r, org)
+	if !ok {
+		return
+	}
+	router.sendJSON(w, http.StatusOK, router.copyUserToSCIMModel(e))
+}
+
+func (router *SCIMRouter) createUser(w http.ResponseWriter, r *http.Request) {
+	org, ok := router.getOrganization(w, r)
+	if !ok {
+		return
+	}
+	var m SCIMUser
+	if UnmarshalBody(r, &m) != nil || m.UserName == "" {
+		router.sendError(w, http.StatusBadRequest, "invalidValue", "userName is required")
+		return
+	}
+	if _, err := router.repos.User.GetByEmail(m.UserName); err == nil {
+		router.sendError(w, http.StatusConflict, "uniqueness", "userName is already in use")
+		return
+	}
+	if !router.repos.User.canCreateUser(org) {
+		router.sendError(w, http.StatusPaymentRequired, "", "Maximum number of users reached")
+		return
+	}
+	if !router.repos.Organization.isValidEmailForOrg(m.UserName, org) {
+		router.sendError(w, http.StatusBadRequest, "invalidValue", "userName must be an email address of a verified domain")
+		return
+	}
+	e := &User{
+		OrganizationID: org.ID,
+		Email:          m.UserName,
+		Role:           UserRoleUser,
+		Disabled:       m.Active != nil && !*m.Active,
+	}
+	if err := router.repos.User.Create(e); err != nil {
+		log.Println(err)
+		router.sendError(w, http.StatusInternalServerError, "", "")
+		return
+	}
+	userRouter := &UserRouter{repos: router.repos}
+	fireWebhookEvent(router.repos, e.OrganizationID, WebhookEventUserCreated, userRouter.copyToRestModel(e, true))
+	res := router.copyUserToSCIMModel(e)
+	w.Header().Set("Location", res.Meta.Location)
+	router.sendJSON(w, http.StatusCreated, res)
+}
+
+func (router *SCIMRouter) replaceUser(w http.ResponseWriter, r *http.Request) {
+	org, ok := router.getOrganization(w, r)
+	if !ok {
+		return
+	}
+	e, ok := router.getUserOfOrg(w, r, org)
+	if !ok {
+		return
+	}
+	var m SCIMUser
+	if UnmarshalBody(r, &m) != nil || m.UserName == "" {
+		router.sendError(w, http.StatusBadRequest, "invalidValue", "userName is required")
+		return
+	}
+	wasDisabled := e.Disabled
+	// Omitted attributes keep their value, so a replace doesn't reactivate a deprovisioned user
+	if m.Active != nil {
+		e.Disabled = !*m.Active
+	}
+	if !router.setUserName(w, org, e, m.UserName) {
+		return
+	}
+	if !router.saveUser(w, e, wasDisabled) {
+		return
+	}
+	router.sendJSON(w, http.StatusOK, router.copyUserToSCIMModel(e))
+}
+
+func (router *SCIMRouter) patchUser(w http.ResponseWriter, r *http.Request) {
+	org, ok := router.getOrganization(w, r)
+	if !ok {
+		return
+	}
+	e, ok := router.getUserOfOrg(w, r, org)
+	if !ok {
+		return
+	}
+	var m SCIMPatchRequest
+	if UnmarshalBody(r, &m) != nil {
+		router.sendError(w, http.StatusBadRequest, "invalidSyntax", "")
+		return
+	}
+	wasDisabled := e.Disabled
+	for _, op := range m.Operations {
+		if !strings.EqualFold(op.Op, "add") && !strings.EqualFold(op.Op, "replace") {
+			// Removing userName or active is not possible, other attributes are not stored
+			continue
+		}
+		values := map[string]json.RawMessage{}
+		if op.Path == "" {
+			if json.Unmarshal(op.Value, &values) != nil {
+				router.sendError(w, http.StatusBadRequest, "invalidValue", "")
+				return
+			}
+		} else {
+			values[op.Path] = op.Value
+		}
+		for attr, value := range values {
+			switch strings.ToLower(attr) {
+			case "active":
+				active, ok := router.parseBool(value)
+				if !ok {
+					router.sendError(w, http.StatusBadRequest, "invalidValue", "active must be a boolean")
+					return
+				}
+				e.Disabled = !active
+			case "username":
+				var userName string
+				if json.Unmarshal(value, &userName) != nil || userName == "" {
+					router.sendError(w, http.StatusBadRequest, "invalidValue", "userName must be a string")
+					return
+				}
+				if !router.setUserName(w, org, e, userName) {
+					return
+				}
+			}
+		}
+	}
+	if !router.saveUser(w, e, wasDisabled) {
+		return
+	}
+	router.sendJSON(w, http.StatusOK, router.copyUserToSCIMModel(e))
+}
+
+func (router *SCIMRouter) deleteUser(w http.ResponseWriter, r *http.Request) {
+	org, ok := router.getOrganization(w, r)
+	if !ok {
+		return
+	}
+	e, ok := router.getUserOfOrg(w, r, org)
+	if !ok {
+		return
+	}
+	// Deprovisioning keeps the user and the booking history, like setting active to false
+	wasDisabled := e.Disabled
+	e.Disabled = true
+	if !router.saveUser(w, e, wasDisabled) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (router *SCIMRouter) getGroups(w http.ResponseWriter, r *http.Request) {
+	org, ok := router.getOrganization(w, r)
+	if !ok {
+		return
+	}
+	startIndex, count, ok := router.getPagination(w, r)
+	if !ok {
+		return
+	}
+	var list []*UserGroup
+	total := 0
+	if filter := r.URL.Query().Get("filter"); filter != "" {
+		attr, value, ok := router.parseFilter(filter)
+		if !ok {
+			router.sendError(w, http.StatusBadRequest, "invalidFilter", "Only 'attribute eq \"value\"' filters are supported")
+			return
+		}
+		var e *UserGroup
+		var err error
+		switch attr {
+		case "displayname":
+			e, err = router.repos.UserGroup.GetByName(org.ID, value)
+		case "externalid":
+			e, err = router.repos.UserGroup.GetByExternalID(org.ID, value)
+		case "id":
+			e, err = router.repos.UserGroup.GetOne(value)
+		default:
+			router.sendError(w, http.StatusBadRequest, "invalidFilter", "Unsupported filter attribute: "+attr)
+			return
+		}
+		if err == nil && e.OrganizationID == org.ID {
+			total = 1
+			if startIndex == 1 && count > 0 {
+				list = append(list, e)
+			}
+		}
+	} else {
+		var err error
+		if total, err = router.repos.UserGroup.GetCount(org.ID); err != nil {
+			log.Println(err)
+			router.sendError(w, http.StatusInternalServerError, "", "")
+			return
+		}
+		if list, err = router.repos.UserGroup.GetAll(org.ID, count, startIndex-1); err != nil {
+			log.Println(err)
+			router.sendError(w, http.StatusInternalServerError, "", "")
+			return
+		}
+	}
+	res := []interface{}{}
+	for _, e := range list {
+		m, err := router.copyGroupToSCIMModel(e)
+		if err != nil {
+			log.Println(err)
+			router.sendError(w, http.StatusInternalServerError, "", "")
+			return
+		}
+		res = append(res, m)
+	}
+	router.sendList(w, res, total, startIndex)
+}
+
+func (router *SCIMRouter) getGroup(w http.ResponseWriter, r *http.Request) {
+	org, ok := router.getOrganization(w, r)
+	if !ok {
+		return
+	}
+	e, ok := router.getGroupOfOrg(w, r, org)
+	if !ok {
+		return
+	}
+	router.sendGroup(w, http.StatusOK, e)
+}
+
+func (router *SCIMRouter) createGroup(w http.ResponseWriter, r *http.Request) {
+	org, ok := router.getOrganization(w, r)
+	if !ok {
+		return
+	}
+	var m SCIMGroup
+	if UnmarshalBody(r, &m) != nil || m.DisplayName == "" {
+		router.sendError(w, http.StatusBadRequest, "invalidValue", "displayName is required")
+		return
+	}
+	if _, err := router.repos.UserGroup.GetByName(org.ID, m.DisplayName); err == nil {
+		router.sendError(w, http.StatusConflict, "uniqueness", "displayName is already in use")
+		return
+	}
+	e := &UserGroup{
+		OrganizationID: org.ID,
+		Name:           m.DisplayName,
+		ExternalID:     m.ExternalID,
+	}
+	if err := router.repos.UserGroup.Create(e); err != nil {
+		log.Println(err)
+		router.sendError(w, http.StatusInternalServerError, "", "")
+		return
+	}
+	if err := router.repos.UserGroup.AddMembers(e, router.getMemberIDs(m.Members)); err != nil {
+		log.Println(err)
+		router.sendError(w, http.StatusInternalServerError, "", "")
+		return
+	}
+	w.Header().Set("Location", router.getLocation("Groups", e.ID))
+	router.sendGroup(w, http.StatusCreated, e)
+}
+
+func (router *SCIMRouter) replaceGroup(w http.ResponseWriter, r *http.Request) {
+	org, ok := router.getOrganization(w, r)
+	if !ok {
+		return
+	}
+	e, ok := router.getGroupOfOrg(w, r, org)
+	if !ok {
+		return
+	}
+	var m SCIMGroup
+	if UnmarshalBody(r, &m) != nil || m.DisplayName == "" {
+		router.sendError(w, http.StatusBadRequest, "invalidValue", "displayName is required")
+		return
+	}
+	if !router.setGroupName(w, org, e, m.DisplayName) {
+		return
+	}
+	e.ExternalID = m.ExternalID
+	if err := router.repos.UserGroup.Update(e); err != nil {
+		log.Println(err)
+		router.sendError(w, http.StatusInternalServerError, "", "")
+		return
+	}
+	if err := router.repos.UserGroup.RemoveAllMembers(e); err != nil {
+		log.Println(err)
+		router.sendError(w, http.StatusInternalServerError, "", "")
+		return
+	}
+	if err := router.repos.UserGroup.AddMembers(e, router.getMemberIDs(m.Members)); err != nil {
+		log.Println(err)
+		router.sendError(w, http.StatusInternalServerError, "", "")
+		return
+	}
+	router.sendGroup(w, http.StatusOK, e)
+}
+
+func (router *SCIMRouter) patchGroup(w http.ResponseWriter, r *http.Request) {
+	org, ok := router.getOrganization(w, r)
+	if !ok {
+		return
+	}
+	e, ok := router.getGroupOfOrg(w, r, org)
+	if !ok {
+		return
+	}
+	var m SCIMPatchRequest
+	if UnmarshalBody(r, &m) != nil {
+		router.sendError(w, http.StatusBadRequest, "invalidSyntax", "")
+		return
+	}
+	for _, op := range m.Operations {
+		if !router.applyGroupPatchOperation(w, org, e, &op) {
+			return
+		}
+	}
+	if err := router.repos.UserGroup.Update(e); err != nil {
+		log.Println(err)
+		router.sendError(w, http.StatusInternalServerError, "", "")
+		return
+	}
+	router.sendGroup(w, http.StatusOK, e)
+}
+
+func (router *SCIMRouter) applyGroupPatchOperation(w http.ResponseWriter, org *Organization, e *UserGroup, op *SCIMPatchOperation) bool {
+	opName := strings.ToLower(op.Op)
+	if opName != "add" && opName != "replace" && opName != "remove" {
+		router.sendError(w, http.StatusBadRequest, "invalidSyntax", "Unsupported operation: "+op.Op)
+		return false
+	}
+	if opName == "remove" {
+		var err error
+		if match := scimMemberPathRegex.FindStringSubmatch(op.Path); match != nil {
+			err = router.repos.UserGroup.RemoveMembers(e, router.getMemberIDs([]SCIMMultiValuedAttribute{{Value: match[1]}}))
+		} else if strings.EqualFold(op.Path, "members") {
+			var members []SCIMMultiValuedAttribute
+			if len(op.Value) > 0 && json.Unmarshal(op.Value, &members) == nil && len(members) > 0 {
+				err = router.repos.UserGroup.RemoveMembers(e, router.getMemberIDs(members))
+			} else {
+				err = router.repos.UserGroup.RemoveAllMembers(e)
+			}
+		} else if strings.EqualFold(op.Path, "externalId") {
+			e.ExternalID = ""
+		} else {
+			router.sendError(w, http.StatusBadRequest, "noTarget", "Unsupported path: "+op.Path)
+			return false
+		}
+		if err != nil {
+			log.Println(err)
+			router.sendError(w, http.StatusInternalServerError, "", "")
+			return false
+		}
+		return true
+	}
+	values := map[string]json.RawMessage{}
+	if op.Path == "" {
+		if json.Unmarshal(op.Value, &values) != nil {
+			router.sendError(w, http.StatusBadRequest, "invalidValue", "")
+			return false
+		}
+	} else {
+		values[op.Path] = op.Value
+	}
+	for attr, value := range values {
+		switch strings.ToLower(attr) {
+		case "displayname":
+			var name string
+			if json.Unmarshal(value, &name) != nil || name == "" {
+				router.sendError(w, http.StatusBadRequest, "invalidValue", "displayName must be a string")
+				return false
+			}
+			if !router.setGroupName(w, org, e, name) {
+				return false
+			}
+		case "externalid":
+			if json.Unmarshal(value, &e.ExternalID) != nil {
+				router.sendError(w, http.StatusBadRequest, "invalidValue", "externalId must be a string")
+				return false
+			}
+		case "members":
+			var members []SCIMMultiValuedAttribute
+			if json.Unmarshal(value, &members) != nil {
+				router.sendError(w, http.StatusBadRequest, "invalidValue", "members must be a list")
+				return false
+			}
+			if opName == "replace" {
+				if err := router.repos.UserGroup.RemoveAllMembers(e); err != nil {
+					log.Println(err)
+					router.sendError(w, http.StatusInternalServerError, "", "")
+					return false
+				}
+			}
+			if err := router.repos.UserGroup.AddMembers(e, router.getMemberIDs(members)); err != nil {
+				log.Println(err)
+				router.sendError(w, http.StatusInternalServerError, "", "")
+				return false
+			}
+		default:
+			router.sendError(w, http.StatusBadRequest, "noTarget", "Unsupported path: "+attr)
+			return false
+		}
+	}
+	return true
+}
+
+func (router *SCIMRouter) deleteGroup(w http.ResponseWriter, r *http.Request) {
+	org, ok := router.getOrganization(w, r)
+	if !ok {
+		return
+	}
+	e, ok := router.getGroupOfOrg(w, r, org)
+	if !ok {
+		return
+	}
+	if err := router.repos.UserGroup.Delete(e); err != nil {
+		log.Println(err)
+		router.sendError(w, http.StatusInternalServerError, "", "")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getOrganization authenticates the request with the bearer token and returns the token's organization.
+func (router *SCIMRouter) getOrganization(w http.ResponseWriter, r *http.Request) (*Organization, bool) {
+	authHeader := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		router.sendError(w, http.StatusUnauthorized, "", "Missing bearer token")
+		return nil, false
+	}
+	token, err := router.repos.SCIMToken.GetByToken(strings.TrimPrefix(authHeader, "Bearer "))
+	if err != nil {
+		router.sendError(w, http.StatusUnauthorized, "", "Invalid bearer token")
+		return nil, false
+	}
+	org, err := router.repos.Organization.GetOne(token.OrganizationID)
+	if err != nil {
+		log.Println(err)
+		router.sendError(w, http.StatusUnauthorized, "", "Invalid bearer token")
+		return nil, false
+	}
+	return org, true
+}
+
+func (router *SCIMRouter) getUserOfOrg(w http.ResponseWriter, r *http.Request, org *Organization) (*User, bool) {
+	vars := mux.Vars(r)
+	e, err := router.repos.User.GetOne(vars["id"])
+	if err != nil || e.OrganizationID != org.ID {
+		router.sendError(w, http.StatusNotFound, "", "User not found")
+		return nil, false
+	}
+	return e, true
+}
+
+func (router *SCIMRouter) getGroupOfOrg(w http.ResponseWriter, r *http.Request, org *Organization) (*UserGroup, bool) {
+	vars := mux.Vars(r)
+	e, err := router.repos.UserGroup.GetOne(vars["id"])
+	if err != nil || e.OrganizationID != org.ID {
+		router.sendError(w, http.StatusNotFound, "", "Group not found")
+		return nil, false
+	}
+	return e, true
+}
+
+func (router *SCIMRouter) setUserName(w http.ResponseWriter, org *Organization, e *User, userName string) bool {
+	if strings.EqualFold(e.Email, userName) {
+		return true
+	}
+	if _, err := router.repos.User.GetByEmail(userName); err == nil {
+		router.sendError(w, http.StatusConflict, "uniqueness", "userName is already in use")
+		return false
+	}
+	if !router.repos.Organization.isValidEmailForOrg(userName, org) {
+		router.sendError(w, http.StatusBadRequest, "invalidValue", "userName must be an email address of a verified domain")
+		return false
+	}
+	e.Email = userName
+	return true
+}
+
+func (router *SCIMRouter) setGroupName(w http.ResponseWriter, org *Organization, e *UserGroup, name string) bool {
+	if e.Name == name {
+		return true
+	}
+	if _, err := router.repos.UserGroup.GetByName(org.ID, name); err == nil {
+		router.sendError(w, http.StatusConflict, "uniqueness", "displayName is already in use")
+		return false
+	}
+	e.Name = name
+	return true
+}
+
+// saveUser stores the user. Deprovisioned users get their upcoming bookings cancelled.
+func (router *SCIMRouter) saveUser(w http.ResponseWriter, e *User, wasDisabled bool) bool {
+	if err := router.repos.User.Update(e); err != nil {
+		log.Println(err)
+		router.sendError(w, http.StatusInternalServerError, "", "")
+		return false
+	}
+	if e.Disabled && !wasDisabled {
+		bookingRouter := &BookingRouter{repos: router.repos}
+		if err := bookingRouter.cancelFutureBookings(e); err != nil {
+			log.Println(err)
+			router.sendError(w, http.StatusInternalServerError, "", "")
+			return false
+		}
+	}
+	return true
+}
+
+// getPagination returns the 1-based start index and the page size requested.
+func (router *SCIMRouter) getPagination(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	startIndex := 1
+	count := SCIMDefaultPageSize
+	if s := r.URL.Query().Get("startIndex"); s != "" {
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			router.sendError(w, http.StatusBadRequest, "invalidValue", "startIndex must be an integer")
+			return 0, 0, false
+		}
+		// Values less than 1 are interpreted as 1 (RFC 7644, section 3.4.2.4)
+		startIndex = MaxOf(i, 1)
+	}
+	if s := r.URL.Query().Get("count"); s != "" {
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			router.sendError(w, http.StatusBadRequest, "invalidValue", "count must be an integer")
+			return 0, 0, false
+		}
+		count = MaxOf(i, 0)
+	}
+	if count > SCIMMaxPageSize {
+		count = SCIMMaxPageSize
+	}
+	return startIndex, count, true
+}
+
+// parseFilter returns the lower case attribute name and the value of an equality filter.
+func (router *SCIMRouter) parseFilter(filter string) (string, string, bool) {
+	match := scimFilterRegex.FindStringSubmatch(filter)
+	if match == nil {
+		return "", "", false
+	}
+	var value string
+	if json.Unmarshal([]byte("\""+match[2]+"\""), &value) != nil {
+		return "", "", false
+	}
+	return strings.ToLower(match[1]), value, true
+}
+
+// parseBool accepts JSON booleans as well as the strings "true" and "false"
+// some identity providers send instead.
+func (router *SCIMRouter) parseBool(value json.RawMessage) (bool, bool) {
+	var b bool
+	if json.Unmarshal(value, &b) == nil {
+		return b, true
+	}
+	var s string
+	if json.Unmarshal(value, &s) != nil {
+		return false, false
+	}
+	b, err := strconv.ParseBool(strings.ToLower(s))
+	return b, err == nil
+}
+
+func (router *SCIMRouter) getMemberIDs(members []SCIMMultiValuedAttribute) []string {
+	res := []string{}
+	for _, member := range members {
+		if _, err := uuid.Parse(member.Value); err == nil {
+			res = append(res, member.Value)
+		}
+	}
+	return res
+}
+
+func (router *SCIMRouter) getLocation(resourceType, id string) string {
+	return GetConfig().PublicURL + "scim/v2/" + resourceType + "/" + id
+}
+
+func (router *SCIMRouter) copyUserToSCIMModel(e *User) *SCIMUser {
+	active := !e.Disabled
+	m := &SCIMUser{
+		Schemas:  []string{SCIMSchemaUser},
+		ID:       e.ID,
+		UserName: e.Email,
+		Active:   &active,
+		Emails: []SCIMMultiValuedAttribute{{
+			Value:   e.Email,
+			Type:    "work",
+			Primary: true,
+		}},
+		Meta: &SCIMMeta{
+			ResourceType: "User",
+			Location:     router.getLocation("Users", e.ID),
+		},
+	}
+	if groups, err := router.repos.UserGroup.GetAllByUser(e.ID); err == nil {
+		for _, group := range groups {
+			m.Groups = append(m.Groups, SCIMMultiValuedAttribute{
+				Value:   group.ID,
+				Display: group.Name,
+			})
+		}
+	} else {
+		log.Println(err)
+	}
+	return m
+}
+
+func (router *SCIMRouter) copyGroupToSCIMModel(e *UserGroup) (*SCIMGroup, error) {
+	m := &SCIMGroup{
+		Schemas:     []string{SCIMSchemaGroup},
+		ID:          e.ID,
+		ExternalID:  e.ExternalID,
+		DisplayName: e.Name,
+		Members:     []SCIMMultiValuedAttribute{},
+		Meta: &SCIMMeta{
+			ResourceType: "Group",
+			Location:     router.getLocation("Groups", e.ID),
+		},
+	}
+	members, err := router.repos.UserGroup.GetMembers(e)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		m.Members = append(m.Members, SCIMMultiValuedAttribute{
+			Value:   member.ID,
+			Display: member.Email,
+		})
+	}
+	return m, nil
+}
+
+func (router *SCIMRouter) sendGroup(w http.ResponseWriter, status int, e *UserGroup) {
+	res, err := router.copyGroupToSCIMModel(e)
+	if err != nil {
+		log.Println(err)
+		router.sendError(w, http.StatusInternalServerError, "", "")
+		return
+	}
+	router.sendJSON(w, status, res)
+}
+
+func (router *SCIMRouter) sendList(w http.ResponseWriter, list []interface{}, total, startIndex int) {
+	res := &SCIMListResponse{
+		Schemas:      []string{SCIMSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(list),
+		Resources:    list,
+	}
+	router.sendJSON(w, http.StatusOK, res)
+}
+
+func (router *SCIMRouter) sendError(w http.ResponseWriter, status int, scimType, detail string) {
+	res := &SCIMError{
+		Schemas:  []string{SCIMSchemaError},
+		Status:   strconv.Itoa(status),
+		SCIMType: scimType,
+		Detail:   detail,
+	}
+	router.sendJSON(w, status, res)
+}
+
+func (router *SCIMRouter) sendJSON(w http.ResponseWriter, status int, v interface{}) {
+	json, err := json.Marshal(v)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	w.Header().Set("Content-Type", SCIMContentType)
+	w.WriteHeader(status)
+	w.Write(json)
+}
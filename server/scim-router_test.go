@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func createTestSCIMToken(t *testing.T, org *Organization) string {
+	admin := createTestUserOrgAdmin(org)
+	req := newHTTPRequest("POST", "/organization/"+org.ID+"/scim", admin.ID, nil)
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody *GetSCIMTokenResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestBool(t, true, resBody.Enabled)
+	checkStringNotEmpty(t, resBody.Token)
+	return resBody.Token
+}
+
+func TestSCIMUnauthorized(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	user := createTestUserInOrg(org)
+	createTestSCIMToken(t, org)
+
+	req := newHTTPRequestWithAccessToken("GET", "/scim/v2/Users", "invalid", nil)
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusUnauthorized, res.Code)
+
+	// User JWTs are not accepted
+	req = newHTTPRequest("GET", "/scim/v2/Users", user.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusUnauthorized, res.Code)
+
+	// Only admins can generate tokens
+	req = newHTTPRequest("POST", "/organization/"+org.ID+"/scim", user.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+}
+
+func TestSCIMUsersCRUD(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	token := createTestSCIMToken(t, org)
+
+	// 1. Create
+	payload := `{"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"], "userName": "scim.user@test.com", "active": true}`
+	req := newHTTPRequestWithAccessToken("POST", "/scim/v2/Users", token, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	checkTestString(t, SCIMContentType, res.Header().Get("Content-Type"))
+	var resBody *SCIMUser
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkStringNotEmpty(t, resBody.ID)
+	checkTestString(t, "scim.user@test.com", resBody.UserName)
+	checkTestBool(t, true, *resBody.Active)
+	id := resBody.ID
+
+	// Duplicate userName
+	req = newHTTPRequestWithAccessToken("POST", "/scim/v2/Users", token, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusConflict, res.Code)
+
+	// Domain not verified
+	payload = `{"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"], "userName": "scim.user@other.com"}`
+	req = newHTTPRequestWithAccessToken("POST", "/scim/v2/Users", token, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+
+	// 2. Filter
+	req = newHTTPRequestWithAccessToken("GET", "/scim/v2/Users?filter="+url.QueryEscape(`userName eq "SCIM.User@test.com"`), token, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody2 *SCIMListResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody2)
+	checkTestInt(t, 1, resBody2.TotalResults)
+	checkTestInt(t, 1, len(resBody2.Resources))
+	checkTestString(t, id, resBody2.Resources[0].(map[string]interface{})["id"].(string))
+
+	req = newHTTPRequestWithAccessToken("GET", "/scim/v2/Users?filter="+url.QueryEscape(`userName eq "nobody@test.com"`), token, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody3 *SCIMListResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody3)
+	checkTestInt(t, 0, resBody3.TotalResults)
+
+	req = newHTTPRequestWithAccessToken("GET", "/scim/v2/Users?filter="+url.QueryEscape(`userName sw "scim"`), token, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+
+	// 3. Replace
+	payload = `{"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"], "userName": "scim.renamed@test.com", "active": true}`
+	req = newHTTPRequestWithAccessToken("PUT", "/scim/v2/Users/"+id, token, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	user, _ := testRepositories.User.GetOne(id)
+	checkTestString(t, "scim.renamed@test.com", user.Email)
+
+	// 4. Delete disables the user
+	req = newHTTPRequestWithAccessToken("DELETE", "/scim/v2/Users/"+id, token, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	req = newHTTPRequestWithAccessToken("GET", "/scim/v2/Users/"+id, token, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody4 *SCIMUser
+	json.Unmarshal(res.Body.Bytes(), &resBody4)
+	checkTestBool(t, false, *resBody4.Active)
+
+	// Replace without active keeps the user disabled
+	payload = `{"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"], "userName": "scim.renamed@test.com"}`
+	req = newHTTPRequestWithAccessToken("PUT", "/scim/v2/Users/"+id, token, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	user, _ = testRepositories.User.GetOne(id)
+	checkTestBool(t, true, user.Disabled)
+}
+
+func TestSCIMUsersPagination(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	token := createTestSCIMToken(t, org)
+	createTestUserInOrg(org)
+	createTestUserInOrg(org)
+
+	// Org admin created by createTestSCIMToken plus two users
+	req := newHTTPRequestWithAccessToken("GET", "/scim/v2/Users?startIndex=2&count=1", token, nil)
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody *SCIMListResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestInt(t, 3, resBody.TotalResults)
+	checkTestInt(t, 2, resBody.StartIndex)
+	checkTestInt(t, 1, resBody.ItemsPerPage)
+	checkTestInt(t, 1, len(resBody.Resources))
+}
+
+func TestSCIMUsersDeprovision(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	token := createTestSCIMToken(t, org)
+	user := createTestUserInOrg(org)
+
+	l := &Location{
+		Name:           "Test",
+		OrganizationID: org.ID,
+	}
+	testRepositories.Location.Create(l)
+	s1 := &Space{Name: "Test 1", LocationID: l.ID}
+	testRepositories.Space.Create(s1)
+	past := &Booking{
+		UserID:  user.ID,
+		SpaceID: s1.ID,
+		Enter:   time.Now().UTC().Add(-72 * time.Hour),
+		Leave:   time.Now().UTC().Add(-64 * time.Hour),
+	}
+	testRepositories.Booking.Create(past)
+	future := &Booking{
+		UserID:  user.ID,
+		SpaceID: s1.ID,
+		Enter:   time.Now().UTC().Add(48 * time.Hour),
+		Leave:   time.Now().UTC().Add(56 * time.Hour),
+	}
+	testRepositories.Booking.Create(future)
+
+	// Some identity providers send booleans as strings
+	payload := `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "Replace", "path": "active", "value": "False"}]}`
+	req := newHTTPRequestWithAccessToken("PATCH", "/scim/v2/Users/"+user.ID, token, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody *SCIMUser
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestBool(t, false, *resBody.Active)
+
+	user, _ = testRepositories.User.GetOne(user.ID)
+	checkTestBool(t, true, user.Disabled)
+	_, err := testRepositories.Booking.GetOne(future.ID)
+	checkTestBool(t, true, err != nil)
+	_, err = testRepositories.Booking.GetOne(past.ID)
+	checkTestBool(t, true, err == nil)
+
+	// Reactivate
+	payload = `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "value": {"active": true}}]}`
+	req = newHTTPRequestWithAccessToken("PATCH", "/scim/v2/Users/"+user.ID, token, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	user, _ = testRepositories.User.GetOne(user.ID)
+	checkTestBool(t, false, user.Disabled)
+}
+
+func TestSCIMUsersDelete(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	token := createTestSCIMToken(t, org)
+	user := createTestUserInOrg(org)
+
+	l := &Location{
+		Name:           "Test",
+		OrganizationID: org.ID,
+	}
+	testRepositories.Location.Create(l)
+	s1 := &Space{Name: "Test 1", LocationID: l.ID}
+	testRepositories.Space.Create(s1)
+	past := &Booking{
+		UserID:  user.ID,
+		SpaceID: s1.ID,
+		Enter:   time.Now().UTC().Add(-72 * time.Hour),
+		Leave:   time.Now().UTC().Add(-64 * time.Hour),
+	}
+	testRepositories.Booking.Create(past)
+	future := &Booking{
+		UserID:  user.ID,
+		SpaceID: s1.ID,
+		Enter:   time.Now().UTC().Add(48 * time.Hour),
+		Leave:   time.Now().UTC().Add(56 * time.Hour),
+	}
+	testRepositories.Booking.Create(future)
+
+	req := newHTTPRequestWithAccessToken("DELETE", "/scim/v2/Users/"+user.ID, token, nil)
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	user, err := testRepositories.User.GetOne(user.ID)
+	checkTestBool(t, true, err == nil)
+	checkTestBool(t, true, user.Disabled)
+	_, err = testRepositories.Booking.GetOne(future.ID)
+	checkTestBool(t, true, err != nil)
+	_, err = testRepositories.Booking.GetOne(past.ID)
+	checkTestBool(t, true, err == nil)
+}
+
+func TestSCIMGroups(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	token := createTestSCIMToken(t, org)
+	user1 := createTestUserInOrg(org)
+	user2 := createTestUserInOrg(org)
+	otherUser := createTestUser("other.com")
+
+	// 1. Create, members of other orgs are ignored
+	payload := `{"schemas": ["urn:ietf:params:scim:schemas:core:2.0:Group"], "displayName": "Team A", "externalId": "ext-1", "members": [{"value": "` + user1.ID + `"}, {"value": "` + otherUser.ID + `"}]}`
+	req := newHTTPRequestWithAccessToken("POST", "/scim/v2/Groups", token, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	var resBody *SCIMGroup
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	id := resBody.ID
+	checkTestString(t, "Team A", resBody.DisplayName)
+	checkTestInt(t, 1, len(resBody.Members))
+	checkTestString(t, user1.ID, resBody.Members[0].Value)
+
+	// 2. Patch members and name
+	payload = `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [` +
+		`{"op": "add", "path": "members", "value": [{"value": "` + user2.ID + `"}]}, ` +
+		`{"op": "remove", "path": "members[value eq \"` + user1.ID + `\"]"}, ` +
+		`{"op": "replace", "path": "displayName", "value": "Team B"}]}`
+	req = newHTTPRequestWithAccessToken("PATCH", "/scim/v2/Groups/"+id, token, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody2 *SCIMGroup
+	json.Unmarshal(res.Body.Bytes(), &resBody2)
+	checkTestString(t, "Team B", resBody2.DisplayName)
+	checkTestInt(t, 1, len(resBody2.Members))
+	checkTestString(t, user2.ID, resBody2.Members[0].Value)
+
+	// Group membership is listed on the user
+	req = newHTTPRequestWithAccessToken("GET", "/scim/v2/Users/"+user2.ID, token, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody3 *SCIMUser
+	json.Unmarshal(res.Body.Bytes(), &resBody3)
+	checkTestInt(t, 1, len(resBody3.Groups))
+	checkTestString(t, "Team B", resBody3.Groups[0].Display)
+
+	// 3. Filter
+	req = newHTTPRequestWithAccessToken("GET", "/scim/v2/Groups?filter="+url.QueryEscape(`externalId eq "ext-1"`), token, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody4 *SCIMListResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody4)
+	checkTestInt(t, 1, resBody4.TotalResults)
+
+	// 4. Delete
+	req = newHTTPRequestWithAccessToken("DELETE", "/scim/v2/Groups/"+id, token, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	req = newHTTPRequestWithAccessToken("GET", "/scim/v2/Groups/"+id, token, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNotFound, res.Code)
+}
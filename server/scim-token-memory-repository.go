@@ -0,0 +1,56 @@
+package main
+
+type MemorySCIMTokenRepository struct {
+	scimTokenRepositoryBase
+}
+
+func (r *MemorySCIMTokenRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemorySCIMTokenRepository) Set(e *SCIMToken) error {
+	if err := memoryCheckUUID(e.OrganizationID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	if memoryCount(memoryData.scimTokens, func(row *SCIMToken) bool {
+		return row.TokenHash == e.TokenHash && row.OrganizationID != e.OrganizationID
+	}) > 0 {
+		return memoryUniqueViolation("idx_scim_tokens_token_hash")
+	}
+	memoryData.scimTokens = memoryDelete(memoryData.scimTokens, func(row *SCIMToken) bool {
+		return row.OrganizationID == e.OrganizationID
+	})
+	row := *e
+	row.Created = memoryTimestamp(e.Created)
+	memoryData.scimTokens = append(memoryData.scimTokens, &row)
+	return nil
+}
+
+func (r *MemorySCIMTokenRepository) GetByOrg(organizationID string) (*SCIMToken, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryGet(memoryData.scimTokens, func(e *SCIMToken) bool {
+		return e.OrganizationID == organizationID
+	})
+}
+
+func (r *MemorySCIMTokenRepository) GetByToken(token string) (*SCIMToken, error) {
+	tokenHash := r.GetTokenHash(token)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryGet(memoryData.scimTokens, func(e *SCIMToken) bool {
+		return e.TokenHash == tokenHash
+	})
+}
+
+func (r *MemorySCIMTokenRepository) DeleteAll(organizationID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.scimTokens = memoryDelete(memoryData.scimTokens, func(row *SCIMToken) bool {
+		return row.OrganizationID == organizationID
+	})
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+type SCIMTokenRepository interface {
+	Repository
+	Set(e *SCIMToken) error
+	GetByOrg(organizationID string) (*SCIMToken, error)
+	GetByToken(token string) (*SCIMToken, error)
+	DeleteAll(organizationID string) error
+	GetTokenHash(token string) string
+}
+
+type PostgresSCIMTokenRepository struct {
+	scimTokenRepositoryBase
+}
+
+// scimTokenRepositoryBase hashes tokens the same way for all implementations.
+type scimTokenRepositoryBase struct {
+}
+
+// SCIMToken authenticates an identity provider's provisioning requests for an
+// organization. Only the SHA-256 hash of the bearer token is stored, so the
+// token itself is shown once when it is generated.
+type SCIMToken struct {
+	OrganizationID string
+	TokenHash      string
+	Created        time.Time
+}
+
+func (r *PostgresSCIMTokenRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		{
+			Version:     28,
+			Description: "Add SCIM tokens",
+			Up: []string{
+				"CREATE TABLE IF NOT EXISTS scim_tokens (" +
+					"organization_id uuid NOT NULL, " +
+					"token_hash VARCHAR NOT NULL, " +
+					"created TIMESTAMP NOT NULL, " +
+					"PRIMARY KEY (organization_id))",
+				"CREATE UNIQUE INDEX IF NOT EXISTS idx_scim_tokens_token_hash ON scim_tokens(token_hash)",
+			},
+			Down: []string{"DROP TABLE IF EXISTS scim_tokens"},
+		},
+	}
+}
+
+// Set stores the token of the organization, replacing any previous one.
+func (r *PostgresSCIMTokenRepository) Set(e *SCIMToken) error {
+	_, err := GetDatabase().DB().Exec("INSERT INTO scim_tokens (organization_id, token_hash, created) "+
+		"VALUES ($1, $2, $3) "+
+		"ON CONFLICT (organization_id) DO UPDATE SET token_hash = $2, created = $3",
+		e.OrganizationID, e.TokenHash, e.Created)
+	return err
+}
+
+func (r *PostgresSCIMTokenRepository) GetByOrg(organizationID string) (*SCIMToken, error) {
+	e := &SCIMToken{}
+	err := GetDatabase().DB().QueryRow("SELECT organization_id, token_hash, created "+
+		"FROM scim_tokens "+
+		"WHERE organization_id = $1",
+		organizationID).Scan(&e.OrganizationID, &e.TokenHash, &e.Created)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (r *PostgresSCIMTokenRepository) GetByToken(token string) (*SCIMToken, error) {
+	e := &SCIMToken{}
+	err := GetDatabase().DB().QueryRow("SELECT organization_id, token_hash, created "+
+		"FROM scim_tokens "+
+		"WHERE token_hash = $1",
+		r.GetTokenHash(token)).Scan(&e.OrganizationID, &e.TokenHash, &e.Created)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (r *PostgresSCIMTokenRepository) DeleteAll(organizationID string) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM scim_tokens WHERE organization_id = $1", organizationID)
+	return err
+}
+
+func (r *scimTokenRepositoryBase) GetTokenHash(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
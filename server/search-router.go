@@ -8,6 +8,7 @@ import (
 )
 
 type SearchRouter struct {
+	repos *Repositories
 }
 
 type GetSearchResultsResponse struct {
@@ -21,7 +22,7 @@ func (router *SearchRouter) setupRoutes(s *mux.Router) {
 }
 
 func (router *SearchRouter) getResults(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanSpaceAdminOrg(user, user.OrganizationID) {
 		SendForbidden(w)
 		return
@@ -52,11 +53,11 @@ func (router *SearchRouter) getResults(w http.ResponseWriter, r *http.Request) {
 }
 
 func (router *SearchRouter) addUserResults(user *User, keyword string, res *GetSearchResultsResponse) error {
-	list, err := GetUserRepository().GetByKeyword(user.OrganizationID, keyword)
+	list, err := router.repos.User.GetByKeyword(user.OrganizationID, keyword)
 	if err != nil {
 		return err
 	}
-	userRouter := &UserRouter{}
+	userRouter := &UserRouter{repos: router.repos}
 	for _, e := range list {
 		m := userRouter.copyToRestModel(e, true)
 		res.Users = append(res.Users, m)
@@ -65,11 +66,11 @@ func (router *SearchRouter) addUserResults(user *User, keyword string, res *GetS
 }
 
 func (router *SearchRouter) addLocationResults(user *User, keyword string, res *GetSearchResultsResponse) error {
-	list, err := GetLocationRepository().GetByKeyword(user.OrganizationID, keyword)
+	list, err := router.repos.Location.GetByKeyword(user.OrganizationID, keyword)
 	if err != nil {
 		return err
 	}
-	locationRouter := &LocationRouter{}
+	locationRouter := &LocationRouter{repos: router.repos}
 	for _, e := range list {
 		m := locationRouter.copyToRestModel(e)
 		res.Locations = append(res.Locations, m)
@@ -78,13 +79,18 @@ func (router *SearchRouter) addLocationResults(user *User, keyword string, res *
 }
 
 func (router *SearchRouter) addSpaceResults(user *User, keyword string, res *GetSearchResultsResponse) error {
-	list, err := GetSpaceRepository().GetByKeyword(user.OrganizationID, keyword)
+	list, err := router.repos.Space.GetByKeyword(user.OrganizationID, keyword)
 	if err != nil {
 		return err
 	}
-	spaceRouter := &SpaceRouter{}
+	spaceRouter := &SpaceRouter{repos: router.repos}
 	for _, e := range list {
+		values, err := router.repos.SpaceAttribute.GetValues(e.ID)
+		if err != nil {
+			return err
+		}
 		m := spaceRouter.copyToRestModel(e)
+		m.Attributes = spaceRouter.copyAttributeValuesToRestModel(values)
 		res.Spaces = append(res.Spaces, m)
 	}
 	return nil
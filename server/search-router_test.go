@@ -27,17 +27,17 @@ func TestSearchUsers(t *testing.T) {
 		Email:          "this.is.max@test.com",
 		OrganizationID: org.ID,
 	}
-	GetUserRepository().Create(u1)
+	testRepositories.User.Create(u1)
 	u2 := &User{
 		Email:          "max.it.is@test.com",
 		OrganizationID: org.ID,
 	}
-	GetUserRepository().Create(u2)
+	testRepositories.User.Create(u2)
 	u3 := &User{
 		Email:          "other.name@test.com",
 		OrganizationID: org.ID,
 	}
-	GetUserRepository().Create(u3)
+	testRepositories.User.Create(u3)
 
 	req := newHTTPRequest("GET", "/search/max", loginResponse.UserID, nil)
 	res := executeTestRequest(req)
@@ -60,17 +60,17 @@ func TestSearchLocations(t *testing.T) {
 		Name:           "Frankfurt 1",
 		OrganizationID: org.ID,
 	}
-	GetLocationRepository().Create(l1)
+	testRepositories.Location.Create(l1)
 	l2 := &Location{
 		Name:           "Frankfurt 2",
 		OrganizationID: org.ID,
 	}
-	GetLocationRepository().Create(l2)
+	testRepositories.Location.Create(l2)
 	l3 := &Location{
 		Name:           "Berlin 1",
 		OrganizationID: org.ID,
 	}
-	GetLocationRepository().Create(l3)
+	testRepositories.Location.Create(l3)
 
 	req := newHTTPRequest("GET", "/search/frank", loginResponse.UserID, nil)
 	res := executeTestRequest(req)
@@ -93,23 +93,23 @@ func TestSearchSpaces(t *testing.T) {
 		Name:           "Frankfurt 1",
 		OrganizationID: org.ID,
 	}
-	GetLocationRepository().Create(l1)
+	testRepositories.Location.Create(l1)
 
 	s1 := &Space{
 		Name:       "H123",
 		LocationID: l1.ID,
 	}
-	GetSpaceRepository().Create(s1)
+	testRepositories.Space.Create(s1)
 	s2 := &Space{
 		Name:       "H234",
 		LocationID: l1.ID,
 	}
-	GetSpaceRepository().Create(s2)
+	testRepositories.Space.Create(s2)
 	s3 := &Space{
 		Name:       "G123",
 		LocationID: l1.ID,
 	}
-	GetSpaceRepository().Create(s3)
+	testRepositories.Space.Create(s3)
 
 	req := newHTTPRequest("GET", "/search/123", loginResponse.UserID, nil)
 	res := executeTestRequest(req)
@@ -121,3 +121,64 @@ func TestSearchSpaces(t *testing.T) {
 	checkTestString(t, s3.Name, resBody.Spaces[0].Name)
 	checkTestString(t, s1.Name, resBody.Spaces[1].Name)
 }
+
+func TestSearchSpacesByAttribute(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	user := createTestUserOrgAdmin(org)
+	loginResponse := loginTestUser(user.ID)
+
+	l1 := &Location{
+		Name:           "Frankfurt 1",
+		OrganizationID: org.ID,
+	}
+	testRepositories.Location.Create(l1)
+
+	s1 := &Space{
+		Name:       "H123",
+		LocationID: l1.ID,
+	}
+	testRepositories.Space.Create(s1)
+	s2 := &Space{
+		Name:       "H234",
+		LocationID: l1.ID,
+	}
+	testRepositories.Space.Create(s2)
+	s3 := &Space{
+		Name:       "G123",
+		LocationID: l1.ID,
+	}
+	testRepositories.Space.Create(s3)
+
+	a1 := &SpaceAttribute{
+		OrganizationID: org.ID,
+		Label:          "Standing desk",
+		Type:           SettingTypeBool,
+	}
+	testRepositories.SpaceAttribute.Create(a1)
+	a2 := &SpaceAttribute{
+		OrganizationID: org.ID,
+		Label:          "Docking station",
+		Type:           SettingTypeString,
+	}
+	testRepositories.SpaceAttribute.Create(a2)
+	testRepositories.SpaceAttribute.SetValues(s1.ID, []*SpaceAttributeValue{{AttributeID: a1.ID, Value: "0"}})
+	testRepositories.SpaceAttribute.SetValues(s2.ID, []*SpaceAttributeValue{{AttributeID: a1.ID, Value: "1"}})
+	testRepositories.SpaceAttribute.SetValues(s3.ID, []*SpaceAttributeValue{{AttributeID: a2.ID, Value: "USB-C Dock"}})
+
+	req := newHTTPRequest("GET", "/search/standing", loginResponse.UserID, nil)
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody *GetSearchResultsResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestInt(t, 1, len(resBody.Spaces))
+	checkTestString(t, s2.Name, resBody.Spaces[0].Name)
+	checkTestInt(t, 1, len(resBody.Spaces[0].Attributes))
+
+	req = newHTTPRequest("GET", "/search/usb-c", loginResponse.UserID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestInt(t, 1, len(resBody.Spaces))
+	checkTestString(t, s3.Name, resBody.Spaces[0].Name)
+}
@@ -2,11 +2,14 @@ package main
 
 import (
 	"crypto/tls"
+	"encoding/base64"
 	"net/smtp"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+
+	"github.com/google/uuid"
 )
 
 const EmailTemplateDefaultLanguage = "en"
@@ -14,9 +17,29 @@ const EmailTemplateDefaultLanguage = "en"
 var EmailTemplateSignup, _ = filepath.Abs("./res/email-signup.txt")
 var EmailTemplateConfirm, _ = filepath.Abs("./res/email-confirm.txt")
 var EmailTemplateResetpassword, _ = filepath.Abs("./res/email-resetpw.txt")
+var EmailTemplateBookingCreated, _ = filepath.Abs("./res/email-booking-created.txt")
+var EmailTemplateBookingUpdated, _ = filepath.Abs("./res/email-booking-updated.txt")
+var EmailTemplateBookingDeleted, _ = filepath.Abs("./res/email-booking-deleted.txt")
+var EmailTemplateBookingReminder, _ = filepath.Abs("./res/email-booking-reminder.txt")
+var EmailTemplateWaitlistOffer, _ = filepath.Abs("./res/email-waitlist-offer.txt")
+var EmailTemplateWaitlistOfferExpired, _ = filepath.Abs("./res/email-waitlist-offer-expired.txt")
+var EmailTemplateBookingPending, _ = filepath.Abs("./res/email-booking-pending.txt")
+var EmailTemplateBookingRejected, _ = filepath.Abs("./res/email-booking-rejected.txt")
+var EmailTemplateBookingApprovalExpired, _ = filepath.Abs("./res/email-booking-approval-expired.txt")
+var EmailTemplateInvite, _ = filepath.Abs("./res/email-invite.txt")
 var SendMailMockContent = ""
 
+type EmailAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
 func sendEmail(recipient, sender, templateFile, language string, vars map[string]string) error {
+	return sendEmailWithAttachment(recipient, sender, templateFile, language, vars, nil)
+}
+
+func sendEmailWithAttachment(recipient, sender, templateFile, language string, vars map[string]string, attachment *EmailAttachment) error {
 	actualTemplateFile, err := getEmailTemplatePath(templateFile, language)
 	if err != nil {
 		return err
@@ -25,6 +48,9 @@ func sendEmail(recipient, sender, templateFile, language string, vars map[string
 	if err != nil {
 		return err
 	}
+	if attachment != nil {
+		body = addEmailAttachment(body, attachment)
+	}
 	if GetConfig().MockSendmail {
 		SendMailMockContent = body
 		return nil
@@ -70,6 +96,40 @@ func compileEmailTemplate(templateFile string, vars map[string]string) (string,
 	return s, nil
 }
 
+// addEmailAttachment converts a compiled plain text email into a multipart message
+// containing the original body and the attachment.
+func addEmailAttachment(mail string, attachment *EmailAttachment) string {
+	mail = strings.ReplaceAll(mail, "\r\n", "\n")
+	headers, body, _ := strings.Cut(mail, "\n\n")
+	contentType := "text/plain; charset=UTF-8"
+	var sb strings.Builder
+	for _, header := range strings.Split(headers, "\n") {
+		if strings.HasPrefix(strings.ToLower(header), "content-type:") {
+			contentType = strings.TrimSpace(header[len("content-type:"):])
+			continue
+		}
+		sb.WriteString(header + "\r\n")
+	}
+	boundary := uuid.New().String()
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: multipart/mixed; boundary=\"" + boundary + "\"\r\n\r\n")
+	sb.WriteString("--" + boundary + "\r\n")
+	sb.WriteString("Content-Type: " + contentType + "\r\n\r\n")
+	sb.WriteString(strings.ReplaceAll(body, "\n", "\r\n") + "\r\n")
+	sb.WriteString("--" + boundary + "\r\n")
+	sb.WriteString("Content-Type: " + attachment.ContentType + "; name=\"" + attachment.Filename + "\"\r\n")
+	sb.WriteString("Content-Disposition: attachment; filename=\"" + attachment.Filename + "\"\r\n")
+	sb.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	data := base64.StdEncoding.EncodeToString(attachment.Data)
+	for len(data) > 76 {
+		sb.WriteString(data[:76] + "\r\n")
+		data = data[76:]
+	}
+	sb.WriteString(data + "\r\n")
+	sb.WriteString("--" + boundary + "--\r\n")
+	return sb.String()
+}
+
 func smtpDialAndSend(from string, to []string, msg []byte) error {
 	config := GetConfig()
 	addr := config.SMTPHost + ":" + strconv.Itoa(config.SMTPPort)
@@ -2,6 +2,7 @@ package main
 
 import (
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -23,3 +24,17 @@ func TestGetEmailTemplatePathNotExists(t *testing.T) {
 	checkTestString(t, "", res)
 	checkTestBool(t, true, err != nil)
 }
+
+func TestAddEmailAttachment(t *testing.T) {
+	mail := "From: Seatsurfing <test@seatsurfing.local>\nTo: user@test.com\nContent-Type: text/plain; charset=UTF-8\nSubject: Test\n\nHello,\nbody"
+	attachment := &EmailAttachment{
+		Filename:    "request.ics",
+		ContentType: "text/calendar; charset=UTF-8; method=REQUEST",
+		Data:        []byte("BEGIN:VCALENDAR"),
+	}
+	res := addEmailAttachment(mail, attachment)
+	checkTestBool(t, true, strings.HasPrefix(res, "From: Seatsurfing <test@seatsurfing.local>\r\nTo: user@test.com\r\nSubject: Test\r\nMIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary="))
+	checkTestBool(t, true, strings.Contains(res, "Content-Type: text/plain; charset=UTF-8\r\n\r\nHello,\r\nbody\r\n"))
+	checkTestBool(t, true, strings.Contains(res, "Content-Type: text/calendar; charset=UTF-8; method=REQUEST; name=\"request.ics\"\r\n"))
+	checkTestBool(t, true, strings.Contains(res, "\r\n\r\nQkVHSU46VkNBTEVOREFS\r\n"))
+}
@@ -0,0 +1,97 @@
+package main
+
+type MemorySettingsRepository struct {
+	settingsRepositoryBase
+}
+
+func (r *MemorySettingsRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemorySettingsRepository) Set(organizationID string, name string, value string) error {
+	if err := memoryCheckUUID(organizationID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	r.set(organizationID, name, value, true)
+	return nil
+}
+
+// set inserts the setting or, if overwrite is true, updates its value. The caller must
+// hold the lock.
+func (r *MemorySettingsRepository) set(organizationID string, name string, value string, overwrite bool) {
+	row, err := memoryFind(memoryData.settings, func(row *OrgSetting) bool {
+		return row.OrganizationID == organizationID && row.Name == name
+	})
+	if err != nil {
+		memoryData.settings = append(memoryData.settings, &OrgSetting{
+			OrganizationID: organizationID,
+			Name:           name,
+			Value:          value,
+		})
+		return
+	}
+	if overwrite {
+		row.Value = value
+	}
+}
+
+func (r *MemorySettingsRepository) Get(organizationID string, name string) (string, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e, err := memoryFind(memoryData.settings, func(e *OrgSetting) bool {
+		return e.OrganizationID == organizationID && e.Name == name
+	})
+	if err != nil {
+		return "", err
+	}
+	return e.Value, nil
+}
+
+func (r *MemorySettingsRepository) GetOrganizationIDsByValue(name, value string) ([]string, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	var res []string
+	for _, e := range memoryData.settings {
+		if e.Name == name && e.Value == value {
+			res = append(res, e.OrganizationID)
+		}
+	}
+	return res, nil
+}
+
+func (r *MemorySettingsRepository) GetAll(organizationID string) ([]*OrgSetting, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	result := memoryCopy(memoryFilter(memoryData.settings, func(e *OrgSetting) bool {
+		return e.OrganizationID == organizationID
+	}))
+	memorySort(result, func(a, b *OrgSetting) bool {
+		return memoryCompare(a.Name, b.Name) < 0
+	})
+	return result, nil
+}
+
+func (r *MemorySettingsRepository) InitDefaultSettingsForOrg(organizationID string) error {
+	if err := memoryCheckUUID(organizationID); err != nil {
+		return err
+	}
+	defaults := r.getDefaultSettingsForOrg()
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	for _, e := range defaults {
+		r.set(organizationID, e.Name, e.Value, false)
+	}
+	return nil
+}
+
+func (r *MemorySettingsRepository) DeleteAll(organizationID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.settings = memoryDelete(memoryData.settings, func(row *OrgSetting) bool {
+		return row.OrganizationID == organizationID
+	})
+	return nil
+}
@@ -2,10 +2,34 @@ package main
 
 import (
 	"strconv"
-	"sync"
+	"strings"
 )
 
-type SettingsRepository struct {
+type SettingsRepository interface {
+	Repository
+	Set(organizationID string, name string, value string) error
+	Get(organizationID string, name string) (string, error)
+	GetOrganizationIDsByValue(name, value string) ([]string, error)
+	SetGlobal(name string, value string) error
+	GetInt(organizationID string, name string) (int, error)
+	GetBool(organizationID string, name string) (bool, error)
+	GetGlobalString(name string) (string, error)
+	GetGlobalInt(name string) (int, error)
+	GetGlobalBool(name string) (bool, error)
+	GetAll(organizationID string) ([]*OrgSetting, error)
+	InitDefaultSettingsForOrg(organizationID string) error
+	InitDefaultSettings(orgIDs []string) error
+	DeleteAll(organizationID string) error
+	getNullUUID() string
+}
+
+type PostgresSettingsRepository struct {
+	settingsRepositoryBase
+}
+
+// settingsRepositoryBase converts and defaults setting values on top of Get and Set.
+type settingsRepositoryBase struct {
+	repos *Repositories
 }
 
 type OrgSetting struct {
@@ -52,31 +76,31 @@ var (
   SettingDisableBuddies                 SettingName = SettingName{Name: "disable_buddies", Type: SettingTypeBool}
 	SettingSubscriptionMaxUsers           SettingName = SettingName{Name: "subscription_max_users", Type: SettingTypeInt}
 	SettingDefaultTimezone                SettingName = SettingName{Name: "default_timezone", Type: SettingTypeString}
+	SettingEnableCheckIn                  SettingName = SettingName{Name: "enable_checkin", Type: SettingTypeBool}
+	SettingCheckInWindowMinutes           SettingName = SettingName{Name: "checkin_window_minutes", Type: SettingTypeInt}
+	SettingEnableAutoRelease              SettingName = SettingName{Name: "enable_auto_release", Type: SettingTypeBool}
+	SettingAutoReleaseGraceMinutes        SettingName = SettingName{Name: "auto_release_grace_minutes", Type: SettingTypeInt}
+	SettingBookingReminderHours           SettingName = SettingName{Name: "booking_reminder_hours", Type: SettingTypeInt}
+	SettingEnforceTOTPAdmins              SettingName = SettingName{Name: "enforce_totp_admins", Type: SettingTypeBool}
+	SettingEnableWaitlist                 SettingName = SettingName{Name: "enable_waitlist", Type: SettingTypeBool}
+	SettingWaitlistOfferMinutes           SettingName = SettingName{Name: "waitlist_offer_minutes", Type: SettingTypeInt}
+	SettingApprovalExpiryHours            SettingName = SettingName{Name: "approval_expiry_hours", Type: SettingTypeInt}
+	SettingAuditLogRetentionDays          SettingName = SettingName{Name: "audit_log_retention_days", Type: SettingTypeInt}
 )
 
-var settingsRepository *SettingsRepository
-var settingsRepositoryOnce sync.Once
-
-func GetSettingsRepository() *SettingsRepository {
-	settingsRepositoryOnce.Do(func() {
-		settingsRepository = &SettingsRepository{}
-		_, err := GetDatabase().DB().Exec("CREATE TABLE IF NOT EXISTS settings (" +
-			"organization_id uuid NOT NULL, " +
-			"name VARCHAR NOT NULL, " +
-			"value VARCHAR NOT NULL DEFAULT '', " +
-			"PRIMARY KEY (organization_id, name))")
-		if err != nil {
-			panic(err)
-		}
-	})
-	return settingsRepository
-}
-
-func (r *SettingsRepository) RunSchemaUpgrade(curVersion, targetVersion int) {
-	// nothing yet
+func (r *PostgresSettingsRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		newInitialSchemaMigration(
+			"CREATE TABLE IF NOT EXISTS settings ("+
+				"organization_id uuid NOT NULL, "+
+				"name VARCHAR NOT NULL, "+
+				"value VARCHAR NOT NULL DEFAULT '', "+
+				"PRIMARY KEY (organization_id, name))",
+		),
+	}
 }
 
-func (r *SettingsRepository) Set(organizationID string, name string, value string) error {
+func (r *PostgresSettingsRepository) Set(organizationID string, name string, value string) error {
 	_, err := GetDatabase().DB().Exec("INSERT INTO settings (organization_id, name, value) "+
 		"VALUES ($1, $2, $3) "+
 		"ON CONFLICT (organization_id, name) DO UPDATE SET value = $3",
@@ -84,7 +108,7 @@ func (r *SettingsRepository) Set(organizationID string, name string, value strin
 	return err
 }
 
-func (r *SettingsRepository) Get(organizationID string, name string) (string, error) {
+func (r *PostgresSettingsRepository) Get(organizationID string, name string) (string, error) {
 	var res string
 	err := GetDatabase().DB().QueryRow("SELECT value FROM settings "+
 		"WHERE organization_id = $1 AND name = $2",
@@ -95,7 +119,7 @@ func (r *SettingsRepository) Get(organizationID string, name string) (string, er
 	return res, nil
 }
 
-func (r *SettingsRepository) GetOrganizationIDsByValue(name, value string) ([]string, error) {
+func (r *PostgresSettingsRepository) GetOrganizationIDsByValue(name, value string) ([]string, error) {
 	var res []string
 	rows, err := GetDatabase().DB().Query("SELECT organization_id FROM settings "+
 		"WHERE name = $1 AND value = $2",
@@ -115,12 +139,12 @@ func (r *SettingsRepository) GetOrganizationIDsByValue(name, value string) ([]st
 	return res, nil
 }
 
-func (r *SettingsRepository) SetGlobal(name string, value string) error {
-	return r.Set(r.getNullUUID(), name, value)
+func (r *settingsRepositoryBase) SetGlobal(name string, value string) error {
+	return r.repos.Settings.Set(r.getNullUUID(), name, value)
 }
 
-func (r *SettingsRepository) GetInt(organizationID string, name string) (int, error) {
-	res, err := r.Get(organizationID, name)
+func (r *settingsRepositoryBase) GetInt(organizationID string, name string) (int, error) {
+	res, err := r.repos.Settings.Get(organizationID, name)
 	if err != nil {
 		return 0, err
 	}
@@ -128,8 +152,8 @@ func (r *SettingsRepository) GetInt(organizationID string, name string) (int, er
 	return i, err
 }
 
-func (r *SettingsRepository) GetBool(organizationID string, name string) (bool, error) {
-	res, err := r.Get(organizationID, name)
+func (r *settingsRepositoryBase) GetBool(organizationID string, name string) (bool, error) {
+	res, err := r.repos.Settings.Get(organizationID, name)
 	if err != nil {
 		return false, err
 	}
@@ -137,16 +161,16 @@ func (r *SettingsRepository) GetBool(organizationID string, name string) (bool,
 	return b, err
 }
 
-func (r *SettingsRepository) GetGlobalString(name string) (string, error) {
-	res, err := r.Get(r.getNullUUID(), name)
+func (r *settingsRepositoryBase) GetGlobalString(name string) (string, error) {
+	res, err := r.repos.Settings.Get(r.getNullUUID(), name)
 	if err != nil {
 		return "", err
 	}
 	return res, nil
 }
 
-func (r *SettingsRepository) GetGlobalInt(name string) (int, error) {
-	res, err := r.Get(r.getNullUUID(), name)
+func (r *settingsRepositoryBase) GetGlobalInt(name string) (int, error) {
+	res, err := r.repos.Settings.Get(r.getNullUUID(), name)
 	if err != nil {
 		return 0, err
 	}
@@ -154,8 +178,8 @@ func (r *SettingsRepository) GetGlobalInt(name string) (int, error) {
 	return i, err
 }
 
-func (r *SettingsRepository) GetGlobalBool(name string) (bool, error) {
-	res, err := r.Get(r.getNullUUID(), name)
+func (r *settingsRepositoryBase) GetGlobalBool(name string) (bool, error) {
+	res, err := r.repos.Settings.Get(r.getNullUUID(), name)
 	if err != nil {
 		return false, err
 	}
@@ -163,7 +187,7 @@ func (r *SettingsRepository) GetGlobalBool(name string) (bool, error) {
 	return b, err
 }
 
-func (r *SettingsRepository) GetAll(organizationID string) ([]*OrgSetting, error) {
+func (r *PostgresSettingsRepository) GetAll(organizationID string) ([]*OrgSetting, error) {
 	var result []*OrgSetting
 	rows, err := GetDatabase().DB().Query("SELECT organization_id, name, value FROM settings "+
 		"WHERE organization_id = $1 "+
@@ -183,49 +207,71 @@ func (r *SettingsRepository) GetAll(organizationID string) ([]*OrgSetting, error
 	return result, nil
 }
 
-func (r *SettingsRepository) InitDefaultSettingsForOrg(organizationID string) error {
+func (r *PostgresSettingsRepository) InitDefaultSettingsForOrg(organizationID string) error {
+	var values []string
+	args := []interface{}{organizationID}
+	for _, e := range r.getDefaultSettingsForOrg() {
+		values = append(values, "($1, $"+strconv.Itoa(len(args)+1)+", $"+strconv.Itoa(len(args)+2)+")")
+		args = append(args, e.Name, e.Value)
+	}
 	_, err := GetDatabase().DB().Exec("INSERT INTO settings (organization_id, name, value) "+
-		"VALUES "+
-		"($1, '"+SettingActiveSubscription.Name+"', '0'), "+
-		"($1, '"+SettingSubscriptionMaxUsers.Name+"', '"+strconv.Itoa(GetConfig().OrgSignupMaxUsers)+"'), "+
-		"($1, '"+SettingAllowAnyUser.Name+"', '1'), "+
-		"($1, '"+SettingDailyBasisBooking.Name+"', '0'), "+
-		"($1, '"+SettingNoAdminRestrictions.Name+"', '0'), "+
-		"($1, '"+SettingCustomLogoUrl.Name+"', ''), "+
-		"($1, '"+SettingShowNames.Name+"', '0'), "+
-		"($1, '"+SettingAllowBookingsNonExistingUsers.Name+"', '0'), "+
-		"($1, '"+SettingDisableBuddies.Name+"', '0'), "+
-		"($1, '"+SettingConfluenceServerSharedSecret.Name+"', ''), "+
-		"($1, '"+SettingConfluenceAnonymous.Name+"', '0'), "+
-		"($1, '"+SettingMaxBookingsPerUser.Name+"', '10'), "+
-		"($1, '"+SettingMaxConcurrentBookingsPerUser.Name+"', '0'), "+
-		"($1, '"+SettingEnableMaxHourBeforeDelete.Name+"', '0'), "+
-		"($1, '"+SettingMaxHoursBeforeDelete.Name+"', '0'), "+
-		"($1, '"+SettingMaxHoursPartiallyBookedEnabled.Name+"', '0'), "+
-		"($1, '"+SettingMaxHoursPartiallyBooked.Name+"', '8'), "+
-		"($1, '"+SettingMinBookingDurationHours.Name+"', '0'), "+
-		"($1, '"+SettingMaxDaysInAdvance.Name+"', '14'), "+
-		"($1, '"+SettingMaxBookingDurationHours.Name+"', '12'), "+
-		"($1, '"+SettingDefaultTimezone.Name+"', 'Europe/Berlin') "+
+		"VALUES "+strings.Join(values, ", ")+" "+
 		"ON CONFLICT (organization_id, name) DO NOTHING",
-		organizationID)
+		args...)
 	return err
 }
 
-func (r *SettingsRepository) InitDefaultSettings(orgIDs []string) error {
+// getDefaultSettingsForOrg returns the settings every organization starts with.
+func (r *settingsRepositoryBase) getDefaultSettingsForOrg() []*OrgSetting {
+	return []*OrgSetting{
+		{Name: SettingActiveSubscription.Name, Value: "0"},
+		{Name: SettingSubscriptionMaxUsers.Name, Value: strconv.Itoa(GetConfig().OrgSignupMaxUsers)},
+		{Name: SettingAllowAnyUser.Name, Value: "1"},
+		{Name: SettingDailyBasisBooking.Name, Value: "0"},
+		{Name: SettingNoAdminRestrictions.Name, Value: "0"},
+		{Name: SettingCustomLogoUrl.Name, Value: ""},
+		{Name: SettingShowNames.Name, Value: "0"},
+		{Name: SettingAllowBookingsNonExistingUsers.Name, Value: "0"},
+		{Name: SettingDisableBuddies.Name, Value: "0"},
+		{Name: SettingConfluenceServerSharedSecret.Name, Value: ""},
+		{Name: SettingConfluenceAnonymous.Name, Value: "0"},
+		{Name: SettingMaxBookingsPerUser.Name, Value: "10"},
+		{Name: SettingMaxConcurrentBookingsPerUser.Name, Value: "0"},
+		{Name: SettingEnableMaxHourBeforeDelete.Name, Value: "0"},
+		{Name: SettingMaxHoursBeforeDelete.Name, Value: "0"},
+		{Name: SettingMaxHoursPartiallyBookedEnabled.Name, Value: "0"},
+		{Name: SettingMaxHoursPartiallyBooked.Name, Value: "8"},
+		{Name: SettingMinBookingDurationHours.Name, Value: "0"},
+		{Name: SettingMaxDaysInAdvance.Name, Value: "14"},
+		{Name: SettingMaxBookingDurationHours.Name, Value: "12"},
+		{Name: SettingEnableCheckIn.Name, Value: "0"},
+		{Name: SettingCheckInWindowMinutes.Name, Value: "15"},
+		{Name: SettingEnableAutoRelease.Name, Value: "0"},
+		{Name: SettingAutoReleaseGraceMinutes.Name, Value: "15"},
+		{Name: SettingBookingReminderHours.Name, Value: "0"},
+		{Name: SettingEnforceTOTPAdmins.Name, Value: "0"},
+		{Name: SettingEnableWaitlist.Name, Value: "0"},
+		{Name: SettingWaitlistOfferMinutes.Name, Value: "0"},
+		{Name: SettingApprovalExpiryHours.Name, Value: "24"},
+		{Name: SettingAuditLogRetentionDays.Name, Value: "365"},
+		{Name: SettingDefaultTimezone.Name, Value: "Europe/Berlin"},
+	}
+}
+
+func (r *settingsRepositoryBase) InitDefaultSettings(orgIDs []string) error {
 	for _, orgID := range orgIDs {
-		if err := r.InitDefaultSettingsForOrg(orgID); err != nil {
+		if err := r.repos.Settings.InitDefaultSettingsForOrg(orgID); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (r *SettingsRepository) DeleteAll(organizationID string) error {
+func (r *PostgresSettingsRepository) DeleteAll(organizationID string) error {
 	_, err := GetDatabase().DB().Exec("DELETE FROM settings WHERE organization_id = $1", organizationID)
 	return err
 }
 
-func (r *SettingsRepository) getNullUUID() string {
+func (r *settingsRepositoryBase) getNullUUID() string {
 	return "00000000-0000-0000-0000-000000000000"
 }
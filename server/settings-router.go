@@ -10,6 +10,7 @@ import (
 )
 
 type SettingsRouter struct {
+	repos *Repositories
 }
 
 type SetSettingsRequest struct {
@@ -40,7 +41,7 @@ func (router *SettingsRouter) getTimezones(w http.ResponseWriter, r *http.Reques
 }
 
 func (router *SettingsRouter) getSetting(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	vars := mux.Vars(r)
 	orgAdmin := CanAdminOrg(user, user.OrganizationID)
 	if !((orgAdmin && router.isValidSettingNameReadAdmin(vars["name"])) || (router.isValidSettingNameReadPublic(vars["name"]))) {
@@ -55,7 +56,7 @@ func (router *SettingsRouter) getSetting(w http.ResponseWriter, r *http.Request)
 		SendJSON(w, router.getSysSettingVersion())
 		return
 	}
-	value, err := GetSettingsRepository().Get(user.OrganizationID, vars["name"])
+	value, err := router.repos.Settings.Get(user.OrganizationID, vars["name"])
 	if err != nil {
 		log.Println(err)
 		SendNotFound(w)
@@ -65,7 +66,7 @@ func (router *SettingsRouter) getSetting(w http.ResponseWriter, r *http.Request)
 }
 
 func (router *SettingsRouter) setSetting(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanAdminOrg(user, user.OrganizationID) {
 		SendForbidden(w)
 		return
@@ -88,7 +89,7 @@ func (router *SettingsRouter) setSetting(w http.ResponseWriter, r *http.Request)
 		SendBadRequest(w)
 		return
 	}
-	err := router.doSetOne(user.OrganizationID, vars["name"], value.Value)
+	err := router.doSetOne(r, user.OrganizationID, vars["name"], value.Value)
 	if err != nil {
 		log.Println(err)
 		if errors.Is(err, ErrAlreadyExists) {
@@ -102,13 +103,13 @@ func (router *SettingsRouter) setSetting(w http.ResponseWriter, r *http.Request)
 }
 
 func (router *SettingsRouter) getAll(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanAccessOrg(user, user.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
 	orgAdmin := CanAdminOrg(user, user.OrganizationID)
-	list, err := GetSettingsRepository().GetAll(user.OrganizationID)
+	list, err := router.repos.Settings.GetAll(user.OrganizationID)
 	if err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
@@ -129,7 +130,7 @@ func (router *SettingsRouter) getAll(w http.ResponseWriter, r *http.Request) {
 }
 
 func (router *SettingsRouter) setAll(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanAdminOrg(user, user.OrganizationID) {
 		SendForbidden(w)
 		return
@@ -153,7 +154,7 @@ func (router *SettingsRouter) setAll(w http.ResponseWriter, r *http.Request) {
 			SendBadRequest(w)
 			return
 		}
-		err := router.doSetOne(user.OrganizationID, e.Name, e.Value)
+		err := router.doSetOne(r, user.OrganizationID, e.Name, e.Value)
 		if err != nil {
 			log.Println(err)
 			if errors.Is(err, ErrAlreadyExists) {
@@ -167,8 +168,12 @@ func (router *SettingsRouter) setAll(w http.ResponseWriter, r *http.Request) {
 	SendUpdated(w)
 }
 
-func (router *SettingsRouter) doSetOne(organizationID, name, value string) error {
-	err := GetSettingsRepository().Set(organizationID, name, value)
+func (router *SettingsRouter) doSetOne(r *http.Request, organizationID, name, value string) error {
+	valueOld, _ := router.repos.Settings.Get(organizationID, name)
+	err := router.repos.Settings.Set(organizationID, name, value)
+	if err == nil {
+		recordAuditEvent(router.repos, r, organizationID, AuditActionSettingUpdated, AuditTargetSetting, name, map[string]string{name: valueOld}, map[string]string{name: value})
+	}
 	return err
 }
 
@@ -196,6 +201,14 @@ func (router *SettingsRouter) isValidSettingNameReadPublic(name string) bool {
 		name == SettingMaxHoursPartiallyBookedEnabled.Name ||
 		name == SettingDefaultTimezone.Name ||
 		name == SettingDisableBuddies.Name ||
+		name == SettingEnableCheckIn.Name ||
+		name == SettingCheckInWindowMinutes.Name ||
+		name == SettingEnableAutoRelease.Name ||
+		name == SettingAutoReleaseGraceMinutes.Name ||
+		name == SettingBookingReminderHours.Name ||
+		name == SettingEnableWaitlist.Name ||
+		name == SettingWaitlistOfferMinutes.Name ||
+		name == SettingApprovalExpiryHours.Name ||
 		name == SysSettingVersion {
 		return true
 	}
@@ -214,6 +227,8 @@ func (router *SettingsRouter) isValidSettingNameReadAdmin(name string) bool {
 		name == SettingSubscriptionMaxUsers.Name ||
 		name == SettingConfluenceServerSharedSecret.Name ||
 		name == SettingConfluenceAnonymous.Name ||
+		name == SettingEnforceTOTPAdmins.Name ||
+		name == SettingAuditLogRetentionDays.Name ||
 		name == SysSettingOrgSignupDelete {
 		return true
 	}
@@ -239,6 +254,16 @@ func (router *SettingsRouter) isValidSettingNameWrite(name string) bool {
 		name == SettingAllowBookingsNonExistingUsers.Name ||
 		name == SettingMaxBookingDurationHours.Name ||
 		name == SettingDisableBuddies.Name ||
+		name == SettingEnableCheckIn.Name ||
+		name == SettingCheckInWindowMinutes.Name ||
+		name == SettingEnableAutoRelease.Name ||
+		name == SettingAutoReleaseGraceMinutes.Name ||
+		name == SettingBookingReminderHours.Name ||
+		name == SettingEnforceTOTPAdmins.Name ||
+		name == SettingEnableWaitlist.Name ||
+		name == SettingWaitlistOfferMinutes.Name ||
+		name == SettingApprovalExpiryHours.Name ||
+		name == SettingAuditLogRetentionDays.Name ||
 		name == SettingDefaultTimezone.Name {
 		return true
 	}
@@ -303,6 +328,36 @@ func (router *SettingsRouter) getSettingType(name string) SettingType {
 	if name == SettingMinBookingDurationHours.Name {
 		return SettingMinBookingDurationHours.Type
 	}
+	if name == SettingEnableCheckIn.Name {
+		return SettingEnableCheckIn.Type
+	}
+	if name == SettingCheckInWindowMinutes.Name {
+		return SettingCheckInWindowMinutes.Type
+	}
+	if name == SettingEnableAutoRelease.Name {
+		return SettingEnableAutoRelease.Type
+	}
+	if name == SettingAutoReleaseGraceMinutes.Name {
+		return SettingAutoReleaseGraceMinutes.Type
+	}
+	if name == SettingBookingReminderHours.Name {
+		return SettingBookingReminderHours.Type
+	}
+	if name == SettingEnforceTOTPAdmins.Name {
+		return SettingEnforceTOTPAdmins.Type
+	}
+	if name == SettingEnableWaitlist.Name {
+		return SettingEnableWaitlist.Type
+	}
+	if name == SettingWaitlistOfferMinutes.Name {
+		return SettingWaitlistOfferMinutes.Type
+	}
+	if name == SettingApprovalExpiryHours.Name {
+		return SettingApprovalExpiryHours.Type
+	}
+	if name == SettingAuditLogRetentionDays.Name {
+		return SettingAuditLogRetentionDays.Type
+	}
 	return 0
 }
 
@@ -329,6 +384,12 @@ func (router *SettingsRouter) isValidSettingValue(name string, value string) boo
 	if name == SettingDefaultTimezone.Name && !isValidTimeZone(value) {
 		return false
 	}
+	if name == SettingCheckInWindowMinutes.Name || name == SettingAutoReleaseGraceMinutes.Name || name == SettingBookingReminderHours.Name ||
+		name == SettingWaitlistOfferMinutes.Name || name == SettingApprovalExpiryHours.Name || name == SettingAuditLogRetentionDays.Name {
+		if i, _ := strconv.Atoi(value); i < 0 {
+			return false
+		}
+	}
 	return true
 }
 
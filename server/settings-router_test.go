@@ -60,6 +60,14 @@ func TestSettingsReadPublic(t *testing.T) {
 		SettingAllowBookingsNonExistingUsers.Name,
 		SettingDefaultTimezone.Name,
 		SettingCustomLogoUrl.Name,
+		SettingEnableCheckIn.Name,
+		SettingCheckInWindowMinutes.Name,
+		SettingEnableAutoRelease.Name,
+		SettingAutoReleaseGraceMinutes.Name,
+		SettingBookingReminderHours.Name,
+		SettingEnableWaitlist.Name,
+		SettingWaitlistOfferMinutes.Name,
+		SettingApprovalExpiryHours.Name,
 		SysSettingVersion,
 	}
 	forbiddenSettings := []string{
@@ -69,6 +77,8 @@ func TestSettingsReadPublic(t *testing.T) {
 		SettingConfluenceAnonymous.Name,
 		SettingActiveSubscription.Name,
 		SettingSubscriptionMaxUsers.Name,
+		SettingEnforceTOTPAdmins.Name,
+		SettingAuditLogRetentionDays.Name,
 	}
 
 	for _, name := range allowedSettings {
@@ -128,6 +138,16 @@ func TestSettingsReadAdmin(t *testing.T) {
 		SettingSubscriptionMaxUsers.Name,
 		SettingDefaultTimezone.Name,
 		SettingCustomLogoUrl.Name,
+		SettingEnableCheckIn.Name,
+		SettingCheckInWindowMinutes.Name,
+		SettingEnableAutoRelease.Name,
+		SettingAutoReleaseGraceMinutes.Name,
+		SettingBookingReminderHours.Name,
+		SettingEnableWaitlist.Name,
+		SettingWaitlistOfferMinutes.Name,
+		SettingApprovalExpiryHours.Name,
+		SettingEnforceTOTPAdmins.Name,
+		SettingAuditLogRetentionDays.Name,
 		SysSettingOrgSignupDelete,
 		SysSettingVersion,
 	}
@@ -200,7 +220,7 @@ func TestSettingsCRUDMany(t *testing.T) {
 	org := createTestOrg("test.com")
 	user := createTestUserOrgAdmin(org)
 	loginResponse := loginTestUser(user.ID)
-	GetDatabase().DB().Exec("TRUNCATE settings")
+	testRepositories.Settings.DeleteAll(org.ID)
 
 	payload := `[{"name": "allow_any_user", "value": "1"}, {"name": "max_bookings_per_user", "value": "5"}]`
 	req := newHTTPRequest("PUT", "/setting/", loginResponse.UserID, bytes.NewBufferString(payload))
@@ -246,7 +266,7 @@ func TestSettingsMaxHoursBeforeDelete(t *testing.T) {
 	org := createTestOrg("test.com")
 	user := createTestUserOrgAdmin(org)
 	loginResponse := loginTestUser(user.ID)
-	GetDatabase().DB().Exec("TRUNCATE settings")
+	testRepositories.Settings.DeleteAll(org.ID)
 
 	payload := `[{"name": "max_hours_before_delete", "value": "2"}]`
 	req := newHTTPRequest("PUT", "/setting/", loginResponse.UserID, bytes.NewBufferString(payload))
@@ -271,7 +291,7 @@ func TestSettingsMinHoursBookingDuration(t *testing.T) {
 	org := createTestOrg("test.com")
 	user := createTestUserOrgAdmin(org)
 	loginResponse := loginTestUser(user.ID)
-	GetDatabase().DB().Exec("TRUNCATE settings")
+	testRepositories.Settings.DeleteAll(org.ID)
 
 	payload := `[{"name": "min_booking_duration_hours", "value": "2"}]`
 	req := newHTTPRequest("PUT", "/setting/", loginResponse.UserID, bytes.NewBufferString(payload))
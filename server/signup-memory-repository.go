@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+type MemorySignupRepository struct {
+}
+
+func (r *MemorySignupRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemorySignupRepository) Create(e *Signup) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e.ID = memoryNewID()
+	row := *e
+	row.Date = memoryTimestamp(e.Date)
+	memoryData.signups = append(memoryData.signups, &row)
+	return nil
+}
+
+func (r *MemorySignupRepository) GetOne(id string) (*Signup, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryGet(memoryData.signups, func(e *Signup) bool {
+		return e.ID == id
+	})
+}
+
+func (r *MemorySignupRepository) GetByEmail(email string) (*Signup, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryGet(memoryData.signups, func(e *Signup) bool {
+		return strings.EqualFold(e.Email, email)
+	})
+}
+
+func (r *MemorySignupRepository) Delete(e *Signup) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.signups = memoryDelete(memoryData.signups, func(row *Signup) bool {
+		return row.ID == e.ID
+	})
+	return nil
+}
+
+func (r *MemorySignupRepository) DeleteExpired() error {
+	now := memoryTimestamp(time.Now().Add(time.Hour * -2))
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.signups = memoryDelete(memoryData.signups, func(row *Signup) bool {
+		return row.Date.Before(now)
+	})
+	return nil
+}
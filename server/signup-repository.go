@@ -2,11 +2,19 @@ package main
 
 import (
 	"strings"
-	"sync"
 	"time"
 )
 
-type SignupRepository struct {
+type SignupRepository interface {
+	Repository
+	Create(e *Signup) error
+	GetOne(id string) (*Signup, error)
+	GetByEmail(email string) (*Signup, error)
+	Delete(e *Signup) error
+	DeleteExpired() error
+}
+
+type PostgresSignupRepository struct {
 }
 
 type Signup struct {
@@ -21,46 +29,41 @@ type Signup struct {
 	Domain       string
 }
 
-var signupRepository *SignupRepository
-var signupRepositoryOnce sync.Once
-
-func GetSignupRepository() *SignupRepository {
-	signupRepositoryOnce.Do(func() {
-		signupRepository = &SignupRepository{}
-		_, err := GetDatabase().DB().Exec("CREATE TABLE IF NOT EXISTS signups (" +
-			"id uuid DEFAULT uuid_generate_v4(), " +
-			"date TIMESTAMP NOT NULL, " +
-			"email VARCHAR NOT NULL, " +
-			"password VARCHAR NOT NULL, " +
-			"firstname VARCHAR NOT NULL, " +
-			"lastname VARCHAR NOT NULL, " +
-			"organization VARCHAR NOT NULL, " +
-			"domain VARCHAR NOT NULL, " +
-			"PRIMARY KEY (id))")
-		if err != nil {
-			panic(err)
-		}
-	})
-	return signupRepository
-}
-
-func (r *SignupRepository) RunSchemaUpgrade(curVersion, targetVersion int) {
-	if curVersion < 6 {
-		if _, err := GetDatabase().DB().Exec("ALTER TABLE signups " +
-			"ADD COLUMN country VARCHAR, " +
-			"ADD COLUMN language VARCHAR"); err != nil {
-			panic(err)
-		}
-	}
-	if curVersion < 15 {
-		if _, err := GetDatabase().DB().Exec("ALTER TABLE signups " +
-			"DROP COLUMN country"); err != nil {
-			panic(err)
-		}
+func (r *PostgresSignupRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		newInitialSchemaMigration(
+			"CREATE TABLE IF NOT EXISTS signups (" +
+				"id uuid DEFAULT uuid_generate_v4(), " +
+				"date TIMESTAMP NOT NULL, " +
+				"email VARCHAR NOT NULL, " +
+				"password VARCHAR NOT NULL, " +
+				"firstname VARCHAR NOT NULL, " +
+				"lastname VARCHAR NOT NULL, " +
+				"organization VARCHAR NOT NULL, " +
+				"domain VARCHAR NOT NULL, " +
+				"PRIMARY KEY (id))",
+		),
+		{
+			Version:     6,
+			Description: "Add country and language to signups",
+			Up: []string{"ALTER TABLE signups " +
+				"ADD COLUMN country VARCHAR, " +
+				"ADD COLUMN language VARCHAR"},
+			Down: []string{"ALTER TABLE signups " +
+				"DROP COLUMN country, " +
+				"DROP COLUMN language"},
+		},
+		{
+			// The dropped countries can't be restored
+			Version:     15,
+			Description: "Remove country from signups",
+			Up: []string{"ALTER TABLE signups " +
+				"DROP COLUMN country"},
+		},
 	}
 }
 
-func (r *SignupRepository) Create(e *Signup) error {
+func (r *PostgresSignupRepository) Create(e *Signup) error {
 	var id string
 	err := GetDatabase().DB().QueryRow("INSERT INTO signups "+
 		"(date, email, password, firstname, lastname, organization, language, domain) "+
@@ -74,7 +77,7 @@ func (r *SignupRepository) Create(e *Signup) error {
 	return nil
 }
 
-func (r *SignupRepository) GetOne(id string) (*Signup, error) {
+func (r *PostgresSignupRepository) GetOne(id string) (*Signup, error) {
 	e := &Signup{}
 	err := GetDatabase().DB().QueryRow("SELECT id, date, email, password, firstname, lastname, organization, language, domain "+
 		"FROM signups "+
@@ -86,7 +89,7 @@ func (r *SignupRepository) GetOne(id string) (*Signup, error) {
 	return e, nil
 }
 
-func (r *SignupRepository) GetByEmail(email string) (*Signup, error) {
+func (r *PostgresSignupRepository) GetByEmail(email string) (*Signup, error) {
 	e := &Signup{}
 	err := GetDatabase().DB().QueryRow("SELECT id, date, email, password, firstname, lastname, organization, language, domain "+
 		"FROM signups "+
@@ -98,12 +101,12 @@ func (r *SignupRepository) GetByEmail(email string) (*Signup, error) {
 	return e, nil
 }
 
-func (r *SignupRepository) Delete(e *Signup) error {
+func (r *PostgresSignupRepository) Delete(e *Signup) error {
 	_, err := GetDatabase().DB().Exec("DELETE FROM signups WHERE id = $1", e.ID)
 	return err
 }
 
-func (r *SignupRepository) DeleteExpired() error {
+func (r *PostgresSignupRepository) DeleteExpired() error {
 	now := time.Now().Add(time.Hour * -2)
 	_, err := GetDatabase().DB().Exec("DELETE FROM signups WHERE date < $1", now)
 	return err
@@ -10,6 +10,7 @@ import (
 )
 
 type SignupRouter struct {
+	repos *Repositories
 }
 
 type SignupRequest struct {
@@ -57,14 +58,14 @@ func (router *SignupRouter) signup(w http.ResponseWriter, r *http.Request) {
 	signup := &Signup{
 		Date:         time.Now(),
 		Email:        m.Email,
-		Password:     GetUserRepository().GetHashedPassword(m.Password),
+		Password:     router.repos.User.GetHashedPassword(m.Password),
 		Firstname:    m.Firstname,
 		Lastname:     m.Lastname,
 		Organization: m.Organization,
 		Language:     m.Language,
 		Domain:       domain,
 	}
-	if err := GetSignupRepository().Create(signup); err != nil {
+	if err := router.repos.Signup.Create(signup); err != nil {
 		log.Println(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -79,14 +80,14 @@ func (router *SignupRouter) signup(w http.ResponseWriter, r *http.Request) {
 
 func (router *SignupRouter) confirm(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	e, err := GetSignupRepository().GetOne(vars["id"])
+	e, err := router.repos.Signup.GetOne(vars["id"])
 	if err != nil {
 		log.Println(err)
 		SendNotFound(w)
 		return
 	}
 	if !router.isDomainAvailable(e.Domain) {
-		GetSignupRepository().Delete(e)
+		router.repos.Signup.Delete(e)
 		w.WriteHeader(http.StatusConflict)
 		return
 	}
@@ -98,12 +99,12 @@ func (router *SignupRouter) confirm(w http.ResponseWriter, r *http.Request) {
 		Language:         e.Language,
 		SignupDate:       e.Date,
 	}
-	if err := GetOrganizationRepository().Create(org); err != nil {
+	if err := router.repos.Organization.Create(org); err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
 	}
-	if err := GetOrganizationRepository().AddDomain(org, e.Domain, true); err != nil {
+	if err := router.repos.Organization.AddDomain(org, e.Domain, true); err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
@@ -114,16 +115,16 @@ func (router *SignupRouter) confirm(w http.ResponseWriter, r *http.Request) {
 		OrganizationID: org.ID,
 		Role:           UserRoleOrgAdmin,
 	}
-	if err := GetUserRepository().Create(user); err != nil {
+	if err := router.repos.User.Create(user); err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
 	}
-	if err := GetOrganizationRepository().createSampleData(org); err != nil {
+	if err := router.repos.Organization.createSampleData(org); err != nil {
 		log.Println(err)
 	}
 	router.sendConfirmMail(e, router.getLanguage(e.Language))
-	GetSignupRepository().Delete(e)
+	router.repos.Signup.Delete(e)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -156,11 +157,11 @@ func (router *SignupRouter) getLanguage(language string) string {
 }
 
 func (router *SignupRouter) isEmailAvailable(email string) bool {
-	org, err := GetOrganizationRepository().GetByEmail(email)
+	org, err := router.repos.Organization.GetByEmail(email)
 	if (err == nil) && (org != nil) {
 		return false
 	}
-	signup, err := GetSignupRepository().GetByEmail(email)
+	signup, err := router.repos.Signup.GetByEmail(email)
 	if (err == nil) && (signup != nil) {
 		return false
 	}
@@ -168,7 +169,7 @@ func (router *SignupRouter) isEmailAvailable(email string) bool {
 }
 
 func (router *SignupRouter) isDomainAvailable(domain string) bool {
-	org, err := GetOrganizationRepository().GetOneByDomain(domain)
+	org, err := router.repos.Organization.GetOneByDomain(domain)
 	if (err == nil) && (org != nil) {
 		return false
 	}
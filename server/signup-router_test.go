@@ -207,7 +207,7 @@ func TestSignupEmailConflictExistingOrg(t *testing.T) {
 
 	org := createTestOrg("testorg.on.seatsurfing.app")
 	org.ContactEmail = "foo@bar.com"
-	GetOrganizationRepository().Update(org)
+	testRepositories.Organization.Update(org)
 
 	payload := `{
 			"firstname": "",
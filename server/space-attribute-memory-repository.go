@@ -0,0 +1,142 @@
+package main
+
+type MemorySpaceAttributeRepository struct {
+	spaceAttributeRepositoryBase
+}
+
+func (r *MemorySpaceAttributeRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemorySpaceAttributeRepository) Create(e *SpaceAttribute) error {
+	if err := memoryCheckUUID(e.OrganizationID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e.ID = memoryNewID()
+	row := *e
+	memoryData.spaceAttributes = append(memoryData.spaceAttributes, &row)
+	return nil
+}
+
+func (r *MemorySpaceAttributeRepository) GetOne(id string) (*SpaceAttribute, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryGet(memoryData.spaceAttributes, func(e *SpaceAttribute) bool {
+		return e.ID == id
+	})
+}
+
+func (r *MemorySpaceAttributeRepository) GetAll(organizationID string) ([]*SpaceAttribute, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	result := memoryCopy(memoryFilter(memoryData.spaceAttributes, func(e *SpaceAttribute) bool {
+		return e.OrganizationID == organizationID
+	}))
+	memorySort(result, func(a, b *SpaceAttribute) bool {
+		return memoryCompare(a.Label, b.Label) < 0
+	})
+	return result, nil
+}
+
+func (r *MemorySpaceAttributeRepository) Update(e *SpaceAttribute) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	row, err := memoryFind(memoryData.spaceAttributes, func(row *SpaceAttribute) bool {
+		return row.ID == e.ID
+	})
+	if err != nil {
+		return nil
+	}
+	row.Label = e.Label
+	return nil
+}
+
+func (r *MemorySpaceAttributeRepository) Delete(e *SpaceAttribute) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.spaceAttributeValues = memoryDelete(memoryData.spaceAttributeValues, func(row *SpaceAttributeValue) bool {
+		return row.AttributeID == e.ID
+	})
+	memoryData.spaceAttributes = memoryDelete(memoryData.spaceAttributes, func(row *SpaceAttribute) bool {
+		return row.ID == e.ID
+	})
+	return nil
+}
+
+func (r *MemorySpaceAttributeRepository) DeleteAll(organizationID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	attributeIDs := make(map[string]bool)
+	for _, e := range memoryData.spaceAttributes {
+		if e.OrganizationID == organizationID {
+			attributeIDs[e.ID] = true
+		}
+	}
+	memoryData.spaceAttributeValues = memoryDelete(memoryData.spaceAttributeValues, func(row *SpaceAttributeValue) bool {
+		return attributeIDs[row.AttributeID]
+	})
+	memoryData.spaceAttributes = memoryDelete(memoryData.spaceAttributes, func(row *SpaceAttribute) bool {
+		return attributeIDs[row.ID]
+	})
+	return nil
+}
+
+func (r *MemorySpaceAttributeRepository) SetValues(spaceID string, values []*SpaceAttributeValue) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.spaceAttributeValues = memoryDelete(memoryData.spaceAttributeValues, func(row *SpaceAttributeValue) bool {
+		return row.SpaceID == spaceID
+	})
+	for _, e := range values {
+		if err := memoryCheckUUID(e.AttributeID, spaceID); err != nil {
+			return err
+		}
+		if memoryCount(memoryData.spaceAttributeValues, func(row *SpaceAttributeValue) bool {
+			return row.AttributeID == e.AttributeID && row.SpaceID == spaceID
+		}) > 0 {
+			return memoryUniqueViolation("space_attribute_values_pkey")
+		}
+		memoryData.spaceAttributeValues = append(memoryData.spaceAttributeValues, &SpaceAttributeValue{
+			AttributeID: e.AttributeID,
+			SpaceID:     spaceID,
+			Value:       e.Value,
+		})
+	}
+	return nil
+}
+
+func (r *MemorySpaceAttributeRepository) GetValues(spaceID string) ([]*SpaceAttributeValue, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryCopy(memoryFilter(memoryData.spaceAttributeValues, func(e *SpaceAttributeValue) bool {
+		return e.SpaceID == spaceID
+	})), nil
+}
+
+func (r *MemorySpaceAttributeRepository) GetValuesInLocation(locationID string) (map[string][]*SpaceAttributeValue, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	result := make(map[string][]*SpaceAttributeValue)
+	for _, e := range memoryData.spaceAttributeValues {
+		if memoryCount(memoryData.spaces, func(space *Space) bool {
+			return space.ID == e.SpaceID && space.LocationID == locationID
+		}) == 0 {
+			continue
+		}
+		c := *e
+		result[e.SpaceID] = append(result[e.SpaceID], &c)
+	}
+	return result, nil
+}
+
+func (r *MemorySpaceAttributeRepository) DeleteValues(spaceID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.spaceAttributeValues = memoryDelete(memoryData.spaceAttributeValues, func(row *SpaceAttributeValue) bool {
+		return row.SpaceID == spaceID
+	})
+	return nil
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+type SpaceAttributeRepository interface {
+	Repository
+	Create(e *SpaceAttribute) error
+	GetOne(id string) (*SpaceAttribute, error)
+	GetAll(organizationID string) ([]*SpaceAttribute, error)
+	Update(e *SpaceAttribute) error
+	Delete(e *SpaceAttribute) error
+	DeleteAll(organizationID string) error
+	SetValues(spaceID string, values []*SpaceAttributeValue) error
+	GetValues(spaceID string) ([]*SpaceAttributeValue, error)
+	GetValuesInLocation(locationID string) (map[string][]*SpaceAttributeValue, error)
+	DeleteValues(spaceID string) error
+	IsValidValue(attributeType SettingType, value string) bool
+	IsValidComparator(attributeType SettingType, comparator SpaceAttributeComparator) bool
+	Matches(values []*SpaceAttributeValue, filters []*SpaceAttributeFilter) bool
+}
+
+type PostgresSpaceAttributeRepository struct {
+	spaceAttributeRepositoryBase
+}
+
+// spaceAttributeRepositoryBase validates and matches attribute values. It doesn't access the storage.
+type spaceAttributeRepositoryBase struct {
+}
+
+// SpaceAttribute is a typed property spaces of an organization can have, i.e. a
+// monitor count (SettingTypeInt) or whether it's a standing desk (SettingTypeBool).
+type SpaceAttribute struct {
+	ID             string
+	OrganizationID string
+	Label          string
+	Type           SettingType
+}
+
+type SpaceAttributeValue struct {
+	AttributeID string
+	SpaceID     string
+	Value       string
+}
+
+type SpaceAttributeComparator string
+
+const (
+	SpaceAttributeComparatorEq       SpaceAttributeComparator = "eq"
+	SpaceAttributeComparatorNeq      SpaceAttributeComparator = "neq"
+	SpaceAttributeComparatorGt       SpaceAttributeComparator = "gt"
+	SpaceAttributeComparatorGte      SpaceAttributeComparator = "gte"
+	SpaceAttributeComparatorLt       SpaceAttributeComparator = "lt"
+	SpaceAttributeComparatorLte      SpaceAttributeComparator = "lte"
+	SpaceAttributeComparatorContains SpaceAttributeComparator = "contains"
+)
+
+// SpaceAttributeFilter matches spaces whose value of the attribute compares to Value.
+type SpaceAttributeFilter struct {
+	Attribute  *SpaceAttribute
+	Comparator SpaceAttributeComparator
+	Value      string
+}
+
+func (r *PostgresSpaceAttributeRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		{
+			Version:     31,
+			Description: "Add space attributes",
+			Up: []string{
+				"CREATE TABLE IF NOT EXISTS space_attributes (" +
+					"id uuid DEFAULT uuid_generate_v4(), " +
+					"organization_id uuid NOT NULL, " +
+					"label VARCHAR NOT NULL, " +
+					"attribute_type INT NOT NULL, " +
+					"PRIMARY KEY (id))",
+				"CREATE INDEX IF NOT EXISTS idx_space_attributes_organization_id ON space_attributes(organization_id)",
+				"CREATE TABLE IF NOT EXISTS space_attribute_values (" +
+					"attribute_id uuid NOT NULL, " +
+					"space_id uuid NOT NULL, " +
+					"value VARCHAR NOT NULL, " +
+					"PRIMARY KEY (attribute_id, space_id))",
+				"CREATE INDEX IF NOT EXISTS idx_space_attribute_values_space_id ON space_attribute_values(space_id)",
+			},
+			Down: []string{
+				"DROP TABLE IF EXISTS space_attribute_values",
+				"DROP TABLE IF EXISTS space_attributes",
+			},
+		},
+	}
+}
+
+func (r *PostgresSpaceAttributeRepository) Create(e *SpaceAttribute) error {
+	var id string
+	err := GetDatabase().DB().QueryRow("INSERT INTO space_attributes "+
+		"(organization_id, label, attribute_type) "+
+		"VALUES ($1, $2, $3) "+
+		"RETURNING id",
+		e.OrganizationID, e.Label, e.Type).Scan(&id)
+	if err != nil {
+		return err
+	}
+	e.ID = id
+	return nil
+}
+
+func (r *PostgresSpaceAttributeRepository) GetOne(id string) (*SpaceAttribute, error) {
+	e := &SpaceAttribute{}
+	err := GetDatabase().DB().QueryRow("SELECT id, organization_id, label, attribute_type "+
+		"FROM space_attributes "+
+		"WHERE id = $1",
+		id).Scan(&e.ID, &e.OrganizationID, &e.Label, &e.Type)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (r *PostgresSpaceAttributeRepository) GetAll(organizationID string) ([]*SpaceAttribute, error) {
+	var result []*SpaceAttribute
+	rows, err := GetDatabase().DB().Query("SELECT id, organization_id, label, attribute_type "+
+		"FROM space_attributes "+
+		"WHERE organization_id = $1 "+
+		"ORDER BY label", organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &SpaceAttribute{}
+		err = rows.Scan(&e.ID, &e.OrganizationID, &e.Label, &e.Type)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+// Update changes the attribute's label. The type can't be changed as existing values
+// wouldn't match it anymore.
+func (r *PostgresSpaceAttributeRepository) Update(e *SpaceAttribute) error {
+	_, err := GetDatabase().DB().Exec("UPDATE space_attributes SET "+
+		"label = $1 "+
+		"WHERE id = $2",
+		e.Label, e.ID)
+	return err
+}
+
+func (r *PostgresSpaceAttributeRepository) Delete(e *SpaceAttribute) error {
+	if _, err := GetDatabase().DB().Exec("DELETE FROM space_attribute_values WHERE attribute_id = $1", e.ID); err != nil {
+		return err
+	}
+	_, err := GetDatabase().DB().Exec("DELETE FROM space_attributes WHERE id = $1", e.ID)
+	return err
+}
+
+func (r *PostgresSpaceAttributeRepository) DeleteAll(organizationID string) error {
+	if _, err := GetDatabase().DB().Exec("DELETE FROM space_attribute_values WHERE attribute_id IN "+
+		"(SELECT id FROM space_attributes WHERE organization_id = $1)", organizationID); err != nil {
+		return err
+	}
+	_, err := GetDatabase().DB().Exec("DELETE FROM space_attributes WHERE organization_id = $1", organizationID)
+	return err
+}
+
+// SetValues replaces all attribute values of the space.
+func (r *PostgresSpaceAttributeRepository) SetValues(spaceID string, values []*SpaceAttributeValue) error {
+	if err := r.DeleteValues(spaceID); err != nil {
+		return err
+	}
+	for _, e := range values {
+		if _, err := GetDatabase().DB().Exec("INSERT INTO space_attribute_values "+
+			"(attribute_id, space_id, value) "+
+			"VALUES ($1, $2, $3)",
+			e.AttributeID, spaceID, e.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PostgresSpaceAttributeRepository) GetValues(spaceID string) ([]*SpaceAttributeValue, error) {
+	var result []*SpaceAttributeValue
+	rows, err := GetDatabase().DB().Query("SELECT attribute_id, space_id, value "+
+		"FROM space_attribute_values "+
+		"WHERE space_id = $1", spaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &SpaceAttributeValue{}
+		err = rows.Scan(&e.AttributeID, &e.SpaceID, &e.Value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+// GetValuesInLocation returns the attribute values of all spaces in the location, keyed by space ID.
+func (r *PostgresSpaceAttributeRepository) GetValuesInLocation(locationID string) (map[string][]*SpaceAttributeValue, error) {
+	result := make(map[string][]*SpaceAttributeValue)
+	rows, err := GetDatabase().DB().Query("SELECT space_attribute_values.attribute_id, space_attribute_values.space_id, space_attribute_values.value "+
+		"FROM space_attribute_values "+
+		"INNER JOIN spaces ON spaces.id = space_attribute_values.space_id "+
+		"WHERE spaces.location_id = $1", locationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &SpaceAttributeValue{}
+		err = rows.Scan(&e.AttributeID, &e.SpaceID, &e.Value)
+		if err != nil {
+			return nil, err
+		}
+		result[e.SpaceID] = append(result[e.SpaceID], e)
+	}
+	return result, nil
+}
+
+func (r *PostgresSpaceAttributeRepository) DeleteValues(spaceID string) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM space_attribute_values WHERE space_id = $1", spaceID)
+	return err
+}
+
+// IsValidValue returns true if value can be stored for an attribute of the given type.
+func (r *spaceAttributeRepositoryBase) IsValidValue(attributeType SettingType, value string) bool {
+	switch attributeType {
+	case SettingTypeBool:
+		return value == "1" || value == "0"
+	case SettingTypeInt:
+		_, err := strconv.Atoi(value)
+		return err == nil
+	case SettingTypeString:
+		return true
+	}
+	return false
+}
+
+// IsValidComparator returns true if the comparator can be used in filters on attributes of the given type.
+func (r *spaceAttributeRepositoryBase) IsValidComparator(attributeType SettingType, comparator SpaceAttributeComparator) bool {
+	switch comparator {
+	case SpaceAttributeComparatorEq, SpaceAttributeComparatorNeq:
+		return true
+	case SpaceAttributeComparatorGt, SpaceAttributeComparatorGte, SpaceAttributeComparatorLt, SpaceAttributeComparatorLte:
+		return attributeType == SettingTypeInt
+	case SpaceAttributeComparatorContains:
+		return attributeType == SettingTypeString
+	}
+	return false
+}
+
+// Matches returns true if the values of a space satisfy all filters. Spaces without a
+// value for a bool attribute are treated as "0", missing values of other types never match.
+func (r *spaceAttributeRepositoryBase) Matches(values []*SpaceAttributeValue, filters []*SpaceAttributeFilter) bool {
+	for _, filter := range filters {
+		value, ok := "", false
+		for _, e := range values {
+			if e.AttributeID == filter.Attribute.ID {
+				value, ok = e.Value, true
+				break
+			}
+		}
+		if !ok && filter.Attribute.Type == SettingTypeBool {
+			value, ok = "0", true
+		}
+		if !ok || !r.matchesFilter(filter, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *spaceAttributeRepositoryBase) matchesFilter(filter *SpaceAttributeFilter, value string) bool {
+	if filter.Attribute.Type == SettingTypeInt {
+		a, err := strconv.Atoi(value)
+		if err != nil {
+			return false
+		}
+		b, err := strconv.Atoi(filter.Value)
+		if err != nil {
+			return false
+		}
+		switch filter.Comparator {
+		case SpaceAttributeComparatorEq:
+			return a == b
+		case SpaceAttributeComparatorNeq:
+			return a != b
+		case SpaceAttributeComparatorGt:
+			return a > b
+		case SpaceAttributeComparatorGte:
+			return a >= b
+		case SpaceAttributeComparatorLt:
+			return a < b
+		case SpaceAttributeComparatorLte:
+			return a <= b
+		}
+		return false
+	}
+	switch filter.Comparator {
+	case SpaceAttributeComparatorEq:
+		return strings.EqualFold(value, filter.Value)
+	case SpaceAttributeComparatorNeq:
+		return !strings.EqualFold(value, filter.Value)
+	case SpaceAttributeComparatorContains:
+		return strings.Contains(strings.ToLower(value), strings.ToLower(filter.Value))
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type SpaceAttributeRouter struct {
+	repos *Repositories
+}
+
+type CreateSpaceAttributeRequest struct {
+	Label string      `json:"label" validate:"required"`
+	Type  SettingType `json:"type" validate:"required"`
+}
+
+type GetSpaceAttributeResponse struct {
+	ID string `json:"id"`
+	CreateSpaceAttributeRequest
+}
+
+func (router *SpaceAttributeRouter) setupRoutes(s *mux.Router) {
+	s.HandleFunc("/{id}", router.getOne).Methods("GET")
+	s.HandleFunc("/{id}", router.update).Methods("PUT")
+	s.HandleFunc("/{id}", router.delete).Methods("DELETE")
+	s.HandleFunc("/", router.create).Methods("POST")
+	s.HandleFunc("/", router.getAll).Methods("GET")
+}
+
+func (router *SpaceAttributeRouter) getOne(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	e, err := router.repos.SpaceAttribute.GetOne(vars["id"])
+	if err != nil {
+		SendNotFound(w)
+		return
+	}
+	if !CanAccessOrg(GetRequestUser(router.repos, r), e.OrganizationID) {
+		SendForbidden(w)
+		return
+	}
+	res := router.copyToRestModel(e)
+	SendJSON(w, res)
+}
+
+func (router *SpaceAttributeRouter) getAll(w http.ResponseWriter, r *http.Request) {
+	user := GetRequestUser(router.repos, r)
+	list, err := router.repos.SpaceAttribute.GetAll(user.OrganizationID)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	res := []*GetSpaceAttributeResponse{}
+	for _, e := range list {
+		m := router.copyToRestModel(e)
+		res = append(res, m)
+	}
+	SendJSON(w, res)
+}
+
+func (router *SpaceAttributeRouter) create(w http.ResponseWriter, r *http.Request) {
+	user := GetRequestUser(router.repos, r)
+	if !CanSpaceAdminOrg(user, user.OrganizationID) {
+		SendForbidden(w)
+		return
+	}
+	var m CreateSpaceAttributeRequest
+	if UnmarshalValidateBody(r, &m) != nil || !router.isValidType(m.Type) {
+		SendBadRequest(w)
+		return
+	}
+	e := router.copyFromRestModel(&m)
+	e.OrganizationID = user.OrganizationID
+	if err := router.repos.SpaceAttribute.Create(e); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	SendCreated(w, e.ID)
+}
+
+func (router *SpaceAttributeRouter) update(w http.ResponseWriter, r *http.Request) {
+	e, ok := router.getSpaceAttribute(w, r)
+	if !ok {
+		return
+	}
+	var m CreateSpaceAttributeRequest
+	if UnmarshalValidateBody(r, &m) != nil || m.Type != e.Type {
+		SendBadRequest(w)
+		return
+	}
+	e.Label = m.Label
+	if err := router.repos.SpaceAttribute.Update(e); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	SendUpdated(w)
+}
+
+func (router *SpaceAttributeRouter) delete(w http.ResponseWriter, r *http.Request) {
+	e, ok := router.getSpaceAttribute(w, r)
+	if !ok {
+		return
+	}
+	if err := router.repos.SpaceAttribute.Delete(e); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	SendUpdated(w)
+}
+
+func (router *SpaceAttributeRouter) getSpaceAttribute(w http.ResponseWriter, r *http.Request) (*SpaceAttribute, bool) {
+	vars := mux.Vars(r)
+	e, err := router.repos.SpaceAttribute.GetOne(vars["id"])
+	if err != nil {
+		SendNotFound(w)
+		return nil, false
+	}
+	if !CanSpaceAdminOrg(GetRequestUser(router.repos, r), e.OrganizationID) {
+		SendForbidden(w)
+		return nil, false
+	}
+	return e, true
+}
+
+func (router *SpaceAttributeRouter) isValidType(attributeType SettingType) bool {
+	return attributeType == SettingTypeInt || attributeType == SettingTypeBool || attributeType == SettingTypeString
+}
+
+func (router *SpaceAttributeRouter) copyFromRestModel(m *CreateSpaceAttributeRequest) *SpaceAttribute {
+	e := &SpaceAttribute{}
+	e.Label = m.Label
+	e.Type = m.Type
+	return e
+}
+
+func (router *SpaceAttributeRouter) copyToRestModel(e *SpaceAttribute) *GetSpaceAttributeResponse {
+	m := &GetSpaceAttributeResponse{}
+	m.ID = e.ID
+	m.Label = e.Label
+	m.Type = e.Type
+	return m
+}
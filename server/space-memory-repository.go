@@ -0,0 +1,193 @@
+package main
+
+import "time"
+
+type MemorySpaceRepository struct {
+	repos *Repositories
+}
+
+func (r *MemorySpaceRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemorySpaceRepository) Create(e *Space) error {
+	if err := memoryCheckUUID(e.LocationID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e.ID = memoryNewID()
+	row := *e
+	memoryData.spaces = append(memoryData.spaces, &row)
+	return nil
+}
+
+func (r *MemorySpaceRepository) GetOne(id string) (*Space, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryGet(memoryData.spaces, func(e *Space) bool {
+		return e.ID == id
+	})
+}
+
+func (r *MemorySpaceRepository) GetAllInTime(locationID string, enter, leave time.Time) ([]*SpaceAvailability, error) {
+	enter, leave = memoryTimestamp(enter), memoryTimestamp(leave)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	var result []*SpaceAvailability
+	for _, space := range r.getAll(func(e *Space) bool {
+		return e.LocationID == locationID
+	}) {
+		e := &SpaceAvailability{Space: *space, Available: true}
+		var bookings []*memoryBooking
+		for _, booking := range memoryData.bookings {
+			if booking.SpaceID == space.ID && memoryOverlaps(enter, leave, booking.Enter, booking.Leave) {
+				e.Available = false
+				bookings = append(bookings, booking)
+			}
+		}
+		memorySort(bookings, func(a, b *memoryBooking) bool {
+			return a.Enter.Before(b.Enter)
+		})
+		for _, booking := range bookings {
+			user := memoryUserByID(booking.UserID)
+			if user == nil {
+				continue
+			}
+			e.Bookings = append(e.Bookings, &SpaceAvailabilityBookingEntry{
+				BookingID: booking.ID,
+				UserID:    user.ID,
+				UserEmail: user.Email,
+				Enter:     booking.Enter,
+				Leave:     booking.Leave,
+			})
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (r *MemorySpaceRepository) GetByKeyword(organizationID string, keyword string) ([]*Space, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return r.getAll(func(e *Space) bool {
+		location := memoryLocationByID(e.LocationID)
+		if location == nil || location.OrganizationID != organizationID {
+			return false
+		}
+		if memoryContains(e.Name, keyword) {
+			return true
+		}
+		for _, value := range memoryData.spaceAttributeValues {
+			if value.SpaceID != e.ID {
+				continue
+			}
+			attribute, err := memoryFind(memoryData.spaceAttributes, func(a *SpaceAttribute) bool {
+				return a.ID == value.AttributeID
+			})
+			if err != nil {
+				continue
+			}
+			if memoryContains(attribute.Label, keyword) && value.Value != "" && value.Value != "0" {
+				return true
+			}
+			if attribute.Type == SettingTypeString && memoryContains(value.Value, keyword) {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (r *MemorySpaceRepository) GetAll(locationID string) ([]*Space, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return r.getAll(func(e *Space) bool {
+		return e.LocationID == locationID
+	}), nil
+}
+
+// getAll returns copies of the matching spaces ordered by name. The caller must hold the lock.
+func (r *MemorySpaceRepository) getAll(cond func(*Space) bool) []*Space {
+	result := memoryCopy(memoryFilter(memoryData.spaces, cond))
+	memorySort(result, func(a, b *Space) bool {
+		return memoryCompare(a.Name, b.Name) < 0
+	})
+	return result
+}
+
+func (r *MemorySpaceRepository) Update(e *Space) error {
+	if err := memoryCheckUUID(e.LocationID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	row, err := memoryFind(memoryData.spaces, func(row *Space) bool {
+		return row.ID == e.ID
+	})
+	if err != nil {
+		return nil
+	}
+	*row = *e
+	return nil
+}
+
+func (r *MemorySpaceRepository) Delete(e *Space) error {
+	if err := r.repos.SpaceAttribute.DeleteValues(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.BookingRestriction.DeleteAll(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.Waitlist.DeleteOfSpace(e); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.spaces = memoryDelete(memoryData.spaces, func(row *Space) bool {
+		return row.ID == e.ID
+	})
+	return nil
+}
+
+func (r *MemorySpaceRepository) GetCount(organizationID string) (int, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryCount(memoryData.spaces, func(e *Space) bool {
+		location := memoryLocationByID(e.LocationID)
+		return location != nil && location.OrganizationID == organizationID
+	}), nil
+}
+
+// memorySpaceDetails returns the space joined with its location, limited to the
+// columns selected by the PostgreSQL implementation, or nil if one of them doesn't
+// exist. The caller must hold the lock.
+func memorySpaceDetails(spaceID string) *SpaceDetails {
+	space, err := memoryFind(memoryData.spaces, func(e *Space) bool {
+		return e.ID == spaceID
+	})
+	if err != nil {
+		return nil
+	}
+	location := memoryLocationByID(space.LocationID)
+	if location == nil {
+		return nil
+	}
+	e := &SpaceDetails{}
+	e.ID = space.ID
+	e.LocationID = space.LocationID
+	e.Name = space.Name
+	e.Location.ID = location.ID
+	e.Location.OrganizationID = location.OrganizationID
+	e.Location.Name = location.Name
+	e.Location.Description = location.Description
+	e.Location.Timezone = location.Timezone
+	return e
+}
+
+// memoryOverlaps returns true if the closed ranges [enter1, leave1] and [enter2, leave2]
+// intersect, like the availability and conflict checks of the PostgreSQL implementation.
+func memoryOverlaps(enter1, leave1, enter2, leave2 time.Time) bool {
+	return !enter1.After(leave2) && !enter2.After(leave1)
+}
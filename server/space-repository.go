@@ -2,15 +2,39 @@ package main
 
 import (
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/lib/pq"
 )
 
-type SpaceRepository struct {
+type SpaceRepository interface {
+	Repository
+	Create(e *Space) error
+	GetOne(id string) (*Space, error)
+	GetAllInTime(locationID string, enter, leave time.Time) ([]*SpaceAvailability, error)
+	GetByKeyword(organizationID string, keyword string) ([]*Space, error)
+	GetAll(locationID string) ([]*Space, error)
+	Update(e *Space) error
+	Delete(e *Space) error
+	GetCount(organizationID string) (int, error)
 }
 
+type PostgresSpaceRepository struct {
+	repos *Repositories
+}
+
+type SpaceType int
+
+const (
+	SpaceTypeDesk        SpaceType = 1
+	SpaceTypeMeetingRoom SpaceType = 2
+	SpaceTypeParking     SpaceType = 3
+	SpaceTypeLocker      SpaceType = 4
+)
+
+// Space is a bookable desk, meeting room, parking spot or locker. Capacity is the
+// number of people who can use the space at once, i.e. the organizer and attendees
+// of a meeting room booking.
 type Space struct {
 	ID         string
 	LocationID string
@@ -20,6 +44,10 @@ type Space struct {
 	Width      uint
 	Height     uint
 	Rotation   uint
+	Type       SpaceType
+	Capacity   uint
+	// RequiresApproval creates bookings pending until approved by an approver of the location.
+	RequiresApproval bool
 }
 
 type SpaceAvailabilityBookingEntry struct {
@@ -41,44 +69,49 @@ type SpaceDetails struct {
 	Space
 }
 
-var spaceRepository *SpaceRepository
-var spaceRepositoryOnce sync.Once
-
-func GetSpaceRepository() *SpaceRepository {
-	spaceRepositoryOnce.Do(func() {
-		spaceRepository = &SpaceRepository{}
-		_, err := GetDatabase().DB().Exec("CREATE TABLE IF NOT EXISTS spaces (" +
-			"id uuid DEFAULT uuid_generate_v4(), " +
-			"location_id uuid NOT NULL, " +
-			"name VARCHAR NOT NULL, " +
-			"x INTEGER, " +
-			"y INTEGER, " +
-			"width INTEGER, " +
-			"height INTEGER, " +
-			"rotation INTEGER, " +
-			"PRIMARY KEY (id))")
-		if err != nil {
-			panic(err)
-		}
-		_, err = GetDatabase().DB().Exec("CREATE INDEX IF NOT EXISTS idx_spaces_location_id ON spaces(location_id)")
-		if err != nil {
-			panic(err)
-		}
-	})
-	return spaceRepository
-}
-
-func (r *SpaceRepository) RunSchemaUpgrade(curVersion, targetVersion int) {
-	// No updates yet
+func (r *PostgresSpaceRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		newInitialSchemaMigration(
+			"CREATE TABLE IF NOT EXISTS spaces ("+
+				"id uuid DEFAULT uuid_generate_v4(), "+
+				"location_id uuid NOT NULL, "+
+				"name VARCHAR NOT NULL, "+
+				"x INTEGER, "+
+				"y INTEGER, "+
+				"width INTEGER, "+
+				"height INTEGER, "+
+				"rotation INTEGER, "+
+				"PRIMARY KEY (id))",
+			"CREATE INDEX IF NOT EXISTS idx_spaces_location_id ON spaces(location_id)",
+		),
+		{
+			Version:     21,
+			Description: "Add type and capacity to spaces",
+			Up: []string{"ALTER TABLE spaces " +
+				"ADD COLUMN space_type INTEGER NOT NULL DEFAULT 1, " +
+				"ADD COLUMN capacity INTEGER NOT NULL DEFAULT 1"},
+			Down: []string{"ALTER TABLE spaces " +
+				"DROP COLUMN space_type, " +
+				"DROP COLUMN capacity"},
+		},
+		{
+			Version:     22,
+			Description: "Add approval requirement to spaces",
+			Up: []string{"ALTER TABLE spaces " +
+				"ADD COLUMN requires_approval boolean NOT NULL DEFAULT FALSE"},
+			Down: []string{"ALTER TABLE spaces " +
+				"DROP COLUMN requires_approval"},
+		},
+	}
 }
 
-func (r *SpaceRepository) Create(e *Space) error {
+func (r *PostgresSpaceRepository) Create(e *Space) error {
 	var id string
 	err := GetDatabase().DB().QueryRow("INSERT INTO spaces "+
-		"(name, location_id, x, y, width, height, rotation) "+
-		"VALUES ($1, $2, $3, $4, $5, $6, $7) "+
+		"(name, location_id, x, y, width, height, rotation, space_type, capacity, requires_approval) "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) "+
 		"RETURNING id",
-		e.Name, e.LocationID, e.X, e.Y, e.Width, e.Height, e.Rotation).Scan(&id)
+		e.Name, e.LocationID, e.X, e.Y, e.Width, e.Height, e.Rotation, e.Type, e.Capacity, e.RequiresApproval).Scan(&id)
 	if err != nil {
 		return err
 	}
@@ -86,19 +119,19 @@ func (r *SpaceRepository) Create(e *Space) error {
 	return nil
 }
 
-func (r *SpaceRepository) GetOne(id string) (*Space, error) {
+func (r *PostgresSpaceRepository) GetOne(id string) (*Space, error) {
 	e := &Space{}
-	err := GetDatabase().DB().QueryRow("SELECT id, location_id, name, x, y, width, height, rotation "+
+	err := GetDatabase().DB().QueryRow("SELECT id, location_id, name, x, y, width, height, rotation, space_type, capacity, requires_approval "+
 		"FROM spaces "+
 		"WHERE id = $1",
-		id).Scan(&e.ID, &e.LocationID, &e.Name, &e.X, &e.Y, &e.Width, &e.Height, &e.Rotation)
+		id).Scan(&e.ID, &e.LocationID, &e.Name, &e.X, &e.Y, &e.Width, &e.Height, &e.Rotation, &e.Type, &e.Capacity, &e.RequiresApproval)
 	if err != nil {
 		return nil, err
 	}
 	return e, nil
 }
 
-func (r *SpaceRepository) GetAllInTime(locationID string, enter, leave time.Time) ([]*SpaceAvailability, error) {
+func (r *PostgresSpaceRepository) GetAllInTime(locationID string, enter, leave time.Time) ([]*SpaceAvailability, error) {
 	var result []*SpaceAvailability
 	subQueryWhere := "bookings.space_id = spaces.id AND (" +
 		"($1 >= bookings.enter_time AND $1 <= bookings.leave_time) OR " +
@@ -106,7 +139,7 @@ func (r *SpaceRepository) GetAllInTime(locationID string, enter, leave time.Time
 		"(bookings.enter_time >= $1 AND bookings.enter_time <= $2) OR " +
 		"(bookings.leave_time >= $1 AND bookings.leave_time <= $2)" +
 		")"
-	rows, err := GetDatabase().DB().Query("SELECT id, location_id, name, x, y, width, height, rotation, "+
+	rows, err := GetDatabase().DB().Query("SELECT id, location_id, name, x, y, width, height, rotation, space_type, capacity, requires_approval, "+
 		"NOT EXISTS(SELECT id FROM bookings WHERE "+subQueryWhere+"), "+
 		"ARRAY(SELECT CONCAT(users.id, '@@@', users.email, '@@@', bookings.enter_time, '@@@', bookings.leave_time, '@@@', bookings.id) FROM bookings INNER JOIN users ON users.id = bookings.user_id WHERE "+subQueryWhere+" ORDER BY bookings.enter_time ASC) "+
 		"FROM spaces "+
@@ -119,7 +152,7 @@ func (r *SpaceRepository) GetAllInTime(locationID string, enter, leave time.Time
 	for rows.Next() {
 		e := &SpaceAvailability{}
 		var bookingUserNames []string
-		err = rows.Scan(&e.ID, &e.LocationID, &e.Name, &e.X, &e.Y, &e.Width, &e.Height, &e.Rotation, &e.Available, pq.Array(&bookingUserNames))
+		err = rows.Scan(&e.ID, &e.LocationID, &e.Name, &e.X, &e.Y, &e.Width, &e.Height, &e.Rotation, &e.Type, &e.Capacity, &e.RequiresApproval, &e.Available, pq.Array(&bookingUserNames))
 		for _, bookingUserName := range bookingUserNames {
 			tokens := strings.Split(bookingUserName, "@@@")
 			timeFormat := "2006-01-02 15:04:05"
@@ -142,20 +175,31 @@ func (r *SpaceRepository) GetAllInTime(locationID string, enter, leave time.Time
 	return result, nil
 }
 
-func (r *SpaceRepository) GetByKeyword(organizationID string, keyword string) ([]*Space, error) {
+// GetByKeyword returns spaces whose name matches the keyword. Spaces also match by attribute:
+// if the label of an attribute which is set (not empty or "0") matches, i.e. "standing desk",
+// or if the value of a string attribute matches.
+func (r *PostgresSpaceRepository) GetByKeyword(organizationID string, keyword string) ([]*Space, error) {
 	var result []*Space
-	rows, err := GetDatabase().DB().Query("SELECT spaces.id, spaces.location_id, spaces.name, spaces.x, spaces.y, spaces.width, spaces.height, spaces.rotation "+
+	rows, err := GetDatabase().DB().Query("SELECT spaces.id, spaces.location_id, spaces.name, spaces.x, spaces.y, spaces.width, spaces.height, spaces.rotation, spaces.space_type, spaces.capacity, spaces.requires_approval "+
 		"FROM spaces "+
 		"INNER JOIN locations ON locations.id = spaces.location_id "+
-		"WHERE locations.organization_id = $1 AND LOWER(spaces.name) LIKE '%' || $2 || '%'"+
-		"ORDER BY spaces.name", organizationID, strings.ToLower(keyword))
+		"WHERE locations.organization_id = $1 AND ("+
+		"LOWER(spaces.name) LIKE '%' || $2 || '%' OR "+
+		"EXISTS(SELECT 1 FROM space_attribute_values "+
+		"INNER JOIN space_attributes ON space_attributes.id = space_attribute_values.attribute_id "+
+		"WHERE space_attribute_values.space_id = spaces.id AND ("+
+		"(LOWER(space_attributes.label) LIKE '%' || $2 || '%' AND space_attribute_values.value NOT IN ('', '0')) OR "+
+		"(space_attributes.attribute_type = $3 AND LOWER(space_attribute_values.value) LIKE '%' || $2 || '%')"+
+		"))"+
+		") "+
+		"ORDER BY spaces.name", organizationID, strings.ToLower(keyword), SettingTypeString)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	for rows.Next() {
 		e := &Space{}
-		err = rows.Scan(&e.ID, &e.LocationID, &e.Name, &e.X, &e.Y, &e.Width, &e.Height, &e.Rotation)
+		err = rows.Scan(&e.ID, &e.LocationID, &e.Name, &e.X, &e.Y, &e.Width, &e.Height, &e.Rotation, &e.Type, &e.Capacity, &e.RequiresApproval)
 		if err != nil {
 			return nil, err
 		}
@@ -164,9 +208,9 @@ func (r *SpaceRepository) GetByKeyword(organizationID string, keyword string) ([
 	return result, nil
 }
 
-func (r *SpaceRepository) GetAll(locationID string) ([]*Space, error) {
+func (r *PostgresSpaceRepository) GetAll(locationID string) ([]*Space, error) {
 	var result []*Space
-	rows, err := GetDatabase().DB().Query("SELECT id, location_id, name, x, y, width, height, rotation "+
+	rows, err := GetDatabase().DB().Query("SELECT id, location_id, name, x, y, width, height, rotation, space_type, capacity, requires_approval "+
 		"FROM spaces "+
 		"WHERE location_id = $1 "+
 		"ORDER BY name", locationID)
@@ -176,7 +220,7 @@ func (r *SpaceRepository) GetAll(locationID string) ([]*Space, error) {
 	defer rows.Close()
 	for rows.Next() {
 		e := &Space{}
-		err = rows.Scan(&e.ID, &e.LocationID, &e.Name, &e.X, &e.Y, &e.Width, &e.Height, &e.Rotation)
+		err = rows.Scan(&e.ID, &e.LocationID, &e.Name, &e.X, &e.Y, &e.Width, &e.Height, &e.Rotation, &e.Type, &e.Capacity, &e.RequiresApproval)
 		if err != nil {
 			return nil, err
 		}
@@ -184,7 +228,7 @@ func (r *SpaceRepository) GetAll(locationID string) ([]*Space, error) {
 	}
 	return result, nil
 }
-func (r *SpaceRepository) Update(e *Space) error {
+func (r *PostgresSpaceRepository) Update(e *Space) error {
 	_, err := GetDatabase().DB().Exec("UPDATE spaces SET "+
 		"location_id = $1, "+
 		"name = $2, "+
@@ -192,21 +236,33 @@ func (r *SpaceRepository) Update(e *Space) error {
 		"y = $4, "+
 		"width = $5, "+
 		"height = $6, "+
-		"rotation = $7 "+
-		"WHERE id = $8",
-		e.LocationID, e.Name, e.X, e.Y, e.Width, e.Height, e.Rotation, e.ID)
+		"rotation = $7, "+
+		"space_type = $8, "+
+		"capacity = $9, "+
+		"requires_approval = $10 "+
+		"WHERE id = $11",
+		e.LocationID, e.Name, e.X, e.Y, e.Width, e.Height, e.Rotation, e.Type, e.Capacity, e.RequiresApproval, e.ID)
 	return err
 }
 
-func (r *SpaceRepository) Delete(e *Space) error {
+func (r *PostgresSpaceRepository) Delete(e *Space) error {
 	// if _, err := GetDatabase().DB().Exec("DELETE FROM bookings WHERE bookings.space_id = $1", e.ID); err != nil {
 	// 	return err
 	// }
+	if err := r.repos.SpaceAttribute.DeleteValues(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.BookingRestriction.DeleteAll(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.Waitlist.DeleteOfSpace(e); err != nil {
+		return err
+	}
 	_, err := GetDatabase().DB().Exec("DELETE FROM spaces WHERE id = $1", e.ID)
 	return err
 }
 
-func (r *SpaceRepository) GetCount(organizationID string) (int, error) {
+func (r *PostgresSpaceRepository) GetCount(organizationID string) (int, error) {
 	var res int
 	err := GetDatabase().DB().QueryRow("SELECT COUNT(spaces.id) "+
 		"FROM spaces "+
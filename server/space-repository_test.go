@@ -10,20 +10,20 @@ func TestSpacesCount(t *testing.T) {
 		OrganizationID: org.ID,
 		Name:           "L1",
 	}
-	GetLocationRepository().Create(l1)
+	testRepositories.Location.Create(l1)
 
 	s1 := &Space{
 		LocationID: l1.ID,
 		Name:       "S1",
 	}
-	GetSpaceRepository().Create(s1)
+	testRepositories.Space.Create(s1)
 	s2 := &Space{
 		LocationID: l1.ID,
 		Name:       "S2",
 	}
-	GetSpaceRepository().Create(s2)
+	testRepositories.Space.Create(s2)
 
-	res, err := GetSpaceRepository().GetCount(org.ID)
+	res, err := testRepositories.Space.GetCount(org.ID)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -9,15 +9,36 @@ import (
 )
 
 type SpaceRouter struct {
+	repos *Repositories
 }
 
+type SpaceAttributeValueRequest struct {
+	AttributeID string `json:"attributeId" validate:"required"`
+	Value       string `json:"value"`
+}
+
+type SpaceAttributeFilterRequest struct {
+	AttributeID string                   `json:"attributeId" validate:"required"`
+	Comparator  SpaceAttributeComparator `json:"comparator" validate:"required"`
+	Value       string                   `json:"value"`
+}
+
+// CreateSpaceRequest replaces the space's attribute values only if Attributes is
+// set, so clients not aware of attributes don't remove them on update. Type and
+// Capacity default to a desk for one person. Bookings of spaces which require
+// approval are pending until an approver of the location approves them.
 type CreateSpaceRequest struct {
-	Name     string `json:"name" validate:"required"`
-	X        uint   `json:"x"`
-	Y        uint   `json:"y"`
-	Width    uint   `json:"width"`
-	Height   uint   `json:"height"`
-	Rotation uint   `json:"rotation"`
+	Name             string                        `json:"name" validate:"required"`
+	X                uint                          `json:"x"`
+	Y                uint                          `json:"y"`
+	Width            uint                          `json:"width"`
+	Height           uint                          `json:"height"`
+	Rotation         uint                          `json:"rotation"`
+	Type             SpaceType                     `json:"type"`
+	Capacity         uint                          `json:"capacity"`
+	RequiresApproval bool                          `json:"requiresApproval"`
+	Attributes       []*SpaceAttributeValueRequest `json:"attributes" validate:"dive"`
+	BookingRestrictionRequest
 }
 
 type UpdateSpaceRequest struct {
@@ -58,14 +79,18 @@ type GetSpaceAvailabilityBookingsResponse struct {
 	Leave     time.Time `json:"leave"`
 }
 
+// GetSpaceAvailabilityResponse is marked as restricted and unavailable if the requesting
+// user's groups are not allowed to book the space.
 type GetSpaceAvailabilityResponse struct {
 	GetSpaceResponse
-	Bookings []*GetSpaceAvailabilityBookingsResponse `json:"bookings"`
+	Restricted bool                                    `json:"restricted"`
+	Bookings   []*GetSpaceAvailabilityBookingsResponse `json:"bookings"`
 }
 
 type GetSpaceAvailabilityRequest struct {
-	Enter time.Time `json:"enter" validate:"required"`
-	Leave time.Time `json:"leave" validate:"required"`
+	Enter      time.Time                      `json:"enter" validate:"required"`
+	Leave      time.Time                      `json:"leave" validate:"required"`
+	Attributes []*SpaceAttributeFilterRequest `json:"attributes" validate:"dive"`
 }
 
 func (router *SpaceRouter) setupRoutes(s *mux.Router) {
@@ -80,23 +105,37 @@ func (router *SpaceRouter) setupRoutes(s *mux.Router) {
 
 func (router *SpaceRouter) getOne(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	e, err := GetSpaceRepository().GetOne(vars["id"])
+	e, err := router.repos.Space.GetOne(vars["id"])
 	if err != nil {
 		log.Println(err)
 		SendNotFound(w)
 		return
 	}
-	location, err := GetLocationRepository().GetOne(e.LocationID)
+	location, err := router.repos.Location.GetOne(e.LocationID)
 	if err != nil {
 		SendBadRequest(w)
 		return
 	}
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanAccessOrg(user, location.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
+	values, err := router.repos.SpaceAttribute.GetValues(e.ID)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	restrictions, err := router.repos.BookingRestriction.GetAll(e.ID)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
 	res := router.copyToRestModel(e)
+	res.Attributes = router.copyAttributeValuesToRestModel(values)
+	res.AllowedGroupIDs, res.DeniedGroupIDs = router.repos.BookingRestriction.Split(restrictions)
 	SendJSON(w, res)
 }
 
@@ -107,22 +146,22 @@ func (router *SpaceRouter) getAvailability(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	vars := mux.Vars(r)
-	location, err := GetLocationRepository().GetOne(vars["locationId"])
+	location, err := router.repos.Location.GetOne(vars["locationId"])
 	if err != nil {
 		SendBadRequest(w)
 		return
 	}
-	enterNew, err := attachTimezoneInformation(m.Enter, location)
+	enterNew, err := attachTimezoneInformation(router.repos, m.Enter, location)
 	if err != nil {
 		SendInternalServerError(w)
 		return
 	}
-	leaveNew, err := attachTimezoneInformation(m.Leave, location)
+	leaveNew, err := attachTimezoneInformation(router.repos, m.Leave, location)
 	if err != nil {
 		SendInternalServerError(w)
 		return
 	}
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanAccessOrg(user, location.OrganizationID) {
 		SendForbidden(w)
 		return
@@ -131,9 +170,38 @@ func (router *SpaceRouter) getAvailability(w http.ResponseWriter, r *http.Reques
 	if CanSpaceAdminOrg(user, location.OrganizationID) {
 		showNames = true
 	} else {
-		showNames, _ = GetSettingsRepository().GetBool(location.OrganizationID, SettingShowNames.Name)
+		showNames, _ = router.repos.Settings.GetBool(location.OrganizationID, SettingShowNames.Name)
+	}
+	attributes, err := router.getAttributes(location.OrganizationID)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	filters, ok := router.copyAttributeFiltersFromRestModel(attributes, m.Attributes)
+	if !ok {
+		SendBadRequest(w)
+		return
+	}
+	list, err := router.repos.Space.GetAllInTime(location.ID, enterNew, leaveNew)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
 	}
-	list, err := GetSpaceRepository().GetAllInTime(location.ID, enterNew, leaveNew)
+	values, err := router.repos.SpaceAttribute.GetValuesInLocation(location.ID)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	restrictions, err := router.repos.BookingRestriction.GetAllInLocation(location.ID)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	groupIDs, err := router.repos.BookingRestriction.GetGroupIDs(user.ID)
 	if err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
@@ -141,6 +209,9 @@ func (router *SpaceRouter) getAvailability(w http.ResponseWriter, r *http.Reques
 	}
 	res := []*GetSpaceAvailabilityResponse{}
 	for _, e := range list {
+		if !router.repos.SpaceAttribute.Matches(values[e.ID], filters) {
+			continue
+		}
 		m := &GetSpaceAvailabilityResponse{}
 		m.ID = e.ID
 		m.LocationID = e.LocationID
@@ -150,12 +221,21 @@ func (router *SpaceRouter) getAvailability(w http.ResponseWriter, r *http.Reques
 		m.Width = e.Width
 		m.Height = e.Height
 		m.Rotation = e.Rotation
+		m.Type = e.Type
+		m.Capacity = e.Capacity
+		m.RequiresApproval = e.RequiresApproval
 		m.Available = e.Available
+		m.Attributes = router.copyAttributeValuesToRestModel(values[e.ID])
+		m.AllowedGroupIDs, m.DeniedGroupIDs = router.repos.BookingRestriction.Split(restrictions[e.ID])
+		if !router.repos.BookingRestriction.IsAllowedForGroups(groupIDs, restrictions[location.ID], restrictions[e.ID]) {
+			m.Restricted = true
+			m.Available = false
+		}
 		m.Bookings = []*GetSpaceAvailabilityBookingsResponse{}
 		for _, booking := range e.Bookings {
 			var showName bool = showNames
-			enter, _ := attachTimezoneInformation(booking.Enter, location)
-			leave, _ := attachTimezoneInformation(booking.Leave, location)
+			enter, _ := attachTimezoneInformation(router.repos, booking.Enter, location)
+			leave, _ := attachTimezoneInformation(router.repos, booking.Leave, location)
 			outUserId := ""
 			outUserEmail := ""
 			if showName || user.Email == booking.UserEmail {
@@ -183,17 +263,24 @@ func (router *SpaceRouter) bulkUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	vars := mux.Vars(r)
-	location, err := GetLocationRepository().GetOne(vars["locationId"])
+	location, err := router.repos.Location.GetOne(vars["locationId"])
 	if err != nil {
 		SendBadRequest(w)
 		return
 	}
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanSpaceAdminOrg(user, location.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
 
+	attributes, err := router.getAttributes(location.OrganizationID)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+
 	res := BulkUpdateResponse{
 		Creates: []BulkUpdateItemResponse{},
 		Updates: []BulkUpdateItemResponse{},
@@ -203,14 +290,15 @@ func (router *SpaceRouter) bulkUpdate(w http.ResponseWriter, r *http.Request) {
 	// Process deletes
 	if m.DeleteIDs != nil {
 		for _, deleteID := range m.DeleteIDs {
-			e, err := GetSpaceRepository().GetOne(deleteID)
+			e, err := router.repos.Space.GetOne(deleteID)
 			if err != nil {
 				res.Deletes = append(res.Deletes, BulkUpdateItemResponse{ID: deleteID, Success: false})
 			} else {
-				if err := GetSpaceRepository().Delete(e); err != nil {
+				if err := router.repos.Space.Delete(e); err != nil {
 					res.Deletes = append(res.Deletes, BulkUpdateItemResponse{ID: deleteID, Success: false})
 				} else {
 					res.Deletes = append(res.Deletes, BulkUpdateItemResponse{ID: deleteID, Success: true})
+					fireWebhookEvent(router.repos, location.OrganizationID, WebhookEventSpaceDeleted, router.copyToRestModel(e))
 				}
 			}
 		}
@@ -221,11 +309,17 @@ func (router *SpaceRouter) bulkUpdate(w http.ResponseWriter, r *http.Request) {
 		for _, mSpace := range m.Creates {
 			e := router.copyFromRestModel(&mSpace)
 			e.LocationID = vars["locationId"]
-			if err := GetSpaceRepository().Create(e); err != nil {
+			values, ok := router.copyAttributeValuesFromRestModel(attributes, mSpace.Attributes)
+			if !ok || !router.isValidType(mSpace.Type) || !router.isValidRestrictions(location.OrganizationID, &mSpace) {
+				res.Creates = append(res.Creates, BulkUpdateItemResponse{ID: "", Success: false})
+				continue
+			}
+			if err := router.createWithDetails(e, &mSpace, values); err != nil {
 				log.Println(err)
 				res.Creates = append(res.Creates, BulkUpdateItemResponse{ID: "", Success: false})
 			} else {
 				res.Creates = append(res.Creates, BulkUpdateItemResponse{ID: e.ID, Success: true})
+				fireWebhookEvent(router.repos, location.OrganizationID, WebhookEventSpaceCreated, router.copyToRestModel(e))
 			}
 		}
 	}
@@ -236,7 +330,12 @@ func (router *SpaceRouter) bulkUpdate(w http.ResponseWriter, r *http.Request) {
 			e := router.copyFromRestModel(&mSpace.CreateSpaceRequest)
 			e.ID = mSpace.ID
 			e.LocationID = vars["locationId"]
-			if err := GetSpaceRepository().Update(e); err != nil {
+			values, ok := router.copyAttributeValuesFromRestModel(attributes, mSpace.Attributes)
+			if !ok || !router.isValidType(mSpace.Type) || !router.isValidRestrictions(location.OrganizationID, &mSpace.CreateSpaceRequest) {
+				res.Updates = append(res.Updates, BulkUpdateItemResponse{ID: "", Success: false})
+				continue
+			}
+			if err := router.updateWithDetails(e, &mSpace.CreateSpaceRequest, values); err != nil {
 				log.Println(err)
 				res.Updates = append(res.Updates, BulkUpdateItemResponse{ID: "", Success: false})
 			} else {
@@ -249,17 +348,29 @@ func (router *SpaceRouter) bulkUpdate(w http.ResponseWriter, r *http.Request) {
 
 func (router *SpaceRouter) getAll(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	location, err := GetLocationRepository().GetOne(vars["locationId"])
+	location, err := router.repos.Location.GetOne(vars["locationId"])
 	if err != nil {
 		SendBadRequest(w)
 		return
 	}
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanAccessOrg(user, location.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
-	list, err := GetSpaceRepository().GetAll(location.ID)
+	list, err := router.repos.Space.GetAll(location.ID)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	values, err := router.repos.SpaceAttribute.GetValuesInLocation(location.ID)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	restrictions, err := router.repos.BookingRestriction.GetAllInLocation(location.ID)
 	if err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
@@ -268,6 +379,8 @@ func (router *SpaceRouter) getAll(w http.ResponseWriter, r *http.Request) {
 	res := []*GetSpaceResponse{}
 	for _, e := range list {
 		m := router.copyToRestModel(e)
+		m.Attributes = router.copyAttributeValuesToRestModel(values[e.ID])
+		m.AllowedGroupIDs, m.DeniedGroupIDs = router.repos.BookingRestriction.Split(restrictions[e.ID])
 		res = append(res, m)
 	}
 	SendJSON(w, res)
@@ -275,7 +388,7 @@ func (router *SpaceRouter) getAll(w http.ResponseWriter, r *http.Request) {
 
 func (router *SpaceRouter) update(w http.ResponseWriter, r *http.Request) {
 	var m CreateSpaceRequest
-	if UnmarshalValidateBody(r, &m) != nil {
+	if UnmarshalValidateBody(r, &m) != nil || !router.isValidType(m.Type) {
 		SendBadRequest(w)
 		return
 	}
@@ -283,17 +396,22 @@ func (router *SpaceRouter) update(w http.ResponseWriter, r *http.Request) {
 	e := router.copyFromRestModel(&m)
 	e.ID = vars["id"]
 	e.LocationID = vars["locationId"]
-	location, err := GetLocationRepository().GetOne(e.LocationID)
+	location, err := router.repos.Location.GetOne(e.LocationID)
 	if err != nil {
 		SendBadRequest(w)
 		return
 	}
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanSpaceAdminOrg(user, location.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
-	if err := GetSpaceRepository().Update(e); err != nil {
+	values, ok := router.getAttributeValues(location.OrganizationID, m.Attributes)
+	if !ok || !router.isValidRestrictions(location.OrganizationID, &m) {
+		SendBadRequest(w)
+		return
+	}
+	if err := router.updateWithDetails(e, &m, values); err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
@@ -303,55 +421,163 @@ func (router *SpaceRouter) update(w http.ResponseWriter, r *http.Request) {
 
 func (router *SpaceRouter) delete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	e, err := GetSpaceRepository().GetOne(vars["id"])
+	e, err := router.repos.Space.GetOne(vars["id"])
 	if err != nil {
 		SendNotFound(w)
 		return
 	}
-	location, err := GetLocationRepository().GetOne(e.LocationID)
+	location, err := router.repos.Location.GetOne(e.LocationID)
 	if err != nil {
 		SendBadRequest(w)
 		return
 	}
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanSpaceAdminOrg(user, location.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
-	if err := GetSpaceRepository().Delete(e); err != nil {
+	if err := router.repos.Space.Delete(e); err != nil {
 		SendInternalServerError(w)
 		return
 	}
+	fireWebhookEvent(router.repos, location.OrganizationID, WebhookEventSpaceDeleted, router.copyToRestModel(e))
 	SendUpdated(w)
 }
 
 func (router *SpaceRouter) create(w http.ResponseWriter, r *http.Request) {
 	var m CreateSpaceRequest
-	if UnmarshalValidateBody(r, &m) != nil {
+	if UnmarshalValidateBody(r, &m) != nil || !router.isValidType(m.Type) {
 		SendBadRequest(w)
 		return
 	}
 	vars := mux.Vars(r)
 	e := router.copyFromRestModel(&m)
 	e.LocationID = vars["locationId"]
-	location, err := GetLocationRepository().GetOne(e.LocationID)
+	location, err := router.repos.Location.GetOne(e.LocationID)
 	if err != nil {
 		SendBadRequest(w)
 		return
 	}
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanSpaceAdminOrg(user, location.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
-	if err := GetSpaceRepository().Create(e); err != nil {
+	values, ok := router.getAttributeValues(location.OrganizationID, m.Attributes)
+	if !ok || !router.isValidRestrictions(location.OrganizationID, &m) {
+		SendBadRequest(w)
+		return
+	}
+	if err := router.createWithDetails(e, &m, values); err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
 	}
+	fireWebhookEvent(router.repos, location.OrganizationID, WebhookEventSpaceCreated, router.copyToRestModel(e))
 	SendCreated(w, e.ID)
 }
 
+func (router *SpaceRouter) createWithDetails(e *Space, m *CreateSpaceRequest, values []*SpaceAttributeValue) error {
+	if err := router.repos.Space.Create(e); err != nil {
+		return err
+	}
+	return router.setDetails(e, m, values)
+}
+
+func (router *SpaceRouter) updateWithDetails(e *Space, m *CreateSpaceRequest, values []*SpaceAttributeValue) error {
+	if err := router.repos.Space.Update(e); err != nil {
+		return err
+	}
+	return router.setDetails(e, m, values)
+}
+
+// setDetails stores the attribute values and booking restrictions set in the request.
+func (router *SpaceRouter) setDetails(e *Space, m *CreateSpaceRequest, values []*SpaceAttributeValue) error {
+	if m.Attributes != nil {
+		if err := router.repos.SpaceAttribute.SetValues(e.ID, values); err != nil {
+			return err
+		}
+	}
+	return setBookingRestrictions(router.repos, e.ID, &m.BookingRestrictionRequest)
+}
+
+func (router *SpaceRouter) isValidRestrictions(organizationID string, m *CreateSpaceRequest) bool {
+	return router.repos.BookingRestriction.IsValidGroupList(organizationID, m.AllowedGroupIDs, m.DeniedGroupIDs)
+}
+
+// getAttributes returns the organization's space attributes, keyed by ID.
+func (router *SpaceRouter) getAttributes(organizationID string) (map[string]*SpaceAttribute, error) {
+	list, err := router.repos.SpaceAttribute.GetAll(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]*SpaceAttribute)
+	for _, e := range list {
+		res[e.ID] = e
+	}
+	return res, nil
+}
+
+func (router *SpaceRouter) getAttributeValues(organizationID string, m []*SpaceAttributeValueRequest) ([]*SpaceAttributeValue, bool) {
+	if len(m) == 0 {
+		return []*SpaceAttributeValue{}, true
+	}
+	attributes, err := router.getAttributes(organizationID)
+	if err != nil {
+		log.Println(err)
+		return nil, false
+	}
+	return router.copyAttributeValuesFromRestModel(attributes, m)
+}
+
+// copyAttributeValuesFromRestModel returns false if an attribute is unknown, set
+// more than once or the value doesn't match the attribute's type.
+func (router *SpaceRouter) copyAttributeValuesFromRestModel(attributes map[string]*SpaceAttribute, m []*SpaceAttributeValueRequest) ([]*SpaceAttributeValue, bool) {
+	res := []*SpaceAttributeValue{}
+	seen := make(map[string]bool)
+	for _, item := range m {
+		attribute, ok := attributes[item.AttributeID]
+		if !ok || seen[item.AttributeID] || !router.repos.SpaceAttribute.IsValidValue(attribute.Type, item.Value) {
+			return nil, false
+		}
+		seen[item.AttributeID] = true
+		res = append(res, &SpaceAttributeValue{
+			AttributeID: item.AttributeID,
+			Value:       item.Value,
+		})
+	}
+	return res, true
+}
+
+func (router *SpaceRouter) copyAttributeFiltersFromRestModel(attributes map[string]*SpaceAttribute, m []*SpaceAttributeFilterRequest) ([]*SpaceAttributeFilter, bool) {
+	res := []*SpaceAttributeFilter{}
+	for _, item := range m {
+		attribute, ok := attributes[item.AttributeID]
+		if !ok ||
+			!router.repos.SpaceAttribute.IsValidComparator(attribute.Type, item.Comparator) ||
+			!router.repos.SpaceAttribute.IsValidValue(attribute.Type, item.Value) {
+			return nil, false
+		}
+		res = append(res, &SpaceAttributeFilter{
+			Attribute:  attribute,
+			Comparator: item.Comparator,
+			Value:      item.Value,
+		})
+	}
+	return res, true
+}
+
+func (router *SpaceRouter) copyAttributeValuesToRestModel(values []*SpaceAttributeValue) []*SpaceAttributeValueRequest {
+	res := []*SpaceAttributeValueRequest{}
+	for _, e := range values {
+		res = append(res, &SpaceAttributeValueRequest{
+			AttributeID: e.AttributeID,
+			Value:       e.Value,
+		})
+	}
+	return res
+}
+
 func (router *SpaceRouter) copyFromRestModel(m *CreateSpaceRequest) *Space {
 	e := &Space{}
 	e.Name = m.Name
@@ -360,9 +586,23 @@ func (router *SpaceRouter) copyFromRestModel(m *CreateSpaceRequest) *Space {
 	e.Width = m.Width
 	e.Height = m.Height
 	e.Rotation = m.Rotation
+	e.Type = m.Type
+	if e.Type == 0 {
+		e.Type = SpaceTypeDesk
+	}
+	e.Capacity = m.Capacity
+	if e.Capacity == 0 {
+		e.Capacity = 1
+	}
+	e.RequiresApproval = m.RequiresApproval
 	return e
 }
 
+// isValidType returns true if the type is known or not set.
+func (router *SpaceRouter) isValidType(spaceType SpaceType) bool {
+	return spaceType == 0 || (spaceType >= SpaceTypeDesk && spaceType <= SpaceTypeLocker)
+}
+
 func (router *SpaceRouter) copyToRestModel(e *Space) *GetSpaceResponse {
 	m := &GetSpaceResponse{}
 	m.ID = e.ID
@@ -373,5 +613,11 @@ func (router *SpaceRouter) copyToRestModel(e *Space) *GetSpaceResponse {
 	m.Width = e.Width
 	m.Height = e.Height
 	m.Rotation = e.Rotation
+	m.Type = e.Type
+	m.Capacity = e.Capacity
+	m.RequiresApproval = e.RequiresApproval
+	m.Attributes = []*SpaceAttributeValueRequest{}
+	m.AllowedGroupIDs = []string{}
+	m.DeniedGroupIDs = []string{}
 	return m
 }
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"testing"
+
+	"github.com/google/uuid"
 )
 
 func TestSpacesSameOrgForbidden(t *testing.T) {
@@ -229,7 +231,7 @@ func TestSpacesAvailabilityOuter(t *testing.T) {
 	org := createTestOrg("test.com")
 	user := createTestUserOrgAdmin(org)
 	loginResponse := loginTestUser(user.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 
 	locationID, spaceID, _, _ := createTestSpaces(t, loginResponse)
 
@@ -262,7 +264,7 @@ func TestSpacesAvailabilityInner(t *testing.T) {
 	org := createTestOrg("test.com")
 	user := createTestUserOrgAdmin(org)
 	loginResponse := loginTestUser(user.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 
 	locationID, spaceID, _, _ := createTestSpaces(t, loginResponse)
 
@@ -295,7 +297,7 @@ func TestSpacesAvailabilityStart(t *testing.T) {
 	org := createTestOrg("test.com")
 	user := createTestUserOrgAdmin(org)
 	loginResponse := loginTestUser(user.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 
 	locationID, spaceID, _, _ := createTestSpaces(t, loginResponse)
 
@@ -328,7 +330,7 @@ func TestSpacesAvailabilityEnd(t *testing.T) {
 	org := createTestOrg("test.com")
 	user := createTestUserOrgAdmin(org)
 	loginResponse := loginTestUser(user.ID)
-	GetSettingsRepository().Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
 
 	locationID, spaceID, _, _ := createTestSpaces(t, loginResponse)
 
@@ -381,6 +383,158 @@ func TestSpacesAvailabilityNoBookings(t *testing.T) {
 	checkTestBool(t, true, resBody[2].Available)
 }
 
+func TestSpacesAvailabilityRestricted(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	loginResponse := loginTestUser(admin.ID)
+	user := createTestUserInOrg(org)
+
+	locationID, spaceID, _, _ := createTestSpaces(t, loginResponse)
+	group := &UserGroup{OrganizationID: org.ID, Name: "Executives"}
+	testRepositories.UserGroup.Create(group)
+	testRepositories.BookingRestriction.SetAll(spaceID, []string{group.ID}, []string{})
+
+	getAvailability := func(userID string) []*GetSpaceAvailabilityResponse {
+		payload := `{"enter": "2030-09-01T08:30:00+02:00", "leave": "2030-09-01T17:00:00+02:00"}`
+		req := newHTTPRequest("POST", "/location/"+locationID+"/space/availability", userID, bytes.NewBufferString(payload))
+		res := executeTestRequest(req)
+		checkTestResponseCode(t, http.StatusOK, res.Code)
+		var resBody []*GetSpaceAvailabilityResponse
+		json.Unmarshal(res.Body.Bytes(), &resBody)
+		return resBody
+	}
+
+	resBody := getAvailability(user.ID)
+	checkTestInt(t, 3, len(resBody))
+	checkTestString(t, spaceID, resBody[0].ID)
+	checkTestBool(t, false, resBody[0].Available)
+	checkTestBool(t, true, resBody[0].Restricted)
+	checkTestBool(t, true, resBody[1].Available)
+	checkTestBool(t, false, resBody[1].Restricted)
+
+	testRepositories.UserGroup.AddMembers(group, []string{user.ID})
+	resBody = getAvailability(user.ID)
+	checkTestBool(t, true, resBody[0].Available)
+	checkTestBool(t, false, resBody[0].Restricted)
+
+	// Invalid group
+	payload := `{"name": "H234", "deniedGroupIds": ["` + uuid.New().String() + `"]}`
+	req := newHTTPRequest("PUT", "/location/"+locationID+"/space/"+spaceID, admin.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+	payload = `{"name": "H234", "allowedGroupIds": ["` + group.ID + `"], "deniedGroupIds": ["` + group.ID + `"]}`
+	req = newHTTPRequest("PUT", "/location/"+locationID+"/space/"+spaceID, admin.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+}
+
+func TestSpacesAttributes(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	user := createTestUserOrgAdmin(org)
+	loginResponse := loginTestUser(user.ID)
+
+	// Create attributes
+	payload := `{"label": "Monitors", "type": 1}`
+	req := newHTTPRequest("POST", "/space-attribute/", loginResponse.UserID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	monitorsID := res.Header().Get("X-Object-Id")
+	payload = `{"label": "Standing desk", "type": 2}`
+	req = newHTTPRequest("POST", "/space-attribute/", loginResponse.UserID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	standingID := res.Header().Get("X-Object-Id")
+
+	locationID, space1ID, space2ID, _ := createTestSpaces(t, loginResponse)
+
+	// Set attributes
+	payload = `{"name": "H234", "attributes": [{"attributeId": "` + monitorsID + `", "value": "2"}, {"attributeId": "` + standingID + `", "value": "1"}]}`
+	req = newHTTPRequest("PUT", "/location/"+locationID+"/space/"+space1ID, loginResponse.UserID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+	payload = `{"name": "H236", "attributes": [{"attributeId": "` + monitorsID + `", "value": "2"}]}`
+	req = newHTTPRequest("PUT", "/location/"+locationID+"/space/"+space2ID, loginResponse.UserID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	// Invalid value
+	payload = `{"name": "H236", "attributes": [{"attributeId": "` + standingID + `", "value": "yes"}]}`
+	req = newHTTPRequest("PUT", "/location/"+locationID+"/space/"+space2ID, loginResponse.UserID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+
+	// Update without attributes keeps them
+	payload = `{"name": "H234"}`
+	req = newHTTPRequest("PUT", "/location/"+locationID+"/space/"+space1ID, loginResponse.UserID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+	req = newHTTPRequest("GET", "/location/"+locationID+"/space/"+space1ID, loginResponse.UserID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody *GetSpaceResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestInt(t, 2, len(resBody.Attributes))
+
+	// Filter availability
+	payload = `{"enter": "2020-09-01T08:30:00+02:00", "leave": "2020-09-01T17:00:00+02:00", "attributes": [{"attributeId": "` + monitorsID + `", "comparator": "gte", "value": "2"}]}`
+	req = newHTTPRequest("POST", "/location/"+locationID+"/space/availability", loginResponse.UserID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody2 []*GetSpaceAvailabilityResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody2)
+	checkTestInt(t, 2, len(resBody2))
+	checkTestString(t, "H234", resBody2[0].Name)
+	checkTestString(t, "H236", resBody2[1].Name)
+
+	payload = `{"enter": "2020-09-01T08:30:00+02:00", "leave": "2020-09-01T17:00:00+02:00", "attributes": [{"attributeId": "` + monitorsID + `", "comparator": "gte", "value": "2"}, {"attributeId": "` + standingID + `", "comparator": "eq", "value": "1"}]}`
+	req = newHTTPRequest("POST", "/location/"+locationID+"/space/availability", loginResponse.UserID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody3 []*GetSpaceAvailabilityResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody3)
+	checkTestInt(t, 1, len(resBody3))
+	checkTestString(t, "H234", resBody3[0].Name)
+
+	payload = `{"enter": "2020-09-01T08:30:00+02:00", "leave": "2020-09-01T17:00:00+02:00", "attributes": [{"attributeId": "` + standingID + `", "comparator": "eq", "value": "0"}]}`
+	req = newHTTPRequest("POST", "/location/"+locationID+"/space/availability", loginResponse.UserID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody4 []*GetSpaceAvailabilityResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody4)
+	checkTestInt(t, 2, len(resBody4))
+
+	// Invalid comparator for bool attribute
+	payload = `{"enter": "2020-09-01T08:30:00+02:00", "leave": "2020-09-01T17:00:00+02:00", "attributes": [{"attributeId": "` + standingID + `", "comparator": "gt", "value": "0"}]}`
+	req = newHTTPRequest("POST", "/location/"+locationID+"/space/availability", loginResponse.UserID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+
+	// Deleting the attribute removes its values
+	req = newHTTPRequest("DELETE", "/space-attribute/"+standingID, loginResponse.UserID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+	values, _ := testRepositories.SpaceAttribute.GetValues(space1ID)
+	checkTestInt(t, 1, len(values))
+}
+
+func TestSpacesAttributesForbidden(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	user := createTestUserInOrg(org)
+	loginResponse := loginTestUser(user.ID)
+
+	payload := `{"label": "Monitors", "type": 1}`
+	req := newHTTPRequest("POST", "/space-attribute/", loginResponse.UserID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+
+	req = newHTTPRequest("GET", "/space-attribute/", loginResponse.UserID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+}
+
 func createTestSpaces(t *testing.T, loginResponse *LoginResponse) (lID, s1ID, s2ID, s3ID string) {
 	// Create location
 	payload := `{"name": "Location 1"}`
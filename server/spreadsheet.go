@@ -0,0 +1,194 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	ExportFormatJSON string = "json"
+	ExportFormatCSV  string = "csv"
+	ExportFormatXLSX string = "xlsx"
+)
+
+const (
+	ContentTypeCSV  string = "text/csv"
+	ContentTypeXLSX string = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// SpreadsheetWriter streams rows of cells to a CSV or XLSX file.
+type SpreadsheetWriter interface {
+	WriteRow(cells []string) error
+	Close() error
+}
+
+// GetRequestExportFormat returns the format requested by the "format" query parameter
+// or, if not set, by the Accept header. It defaults to JSON.
+func GetRequestExportFormat(r *http.Request) (string, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		accept := r.Header.Get("Accept")
+		if strings.Contains(accept, ContentTypeCSV) {
+			return ExportFormatCSV, nil
+		}
+		if strings.Contains(accept, ContentTypeXLSX) {
+			return ExportFormatXLSX, nil
+		}
+		return ExportFormatJSON, nil
+	}
+	if format != ExportFormatJSON && format != ExportFormatCSV && format != ExportFormatXLSX {
+		return "", errors.New("unsupported export format: " + format)
+	}
+	return format, nil
+}
+
+// NewSpreadsheetWriter sets the response's headers for a download of the file in the
+// specified format. The file name is given without extension.
+func NewSpreadsheetWriter(w http.ResponseWriter, format, filename string) (SpreadsheetWriter, error) {
+	switch format {
+	case ExportFormatCSV:
+		w.Header().Set("Content-Type", ContentTypeCSV+"; charset=utf-8")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+".csv\"")
+		return &csvSpreadsheetWriter{writer: csv.NewWriter(w)}, nil
+	case ExportFormatXLSX:
+		w.Header().Set("Content-Type", ContentTypeXLSX)
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+".xlsx\"")
+		return newXLSXSpreadsheetWriter(w)
+	}
+	return nil, errors.New("unsupported export format: " + format)
+}
+
+type csvSpreadsheetWriter struct {
+	writer *csv.Writer
+}
+
+// WriteRow escapes cells which spreadsheet applications would interpret as formulas,
+// as they may contain user input (CSV injection). These are prefixed with a single
+// quote. Integers are written unchanged, so negative numbers stay numbers.
+func (s *csvSpreadsheetWriter) WriteRow(cells []string) error {
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		escaped[i] = cell
+		if cell == "" || !strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			continue
+		}
+		if n, err := strconv.Atoi(cell); err == nil && strconv.Itoa(n) == cell {
+			continue
+		}
+		escaped[i] = "'" + cell
+	}
+	return s.writer.Write(escaped)
+}
+
+func (s *csvSpreadsheetWriter) Close() error {
+	s.writer.Flush()
+	return s.writer.Error()
+}
+
+// xlsxSpreadsheetWriter writes a workbook with a single sheet. Cells holding integers
+// are written as numbers, all other cells as inline strings, so no shared strings
+// table has to be kept in memory.
+type xlsxSpreadsheetWriter struct {
+	zip    *zip.Writer
+	sheet  *bufio.Writer
+	numRow int
+}
+
+const xlsxContentTypes string = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+	`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`</Types>`
+
+const xlsxRels string = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+	`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbook string = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+	`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets>` +
+	`</workbook>`
+
+const xlsxWorkbookRels string = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+	`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`</Relationships>`
+
+func newXLSXSpreadsheetWriter(w io.Writer) (*xlsxSpreadsheetWriter, error) {
+	s := &xlsxSpreadsheetWriter{zip: zip.NewWriter(w)}
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+	for _, file := range files {
+		f, err := s.zip.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, file.content); err != nil {
+			return nil, err
+		}
+	}
+	// The sheet must be the last file, as it is streamed row by row
+	f, err := s.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	s.sheet = bufio.NewWriter(f)
+	s.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	return s, nil
+}
+
+func (s *xlsxSpreadsheetWriter) WriteRow(cells []string) error {
+	s.numRow++
+	row := strconv.Itoa(s.numRow)
+	s.sheet.WriteString(`<row r="` + row + `">`)
+	for i, cell := range cells {
+		ref := getXLSXColumnName(i) + row
+		if n, err := strconv.Atoi(cell); err == nil && strconv.Itoa(n) == cell {
+			s.sheet.WriteString(`<c r="` + ref + `"><v>` + cell + `</v></c>`)
+			continue
+		}
+		s.sheet.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">`)
+		if err := xml.EscapeText(s.sheet, []byte(cell)); err != nil {
+			return err
+		}
+		s.sheet.WriteString(`</t></is></c>`)
+	}
+	_, err := s.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (s *xlsxSpreadsheetWriter) Close() error {
+	s.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := s.sheet.Flush(); err != nil {
+		return err
+	}
+	return s.zip.Close()
+}
+
+// getXLSXColumnName returns the name of the zero-based column, i.e. A, B, ..., Z, AA, AB, ...
+func getXLSXColumnName(col int) string {
+	name := ""
+	for col >= 0 {
+		name = string(rune('A'+col%26)) + name
+		col = col/26 - 1
+	}
+	return name
+}
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
 )
 
 type StatsRouter struct {
+	repos *Repositories
 }
 
 type GetStatsResponse struct {
@@ -25,21 +30,68 @@ type GetStatsResponse struct {
 	SpaceLoadLastWeek    int `json:"spaceLoadLastWeek"`
 }
 
+type GetAnalyticsRequest struct {
+	Start      time.Time `json:"start" validate:"required"`
+	End        time.Time `json:"end" validate:"required"`
+	LocationID string    `json:"locationId"`
+	GroupBy    string    `json:"groupBy" validate:"required"`
+}
+
+type GetAnalyticsGroupResponse struct {
+	Key                    string  `json:"key"`
+	Name                   string  `json:"name"`
+	NumBookings            int     `json:"numBookings"`
+	NumNoShows             int     `json:"numNoShows"`
+	BookedMinutes          int     `json:"bookedMinutes"`
+	OccupancyRate          float64 `json:"occupancyRate"`
+	PeakConcurrency        int     `json:"peakConcurrency"`
+	AverageDurationMinutes float64 `json:"averageDurationMinutes"`
+	AverageLeadTimeMinutes float64 `json:"averageLeadTimeMinutes"`
+	NoShowRate             float64 `json:"noShowRate"`
+}
+
+type GetAnalyticsResponse struct {
+	Total  *GetAnalyticsGroupResponse   `json:"total"`
+	Groups []*GetAnalyticsGroupResponse `json:"groups"`
+}
+
+const (
+	AnalyticsGroupByLocation  string = "location"
+	AnalyticsGroupBySpace     string = "space"
+	AnalyticsGroupByWeekday   string = "weekday"
+	AnalyticsGroupByHour      string = "hour"
+	AnalyticsGroupByUserGroup string = "usergroup"
+)
+
+const AnalyticsMaxRangeDays int = 366
+
+// analyticsGroup accumulates the figures of one group while iterating the bookings.
+type analyticsGroup struct {
+	res             *GetAnalyticsGroupResponse
+	capacityMinutes float64
+	bookedMinutes   float64
+	durationMinutes float64
+	leadTimeMinutes float64
+	numLeadTimes    int
+	intervals       []*timeInterval
+}
+
 func (router *StatsRouter) setupRoutes(s *mux.Router) {
+	s.HandleFunc("/analytics/", router.getAnalytics).Methods("POST")
 	s.HandleFunc("/", router.getStats).Methods("GET")
 }
 
 func (router *StatsRouter) getStats(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanSpaceAdminOrg(user, user.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
 	m := &GetStatsResponse{}
-	m.NumUsers, _ = GetUserRepository().GetCount(user.OrganizationID)
-	m.NumBookings, _ = GetBookingRepository().GetCount(user.OrganizationID)
-	m.NumLocations, _ = GetLocationRepository().GetCount(user.OrganizationID)
-	m.NumSpaces, _ = GetSpaceRepository().GetCount(user.OrganizationID)
+	m.NumUsers, _ = router.repos.User.GetCount(user.OrganizationID)
+	m.NumBookings, _ = router.repos.Booking.GetCount(user.OrganizationID)
+	m.NumLocations, _ = router.repos.Location.GetCount(user.OrganizationID)
+	m.NumSpaces, _ = router.repos.Space.GetCount(user.OrganizationID)
 
 	now := time.Now().UTC()
 	weekday := int(now.Weekday())
@@ -55,15 +107,294 @@ func (router *StatsRouter) getStats(w http.ResponseWriter, r *http.Request) {
 	lastWeekEnter := time.Date(now.Year(), now.Month(), now.Day()-int(weekday-1)-7, 0, 0, 0, 0, now.Location())
 	lastWeekLeave := time.Date(now.Year(), now.Month(), now.Day()+int(7-weekday)-7, 23, 59, 59, 0, now.Location())
 
-	m.NumBookingsToday, _ = GetBookingRepository().GetCountDateRange(user.OrganizationID, todayEnter, todayLeave)
-	m.NumBookingsYesterday, _ = GetBookingRepository().GetCountDateRange(user.OrganizationID, yesterdayEnter, yesterdayLeave)
-	m.NumBookingsThisWeek, _ = GetBookingRepository().GetCountDateRange(user.OrganizationID, thisWeekEnter, thisWeekLeave)
-	m.NumBookingsLastWeek, _ = GetBookingRepository().GetCountDateRange(user.OrganizationID, lastWeekEnter, lastWeekLeave)
+	m.NumBookingsToday, _ = router.repos.Booking.GetCountDateRange(user.OrganizationID, todayEnter, todayLeave)
+	m.NumBookingsYesterday, _ = router.repos.Booking.GetCountDateRange(user.OrganizationID, yesterdayEnter, yesterdayLeave)
+	m.NumBookingsThisWeek, _ = router.repos.Booking.GetCountDateRange(user.OrganizationID, thisWeekEnter, thisWeekLeave)
+	m.NumBookingsLastWeek, _ = router.repos.Booking.GetCountDateRange(user.OrganizationID, lastWeekEnter, lastWeekLeave)
 
-	m.SpaceLoadToday, _ = GetBookingRepository().GetLoad(user.OrganizationID, todayEnter, todayLeave)
-	m.SpaceLoadYesterday, _ = GetBookingRepository().GetLoad(user.OrganizationID, yesterdayEnter, yesterdayLeave)
-	m.SpaceLoadThisWeek, _ = GetBookingRepository().GetLoad(user.OrganizationID, thisWeekEnter, thisWeekLeave)
-	m.SpaceLoadLastWeek, _ = GetBookingRepository().GetLoad(user.OrganizationID, lastWeekEnter, lastWeekLeave)
+	m.SpaceLoadToday, _ = router.repos.Booking.GetLoad(user.OrganizationID, todayEnter, todayLeave)
+	m.SpaceLoadYesterday, _ = router.repos.Booking.GetLoad(user.OrganizationID, yesterdayEnter, yesterdayLeave)
+	m.SpaceLoadThisWeek, _ = router.repos.Booking.GetLoad(user.OrganizationID, thisWeekEnter, thisWeekLeave)
+	m.SpaceLoadLastWeek, _ = router.repos.Booking.GetLoad(user.OrganizationID, lastWeekEnter, lastWeekLeave)
 
 	SendJSON(w, m)
 }
+
+// getAnalytics returns utilization figures of the range's confirmed bookings, grouped
+// by location, space, day of week, hour of day or user group. Start and end are local
+// wall clock times like the bookings' times.
+//
+// Booked minutes, occupancy rate and peak concurrency refer to the time within the
+// range and group, i.e. a booking from 08:00 to 17:00 is split across the hours of the
+// day. The other figures refer to whole bookings, grouped by their enter time. Lead
+// times are only known for bookings created since they are recorded. As the capacity
+// is not divided among user groups, a user group's occupancy rate is its share of the
+// capacity of all spaces, and a booking of a user in several groups counts for each.
+func (router *StatsRouter) getAnalytics(w http.ResponseWriter, r *http.Request) {
+	user := GetRequestUser(router.repos, r)
+	if !CanSpaceAdminOrg(user, user.OrganizationID) {
+		SendForbidden(w)
+		return
+	}
+	var m GetAnalyticsRequest
+	if UnmarshalValidateBody(r, &m) != nil || !m.End.After(m.Start) || m.End.Sub(m.Start) > time.Duration(AnalyticsMaxRangeDays)*24*time.Hour {
+		SendBadRequest(w)
+		return
+	}
+	var location *Location = nil
+	var locations []*Location
+	if m.LocationID != "" {
+		location, _ = router.repos.Location.GetOne(m.LocationID)
+		if location == nil {
+			SendNotFound(w)
+			return
+		}
+		if location.OrganizationID != user.OrganizationID {
+			SendForbidden(w)
+			return
+		}
+		locations = []*Location{location}
+	} else {
+		var err error
+		locations, err = router.repos.Location.GetAll(user.OrganizationID)
+		if err != nil {
+			log.Println(err)
+			SendInternalServerError(w)
+			return
+		}
+	}
+	spaces := make(map[string][]*Space)
+	spaceLocationIDs := make(map[string]string)
+	numSpaces := 0
+	for _, location := range locations {
+		list, err := router.repos.Space.GetAll(location.ID)
+		if err != nil {
+			log.Println(err)
+			SendInternalServerError(w)
+			return
+		}
+		spaces[location.ID] = list
+		for _, space := range list {
+			spaceLocationIDs[space.ID] = location.ID
+		}
+		numSpaces += len(list)
+	}
+	userGroupIDs := make(map[string][]string)
+	groups := make(map[string]*analyticsGroup)
+	var keys []string
+	addGroup := func(key, name string, capacityMinutes float64) {
+		groups[key] = &analyticsGroup{
+			res:             &GetAnalyticsGroupResponse{Key: key, Name: name},
+			capacityMinutes: capacityMinutes,
+		}
+		keys = append(keys, key)
+	}
+	rangeMinutes := m.End.Sub(m.Start).Minutes()
+	switch m.GroupBy {
+	case AnalyticsGroupByLocation:
+		for _, location := range locations {
+			addGroup(location.ID, location.Name, float64(len(spaces[location.ID]))*rangeMinutes)
+		}
+	case AnalyticsGroupBySpace:
+		for _, location := range locations {
+			for _, space := range spaces[location.ID] {
+				addGroup(space.ID, space.Name, rangeMinutes)
+			}
+		}
+	case AnalyticsGroupByWeekday:
+		for weekday := 1; weekday <= 7; weekday++ {
+			addGroup(strconv.Itoa(weekday), time.Weekday(weekday%7).String(), 0)
+		}
+	case AnalyticsGroupByHour:
+		for hour := 0; hour < 24; hour++ {
+			addGroup(strconv.Itoa(hour), fmt.Sprintf("%02d:00", hour), 0)
+		}
+	case AnalyticsGroupByUserGroup:
+		count, err := router.repos.UserGroup.GetCount(user.OrganizationID)
+		if err != nil {
+			log.Println(err)
+			SendInternalServerError(w)
+			return
+		}
+		list, err := router.repos.UserGroup.GetAll(user.OrganizationID, count, 0)
+		if err != nil {
+			log.Println(err)
+			SendInternalServerError(w)
+			return
+		}
+		for _, group := range list {
+			memberIDs, err := router.repos.UserGroup.GetMemberIDs(group)
+			if err != nil {
+				log.Println(err)
+				SendInternalServerError(w)
+				return
+			}
+			for _, memberID := range memberIDs {
+				userGroupIDs[memberID] = append(userGroupIDs[memberID], group.ID)
+			}
+			addGroup(group.ID, group.Name, float64(numSpaces)*rangeMinutes)
+		}
+	default:
+		SendBadRequest(w)
+		return
+	}
+	total := &analyticsGroup{
+		res:             &GetAnalyticsGroupResponse{},
+		capacityMinutes: float64(numSpaces) * rangeMinutes,
+	}
+	if m.GroupBy == AnalyticsGroupByWeekday || m.GroupBy == AnalyticsGroupByHour {
+		for _, interval := range router.splitAnalyticsInterval(&timeInterval{Enter: m.Start, Leave: m.End}) {
+			groups[router.getAnalyticsTimeKey(m.GroupBy, interval.Enter)].capacityMinutes += float64(numSpaces) * interval.Leave.Sub(interval.Enter).Minutes()
+		}
+	}
+	// getKeys returns the keys of the groups a booking or no-show belongs to
+	getKeys := func(userID, spaceID string, enter time.Time) []string {
+		switch m.GroupBy {
+		case AnalyticsGroupByLocation:
+			return []string{spaceLocationIDs[spaceID]}
+		case AnalyticsGroupBySpace:
+			return []string{spaceID}
+		case AnalyticsGroupByUserGroup:
+			return userGroupIDs[userID]
+		}
+		return []string{router.getAnalyticsTimeKey(m.GroupBy, enter)}
+	}
+
+	list, err := router.repos.Booking.GetAllForAnalytics(user.OrganizationID, m.LocationID, m.Start, m.End)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	timezones := make(map[string]*time.Location)
+	for _, e := range list {
+		interval := &timeInterval{Enter: e.Enter, Leave: e.Leave}
+		if interval.Enter.Before(m.Start) {
+			interval.Enter = m.Start
+		}
+		if interval.Leave.After(m.End) {
+			interval.Leave = m.End
+		}
+		leadTime := -1.0
+		if e.Created != nil {
+			tz, ok := timezones[e.Location.ID]
+			if !ok {
+				tz, err = time.LoadLocation(router.repos.Location.GetTimezone(&e.Location))
+				if err != nil {
+					log.Println(err)
+					tz = time.UTC
+				}
+				timezones[e.Location.ID] = tz
+			}
+			created := e.Created.In(tz)
+			created = time.Date(created.Year(), created.Month(), created.Day(), created.Hour(), created.Minute(), created.Second(), 0, time.UTC)
+			leadTime = math.Max(e.Enter.Sub(created).Minutes(), 0)
+		}
+		addBooking := func(group *analyticsGroup) {
+			group.res.NumBookings++
+			group.durationMinutes += e.Leave.Sub(e.Enter).Minutes()
+			if leadTime >= 0 {
+				group.leadTimeMinutes += leadTime
+				group.numLeadTimes++
+			}
+		}
+		addBooking(total)
+		total.intervals = append(total.intervals, interval)
+		for _, key := range getKeys(e.UserID, e.SpaceID, e.Enter) {
+			if group, ok := groups[key]; ok {
+				addBooking(group)
+			}
+		}
+		if m.GroupBy == AnalyticsGroupByWeekday || m.GroupBy == AnalyticsGroupByHour {
+			for _, part := range router.splitAnalyticsInterval(interval) {
+				group := groups[router.getAnalyticsTimeKey(m.GroupBy, part.Enter)]
+				group.intervals = append(group.intervals, part)
+			}
+		} else {
+			for _, key := range getKeys(e.UserID, e.SpaceID, e.Enter) {
+				if group, ok := groups[key]; ok {
+					group.intervals = append(group.intervals, interval)
+				}
+			}
+		}
+	}
+
+	noShows, err := router.repos.NoShow.GetAllByOrg(user.OrganizationID, location, m.Start, m.End)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	for _, e := range noShows {
+		total.res.NumNoShows++
+		for _, key := range getKeys(e.UserID, e.SpaceID, e.Enter) {
+			if group, ok := groups[key]; ok {
+				group.res.NumNoShows++
+			}
+		}
+	}
+
+	res := &GetAnalyticsResponse{
+		Total:  router.getAnalyticsGroupResult(total),
+		Groups: []*GetAnalyticsGroupResponse{},
+	}
+	for _, key := range keys {
+		res.Groups = append(res.Groups, router.getAnalyticsGroupResult(groups[key]))
+	}
+	SendJSON(w, res)
+}
+
+// splitAnalyticsInterval splits the interval at full hours.
+func (router *StatsRouter) splitAnalyticsInterval(interval *timeInterval) []*timeInterval {
+	var res []*timeInterval
+	enter := interval.Enter
+	for enter.Before(interval.Leave) {
+		leave := enter.Truncate(time.Hour).Add(time.Hour)
+		if leave.After(interval.Leave) {
+			leave = interval.Leave
+		}
+		res = append(res, &timeInterval{Enter: enter, Leave: leave})
+		enter = leave
+	}
+	return res
+}
+
+// getAnalyticsTimeKey returns the day of week (1 = Monday, 7 = Sunday) or the hour of day
+// of the specified time, depending on the grouping.
+func (router *StatsRouter) getAnalyticsTimeKey(groupBy string, t time.Time) string {
+	if groupBy == AnalyticsGroupByHour {
+		return strconv.Itoa(t.Hour())
+	}
+	weekday := int(t.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	return strconv.Itoa(weekday)
+}
+
+// getAnalyticsGroupResult computes the group's rates and averages. Rates are percentages.
+func (router *StatsRouter) getAnalyticsGroupResult(group *analyticsGroup) *GetAnalyticsGroupResponse {
+	res := group.res
+	for _, interval := range group.intervals {
+		group.bookedMinutes += interval.Leave.Sub(interval.Enter).Minutes()
+	}
+	res.BookedMinutes = int(math.RoundToEven(group.bookedMinutes))
+	if group.capacityMinutes > 0 {
+		res.OccupancyRate = router.roundAnalyticsValue(math.Min(group.bookedMinutes/group.capacityMinutes*100, 100))
+	}
+	res.PeakConcurrency = getPeakConcurrency(group.intervals)
+	if res.NumBookings > 0 {
+		res.AverageDurationMinutes = router.roundAnalyticsValue(group.durationMinutes / float64(res.NumBookings))
+	}
+	if group.numLeadTimes > 0 {
+		res.AverageLeadTimeMinutes = router.roundAnalyticsValue(group.leadTimeMinutes / float64(group.numLeadTimes))
+	}
+	if res.NumBookings+res.NumNoShows > 0 {
+		res.NoShowRate = router.roundAnalyticsValue(float64(res.NumNoShows) / float64(res.NumBookings+res.NumNoShows) * 100)
+	}
+	return res
+}
+
+func (router *StatsRouter) roundAnalyticsValue(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func createTestAnalyticsBookings(t *testing.T, org *Organization, admin, user1, user2 *User) (locationID string) {
+	locationID, spaceID1 := createTestWaitlistSpace(t, org, admin)
+	space2 := &Space{LocationID: locationID, Name: "H235", Type: SpaceTypeDesk, Capacity: 1}
+	if err := testRepositories.Space.Create(space2); err != nil {
+		t.Fatal(err)
+	}
+	bookings := []*Booking{
+		{UserID: user1.ID, SpaceID: spaceID1, Enter: time.Date(2030, 9, 2, 8, 0, 0, 0, time.UTC), Leave: time.Date(2030, 9, 2, 12, 0, 0, 0, time.UTC)},
+		{UserID: user2.ID, SpaceID: space2.ID, Enter: time.Date(2030, 9, 2, 10, 0, 0, 0, time.UTC), Leave: time.Date(2030, 9, 2, 14, 0, 0, 0, time.UTC)},
+		// Outside of the range
+		{UserID: user1.ID, SpaceID: spaceID1, Enter: time.Date(2030, 9, 3, 8, 0, 0, 0, time.UTC), Leave: time.Date(2030, 9, 3, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, e := range bookings {
+		if err := testRepositories.Booking.Create(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	noShow := &NoShow{
+		UserID:    user2.ID,
+		SpaceID:   spaceID1,
+		BookingID: bookings[0].ID,
+		Enter:     time.Date(2030, 9, 2, 14, 0, 0, 0, time.UTC),
+		Leave:     time.Date(2030, 9, 2, 16, 0, 0, 0, time.UTC),
+		Released:  time.Date(2030, 9, 2, 14, 30, 0, 0, time.UTC),
+	}
+	if err := testRepositories.NoShow.Create(noShow); err != nil {
+		t.Fatal(err)
+	}
+	return locationID
+}
+
+func getTestAnalytics(t *testing.T, userID, groupBy string) *GetAnalyticsResponse {
+	payload := `{"start": "2030-09-02T00:00:00Z", "end": "2030-09-03T00:00:00Z", "groupBy": "` + groupBy + `"}`
+	req := newHTTPRequest("POST", "/stats/analytics/", userID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody *GetAnalyticsResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	return resBody
+}
+
+func TestStatsAnalyticsForbidden(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	user := createTestUserInOrg(org)
+
+	payload := `{"start": "2030-09-02T00:00:00Z", "end": "2030-09-03T00:00:00Z", "groupBy": "location"}`
+	req := newHTTPRequest("POST", "/stats/analytics/", user.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+}
+
+func TestStatsAnalyticsInvalidRequest(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+
+	payloads := []string{
+		`{"start": "2030-09-02T00:00:00Z", "end": "2030-09-03T00:00:00Z", "groupBy": "month"}`,
+		`{"start": "2030-09-03T00:00:00Z", "end": "2030-09-02T00:00:00Z", "groupBy": "location"}`,
+		`{"start": "2030-01-01T00:00:00Z", "end": "2031-06-01T00:00:00Z", "groupBy": "location"}`,
+		`{"start": "2030-09-02T00:00:00Z", "end": "2030-09-03T00:00:00Z"}`,
+	}
+	for _, payload := range payloads {
+		req := newHTTPRequest("POST", "/stats/analytics/", admin.ID, bytes.NewBufferString(payload))
+		res := executeTestRequest(req)
+		checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+	}
+}
+
+func TestStatsAnalyticsByLocation(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	user1 := createTestUserInOrg(org)
+	user2 := createTestUserInOrg(org)
+	locationID := createTestAnalyticsBookings(t, org, admin, user1, user2)
+
+	resBody := getTestAnalytics(t, admin.ID, AnalyticsGroupByLocation)
+	checkTestInt(t, 1, len(resBody.Groups))
+	e := resBody.Groups[0]
+	checkTestString(t, locationID, e.Key)
+	checkTestString(t, "Location 1", e.Name)
+	checkTestInt(t, 2, e.NumBookings)
+	checkTestInt(t, 1, e.NumNoShows)
+	checkTestInt(t, 480, e.BookedMinutes)
+	checkTestBool(t, true, e.OccupancyRate == 16.67)
+	checkTestInt(t, 2, e.PeakConcurrency)
+	checkTestBool(t, true, e.AverageDurationMinutes == 240)
+	checkTestBool(t, true, e.AverageLeadTimeMinutes > 0)
+	checkTestBool(t, true, e.NoShowRate == 33.33)
+	checkTestInt(t, 2, resBody.Total.NumBookings)
+	checkTestInt(t, 480, resBody.Total.BookedMinutes)
+}
+
+func TestStatsAnalyticsByHour(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	user1 := createTestUserInOrg(org)
+	user2 := createTestUserInOrg(org)
+	createTestAnalyticsBookings(t, org, admin, user1, user2)
+
+	resBody := getTestAnalytics(t, admin.ID, AnalyticsGroupByHour)
+	checkTestInt(t, 24, len(resBody.Groups))
+	checkTestString(t, "08:00", resBody.Groups[8].Name)
+	checkTestInt(t, 1, resBody.Groups[8].NumBookings)
+	checkTestInt(t, 1, resBody.Groups[8].PeakConcurrency)
+	checkTestBool(t, true, resBody.Groups[8].OccupancyRate == 50)
+	checkTestInt(t, 120, resBody.Groups[10].BookedMinutes)
+	checkTestInt(t, 2, resBody.Groups[10].PeakConcurrency)
+	checkTestBool(t, true, resBody.Groups[10].OccupancyRate == 100)
+	checkTestInt(t, 0, resBody.Groups[13].NumBookings)
+	checkTestInt(t, 60, resBody.Groups[13].BookedMinutes)
+	checkTestBool(t, true, resBody.Groups[14].NoShowRate == 100)
+
+	resBody = getTestAnalytics(t, admin.ID, AnalyticsGroupByWeekday)
+	checkTestInt(t, 7, len(resBody.Groups))
+	checkTestString(t, "1", resBody.Groups[0].Key)
+	checkTestString(t, "Monday", resBody.Groups[0].Name)
+	checkTestInt(t, 2, resBody.Groups[0].NumBookings)
+	checkTestBool(t, true, resBody.Groups[0].OccupancyRate == 16.67)
+	checkTestInt(t, 0, resBody.Groups[1].NumBookings)
+}
+
+func TestStatsAnalyticsByUserGroup(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	user1 := createTestUserInOrg(org)
+	user2 := createTestUserInOrg(org)
+	createTestAnalyticsBookings(t, org, admin, user1, user2)
+	group := &UserGroup{OrganizationID: org.ID, Name: "Engineering"}
+	testRepositories.UserGroup.Create(group)
+	testRepositories.UserGroup.AddMembers(group, []string{user1.ID})
+
+	resBody := getTestAnalytics(t, admin.ID, AnalyticsGroupByUserGroup)
+	checkTestInt(t, 1, len(resBody.Groups))
+	checkTestString(t, group.ID, resBody.Groups[0].Key)
+	checkTestInt(t, 1, resBody.Groups[0].NumBookings)
+	checkTestInt(t, 0, resBody.Groups[0].NumNoShows)
+	checkTestInt(t, 240, resBody.Groups[0].BookedMinutes)
+	checkTestInt(t, 2, resBody.Total.NumBookings)
+}
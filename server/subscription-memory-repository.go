@@ -0,0 +1,47 @@
+package main
+
+import "math"
+
+type MemorySubscriptionRepository struct {
+}
+
+func (r *MemorySubscriptionRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemorySubscriptionRepository) Create(e *SubscriptionEvent) error {
+	if err := memoryCheckUUID(e.OrganizationID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e.ID = memoryNewID()
+	row := *e
+	row.EventTime = memoryTimestamp(e.EventTime)
+	row.ActivationTime = memoryTimestamp(e.ActivationTime)
+	// The price is stored as NUMERIC(8, 2)
+	row.Price = float32(math.Round(float64(e.Price)*100) / 100)
+	memoryData.subscriptionEvents = append(memoryData.subscriptionEvents, &row)
+	return nil
+}
+
+func (r *MemorySubscriptionRepository) GetLatest(organizationID string, maxResults int) ([]*SubscriptionEvent, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	result := memoryCopy(memoryFilter(memoryData.subscriptionEvents, func(e *SubscriptionEvent) bool {
+		return e.OrganizationID == organizationID
+	}))
+	memorySort(result, func(a, b *SubscriptionEvent) bool {
+		return a.EventTime.After(b.EventTime)
+	})
+	return memoryPage(result, maxResults, 0), nil
+}
+
+func (r *MemorySubscriptionRepository) GetProcessedByBrokerEventID(brokerEventID string) (*SubscriptionEvent, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryGet(memoryData.subscriptionEvents, func(e *SubscriptionEvent) bool {
+		return e.Processed && e.BrokerEventID == brokerEventID
+	})
+}
@@ -1,11 +1,15 @@
 package main
 
-import (
-	"sync"
-	"time"
-)
+import "time"
+
+type SubscriptionRepository interface {
+	Repository
+	Create(e *SubscriptionEvent) error
+	GetLatest(organizationID string, maxResults int) ([]*SubscriptionEvent, error)
+	GetProcessedByBrokerEventID(brokerEventID string) (*SubscriptionEvent, error)
+}
 
-type SubscriptionRepository struct {
+type PostgresSubscriptionRepository struct {
 }
 
 type SubscriptionEventType string
@@ -30,45 +34,29 @@ type SubscriptionEvent struct {
 	Processed            bool
 }
 
-var subscriptionRepository *SubscriptionRepository
-var subscriptionRepositoryOnce sync.Once
-
-func GetSubscriptionRepository() *SubscriptionRepository {
-	subscriptionRepositoryOnce.Do(func() {
-		subscriptionRepository = &SubscriptionRepository{}
-		_, err := GetDatabase().DB().Exec("CREATE TABLE IF NOT EXISTS subscription_events (" +
-			"id uuid DEFAULT uuid_generate_v4(), " +
-			"organization_id uuid NOT NULL, " +
-			"event_type VARCHAR NOT NULL, " +
-			"event_time TIMESTAMP NOT NULL, " +
-			"activation_time TIMESTAMP NOT NULL, " +
-			"max_users INT, " +
-			"price NUMERIC(8, 2), " +
-			"broker_subscription_id VARCHAR NOT NULL, " +
-			"broker_customer_id VARCHAR NOT NULL, " +
-			"broker_event_id VARCHAR NOT NULL, " +
-			"processed BOOLEAN, " +
-			"PRIMARY KEY (id))")
-		if err != nil {
-			panic(err)
-		}
-		_, err = GetDatabase().DB().Exec("CREATE INDEX IF NOT EXISTS idx_subscription_events_organization_id ON subscription_events(organization_id)")
-		if err != nil {
-			panic(err)
-		}
-		_, err = GetDatabase().DB().Exec("CREATE INDEX IF NOT EXISTS idx_subscription_events_broker_event_id ON subscription_events(broker_event_id)")
-		if err != nil {
-			panic(err)
-		}
-	})
-	return subscriptionRepository
-}
-
-func (r *SubscriptionRepository) RunSchemaUpgrade(curVersion, targetVersion int) {
-	// No updates yet
+func (r *PostgresSubscriptionRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		newInitialSchemaMigration(
+			"CREATE TABLE IF NOT EXISTS subscription_events ("+
+				"id uuid DEFAULT uuid_generate_v4(), "+
+				"organization_id uuid NOT NULL, "+
+				"event_type VARCHAR NOT NULL, "+
+				"event_time TIMESTAMP NOT NULL, "+
+				"activation_time TIMESTAMP NOT NULL, "+
+				"max_users INT, "+
+				"price NUMERIC(8, 2), "+
+				"broker_subscription_id VARCHAR NOT NULL, "+
+				"broker_customer_id VARCHAR NOT NULL, "+
+				"broker_event_id VARCHAR NOT NULL, "+
+				"processed BOOLEAN, "+
+				"PRIMARY KEY (id))",
+			"CREATE INDEX IF NOT EXISTS idx_subscription_events_organization_id ON subscription_events(organization_id)",
+			"CREATE INDEX IF NOT EXISTS idx_subscription_events_broker_event_id ON subscription_events(broker_event_id)",
+		),
+	}
 }
 
-func (r *SubscriptionRepository) Create(e *SubscriptionEvent) error {
+func (r *PostgresSubscriptionRepository) Create(e *SubscriptionEvent) error {
 	var id string
 	err := GetDatabase().DB().QueryRow("INSERT INTO subscription_events "+
 		"(organization_id, event_type, event_time, activation_time, max_users, price, broker_subscription_id, broker_customer_id, broker_event_id, processed) "+
@@ -82,7 +70,7 @@ func (r *SubscriptionRepository) Create(e *SubscriptionEvent) error {
 	return nil
 }
 
-func (r *SubscriptionRepository) GetLatest(organizationID string, maxResults int) ([]*SubscriptionEvent, error) {
+func (r *PostgresSubscriptionRepository) GetLatest(organizationID string, maxResults int) ([]*SubscriptionEvent, error) {
 	var result []*SubscriptionEvent
 	rows, err := GetDatabase().DB().Query("SELECT id, organization_id, event_type, event_time, activation_time, max_users, price, broker_subscription_id, broker_customer_id, broker_event_id, processed "+
 		"FROM subscription_events "+
@@ -104,7 +92,7 @@ func (r *SubscriptionRepository) GetLatest(organizationID string, maxResults int
 	return result, nil
 }
 
-func (r *SubscriptionRepository) GetProcessedByBrokerEventID(brokerEventID string) (*SubscriptionEvent, error) {
+func (r *PostgresSubscriptionRepository) GetProcessedByBrokerEventID(brokerEventID string) (*SubscriptionEvent, error) {
 	e := &SubscriptionEvent{}
 	err := GetDatabase().DB().QueryRow("SELECT id, organization_id, event_type, event_time, activation_time, max_users, price, broker_subscription_id, broker_customer_id, broker_event_id, processed "+
 		"FROM subscription_events "+
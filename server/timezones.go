@@ -22,11 +22,34 @@ func attachTimezoneInformationTz(timestamp time.Time, tz string) (time.Time, err
 	return targetTimestamp, nil
 }
 
-func attachTimezoneInformation(timestamp time.Time, location *Location) (time.Time, error) {
-	tz := GetLocationRepository().GetTimezone(location)
+func attachTimezoneInformation(repos *Repositories, timestamp time.Time, location *Location) (time.Time, error) {
+	tz := repos.Location.GetTimezone(location)
 	return attachTimezoneInformationTz(timestamp, tz)
 }
 
+// getLocalWallTime is the inverse of attachTimezoneInformation: it returns the
+// location's local wall clock time of the specified timestamp, labeled as UTC.
+func getLocalWallTime(repos *Repositories, timestamp time.Time, location *Location) (time.Time, error) {
+	tz := repos.Location.GetTimezone(location)
+	targetTz, err := time.LoadLocation(tz)
+	if err != nil {
+		return timestamp, err
+	}
+	t := timestamp.In(targetTz)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC), nil
+}
+
+// maxTimezoneOffset is the largest offset of any timezone from UTC.
+const maxTimezoneOffset = 14 * time.Hour
+
+// getWallClockSearchRange returns the range of local wall clock times which the
+// locations can have between the timestamps from and until. It is used to query
+// booking times, which are stored as local wall clock times, independent of the
+// locations' timezones. The results must be filtered by the actual local time.
+func getWallClockSearchRange(from, until time.Time) (time.Time, time.Time) {
+	return from.Add(-maxTimezoneOffset), until.Add(maxTimezoneOffset)
+}
+
 var TimeZones = []string{
 	"Africa/Abidjan",
 	"Africa/Accra",
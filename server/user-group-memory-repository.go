@@ -0,0 +1,223 @@
+package main
+
+type MemoryUserGroupRepository struct {
+	repos *Repositories
+}
+
+type memoryUserGroupMember struct {
+	GroupID string
+	UserID  string
+}
+
+func (r *MemoryUserGroupRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryUserGroupRepository) Create(e *UserGroup) error {
+	if err := memoryCheckUUID(e.OrganizationID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e.ID = memoryNewID()
+	row := *e
+	memoryData.userGroups = append(memoryData.userGroups, &row)
+	return nil
+}
+
+func (r *MemoryUserGroupRepository) GetOne(id string) (*UserGroup, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryGet(memoryData.userGroups, func(e *UserGroup) bool {
+		return e.ID == id
+	})
+}
+
+func (r *MemoryUserGroupRepository) GetByName(organizationID, name string) (*UserGroup, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryGet(memoryData.userGroups, func(e *UserGroup) bool {
+		return e.OrganizationID == organizationID && e.Name == name
+	})
+}
+
+func (r *MemoryUserGroupRepository) GetByExternalID(organizationID, externalID string) (*UserGroup, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryGet(memoryData.userGroups, func(e *UserGroup) bool {
+		return e.OrganizationID == organizationID && e.ExternalID == externalID && e.ExternalID != ""
+	})
+}
+
+func (r *MemoryUserGroupRepository) GetAll(organizationID string, maxResults int, offset int) ([]*UserGroup, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	result := r.getAll(func(e *UserGroup) bool {
+		return e.OrganizationID == organizationID
+	})
+	return memoryPage(result, maxResults, offset), nil
+}
+
+func (r *MemoryUserGroupRepository) GetAllByUser(userID string) ([]*UserGroup, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return r.getAll(func(e *UserGroup) bool {
+		return r.isMember(e.ID, userID)
+	}), nil
+}
+
+// getAll returns copies of the matching groups ordered by name. The caller must hold the lock.
+func (r *MemoryUserGroupRepository) getAll(cond func(*UserGroup) bool) []*UserGroup {
+	result := memoryCopy(memoryFilter(memoryData.userGroups, cond))
+	memorySort(result, func(a, b *UserGroup) bool {
+		return memoryCompare(a.Name, b.Name) < 0
+	})
+	return result
+}
+
+// isMember returns true if the user is a member of the group. The caller must hold the lock.
+func (r *MemoryUserGroupRepository) isMember(groupID, userID string) bool {
+	return memoryCount(memoryData.userGroupMembers, func(m *memoryUserGroupMember) bool {
+		return m.GroupID == groupID && m.UserID == userID
+	}) > 0
+}
+
+func (r *MemoryUserGroupRepository) GetCount(organizationID string) (int, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryCount(memoryData.userGroups, func(e *UserGroup) bool {
+		return e.OrganizationID == organizationID
+	}), nil
+}
+
+func (r *MemoryUserGroupRepository) Update(e *UserGroup) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	row, err := memoryFind(memoryData.userGroups, func(row *UserGroup) bool {
+		return row.ID == e.ID
+	})
+	if err != nil {
+		return nil
+	}
+	row.Name = e.Name
+	row.ExternalID = e.ExternalID
+	return nil
+}
+
+func (r *MemoryUserGroupRepository) Delete(e *UserGroup) error {
+	if err := r.repos.BookingRestriction.DeleteOfGroup(e.ID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.userGroupMembers = memoryDelete(memoryData.userGroupMembers, func(row *memoryUserGroupMember) bool {
+		return row.GroupID == e.ID
+	})
+	memoryData.userGroups = memoryDelete(memoryData.userGroups, func(row *UserGroup) bool {
+		return row.ID == e.ID
+	})
+	return nil
+}
+
+func (r *MemoryUserGroupRepository) DeleteAll(organizationID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	groupIDs := make(map[string]bool)
+	for _, e := range memoryData.userGroups {
+		if e.OrganizationID == organizationID {
+			groupIDs[e.ID] = true
+		}
+	}
+	memoryData.userGroupMembers = memoryDelete(memoryData.userGroupMembers, func(row *memoryUserGroupMember) bool {
+		return groupIDs[row.GroupID]
+	})
+	memoryData.userGroups = memoryDelete(memoryData.userGroups, func(row *UserGroup) bool {
+		return groupIDs[row.ID]
+	})
+	return nil
+}
+
+func (r *MemoryUserGroupRepository) GetMemberIDs(e *UserGroup) ([]string, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	var result []string
+	for _, m := range memoryData.userGroupMembers {
+		if m.GroupID == e.ID {
+			result = append(result, m.UserID)
+		}
+	}
+	return result, nil
+}
+
+func (r *MemoryUserGroupRepository) GetMembers(e *UserGroup) ([]*User, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	var result []*User
+	for _, m := range memoryData.userGroupMembers {
+		if m.GroupID != e.ID {
+			continue
+		}
+		if user := memoryUserByID(m.UserID); user != nil {
+			result = append(result, memoryCopyUser(user))
+		}
+	}
+	memorySort(result, func(a, b *User) bool {
+		return memoryCompare(a.Email, b.Email) < 0
+	})
+	return result, nil
+}
+
+func (r *MemoryUserGroupRepository) AddMembers(e *UserGroup, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	if err := memoryCheckUUID(userIDs...); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	for _, user := range memoryData.users {
+		if user.OrganizationID != e.OrganizationID || !memoryContainsID(userIDs, user.ID) || r.isMember(e.ID, user.ID) {
+			continue
+		}
+		memoryData.userGroupMembers = append(memoryData.userGroupMembers, &memoryUserGroupMember{
+			GroupID: e.ID,
+			UserID:  user.ID,
+		})
+	}
+	return nil
+}
+
+func (r *MemoryUserGroupRepository) RemoveMembers(e *UserGroup, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	if err := memoryCheckUUID(userIDs...); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.userGroupMembers = memoryDelete(memoryData.userGroupMembers, func(row *memoryUserGroupMember) bool {
+		return row.GroupID == e.ID && memoryContainsID(userIDs, row.UserID)
+	})
+	return nil
+}
+
+func (r *MemoryUserGroupRepository) RemoveAllMembers(e *UserGroup) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.userGroupMembers = memoryDelete(memoryData.userGroupMembers, func(row *memoryUserGroupMember) bool {
+		return row.GroupID == e.ID
+	})
+	return nil
+}
+
+func (r *MemoryUserGroupRepository) DeleteOfUser(u *User) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.userGroupMembers = memoryDelete(memoryData.userGroupMembers, func(row *memoryUserGroupMember) bool {
+		return row.UserID == u.ID
+	})
+	return nil
+}
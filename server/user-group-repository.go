@@ -0,0 +1,271 @@
+package main
+
+import "github.com/lib/pq"
+
+type UserGroupRepository interface {
+	Repository
+	Create(e *UserGroup) error
+	GetOne(id string) (*UserGroup, error)
+	GetByName(organizationID, name string) (*UserGroup, error)
+	GetByExternalID(organizationID, externalID string) (*UserGroup, error)
+	GetAll(organizationID string, maxResults int, offset int) ([]*UserGroup, error)
+	GetAllByUser(userID string) ([]*UserGroup, error)
+	GetCount(organizationID string) (int, error)
+	Update(e *UserGroup) error
+	Delete(e *UserGroup) error
+	DeleteAll(organizationID string) error
+	GetMemberIDs(e *UserGroup) ([]string, error)
+	GetMembers(e *UserGroup) ([]*User, error)
+	AddMembers(e *UserGroup, userIDs []string) error
+	RemoveMembers(e *UserGroup, userIDs []string) error
+	RemoveAllMembers(e *UserGroup) error
+	DeleteOfUser(u *User) error
+}
+
+type PostgresUserGroupRepository struct {
+	repos *Repositories
+}
+
+// UserGroup is a named set of users within an organization. Groups can be
+// maintained by admins or provisioned by an identity provider via SCIM, in
+// which case ExternalID holds the identifier assigned by the provider.
+type UserGroup struct {
+	ID             string
+	OrganizationID string
+	Name           string
+	ExternalID     string
+}
+
+func (r *PostgresUserGroupRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		{
+			Version:     28,
+			Description: "Add user groups",
+			Up: []string{
+				"CREATE TABLE IF NOT EXISTS user_groups (" +
+					"id uuid DEFAULT uuid_generate_v4(), " +
+					"organization_id uuid NOT NULL, " +
+					"name VARCHAR NOT NULL, " +
+					"external_id VARCHAR NOT NULL DEFAULT '', " +
+					"PRIMARY KEY (id))",
+				"CREATE INDEX IF NOT EXISTS idx_user_groups_organization_id ON user_groups(organization_id)",
+				"CREATE TABLE IF NOT EXISTS user_groups_members (" +
+					"group_id uuid NOT NULL, " +
+					"user_id uuid NOT NULL, " +
+					"PRIMARY KEY (group_id, user_id))",
+				"CREATE INDEX IF NOT EXISTS idx_user_groups_members_user_id ON user_groups_members(user_id)",
+			},
+			Down: []string{
+				"DROP TABLE IF EXISTS user_groups_members",
+				"DROP TABLE IF EXISTS user_groups",
+			},
+		},
+	}
+}
+
+func (r *PostgresUserGroupRepository) Create(e *UserGroup) error {
+	var id string
+	err := GetDatabase().DB().QueryRow("INSERT INTO user_groups "+
+		"(organization_id, name, external_id) "+
+		"VALUES ($1, $2, $3) "+
+		"RETURNING id",
+		e.OrganizationID, e.Name, e.ExternalID).Scan(&id)
+	if err != nil {
+		return err
+	}
+	e.ID = id
+	return nil
+}
+
+func (r *PostgresUserGroupRepository) GetOne(id string) (*UserGroup, error) {
+	e := &UserGroup{}
+	err := GetDatabase().DB().QueryRow("SELECT id, organization_id, name, external_id "+
+		"FROM user_groups "+
+		"WHERE id = $1",
+		id).Scan(&e.ID, &e.OrganizationID, &e.Name, &e.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (r *PostgresUserGroupRepository) GetByName(organizationID, name string) (*UserGroup, error) {
+	e := &UserGroup{}
+	err := GetDatabase().DB().QueryRow("SELECT id, organization_id, name, external_id "+
+		"FROM user_groups "+
+		"WHERE organization_id = $1 AND name = $2",
+		organizationID, name).Scan(&e.ID, &e.OrganizationID, &e.Name, &e.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (r *PostgresUserGroupRepository) GetByExternalID(organizationID, externalID string) (*UserGroup, error) {
+	e := &UserGroup{}
+	err := GetDatabase().DB().QueryRow("SELECT id, organization_id, name, external_id "+
+		"FROM user_groups "+
+		"WHERE organization_id = $1 AND external_id = $2 AND external_id != ''",
+		organizationID, externalID).Scan(&e.ID, &e.OrganizationID, &e.Name, &e.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (r *PostgresUserGroupRepository) GetAll(organizationID string, maxResults int, offset int) ([]*UserGroup, error) {
+	var result []*UserGroup
+	rows, err := GetDatabase().DB().Query("SELECT id, organization_id, name, external_id "+
+		"FROM user_groups "+
+		"WHERE organization_id = $1 "+
+		"ORDER BY name "+
+		"LIMIT $2 OFFSET $3", organizationID, maxResults, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &UserGroup{}
+		err = rows.Scan(&e.ID, &e.OrganizationID, &e.Name, &e.ExternalID)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (r *PostgresUserGroupRepository) GetAllByUser(userID string) ([]*UserGroup, error) {
+	var result []*UserGroup
+	rows, err := GetDatabase().DB().Query("SELECT user_groups.id, user_groups.organization_id, user_groups.name, user_groups.external_id "+
+		"FROM user_groups "+
+		"INNER JOIN user_groups_members ON user_groups_members.group_id = user_groups.id "+
+		"WHERE user_groups_members.user_id = $1 "+
+		"ORDER BY user_groups.name", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &UserGroup{}
+		err = rows.Scan(&e.ID, &e.OrganizationID, &e.Name, &e.ExternalID)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (r *PostgresUserGroupRepository) GetCount(organizationID string) (int, error) {
+	var res int
+	err := GetDatabase().DB().QueryRow("SELECT COUNT(id) "+
+		"FROM user_groups "+
+		"WHERE organization_id = $1",
+		organizationID).Scan(&res)
+	return res, err
+}
+
+func (r *PostgresUserGroupRepository) Update(e *UserGroup) error {
+	_, err := GetDatabase().DB().Exec("UPDATE user_groups SET "+
+		"name = $1, "+
+		"external_id = $2 "+
+		"WHERE id = $3",
+		e.Name, e.ExternalID, e.ID)
+	return err
+}
+
+func (r *PostgresUserGroupRepository) Delete(e *UserGroup) error {
+	if err := r.repos.BookingRestriction.DeleteOfGroup(e.ID); err != nil {
+		return err
+	}
+	if _, err := GetDatabase().DB().Exec("DELETE FROM user_groups_members WHERE group_id = $1", e.ID); err != nil {
+		return err
+	}
+	_, err := GetDatabase().DB().Exec("DELETE FROM user_groups WHERE id = $1", e.ID)
+	return err
+}
+
+func (r *PostgresUserGroupRepository) DeleteAll(organizationID string) error {
+	if _, err := GetDatabase().DB().Exec("DELETE FROM user_groups_members WHERE group_id IN "+
+		"(SELECT id FROM user_groups WHERE organization_id = $1)", organizationID); err != nil {
+		return err
+	}
+	_, err := GetDatabase().DB().Exec("DELETE FROM user_groups WHERE organization_id = $1", organizationID)
+	return err
+}
+
+func (r *PostgresUserGroupRepository) GetMemberIDs(e *UserGroup) ([]string, error) {
+	var result []string
+	rows, err := GetDatabase().DB().Query("SELECT user_id "+
+		"FROM user_groups_members "+
+		"WHERE group_id = $1", e.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID string
+		err = rows.Scan(&userID)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, userID)
+	}
+	return result, nil
+}
+
+func (r *PostgresUserGroupRepository) GetMembers(e *UserGroup) ([]*User, error) {
+	var result []*User
+	rows, err := GetDatabase().DB().Query("SELECT users.id, users.organization_id, users.email, users.role, users.password, users.auth_provider_id, users.atlassian_id, users.disabled, users.ban_expiry, users.display_name "+
+		"FROM users "+
+		"INNER JOIN user_groups_members ON user_groups_members.user_id = users.id "+
+		"WHERE user_groups_members.group_id = $1 "+
+		"ORDER BY users.email", e.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		u := &User{}
+		err = rows.Scan(&u.ID, &u.OrganizationID, &u.Email, &u.Role, &u.HashedPassword, &u.AuthProviderID, &u.AtlassianID, &u.Disabled, &u.BanExpiry, &u.DisplayName)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, u)
+	}
+	return result, nil
+}
+
+// AddMembers adds the users to the group. Users that are already members or
+// that don't belong to the group's organization are skipped.
+func (r *PostgresUserGroupRepository) AddMembers(e *UserGroup, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	_, err := GetDatabase().DB().Exec("INSERT INTO user_groups_members (group_id, user_id) "+
+		"SELECT $1, id FROM users WHERE organization_id = $2 AND id = ANY($3) "+
+		"ON CONFLICT (group_id, user_id) DO NOTHING",
+		e.ID, e.OrganizationID, pq.Array(userIDs))
+	return err
+}
+
+func (r *PostgresUserGroupRepository) RemoveMembers(e *UserGroup, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	_, err := GetDatabase().DB().Exec("DELETE FROM user_groups_members "+
+		"WHERE group_id = $1 AND user_id = ANY($2)",
+		e.ID, pq.Array(userIDs))
+	return err
+}
+
+func (r *PostgresUserGroupRepository) RemoveAllMembers(e *UserGroup) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM user_groups_members WHERE group_id = $1", e.ID)
+	return err
+}
+
+func (r *PostgresUserGroupRepository) DeleteOfUser(u *User) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM user_groups_members WHERE user_id = $1", u.ID)
+	return err
+}
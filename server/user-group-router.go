@@ -0,0 +1,213 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type UserGroupRouter struct {
+	repos *Repositories
+}
+
+type CreateUserGroupRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type GetUserGroupResponse struct {
+	ID         string `json:"id"`
+	ExternalID string `json:"externalId"`
+	CreateUserGroupRequest
+}
+
+type SetUserGroupMembersRequest struct {
+	UserIDs []string `json:"userIds"`
+}
+
+type GetUserGroupMemberResponse struct {
+	UserID string `json:"userId"`
+	Email  string `json:"email"`
+}
+
+func (router *UserGroupRouter) setupRoutes(s *mux.Router) {
+	s.HandleFunc("/{id}/member", router.getMembers).Methods("GET")
+	s.HandleFunc("/{id}/member", router.setMembers).Methods("PUT")
+	s.HandleFunc("/{id}", router.getOne).Methods("GET")
+	s.HandleFunc("/{id}", router.update).Methods("PUT")
+	s.HandleFunc("/{id}", router.delete).Methods("DELETE")
+	s.HandleFunc("/", router.create).Methods("POST")
+	s.HandleFunc("/", router.getAll).Methods("GET")
+}
+
+func (router *UserGroupRouter) getOne(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	e, err := router.repos.UserGroup.GetOne(vars["id"])
+	if err != nil {
+		SendNotFound(w)
+		return
+	}
+	if !CanSpaceAdminOrg(GetRequestUser(router.repos, r), e.OrganizationID) {
+		SendForbidden(w)
+		return
+	}
+	res := router.copyToRestModel(e)
+	SendJSON(w, res)
+}
+
+func (router *UserGroupRouter) getAll(w http.ResponseWriter, r *http.Request) {
+	user := GetRequestUser(router.repos, r)
+	if !CanSpaceAdminOrg(user, user.OrganizationID) {
+		SendForbidden(w)
+		return
+	}
+	list, err := router.repos.UserGroup.GetAll(user.OrganizationID, 1000, 0)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	res := []*GetUserGroupResponse{}
+	for _, e := range list {
+		m := router.copyToRestModel(e)
+		res = append(res, m)
+	}
+	SendJSON(w, res)
+}
+
+func (router *UserGroupRouter) create(w http.ResponseWriter, r *http.Request) {
+	user := GetRequestUser(router.repos, r)
+	if !CanAdminOrg(user, user.OrganizationID) {
+		SendForbidden(w)
+		return
+	}
+	var m CreateUserGroupRequest
+	if UnmarshalValidateBody(r, &m) != nil {
+		SendBadRequest(w)
+		return
+	}
+	if _, err := router.repos.UserGroup.GetByName(user.OrganizationID, m.Name); err == nil {
+		SendAleadyExists(w)
+		return
+	}
+	e := &UserGroup{
+		OrganizationID: user.OrganizationID,
+		Name:           m.Name,
+	}
+	if err := router.repos.UserGroup.Create(e); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	SendCreated(w, e.ID)
+}
+
+func (router *UserGroupRouter) update(w http.ResponseWriter, r *http.Request) {
+	e, ok := router.getUserGroup(w, r)
+	if !ok {
+		return
+	}
+	var m CreateUserGroupRequest
+	if UnmarshalValidateBody(r, &m) != nil {
+		SendBadRequest(w)
+		return
+	}
+	if existing, err := router.repos.UserGroup.GetByName(e.OrganizationID, m.Name); err == nil && existing.ID != e.ID {
+		SendAleadyExists(w)
+		return
+	}
+	e.Name = m.Name
+	if err := router.repos.UserGroup.Update(e); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	SendUpdated(w)
+}
+
+func (router *UserGroupRouter) delete(w http.ResponseWriter, r *http.Request) {
+	e, ok := router.getUserGroup(w, r)
+	if !ok {
+		return
+	}
+	if err := router.repos.UserGroup.Delete(e); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	SendUpdated(w)
+}
+
+func (router *UserGroupRouter) getMembers(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	e, err := router.repos.UserGroup.GetOne(vars["id"])
+	if err != nil {
+		SendNotFound(w)
+		return
+	}
+	if !CanSpaceAdminOrg(GetRequestUser(router.repos, r), e.OrganizationID) {
+		SendForbidden(w)
+		return
+	}
+	list, err := router.repos.UserGroup.GetMembers(e)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	res := []*GetUserGroupMemberResponse{}
+	for _, member := range list {
+		res = append(res, &GetUserGroupMemberResponse{
+			UserID: member.ID,
+			Email:  member.Email,
+		})
+	}
+	SendJSON(w, res)
+}
+
+// setMembers replaces the group's members. Users not belonging to the group's
+// organization are ignored.
+func (router *UserGroupRouter) setMembers(w http.ResponseWriter, r *http.Request) {
+	e, ok := router.getUserGroup(w, r)
+	if !ok {
+		return
+	}
+	var m SetUserGroupMembersRequest
+	if UnmarshalValidateBody(r, &m) != nil {
+		SendBadRequest(w)
+		return
+	}
+	if err := router.repos.UserGroup.RemoveAllMembers(e); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	if err := router.repos.UserGroup.AddMembers(e, m.UserIDs); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	SendUpdated(w)
+}
+
+func (router *UserGroupRouter) getUserGroup(w http.ResponseWriter, r *http.Request) (*UserGroup, bool) {
+	vars := mux.Vars(r)
+	e, err := router.repos.UserGroup.GetOne(vars["id"])
+	if err != nil {
+		SendNotFound(w)
+		return nil, false
+	}
+	if !CanAdminOrg(GetRequestUser(router.repos, r), e.OrganizationID) {
+		SendForbidden(w)
+		return nil, false
+	}
+	return e, true
+}
+
+func (router *UserGroupRouter) copyToRestModel(e *UserGroup) *GetUserGroupResponse {
+	m := &GetUserGroupResponse{}
+	m.ID = e.ID
+	m.ExternalID = e.ExternalID
+	m.Name = e.Name
+	return m
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestUserGroupsCRUD(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	user1 := createTestUserInOrg(org)
+	user2 := createTestUserInOrg(org)
+	foreignUser := createTestUser("foo.com")
+
+	payload := `{"name": "First Aid"}`
+	req := newHTTPRequest("POST", "/user-group/", admin.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	id := res.Header().Get("X-Object-Id")
+
+	req = newHTTPRequest("POST", "/user-group/", admin.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusConflict, res.Code)
+
+	payload = `{"name": "First Aid Rota"}`
+	req = newHTTPRequest("PUT", "/user-group/"+id, admin.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	req = newHTTPRequest("GET", "/user-group/", admin.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resList []*GetUserGroupResponse
+	json.Unmarshal(res.Body.Bytes(), &resList)
+	checkTestInt(t, 1, len(resList))
+	checkTestString(t, id, resList[0].ID)
+	checkTestString(t, "First Aid Rota", resList[0].Name)
+
+	payload = `{"userIds": ["` + user1.ID + `", "` + user2.ID + `", "` + foreignUser.ID + `"]}`
+	req = newHTTPRequest("PUT", "/user-group/"+id+"/member", admin.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	req = newHTTPRequest("GET", "/user-group/"+id+"/member", admin.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resMembers []*GetUserGroupMemberResponse
+	json.Unmarshal(res.Body.Bytes(), &resMembers)
+	checkTestInt(t, 2, len(resMembers))
+
+	payload = `{"userIds": ["` + user2.ID + `"]}`
+	req = newHTTPRequest("PUT", "/user-group/"+id+"/member", admin.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+	group, _ := testRepositories.UserGroup.GetOne(id)
+	memberIDs, _ := testRepositories.UserGroup.GetMemberIDs(group)
+	checkTestInt(t, 1, len(memberIDs))
+	checkTestString(t, user2.ID, memberIDs[0])
+
+	req = newHTTPRequest("DELETE", "/user-group/"+id, admin.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	req = newHTTPRequest("GET", "/user-group/"+id, admin.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNotFound, res.Code)
+}
+
+func TestUserGroupsForbidden(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	user := createTestUserInOrg(org)
+	org2 := createTestOrg("foo.com")
+	admin2 := createTestUserOrgAdmin(org2)
+
+	payload := `{"name": "First Aid"}`
+	req := newHTTPRequest("POST", "/user-group/", user.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+
+	req = newHTTPRequest("POST", "/user-group/", admin.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	id := res.Header().Get("X-Object-Id")
+
+	req = newHTTPRequest("GET", "/user-group/", user.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+
+	req = newHTTPRequest("GET", "/user-group/"+id, admin2.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+
+	req = newHTTPRequest("DELETE", "/user-group/"+id, admin2.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type UserImportRouter struct {
+	repos *Repositories
+}
+
+type ImportUsersRowResponse struct {
+	Row     int    `json:"row"`
+	Email   string `json:"email"`
+	UserID  string `json:"userId,omitempty"`
+	Invited bool   `json:"invited"`
+	Error   string `json:"error,omitempty"`
+}
+
+type ImportUsersResponse struct {
+	DryRun     bool                      `json:"dryRun"`
+	NumCreated int                       `json:"numCreated"`
+	NumErrors  int                       `json:"numErrors"`
+	Rows       []*ImportUsersRowResponse `json:"rows"`
+}
+
+// importUserRow is a parsed row of the CSV file.
+type importUserRow struct {
+	res          *ImportUsersRowResponse
+	role         UserRole
+	authProvider *AuthProvider
+	password     string
+	invite       bool
+}
+
+const (
+	ImportUsersColumnEmail        string = "email"
+	ImportUsersColumnRole         string = "role"
+	ImportUsersColumnAuthProvider string = "authprovider"
+	ImportUsersColumnPassword     string = "password"
+	ImportUsersColumnInvite       string = "invite"
+)
+
+const (
+	ImportUsersErrorInvalidEmail         string = "invalid_email"
+	ImportUsersErrorDuplicateEmail       string = "duplicate_email"
+	ImportUsersErrorEmailExists          string = "email_exists"
+	ImportUsersErrorInvalidRole          string = "invalid_role"
+	ImportUsersErrorAuthProviderNotFound string = "auth_provider_not_found"
+	ImportUsersErrorPasswordNotAllowed   string = "password_not_allowed"
+	ImportUsersErrorInvalidInvite        string = "invalid_invite"
+	ImportUsersErrorMaxUsersExceeded     string = "max_users_exceeded"
+	ImportUsersErrorInternal             string = "internal_error"
+)
+
+const ImportUsersMaxRows int = 10000
+const ImportUsersMaxBodySize int64 = 10 << 20
+
+// UserInviteExpiry is the time an invited user has to set a password.
+const UserInviteExpiry time.Duration = 7 * 24 * time.Hour
+
+func (router *UserImportRouter) setupRoutes(s *mux.Router) {
+	s.HandleFunc("/", router.importUsers).Methods("POST")
+}
+
+// importUsers creates the users of the CSV file in the request body. The first line
+// names the columns: email is required, role (user, spaceadmin, orgadmin), authProvider
+// (ID or name), password and invite (true or false) are optional. Unless the "mode"
+// query parameter is "apply", the rows are validated only. Invalid rows are reported
+// and skipped, while the valid rows are created.
+func (router *UserImportRouter) importUsers(w http.ResponseWriter, r *http.Request) {
+	user := GetRequestUser(router.repos, r)
+	if !CanAdminOrg(user, user.OrganizationID) {
+		SendForbidden(w)
+		return
+	}
+	mode := r.URL.Query().Get("mode")
+	if mode != "" && mode != "dry-run" && mode != "apply" {
+		SendBadRequest(w)
+		return
+	}
+	org, err := router.repos.Organization.GetOne(user.OrganizationID)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	authProviders, err := router.repos.AuthProvider.GetAll(org.ID)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	rows, err := router.parseCSV(http.MaxBytesReader(w, r.Body, ImportUsersMaxBodySize), user, org, authProviders)
+	if err != nil {
+		SendBadRequest(w)
+		return
+	}
+	maxUsers, _ := router.repos.Settings.GetInt(org.ID, SettingSubscriptionMaxUsers.Name)
+	curUsers, _ := router.repos.User.GetCount(org.ID)
+	res := &ImportUsersResponse{
+		DryRun: mode != "apply",
+		Rows:   []*ImportUsersRowResponse{},
+	}
+	for _, row := range rows {
+		res.Rows = append(res.Rows, row.res)
+		if row.res.Error == "" && curUsers >= maxUsers {
+			row.res.Error = ImportUsersErrorMaxUsersExceeded
+		}
+		if row.res.Error != "" {
+			res.NumErrors++
+			continue
+		}
+		curUsers++
+		res.NumCreated++
+		row.res.Invited = row.invite
+		if res.DryRun {
+			continue
+		}
+		if err := router.createUser(r, org, row); err != nil {
+			log.Println(err)
+			row.res.Error = ImportUsersErrorInternal
+			row.res.Invited = false
+			res.NumCreated--
+			res.NumErrors++
+		}
+	}
+	SendJSON(w, res)
+}
+
+// parseCSV returns the validated rows. An error is returned if the file can't be parsed.
+func (router *UserImportRouter) parseCSV(body io.Reader, user *User, org *Organization, authProviders []*AuthProvider) ([]*importUserRow, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		// Spreadsheet applications may prepend a byte order mark
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if name != ImportUsersColumnEmail && name != ImportUsersColumnRole && name != ImportUsersColumnAuthProvider && name != ImportUsersColumnPassword && name != ImportUsersColumnInvite {
+			return nil, errors.New("unknown column: " + name)
+		}
+		if _, ok := columns[name]; ok {
+			return nil, errors.New("duplicate column: " + name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns[ImportUsersColumnEmail]; !ok {
+		return nil, errors.New("missing column: " + ImportUsersColumnEmail)
+	}
+	getValue := func(record []string, name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	var res []*importUserRow
+	emails := make(map[string]bool)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(res) >= ImportUsersMaxRows {
+			return nil, errors.New("too many rows")
+		}
+		row := &importUserRow{
+			res: &ImportUsersRowResponse{
+				Row:   len(res) + 1,
+				Email: getValue(record, ImportUsersColumnEmail),
+			},
+			password: getValue(record, ImportUsersColumnPassword),
+		}
+		res = append(res, row)
+		row.res.Error = router.validateRow(row, record, getValue, user, org, authProviders, emails)
+		emails[strings.ToLower(row.res.Email)] = true
+	}
+	return res, nil
+}
+
+func (router *UserImportRouter) validateRow(row *importUserRow, record []string, getValue func([]string, string) string, user *User, org *Organization, authProviders []*AuthProvider, emails map[string]bool) string {
+	email := row.res.Email
+	if !router.repos.Organization.isValidEmailForOrg(email, org) {
+		return ImportUsersErrorInvalidEmail
+	}
+	if emails[strings.ToLower(email)] {
+		return ImportUsersErrorDuplicateEmail
+	}
+	if existing, _ := router.repos.User.GetByEmail(email); existing != nil {
+		return ImportUsersErrorEmailExists
+	}
+	switch strings.ToLower(getValue(record, ImportUsersColumnRole)) {
+	case "", "user":
+		row.role = UserRoleUser
+	case "spaceadmin":
+		row.role = UserRoleSpaceAdmin
+	case "orgadmin":
+		row.role = UserRoleOrgAdmin
+	default:
+		return ImportUsersErrorInvalidRole
+	}
+	if row.role > user.Role {
+		return ImportUsersErrorInvalidRole
+	}
+	if authProvider := getValue(record, ImportUsersColumnAuthProvider); authProvider != "" {
+		for _, e := range authProviders {
+			if e.ID == authProvider || strings.EqualFold(e.Name, authProvider) {
+				row.authProvider = e
+				break
+			}
+		}
+		if row.authProvider == nil {
+			return ImportUsersErrorAuthProviderNotFound
+		}
+		if row.password != "" {
+			return ImportUsersErrorPasswordNotAllowed
+		}
+	}
+	if invite := getValue(record, ImportUsersColumnInvite); invite != "" {
+		var err error
+		if row.invite, err = strconv.ParseBool(invite); err != nil {
+			return ImportUsersErrorInvalidInvite
+		}
+	}
+	return ""
+}
+
+// createUser creates the row's user. Invited users without auth provider and password
+// get a random password, which they replace using the link in the invite email.
+func (router *UserImportRouter) createUser(r *http.Request, org *Organization, row *importUserRow) error {
+	e := &User{
+		OrganizationID: org.ID,
+		Email:          row.res.Email,
+		Role:           row.role,
+	}
+	setPasswordLink := row.invite && row.authProvider == nil && row.password == ""
+	if setPasswordLink {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		row.password = hex.EncodeToString(b)
+	}
+	if row.password != "" {
+		e.HashedPassword = NullString(router.repos.User.GetHashedPassword(row.password))
+	}
+	if row.authProvider != nil {
+		e.AuthProviderID = NullString(row.authProvider.ID)
+	}
+	if err := router.repos.User.Create(e); err != nil {
+		return err
+	}
+	row.res.UserID = e.ID
+	userRouter := &UserRouter{repos: router.repos}
+	recordAuditEvent(router.repos, r, e.OrganizationID, AuditActionUserCreated, AuditTargetUser, e.ID, nil, userRouter.copyToRestModel(e, true))
+	fireWebhookEvent(router.repos, e.OrganizationID, WebhookEventUserCreated, userRouter.copyToRestModel(e, true))
+	if !row.invite {
+		return nil
+	}
+	inviteURL := GetConfig().FrontendURL + "ui/login"
+	if setPasswordLink {
+		authState := &AuthState{
+			AuthProviderID: router.repos.Settings.getNullUUID(),
+			Expiry:         time.Now().Add(UserInviteExpiry),
+			AuthStateType:  AuthResetPasswordRequest,
+			Payload:        e.ID,
+		}
+		if err := router.repos.AuthState.Create(authState); err != nil {
+			return err
+		}
+		inviteURL = GetConfig().FrontendURL + "ui/resetpw/" + authState.ID
+	}
+	if err := router.sendInviteEmail(e, org, inviteURL); err != nil {
+		// The user has been created, so the row is reported as successful
+		log.Println(err)
+		row.res.Invited = false
+	}
+	return nil
+}
+
+func (router *UserImportRouter) sendInviteEmail(user *User, org *Organization, inviteURL string) error {
+	vars := map[string]string{
+		"recipientName":  user.Email,
+		"recipientEmail": user.Email,
+		"orgName":        org.Name,
+		"inviteUrl":      inviteURL,
+	}
+	return sendEmail(user.Email, GetConfig().SMTPSenderAddress, EmailTemplateInvite, org.Language, vars)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func importTestUsers(t *testing.T, userID, mode, payload string) *ImportUsersResponse {
+	req := newHTTPRequest("POST", "/user/import/?mode="+mode, userID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody *ImportUsersResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	return resBody
+}
+
+func TestUserImportForbidden(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	user := createTestUserInOrgWithName(org, "admin@test.com", UserRoleSpaceAdmin)
+
+	req := newHTTPRequest("POST", "/user/import/", user.ID, bytes.NewBufferString("email\nu1@test.com\n"))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+}
+
+func TestUserImportInvalidFile(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+
+	payloads := []string{
+		"",
+		"role\nuser\n",
+		"email,phone\nu1@test.com,123\n",
+		"email,email\nu1@test.com,u1@test.com\n",
+		"email,role\nu1@test.com\n",
+	}
+	for _, payload := range payloads {
+		req := newHTTPRequest("POST", "/user/import/", admin.ID, bytes.NewBufferString(payload))
+		res := executeTestRequest(req)
+		checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+	}
+
+	req := newHTTPRequest("POST", "/user/import/?mode=force", admin.ID, bytes.NewBufferString("email\nu1@test.com\n"))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+}
+
+func TestUserImportDryRun(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	createTestUserInOrgWithName(org, "existing@test.com", UserRoleUser)
+	provider := &AuthProvider{OrganizationID: org.ID, Name: "Corporate SSO"}
+	testRepositories.AuthProvider.Create(provider)
+	numUsers, _ := testRepositories.User.GetCount(org.ID)
+
+	payload := "\ufeffEmail,Role,AuthProvider,Password,Invite\n" +
+		"u1@test.com,user,,secret123,\n" +
+		"u2@test.com,SpaceAdmin,corporate sso,,true\n" +
+		"u3@other.com,user,,,\n" +
+		"U1@test.com,user,,,\n" +
+		"existing@test.com,user,,,\n" +
+		"u4@test.com,superadmin,,,\n" +
+		"u5@test.com,user,Unknown,,\n" +
+		"u6@test.com,user," + provider.ID + ",secret123,\n" +
+		"u7@test.com,user,,,maybe\n"
+	resBody := importTestUsers(t, admin.ID, "dry-run", payload)
+	checkTestBool(t, true, resBody.DryRun)
+	checkTestInt(t, 2, resBody.NumCreated)
+	checkTestInt(t, 7, resBody.NumErrors)
+	checkTestInt(t, 9, len(resBody.Rows))
+	expected := []string{
+		"",
+		"",
+		ImportUsersErrorInvalidEmail,
+		ImportUsersErrorDuplicateEmail,
+		ImportUsersErrorEmailExists,
+		ImportUsersErrorInvalidRole,
+		ImportUsersErrorAuthProviderNotFound,
+		ImportUsersErrorPasswordNotAllowed,
+		ImportUsersErrorInvalidInvite,
+	}
+	for i, e := range resBody.Rows {
+		checkTestInt(t, i+1, e.Row)
+		checkTestString(t, expected[i], e.Error)
+		checkTestString(t, "", e.UserID)
+	}
+	checkTestBool(t, true, resBody.Rows[1].Invited)
+
+	count, _ := testRepositories.User.GetCount(org.ID)
+	checkTestInt(t, numUsers, count)
+}
+
+func TestUserImportApply(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	provider := &AuthProvider{OrganizationID: org.ID, Name: "Corporate SSO"}
+	testRepositories.AuthProvider.Create(provider)
+	SendMailMockContent = ""
+
+	payload := "email,role,authProvider,password,invite\n" +
+		"u1@test.com,spaceadmin,,secret123,false\n" +
+		"u2@test.com,user,Corporate SSO,,\n" +
+		"u3@test.com,,,,false\n" +
+		"u4@test.com,,,,1\n"
+	resBody := importTestUsers(t, admin.ID, "apply", payload)
+	checkTestBool(t, false, resBody.DryRun)
+	checkTestInt(t, 4, resBody.NumCreated)
+	checkTestInt(t, 0, resBody.NumErrors)
+
+	user1, _ := testRepositories.User.GetByEmail("u1@test.com")
+	checkTestString(t, resBody.Rows[0].UserID, user1.ID)
+	checkTestInt(t, int(UserRoleSpaceAdmin), int(user1.Role))
+	checkTestBool(t, true, testRepositories.User.CheckPassword(string(user1.HashedPassword), "secret123"))
+	user2, _ := testRepositories.User.GetByEmail("u2@test.com")
+	checkTestString(t, provider.ID, string(user2.AuthProviderID))
+	checkTestBool(t, true, user2.HashedPassword == "")
+
+	// Invited password users get a link to set their password
+	user4, _ := testRepositories.User.GetByEmail("u4@test.com")
+	checkTestBool(t, true, resBody.Rows[3].Invited)
+	checkTestBool(t, true, user4.HashedPassword != "")
+	checkTestBool(t, true, strings.Contains(SendMailMockContent, "To: u4@test.com"))
+	checkTestBool(t, true, strings.Contains(SendMailMockContent, "Ihre Organisation Test Org hat Sie eingeladen"))
+	checkTestBool(t, true, strings.Contains(SendMailMockContent, "ui/resetpw/"))
+
+	resBody = importTestUsers(t, admin.ID, "apply", payload)
+	checkTestInt(t, 0, resBody.NumCreated)
+	checkTestString(t, ImportUsersErrorEmailExists, resBody.Rows[0].Error)
+}
+
+func TestUserImportMaxUsers(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	numUsers, _ := testRepositories.User.GetCount(org.ID)
+	testRepositories.Settings.Set(org.ID, SettingSubscriptionMaxUsers.Name, strconv.Itoa(numUsers+1))
+
+	resBody := importTestUsers(t, admin.ID, "apply", "email\nu1@other.com\nu2@test.com\nu3@test.com\n")
+	checkTestInt(t, 1, resBody.NumCreated)
+	checkTestInt(t, 2, resBody.NumErrors)
+	checkTestString(t, ImportUsersErrorInvalidEmail, resBody.Rows[0].Error)
+	checkTestString(t, "", resBody.Rows[1].Error)
+	checkTestString(t, ImportUsersErrorMaxUsersExceeded, resBody.Rows[2].Error)
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+type MemoryUserRepository struct {
+	userRepositoryBase
+}
+
+func (r *MemoryUserRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryUserRepository) Create(e *User) error {
+	if err := r.checkUUIDs(e); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	row := memoryCopyUser(e)
+	row.Email = strings.ToLower(e.Email)
+	if r.emailExists(row.Email, "") {
+		memoryLock.Unlock()
+		return memoryUniqueViolation("users_email")
+	}
+	row.ID = memoryNewID()
+	memoryData.users = append(memoryData.users, row)
+	memoryLock.Unlock()
+	e.ID = row.ID
+	r.repos.UserPreferences.InitDefaultSettingsForUser(e.ID)
+	return nil
+}
+
+func (r *MemoryUserRepository) checkUUIDs(e *User) error {
+	if err := memoryCheckUUID(e.OrganizationID); err != nil {
+		return err
+	}
+	if e.AuthProviderID != "" {
+		return memoryCheckUUID(string(e.AuthProviderID))
+	}
+	return nil
+}
+
+// emailExists returns true if another user than the specified one has the email address.
+// The caller must hold the lock.
+func (r *MemoryUserRepository) emailExists(email, exceptID string) bool {
+	return memoryCount(memoryData.users, func(row *User) bool {
+		return row.Email == email && row.ID != exceptID
+	}) > 0
+}
+
+func (r *MemoryUserRepository) GetOne(id string) (*User, error) {
+	return r.getOne(func(e *User) bool {
+		return e.ID == id
+	})
+}
+
+func (r *MemoryUserRepository) GetByEmail(email string) (*User, error) {
+	return r.getOne(func(e *User) bool {
+		return strings.ToLower(e.Email) == strings.ToLower(email)
+	})
+}
+
+func (r *MemoryUserRepository) GetByAtlassianID(atlassianID string) (*User, error) {
+	return r.getOne(func(e *User) bool {
+		return e.AtlassianID != "" && strings.ToLower(string(e.AtlassianID)) == strings.ToLower(atlassianID)
+	})
+}
+
+func (r *MemoryUserRepository) getOne(cond func(*User) bool) (*User, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e, err := memoryFind(memoryData.users, cond)
+	if err != nil {
+		return nil, err
+	}
+	return memoryCopyUser(e), nil
+}
+
+func (r *MemoryUserRepository) GetUsersWithAtlassianID(organizationID string) ([]*User, error) {
+	return r.getAll(func(e *User) bool {
+		return e.OrganizationID == organizationID && e.AtlassianID != ""
+	}, r.byEmail), nil
+}
+
+func (r *MemoryUserRepository) UpdateAtlassianClientIDForUser(organizationID, userId, atlassianID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	for _, row := range memoryData.users {
+		if row.OrganizationID == organizationID && row.ID == userId {
+			row.AtlassianID = NullString(strings.ToLower(atlassianID))
+		}
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) UpdateAtlassianClientID(organizationID, oldClientID, newClientID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	for _, row := range memoryData.users {
+		if row.OrganizationID == organizationID && row.AtlassianID != "" {
+			row.AtlassianID = NullString(strings.ReplaceAll(string(row.AtlassianID), "@"+oldClientID, "@"+newClientID))
+			row.Email = strings.ReplaceAll(row.Email, "@"+oldClientID, "@"+newClientID)
+		}
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) GetByKeyword(organizationID string, keyword string) ([]*User, error) {
+	return r.getAll(func(e *User) bool {
+		return e.OrganizationID == organizationID && memoryContains(e.Email, keyword)
+	}, r.byEmail), nil
+}
+
+func (r *MemoryUserRepository) GetAll(organizationID string, maxResults int, offset int) ([]*User, error) {
+	result := r.getAll(func(e *User) bool {
+		return e.OrganizationID == organizationID
+	}, r.byEmail)
+	return memoryPage(result, maxResults, offset), nil
+}
+
+func (r *MemoryUserRepository) GetAllServiceAccounts(organizationID string) ([]*User, error) {
+	return r.getAll(func(e *User) bool {
+		return e.OrganizationID == organizationID && e.ServiceAccount
+	}, func(a, b *User) bool {
+		return memoryCompare(a.DisplayName, b.DisplayName) < 0
+	}), nil
+}
+
+func (r *MemoryUserRepository) byEmail(a, b *User) bool {
+	return memoryCompare(a.Email, b.Email) < 0
+}
+
+func (r *MemoryUserRepository) getAll(cond func(*User) bool, less func(a, b *User) bool) []*User {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	var result []*User
+	for _, e := range memoryData.users {
+		if cond(e) {
+			result = append(result, memoryCopyUser(e))
+		}
+	}
+	memorySort(result, less)
+	return result
+}
+
+func (r *MemoryUserRepository) GetAllIDs() ([]string, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	var result []string
+	for _, e := range memoryData.users {
+		result = append(result, e.ID)
+	}
+	return result, nil
+}
+
+func (r *MemoryUserRepository) Update(e *User) error {
+	if err := r.checkUUIDs(e); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	row := memoryUserByID(e.ID)
+	if row == nil {
+		return nil
+	}
+	email := strings.ToLower(e.Email)
+	if r.emailExists(email, e.ID) {
+		return memoryUniqueViolation("users_email")
+	}
+	*row = *memoryCopyUser(e)
+	row.Email = email
+	return nil
+}
+
+func (r *MemoryUserRepository) Delete(e *User) error {
+	if err := r.repos.BookingAttendee.DeleteOfUser(e); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	memoryData.bookings = memoryDelete(memoryData.bookings, func(row *memoryBooking) bool {
+		return row.UserID == e.ID
+	})
+	memoryLock.Unlock()
+	if err := r.repos.ICalToken.DeleteOfUser(e); err != nil {
+		return err
+	}
+	if err := r.repos.NoShow.DeleteOfUser(e); err != nil {
+		return err
+	}
+	if err := r.repos.Waitlist.DeleteOfUser(e); err != nil {
+		return err
+	}
+	if err := r.repos.LocationApprover.DeleteOfUser(e); err != nil {
+		return err
+	}
+	if err := r.repos.UserGroup.DeleteOfUser(e); err != nil {
+		return err
+	}
+	if err := r.repos.UserTOTP.Delete(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.APIToken.DeleteOfUser(e); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.users = memoryDelete(memoryData.users, func(row *User) bool {
+		return row.ID == e.ID
+	})
+	return nil
+}
+
+func (r *MemoryUserRepository) DeleteAll(organizationID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.users = memoryDelete(memoryData.users, func(row *User) bool {
+		return row.OrganizationID == organizationID
+	})
+	return nil
+}
+
+func (r *MemoryUserRepository) GetCount(organizationID string) (int, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryCount(memoryData.users, func(e *User) bool {
+		return e.OrganizationID == organizationID
+	}), nil
+}
+
+func (r *MemoryUserRepository) mergeUsers(source, target *User) error {
+	if source.OrganizationID != target.OrganizationID {
+		return errors.New("Organization ID of source and target users don't match")
+	}
+	memoryLock.Lock()
+	for _, row := range memoryData.bookings {
+		if row.UserID == source.ID {
+			row.UserID = target.ID
+		}
+	}
+	memoryLock.Unlock()
+	if target.AtlassianID == "" {
+		target.AtlassianID = source.AtlassianID
+	}
+	target.Role = UserRole(MaxOf(int(target.Role), int(source.Role)))
+	if err := r.Delete(source); err != nil {
+		return err
+	}
+	if err := r.Update(target); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) enableUsersWithExpiredBan() error {
+	now := memoryTimestamp(time.Now())
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	for _, row := range memoryData.users {
+		if row.Disabled && row.BanExpiry != nil && !row.BanExpiry.After(now) {
+			row.Disabled = false
+			row.BanExpiry = nil
+		}
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) DeleteObsoleteConfluenceAnonymousUsers() (int, error) {
+	timestamp := memoryTimestamp(time.Now().Add(-24 * time.Hour))
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	userIDs := make(map[string]bool)
+	for _, e := range memoryData.users {
+		if !strings.HasPrefix(e.Email, "confluence-anonymous-") {
+			continue
+		}
+		if memoryCount(memoryData.authAttempts, func(a *AuthAttempt) bool {
+			return a.UserID == e.ID && a.Successful && a.Timestamp.After(timestamp)
+		}) == 0 {
+			userIDs[e.ID] = true
+		}
+	}
+	memoryData.users = memoryDelete(memoryData.users, func(row *User) bool {
+		return userIDs[row.ID]
+	})
+	memoryData.bookings = memoryDelete(memoryData.bookings, func(row *memoryBooking) bool {
+		return userIDs[row.UserID]
+	})
+	return len(userIDs), nil
+}
+
+func (r *MemoryUserRepository) HasAnyUserInOrgPasswordSet(organizationID string) (bool, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryCount(memoryData.users, func(e *User) bool {
+		return e.OrganizationID == organizationID && e.HashedPassword != ""
+	}) > 0, nil
+}
+
+// memoryUserByID returns the stored user or nil. The caller must hold the lock.
+func memoryUserByID(id string) *User {
+	e, _ := memoryFind(memoryData.users, func(e *User) bool {
+		return e.ID == id
+	})
+	return e
+}
+
+func memoryCopyUser(e *User) *User {
+	c := *e
+	c.BanExpiry = memoryNullTimestamp(e.BanExpiry)
+	return &c
+}
@@ -0,0 +1,85 @@
+package main
+
+type MemoryUserPreferencesRepository struct {
+	userPreferencesRepositoryBase
+}
+
+func (r *MemoryUserPreferencesRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryUserPreferencesRepository) Set(userID string, name string, value string) error {
+	if err := memoryCheckUUID(userID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	r.set(userID, name, value, true)
+	return nil
+}
+
+// set inserts the preference or, if overwrite is true, updates its value. The caller
+// must hold the lock.
+func (r *MemoryUserPreferencesRepository) set(userID string, name string, value string, overwrite bool) {
+	row, err := memoryFind(memoryData.userPreferences, func(row *UserPreference) bool {
+		return row.UserID == userID && row.Name == name
+	})
+	if err != nil {
+		memoryData.userPreferences = append(memoryData.userPreferences, &UserPreference{
+			UserID: userID,
+			Name:   name,
+			Value:  value,
+		})
+		return
+	}
+	if overwrite {
+		row.Value = value
+	}
+}
+
+func (r *MemoryUserPreferencesRepository) Get(userID string, name string) (string, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e, err := memoryFind(memoryData.userPreferences, func(e *UserPreference) bool {
+		return e.UserID == userID && e.Name == name
+	})
+	if err != nil {
+		return "", err
+	}
+	return e.Value, nil
+}
+
+func (r *MemoryUserPreferencesRepository) GetAll(userID string) ([]*UserPreference, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	result := memoryCopy(memoryFilter(memoryData.userPreferences, func(e *UserPreference) bool {
+		return e.UserID == userID
+	}))
+	memorySort(result, func(a, b *UserPreference) bool {
+		return memoryCompare(a.Name, b.Name) < 0
+	})
+	return result, nil
+}
+
+func (r *MemoryUserPreferencesRepository) InitDefaultSettingsForUser(userID string) error {
+	if err := memoryCheckUUID(userID); err != nil {
+		return err
+	}
+	defaults := r.getDefaultSettingsForUser()
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	for _, e := range defaults {
+		r.set(userID, e.Name, e.Value, false)
+	}
+	return nil
+}
+
+func (r *MemoryUserPreferencesRepository) DeleteAll(userID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.userPreferences = memoryDelete(memoryData.userPreferences, func(row *UserPreference) bool {
+		return row.UserID == userID
+	})
+	return nil
+}
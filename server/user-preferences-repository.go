@@ -2,10 +2,28 @@ package main
 
 import (
 	"strconv"
-	"sync"
+	"strings"
 )
 
-type UserPreferencesRepository struct {
+type UserPreferencesRepository interface {
+	Repository
+	Set(userID string, name string, value string) error
+	Get(userID string, name string) (string, error)
+	GetInt(userID string, name string) (int, error)
+	GetBool(userID string, name string) (bool, error)
+	GetAll(userID string) ([]*UserPreference, error)
+	InitDefaultSettingsForUser(userID string) error
+	InitDefaultSettings(userIDs []string) error
+	DeleteAll(userID string) error
+}
+
+type PostgresUserPreferencesRepository struct {
+	userPreferencesRepositoryBase
+}
+
+// userPreferencesRepositoryBase parses preference values and knows the defaults of new users.
+type userPreferencesRepositoryBase struct {
+	repos *Repositories
 }
 
 type UserPreference struct {
@@ -20,16 +38,18 @@ type PreferenceName struct {
 }
 
 var (
-	PreferenceEnterTime            PreferenceName = PreferenceName{Name: "enter_time", Type: SettingTypeInt}
-	PreferenceWorkdayStart         PreferenceName = PreferenceName{Name: "workday_start", Type: SettingTypeInt}
-	PreferenceWorkdayEnd           PreferenceName = PreferenceName{Name: "workday_end", Type: SettingTypeInt}
-	PreferenceWorkdays             PreferenceName = PreferenceName{Name: "workdays", Type: SettingTypeIntArray}
-	PreferenceLocation             PreferenceName = PreferenceName{Name: "location_id", Type: SettingTypeString}
-	PreferenceBookedColor          PreferenceName = PreferenceName{Name: "booked_color", Type: SettingTypeString}
-	PreferenceNotBookedColor       PreferenceName = PreferenceName{Name: "not_booked_color", Type: SettingTypeString}
-	PreferenceSelfBookedColor      PreferenceName = PreferenceName{Name: "self_booked_color", Type: SettingTypeString}
-	PreferencePartiallyBookedColor PreferenceName = PreferenceName{Name: "partially_booked_color", Type: SettingTypeString}
-	PreferenceBuddyBookedColor     PreferenceName = PreferenceName{Name: "buddy_booked_color", Type: SettingTypeString}
+	PreferenceEnterTime               PreferenceName = PreferenceName{Name: "enter_time", Type: SettingTypeInt}
+	PreferenceWorkdayStart            PreferenceName = PreferenceName{Name: "workday_start", Type: SettingTypeInt}
+	PreferenceWorkdayEnd              PreferenceName = PreferenceName{Name: "workday_end", Type: SettingTypeInt}
+	PreferenceWorkdays                PreferenceName = PreferenceName{Name: "workdays", Type: SettingTypeIntArray}
+	PreferenceLocation                PreferenceName = PreferenceName{Name: "location_id", Type: SettingTypeString}
+	PreferenceBookedColor             PreferenceName = PreferenceName{Name: "booked_color", Type: SettingTypeString}
+	PreferenceNotBookedColor          PreferenceName = PreferenceName{Name: "not_booked_color", Type: SettingTypeString}
+	PreferenceSelfBookedColor         PreferenceName = PreferenceName{Name: "self_booked_color", Type: SettingTypeString}
+	PreferencePartiallyBookedColor    PreferenceName = PreferenceName{Name: "partially_booked_color", Type: SettingTypeString}
+	PreferenceBuddyBookedColor        PreferenceName = PreferenceName{Name: "buddy_booked_color", Type: SettingTypeString}
+	PreferenceMailBookingConfirmation PreferenceName = PreferenceName{Name: "mail_booking_confirmation", Type: SettingTypeBool}
+	PreferenceMailBookingReminder     PreferenceName = PreferenceName{Name: "mail_booking_reminder", Type: SettingTypeBool}
 )
 
 var (
@@ -38,29 +58,19 @@ var (
 	PreferenceEnterTimeNextWorkday int = 3
 )
 
-var userPreferencesRepository *UserPreferencesRepository
-var userPreferencesRepositoryOnce sync.Once
-
-func GetUserPreferencesRepository() *UserPreferencesRepository {
-	userPreferencesRepositoryOnce.Do(func() {
-		userPreferencesRepository = &UserPreferencesRepository{}
-		_, err := GetDatabase().DB().Exec("CREATE TABLE IF NOT EXISTS users_preferences (" +
-			"user_id uuid NOT NULL, " +
-			"name VARCHAR NOT NULL, " +
-			"value VARCHAR NOT NULL DEFAULT '', " +
-			"PRIMARY KEY (user_id, name))")
-		if err != nil {
-			panic(err)
-		}
-	})
-	return userPreferencesRepository
-}
-
-func (r *UserPreferencesRepository) RunSchemaUpgrade(curVersion, targetVersion int) {
-	// nothing yet
+func (r *PostgresUserPreferencesRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		newInitialSchemaMigration(
+			"CREATE TABLE IF NOT EXISTS users_preferences (" +
+				"user_id uuid NOT NULL, " +
+				"name VARCHAR NOT NULL, " +
+				"value VARCHAR NOT NULL DEFAULT '', " +
+				"PRIMARY KEY (user_id, name))",
+		),
+	}
 }
 
-func (r *UserPreferencesRepository) Set(userID string, name string, value string) error {
+func (r *PostgresUserPreferencesRepository) Set(userID string, name string, value string) error {
 	_, err := GetDatabase().DB().Exec("INSERT INTO users_preferences (user_id, name, value) "+
 		"VALUES ($1, $2, $3) "+
 		"ON CONFLICT (user_id, name) DO UPDATE SET value = $3",
@@ -68,7 +78,7 @@ func (r *UserPreferencesRepository) Set(userID string, name string, value string
 	return err
 }
 
-func (r *UserPreferencesRepository) Get(userID string, name string) (string, error) {
+func (r *PostgresUserPreferencesRepository) Get(userID string, name string) (string, error) {
 	var res string
 	err := GetDatabase().DB().QueryRow("SELECT value FROM users_preferences "+
 		"WHERE user_id = $1 AND name = $2",
@@ -79,8 +89,8 @@ func (r *UserPreferencesRepository) Get(userID string, name string) (string, err
 	return res, nil
 }
 
-func (r *UserPreferencesRepository) GetInt(userID string, name string) (int, error) {
-	res, err := r.Get(userID, name)
+func (r *userPreferencesRepositoryBase) GetInt(userID string, name string) (int, error) {
+	res, err := r.repos.UserPreferences.Get(userID, name)
 	if err != nil {
 		return 0, err
 	}
@@ -98,8 +108,8 @@ func (r *UserPreferencesRepository) GetIntArray(userID string, name string) (int
 	return i, err
 } */
 
-func (r *UserPreferencesRepository) GetBool(userID string, name string) (bool, error) {
-	res, err := r.Get(userID, name)
+func (r *userPreferencesRepositoryBase) GetBool(userID string, name string) (bool, error) {
+	res, err := r.repos.UserPreferences.Get(userID, name)
 	if err != nil {
 		return false, err
 	}
@@ -107,7 +117,7 @@ func (r *UserPreferencesRepository) GetBool(userID string, name string) (bool, e
 	return b, err
 }
 
-func (r *UserPreferencesRepository) GetAll(userID string) ([]*UserPreference, error) {
+func (r *PostgresUserPreferencesRepository) GetAll(userID string) ([]*UserPreference, error) {
 	var result []*UserPreference
 	rows, err := GetDatabase().DB().Query("SELECT user_id, name, value FROM users_preferences "+
 		"WHERE user_id = $1 "+
@@ -127,34 +137,48 @@ func (r *UserPreferencesRepository) GetAll(userID string) ([]*UserPreference, er
 	return result, nil
 }
 
-func (r *UserPreferencesRepository) InitDefaultSettingsForUser(userID string) error {
+func (r *PostgresUserPreferencesRepository) InitDefaultSettingsForUser(userID string) error {
+	var values []string
+	args := []interface{}{userID}
+	for _, e := range r.getDefaultSettingsForUser() {
+		values = append(values, "($1, $"+strconv.Itoa(len(args)+1)+", $"+strconv.Itoa(len(args)+2)+")")
+		args = append(args, e.Name, e.Value)
+	}
 	_, err := GetDatabase().DB().Exec("INSERT INTO users_preferences (user_id, name, value) "+
-		"VALUES "+
-		"($1, '"+PreferenceEnterTime.Name+"', '"+strconv.Itoa(PreferenceEnterTimeNow)+"'), "+
-		"($1, '"+PreferenceWorkdayStart.Name+"', '9'), "+
-		"($1, '"+PreferenceWorkdayEnd.Name+"', '17'), "+
-		"($1, '"+PreferenceWorkdays.Name+"', '1,2,3,4,5'), "+
-		"($1, '"+PreferenceLocation.Name+"', ''), "+
-		"($1, '"+PreferenceBookedColor.Name+"', '#ff453a'), "+
-		"($1, '"+PreferenceNotBookedColor.Name+"', '#30d158'), "+
-		"($1, '"+PreferenceSelfBookedColor.Name+"', '#b825de'), "+
-		"($1, '"+PreferencePartiallyBookedColor.Name+"', '#ff9100'), "+
-		"($1, '"+PreferenceBuddyBookedColor.Name+"', '#2415c5') "+
+		"VALUES "+strings.Join(values, ", ")+" "+
 		"ON CONFLICT (user_id, name) DO NOTHING",
-		userID)
+		args...)
 	return err
 }
 
-func (r *UserPreferencesRepository) InitDefaultSettings(userIDs []string) error {
+// getDefaultSettingsForUser returns the preferences every user starts with.
+func (r *userPreferencesRepositoryBase) getDefaultSettingsForUser() []*UserPreference {
+	return []*UserPreference{
+		{Name: PreferenceEnterTime.Name, Value: strconv.Itoa(PreferenceEnterTimeNow)},
+		{Name: PreferenceWorkdayStart.Name, Value: "9"},
+		{Name: PreferenceWorkdayEnd.Name, Value: "17"},
+		{Name: PreferenceWorkdays.Name, Value: "1,2,3,4,5"},
+		{Name: PreferenceLocation.Name, Value: ""},
+		{Name: PreferenceBookedColor.Name, Value: "#ff453a"},
+		{Name: PreferenceNotBookedColor.Name, Value: "#30d158"},
+		{Name: PreferenceSelfBookedColor.Name, Value: "#b825de"},
+		{Name: PreferencePartiallyBookedColor.Name, Value: "#ff9100"},
+		{Name: PreferenceBuddyBookedColor.Name, Value: "#2415c5"},
+		{Name: PreferenceMailBookingConfirmation.Name, Value: "1"},
+		{Name: PreferenceMailBookingReminder.Name, Value: "1"},
+	}
+}
+
+func (r *userPreferencesRepositoryBase) InitDefaultSettings(userIDs []string) error {
 	for _, userID := range userIDs {
-		if err := r.InitDefaultSettingsForUser(userID); err != nil {
+		if err := r.repos.UserPreferences.InitDefaultSettingsForUser(userID); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (r *UserPreferencesRepository) DeleteAll(userID string) error {
+func (r *PostgresUserPreferencesRepository) DeleteAll(userID string) error {
 	_, err := GetDatabase().DB().Exec("DELETE FROM users_preferences WHERE user_id = $1", userID)
 	return err
 }
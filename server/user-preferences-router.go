@@ -10,6 +10,7 @@ import (
 )
 
 type UserPreferencesRouter struct {
+	repos *Repositories
 }
 
 func (router *UserPreferencesRouter) setupRoutes(s *mux.Router) {
@@ -20,13 +21,13 @@ func (router *UserPreferencesRouter) setupRoutes(s *mux.Router) {
 }
 
 func (router *UserPreferencesRouter) getPreference(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	vars := mux.Vars(r)
 	if !router.isValidPreferenceName(vars["name"]) {
 		SendNotFound(w)
 		return
 	}
-	value, err := GetUserPreferencesRepository().Get(user.ID, vars["name"])
+	value, err := router.repos.UserPreferences.Get(user.ID, vars["name"])
 	if err != nil {
 		log.Println(err)
 		SendNotFound(w)
@@ -36,7 +37,7 @@ func (router *UserPreferencesRouter) getPreference(w http.ResponseWriter, r *htt
 }
 
 func (router *UserPreferencesRouter) setPreference(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	var value SetSettingsRequest
 	if UnmarshalValidateBody(r, &value) != nil {
 		SendBadRequest(w)
@@ -65,8 +66,8 @@ func (router *UserPreferencesRouter) setPreference(w http.ResponseWriter, r *htt
 }
 
 func (router *UserPreferencesRouter) getAll(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
-	list, err := GetUserPreferencesRepository().GetAll(user.ID)
+	user := GetRequestUser(router.repos, r)
+	list, err := router.repos.UserPreferences.GetAll(user.ID)
 	if err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
@@ -81,7 +82,7 @@ func (router *UserPreferencesRouter) getAll(w http.ResponseWriter, r *http.Reque
 }
 
 func (router *UserPreferencesRouter) setAll(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	var list []GetSettingsResponse
 	if err := UnmarshalBody(r, &list); err != nil {
 		log.Println(err)
@@ -112,7 +113,7 @@ func (router *UserPreferencesRouter) setAll(w http.ResponseWriter, r *http.Reque
 }
 
 func (router *UserPreferencesRouter) doSetOne(userID, name, value string) error {
-	err := GetUserPreferencesRepository().Set(userID, name, value)
+	err := router.repos.UserPreferences.Set(userID, name, value)
 	return err
 }
 
@@ -126,6 +127,8 @@ func (router *UserPreferencesRouter) isValidPreferenceName(name string) bool {
 		name == PreferenceSelfBookedColor.Name ||
 		name == PreferencePartiallyBookedColor.Name ||
 		name == PreferenceNotBookedColor.Name ||
+		name == PreferenceMailBookingConfirmation.Name ||
+		name == PreferenceMailBookingReminder.Name ||
 		name == PreferenceLocation.Name {
 		return true
 	}
@@ -163,6 +166,12 @@ func (router *UserPreferencesRouter) getPreferenceType(name string) SettingType
 	if name == PreferenceLocation.Name {
 		return PreferenceLocation.Type
 	}
+	if name == PreferenceMailBookingConfirmation.Name {
+		return PreferenceMailBookingConfirmation.Type
+	}
+	if name == PreferenceMailBookingReminder.Name {
+		return PreferenceMailBookingReminder.Type
+	}
 	return 0
 }
 
@@ -43,7 +43,7 @@ func TestUserPreferencesCRUDMany(t *testing.T) {
 	org := createTestOrg("test.com")
 	user := createTestUserInOrg(org)
 	loginResponse := loginTestUser(user.ID)
-	GetDatabase().DB().Exec("TRUNCATE users_preferences")
+	testRepositories.UserPreferences.DeleteAll(user.ID)
 
 	payload := `[{"name": "enter_time", "value": "1"}, {"name": "workday_start", "value": "5"}]`
 	req := newHTTPRequest("PUT", "/preference/", loginResponse.UserID, bytes.NewBufferString(payload))
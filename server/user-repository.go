@@ -4,14 +4,48 @@ import (
 	"errors"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
-type UserRepository struct {
+type UserRepository interface {
+	Repository
+	Create(e *User) error
+	GetOne(id string) (*User, error)
+	GetByEmail(email string) (*User, error)
+	GetByAtlassianID(atlassianID string) (*User, error)
+	GetUsersWithAtlassianID(organizationID string) ([]*User, error)
+	UpdateAtlassianClientIDForUser(organizationID, userId, atlassianID string) error
+	UpdateAtlassianClientID(organizationID, oldClientID, newClientID string) error
+	GetByKeyword(organizationID string, keyword string) ([]*User, error)
+	GetAll(organizationID string, maxResults int, offset int) ([]*User, error)
+	GetAllServiceAccounts(organizationID string) ([]*User, error)
+	GetAllIDs() ([]string, error)
+	Update(e *User) error
+	Delete(e *User) error
+	DeleteAll(organizationID string) error
+	GetCount(organizationID string) (int, error)
+	GetHashedPassword(password string) string
+	CheckPassword(hashedPassword, password string) bool
+	mergeUsers(source, target *User) error
+	enableUsersWithExpiredBan() error
+	canCreateUser(org *Organization) bool
+	isSpaceAdmin(user *User) bool
+	isOrgAdmin(user *User) bool
+	isSuperAdmin(user *User) bool
+	DeleteObsoleteConfluenceAnonymousUsers() (int, error)
+	HasAnyUserInOrgPasswordSet(organizationID string) (bool, error)
+}
+
+type PostgresUserRepository struct {
+	userRepositoryBase
+}
+
+// userRepositoryBase contains the password handling and role checks of users.
+type userRepositoryBase struct {
+	repos *Repositories
 }
 
 type UserRole int
@@ -33,137 +67,151 @@ type User struct {
 	Role           UserRole
 	Disabled       bool
 	BanExpiry      *time.Time
+	DisplayName    string
+	ServiceAccount bool
 }
 
-var userRepository *UserRepository
-var userRepositoryOnce sync.Once
-
-func GetUserRepository() *UserRepository {
-	userRepositoryOnce.Do(func() {
-		userRepository = &UserRepository{}
-		_, err := GetDatabase().DB().Exec("CREATE TABLE IF NOT EXISTS users (" +
-			"id uuid DEFAULT uuid_generate_v4(), " +
-			"organization_id uuid NOT NULL, " +
-			"email VARCHAR NOT NULL, " +
-			"org_admin boolean NOT NULL DEFAULT FALSE, " +
-			"super_admin boolean NOT NULL DEFAULT FALSE, " +
-			"PRIMARY KEY (id))")
-		if err != nil {
-			panic(err)
-		}
-		_, err = GetDatabase().DB().Exec("CREATE UNIQUE INDEX IF NOT EXISTS users_email ON users(email)")
-		if err != nil {
-			panic(err)
-		}
-	})
-	return userRepository
-}
-
-func (r *UserRepository) RunSchemaUpgrade(curVersion, targetVersion int) {
-	if curVersion < 1 {
-		if _, err := GetDatabase().DB().Exec("ALTER TABLE users " +
-			"ADD COLUMN password VARCHAR, " +
-			"ADD COLUMN auth_provider_id uuid"); err != nil {
-			panic(err)
-		}
-	}
-	if curVersion < 2 {
-		if _, err := GetDatabase().DB().Exec("ALTER TABLE users " +
-			"ALTER COLUMN id SET DEFAULT uuid_generate_v4()"); err != nil {
-			panic(err)
-		}
-	}
-	if curVersion < 7 {
-		if _, err := GetDatabase().DB().Exec("ALTER TABLE users " +
-			"ADD COLUMN atlassian_id VARCHAR"); err != nil {
-			panic(err)
-		}
-		if _, err := GetDatabase().DB().Exec("CREATE INDEX IF NOT EXISTS users_atlassian_id ON users(atlassian_id)"); err != nil {
-			panic(err)
-		}
-	}
-	if curVersion < 13 {
-		if _, err := GetDatabase().DB().Exec("ALTER TABLE users " +
-			"ADD COLUMN role INT"); err != nil {
-			panic(err)
-		}
-		if _, err := GetDatabase().DB().Exec("UPDATE users SET role = " + strconv.Itoa(int(UserRoleUser))); err != nil {
-			panic(err)
-		}
-		if _, err := GetDatabase().DB().Exec("UPDATE users SET role = " + strconv.Itoa(int(UserRoleOrgAdmin)) + " WHERE org_admin IS TRUE"); err != nil {
-			panic(err)
-		}
-		if _, err := GetDatabase().DB().Exec("UPDATE users SET role = " + strconv.Itoa(int(UserRoleSuperAdmin)) + " WHERE super_admin IS TRUE"); err != nil {
-			panic(err)
-		}
-		if _, err := GetDatabase().DB().Exec("ALTER TABLE users " +
-			"DROP COLUMN org_admin, " +
-			"DROP COLUMN super_admin"); err != nil {
-			panic(err)
-		}
-	}
-	if curVersion < 14 {
-		if _, err := GetDatabase().DB().Exec("ALTER TABLE users " +
-			"ADD COLUMN disabled boolean NOT NULL DEFAULT FALSE, " +
-			"ADD COLUMN ban_expiry TIMESTAMP NULL DEFAULT NULL"); err != nil {
-			panic(err)
-		}
+func (r *PostgresUserRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		newInitialSchemaMigration(
+			"CREATE TABLE IF NOT EXISTS users ("+
+				"id uuid DEFAULT uuid_generate_v4(), "+
+				"organization_id uuid NOT NULL, "+
+				"email VARCHAR NOT NULL, "+
+				"org_admin boolean NOT NULL DEFAULT FALSE, "+
+				"super_admin boolean NOT NULL DEFAULT FALSE, "+
+				"PRIMARY KEY (id))",
+			"CREATE UNIQUE INDEX IF NOT EXISTS users_email ON users(email)",
+		),
+		{
+			Version:     1,
+			Description: "Add password and auth provider to users",
+			Up: []string{"ALTER TABLE users " +
+				"ADD COLUMN password VARCHAR, " +
+				"ADD COLUMN auth_provider_id uuid"},
+			Down: []string{"ALTER TABLE users " +
+				"DROP COLUMN password, " +
+				"DROP COLUMN auth_provider_id"},
+		},
+		{
+			Version:     2,
+			Description: "Generate IDs of users",
+			Up: []string{"ALTER TABLE users " +
+				"ALTER COLUMN id SET DEFAULT uuid_generate_v4()"},
+			Down: []string{"ALTER TABLE users " +
+				"ALTER COLUMN id DROP DEFAULT"},
+		},
+		{
+			Version:     7,
+			Description: "Add Atlassian ID to users",
+			Up: []string{
+				"ALTER TABLE users " +
+					"ADD COLUMN atlassian_id VARCHAR",
+				"CREATE INDEX IF NOT EXISTS users_atlassian_id ON users(atlassian_id)",
+			},
+			Down: []string{
+				"DROP INDEX IF EXISTS users_atlassian_id",
+				"ALTER TABLE users " +
+					"DROP COLUMN atlassian_id",
+			},
+		},
+		{
+			// Roles added since then, i.e. space admins, can't be expressed by the flags
+			Version:     13,
+			Description: "Replace admin flags of users with roles",
+			Up: []string{
+				"ALTER TABLE users " +
+					"ADD COLUMN role INT",
+				"UPDATE users SET role = " + strconv.Itoa(int(UserRoleUser)),
+				"UPDATE users SET role = " + strconv.Itoa(int(UserRoleOrgAdmin)) + " WHERE org_admin IS TRUE",
+				"UPDATE users SET role = " + strconv.Itoa(int(UserRoleSuperAdmin)) + " WHERE super_admin IS TRUE",
+				"ALTER TABLE users " +
+					"DROP COLUMN org_admin, " +
+					"DROP COLUMN super_admin",
+			},
+		},
+		{
+			Version:     14,
+			Description: "Add disabling and banning of users",
+			Up: []string{"ALTER TABLE users " +
+				"ADD COLUMN disabled boolean NOT NULL DEFAULT FALSE, " +
+				"ADD COLUMN ban_expiry TIMESTAMP NULL DEFAULT NULL"},
+			Down: []string{"ALTER TABLE users " +
+				"DROP COLUMN disabled, " +
+				"DROP COLUMN ban_expiry"},
+		},
+		{
+			Version:     19,
+			Description: "Add display name to users",
+			Up: []string{"ALTER TABLE users " +
+				"ADD COLUMN display_name VARCHAR NOT NULL DEFAULT ''"},
+			Down: []string{"ALTER TABLE users " +
+				"DROP COLUMN display_name"},
+		},
+		{
+			Version:     23,
+			Description: "Add service accounts",
+			Up: []string{"ALTER TABLE users " +
+				"ADD COLUMN service_account boolean NOT NULL DEFAULT FALSE"},
+			Down: []string{"ALTER TABLE users " +
+				"DROP COLUMN service_account"},
+		},
 	}
 }
 
-func (r *UserRepository) Create(e *User) error {
+func (r *PostgresUserRepository) Create(e *User) error {
 	var id string
 	err := GetDatabase().DB().QueryRow("INSERT INTO users "+
-		"(organization_id, email, role, password, auth_provider_id, atlassian_id, disabled, ban_expiry) "+
-		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8) "+
+		"(organization_id, email, role, password, auth_provider_id, atlassian_id, disabled, ban_expiry, display_name, service_account) "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) "+
 		"RETURNING id",
-		e.OrganizationID, strings.ToLower(e.Email), e.Role, CheckNullString(e.HashedPassword), CheckNullString(e.AuthProviderID), CheckNullString(e.AtlassianID), e.Disabled, e.BanExpiry).Scan(&id)
+		e.OrganizationID, strings.ToLower(e.Email), e.Role, CheckNullString(e.HashedPassword), CheckNullString(e.AuthProviderID), CheckNullString(e.AtlassianID), e.Disabled, e.BanExpiry, e.DisplayName, e.ServiceAccount).Scan(&id)
 	if err != nil {
 		return err
 	}
 	e.ID = id
-	GetUserPreferencesRepository().InitDefaultSettingsForUser(e.ID)
+	r.repos.UserPreferences.InitDefaultSettingsForUser(e.ID)
 	return nil
 }
 
-func (r *UserRepository) GetOne(id string) (*User, error) {
+func (r *PostgresUserRepository) GetOne(id string) (*User, error) {
 	e := &User{}
-	err := GetDatabase().DB().QueryRow("SELECT id, organization_id, email, role, password, auth_provider_id, atlassian_id, disabled, ban_expiry "+
+	err := GetDatabase().DB().QueryRow("SELECT id, organization_id, email, role, password, auth_provider_id, atlassian_id, disabled, ban_expiry, display_name, service_account "+
 		"FROM users "+
 		"WHERE id = $1",
-		id).Scan(&e.ID, &e.OrganizationID, &e.Email, &e.Role, &e.HashedPassword, &e.AuthProviderID, &e.AtlassianID, &e.Disabled, &e.BanExpiry)
+		id).Scan(&e.ID, &e.OrganizationID, &e.Email, &e.Role, &e.HashedPassword, &e.AuthProviderID, &e.AtlassianID, &e.Disabled, &e.BanExpiry, &e.DisplayName, &e.ServiceAccount)
 	if err != nil {
 		return nil, err
 	}
 	return e, nil
 }
 
-func (r *UserRepository) GetByEmail(email string) (*User, error) {
+func (r *PostgresUserRepository) GetByEmail(email string) (*User, error) {
 	e := &User{}
-	err := GetDatabase().DB().QueryRow("SELECT id, organization_id, email, role, password, auth_provider_id, atlassian_id, disabled, ban_expiry "+
+	err := GetDatabase().DB().QueryRow("SELECT id, organization_id, email, role, password, auth_provider_id, atlassian_id, disabled, ban_expiry, display_name, service_account "+
 		"FROM users "+
 		"WHERE LOWER(email) = $1",
-		strings.ToLower(email)).Scan(&e.ID, &e.OrganizationID, &e.Email, &e.Role, &e.HashedPassword, &e.AuthProviderID, &e.AtlassianID, &e.Disabled, &e.BanExpiry)
+		strings.ToLower(email)).Scan(&e.ID, &e.OrganizationID, &e.Email, &e.Role, &e.HashedPassword, &e.AuthProviderID, &e.AtlassianID, &e.Disabled, &e.BanExpiry, &e.DisplayName, &e.ServiceAccount)
 	if err != nil {
 		return nil, err
 	}
 	return e, nil
 }
-func (r *UserRepository) GetByAtlassianID(atlassianID string) (*User, error) {
+func (r *PostgresUserRepository) GetByAtlassianID(atlassianID string) (*User, error) {
 	e := &User{}
-	err := GetDatabase().DB().QueryRow("SELECT id, organization_id, email, role, password, auth_provider_id, atlassian_id, disabled, ban_expiry "+
+	err := GetDatabase().DB().QueryRow("SELECT id, organization_id, email, role, password, auth_provider_id, atlassian_id, disabled, ban_expiry, display_name, service_account "+
 		"FROM users "+
 		"WHERE LOWER(atlassian_id) = $1",
-		strings.ToLower(atlassianID)).Scan(&e.ID, &e.OrganizationID, &e.Email, &e.Role, &e.HashedPassword, &e.AuthProviderID, &e.AtlassianID, &e.Disabled, &e.BanExpiry)
+		strings.ToLower(atlassianID)).Scan(&e.ID, &e.OrganizationID, &e.Email, &e.Role, &e.HashedPassword, &e.AuthProviderID, &e.AtlassianID, &e.Disabled, &e.BanExpiry, &e.DisplayName, &e.ServiceAccount)
 	if err != nil {
 		return nil, err
 	}
 	return e, nil
 }
 
-func (r *UserRepository) GetUsersWithAtlassianID(organizationID string) ([]*User, error) {
+func (r *PostgresUserRepository) GetUsersWithAtlassianID(organizationID string) ([]*User, error) {
 	var result []*User
-	rows, err := GetDatabase().DB().Query("SELECT id, organization_id, email, role, password, auth_provider_id, atlassian_id, disabled, ban_expiry "+
+	rows, err := GetDatabase().DB().Query("SELECT id, organization_id, email, role, password, auth_provider_id, atlassian_id, disabled, ban_expiry, display_name, service_account "+
 		"FROM users "+
 		"WHERE organization_id = $1 AND (atlassian_id IS NOT NULL OR atlassian_id != '') "+
 		"ORDER BY email", organizationID)
@@ -173,7 +221,7 @@ func (r *UserRepository) GetUsersWithAtlassianID(organizationID string) ([]*User
 	defer rows.Close()
 	for rows.Next() {
 		e := &User{}
-		err = rows.Scan(&e.ID, &e.OrganizationID, &e.Email, &e.Role, &e.HashedPassword, &e.AuthProviderID, &e.AtlassianID, &e.Disabled, &e.BanExpiry)
+		err = rows.Scan(&e.ID, &e.OrganizationID, &e.Email, &e.Role, &e.HashedPassword, &e.AuthProviderID, &e.AtlassianID, &e.Disabled, &e.BanExpiry, &e.DisplayName, &e.ServiceAccount)
 		if err != nil {
 			return nil, err
 		}
@@ -182,7 +230,7 @@ func (r *UserRepository) GetUsersWithAtlassianID(organizationID string) ([]*User
 	return result, nil
 }
 
-func (r *UserRepository) UpdateAtlassianClientIDForUser(organizationID, userId, atlassianID string) error {
+func (r *PostgresUserRepository) UpdateAtlassianClientIDForUser(organizationID, userId, atlassianID string) error {
 	_, err := GetDatabase().DB().Exec("UPDATE users SET "+
 		"atlassian_id =  $3 "+
 		"WHERE organization_id = $1 AND id = $2",
@@ -190,7 +238,7 @@ func (r *UserRepository) UpdateAtlassianClientIDForUser(organizationID, userId,
 	return err
 }
 
-func (r *UserRepository) UpdateAtlassianClientID(organizationID, oldClientID, newClientID string) error {
+func (r *PostgresUserRepository) UpdateAtlassianClientID(organizationID, oldClientID, newClientID string) error {
 	_, err := GetDatabase().DB().Exec("UPDATE users SET "+
 		"atlassian_id = REPLACE(atlassian_id, '@"+oldClientID+"', '@"+newClientID+"') ,"+
 		"email = REPLACE(email, '@"+oldClientID+"', '@"+newClientID+"')"+
@@ -199,9 +247,9 @@ func (r *UserRepository) UpdateAtlassianClientID(organizationID, oldClientID, ne
 	return err
 }
 
-func (r *UserRepository) GetByKeyword(organizationID string, keyword string) ([]*User, error) {
+func (r *PostgresUserRepository) GetByKeyword(organizationID string, keyword string) ([]*User, error) {
 	var result []*User
-	rows, err := GetDatabase().DB().Query("SELECT id, organization_id, email, role, password, auth_provider_id, atlassian_id, disabled, ban_expiry "+
+	rows, err := GetDatabase().DB().Query("SELECT id, organization_id, email, role, password, auth_provider_id, atlassian_id, disabled, ban_expiry, display_name, service_account "+
 		"FROM users "+
 		"WHERE organization_id = $1 AND LOWER(email) LIKE '%' || $2 || '%' "+
 		"ORDER BY email", organizationID, strings.ToLower(keyword))
@@ -211,7 +259,7 @@ func (r *UserRepository) GetByKeyword(organizationID string, keyword string) ([]
 	defer rows.Close()
 	for rows.Next() {
 		e := &User{}
-		err = rows.Scan(&e.ID, &e.OrganizationID, &e.Email, &e.Role, &e.HashedPassword, &e.AuthProviderID, &e.AtlassianID, &e.Disabled, &e.BanExpiry)
+		err = rows.Scan(&e.ID, &e.OrganizationID, &e.Email, &e.Role, &e.HashedPassword, &e.AuthProviderID, &e.AtlassianID, &e.Disabled, &e.BanExpiry, &e.DisplayName, &e.ServiceAccount)
 		if err != nil {
 			return nil, err
 		}
@@ -220,9 +268,9 @@ func (r *UserRepository) GetByKeyword(organizationID string, keyword string) ([]
 	return result, nil
 }
 
-func (r *UserRepository) GetAll(organizationID string, maxResults int, offset int) ([]*User, error) {
+func (r *PostgresUserRepository) GetAll(organizationID string, maxResults int, offset int) ([]*User, error) {
 	var result []*User
-	rows, err := GetDatabase().DB().Query("SELECT id, organization_id, email, role, password, auth_provider_id, atlassian_id, disabled, ban_expiry "+
+	rows, err := GetDatabase().DB().Query("SELECT id, organization_id, email, role, password, auth_provider_id, atlassian_id, disabled, ban_expiry, display_name, service_account "+
 		"FROM users "+
 		"WHERE organization_id = $1 "+
 		"ORDER BY email "+
@@ -233,7 +281,28 @@ func (r *UserRepository) GetAll(organizationID string, maxResults int, offset in
 	defer rows.Close()
 	for rows.Next() {
 		e := &User{}
-		err = rows.Scan(&e.ID, &e.OrganizationID, &e.Email, &e.Role, &e.HashedPassword, &e.AuthProviderID, &e.AtlassianID, &e.Disabled, &e.BanExpiry)
+		err = rows.Scan(&e.ID, &e.OrganizationID, &e.Email, &e.Role, &e.HashedPassword, &e.AuthProviderID, &e.AtlassianID, &e.Disabled, &e.BanExpiry, &e.DisplayName, &e.ServiceAccount)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (r *PostgresUserRepository) GetAllServiceAccounts(organizationID string) ([]*User, error) {
+	var result []*User
+	rows, err := GetDatabase().DB().Query("SELECT id, organization_id, email, role, password, auth_provider_id, atlassian_id, disabled, ban_expiry, display_name, service_account "+
+		"FROM users "+
+		"WHERE organization_id = $1 AND service_account = TRUE "+
+		"ORDER BY display_name", organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &User{}
+		err = rows.Scan(&e.ID, &e.OrganizationID, &e.Email, &e.Role, &e.HashedPassword, &e.AuthProviderID, &e.AtlassianID, &e.Disabled, &e.BanExpiry, &e.DisplayName, &e.ServiceAccount)
 		if err != nil {
 			return nil, err
 		}
@@ -242,7 +311,7 @@ func (r *UserRepository) GetAll(organizationID string, maxResults int, offset in
 	return result, nil
 }
 
-func (r *UserRepository) GetAllIDs() ([]string, error) {
+func (r *PostgresUserRepository) GetAllIDs() ([]string, error) {
 	var result []string
 	rows, err := GetDatabase().DB().Query("SELECT id " +
 		"FROM users")
@@ -261,7 +330,7 @@ func (r *UserRepository) GetAllIDs() ([]string, error) {
 	return result, nil
 }
 
-func (r *UserRepository) Update(e *User) error {
+func (r *PostgresUserRepository) Update(e *User) error {
 	_, err := GetDatabase().DB().Exec("UPDATE users SET "+
 		"organization_id = $1, "+
 		"email = $2, "+
@@ -270,27 +339,53 @@ func (r *UserRepository) Update(e *User) error {
 		"auth_provider_id = $5, "+
 		"atlassian_id = $6, "+
 		"disabled = $7, "+
-		"ban_expiry = $8 "+
-		"WHERE id = $9",
-		e.OrganizationID, strings.ToLower(e.Email), e.Role, CheckNullString(e.HashedPassword), CheckNullString(e.AuthProviderID), CheckNullString(e.AtlassianID), e.Disabled, e.BanExpiry, e.ID)
+		"ban_expiry = $8, "+
+		"display_name = $9, "+
+		"service_account = $10 "+
+		"WHERE id = $11",
+		e.OrganizationID, strings.ToLower(e.Email), e.Role, CheckNullString(e.HashedPassword), CheckNullString(e.AuthProviderID), CheckNullString(e.AtlassianID), e.Disabled, e.BanExpiry, e.DisplayName, e.ServiceAccount, e.ID)
 	return err
 }
 
-func (r *UserRepository) Delete(e *User) error {
+func (r *PostgresUserRepository) Delete(e *User) error {
+	if err := r.repos.BookingAttendee.DeleteOfUser(e); err != nil {
+		return err
+	}
 	if _, err := GetDatabase().DB().Exec("DELETE FROM bookings WHERE "+
 		"bookings.user_id = $1", e.ID); err != nil {
 		return err
 	}
+	if err := r.repos.ICalToken.DeleteOfUser(e); err != nil {
+		return err
+	}
+	if err := r.repos.NoShow.DeleteOfUser(e); err != nil {
+		return err
+	}
+	if err := r.repos.Waitlist.DeleteOfUser(e); err != nil {
+		return err
+	}
+	if err := r.repos.LocationApprover.DeleteOfUser(e); err != nil {
+		return err
+	}
+	if err := r.repos.UserGroup.DeleteOfUser(e); err != nil {
+		return err
+	}
+	if err := r.repos.UserTOTP.Delete(e.ID); err != nil {
+		return err
+	}
+	if err := r.repos.APIToken.DeleteOfUser(e); err != nil {
+		return err
+	}
 	_, err := GetDatabase().DB().Exec("DELETE FROM users WHERE id = $1", e.ID)
 	return err
 }
 
-func (r *UserRepository) DeleteAll(organizationID string) error {
+func (r *PostgresUserRepository) DeleteAll(organizationID string) error {
 	_, err := GetDatabase().DB().Exec("DELETE FROM users WHERE organization_id = $1", organizationID)
 	return err
 }
 
-func (r *UserRepository) GetCount(organizationID string) (int, error) {
+func (r *PostgresUserRepository) GetCount(organizationID string) (int, error) {
 	var res int
 	err := GetDatabase().DB().QueryRow("SELECT COUNT(id) "+
 		"FROM users "+
@@ -299,17 +394,17 @@ func (r *UserRepository) GetCount(organizationID string) (int, error) {
 	return res, err
 }
 
-func (r *UserRepository) GetHashedPassword(password string) string {
+func (r *userRepositoryBase) GetHashedPassword(password string) string {
 	pwHash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	return string(pwHash)
 }
 
-func (r *UserRepository) CheckPassword(hashedPassword, password string) bool {
+func (r *userRepositoryBase) CheckPassword(hashedPassword, password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 	return err == nil
 }
 
-func (r *UserRepository) mergeUsers(source, target *User) error {
+func (r *PostgresUserRepository) mergeUsers(source, target *User) error {
 	if source.OrganizationID != target.OrganizationID {
 		return errors.New("Organization ID of source and target users don't match")
 	}
@@ -329,32 +424,32 @@ func (r *UserRepository) mergeUsers(source, target *User) error {
 	return nil
 }
 
-func (r *UserRepository) enableUsersWithExpiredBan() error {
+func (r *PostgresUserRepository) enableUsersWithExpiredBan() error {
 	_, err := GetDatabase().DB().Exec("UPDATE users "+
 		"SET disabled = FALSE, ban_expiry = NULL "+
 		"WHERE disabled = TRUE AND ban_expiry <= $1", time.Now())
 	return err
 }
 
-func (r *UserRepository) canCreateUser(org *Organization) bool {
-	maxUsers, _ := GetSettingsRepository().GetInt(org.ID, SettingSubscriptionMaxUsers.Name)
-	curUsers, _ := GetUserRepository().GetCount(org.ID)
+func (r *userRepositoryBase) canCreateUser(org *Organization) bool {
+	maxUsers, _ := r.repos.Settings.GetInt(org.ID, SettingSubscriptionMaxUsers.Name)
+	curUsers, _ := r.repos.User.GetCount(org.ID)
 	return curUsers < maxUsers
 }
 
-func (r *UserRepository) isSpaceAdmin(user *User) bool {
+func (r *userRepositoryBase) isSpaceAdmin(user *User) bool {
 	return int(user.Role) >= int(UserRoleSpaceAdmin)
 }
 
-func (r *UserRepository) isOrgAdmin(user *User) bool {
+func (r *userRepositoryBase) isOrgAdmin(user *User) bool {
 	return int(user.Role) >= int(UserRoleOrgAdmin)
 }
 
-func (r *UserRepository) isSuperAdmin(user *User) bool {
+func (r *userRepositoryBase) isSuperAdmin(user *User) bool {
 	return int(user.Role) >= int(UserRoleSuperAdmin)
 }
 
-func (r *UserRepository) DeleteObsoleteConfluenceAnonymousUsers() (int, error) {
+func (r *PostgresUserRepository) DeleteObsoleteConfluenceAnonymousUsers() (int, error) {
 	timestamp := time.Now().Add(-24 * time.Hour)
 	rows, err := GetDatabase().DB().Query("DELETE FROM users u "+
 		"WHERE u.email LIKE 'confluence-anonymous-%' and "+
@@ -383,7 +478,7 @@ func (r *UserRepository) DeleteObsoleteConfluenceAnonymousUsers() (int, error) {
 	return len(userIDs), nil
 }
 
-func (r *UserRepository) HasAnyUserInOrgPasswordSet(organizationID string) (bool, error) {
+func (r *PostgresUserRepository) HasAnyUserInOrgPasswordSet(organizationID string) (bool, error) {
 	var result int
 	err := GetDatabase().DB().QueryRow("SELECT COUNT(*) FROM users WHERE "+
 		"organization_id = $1 AND password IS NOT NULL AND password != ''", organizationID).Scan(&result)
@@ -15,11 +15,11 @@ func TestUsersCRUD(t *testing.T) {
 		Email:          uuid.New().String() + "@test.com",
 		OrganizationID: "73980078-f4d7-40ff-9211-a7bcbf8d1981",
 	}
-	GetUserRepository().Create(user)
+	testRepositories.User.Create(user)
 	checkStringNotEmpty(t, user.ID)
 
 	// Read
-	user2, err := GetUserRepository().GetOne(user.ID)
+	user2, err := testRepositories.User.GetOne(user.ID)
 	if err != nil {
 		t.Fatalf("Expected non-nil user")
 	}
@@ -31,10 +31,10 @@ func TestUsersCRUD(t *testing.T) {
 		ID:             user.ID,
 		OrganizationID: "61bf23af-0310-4d2b-b401-21c31d60c2c4",
 	}
-	GetUserRepository().Update(user2)
+	testRepositories.User.Update(user2)
 
 	// Read
-	user3, err := GetUserRepository().GetOne(user.ID)
+	user3, err := testRepositories.User.GetOne(user.ID)
 	if err != nil {
 		t.Fatalf("Expected non-nil user")
 	}
@@ -42,8 +42,8 @@ func TestUsersCRUD(t *testing.T) {
 	checkTestString(t, "61bf23af-0310-4d2b-b401-21c31d60c2c4", user3.OrganizationID)
 
 	// Delete
-	GetUserRepository().Delete(user)
-	_, err = GetUserRepository().GetOne(user.ID)
+	testRepositories.User.Delete(user)
+	_, err = testRepositories.User.GetOne(user.ID)
 	if err == nil {
 		t.Fatalf("Expected nil user")
 	}
@@ -55,7 +55,7 @@ func TestUsersCount(t *testing.T) {
 	createTestUserInOrg(org)
 	createTestUserInOrg(org)
 
-	res, err := GetUserRepository().GetCount(org.ID)
+	res, err := testRepositories.User.GetCount(org.ID)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -70,7 +70,7 @@ func TestDeleteObsoleteConfluenceAnonymousUsers(t *testing.T) {
 
 	// Confluence User 1 with recent login (not to be deleted)
 	cu1 := createTestUserInOrgWithName(org, "confluence-anonymous-"+uuid.New().String()+"@test.com", UserRoleUser)
-	GetAuthAttemptRepository().RecordLoginAttempt(cu1, true)
+	testRepositories.AuthAttempt.RecordLoginAttempt(cu1, true)
 
 	// Confluence User 2 without login (to be deleted)
 	createTestUserInOrgWithName(org, "confluence-anonymous-"+uuid.New().String()+"@test.com", UserRoleUser)
@@ -83,7 +83,7 @@ func TestDeleteObsoleteConfluenceAnonymousUsers(t *testing.T) {
 		Timestamp:  time.Now().Add(-26 * time.Hour),
 		Successful: true,
 	}
-	GetAuthAttemptRepository().Create(la)
+	testRepositories.AuthAttempt.Create(la)
 
 	// Confluence User 4 with recent failed login (to be deleted)
 	cu4 := createTestUserInOrgWithName(org, "confluence-anonymous-"+uuid.New().String()+"@test.com", UserRoleUser)
@@ -93,15 +93,15 @@ func TestDeleteObsoleteConfluenceAnonymousUsers(t *testing.T) {
 		Timestamp:  time.Now().Add(-5 * time.Hour),
 		Successful: false,
 	}
-	GetAuthAttemptRepository().Create(la)
+	testRepositories.AuthAttempt.Create(la)
 
-	num, err := GetUserRepository().DeleteObsoleteConfluenceAnonymousUsers()
+	num, err := testRepositories.User.DeleteObsoleteConfluenceAnonymousUsers()
 	if err != nil {
 		t.Fatal(err)
 	}
 	checkTestInt(t, 3, num)
 
-	users, _ := GetUserRepository().GetAll(org.ID, 10000, 0)
+	users, _ := testRepositories.User.GetAll(org.ID, 10000, 0)
 	checkTestInt(t, 3, len(users))
 
 	invalid := false
@@ -10,6 +10,7 @@ import (
 )
 
 type UserRouter struct {
+	repos *Repositories
 }
 
 type CreateUserRequest struct {
@@ -28,6 +29,8 @@ type GetUserResponse struct {
 	SpaceAdmin      bool                    `json:"spaceAdmin"`
 	OrgAdmin        bool                    `json:"admin"`
 	SuperAdmin      bool                    `json:"superAdmin"`
+	DisplayName     string                  `json:"displayName"`
+	ServiceAccount  bool                    `json:"serviceAccount"`
 	CreateUserRequest
 }
 
@@ -50,6 +53,25 @@ type SetPasswordRequest struct {
 	Password string `json:"password"`
 }
 
+type GetTOTPResponse struct {
+	Enabled           bool `json:"enabled"`
+	Required          bool `json:"required"`
+	RecoveryCodesLeft int  `json:"recoveryCodesLeft"`
+}
+
+type CreateTOTPResponse struct {
+	Secret string `json:"secret"`
+	URL    string `json:"url"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type TOTPRecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
 type InitMergeUsersRequest struct {
 	Email string `json:"email"`
 }
@@ -59,10 +81,19 @@ func (router *UserRouter) setupRoutes(s *mux.Router) {
 	s.HandleFunc("/merge/finish/{id}", router.mergeFinish).Methods("POST")
 	s.HandleFunc("/merge", router.getMergeRequests).Methods("GET")
 	s.HandleFunc("/count", router.getCount).Methods("GET")
+	s.HandleFunc("/me/ical", router.getICalToken).Methods("GET")
+	s.HandleFunc("/me/ical", router.createICalToken).Methods("POST")
+	s.HandleFunc("/me/ical", router.deleteICalToken).Methods("DELETE")
+	s.HandleFunc("/me/totp/verify", router.verifyTOTP).Methods("POST")
+	s.HandleFunc("/me/totp/recovery", router.createTOTPRecoveryCodes).Methods("POST")
+	s.HandleFunc("/me/totp", router.getTOTP).Methods("GET")
+	s.HandleFunc("/me/totp", router.createTOTP).Methods("POST")
+	s.HandleFunc("/me/totp", router.deleteTOTP).Methods("DELETE")
 	s.HandleFunc("/me", router.getSelf).Methods("GET")
 	s.HandleFunc("/{id}", router.getOne).Methods("GET")
 	s.HandleFunc("/byEmail/{email}", router.getOneByEmail).Methods("GET")
 	s.HandleFunc("/{id}/password", router.setPassword).Methods("PUT")
+	s.HandleFunc("/{id}/totp", router.resetTOTP).Methods("DELETE")
 	s.HandleFunc("/{id}", router.update).Methods("PUT")
 	s.HandleFunc("/{id}", router.delete).Methods("DELETE")
 	s.HandleFunc("/", router.create).Methods("POST")
@@ -70,8 +101,8 @@ func (router *UserRouter) setupRoutes(s *mux.Router) {
 }
 
 func (router *UserRouter) getMergeRequests(w http.ResponseWriter, r *http.Request) {
-	target := GetRequestUser(r)
-	list, err := GetAuthStateRepository().GetByAuthProviderID(target.ID)
+	target := GetRequestUser(router.repos, r)
+	list, err := router.repos.AuthState.GetByAuthProviderID(target.ID)
 	if err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
@@ -79,7 +110,7 @@ func (router *UserRouter) getMergeRequests(w http.ResponseWriter, r *http.Reques
 	}
 	res := []*GetMergeRequestResponse{}
 	for _, e := range list {
-		source, err := GetUserRepository().GetOne(e.Payload)
+		source, err := router.repos.User.GetOne(e.Payload)
 		if err == nil && source != nil {
 			m := &GetMergeRequestResponse{
 				ID:     e.ID,
@@ -98,8 +129,8 @@ func (router *UserRouter) mergeInit(w http.ResponseWriter, r *http.Request) {
 		SendBadRequest(w)
 		return
 	}
-	source := GetRequestUser(r)
-	target, err := GetUserRepository().GetByEmail(m.Email)
+	source := GetRequestUser(router.repos, r)
+	target, err := router.repos.User.GetByEmail(m.Email)
 	if err != nil || target == nil {
 		SendNotFound(w)
 		return
@@ -110,7 +141,7 @@ func (router *UserRouter) mergeInit(w http.ResponseWriter, r *http.Request) {
 		AuthStateType:  AuthMergeRequest,
 		Payload:        source.ID,
 	}
-	if err := GetAuthStateRepository().Create(authState); err != nil {
+	if err := router.repos.AuthState.Create(authState); err != nil {
 		SendInternalServerError(w)
 		return
 	}
@@ -119,32 +150,33 @@ func (router *UserRouter) mergeInit(w http.ResponseWriter, r *http.Request) {
 
 func (router *UserRouter) mergeFinish(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	target := GetRequestUser(r)
-	authState, err := GetAuthStateRepository().GetOne(vars["id"])
+	target := GetRequestUser(router.repos, r)
+	authState, err := router.repos.AuthState.GetOne(vars["id"])
 	if err != nil || authState == nil || authState.AuthStateType != AuthMergeRequest || authState.AuthProviderID != target.ID {
 		SendNotFound(w)
 		return
 	}
-	source, err := GetUserRepository().GetOne(authState.Payload)
+	source, err := router.repos.User.GetOne(authState.Payload)
 	if err != nil || source == nil {
 		SendBadRequest(w)
 		return
 	}
-	if err := GetUserRepository().mergeUsers(source, target); err != nil {
+	if err := router.repos.User.mergeUsers(source, target); err != nil {
 		SendInternalServerError(w)
 		return
 	}
-	GetAuthStateRepository().Delete(authState)
+	recordAuditEvent(router.repos, r, target.OrganizationID, AuditActionUserMerged, AuditTargetUser, source.ID, router.copyToRestModel(source, true), map[string]string{"mergedIntoId": target.ID, "mergedIntoEmail": target.Email})
+	router.repos.AuthState.Delete(authState)
 	SendUpdated(w)
 }
 
 func (router *UserRouter) getCount(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanAdminOrg(user, user.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
-	num, _ := GetUserRepository().GetCount(user.OrganizationID)
+	num, _ := router.repos.User.GetCount(user.OrganizationID)
 	m := &GetUserCountResponse{
 		Count: num,
 	}
@@ -158,10 +190,10 @@ func (router *UserRouter) setPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	vars := mux.Vars(r)
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	e := user
 	if vars["id"] != "me" {
-		eUser, err := GetUserRepository().GetOne(vars["id"])
+		eUser, err := router.repos.User.GetOne(vars["id"])
 		if err != nil {
 			SendBadRequest(w)
 			return
@@ -172,8 +204,13 @@ func (router *UserRouter) setPassword(w http.ResponseWriter, r *http.Request) {
 		SendForbidden(w)
 		return
 	}
-	e.HashedPassword = NullString(GetUserRepository().GetHashedPassword(m.Password))
-	if err := GetUserRepository().Update(e); err != nil {
+	// Service accounts authenticate with API tokens only
+	if e.ServiceAccount {
+		SendBadRequest(w)
+		return
+	}
+	e.HashedPassword = NullString(router.repos.User.GetHashedPassword(m.Password))
+	if err := router.repos.User.Update(e); err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
@@ -182,12 +219,12 @@ func (router *UserRouter) setPassword(w http.ResponseWriter, r *http.Request) {
 }
 
 func (router *UserRouter) getSelf(w http.ResponseWriter, r *http.Request) {
-	e := GetRequestUser(r)
+	e := GetRequestUser(router.repos, r)
 	if e == nil {
 		SendNotFound(w)
 		return
 	}
-	org, err := GetOrganizationRepository().GetOne(e.OrganizationID)
+	org, err := router.repos.Organization.GetOne(e.OrganizationID)
 	if err != nil {
 		SendInternalServerError(w)
 		return
@@ -202,13 +239,222 @@ func (router *UserRouter) getSelf(w http.ResponseWriter, r *http.Request) {
 	SendJSON(w, res)
 }
 
+func (router *UserRouter) getICalToken(w http.ResponseWriter, r *http.Request) {
+	token, err := router.repos.ICalToken.GetByUser(GetRequestUserID(r))
+	if err != nil {
+		SendNotFound(w)
+		return
+	}
+	icalRouter := &ICalRouter{repos: router.repos}
+	res := &GetICalTokenResponse{
+		Token: token.ID,
+		URL:   icalRouter.getFeedURL(token),
+	}
+	SendJSON(w, res)
+}
+
+// createICalToken creates a new iCalendar feed token, revoking the existing one.
+func (router *UserRouter) createICalToken(w http.ResponseWriter, r *http.Request) {
+	user := GetRequestUser(router.repos, r)
+	if err := router.repos.ICalToken.DeleteOfUser(user); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	token := &ICalToken{
+		UserID:  user.ID,
+		Created: time.Now().UTC(),
+	}
+	if err := router.repos.ICalToken.Create(token); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	SendCreated(w, token.ID)
+}
+
+func (router *UserRouter) deleteICalToken(w http.ResponseWriter, r *http.Request) {
+	if err := router.repos.ICalToken.DeleteOfUser(GetRequestUser(router.repos, r)); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	SendUpdated(w)
+}
+
+func (router *UserRouter) getTOTP(w http.ResponseWriter, r *http.Request) {
+	user := GetRequestUser(router.repos, r)
+	authRouter := &AuthRouter{repos: router.repos}
+	res := &GetTOTPResponse{
+		Enabled:  router.repos.UserTOTP.IsEnabled(user.ID),
+		Required: authRouter.isTOTPRequired(user),
+	}
+	if res.Enabled {
+		count, err := router.repos.UserTOTP.GetRecoveryCodeCount(user.ID)
+		if err != nil {
+			log.Println(err)
+			SendInternalServerError(w)
+			return
+		}
+		res.RecoveryCodesLeft = count
+	}
+	SendJSON(w, res)
+}
+
+// createTOTP starts TOTP enrollment. The secret is only used for logins after it
+// was confirmed with a code. An enabled enrollment must be deleted first.
+func (router *UserRouter) createTOTP(w http.ResponseWriter, r *http.Request) {
+	user := GetRequestUser(router.repos, r)
+	if router.repos.UserTOTP.IsEnabled(user.ID) {
+		SendAleadyExists(w)
+		return
+	}
+	key, err := router.repos.UserTOTP.CreateSecret(user)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	res := &CreateTOTPResponse{
+		Secret: key.Secret(),
+		URL:    key.URL(),
+	}
+	SendJSON(w, res)
+}
+
+// verifyTOTP completes enrollment and returns the recovery codes, which are only shown once.
+func (router *UserRouter) verifyTOTP(w http.ResponseWriter, r *http.Request) {
+	var m TOTPCodeRequest
+	if UnmarshalValidateBody(r, &m) != nil {
+		SendBadRequest(w)
+		return
+	}
+	user := GetRequestUser(router.repos, r)
+	e, err := router.repos.UserTOTP.GetOne(user.ID)
+	if err != nil || e.Enabled {
+		SendNotFound(w)
+		return
+	}
+	valid, err := router.repos.UserTOTP.CheckCode(e, m.Code)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	if !valid {
+		SendBadRequest(w)
+		return
+	}
+	e.Enabled = true
+	if err := router.repos.UserTOTP.Set(e); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	router.sendTOTPRecoveryCodes(w, user)
+}
+
+func (router *UserRouter) createTOTPRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var m TOTPCodeRequest
+	if UnmarshalValidateBody(r, &m) != nil {
+		SendBadRequest(w)
+		return
+	}
+	user := GetRequestUser(router.repos, r)
+	if !router.checkTOTPCode(w, user, m.Code) {
+		return
+	}
+	router.sendTOTPRecoveryCodes(w, user)
+}
+
+func (router *UserRouter) deleteTOTP(w http.ResponseWriter, r *http.Request) {
+	var m TOTPCodeRequest
+	if UnmarshalValidateBody(r, &m) != nil {
+		SendBadRequest(w)
+		return
+	}
+	user := GetRequestUser(router.repos, r)
+	authRouter := &AuthRouter{repos: router.repos}
+	if authRouter.isTOTPRequired(user) {
+		SendForbiddenCode(w, ResponseCodeTOTPEnforced)
+		return
+	}
+	if !router.checkTOTPCode(w, user, m.Code) {
+		return
+	}
+	if err := router.repos.UserTOTP.Delete(user.ID); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	SendUpdated(w)
+}
+
+// resetTOTP lets admins remove the TOTP enrollment of users who lost their device.
+func (router *UserRouter) resetTOTP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	e, err := router.repos.User.GetOne(vars["id"])
+	if err != nil {
+		SendNotFound(w)
+		return
+	}
+	user := GetRequestUser(router.repos, r)
+	if !CanAdminOrg(user, e.OrganizationID) || e.Role > user.Role {
+		SendForbidden(w)
+		return
+	}
+	if err := router.repos.UserTOTP.Delete(e.ID); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	SendUpdated(w)
+}
+
+// checkTOTPCode verifies a TOTP or recovery code of the user's enabled enrollment,
+// sending an error response if it's invalid.
+func (router *UserRouter) checkTOTPCode(w http.ResponseWriter, user *User, code string) bool {
+	e, err := router.repos.UserTOTP.GetOne(user.ID)
+	if err != nil || !e.Enabled {
+		SendNotFound(w)
+		return false
+	}
+	valid, err := router.repos.UserTOTP.CheckCode(e, code)
+	if err == nil && !valid {
+		valid, err = router.repos.UserTOTP.UseRecoveryCode(user.ID, code)
+	}
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return false
+	}
+	if !valid {
+		SendBadRequest(w)
+		return false
+	}
+	return true
+}
+
+func (router *UserRouter) sendTOTPRecoveryCodes(w http.ResponseWriter, user *User) {
+	codes, err := router.repos.UserTOTP.CreateRecoveryCodes(user.ID)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	res := &TOTPRecoveryCodesResponse{
+		RecoveryCodes: codes,
+	}
+	SendJSON(w, res)
+}
+
 func (router *UserRouter) getOneByEmail(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	var showNames bool = false
 	if CanSpaceAdminOrg(user, user.OrganizationID) {
 		showNames = true
 	} else {
-		showNames, _ = GetSettingsRepository().GetBool(user.OrganizationID, SettingShowNames.Name)
+		showNames, _ = router.repos.Settings.GetBool(user.OrganizationID, SettingShowNames.Name)
 	}
 
 	if !showNames {
@@ -217,7 +463,7 @@ func (router *UserRouter) getOneByEmail(w http.ResponseWriter, r *http.Request)
 	}
 
 	vars := mux.Vars(r)
-	e, err := GetUserRepository().GetByEmail(vars["email"])
+	e, err := router.repos.User.GetByEmail(vars["email"])
 
 	if err != nil || e.ID == user.ID {
 		log.Println(err)
@@ -233,13 +479,13 @@ func (router *UserRouter) getOneByEmail(w http.ResponseWriter, r *http.Request)
 }
 
 func (router *UserRouter) getOne(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanAdminOrg(user, user.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
 	vars := mux.Vars(r)
-	e, err := GetUserRepository().GetOne(vars["id"])
+	e, err := router.repos.User.GetOne(vars["id"])
 	if err != nil {
 		log.Println(err)
 		SendNotFound(w)
@@ -255,7 +501,7 @@ func (router *UserRouter) getOne(w http.ResponseWriter, r *http.Request) {
 
 func (router *UserRouter) getAll(w http.ResponseWriter, r *http.Request) {
 	search := r.URL.Query().Get("q")
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanSpaceAdminOrg(user, user.OrganizationID) {
 		SendForbidden(w)
 		return
@@ -263,9 +509,9 @@ func (router *UserRouter) getAll(w http.ResponseWriter, r *http.Request) {
 	var list []*User
 	var err error
 	if strings.TrimSpace(search) != "" {
-		list, err = GetUserRepository().GetByKeyword(user.OrganizationID, strings.TrimSpace(search))
+		list, err = router.repos.User.GetByKeyword(user.OrganizationID, strings.TrimSpace(search))
 	} else {
-		list, err = GetUserRepository().GetAll(user.OrganizationID, 1000, 0)
+		list, err = router.repos.User.GetAll(user.OrganizationID, 1000, 0)
 	}
 	if err != nil {
 		log.Println(err)
@@ -287,12 +533,12 @@ func (router *UserRouter) update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	vars := mux.Vars(r)
-	e, err := GetUserRepository().GetOne(vars["id"])
+	e, err := router.repos.User.GetOne(vars["id"])
 	if err != nil {
 		SendBadRequest(w)
 		return
 	}
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanAdminOrg(user, e.OrganizationID) {
 		SendForbidden(w)
 		return
@@ -304,46 +550,51 @@ func (router *UserRouter) update(w http.ResponseWriter, r *http.Request) {
 	}
 	eNew.OrganizationID = e.OrganizationID
 	eNew.HashedPassword = e.HashedPassword
-	org, err := GetOrganizationRepository().GetOne(e.OrganizationID)
+	eNew.DisplayName = e.DisplayName
+	eNew.ServiceAccount = e.ServiceAccount
+	org, err := router.repos.Organization.GetOne(e.OrganizationID)
 	if err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
 	}
-	if !GetOrganizationRepository().isValidEmailForOrg(user.Email, org) {
+	if !router.repos.Organization.isValidEmailForOrg(user.Email, org) {
 		SendBadRequest(w)
 		return
 	}
-	if err := GetUserRepository().Update(eNew); err != nil {
+	if err := router.repos.User.Update(eNew); err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
 	}
+	recordAuditEvent(router.repos, r, e.OrganizationID, AuditActionUserUpdated, AuditTargetUser, e.ID, router.copyToRestModel(e, true), router.copyToRestModel(eNew, true))
 	SendUpdated(w)
 }
 
 func (router *UserRouter) delete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	e, err := GetUserRepository().GetOne(vars["id"])
+	e, err := router.repos.User.GetOne(vars["id"])
 	if err != nil {
 		SendNotFound(w)
 		return
 	}
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanAdminOrg(user, e.OrganizationID) {
 		SendForbidden(w)
 		return
 	}
-	if err := GetUserRepository().Delete(e); err != nil {
+	if err := router.repos.User.Delete(e); err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
 	}
+	recordAuditEvent(router.repos, r, e.OrganizationID, AuditActionUserDeleted, AuditTargetUser, e.ID, router.copyToRestModel(e, true), nil)
+	fireWebhookEvent(router.repos, e.OrganizationID, WebhookEventUserDeleted, router.copyToRestModel(e, true))
 	SendUpdated(w)
 }
 
 func (router *UserRouter) create(w http.ResponseWriter, r *http.Request) {
-	user := GetRequestUser(r)
+	user := GetRequestUser(router.repos, r)
 	if !CanAdminOrg(user, user.OrganizationID) {
 		SendForbidden(w)
 		return
@@ -353,36 +604,38 @@ func (router *UserRouter) create(w http.ResponseWriter, r *http.Request) {
 		SendBadRequest(w)
 		return
 	}
-	if m.OrganizationID != "" && m.OrganizationID != user.OrganizationID && !GetUserRepository().isSuperAdmin(user) {
+	if m.OrganizationID != "" && m.OrganizationID != user.OrganizationID && !router.repos.User.isSuperAdmin(user) {
 		SendForbidden(w)
 		return
 	}
 	e := router.copyFromRestModel(&m)
-	if e.OrganizationID == "" || !GetUserRepository().isSuperAdmin(user) {
+	if e.OrganizationID == "" || !router.repos.User.isSuperAdmin(user) {
 		e.OrganizationID = user.OrganizationID
 	}
 	if e.Role > user.Role {
 		e.Role = UserRoleUser
 	}
-	org, err := GetOrganizationRepository().GetOne(e.OrganizationID)
+	org, err := router.repos.Organization.GetOne(e.OrganizationID)
 	if err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
 	}
-	if !GetUserRepository().canCreateUser(org) {
+	if !router.repos.User.canCreateUser(org) {
 		SendPaymentRequired(w)
 		return
 	}
-	if !GetOrganizationRepository().isValidEmailForOrg(e.Email, org) {
+	if !router.repos.Organization.isValidEmailForOrg(e.Email, org) {
 		SendBadRequest(w)
 		return
 	}
-	if err := GetUserRepository().Create(e); err != nil {
+	if err := router.repos.User.Create(e); err != nil {
 		log.Println(err)
 		SendInternalServerError(w)
 		return
 	}
+	recordAuditEvent(router.repos, r, e.OrganizationID, AuditActionUserCreated, AuditTargetUser, e.ID, nil, router.copyToRestModel(e, true))
+	fireWebhookEvent(router.repos, e.OrganizationID, WebhookEventUserCreated, router.copyToRestModel(e, true))
 	SendCreated(w, e.ID)
 }
 
@@ -391,7 +644,7 @@ func (router *UserRouter) copyFromRestModel(m *CreateUserRequest) *User {
 	e.Email = m.Email
 	e.Role = UserRole(m.Role)
 	if m.Password != "" {
-		e.HashedPassword = NullString(GetUserRepository().GetHashedPassword(m.Password))
+		e.HashedPassword = NullString(router.repos.User.GetHashedPassword(m.Password))
 		e.AuthProviderID = NullString("")
 	} else {
 		e.AuthProviderID = NullString(m.AuthProviderID)
@@ -405,12 +658,14 @@ func (router *UserRouter) copyToRestModel(e *User, admin bool) *GetUserResponse
 	m.ID = e.ID
 	m.OrganizationID = e.OrganizationID
 	m.Email = e.Email
+	m.DisplayName = e.DisplayName
 	m.AtlassianID = string(e.AtlassianID)
 	m.Role = int(e.Role)
-	m.SpaceAdmin = GetUserRepository().isSpaceAdmin(e)
-	m.OrgAdmin = GetUserRepository().isOrgAdmin(e)
-	m.SuperAdmin = GetUserRepository().isSuperAdmin(e)
+	m.SpaceAdmin = router.repos.User.isSpaceAdmin(e)
+	m.OrgAdmin = router.repos.User.isOrgAdmin(e)
+	m.SuperAdmin = router.repos.User.isSuperAdmin(e)
 	m.RequirePassword = (e.HashedPassword != "")
+	m.ServiceAccount = e.ServiceAccount
 	if admin {
 		m.AuthProviderID = string(e.AuthProviderID)
 	}
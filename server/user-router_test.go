@@ -112,11 +112,11 @@ func TestUserSetPassword(t *testing.T) {
 	res := executeTestRequest(req)
 	checkTestResponseCode(t, http.StatusNoContent, res.Code)
 
-	user2, err := GetUserRepository().GetOne(user.ID)
+	user2, err := testRepositories.User.GetOne(user.ID)
 	if err != nil {
 		t.Fatal(err)
 	}
-	checkTestBool(t, true, GetUserRepository().CheckPassword(string(user2.HashedPassword), "12345678"))
+	checkTestBool(t, true, testRepositories.User.CheckPassword(string(user2.HashedPassword), "12345678"))
 }
 
 func TestUserSubscriptionExceeded(t *testing.T) {
@@ -125,7 +125,7 @@ func TestUserSubscriptionExceeded(t *testing.T) {
 	user := createTestUserOrgAdmin(org)
 	loginResponse := loginTestUser(user.ID)
 
-	GetSettingsRepository().Set(org.ID, SettingSubscriptionMaxUsers.Name, "1")
+	testRepositories.Settings.Set(org.ID, SettingSubscriptionMaxUsers.Name, "1")
 
 	username := uuid.New().String() + "@test.com"
 	payload := "{\"email\": \"" + username + "\", \"password\": \"12345678\"}"
@@ -156,7 +156,7 @@ func TestUserMergeUsers(t *testing.T) {
 
 	// Prepare source
 	source.AtlassianID = NullString(source.Email)
-	GetUserRepository().Update(source)
+	testRepositories.User.Update(source)
 
 	// Init from source
 	loginResponseSource := loginTestUser(source.ID)
@@ -182,13 +182,13 @@ func TestUserMergeUsers(t *testing.T) {
 	checkTestResponseCode(t, http.StatusNoContent, res.Code)
 
 	// Check if source user is gone
-	user, err := GetUserRepository().GetOne(source.ID)
+	user, err := testRepositories.User.GetOne(source.ID)
 	if err == nil || user != nil {
 		t.Fatal("Expected source user to be deleted")
 	}
 
 	// Check if target user has inherited source user's properties
-	user, err = GetUserRepository().GetOne(target.ID)
+	user, err = testRepositories.User.GetOne(target.ID)
 	if err != nil || user == nil {
 		t.Fatal("Expected source user to be deleted")
 	}
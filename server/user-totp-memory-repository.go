@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+type MemoryUserTOTPRepository struct {
+	userTOTPRepositoryBase
+}
+
+type memoryRecoveryCode struct {
+	UserID   string
+	CodeHash string
+}
+
+func (r *MemoryUserTOTPRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryUserTOTPRepository) Set(e *UserTOTP) error {
+	if err := memoryCheckUUID(e.UserID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	row := *e
+	row.Created = memoryTimestamp(e.Created)
+	memoryData.userTOTP = memoryDelete(memoryData.userTOTP, func(existing *UserTOTP) bool {
+		return existing.UserID == e.UserID
+	})
+	memoryData.userTOTP = append(memoryData.userTOTP, &row)
+	return nil
+}
+
+func (r *MemoryUserTOTPRepository) GetOne(userID string) (*UserTOTP, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryGet(memoryData.userTOTP, func(e *UserTOTP) bool {
+		return e.UserID == userID
+	})
+}
+
+func (r *MemoryUserTOTPRepository) Delete(userID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.userTOTPRecoveryCodes = memoryDelete(memoryData.userTOTPRecoveryCodes, func(row *memoryRecoveryCode) bool {
+		return row.UserID == userID
+	})
+	memoryData.userTOTP = memoryDelete(memoryData.userTOTP, func(row *UserTOTP) bool {
+		return row.UserID == userID
+	})
+	return nil
+}
+
+func (r *MemoryUserTOTPRepository) DeleteAll(organizationID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	inOrg := func(userID string) bool {
+		user := memoryUserByID(userID)
+		return user != nil && user.OrganizationID == organizationID
+	}
+	memoryData.userTOTPRecoveryCodes = memoryDelete(memoryData.userTOTPRecoveryCodes, func(row *memoryRecoveryCode) bool {
+		return inOrg(row.UserID)
+	})
+	memoryData.userTOTP = memoryDelete(memoryData.userTOTP, func(row *UserTOTP) bool {
+		return inOrg(row.UserID)
+	})
+	return nil
+}
+
+func (r *MemoryUserTOTPRepository) CheckCode(e *UserTOTP, code string) (bool, error) {
+	counter, ok, err := r.matchCode(e, code)
+	if err != nil || !ok {
+		return false, err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	row, err := memoryFind(memoryData.userTOTP, func(row *UserTOTP) bool {
+		return row.UserID == e.UserID
+	})
+	if err != nil || row.LastCounter >= counter {
+		return false, nil
+	}
+	row.LastCounter = counter
+	e.LastCounter = counter
+	return true, nil
+}
+
+func (r *MemoryUserTOTPRepository) CreateRecoveryCodes(userID string) ([]string, error) {
+	if err := memoryCheckUUID(userID); err != nil {
+		return nil, err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.userTOTPRecoveryCodes = memoryDelete(memoryData.userTOTPRecoveryCodes, func(row *memoryRecoveryCode) bool {
+		return row.UserID == userID
+	})
+	codes := []string{}
+	for i := 0; i < TOTPNumRecoveryCodes; i++ {
+		b := make([]byte, TOTPRecoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := hex.EncodeToString(b)
+		memoryData.userTOTPRecoveryCodes = append(memoryData.userTOTPRecoveryCodes, &memoryRecoveryCode{
+			UserID:   userID,
+			CodeHash: r.getRecoveryCodeHash(code),
+		})
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+func (r *MemoryUserTOTPRepository) UseRecoveryCode(userID, code string) (bool, error) {
+	hash := r.getRecoveryCodeHash(code)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	n := len(memoryData.userTOTPRecoveryCodes)
+	memoryData.userTOTPRecoveryCodes = memoryDelete(memoryData.userTOTPRecoveryCodes, func(row *memoryRecoveryCode) bool {
+		return row.UserID == userID && row.CodeHash == hash
+	})
+	return len(memoryData.userTOTPRecoveryCodes) < n, nil
+}
+
+func (r *MemoryUserTOTPRepository) GetRecoveryCodeCount(userID string) (int, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryCount(memoryData.userTOTPRecoveryCodes, func(row *memoryRecoveryCode) bool {
+		return row.UserID == userID
+	}), nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+	"github.com/pquerna/otp/totp"
+)
+
+type UserTOTPRepository interface {
+	Repository
+	Set(e *UserTOTP) error
+	GetOne(userID string) (*UserTOTP, error)
+	IsEnabled(userID string) bool
+	Delete(userID string) error
+	DeleteAll(organizationID string) error
+	CreateSecret(user *User) (*otp.Key, error)
+	CheckCode(e *UserTOTP, code string) (bool, error)
+	CreateRecoveryCodes(userID string) ([]string, error)
+	UseRecoveryCode(userID, code string) (bool, error)
+	GetRecoveryCodeCount(userID string) (int, error)
+}
+
+type PostgresUserTOTPRepository struct {
+	userTOTPRepositoryBase
+}
+
+// userTOTPRepositoryBase generates and verifies codes. Only storing them differs between implementations.
+type userTOTPRepositoryBase struct {
+	repos *Repositories
+}
+
+// UserTOTP is a user's TOTP enrollment. The secret is stored when enrollment
+// starts, but only checked at login once the user confirmed a code and Enabled is set.
+// LastCounter is the time step of the last accepted code, so a code can't be used twice.
+type UserTOTP struct {
+	UserID      string
+	Secret      string
+	Enabled     bool
+	Created     time.Time
+	LastCounter int64
+}
+
+const (
+	TOTPIssuer            string = "Seatsurfing"
+	TOTPPeriod            uint   = 30
+	TOTPSkew              int64  = 1
+	TOTPNumRecoveryCodes  int    = 10
+	TOTPRecoveryCodeBytes int    = 5
+	// TOTPMaxFailedAttempts is the number of wrong codes after which a login challenge
+	// is invalidated, so the password must be entered again
+	TOTPMaxFailedAttempts int           = 3
+	TOTPChallengeLifetime time.Duration = time.Minute * 5
+)
+
+func (r *PostgresUserTOTPRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		{
+			Version:     30,
+			Description: "Add two-factor authentication of users",
+			Up: []string{
+				"CREATE TABLE IF NOT EXISTS users_totp (" +
+					"user_id uuid NOT NULL, " +
+					"secret VARCHAR NOT NULL, " +
+					"enabled boolean NOT NULL DEFAULT FALSE, " +
+					"created TIMESTAMP NOT NULL, " +
+					"last_counter BIGINT NOT NULL DEFAULT 0, " +
+					"PRIMARY KEY (user_id))",
+				"CREATE TABLE IF NOT EXISTS users_totp_recovery_codes (" +
+					"user_id uuid NOT NULL, " +
+					"code_hash VARCHAR NOT NULL, " +
+					"PRIMARY KEY (user_id, code_hash))",
+			},
+			Down: []string{
+				"DROP TABLE IF EXISTS users_totp_recovery_codes",
+				"DROP TABLE IF EXISTS users_totp",
+			},
+		},
+	}
+}
+
+func (r *PostgresUserTOTPRepository) Set(e *UserTOTP) error {
+	_, err := GetDatabase().DB().Exec("INSERT INTO users_totp "+
+		"(user_id, secret, enabled, created, last_counter) "+
+		"VALUES ($1, $2, $3, $4, $5) "+
+		"ON CONFLICT (user_id) DO UPDATE SET secret = $2, enabled = $3, created = $4, last_counter = $5",
+		e.UserID, e.Secret, e.Enabled, e.Created, e.LastCounter)
+	return err
+}
+
+func (r *PostgresUserTOTPRepository) GetOne(userID string) (*UserTOTP, error) {
+	e := &UserTOTP{}
+	err := GetDatabase().DB().QueryRow("SELECT user_id, secret, enabled, created, last_counter "+
+		"FROM users_totp "+
+		"WHERE user_id = $1",
+		userID).Scan(&e.UserID, &e.Secret, &e.Enabled, &e.Created, &e.LastCounter)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// IsEnabled returns true if the user completed TOTP enrollment.
+func (r *userTOTPRepositoryBase) IsEnabled(userID string) bool {
+	e, err := r.repos.UserTOTP.GetOne(userID)
+	return err == nil && e.Enabled
+}
+
+func (r *PostgresUserTOTPRepository) Delete(userID string) error {
+	if _, err := GetDatabase().DB().Exec("DELETE FROM users_totp_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	_, err := GetDatabase().DB().Exec("DELETE FROM users_totp WHERE user_id = $1", userID)
+	return err
+}
+
+func (r *PostgresUserTOTPRepository) DeleteAll(organizationID string) error {
+	if _, err := GetDatabase().DB().Exec("DELETE FROM users_totp_recovery_codes WHERE user_id IN "+
+		"(SELECT id FROM users WHERE organization_id = $1)", organizationID); err != nil {
+		return err
+	}
+	_, err := GetDatabase().DB().Exec("DELETE FROM users_totp WHERE user_id IN "+
+		"(SELECT id FROM users WHERE organization_id = $1)", organizationID)
+	return err
+}
+
+// CreateSecret starts a new, not yet enabled enrollment for the user, replacing
+// any previous one. The returned key holds the secret and the otpauth:// URL for authenticator apps.
+func (r *userTOTPRepositoryBase) CreateSecret(user *User) (*otp.Key, error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      TOTPIssuer,
+		AccountName: user.Email,
+		Period:      TOTPPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := r.repos.UserTOTP.Delete(user.ID); err != nil {
+		return nil, err
+	}
+	e := &UserTOTP{
+		UserID:  user.ID,
+		Secret:  key.Secret(),
+		Enabled: false,
+		Created: time.Now(),
+	}
+	if err := r.repos.UserTOTP.Set(e); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// CheckCode validates a code against the user's secret, allowing one time step of
+// clock skew. Accepted codes can't be used again.
+func (r *PostgresUserTOTPRepository) CheckCode(e *UserTOTP, code string) (bool, error) {
+	counter, ok, err := r.matchCode(e, code)
+	if err != nil || !ok {
+		return false, err
+	}
+	res, err := GetDatabase().DB().Exec("UPDATE users_totp SET last_counter = $1 "+
+		"WHERE user_id = $2 AND last_counter < $1", counter, e.UserID)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	e.LastCounter = counter
+	return true, nil
+}
+
+// CreateRecoveryCodes replaces the user's recovery codes. Only their hashes are
+// stored, the codes are returned to be shown to the user once.
+func (r *PostgresUserTOTPRepository) CreateRecoveryCodes(userID string) ([]string, error) {
+	if _, err := GetDatabase().DB().Exec("DELETE FROM users_totp_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return nil, err
+	}
+	codes := []string{}
+	for i := 0; i < TOTPNumRecoveryCodes; i++ {
+		b := make([]byte, TOTPRecoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := hex.EncodeToString(b)
+		if _, err := GetDatabase().DB().Exec("INSERT INTO users_totp_recovery_codes (user_id, code_hash) VALUES ($1, $2)",
+			userID, r.getRecoveryCodeHash(code)); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// UseRecoveryCode consumes the recovery code, returning false if it is unknown.
+func (r *PostgresUserTOTPRepository) UseRecoveryCode(userID, code string) (bool, error) {
+	res, err := GetDatabase().DB().Exec("DELETE FROM users_totp_recovery_codes WHERE user_id = $1 AND code_hash = $2",
+		userID, r.getRecoveryCodeHash(code))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (r *PostgresUserTOTPRepository) GetRecoveryCodeCount(userID string) (int, error) {
+	var res int
+	err := GetDatabase().DB().QueryRow("SELECT COUNT(*) FROM users_totp_recovery_codes WHERE user_id = $1", userID).Scan(&res)
+	return res, err
+}
+
+// matchCode returns the time step matching the code within the allowed skew. Steps up to
+// LastCounter are skipped, so a code can't be used twice.
+func (r *userTOTPRepositoryBase) matchCode(e *UserTOTP, code string) (int64, bool, error) {
+	code = strings.TrimSpace(code)
+	now := time.Now().Unix() / int64(TOTPPeriod)
+	for counter := now - TOTPSkew; counter <= now+TOTPSkew; counter++ {
+		if counter <= e.LastCounter {
+			continue
+		}
+		expected, err := hotp.GenerateCodeCustom(e.Secret, uint64(counter), hotp.ValidateOpts{
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, false, err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return counter, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+func (r *userTOTPRepositoryBase) getRecoveryCodeHash(code string) string {
+	hash := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code))))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"database/sql"
+	"time"
+)
+
+type MemoryWaitlistRepository struct {
+}
+
+func (r *MemoryWaitlistRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryWaitlistRepository) Create(e *WaitlistEntry) error {
+	if err := memoryCheckUUID(e.UserID, e.LocationID); err != nil {
+		return err
+	}
+	if e.SpaceID != "" {
+		if err := memoryCheckUUID(string(e.SpaceID)); err != nil {
+			return err
+		}
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e.Created = time.Now().UTC()
+	e.ID = memoryNewID()
+	row := r.copy(e)
+	row.OfferedSpaceID = ""
+	row.OfferExpiry = nil
+	memoryData.waitlistEntries = append(memoryData.waitlistEntries, row)
+	return nil
+}
+
+func (r *MemoryWaitlistRepository) GetOne(id string) (*WaitlistEntryDetails, error) {
+	result := r.getAll(func(e *WaitlistEntry) bool {
+		return e.ID == id
+	}, nil)
+	if len(result) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return result[0], nil
+}
+
+func (r *MemoryWaitlistRepository) GetAllByUser(userID string) ([]*WaitlistEntryDetails, error) {
+	return r.getAll(func(e *WaitlistEntry) bool {
+		return e.UserID == userID
+	}, func(a, b *WaitlistEntryDetails) bool {
+		return a.Enter.Before(b.Enter)
+	}), nil
+}
+
+func (r *MemoryWaitlistRepository) GetAllWaitingForSpace(space *Space) ([]*WaitlistEntryDetails, error) {
+	return r.getAll(func(e *WaitlistEntry) bool {
+		return e.OfferedSpaceID == "" &&
+			(string(e.SpaceID) == space.ID || (e.SpaceID == "" && e.LocationID == space.LocationID))
+	}, func(a, b *WaitlistEntryDetails) bool {
+		return a.Created.Before(b.Created)
+	}), nil
+}
+
+func (r *MemoryWaitlistRepository) GetExpiredOffers(now time.Time) ([]*WaitlistEntryDetails, error) {
+	now = memoryTimestamp(now)
+	return r.getAll(func(e *WaitlistEntry) bool {
+		return e.OfferExpiry != nil && e.OfferExpiry.Before(now)
+	}, func(a, b *WaitlistEntryDetails) bool {
+		return a.OfferExpiry.Before(*b.OfferExpiry)
+	}), nil
+}
+
+// getAll returns the matching entries joined with their users' email addresses.
+func (r *MemoryWaitlistRepository) getAll(cond func(*WaitlistEntry) bool, less func(a, b *WaitlistEntryDetails) bool) []*WaitlistEntryDetails {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	var result []*WaitlistEntryDetails
+	for _, e := range memoryData.waitlistEntries {
+		if !cond(e) {
+			continue
+		}
+		user := memoryUserByID(e.UserID)
+		if user == nil {
+			continue
+		}
+		result = append(result, &WaitlistEntryDetails{
+			UserEmail:     user.Email,
+			WaitlistEntry: *r.copy(e),
+		})
+	}
+	if less != nil {
+		memorySort(result, less)
+	}
+	return result
+}
+
+func (r *MemoryWaitlistRepository) HasPendingOffer(spaceID string, enter time.Time, leave time.Time, excludeUserID string) (bool, error) {
+	now := memoryTimestamp(time.Now().UTC())
+	enter, leave = memoryTimestamp(enter), memoryTimestamp(leave)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	return memoryCount(memoryData.waitlistEntries, func(e *WaitlistEntry) bool {
+		return string(e.OfferedSpaceID) == spaceID && e.UserID != excludeUserID &&
+			e.OfferExpiry != nil && !e.OfferExpiry.Before(now) &&
+			e.Enter.Before(leave) && e.Leave.After(enter)
+	}) > 0, nil
+}
+
+func (r *MemoryWaitlistRepository) SetOffer(e *WaitlistEntry, spaceID string, expiry time.Time) error {
+	if err := memoryCheckUUID(spaceID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	for _, row := range memoryData.waitlistEntries {
+		if row.ID == e.ID {
+			row.OfferedSpaceID = NullString(spaceID)
+			row.OfferExpiry = memoryNullTimestamp(&expiry)
+		}
+	}
+	e.OfferedSpaceID = NullString(spaceID)
+	e.OfferExpiry = &expiry
+	return nil
+}
+
+func (r *MemoryWaitlistRepository) Delete(e *WaitlistEntry) error {
+	return r.delete(func(row *WaitlistEntry) bool {
+		return row.ID == e.ID
+	})
+}
+
+func (r *MemoryWaitlistRepository) DeleteExpired(leaveBefore time.Time) error {
+	leaveBefore = memoryTimestamp(leaveBefore)
+	return r.delete(func(row *WaitlistEntry) bool {
+		return row.Leave.Before(leaveBefore)
+	})
+}
+
+func (r *MemoryWaitlistRepository) DeleteOfUser(e *User) error {
+	return r.delete(func(row *WaitlistEntry) bool {
+		return row.UserID == e.ID
+	})
+}
+
+func (r *MemoryWaitlistRepository) DeleteOfSpace(e *Space) error {
+	return r.delete(func(row *WaitlistEntry) bool {
+		return string(row.SpaceID) == e.ID || string(row.OfferedSpaceID) == e.ID
+	})
+}
+
+func (r *MemoryWaitlistRepository) delete(cond func(*WaitlistEntry) bool) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.waitlistEntries = memoryDelete(memoryData.waitlistEntries, cond)
+	return nil
+}
+
+func (r *MemoryWaitlistRepository) copy(e *WaitlistEntry) *WaitlistEntry {
+	c := *e
+	c.Enter = memoryTimestamp(e.Enter)
+	c.Leave = memoryTimestamp(e.Leave)
+	c.Created = memoryTimestamp(e.Created)
+	c.OfferExpiry = memoryNullTimestamp(e.OfferExpiry)
+	return &c
+}
@@ -0,0 +1,187 @@
+package main
+
+import "time"
+
+type WaitlistRepository interface {
+	Repository
+	Create(e *WaitlistEntry) error
+	GetOne(id string) (*WaitlistEntryDetails, error)
+	GetAllByUser(userID string) ([]*WaitlistEntryDetails, error)
+	GetAllWaitingForSpace(space *Space) ([]*WaitlistEntryDetails, error)
+	GetExpiredOffers(now time.Time) ([]*WaitlistEntryDetails, error)
+	HasPendingOffer(spaceID string, enter time.Time, leave time.Time, excludeUserID string) (bool, error)
+	SetOffer(e *WaitlistEntry, spaceID string, expiry time.Time) error
+	Delete(e *WaitlistEntry) error
+	DeleteExpired(leaveBefore time.Time) error
+	DeleteOfUser(e *User) error
+	DeleteOfSpace(e *Space) error
+}
+
+type PostgresWaitlistRepository struct {
+}
+
+// WaitlistEntry is a user waiting for a space to become available. If SpaceID is
+// empty, the user waits for any space in the location. Enter and Leave are local
+// wall clock times of the location like booking times. If the organization gives
+// users time to accept, OfferedSpaceID is held for the user until OfferExpiry,
+// which is in UTC.
+type WaitlistEntry struct {
+	ID             string
+	UserID         string
+	LocationID     string
+	SpaceID        NullString
+	Enter          time.Time
+	Leave          time.Time
+	Created        time.Time
+	OfferedSpaceID NullString
+	OfferExpiry    *time.Time
+}
+
+type WaitlistEntryDetails struct {
+	UserEmail string
+	WaitlistEntry
+}
+
+func (r *PostgresWaitlistRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		{
+			Version:     33,
+			Description: "Add waitlist",
+			Up: []string{
+				"CREATE TABLE IF NOT EXISTS waitlist_entries (" +
+					"id uuid DEFAULT uuid_generate_v4(), " +
+					"user_id uuid NOT NULL, " +
+					"location_id uuid NOT NULL, " +
+					"space_id uuid NULL, " +
+					"enter_time TIMESTAMP NOT NULL, " +
+					"leave_time TIMESTAMP NOT NULL, " +
+					"created TIMESTAMP NOT NULL, " +
+					"offered_space_id uuid NULL, " +
+					"offer_expiry TIMESTAMP NULL, " +
+					"PRIMARY KEY (id))",
+				"CREATE INDEX IF NOT EXISTS idx_waitlist_entries_user_id ON waitlist_entries(user_id)",
+				"CREATE INDEX IF NOT EXISTS idx_waitlist_entries_location_id ON waitlist_entries(location_id)",
+			},
+			Down: []string{"DROP TABLE IF EXISTS waitlist_entries"},
+		},
+	}
+}
+
+func (r *PostgresWaitlistRepository) Create(e *WaitlistEntry) error {
+	var id string
+	e.Created = time.Now().UTC()
+	err := GetDatabase().DB().QueryRow("INSERT INTO waitlist_entries "+
+		"(user_id, location_id, space_id, enter_time, leave_time, created) "+
+		"VALUES ($1, $2, $3, $4, $5, $6) "+
+		"RETURNING id",
+		e.UserID, e.LocationID, CheckNullString(e.SpaceID), e.Enter, e.Leave, e.Created).Scan(&id)
+	if err != nil {
+		return err
+	}
+	e.ID = id
+	return nil
+}
+
+func (r *PostgresWaitlistRepository) GetOne(id string) (*WaitlistEntryDetails, error) {
+	e := &WaitlistEntryDetails{}
+	err := GetDatabase().DB().QueryRow("SELECT waitlist_entries.id, waitlist_entries.user_id, waitlist_entries.location_id, waitlist_entries.space_id, "+
+		"waitlist_entries.enter_time, waitlist_entries.leave_time, waitlist_entries.created, waitlist_entries.offered_space_id, waitlist_entries.offer_expiry, "+
+		"users.email "+
+		"FROM waitlist_entries "+
+		"INNER JOIN users ON users.id = waitlist_entries.user_id "+
+		"WHERE waitlist_entries.id = $1",
+		id).Scan(&e.ID, &e.UserID, &e.LocationID, &e.SpaceID, &e.Enter, &e.Leave, &e.Created, &e.OfferedSpaceID, &e.OfferExpiry, &e.UserEmail)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (r *PostgresWaitlistRepository) GetAllByUser(userID string) ([]*WaitlistEntryDetails, error) {
+	return r.getAll("WHERE waitlist_entries.user_id = $1 "+
+		"ORDER BY waitlist_entries.enter_time", userID)
+}
+
+// GetAllWaitingForSpace returns the entries without a pending offer that wait for the
+// space or any space in its location, first come first served.
+func (r *PostgresWaitlistRepository) GetAllWaitingForSpace(space *Space) ([]*WaitlistEntryDetails, error) {
+	return r.getAll("WHERE waitlist_entries.offered_space_id IS NULL AND "+
+		"(waitlist_entries.space_id = $1 OR (waitlist_entries.space_id IS NULL AND waitlist_entries.location_id = $2)) "+
+		"ORDER BY waitlist_entries.created", space.ID, space.LocationID)
+}
+
+// GetExpiredOffers returns the entries with an offer which has not been accepted in time.
+func (r *PostgresWaitlistRepository) GetExpiredOffers(now time.Time) ([]*WaitlistEntryDetails, error) {
+	return r.getAll("WHERE waitlist_entries.offer_expiry IS NOT NULL AND waitlist_entries.offer_expiry < $1 "+
+		"ORDER BY waitlist_entries.offer_expiry", now)
+}
+
+func (r *PostgresWaitlistRepository) getAll(condition string, args ...interface{}) ([]*WaitlistEntryDetails, error) {
+	var result []*WaitlistEntryDetails
+	rows, err := GetDatabase().DB().Query("SELECT waitlist_entries.id, waitlist_entries.user_id, waitlist_entries.location_id, waitlist_entries.space_id, "+
+		"waitlist_entries.enter_time, waitlist_entries.leave_time, waitlist_entries.created, waitlist_entries.offered_space_id, waitlist_entries.offer_expiry, "+
+		"users.email "+
+		"FROM waitlist_entries "+
+		"INNER JOIN users ON users.id = waitlist_entries.user_id "+
+		condition, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &WaitlistEntryDetails{}
+		err = rows.Scan(&e.ID, &e.UserID, &e.LocationID, &e.SpaceID, &e.Enter, &e.Leave, &e.Created, &e.OfferedSpaceID, &e.OfferExpiry, &e.UserEmail)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+// HasPendingOffer returns true if the space is held for a user other than the
+// specified one within the specified enter and leave times.
+func (r *PostgresWaitlistRepository) HasPendingOffer(spaceID string, enter time.Time, leave time.Time, excludeUserID string) (bool, error) {
+	var res int
+	err := GetDatabase().DB().QueryRow("SELECT COUNT(id) "+
+		"FROM waitlist_entries "+
+		"WHERE offered_space_id = $1 AND user_id::text != $2 AND offer_expiry >= $3 AND "+
+		"enter_time < $5 AND leave_time > $4",
+		spaceID, excludeUserID, time.Now().UTC(), enter, leave).Scan(&res)
+	return res > 0, err
+}
+
+func (r *PostgresWaitlistRepository) SetOffer(e *WaitlistEntry, spaceID string, expiry time.Time) error {
+	_, err := GetDatabase().DB().Exec("UPDATE waitlist_entries SET "+
+		"offered_space_id = $1, "+
+		"offer_expiry = $2 "+
+		"WHERE id = $3",
+		spaceID, expiry, e.ID)
+	if err != nil {
+		return err
+	}
+	e.OfferedSpaceID = NullString(spaceID)
+	e.OfferExpiry = &expiry
+	return nil
+}
+
+func (r *PostgresWaitlistRepository) Delete(e *WaitlistEntry) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM waitlist_entries WHERE id = $1", e.ID)
+	return err
+}
+
+// DeleteExpired deletes entries which have ended before the specified time.
+func (r *PostgresWaitlistRepository) DeleteExpired(leaveBefore time.Time) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM waitlist_entries WHERE leave_time < $1", leaveBefore)
+	return err
+}
+
+func (r *PostgresWaitlistRepository) DeleteOfUser(e *User) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM waitlist_entries WHERE user_id = $1", e.ID)
+	return err
+}
+
+func (r *PostgresWaitlistRepository) DeleteOfSpace(e *Space) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM waitlist_entries WHERE space_id = $1 OR offered_space_id = $1", e.ID)
+	return err
+}
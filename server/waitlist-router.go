@@ -0,0 +1,502 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type WaitlistRouter struct {
+	repos *Repositories
+}
+
+// CreateWaitlistEntryRequest waits for the space or, if SpaceID is empty, for any
+// space in the location.
+type CreateWaitlistEntryRequest struct {
+	LocationID string    `json:"locationId" validate:"required"`
+	SpaceID    string    `json:"spaceId"`
+	Enter      time.Time `json:"enter" validate:"required"`
+	Leave      time.Time `json:"leave" validate:"required"`
+}
+
+type GetWaitlistEntryResponse struct {
+	ID             string     `json:"id"`
+	UserID         string     `json:"userId"`
+	UserEmail      string     `json:"userEmail"`
+	Created        time.Time  `json:"created"`
+	OfferedSpaceID string     `json:"offeredSpaceId"`
+	OfferExpiry    *time.Time `json:"offerExpiry"`
+	CreateWaitlistEntryRequest
+}
+
+func (router *WaitlistRouter) setupRoutes(s *mux.Router) {
+	s.HandleFunc("/{id}/accept", router.accept).Methods("POST")
+	s.HandleFunc("/{id}", router.getOne).Methods("GET")
+	s.HandleFunc("/{id}", router.delete).Methods("DELETE")
+	s.HandleFunc("/", router.create).Methods("POST")
+	s.HandleFunc("/", router.getAll).Methods("GET")
+}
+
+func (router *WaitlistRouter) getOne(w http.ResponseWriter, r *http.Request) {
+	e, location, ok := router.getWaitlistEntry(w, r)
+	if !ok {
+		return
+	}
+	res := router.copyToRestModel(e, location)
+	SendJSON(w, res)
+}
+
+func (router *WaitlistRouter) getAll(w http.ResponseWriter, r *http.Request) {
+	list, err := router.repos.Waitlist.GetAllByUser(GetRequestUserID(r))
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	res := []*GetWaitlistEntryResponse{}
+	for _, e := range list {
+		location, err := router.repos.Location.GetOne(e.LocationID)
+		if err != nil {
+			log.Println(err)
+			SendInternalServerError(w)
+			return
+		}
+		m := router.copyToRestModel(e, location)
+		res = append(res, m)
+	}
+	SendJSON(w, res)
+}
+
+// create puts the user on the waitlist. Users can only join if no matching space
+// is available to them, otherwise they are expected to book it.
+func (router *WaitlistRouter) create(w http.ResponseWriter, r *http.Request) {
+	var m CreateWaitlistEntryRequest
+	if UnmarshalValidateBody(r, &m) != nil || !m.Leave.After(m.Enter) {
+		SendBadRequest(w)
+		return
+	}
+	location, err := router.repos.Location.GetOne(m.LocationID)
+	if err != nil {
+		SendBadRequest(w)
+		return
+	}
+	requestUser := GetRequestUser(router.repos, r)
+	if !CanAccessOrg(requestUser, location.OrganizationID) {
+		SendForbidden(w)
+		return
+	}
+	if enabled, _ := router.repos.Settings.GetBool(location.OrganizationID, SettingEnableWaitlist.Name); !enabled {
+		SendBadRequest(w)
+		return
+	}
+	if m.SpaceID != "" {
+		space, err := router.repos.Space.GetOne(m.SpaceID)
+		if err != nil || space.LocationID != location.ID {
+			SendBadRequest(w)
+			return
+		}
+	}
+	e, err := router.copyFromRestModel(&m, location)
+	if err != nil {
+		SendInternalServerError(w)
+		return
+	}
+	e.UserID = requestUser.ID
+	bookingReq := &BookingRequest{
+		Enter: e.Enter,
+		Leave: e.Leave,
+	}
+	bookingRouter := &BookingRouter{repos: router.repos}
+	if valid, code := bookingRouter.isValidBookingRequest(bookingReq, requestUser, location.OrganizationID, ""); !valid {
+		SendBadRequestCode(w, code)
+		return
+	}
+	allowed, err := router.repos.BookingRestriction.IsAllowed(requestUser.ID, location.ID, m.SpaceID)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	if !allowed {
+		SendBadRequestCode(w, ResponseCodeBookingSpaceRestricted)
+		return
+	}
+	available, err := router.hasAvailableSpace(bookingReq, location, m.SpaceID, requestUser)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	if available {
+		SendBadRequestCode(w, ResponseCodeWaitlistSpaceAvailable)
+		return
+	}
+	if err := router.repos.Waitlist.Create(e); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	SendCreated(w, e.ID)
+}
+
+// delete removes the entry from the waitlist. A pending offer is passed on to the
+// next user waiting.
+func (router *WaitlistRouter) delete(w http.ResponseWriter, r *http.Request) {
+	e, _, ok := router.getWaitlistEntry(w, r)
+	if !ok {
+		return
+	}
+	if err := router.repos.Waitlist.Delete(&e.WaitlistEntry); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	if e.OfferedSpaceID != "" {
+		router.onSpaceFreed(string(e.OfferedSpaceID))
+	}
+	SendUpdated(w)
+}
+
+// accept books the space offered to the user and removes the entry from the waitlist.
+func (router *WaitlistRouter) accept(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	e, err := router.repos.Waitlist.GetOne(vars["id"])
+	if err != nil {
+		SendNotFound(w)
+		return
+	}
+	if e.UserID != GetRequestUserID(r) {
+		SendForbidden(w)
+		return
+	}
+	if e.OfferedSpaceID == "" || e.OfferExpiry == nil || e.OfferExpiry.Before(time.Now().UTC()) {
+		SendBadRequestCode(w, ResponseCodeWaitlistNoOffer)
+		return
+	}
+	space, err := router.repos.Space.GetOne(string(e.OfferedSpaceID))
+	if err != nil {
+		SendBadRequest(w)
+		return
+	}
+	location, err := router.repos.Location.GetOne(space.LocationID)
+	if err != nil {
+		SendBadRequest(w)
+		return
+	}
+	booking, code, err := router.checkAndBook(r.Context(), e, space, location)
+	if code != 0 {
+		SendBadRequestCode(w, code)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	SendCreated(w, booking.ID)
+}
+
+func (router *WaitlistRouter) getWaitlistEntry(w http.ResponseWriter, r *http.Request) (*WaitlistEntryDetails, *Location, bool) {
+	vars := mux.Vars(r)
+	e, err := router.repos.Waitlist.GetOne(vars["id"])
+	if err != nil {
+		SendNotFound(w)
+		return nil, nil, false
+	}
+	location, err := router.repos.Location.GetOne(e.LocationID)
+	if err != nil {
+		SendNotFound(w)
+		return nil, nil, false
+	}
+	requestUser := GetRequestUser(router.repos, r)
+	if e.UserID != requestUser.ID && !CanSpaceAdminOrg(requestUser, location.OrganizationID) {
+		SendForbidden(w)
+		return nil, nil, false
+	}
+	return e, location, true
+}
+
+// hasAvailableSpace returns true if the user could book the space or, if spaceID is
+// empty, any space in the location right away.
+func (router *WaitlistRouter) hasAvailableSpace(m *BookingRequest, location *Location, spaceID string, user *User) (bool, error) {
+	bookingRouter := &BookingRouter{repos: router.repos}
+	if !bookingRouter.isValidConcurrent(m, location, "") {
+		return false, nil
+	}
+	list, err := router.repos.Space.GetAllInTime(location.ID, m.Enter, m.Leave)
+	if err != nil {
+		return false, err
+	}
+	restrictions, err := router.repos.BookingRestriction.GetAllInLocation(location.ID)
+	if err != nil {
+		return false, err
+	}
+	groupIDs, err := router.repos.BookingRestriction.GetGroupIDs(user.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, space := range list {
+		if (spaceID != "" && space.ID != spaceID) || !space.Available {
+			continue
+		}
+		if !router.repos.BookingRestriction.IsAllowedForGroups(groupIDs, restrictions[location.ID], restrictions[space.ID]) {
+			continue
+		}
+		held, err := router.repos.Waitlist.HasPendingOffer(space.ID, m.Enter, m.Leave, user.ID)
+		if err != nil {
+			return false, err
+		}
+		if !held {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// onSpaceFreed serves the users waiting for the space, first come first served. Each
+// user whose time range is free and who may book the space gets it, so a cancelled
+// full-day booking can serve several users waiting for shorter ranges. If the
+// organization gives users time to accept, the space is held for them and they are
+// notified of the offer, otherwise it is booked for them right away.
+// Failures are logged only, as they must not affect the change which freed the space.
+func (router *WaitlistRouter) onSpaceFreed(spaceID string) {
+	space, err := router.repos.Space.GetOne(spaceID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	location, err := router.repos.Location.GetOne(space.LocationID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if enabled, _ := router.repos.Settings.GetBool(location.OrganizationID, SettingEnableWaitlist.Name); !enabled {
+		return
+	}
+	offerMinutes, _ := router.repos.Settings.GetInt(location.OrganizationID, SettingWaitlistOfferMinutes.Name)
+	list, err := router.repos.Waitlist.GetAllWaitingForSpace(space)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	for _, e := range list {
+		if offerMinutes <= 0 {
+			if _, _, err := router.checkAndBook(context.Background(), e, space, location); err != nil {
+				log.Println(err)
+			}
+			continue
+		}
+		if router.checkEntry(e, space, location) != 0 {
+			continue
+		}
+		expiry := time.Now().UTC().Add(time.Minute * time.Duration(offerMinutes))
+		if err := router.repos.Waitlist.SetOffer(&e.WaitlistEntry, space.ID, expiry); err != nil {
+			log.Println(err)
+			continue
+		}
+		router.sendWaitlistMail(e, space, location, EmailTemplateWaitlistOffer)
+	}
+}
+
+// processExpiredOffers removes the users who have not accepted their offer in time
+// from the waitlist and passes the spaces on. Entries which have ended are deleted.
+func (router *WaitlistRouter) processExpiredOffers() error {
+	now := time.Now().UTC()
+	list, err := router.repos.Waitlist.GetExpiredOffers(now)
+	if err != nil {
+		return err
+	}
+	for _, e := range list {
+		if err := router.repos.Waitlist.Delete(&e.WaitlistEntry); err != nil {
+			log.Println(err)
+			continue
+		}
+		space, err := router.repos.Space.GetOne(string(e.OfferedSpaceID))
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		location, err := router.repos.Location.GetOne(space.LocationID)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		router.sendWaitlistMail(e, space, location, EmailTemplateWaitlistOfferExpired)
+		router.onSpaceFreed(space.ID)
+	}
+	from, _ := getWallClockSearchRange(now, now)
+	return router.repos.Waitlist.DeleteExpired(from)
+}
+
+// checkEntry returns 0 if the space can be booked for the waiting user, otherwise
+// the response code.
+func (router *WaitlistRouter) checkEntry(e *WaitlistEntryDetails, space *Space, location *Location) int {
+	localNow, err := getLocalWallTime(router.repos, time.Now(), location)
+	if err != nil {
+		log.Println(err)
+		return ResponseCodeBookingSlotConflict
+	}
+	if !e.Leave.After(localNow) {
+		return ResponseCodeBookingSlotConflict
+	}
+	user, err := router.repos.User.GetOne(e.UserID)
+	if err != nil {
+		log.Println(err)
+		return ResponseCodeBookingSlotConflict
+	}
+	bookingReq, err := router.getBookingRequest(e, location)
+	if err != nil {
+		log.Println(err)
+		return ResponseCodeBookingSlotConflict
+	}
+	bookingRouter := &BookingRouter{repos: router.repos}
+	if valid, code := bookingRouter.checkBookingCreateUpdate(bookingReq, location, space.ID, user, user.ID, ""); !valid {
+		return code
+	}
+	conflicts, err := router.repos.Booking.GetConflicts(space.ID, bookingReq.Enter, bookingReq.Leave, "")
+	if err != nil {
+		log.Println(err)
+		return ResponseCodeBookingSlotConflict
+	}
+	if len(conflicts) > 0 {
+		return ResponseCodeBookingSlotConflict
+	}
+	held, err := router.repos.Waitlist.HasPendingOffer(space.ID, bookingReq.Enter, bookingReq.Leave, user.ID)
+	if err != nil {
+		log.Println(err)
+		return ResponseCodeBookingSlotConflict
+	}
+	if held {
+		return ResponseCodeBookingSlotConflict
+	}
+	return 0
+}
+
+// checkAndBook books the space for the waiting user if the entry passes the checks. The
+// booking lock is held in the meantime, so no concurrent booking can take the slot. If the
+// entry can't be booked, the response code is returned.
+func (router *WaitlistRouter) checkAndBook(ctx context.Context, e *WaitlistEntryDetails, space *Space, location *Location) (*Booking, int, error) {
+	unlock, err := router.repos.Booking.Lock(ctx, e.UserID, location.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer unlock()
+	if code := router.checkEntry(e, space, location); code != 0 {
+		return nil, code, nil
+	}
+	booking, err := router.book(e, space, location)
+	if errors.Is(err, ErrBookingConflict) {
+		return nil, ResponseCodeBookingSlotConflict, err
+	}
+	return booking, 0, err
+}
+
+func (router *WaitlistRouter) book(e *WaitlistEntryDetails, space *Space, location *Location) (*Booking, error) {
+	bookingReq, err := router.getBookingRequest(e, location)
+	if err != nil {
+		return nil, err
+	}
+	user, err := router.repos.User.GetOne(e.UserID)
+	if err != nil {
+		return nil, err
+	}
+	booking := &Booking{
+		UserID:  e.UserID,
+		SpaceID: space.ID,
+		Enter:   bookingReq.Enter,
+		Leave:   bookingReq.Leave,
+	}
+	bookingRouter := &BookingRouter{repos: router.repos}
+	if err := bookingRouter.setApprovalStatus(booking, space, location, user); err != nil {
+		return nil, err
+	}
+	if err := router.repos.Booking.Create(booking); err != nil {
+		return nil, err
+	}
+	if err := router.repos.Waitlist.Delete(&e.WaitlistEntry); err != nil {
+		return nil, err
+	}
+	if err := bookingRouter.autoCheckIn(booking, location); err != nil {
+		log.Println(err)
+	}
+	if booking.Status == BookingStatusPending {
+		bookingApprovalRouter := &BookingApprovalRouter{repos: router.repos}
+		bookingApprovalRouter.onBookingPending(booking.ID, WebhookEventBookingCreated)
+	} else {
+		bookingRouter.onBookingChanged(booking.ID, EmailTemplateBookingCreated, WebhookEventBookingCreated)
+	}
+	return booking, nil
+}
+
+func (router *WaitlistRouter) getBookingRequest(e *WaitlistEntryDetails, location *Location) (*BookingRequest, error) {
+	enter, err := attachTimezoneInformation(router.repos, e.Enter, location)
+	if err != nil {
+		return nil, err
+	}
+	leave, err := attachTimezoneInformation(router.repos, e.Leave, location)
+	if err != nil {
+		return nil, err
+	}
+	return &BookingRequest{Enter: enter, Leave: leave}, nil
+}
+
+// sendWaitlistMail notifies the waiting user of an offer. Failures are logged only.
+func (router *WaitlistRouter) sendWaitlistMail(e *WaitlistEntryDetails, space *Space, location *Location, templateFile string) {
+	org, err := router.repos.Organization.GetOne(location.OrganizationID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	vars := map[string]string{
+		"recipientName":  e.UserEmail,
+		"recipientEmail": e.UserEmail,
+		"spaceName":      space.Name,
+		"locationName":   location.Name,
+		"enter":          e.Enter.Format("2006-01-02 15:04"),
+		"leave":          e.Leave.Format("2006-01-02 15:04"),
+		"timezone":       router.repos.Location.GetTimezone(location),
+	}
+	if e.OfferExpiry != nil {
+		expiry, _ := getLocalWallTime(router.repos, *e.OfferExpiry, location)
+		vars["offerExpiry"] = expiry.Format("2006-01-02 15:04")
+	}
+	if err := sendEmail(e.UserEmail, GetConfig().SMTPSenderAddress, templateFile, org.Language, vars); err != nil {
+		log.Println(err)
+	}
+}
+
+func (router *WaitlistRouter) copyFromRestModel(m *CreateWaitlistEntryRequest, location *Location) (*WaitlistEntry, error) {
+	e := &WaitlistEntry{}
+	e.LocationID = m.LocationID
+	e.SpaceID = NullString(m.SpaceID)
+	enter, err := attachTimezoneInformation(router.repos, m.Enter, location)
+	if err != nil {
+		return nil, err
+	}
+	e.Enter = enter
+	leave, err := attachTimezoneInformation(router.repos, m.Leave, location)
+	if err != nil {
+		return nil, err
+	}
+	e.Leave = leave
+	return e, nil
+}
+
+func (router *WaitlistRouter) copyToRestModel(e *WaitlistEntryDetails, location *Location) *GetWaitlistEntryResponse {
+	m := &GetWaitlistEntryResponse{}
+	m.ID = e.ID
+	m.UserID = e.UserID
+	m.UserEmail = e.UserEmail
+	m.Created = e.Created
+	m.LocationID = e.LocationID
+	m.SpaceID = string(e.SpaceID)
+	m.Enter, _ = attachTimezoneInformation(router.repos, e.Enter, location)
+	m.Leave, _ = attachTimezoneInformation(router.repos, e.Leave, location)
+	m.OfferedSpaceID = string(e.OfferedSpaceID)
+	m.OfferExpiry = e.OfferExpiry
+	return m
+}
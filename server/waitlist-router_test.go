@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func createTestWaitlistSpace(t *testing.T, org *Organization, admin *User) (locationID, spaceID string) {
+	testRepositories.Settings.Set(org.ID, SettingMaxDaysInAdvance.Name, "5000")
+	testRepositories.Settings.Set(org.ID, SettingEnableWaitlist.Name, "1")
+	payload := `{"name": "Location 1"}`
+	req := newHTTPRequest("POST", "/location/", admin.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	locationID = res.Header().Get("X-Object-Id")
+	payload = `{"name": "H234"}`
+	req = newHTTPRequest("POST", "/location/"+locationID+"/space/", admin.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	spaceID = res.Header().Get("X-Object-Id")
+	return locationID, spaceID
+}
+
+func TestWaitlistBookOnCancel(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	user1 := createTestUserInOrg(org)
+	user2 := createTestUserInOrg(org)
+	locationID, spaceID := createTestWaitlistSpace(t, org, admin)
+
+	// Space is available
+	payload := `{"locationId": "` + locationID + `", "spaceId": "` + spaceID + `", "enter": "2030-09-01T08:30:00Z", "leave": "2030-09-01T17:00:00Z"}`
+	req := newHTTPRequest("POST", "/booking/waitlist/", user2.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+	checkTestString(t, strconv.Itoa(ResponseCodeWaitlistSpaceAvailable), res.Header().Get("X-Error-Code"))
+
+	payload = `{"spaceId": "` + spaceID + `", "enter": "2030-09-01T08:30:00Z", "leave": "2030-09-01T17:00:00Z"}`
+	req = newHTTPRequest("POST", "/booking/", user1.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	bookingID := res.Header().Get("X-Object-Id")
+
+	// Wait for any space in the location
+	payload = `{"locationId": "` + locationID + `", "enter": "2030-09-01T09:00:00Z", "leave": "2030-09-01T12:00:00Z"}`
+	req = newHTTPRequest("POST", "/booking/waitlist/", user2.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	entryID := res.Header().Get("X-Object-Id")
+
+	req = newHTTPRequest("GET", "/booking/waitlist/", user2.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody []*GetWaitlistEntryResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestInt(t, 1, len(resBody))
+	checkTestString(t, entryID, resBody[0].ID)
+	checkTestString(t, "", resBody[0].SpaceID)
+	checkTestString(t, "2030-09-01T09:00:00+02:00", resBody[0].Enter.Format(JsDateTimeFormatWithTimezone))
+
+	req = newHTTPRequest("GET", "/booking/waitlist/"+entryID, user1.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+
+	// Cancellation books the space for the waiting user
+	SendMailMockContent = ""
+	req = newHTTPRequest("DELETE", "/booking/"+bookingID, user1.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+	checkStringNotEmpty(t, SendMailMockContent)
+
+	req = newHTTPRequest("GET", "/booking/", user2.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBookings []*GetBookingResponse
+	json.Unmarshal(res.Body.Bytes(), &resBookings)
+	checkTestInt(t, 1, len(resBookings))
+	checkTestString(t, spaceID, resBookings[0].Space.ID)
+	checkTestString(t, "2030-09-01T09:00:00+02:00", resBookings[0].Enter.Format(JsDateTimeFormatWithTimezone))
+
+	req = newHTTPRequest("GET", "/booking/waitlist/"+entryID, user2.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNotFound, res.Code)
+}
+
+func TestWaitlistOffer(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	user1 := createTestUserInOrg(org)
+	user2 := createTestUserInOrg(org)
+	user3 := createTestUserInOrg(org)
+	user4 := createTestUserInOrg(org)
+	locationID, spaceID := createTestWaitlistSpace(t, org, admin)
+	testRepositories.Settings.Set(org.ID, SettingWaitlistOfferMinutes.Name, "30")
+
+	payload := `{"spaceId": "` + spaceID + `", "enter": "2030-09-01T08:30:00Z", "leave": "2030-09-01T17:00:00Z"}`
+	req := newHTTPRequest("POST", "/booking/", user1.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	bookingID := res.Header().Get("X-Object-Id")
+
+	var entryIDs []string
+	for _, user := range []*User{user2, user3, user4} {
+		payload = `{"locationId": "` + locationID + `", "spaceId": "` + spaceID + `", "enter": "2030-09-01T08:30:00Z", "leave": "2030-09-01T17:00:00Z"}`
+		req = newHTTPRequest("POST", "/booking/waitlist/", user.ID, bytes.NewBufferString(payload))
+		res = executeTestRequest(req)
+		checkTestResponseCode(t, http.StatusCreated, res.Code)
+		entryIDs = append(entryIDs, res.Header().Get("X-Object-Id"))
+	}
+
+	// No offer yet
+	req = newHTTPRequest("POST", "/booking/waitlist/"+entryIDs[0]+"/accept", user2.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+	checkTestString(t, strconv.Itoa(ResponseCodeWaitlistNoOffer), res.Header().Get("X-Error-Code"))
+
+	// Cancellation offers the space to the first user waiting
+	SendMailMockContent = ""
+	req = newHTTPRequest("DELETE", "/booking/"+bookingID, user1.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+	checkStringNotEmpty(t, SendMailMockContent)
+	entry, _ := testRepositories.Waitlist.GetOne(entryIDs[0])
+	checkTestString(t, spaceID, string(entry.OfferedSpaceID))
+	entry, _ = testRepositories.Waitlist.GetOne(entryIDs[1])
+	checkTestString(t, "", string(entry.OfferedSpaceID))
+
+	// The space is held for the user with the offer
+	payload = `{"spaceId": "` + spaceID + `", "enter": "2030-09-01T10:00:00Z", "leave": "2030-09-01T11:00:00Z"}`
+	req = newHTTPRequest("POST", "/booking/", user1.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusConflict, res.Code)
+
+	// Declining passes the offer on
+	req = newHTTPRequest("DELETE", "/booking/waitlist/"+entryIDs[0], user2.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+	entry, _ = testRepositories.Waitlist.GetOne(entryIDs[1])
+	checkTestString(t, spaceID, string(entry.OfferedSpaceID))
+
+	// Expired offers are passed on as well
+	testRepositories.Waitlist.SetOffer(&entry.WaitlistEntry, spaceID, time.Now().UTC().Add(-time.Minute))
+	waitlistRouter := &WaitlistRouter{repos: testRepositories}
+	if err := waitlistRouter.processExpiredOffers(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testRepositories.Waitlist.GetOne(entryIDs[1]); err == nil {
+		t.Fatal("expected expired entry to be deleted")
+	}
+	entry, _ = testRepositories.Waitlist.GetOne(entryIDs[2])
+	checkTestString(t, spaceID, string(entry.OfferedSpaceID))
+
+	req = newHTTPRequest("POST", "/booking/waitlist/"+entryIDs[2]+"/accept", user3.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+	req = newHTTPRequest("POST", "/booking/waitlist/"+entryIDs[2]+"/accept", user4.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	booking, err := testRepositories.Booking.GetOne(res.Header().Get("X-Object-Id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTestString(t, user4.ID, booking.UserID)
+	checkTestString(t, spaceID, booking.SpaceID)
+	if _, err := testRepositories.Waitlist.GetOne(entryIDs[2]); err == nil {
+		t.Fatal("expected accepted entry to be deleted")
+	}
+}
+
+func TestWaitlistDisabled(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	user := createTestUserInOrg(org)
+	locationID, _ := createTestWaitlistSpace(t, org, admin)
+	testRepositories.Settings.Set(org.ID, SettingEnableWaitlist.Name, "0")
+
+	payload := `{"locationId": "` + locationID + `", "enter": "2030-09-01T08:30:00Z", "leave": "2030-09-01T17:00:00Z"}`
+	req := newHTTPRequest("POST", "/booking/waitlist/", user.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+}
@@ -0,0 +1,110 @@
+package main
+
+import "time"
+
+type MemoryWebhookDeliveryRepository struct {
+}
+
+func (r *MemoryWebhookDeliveryRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryWebhookDeliveryRepository) Create(e *WebhookDelivery) error {
+	if err := memoryCheckUUID(e.WebhookID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e.ID = memoryNewID()
+	memoryData.webhookDeliveries = append(memoryData.webhookDeliveries, r.copy(e))
+	return nil
+}
+
+func (r *MemoryWebhookDeliveryRepository) GetOne(id string) (*WebhookDelivery, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e, err := memoryFind(memoryData.webhookDeliveries, func(e *WebhookDelivery) bool {
+		return e.ID == id
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.copy(e), nil
+}
+
+func (r *MemoryWebhookDeliveryRepository) GetAll(webhookID string, maxResults int) ([]*WebhookDelivery, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	var result []*WebhookDelivery
+	for _, e := range memoryData.webhookDeliveries {
+		if e.WebhookID == webhookID {
+			result = append(result, r.copy(e))
+		}
+	}
+	memorySort(result, func(a, b *WebhookDelivery) bool {
+		return a.Created.After(b.Created)
+	})
+	return memoryPage(result, maxResults, 0), nil
+}
+
+func (r *MemoryWebhookDeliveryRepository) GetAllDue(until time.Time) ([]*WebhookDelivery, error) {
+	until = memoryTimestamp(until)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	var result []*WebhookDelivery
+	for _, e := range memoryData.webhookDeliveries {
+		if !e.Delivered && e.NextAttempt != nil && !e.NextAttempt.After(until) {
+			result = append(result, r.copy(e))
+		}
+	}
+	memorySort(result, func(a, b *WebhookDelivery) bool {
+		return a.NextAttempt.Before(*b.NextAttempt)
+	})
+	return result, nil
+}
+
+func (r *MemoryWebhookDeliveryRepository) Update(e *WebhookDelivery) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	row, err := memoryFind(memoryData.webhookDeliveries, func(row *WebhookDelivery) bool {
+		return row.ID == e.ID
+	})
+	if err != nil {
+		return nil
+	}
+	row.Attempts = e.Attempts
+	row.NextAttempt = memoryNullTimestamp(e.NextAttempt)
+	row.LastAttempt = memoryNullTimestamp(e.LastAttempt)
+	row.LastStatusCode = e.LastStatusCode
+	row.LastError = e.LastError
+	row.Delivered = e.Delivered
+	return nil
+}
+
+func (r *MemoryWebhookDeliveryRepository) DeleteAll(webhookID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.webhookDeliveries = memoryDelete(memoryData.webhookDeliveries, func(row *WebhookDelivery) bool {
+		return row.WebhookID == webhookID
+	})
+	return nil
+}
+
+func (r *MemoryWebhookDeliveryRepository) DeleteOlderThan(t time.Time) error {
+	t = memoryTimestamp(t)
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.webhookDeliveries = memoryDelete(memoryData.webhookDeliveries, func(row *WebhookDelivery) bool {
+		return row.Created.Before(t) && row.NextAttempt == nil
+	})
+	return nil
+}
+
+func (r *MemoryWebhookDeliveryRepository) copy(e *WebhookDelivery) *WebhookDelivery {
+	c := *e
+	c.Created = memoryTimestamp(e.Created)
+	c.NextAttempt = memoryNullTimestamp(e.NextAttempt)
+	c.LastAttempt = memoryNullTimestamp(e.LastAttempt)
+	return &c
+}
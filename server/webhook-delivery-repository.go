@@ -0,0 +1,152 @@
+package main
+
+import "time"
+
+type WebhookDeliveryRepository interface {
+	Repository
+	Create(e *WebhookDelivery) error
+	GetOne(id string) (*WebhookDelivery, error)
+	GetAll(webhookID string, maxResults int) ([]*WebhookDelivery, error)
+	GetAllDue(until time.Time) ([]*WebhookDelivery, error)
+	Update(e *WebhookDelivery) error
+	DeleteAll(webhookID string) error
+	DeleteOlderThan(t time.Time) error
+}
+
+type PostgresWebhookDeliveryRepository struct {
+}
+
+// WebhookDelivery is one event sent to a webhook. NextAttempt is nil once the
+// delivery succeeded or all attempts failed.
+type WebhookDelivery struct {
+	ID             string
+	WebhookID      string
+	Event          string
+	Payload        string
+	Created        time.Time
+	Attempts       int
+	NextAttempt    *time.Time
+	LastAttempt    *time.Time
+	LastStatusCode int
+	LastError      string
+	Delivered      bool
+}
+
+func (r *PostgresWebhookDeliveryRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		{
+			Version:     27,
+			Description: "Add deliveries of webhooks",
+			Up: []string{
+				"CREATE TABLE IF NOT EXISTS webhooks_deliveries (" +
+					"id uuid DEFAULT uuid_generate_v4(), " +
+					"webhook_id uuid NOT NULL, " +
+					"event VARCHAR NOT NULL, " +
+					"payload TEXT NOT NULL, " +
+					"created TIMESTAMP NOT NULL, " +
+					"attempts INTEGER NOT NULL DEFAULT 0, " +
+					"next_attempt TIMESTAMP NULL, " +
+					"last_attempt TIMESTAMP NULL, " +
+					"last_status_code INTEGER NOT NULL DEFAULT 0, " +
+					"last_error VARCHAR NOT NULL DEFAULT '', " +
+					"delivered boolean NOT NULL DEFAULT FALSE, " +
+					"PRIMARY KEY (id))",
+				"CREATE INDEX IF NOT EXISTS idx_webhooks_deliveries_webhook_id ON webhooks_deliveries(webhook_id)",
+			},
+			Down: []string{"DROP TABLE IF EXISTS webhooks_deliveries"},
+		},
+	}
+}
+
+func (r *PostgresWebhookDeliveryRepository) Create(e *WebhookDelivery) error {
+	var id string
+	err := GetDatabase().DB().QueryRow("INSERT INTO webhooks_deliveries "+
+		"(webhook_id, event, payload, created, attempts, next_attempt, last_attempt, last_status_code, last_error, delivered) "+
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) "+
+		"RETURNING id",
+		e.WebhookID, e.Event, e.Payload, e.Created, e.Attempts, e.NextAttempt, e.LastAttempt, e.LastStatusCode, e.LastError, e.Delivered).Scan(&id)
+	if err != nil {
+		return err
+	}
+	e.ID = id
+	return nil
+}
+
+func (r *PostgresWebhookDeliveryRepository) GetOne(id string) (*WebhookDelivery, error) {
+	e := &WebhookDelivery{}
+	err := GetDatabase().DB().QueryRow("SELECT id, webhook_id, event, payload, created, attempts, next_attempt, last_attempt, last_status_code, last_error, delivered "+
+		"FROM webhooks_deliveries "+
+		"WHERE id = $1",
+		id).Scan(&e.ID, &e.WebhookID, &e.Event, &e.Payload, &e.Created, &e.Attempts, &e.NextAttempt, &e.LastAttempt, &e.LastStatusCode, &e.LastError, &e.Delivered)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (r *PostgresWebhookDeliveryRepository) GetAll(webhookID string, maxResults int) ([]*WebhookDelivery, error) {
+	rows, err := GetDatabase().DB().Query("SELECT id, webhook_id, event, payload, created, attempts, next_attempt, last_attempt, last_status_code, last_error, delivered "+
+		"FROM webhooks_deliveries "+
+		"WHERE webhook_id = $1 "+
+		"ORDER BY created DESC "+
+		"LIMIT $2", webhookID, maxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []*WebhookDelivery
+	for rows.Next() {
+		e := &WebhookDelivery{}
+		err = rows.Scan(&e.ID, &e.WebhookID, &e.Event, &e.Payload, &e.Created, &e.Attempts, &e.NextAttempt, &e.LastAttempt, &e.LastStatusCode, &e.LastError, &e.Delivered)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+// GetAllDue returns all deliveries which are scheduled for another attempt up to the specified time.
+func (r *PostgresWebhookDeliveryRepository) GetAllDue(until time.Time) ([]*WebhookDelivery, error) {
+	rows, err := GetDatabase().DB().Query("SELECT id, webhook_id, event, payload, created, attempts, next_attempt, last_attempt, last_status_code, last_error, delivered "+
+		"FROM webhooks_deliveries "+
+		"WHERE delivered = FALSE AND next_attempt IS NOT NULL AND next_attempt <= $1 "+
+		"ORDER BY next_attempt", until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []*WebhookDelivery
+	for rows.Next() {
+		e := &WebhookDelivery{}
+		err = rows.Scan(&e.ID, &e.WebhookID, &e.Event, &e.Payload, &e.Created, &e.Attempts, &e.NextAttempt, &e.LastAttempt, &e.LastStatusCode, &e.LastError, &e.Delivered)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (r *PostgresWebhookDeliveryRepository) Update(e *WebhookDelivery) error {
+	_, err := GetDatabase().DB().Exec("UPDATE webhooks_deliveries SET "+
+		"attempts = $1, "+
+		"next_attempt = $2, "+
+		"last_attempt = $3, "+
+		"last_status_code = $4, "+
+		"last_error = $5, "+
+		"delivered = $6 "+
+		"WHERE id = $7",
+		e.Attempts, e.NextAttempt, e.LastAttempt, e.LastStatusCode, e.LastError, e.Delivered, e.ID)
+	return err
+}
+
+func (r *PostgresWebhookDeliveryRepository) DeleteAll(webhookID string) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM webhooks_deliveries WHERE webhook_id = $1", webhookID)
+	return err
+}
+
+func (r *PostgresWebhookDeliveryRepository) DeleteOlderThan(t time.Time) error {
+	_, err := GetDatabase().DB().Exec("DELETE FROM webhooks_deliveries WHERE created < $1 AND next_attempt IS NULL", t)
+	return err
+}
@@ -0,0 +1,100 @@
+package main
+
+type MemoryWebhookRepository struct {
+	webhookRepositoryBase
+}
+
+func (r *MemoryWebhookRepository) GetMigrations() []*Migration {
+	// Nothing to migrate
+	return nil
+}
+
+func (r *MemoryWebhookRepository) Create(e *Webhook) error {
+	if err := memoryCheckUUID(e.OrganizationID); err != nil {
+		return err
+	}
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e.ID = memoryNewID()
+	memoryData.webhooks = append(memoryData.webhooks, r.copy(e))
+	return nil
+}
+
+func (r *MemoryWebhookRepository) GetOne(id string) (*Webhook, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	e, err := memoryFind(memoryData.webhooks, func(e *Webhook) bool {
+		return e.ID == id
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.copy(e), nil
+}
+
+func (r *MemoryWebhookRepository) GetAll(organizationID string) ([]*Webhook, error) {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	var result []*Webhook
+	for _, e := range memoryData.webhooks {
+		if e.OrganizationID == organizationID {
+			result = append(result, r.copy(e))
+		}
+	}
+	memorySort(result, func(a, b *Webhook) bool {
+		return memoryCompare(a.URL, b.URL) < 0
+	})
+	return result, nil
+}
+
+func (r *MemoryWebhookRepository) Update(e *Webhook) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	row, err := memoryFind(memoryData.webhooks, func(row *Webhook) bool {
+		return row.ID == e.ID
+	})
+	if err != nil {
+		return nil
+	}
+	c := r.copy(e)
+	row.URL = c.URL
+	row.Secret = c.Secret
+	row.Events = c.Events
+	row.Active = c.Active
+	return nil
+}
+
+func (r *MemoryWebhookRepository) Delete(e *Webhook) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.webhookDeliveries = memoryDelete(memoryData.webhookDeliveries, func(row *WebhookDelivery) bool {
+		return row.WebhookID == e.ID
+	})
+	memoryData.webhooks = memoryDelete(memoryData.webhooks, func(row *Webhook) bool {
+		return row.ID == e.ID
+	})
+	return nil
+}
+
+func (r *MemoryWebhookRepository) DeleteAll(organizationID string) error {
+	memoryLock.Lock()
+	defer memoryLock.Unlock()
+	memoryData.webhookDeliveries = memoryDelete(memoryData.webhookDeliveries, func(row *WebhookDelivery) bool {
+		webhook, err := memoryFind(memoryData.webhooks, func(e *Webhook) bool {
+			return e.ID == row.WebhookID
+		})
+		return err == nil && webhook.OrganizationID == organizationID
+	})
+	memoryData.webhooks = memoryDelete(memoryData.webhooks, func(row *Webhook) bool {
+		return row.OrganizationID == organizationID
+	})
+	return nil
+}
+
+// copy returns a copy of the webhook. Like the comma-separated column, an empty list
+// of events is never nil.
+func (r *MemoryWebhookRepository) copy(e *Webhook) *Webhook {
+	c := *e
+	c.Events = append([]string{}, e.Events...)
+	return &c
+}
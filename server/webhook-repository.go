@@ -0,0 +1,163 @@
+package main
+
+import "strings"
+
+type WebhookRepository interface {
+	Repository
+	Create(e *Webhook) error
+	GetOne(id string) (*Webhook, error)
+	GetAll(organizationID string) ([]*Webhook, error)
+	GetAllForEvent(organizationID, event string) ([]*Webhook, error)
+	Update(e *Webhook) error
+	Delete(e *Webhook) error
+	DeleteAll(organizationID string) error
+}
+
+type PostgresWebhookRepository struct {
+	webhookRepositoryBase
+}
+
+// webhookRepositoryBase filters the webhooks independent of where they are stored.
+type webhookRepositoryBase struct {
+	repos *Repositories
+}
+
+type Webhook struct {
+	ID             string
+	OrganizationID string
+	URL            string
+	Secret         string
+	Events         []string
+	Active         bool
+}
+
+func (r *PostgresWebhookRepository) GetMigrations() []*Migration {
+	return []*Migration{
+		{
+			Version:     27,
+			Description: "Add webhooks",
+			Up: []string{
+				"CREATE TABLE IF NOT EXISTS webhooks (" +
+					"id uuid DEFAULT uuid_generate_v4(), " +
+					"organization_id uuid NOT NULL, " +
+					"url VARCHAR NOT NULL, " +
+					"secret VARCHAR NOT NULL, " +
+					"events VARCHAR NOT NULL DEFAULT '', " +
+					"active boolean NOT NULL DEFAULT TRUE, " +
+					"PRIMARY KEY (id))",
+				"CREATE INDEX IF NOT EXISTS idx_webhooks_organization_id ON webhooks(organization_id)",
+			},
+			Down: []string{"DROP TABLE IF EXISTS webhooks"},
+		},
+	}
+}
+
+func (r *PostgresWebhookRepository) Create(e *Webhook) error {
+	var id string
+	err := GetDatabase().DB().QueryRow("INSERT INTO webhooks "+
+		"(organization_id, url, secret, events, active) "+
+		"VALUES ($1, $2, $3, $4, $5) "+
+		"RETURNING id",
+		e.OrganizationID, e.URL, e.Secret, strings.Join(e.Events, ","), e.Active).Scan(&id)
+	if err != nil {
+		return err
+	}
+	e.ID = id
+	return nil
+}
+
+func (r *PostgresWebhookRepository) GetOne(id string) (*Webhook, error) {
+	e := &Webhook{}
+	var events string
+	err := GetDatabase().DB().QueryRow("SELECT id, organization_id, url, secret, events, active "+
+		"FROM webhooks "+
+		"WHERE id = $1",
+		id).Scan(&e.ID, &e.OrganizationID, &e.URL, &e.Secret, &events, &e.Active)
+	if err != nil {
+		return nil, err
+	}
+	e.Events = r.splitEvents(events)
+	return e, nil
+}
+
+func (r *PostgresWebhookRepository) GetAll(organizationID string) ([]*Webhook, error) {
+	var result []*Webhook
+	rows, err := GetDatabase().DB().Query("SELECT id, organization_id, url, secret, events, active "+
+		"FROM webhooks "+
+		"WHERE organization_id = $1 "+
+		"ORDER BY url", organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := &Webhook{}
+		var events string
+		err = rows.Scan(&e.ID, &e.OrganizationID, &e.URL, &e.Secret, &events, &e.Active)
+		if err != nil {
+			return nil, err
+		}
+		e.Events = r.splitEvents(events)
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+// GetAllForEvent returns all active webhooks of the organization subscribed to the event.
+func (r *webhookRepositoryBase) GetAllForEvent(organizationID, event string) ([]*Webhook, error) {
+	list, err := r.repos.Webhook.GetAll(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	var result []*Webhook
+	for _, e := range list {
+		if e.Active && e.HasEvent(event) {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+func (r *PostgresWebhookRepository) Update(e *Webhook) error {
+	_, err := GetDatabase().DB().Exec("UPDATE webhooks SET "+
+		"url = $1, "+
+		"secret = $2, "+
+		"events = $3, "+
+		"active = $4 "+
+		"WHERE id = $5",
+		e.URL, e.Secret, strings.Join(e.Events, ","), e.Active, e.ID)
+	return err
+}
+
+func (r *PostgresWebhookRepository) Delete(e *Webhook) error {
+	if err := r.repos.WebhookDelivery.DeleteAll(e.ID); err != nil {
+		return err
+	}
+	_, err := GetDatabase().DB().Exec("DELETE FROM webhooks WHERE id = $1", e.ID)
+	return err
+}
+
+func (r *PostgresWebhookRepository) DeleteAll(organizationID string) error {
+	if _, err := GetDatabase().DB().Exec("DELETE FROM webhooks_deliveries WHERE "+
+		"webhook_id IN (SELECT id FROM webhooks WHERE organization_id = $1)", organizationID); err != nil {
+		return err
+	}
+	_, err := GetDatabase().DB().Exec("DELETE FROM webhooks WHERE organization_id = $1", organizationID)
+	return err
+}
+
+func (e *Webhook) HasEvent(event string) bool {
+	for _, item := range e.Events {
+		if item == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *PostgresWebhookRepository) splitEvents(events string) []string {
+	if events == "" {
+		return []string{}
+	}
+	return strings.Split(events, ",")
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type WebhookRouter struct {
+	repos *Repositories
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" validate:"required"`
+	Secret string   `json:"secret,omitempty"`
+	Events []string `json:"events" validate:"required"`
+	Active bool     `json:"active"`
+}
+
+// GetWebhookResponse contains the secret only when the webhook is created.
+type GetWebhookResponse struct {
+	ID string `json:"id"`
+	CreateWebhookRequest
+}
+
+type GetWebhookDeliveryResponse struct {
+	ID             string     `json:"id"`
+	WebhookID      string     `json:"webhookId"`
+	Event          string     `json:"event"`
+	Payload        string     `json:"payload"`
+	Created        time.Time  `json:"created"`
+	Attempts       int        `json:"attempts"`
+	NextAttempt    *time.Time `json:"nextAttempt"`
+	LastAttempt    *time.Time `json:"lastAttempt"`
+	LastStatusCode int        `json:"lastStatusCode"`
+	LastError      string     `json:"lastError"`
+	Delivered      bool       `json:"delivered"`
+}
+
+type WebhookPayload struct {
+	Event          string      `json:"event"`
+	OrganizationID string      `json:"organizationId"`
+	Created        time.Time   `json:"created"`
+	Data           interface{} `json:"data"`
+}
+
+const (
+	WebhookEventBookingCreated  string = "booking.created"
+	WebhookEventBookingUpdated  string = "booking.updated"
+	WebhookEventBookingDeleted  string = "booking.deleted"
+	WebhookEventUserCreated     string = "user.created"
+	WebhookEventUserDeleted     string = "user.deleted"
+	WebhookEventSpaceCreated    string = "space.created"
+	WebhookEventSpaceDeleted    string = "space.deleted"
+	WebhookEventLocationUpdated string = "location.updated"
+)
+
+var WebhookEvents = []string{
+	WebhookEventBookingCreated,
+	WebhookEventBookingUpdated,
+	WebhookEventBookingDeleted,
+	WebhookEventUserCreated,
+	WebhookEventUserDeleted,
+	WebhookEventSpaceCreated,
+	WebhookEventSpaceDeleted,
+	WebhookEventLocationUpdated,
+}
+
+const WebhookMaxAttempts int = 8
+const WebhookRetryBaseDelay time.Duration = time.Minute
+const WebhookRequestTimeout time.Duration = time.Second * 10
+const WebhookDeliveryRetentionDays int = 30
+
+func (router *WebhookRouter) setupRoutes(s *mux.Router) {
+	s.HandleFunc("/{id}/delivery/{deliveryId}/redeliver", router.redeliver).Methods("POST")
+	s.HandleFunc("/{id}/delivery/", router.getDeliveries).Methods("GET")
+	s.HandleFunc("/{id}", router.getOne).Methods("GET")
+	s.HandleFunc("/{id}", router.update).Methods("PUT")
+	s.HandleFunc("/{id}", router.delete).Methods("DELETE")
+	s.HandleFunc("/", router.create).Methods("POST")
+	s.HandleFunc("/", router.getAll).Methods("GET")
+}
+
+func (router *WebhookRouter) getOne(w http.ResponseWriter, r *http.Request) {
+	e, ok := router.getWebhook(w, r)
+	if !ok {
+		return
+	}
+	res := router.copyToRestModel(e)
+	SendJSON(w, res)
+}
+
+func (router *WebhookRouter) getAll(w http.ResponseWriter, r *http.Request) {
+	user := GetRequestUser(router.repos, r)
+	if !CanAdminOrg(user, user.OrganizationID) {
+		SendForbidden(w)
+		return
+	}
+	list, err := router.repos.Webhook.GetAll(user.OrganizationID)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	res := []*GetWebhookResponse{}
+	for _, e := range list {
+		m := router.copyToRestModel(e)
+		res = append(res, m)
+	}
+	SendJSON(w, res)
+}
+
+func (router *WebhookRouter) create(w http.ResponseWriter, r *http.Request) {
+	user := GetRequestUser(router.repos, r)
+	if !CanAdminOrg(user, user.OrganizationID) {
+		SendForbidden(w)
+		return
+	}
+	var m CreateWebhookRequest
+	if UnmarshalValidateBody(r, &m) != nil || !router.isValidRequest(&m) {
+		SendBadRequest(w)
+		return
+	}
+	e := router.copyFromRestModel(&m)
+	e.OrganizationID = user.OrganizationID
+	if e.Secret == "" {
+		e.Secret = router.generateSecret()
+	}
+	if err := router.repos.Webhook.Create(e); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	res := router.copyToRestModel(e)
+	res.Secret = e.Secret
+	SendCreatedJSON(w, e.ID, res)
+}
+
+func (router *WebhookRouter) update(w http.ResponseWriter, r *http.Request) {
+	e, ok := router.getWebhook(w, r)
+	if !ok {
+		return
+	}
+	var m CreateWebhookRequest
+	if UnmarshalValidateBody(r, &m) != nil || !router.isValidRequest(&m) {
+		SendBadRequest(w)
+		return
+	}
+	eNew := router.copyFromRestModel(&m)
+	eNew.ID = e.ID
+	eNew.OrganizationID = e.OrganizationID
+	if eNew.Secret == "" {
+		eNew.Secret = e.Secret
+	}
+	if err := router.repos.Webhook.Update(eNew); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	SendUpdated(w)
+}
+
+func (router *WebhookRouter) delete(w http.ResponseWriter, r *http.Request) {
+	e, ok := router.getWebhook(w, r)
+	if !ok {
+		return
+	}
+	if err := router.repos.Webhook.Delete(e); err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	SendUpdated(w)
+}
+
+func (router *WebhookRouter) getDeliveries(w http.ResponseWriter, r *http.Request) {
+	e, ok := router.getWebhook(w, r)
+	if !ok {
+		return
+	}
+	list, err := router.repos.WebhookDelivery.GetAll(e.ID, 100)
+	if err != nil {
+		log.Println(err)
+		SendInternalServerError(w)
+		return
+	}
+	res := []*GetWebhookDeliveryResponse{}
+	for _, delivery := range list {
+		m := router.copyDeliveryToRestModel(delivery)
+		res = append(res, m)
+	}
+	SendJSON(w, res)
+}
+
+// redeliver sends a delivery again immediately and returns the result.
+func (router *WebhookRouter) redeliver(w http.ResponseWriter, r *http.Request) {
+	e, ok := router.getWebhook(w, r)
+	if !ok {
+		return
+	}
+	vars := mux.Vars(r)
+	delivery, err := router.repos.WebhookDelivery.GetOne(vars["deliveryId"])
+	if err != nil || delivery.WebhookID != e.ID {
+		SendNotFound(w)
+		return
+	}
+	delivery.Attempts = 0
+	delivery.Delivered = false
+	router.deliver(e, delivery)
+	res := router.copyDeliveryToRestModel(delivery)
+	SendJSON(w, res)
+}
+
+// fireWebhookEvent stores a delivery for each webhook of the organization subscribed
+// to the event and sends it in the background. Failed deliveries are retried by
+// retryDeliveries with exponential backoff.
+func fireWebhookEvent(repos *Repositories, organizationID, event string, data interface{}) {
+	list, err := repos.Webhook.GetAllForEvent(organizationID, event)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if len(list) == 0 {
+		return
+	}
+	payload, err := json.Marshal(&WebhookPayload{
+		Event:          event,
+		OrganizationID: organizationID,
+		Created:        time.Now().UTC(),
+		Data:           data,
+	})
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	router := &WebhookRouter{repos: repos}
+	for _, e := range list {
+		// Scheduled as first retry already, so a crash before sending doesn't lose the delivery
+		nextAttempt := time.Now().UTC().Add(WebhookRetryBaseDelay)
+		delivery := &WebhookDelivery{
+			WebhookID:   e.ID,
+			Event:       event,
+			Payload:     string(payload),
+			Created:     time.Now().UTC(),
+			NextAttempt: &nextAttempt,
+		}
+		if err := repos.WebhookDelivery.Create(delivery); err != nil {
+			log.Println(err)
+			continue
+		}
+		go router.deliver(e, delivery)
+	}
+}
+
+// retryDeliveries sends all deliveries which are due for another attempt and removes old ones.
+func (router *WebhookRouter) retryDeliveries() error {
+	list, err := router.repos.WebhookDelivery.GetAllDue(time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	for _, delivery := range list {
+		e, err := router.repos.Webhook.GetOne(delivery.WebhookID)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		router.deliver(e, delivery)
+	}
+	return router.repos.WebhookDelivery.DeleteOlderThan(time.Now().UTC().AddDate(0, 0, -WebhookDeliveryRetentionDays))
+}
+
+// deliver posts the payload signed with the webhook's secret and updates the delivery's state.
+func (router *WebhookRouter) deliver(e *Webhook, delivery *WebhookDelivery) {
+	now := time.Now().UTC()
+	delivery.Attempts++
+	delivery.LastAttempt = &now
+	delivery.LastStatusCode = 0
+	delivery.LastError = ""
+	req, err := http.NewRequest("POST", e.URL, bytes.NewBufferString(delivery.Payload))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "Seatsurfing-Webhook/"+GetProductVersion())
+		req.Header.Set("X-Seatsurfing-Event", delivery.Event)
+		req.Header.Set("X-Seatsurfing-Delivery", delivery.ID)
+		req.Header.Set("X-Seatsurfing-Signature", "sha256="+router.sign(e.Secret, delivery.Payload))
+		client := newRestrictedHTTPClient(WebhookRequestTimeout, GetConfig().WebhookAllowPrivateNetworks)
+		var res *http.Response
+		res, err = client.Do(req)
+		if err == nil {
+			res.Body.Close()
+			delivery.LastStatusCode = res.StatusCode
+		}
+	}
+	if err != nil {
+		delivery.LastError = err.Error()
+	}
+	delivery.Delivered = (err == nil && delivery.LastStatusCode >= 200 && delivery.LastStatusCode < 300)
+	delivery.NextAttempt = nil
+	if !delivery.Delivered && delivery.Attempts < WebhookMaxAttempts {
+		backoff := WebhookRetryBaseDelay * time.Duration(math.Pow(2, float64(delivery.Attempts-1)))
+		nextAttempt := now.Add(backoff)
+		delivery.NextAttempt = &nextAttempt
+	}
+	if err := router.repos.WebhookDelivery.Update(delivery); err != nil {
+		log.Println(err)
+	}
+}
+
+func (router *WebhookRouter) sign(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (router *WebhookRouter) generateSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (router *WebhookRouter) getWebhook(w http.ResponseWriter, r *http.Request) (*Webhook, bool) {
+	vars := mux.Vars(r)
+	e, err := router.repos.Webhook.GetOne(vars["id"])
+	if err != nil {
+		SendNotFound(w)
+		return nil, false
+	}
+	if !CanAdminOrg(GetRequestUser(router.repos, r), e.OrganizationID) {
+		SendForbidden(w)
+		return nil, false
+	}
+	return e, true
+}
+
+func (router *WebhookRouter) isValidRequest(m *CreateWebhookRequest) bool {
+	u, err := url.Parse(m.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return false
+	}
+	if !GetConfig().WebhookAllowPrivateNetworks && !isPublicHost(u.Hostname()) {
+		return false
+	}
+	if len(m.Events) == 0 {
+		return false
+	}
+	for _, event := range m.Events {
+		if !router.isValidEvent(event) {
+			return false
+		}
+	}
+	return true
+}
+
+func (router *WebhookRouter) isValidEvent(event string) bool {
+	for _, item := range WebhookEvents {
+		if item == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (router *WebhookRouter) copyFromRestModel(m *CreateWebhookRequest) *Webhook {
+	e := &Webhook{}
+	e.URL = m.URL
+	e.Secret = m.Secret
+	e.Events = m.Events
+	e.Active = m.Active
+	return e
+}
+
+func (router *WebhookRouter) copyToRestModel(e *Webhook) *GetWebhookResponse {
+	m := &GetWebhookResponse{}
+	m.ID = e.ID
+	m.URL = e.URL
+	m.Events = e.Events
+	m.Active = e.Active
+	return m
+}
+
+func (router *WebhookRouter) copyDeliveryToRestModel(e *WebhookDelivery) *GetWebhookDeliveryResponse {
+	m := &GetWebhookDeliveryResponse{}
+	m.ID = e.ID
+	m.WebhookID = e.WebhookID
+	m.Event = e.Event
+	m.Payload = e.Payload
+	m.Created = e.Created
+	m.Attempts = e.Attempts
+	m.NextAttempt = e.NextAttempt
+	m.LastAttempt = e.LastAttempt
+	m.LastStatusCode = e.LastStatusCode
+	m.LastError = e.LastError
+	m.Delivered = e.Delivered
+	return m
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhooksCRUD(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+	user := createTestUserInOrg(org)
+
+	// Non-admin
+	payload := `{"url": "https://hooks.test.com/seatsurfing", "events": ["booking.created"], "active": true}`
+	req := newHTTPRequest("POST", "/webhook/", user.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusForbidden, res.Code)
+
+	// Invalid event
+	payload = `{"url": "https://hooks.test.com/seatsurfing", "events": ["booking.invalid"], "active": true}`
+	req = newHTTPRequest("POST", "/webhook/", admin.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+
+	// Invalid URL
+	payload = `{"url": "ftp://hooks.test.com/seatsurfing", "events": ["booking.created"], "active": true}`
+	req = newHTTPRequest("POST", "/webhook/", admin.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+
+	// Internal targets
+	for _, url := range []string{"http://127.0.0.1:8080/", "http://localhost/", "http://10.1.2.3/", "http://169.254.169.254/latest/meta-data/", "http://[::1]/"} {
+		payload = `{"url": "` + url + `", "events": ["booking.created"], "active": true}`
+		req = newHTTPRequest("POST", "/webhook/", admin.ID, bytes.NewBufferString(payload))
+		res = executeTestRequest(req)
+		checkTestResponseCode(t, http.StatusBadRequest, res.Code)
+	}
+
+	// 1. Create returns the secret
+	payload = `{"url": "https://hooks.test.com/seatsurfing", "events": ["booking.created", "user.deleted"], "active": true}`
+	req = newHTTPRequest("POST", "/webhook/", admin.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	id := res.Header().Get("X-Object-Id")
+	var createBody *GetWebhookResponse
+	json.Unmarshal(res.Body.Bytes(), &createBody)
+	checkTestString(t, id, createBody.ID)
+	checkTestInt(t, 64, len(createBody.Secret))
+
+	// 2. Read
+	req = newHTTPRequest("GET", "/webhook/"+id, admin.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody *GetWebhookResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestString(t, "https://hooks.test.com/seatsurfing", resBody.URL)
+	checkTestInt(t, 2, len(resBody.Events))
+	checkTestString(t, "", resBody.Secret)
+	checkTestBool(t, true, resBody.Active)
+
+	// 3. Update
+	payload = `{"url": "https://hooks.test.com/other", "events": ["space.created"], "active": false}`
+	req = newHTTPRequest("PUT", "/webhook/"+id, admin.ID, bytes.NewBufferString(payload))
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	req = newHTTPRequest("GET", "/webhook/", admin.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody2 []*GetWebhookResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody2)
+	checkTestInt(t, 1, len(resBody2))
+	checkTestString(t, "https://hooks.test.com/other", resBody2[0].URL)
+	checkTestString(t, "space.created", resBody2[0].Events[0])
+	e, _ := testRepositories.Webhook.GetOne(id)
+	checkTestString(t, createBody.Secret, e.Secret)
+	checkTestBool(t, false, resBody2[0].Active)
+
+	// 4. Delete
+	req = newHTTPRequest("DELETE", "/webhook/"+id, admin.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNoContent, res.Code)
+
+	req = newHTTPRequest("GET", "/webhook/"+id, admin.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusNotFound, res.Code)
+}
+
+func TestWebhooksDelivery(t *testing.T) {
+	clearTestDB()
+	// The test server listens on the loopback interface
+	GetConfig().WebhookAllowPrivateNetworks = true
+	defer func() { GetConfig().WebhookAllowPrivateNetworks = false }()
+	org := createTestOrg("test.com")
+	admin := createTestUserOrgAdmin(org)
+
+	type receivedRequest struct {
+		Event     string
+		Signature string
+		Body      string
+	}
+	received := make(chan receivedRequest, 10)
+	statusCode := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- receivedRequest{
+			Event:     r.Header.Get("X-Seatsurfing-Event"),
+			Signature: r.Header.Get("X-Seatsurfing-Signature"),
+			Body:      string(body),
+		}
+		w.WriteHeader(statusCode)
+	}))
+	defer server.Close()
+
+	e := &Webhook{
+		OrganizationID: org.ID,
+		URL:            server.URL,
+		Secret:         "secret",
+		Events:         []string{WebhookEventSpaceCreated},
+		Active:         true,
+	}
+	testRepositories.Webhook.Create(e)
+	l := &Location{
+		Name:           "Test",
+		OrganizationID: org.ID,
+	}
+	testRepositories.Location.Create(l)
+
+	payload := `{"name": "H234", "x": 50, "y": 100, "width": 200, "height": 300, "rotation": 90}`
+	req := newHTTPRequest("POST", "/location/"+l.ID+"/space/", admin.ID, bytes.NewBufferString(payload))
+	res := executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusCreated, res.Code)
+	spaceID := res.Header().Get("X-Object-Id")
+
+	var item receivedRequest
+	select {
+	case item = <-received:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("Expected webhook delivery")
+	}
+	checkTestString(t, WebhookEventSpaceCreated, item.Event)
+	router := &WebhookRouter{repos: testRepositories}
+	checkTestString(t, "sha256="+router.sign("secret", item.Body), item.Signature)
+	var body *WebhookPayload
+	json.Unmarshal([]byte(item.Body), &body)
+	checkTestString(t, org.ID, body.OrganizationID)
+	checkTestString(t, spaceID, body.Data.(map[string]interface{})["id"].(string))
+
+	// Failed delivery is scheduled for retry
+	list, _ := testRepositories.WebhookDelivery.GetAll(e.ID, 10)
+	checkTestInt(t, 1, len(list))
+	statusCode = http.StatusInternalServerError
+	req = newHTTPRequest("POST", "/webhook/"+e.ID+"/delivery/"+list[0].ID+"/redeliver", admin.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	<-received
+	var resBody *GetWebhookDeliveryResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody)
+	checkTestBool(t, false, resBody.Delivered)
+	checkTestInt(t, http.StatusInternalServerError, resBody.LastStatusCode)
+	checkTestInt(t, 1, resBody.Attempts)
+	checkTestBool(t, true, resBody.NextAttempt != nil)
+
+	req = newHTTPRequest("GET", "/webhook/"+e.ID+"/delivery/", admin.ID, nil)
+	res = executeTestRequest(req)
+	checkTestResponseCode(t, http.StatusOK, res.Code)
+	var resBody2 []*GetWebhookDeliveryResponse
+	json.Unmarshal(res.Body.Bytes(), &resBody2)
+	checkTestInt(t, 1, len(resBody2))
+	checkTestString(t, WebhookEventSpaceCreated, resBody2[0].Event)
+}
+
+func TestWebhooksDeliveryPrivateNetwork(t *testing.T) {
+	clearTestDB()
+	org := createTestOrg("test.com")
+
+	received := make(chan bool, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- true
+	}))
+	defer server.Close()
+
+	// Stored before the target was checked, e.g. with a host name resolving to another address later
+	e := &Webhook{
+		OrganizationID: org.ID,
+		URL:            server.URL,
+		Secret:         "secret",
+		Events:         []string{WebhookEventSpaceCreated},
+		Active:         true,
+	}
+	testRepositories.Webhook.Create(e)
+	delivery := &WebhookDelivery{
+		WebhookID: e.ID,
+		Event:     WebhookEventSpaceCreated,
+		Payload:   "{}",
+		Created:   time.Now().UTC(),
+	}
+	testRepositories.WebhookDelivery.Create(delivery)
+
+	router := &WebhookRouter{repos: testRepositories}
+	router.deliver(e, delivery)
+	checkTestBool(t, false, delivery.Delivered)
+	checkTestInt(t, 0, delivery.LastStatusCode)
+	checkStringNotEmpty(t, delivery.LastError)
+	select {
+	case <-received:
+		t.Fatalf("Expected no request to the internal target")
+	default:
+	}
+}